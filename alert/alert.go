@@ -0,0 +1,134 @@
+// Package alert notifies operators when the publishing of advertisements runs
+// into trouble, so that stale indexes are noticed as they happen rather than
+// days later.
+//
+// A Monitor watches an engine.Engine and raises an Alert when announcements
+// fail repeatedly, when indexers lag behind in ingesting the advertisements of
+// the provider, or when announcements pile up. Each alert is sent to the
+// configured notifiers once when raised, and once more when resolved. Alerts
+// can be posted to a webhook via Webhook, or passed to a command via Command.
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// Kind is the kind of condition that an Alert is raised for.
+type Kind string
+
+const (
+	// AnnounceFailures is raised when announcements of advertisements fail
+	// consecutively. See: WithMaxAnnounceFailures.
+	AnnounceFailures Kind = "announce-failures"
+	// IngestionLag is raised when an indexer lags behind in ingesting the
+	// advertisements of the provider. See: WithIngestionLag.
+	IngestionLag Kind = "ingestion-lag"
+	// PublishBacklog is raised when announcements pile up because publishing
+	// outpaces announcing. See: WithMaxPendingAnnounces.
+	PublishBacklog Kind = "publish-backlog"
+)
+
+// Alert describes a condition that requires the attention of operators.
+type Alert struct {
+	// Kind is the kind of condition.
+	Kind Kind `json:"kind"`
+	// Subject is what the condition is about, such as the URL of the indexer
+	// that lags behind, if any.
+	Subject string `json:"subject,omitempty"`
+	// Provider is the ID of the provider.
+	Provider string `json:"provider"`
+	// Message describes the condition.
+	Message string `json:"message"`
+	// Resolved is whether the condition is over.
+	Resolved bool `json:"resolved"`
+	// Time is the time at which the alert was raised or resolved.
+	Time time.Time `json:"time"`
+}
+
+// Notifier sends alerts to operators.
+type Notifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// Webhook is a Notifier that posts alerts as JSON to a URL.
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhook instantiates a Webhook that posts alerts to the given URL using
+// the given HTTP client, or http.DefaultClient if nil.
+func NewWebhook(url string, client *http.Client) *Webhook {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Webhook{url: url, client: client}
+}
+
+// Notify posts the alert as JSON to the URL of the webhook, and fails unless
+// the response has a 2xx status.
+func (w *Webhook) Notify(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook responded with status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// Command is a Notifier that runs a command for each alert.
+type Command struct {
+	name string
+	args []string
+}
+
+// NewCommand instantiates a Command that runs the named program with the given
+// arguments for each alert. The alert is written as JSON to the standard input
+// of the program, and also set in the ALERT_KIND, ALERT_SUBJECT,
+// ALERT_PROVIDER, ALERT_MESSAGE and ALERT_RESOLVED environment variables.
+func NewCommand(name string, args ...string) *Command {
+	return &Command{name: name, args: args}
+}
+
+// Notify runs the command, and fails if it exits with a non-zero status.
+func (c *Command) Notify(ctx context.Context, alert Alert) error {
+	input, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, c.name, c.args...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Env = append(os.Environ(),
+		"ALERT_KIND="+string(alert.Kind),
+		"ALERT_SUBJECT="+alert.Subject,
+		"ALERT_PROVIDER="+alert.Provider,
+		"ALERT_MESSAGE="+alert.Message,
+		"ALERT_RESOLVED="+strconv.FormatBool(alert.Resolved))
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("alert command failed: %w: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
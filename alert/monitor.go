@@ -0,0 +1,220 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/ipni/go-libipni/apierror"
+	findclient "github.com/ipni/go-libipni/find/client"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+var log = logging.Logger("provider/alert")
+
+// Monitor watches an engine and notifies operators of the conditions that
+// require their attention. See: Kind.
+type Monitor struct {
+	*options
+	e          *engine.Engine
+	providerID peer.ID
+	indexers   map[string]*findclient.Client
+
+	// raised holds the alerts that are raised and not yet resolved, and
+	// announceFailures the number of consecutive announce failures. Both are
+	// only accessed by the monitoring goroutine.
+	raised           map[alertKey]struct{}
+	announceFailures int
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type alertKey struct {
+	kind    Kind
+	subject string
+}
+
+// New instantiates a new Monitor of the given engine, which publishes the
+// advertisements of the given provider. Monitoring starts once Monitor.Start
+// is called.
+func New(e *engine.Engine, providerID peer.ID, o ...Option) (*Monitor, error) {
+	opts, err := newOptions(o...)
+	if err != nil {
+		return nil, err
+	}
+	indexers := make(map[string]*findclient.Client, len(opts.indexers))
+	for _, indexer := range opts.indexers {
+		client, err := findclient.New(indexer, findclient.WithClient(opts.httpClient))
+		if err != nil {
+			return nil, fmt.Errorf("bad indexer URL %q: %w", indexer, err)
+		}
+		indexers[indexer] = client
+	}
+	return &Monitor{
+		options:    opts,
+		e:          e,
+		providerID: providerID,
+		indexers:   indexers,
+		raised:     make(map[alertKey]struct{}),
+		done:       make(chan struct{}),
+	}, nil
+}
+
+// Start starts monitoring the engine in the background until Monitor.Close is
+// called or the engine is shut down.
+func (m *Monitor) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	events, unsubscribe := m.e.SubscribePublishEvents()
+	go func() {
+		defer close(m.done)
+		defer unsubscribe()
+		m.run(ctx, events)
+	}()
+}
+
+// Close stops monitoring and waits for alerts that are being sent.
+func (m *Monitor) Close() {
+	if m.cancel == nil {
+		return
+	}
+	m.cancel()
+	<-m.done
+}
+
+func (m *Monitor) run(ctx context.Context, events <-chan engine.PublishEvent) {
+	ticker := time.NewTicker(m.checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.Kind == engine.AdAnnounced {
+				m.announced(ctx, event)
+			}
+		case <-ticker.C:
+			m.check(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// announced raises an AnnounceFailures alert once announcements failed
+// consecutively as many times as allowed, and resolves it once one succeeds.
+func (m *Monitor) announced(ctx context.Context, event engine.PublishEvent) {
+	if m.maxAnnounceFailures == 0 {
+		return
+	}
+	if event.Err == nil {
+		m.announceFailures = 0
+		m.resolve(ctx, AnnounceFailures, "", fmt.Sprintf("Announced advertisement %s", event.AdCid))
+		return
+	}
+	m.announceFailures++
+	if m.announceFailures >= m.maxAnnounceFailures {
+		m.raise(ctx, AnnounceFailures, "", fmt.Sprintf("%d consecutive announcements failed, last of advertisement %s: %v",
+			m.announceFailures, event.AdCid, event.Err))
+	}
+}
+
+// check checks the number of pending announcements and the ingestion lag of
+// indexers.
+func (m *Monitor) check(ctx context.Context) {
+	if m.maxPendingAnnounces != 0 {
+		if pending := m.e.PendingAnnounces(); pending >= m.maxPendingAnnounces {
+			m.raise(ctx, PublishBacklog, "", fmt.Sprintf("%d announcements are pending", pending))
+		} else {
+			m.resolve(ctx, PublishBacklog, "", fmt.Sprintf("%d announcements are pending", pending))
+		}
+	}
+
+	if len(m.indexers) == 0 {
+		return
+	}
+	head, _, err := m.e.GetLatestAdv(ctx)
+	if err != nil {
+		log.Errorw("Failed to get latest advertisement to check ingestion lag", "err", err)
+		return
+	}
+	for indexer, client := range m.indexers {
+		lag, err := m.ingestionLag(ctx, client, head)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Warnw("Failed to check ingestion lag", "indexer", indexer, "err", err)
+			}
+			continue
+		}
+		if lag > m.maxIngestionLag {
+			m.raise(ctx, IngestionLag, indexer, fmt.Sprintf("Indexer lag exceeds %d advertisements", m.maxIngestionLag))
+		} else {
+			m.resolve(ctx, IngestionLag, indexer, fmt.Sprintf("Indexer lag is %d advertisements", lag))
+		}
+	}
+}
+
+// ingestionLag counts the advertisements from head back to the latest one
+// ingested by the indexer, up to one more than the maximum ingestion lag.
+func (m *Monitor) ingestionLag(ctx context.Context, client *findclient.Client, head cid.Cid) (int, error) {
+	var ingested cid.Cid
+	info, err := client.GetProvider(ctx, m.providerID)
+	if err != nil {
+		// An indexer that does not know the provider has ingested nothing.
+		var apiErr *apierror.Error
+		if !errors.As(err, &apiErr) || apiErr.Status() != http.StatusNotFound {
+			return 0, err
+		}
+	} else {
+		ingested = info.LastAdvertisement
+	}
+
+	var lag int
+	for c := head; c != cid.Undef && c != ingested && lag <= m.maxIngestionLag; lag++ {
+		ad, err := m.e.GetAdInfo(ctx, c)
+		if err != nil {
+			return 0, err
+		}
+		c = ad.Advertisement.PreviousCid()
+	}
+	return lag, nil
+}
+
+func (m *Monitor) raise(ctx context.Context, kind Kind, subject, message string) {
+	key := alertKey{kind: kind, subject: subject}
+	if _, ok := m.raised[key]; ok {
+		return
+	}
+	m.raised[key] = struct{}{}
+	log.Warnw("Raising alert", "kind", kind, "subject", subject, "message", message)
+	m.notify(ctx, Alert{Kind: kind, Subject: subject, Message: message})
+}
+
+func (m *Monitor) resolve(ctx context.Context, kind Kind, subject, message string) {
+	key := alertKey{kind: kind, subject: subject}
+	if _, ok := m.raised[key]; !ok {
+		return
+	}
+	delete(m.raised, key)
+	log.Infow("Resolving alert", "kind", kind, "subject", subject, "message", message)
+	m.notify(ctx, Alert{Kind: kind, Subject: subject, Message: message, Resolved: true})
+}
+
+func (m *Monitor) notify(ctx context.Context, alert Alert) {
+	alert.Provider = m.providerID.String()
+	alert.Time = time.Now().UTC()
+	for _, n := range m.notifiers {
+		nctx, cancel := context.WithTimeout(ctx, m.notifyTimeout)
+		if err := n.Notify(nctx, alert); err != nil {
+			log.Errorw("Failed to send alert", "kind", alert.Kind, "err", err)
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,199 @@
+package alert_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/go-libipni/find/model"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/alert"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+const testTimeout = 10 * time.Second
+
+type recorder chan alert.Alert
+
+func (r recorder) Notify(_ context.Context, a alert.Alert) error {
+	r <- a
+	return nil
+}
+
+func (r recorder) next(t *testing.T) alert.Alert {
+	select {
+	case a := <-r:
+		return a
+	case <-time.After(testTimeout):
+		t.Fatal("timed out waiting for alert")
+		return alert.Alert{}
+	}
+}
+
+func newEngine(t *testing.T, indexerURL string) *engine.Engine {
+	subject, err := engine.New(
+		engine.WithPublisherKind(engine.HttpPublisher),
+		engine.WithHttpPublisherWithoutServer(),
+		engine.WithHttpPublisherAnnounceAddr("/ip4/127.0.0.1/tcp/3104/http"),
+		engine.WithPubsubAnnounce(false),
+		engine.WithDirectAnnounce(indexerURL))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(context.Background()))
+	t.Cleanup(func() { subject.Shutdown() })
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+	return subject
+}
+
+func TestMonitor_AnnounceFailures(t *testing.T) {
+	ctx := context.Background()
+	var down atomic.Bool
+	down.Store(true)
+	indexer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(indexer.Close)
+	eng := newEngine(t, indexer.URL)
+	providerID, _, _ := test.RandomIdentity()
+
+	alerts := make(recorder, 10)
+	subject, err := alert.New(eng, providerID, alert.WithNotifier(alerts), alert.WithMaxAnnounceFailures(2))
+	require.NoError(t, err)
+	subject.Start()
+	t.Cleanup(subject.Close)
+
+	for _, contextID := range []string{"fish", "lobster", "crab"} {
+		_, err = eng.NotifyPut(ctx, nil, []byte(contextID), metadata.Default.New(metadata.Bitswap{}))
+		require.NoError(t, err)
+	}
+	raised := alerts.next(t)
+	require.Equal(t, alert.AnnounceFailures, raised.Kind)
+	require.Equal(t, providerID.String(), raised.Provider)
+	require.Contains(t, raised.Message, "2 consecutive announcements failed")
+	require.False(t, raised.Resolved)
+
+	down.Store(false)
+	_, err = eng.NotifyPut(ctx, nil, []byte("squid"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	resolved := alerts.next(t)
+	require.Equal(t, alert.AnnounceFailures, resolved.Kind)
+	require.True(t, resolved.Resolved)
+	require.Empty(t, alerts)
+}
+
+func TestMonitor_IngestionLag(t *testing.T) {
+	ctx := context.Background()
+	announcer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(announcer.Close)
+	eng := newEngine(t, announcer.URL)
+	providerID, _, _ := test.RandomIdentity()
+
+	var ingested atomic.Value
+	ingested.Store(cid.Undef)
+	indexer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/providers/"+providerID.String(), r.URL.Path)
+		last := ingested.Load().(cid.Cid)
+		if last == cid.Undef {
+			http.Error(w, "provider not found", http.StatusNotFound)
+			return
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(&model.ProviderInfo{
+			AddrInfo:          peer.AddrInfo{ID: providerID},
+			LastAdvertisement: last,
+		}))
+	}))
+	t.Cleanup(indexer.Close)
+
+	var adCids []cid.Cid
+	for _, contextID := range []string{"fish", "lobster", "crab"} {
+		adCid, err := eng.NotifyPut(ctx, nil, []byte(contextID), metadata.Default.New(metadata.Bitswap{}))
+		require.NoError(t, err)
+		adCids = append(adCids, adCid)
+	}
+
+	alerts := make(recorder, 10)
+	subject, err := alert.New(eng, providerID,
+		alert.WithNotifier(alerts),
+		alert.WithIngestionLag(1, indexer.URL),
+		alert.WithCheckInterval(10*time.Millisecond))
+	require.NoError(t, err)
+	subject.Start()
+	t.Cleanup(subject.Close)
+
+	raised := alerts.next(t)
+	require.Equal(t, alert.IngestionLag, raised.Kind)
+	require.Equal(t, indexer.URL, raised.Subject)
+	require.False(t, raised.Resolved)
+
+	// Lagging one advertisement behind is tolerated.
+	ingested.Store(adCids[1])
+	resolved := alerts.next(t)
+	require.Equal(t, alert.IngestionLag, resolved.Kind)
+	require.True(t, resolved.Resolved)
+	require.Contains(t, resolved.Message, "lag is 1 advertisements")
+}
+
+func TestNew_RequiresNotifier(t *testing.T) {
+	_, err := alert.New(nil, "")
+	require.ErrorContains(t, err, "notifier")
+}
+
+func TestWebhook(t *testing.T) {
+	var got alert.Alert
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		if got.Resolved {
+			http.Error(w, "fish", http.StatusBadGateway)
+		}
+	}))
+	t.Cleanup(webhook.Close)
+
+	subject := alert.NewWebhook(webhook.URL, nil)
+	want := alert.Alert{Kind: alert.PublishBacklog, Provider: "fish", Message: "lobster", Time: time.Now().UTC()}
+	require.NoError(t, subject.Notify(context.Background(), want))
+	require.Equal(t, want.Kind, got.Kind)
+	require.Equal(t, want.Message, got.Message)
+	require.True(t, want.Time.Equal(got.Time))
+
+	want.Resolved = true
+	require.ErrorContains(t, subject.Notify(context.Background(), want), "status 502: fish")
+}
+
+func TestCommand(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "alert")
+	subject := alert.NewCommand("sh", "-c", `{ cat; echo; echo "$ALERT_KIND $ALERT_RESOLVED"; } > "$0"`, out)
+	want := alert.Alert{Kind: alert.AnnounceFailures, Message: "fish", Resolved: true}
+	require.NoError(t, subject.Notify(context.Background(), want))
+
+	written, err := os.ReadFile(out)
+	require.NoError(t, err)
+	var got alert.Alert
+	dec := json.NewDecoder(bytes.NewReader(written))
+	require.NoError(t, dec.Decode(&got))
+	require.Equal(t, want.Message, got.Message)
+	require.Contains(t, string(written), "\nannounce-failures true\n")
+
+	failing := alert.NewCommand("sh", "-c", "echo fish >&2; exit 3")
+	require.ErrorContains(t, failing.Notify(context.Background(), want), "fish")
+}
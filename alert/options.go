@@ -0,0 +1,133 @@
+package alert
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultCheckInterval       = time.Minute
+	defaultMaxAnnounceFailures = 3
+	defaultNotifyTimeout       = 30 * time.Second
+)
+
+type (
+	Option  func(*options) error
+	options struct {
+		notifiers           []Notifier
+		checkInterval       time.Duration
+		maxAnnounceFailures int
+		indexers            []string
+		maxIngestionLag     int
+		maxPendingAnnounces int
+		notifyTimeout       time.Duration
+		httpClient          *http.Client
+	}
+)
+
+func newOptions(o ...Option) (*options, error) {
+	opts := options{
+		checkInterval:       defaultCheckInterval,
+		maxAnnounceFailures: defaultMaxAnnounceFailures,
+		notifyTimeout:       defaultNotifyTimeout,
+		httpClient:          http.DefaultClient,
+	}
+	for _, apply := range o {
+		if err := apply(&opts); err != nil {
+			return nil, err
+		}
+	}
+	if len(opts.notifiers) == 0 {
+		return nil, errors.New("at least one notifier must be specified")
+	}
+	return &opts, nil
+}
+
+// WithNotifier adds a notifier to send alerts to. At least one notifier must
+// be specified. This option may be specified multiple times.
+func WithNotifier(n Notifier) Option {
+	return func(o *options) error {
+		if n == nil {
+			return errors.New("notifier must not be nil")
+		}
+		o.notifiers = append(o.notifiers, n)
+		return nil
+	}
+}
+
+// WithCheckInterval sets the interval at which the ingestion lag of indexers
+// and the pending announcements are checked. Defaults to 1 minute.
+func WithCheckInterval(d time.Duration) Option {
+	return func(o *options) error {
+		if d <= 0 {
+			return errors.New("check interval must be greater than zero")
+		}
+		o.checkInterval = d
+		return nil
+	}
+}
+
+// WithMaxAnnounceFailures sets the number of consecutive announce failures at
+// which an AnnounceFailures alert is raised. The alert is resolved by the next
+// successful announcement. Announce failures are not alerted if 0. Defaults to
+// 3.
+func WithMaxAnnounceFailures(n int) Option {
+	return func(o *options) error {
+		if n < 0 {
+			return errors.New("max announce failures must not be negative")
+		}
+		o.maxAnnounceFailures = n
+		return nil
+	}
+}
+
+// WithIngestionLag sets the URLs of the indexers whose ingestion lag is
+// checked, and the number of advertisements they may lag behind the latest
+// advertisement of the provider before an IngestionLag alert is raised.
+func WithIngestionLag(maxLag int, indexers ...string) Option {
+	return func(o *options) error {
+		if maxLag < 0 {
+			return errors.New("max ingestion lag must not be negative")
+		}
+		o.maxIngestionLag = maxLag
+		o.indexers = indexers
+		return nil
+	}
+}
+
+// WithMaxPendingAnnounces sets the number of pending announcements at which a
+// PublishBacklog alert is raised. Pending announcements are not alerted if 0,
+// which is the default.
+//
+// See: engine.Engine.PendingAnnounces.
+func WithMaxPendingAnnounces(n int) Option {
+	return func(o *options) error {
+		if n < 0 {
+			return errors.New("max pending announces must not be negative")
+		}
+		o.maxPendingAnnounces = n
+		return nil
+	}
+}
+
+// WithNotifyTimeout sets the maximum time that sending an alert to a notifier
+// may take. Defaults to 30 seconds.
+func WithNotifyTimeout(d time.Duration) Option {
+	return func(o *options) error {
+		if d <= 0 {
+			return errors.New("notify timeout must be greater than zero")
+		}
+		o.notifyTimeout = d
+		return nil
+	}
+}
+
+// WithHttpClient sets the HTTP client used to query indexers for the ingestion
+// lag. Defaults to http.DefaultClient.
+func WithHttpClient(c *http.Client) Option {
+	return func(o *options) error {
+		o.httpClient = c
+		return nil
+	}
+}
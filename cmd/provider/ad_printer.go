@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/index-provider/cmd/provider/internal"
+	"github.com/ipni/index-provider/engine"
+)
+
+const (
+	outputText   = "text"
+	outputJson   = "json"
+	outputNdjson = "ndjson"
+)
+
+type (
+	// adPrinter writes advertisements to an output in a specific format.
+	adPrinter interface {
+		print(ad *internal.Advertisement) error
+		// close flushes any buffered output.
+		close() error
+	}
+
+	// adOutput is the machine-readable representation of an advertisement.
+	adOutput struct {
+		ID          string     `json:"id"`
+		PreviousID  string     `json:"previousId,omitempty"`
+		ProviderID  string     `json:"providerId"`
+		Addresses   []string   `json:"addresses"`
+		ContextID   []byte     `json:"contextId"`
+		Metadata    []byte     `json:"metadata"`
+		IsRemove    bool       `json:"isRemove"`
+		Sequence    *uint64    `json:"sequence,omitempty"`
+		Timestamp   *time.Time `json:"timestamp,omitempty"`
+		Entries     string     `json:"entries,omitempty"`
+		ChunkCount  *int       `json:"chunkCount,omitempty"`
+		Multihashes []string   `json:"multihashes,omitempty"`
+	}
+
+	textAdPrinter struct {
+		w            io.Writer
+		printEntries bool
+		count        int
+	}
+
+	jsonAdPrinter struct {
+		w            io.Writer
+		printEntries bool
+		ads          []*adOutput
+	}
+
+	ndjsonAdPrinter struct {
+		enc          *json.Encoder
+		printEntries bool
+	}
+)
+
+// newAdPrinter instantiates an adPrinter for the given output format. The
+// entries of each advertisement are fetched and printed if printEntries is
+// set.
+func newAdPrinter(w io.Writer, output string, printEntries bool) (adPrinter, error) {
+	switch output {
+	case outputText:
+		return &textAdPrinter{w: w, printEntries: printEntries}, nil
+	case outputJson:
+		return &jsonAdPrinter{w: w, printEntries: printEntries}, nil
+	case outputNdjson:
+		return &ndjsonAdPrinter{enc: json.NewEncoder(w), printEntries: printEntries}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q; must be one of %s, %s or %s", output, outputText, outputJson, outputNdjson)
+	}
+}
+
+func newAdOutput(ad *internal.Advertisement, printEntries bool) (*adOutput, error) {
+	out := &adOutput{
+		ID:         ad.ID.String(),
+		ProviderID: ad.ProviderID.String(),
+		Addresses:  ad.Addresses,
+		ContextID:  ad.ContextID,
+		Metadata:   ad.Metadata,
+		IsRemove:   ad.IsRemove,
+	}
+	if ad.PreviousID != cid.Undef {
+		out.PreviousID = ad.PreviousID.String()
+	}
+	if seq, err := engine.GetAdSequence(ad.Metadata); err == nil && seq != nil {
+		out.Sequence = &seq.Seq
+		out.Timestamp = &seq.Time
+	}
+	if ad.Entries.Root() != cid.Undef {
+		out.Entries = ad.Entries.Root().String()
+	}
+	if printEntries && ad.HasEntries() {
+		mhs, err := ad.Entries.Drain()
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch entries of advertisement %s: %w", ad.ID, err)
+		}
+		out.Multihashes = make([]string, 0, len(mhs))
+		for _, mh := range mhs {
+			out.Multihashes = append(out.Multihashes, mh.B58String())
+		}
+		chunkCount := ad.Entries.ChunkCount()
+		out.ChunkCount = &chunkCount
+	}
+	return out, nil
+}
+
+func (p *textAdPrinter) print(ad *internal.Advertisement) error {
+	out, err := newAdOutput(ad, p.printEntries)
+	if err != nil {
+		return err
+	}
+	if p.count != 0 {
+		fmt.Fprintln(p.w)
+	}
+	p.count++
+	fmt.Fprintln(p.w, "ID:         ", out.ID)
+	fmt.Fprintln(p.w, "PreviousID: ", out.PreviousID)
+	fmt.Fprintln(p.w, "ProviderID: ", out.ProviderID)
+	fmt.Fprintln(p.w, "Addresses:  ", out.Addresses)
+	fmt.Fprintln(p.w, "ContextID:  ", base64.StdEncoding.EncodeToString(out.ContextID))
+	fmt.Fprintln(p.w, "Metadata:   ", base64.StdEncoding.EncodeToString(out.Metadata))
+	fmt.Fprintln(p.w, "Is Remove:  ", out.IsRemove)
+	if out.Sequence != nil {
+		fmt.Fprintln(p.w, "Sequence:   ", *out.Sequence)
+		fmt.Fprintln(p.w, "Timestamp:  ", out.Timestamp.Format(time.RFC3339Nano))
+	}
+	fmt.Fprintln(p.w, "Entries:    ", out.Entries)
+	if out.ChunkCount != nil {
+		fmt.Fprintln(p.w, "  Chunk Count:", *out.ChunkCount)
+		fmt.Fprintln(p.w, "  Total Count:", len(out.Multihashes))
+		for _, mh := range out.Multihashes {
+			fmt.Fprintln(p.w, "  ", mh)
+		}
+	}
+	return nil
+}
+
+func (p *textAdPrinter) close() error {
+	return nil
+}
+
+func (p *jsonAdPrinter) print(ad *internal.Advertisement) error {
+	out, err := newAdOutput(ad, p.printEntries)
+	if err != nil {
+		return err
+	}
+	p.ads = append(p.ads, out)
+	return nil
+}
+
+func (p *jsonAdPrinter) close() error {
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "  ")
+	if p.ads == nil {
+		p.ads = []*adOutput{}
+	}
+	return enc.Encode(p.ads)
+}
+
+func (p *ndjsonAdPrinter) print(ad *internal.Advertisement) error {
+	out, err := newAdOutput(ad, p.printEntries)
+	if err != nil {
+		return err
+	}
+	return p.enc.Encode(out)
+}
+
+func (p *ndjsonAdPrinter) close() error {
+	return nil
+}
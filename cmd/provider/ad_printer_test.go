@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ipni/go-libipni/test"
+	"github.com/ipni/index-provider/cmd/provider/internal"
+	"github.com/stretchr/testify/require"
+)
+
+func testAds(t *testing.T, n int) []*internal.Advertisement {
+	providerID, _, _ := test.RandomIdentity()
+	cids := test.RandomCids(n)
+	ads := make([]*internal.Advertisement, 0, n)
+	for i, c := range cids {
+		ad := &internal.Advertisement{
+			ID:         c,
+			ProviderID: providerID,
+			Addresses:  []string{"/ip4/127.0.0.1/tcp/9999"},
+			ContextID:  []byte("fish"),
+			Metadata:   []byte("lobster"),
+			IsRemove:   i%2 == 1,
+			Entries:    &internal.EntriesIterator{},
+		}
+		if i+1 < len(cids) {
+			ad.PreviousID = cids[i+1]
+		}
+		ads = append(ads, ad)
+	}
+	return ads
+}
+
+func Test_adPrinter_Json(t *testing.T) {
+	ads := testAds(t, 2)
+	var buf bytes.Buffer
+	printer, err := newAdPrinter(&buf, outputJson, false)
+	require.NoError(t, err)
+	for _, ad := range ads {
+		require.NoError(t, printer.print(ad))
+	}
+	require.NoError(t, printer.close())
+
+	var got []adOutput
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	require.Len(t, got, 2)
+	require.Equal(t, ads[0].ID.String(), got[0].ID)
+	require.Equal(t, ads[1].ID.String(), got[0].PreviousID)
+	require.Equal(t, ads[0].ProviderID.String(), got[0].ProviderID)
+	require.Equal(t, []byte("fish"), got[0].ContextID)
+	require.False(t, got[0].IsRemove)
+	require.True(t, got[1].IsRemove)
+	require.Empty(t, got[1].PreviousID)
+	require.Nil(t, got[0].ChunkCount)
+}
+
+func Test_adPrinter_JsonEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	printer, err := newAdPrinter(&buf, outputJson, false)
+	require.NoError(t, err)
+	require.NoError(t, printer.close())
+	require.Equal(t, "[]\n", buf.String())
+}
+
+func Test_adPrinter_Ndjson(t *testing.T) {
+	ads := testAds(t, 3)
+	var buf bytes.Buffer
+	printer, err := newAdPrinter(&buf, outputNdjson, false)
+	require.NoError(t, err)
+	for _, ad := range ads {
+		require.NoError(t, printer.print(ad))
+	}
+	require.NoError(t, printer.close())
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	for i, line := range lines {
+		var got adOutput
+		require.NoError(t, json.Unmarshal([]byte(line), &got))
+		require.Equal(t, ads[i].ID.String(), got.ID)
+	}
+}
+
+func Test_newAdPrinter_UnknownOutput(t *testing.T) {
+	_, err := newAdPrinter(&bytes.Buffer{}, "yaml", false)
+	require.ErrorContains(t, err, "unknown output format")
+}
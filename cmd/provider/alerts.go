@@ -0,0 +1,28 @@
+package main
+
+import (
+	"time"
+
+	"github.com/ipni/index-provider/alert"
+	"github.com/ipni/index-provider/cmd/provider/internal/config"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// newAlertMonitor instantiates the monitor that sends the alerts configured by
+// cfg about the engine publishing the advertisements of the given provider.
+func newAlertMonitor(eng *engine.Engine, providerID peer.ID, cfg config.Alerts) (*alert.Monitor, error) {
+	opts := []alert.Option{
+		alert.WithMaxAnnounceFailures(cfg.MaxAnnounceFailures),
+		alert.WithIngestionLag(cfg.MaxIngestionLag, cfg.Indexers...),
+		alert.WithMaxPendingAnnounces(cfg.MaxPendingAnnounces),
+		alert.WithCheckInterval(time.Duration(cfg.CheckInterval)),
+	}
+	if cfg.WebhookURL != "" {
+		opts = append(opts, alert.WithNotifier(alert.NewWebhook(cfg.WebhookURL, nil)))
+	}
+	if len(cfg.Command) != 0 {
+		opts = append(opts, alert.WithNotifier(alert.NewCommand(cfg.Command[0], cfg.Command[1:]...)))
+	}
+	return alert.New(eng, providerID, opts...)
+}
@@ -1,18 +1,19 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
-	"net/http"
+	"net/url"
 
-	adminserver "github.com/ipni/index-provider/server/admin/http"
 	"github.com/urfave/cli/v2"
 )
 
 var AnnounceCmd = &cli.Command{
-	Name:   "announce",
-	Usage:  "Publish an announcement message for the latest advertisement",
+	Name:      "announce",
+	Usage:     "Publish an announcement message for the latest advertisement",
+	ArgsUsage: "[indexer-url...]",
+	Description: `Publishes an announcement message for the latest advertisement using the announce senders
+configured on the provider daemon. When indexer URLs are given, the announcement is instead sent
+directly to each of the given indexers via HTTP.`,
 	Flags:  announceFlags,
 	Action: announceCommand,
 }
@@ -34,67 +35,42 @@ var announceHttpFlags = []cli.Flag{
 }
 
 func announceCommand(cctx *cli.Context) error {
-	req, err := http.NewRequestWithContext(cctx.Context, http.MethodPost, adminAPIFlagValue+"/admin/announce", nil)
+	if cctx.NArg() != 0 {
+		return announceHttp(cctx, cctx.Args().Slice())
+	}
+	client, err := newAdminClient()
 	if err != nil {
 		return err
 	}
-
-	cl := &http.Client{}
-	resp, err := cl.Do(req)
+	adCid, err := client.Announce(cctx.Context)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-
-	// Handle failed requests
-	if resp.StatusCode != http.StatusOK {
-		return errFromHttpResp(resp)
-	}
-
-	var res adminserver.AnnounceRes
-	if _, err := res.ReadFrom(resp.Body); err != nil {
-		return fmt.Errorf("received ok response from server but cannot decode response body. %v", err)
-	}
-	msg := fmt.Sprintf("Announced latest advertisement: %s\n", res.AdvId)
+	msg := fmt.Sprintf("Announced latest advertisement: %s\n", adCid)
 
 	_, err = cctx.App.Writer.Write([]byte(msg))
 	return err
 }
 
 func announceHttpCommand(cctx *cli.Context) error {
-	indexer := cctx.String("indexer")
+	return announceHttp(cctx, []string{cctx.String("indexer")})
+}
 
-	params := map[string][]byte{
-		"indexer": []byte(indexer),
-	}
-	bodyData, err := json.Marshal(&params)
-	if err != nil {
-		return err
+func announceHttp(cctx *cli.Context, indexers []string) error {
+	for _, indexer := range indexers {
+		if _, err := url.ParseRequestURI(indexer); err != nil {
+			return fmt.Errorf("invalid indexer url %q: %w", indexer, err)
+		}
 	}
-	body := bytes.NewBuffer(bodyData)
-
-	req, err := http.NewRequestWithContext(cctx.Context, http.MethodPost, adminAPIFlagValue+"/admin/announcehttp", body)
+	client, err := newAdminClient()
 	if err != nil {
 		return err
 	}
-	req.Header.Set("Content-Type", "application/json")
-
-	cl := &http.Client{}
-	resp, err := cl.Do(req)
+	adCid, err := client.AnnounceHttp(cctx.Context, indexers)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return errFromHttpResp(resp)
-	}
-
-	var res adminserver.AnnounceRes
-	if _, err := res.ReadFrom(resp.Body); err != nil {
-		return fmt.Errorf("received ok response from server but cannot decode response body. %v", err)
-	}
-	msg := fmt.Sprintf("Announced latest advertisement via HTTP: %s\n", res.AdvId)
+	msg := fmt.Sprintf("Announced latest advertisement via HTTP: %s\n", adCid)
 
 	_, err = cctx.App.Writer.Write([]byte(msg))
 	return err
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ipni/go-libipni/test"
+	adminserver "github.com/ipni/index-provider/server/admin/http"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestAnnounceCmd_AnnouncesToGivenIndexers(t *testing.T) {
+	adCid := test.RandomCids(1)[0]
+	var gotReq adminserver.AnnounceHttpReq
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/admin/announcehttp", r.URL.Path)
+		_, err := gotReq.ReadFrom(r.Body)
+		require.NoError(t, err)
+		_, err = (&adminserver.AnnounceRes{AdvId: adCid}).WriteTo(w)
+		require.NoError(t, err)
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	app := &cli.App{
+		Writer:   &out,
+		Commands: []*cli.Command{AnnounceCmd},
+	}
+	err := app.Run([]string{"provider", "announce", "-l", server.URL, "http://indexer-1:3001", "https://indexer-2"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"http://indexer-1:3001", "https://indexer-2"}, gotReq.Indexers)
+	require.Contains(t, out.String(), adCid.String())
+}
+
+func TestAnnounceCmd_RejectsInvalidIndexerURL(t *testing.T) {
+	app := &cli.App{
+		Writer:   &bytes.Buffer{},
+		Commands: []*cli.Command{AnnounceCmd},
+	}
+	err := app.Run([]string{"provider", "announce", "-l", "http://localhost:0", "not a url"})
+	require.ErrorContains(t, err, "invalid indexer url")
+}
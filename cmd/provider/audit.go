@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	adminserver "github.com/ipni/index-provider/server/admin/http"
+	"github.com/urfave/cli/v2"
+)
+
+// auditPageSize is the number of audit records fetched from the admin server
+// at a time.
+const auditPageSize = 1000
+
+var AuditCmd = &cli.Command{
+	Name:  "audit",
+	Usage: "Lists the audit log of the advertisements published by a running provider",
+	Description: `Fetches the records of the advertisements published by a running provider from its admin
+server, oldest first. Each record holds the CID, context ID, provider and kind of the advertisement,
+the number of multihashes advertised, the retrieval protocols of its metadata, and where it was
+announced to along with the outcome. Records are only kept if the audit log is enabled via the
+--audit-log flag of the daemon.
+
+The ndjson output format prints one JSON record per line, suitable for archiving.`,
+	Action: doAudit,
+	Flags: []cli.Flag{
+		adminAPIFlag,
+		&cli.Uint64Flag{
+			Name:  "after",
+			Usage: "The sequence number of the record after which to list records.",
+		},
+		&cli.IntFlag{
+			Name:  "limit",
+			Usage: "The maximum number of records to list. All records are listed if 0.",
+		},
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Usage:   "The output format, one of: table, ndjson.",
+			Value:   outputTable,
+		},
+	},
+}
+
+func doAudit(cctx *cli.Context) error {
+	output := cctx.String("output")
+	if output != outputTable && output != outputNdjson {
+		return fmt.Errorf("unknown output format %q; must be one of %s or %s", output, outputTable, outputNdjson)
+	}
+	limit := cctx.Int("limit")
+	if limit < 0 {
+		return fmt.Errorf("limit must not be negative, got %d", limit)
+	}
+
+	client, err := newAdminClient()
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(cctx.App.Writer)
+	tw := tabwriter.NewWriter(cctx.App.Writer, 0, 4, 2, ' ', 0)
+	if output == outputTable {
+		fmt.Fprintln(tw, "SEQ\tTIME\tAD CID\tKIND\tCONTEXT ID\tMULTIHASHES\tPROTOCOLS\tANNOUNCED")
+	}
+	after := cctx.Uint64("after")
+	var listed int
+	for limit == 0 || listed < limit {
+		pageSize := auditPageSize
+		if limit != 0 && limit-listed < pageSize {
+			pageSize = limit - listed
+		}
+		res, err := client.ListAudit(cctx.Context, after, pageSize)
+		if err != nil {
+			return err
+		}
+		for i := range res.Records {
+			record := &res.Records[i]
+			if output == outputNdjson {
+				if err = enc.Encode(record); err != nil {
+					return err
+				}
+			} else {
+				printAuditRecord(tw, record)
+			}
+			after = record.Seq
+			listed++
+		}
+		if res.Next == nil || len(res.Records) == 0 {
+			break
+		}
+	}
+	if output == outputTable {
+		return tw.Flush()
+	}
+	return nil
+}
+
+func printAuditRecord(tw *tabwriter.Writer, record *adminserver.AuditRecord) {
+	kind := "put"
+	if record.IsRm {
+		kind = "remove"
+	} else if record.IsUpdate {
+		kind = "update"
+	}
+	mhCount := "unknown"
+	if record.MultihashCount >= 0 {
+		mhCount = fmt.Sprint(record.MultihashCount)
+	}
+	if record.SkippedCount != 0 {
+		mhCount += fmt.Sprintf(" (%d skipped)", record.SkippedCount)
+	}
+	protocols := "-"
+	if len(record.Protocols) != 0 {
+		protocols = strings.Join(record.Protocols, ",")
+	}
+	fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", record.Seq, record.Time.Format(time.RFC3339),
+		record.AdID, kind, base64.StdEncoding.EncodeToString(record.ContextID), mhCount, protocols,
+		auditAnnounced(record.Announces))
+}
+
+// auditAnnounced summarizes the outcome of announcing an advertisement, e.g.
+// "2/3" if two of three announce senders succeeded.
+func auditAnnounced(announces []adminserver.AuditAnnounce) string {
+	if len(announces) == 0 {
+		return "no"
+	}
+	var ok int
+	for _, a := range announces {
+		if a.Error == "" {
+			ok++
+		}
+	}
+	return fmt.Sprintf("%d/%d", ok, len(announces))
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipni/go-libipni/test"
+	adminserver "github.com/ipni/index-provider/server/admin/http"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestAuditCmd(t *testing.T) {
+	adCids := test.RandomCids(3)
+	published := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/admin/audit", r.URL.Path)
+		res := &adminserver.ListAuditRes{}
+		switch r.URL.Query().Get("after") {
+		case "":
+			next := uint64(2)
+			res.Next = &next
+			res.Records = []adminserver.AuditRecord{
+				{
+					Seq:            1,
+					Time:           published,
+					AdID:           adCids[0],
+					ContextID:      []byte("fish"),
+					MultihashCount: 7,
+					Protocols:      []string{"transport-bitswap"},
+					Announces: []adminserver.AuditAnnounce{
+						{Targets: []string{"http://indexer.example/announce"}},
+						{Targets: []string{"pubsub:/indexer/ingest/mainnet"}, Error: "no peers"},
+					},
+				},
+				{Seq: 2, Time: published, AdID: adCids[1], ContextID: []byte("fish"), IsRm: true, MultihashCount: -1},
+			}
+		case "2":
+			res.Records = []adminserver.AuditRecord{{Seq: 3, Time: published, AdID: adCids[2], MultihashCount: 1}}
+		default:
+			t.Fatalf("unexpected after %q", r.URL.Query().Get("after"))
+		}
+		if limit, _ := strconv.Atoi(r.URL.Query().Get("limit")); limit < len(res.Records) {
+			next := res.Records[limit-1].Seq
+			res.Records, res.Next = res.Records[:limit], &next
+		}
+		_, err := res.WriteTo(w)
+		require.NoError(t, err)
+	}))
+	defer server.Close()
+
+	run := func(args ...string) string {
+		var out bytes.Buffer
+		app := &cli.App{
+			Writer:   &out,
+			Commands: []*cli.Command{AuditCmd},
+		}
+		require.NoError(t, app.Run(append([]string{"provider", "audit", "-l", server.URL}, args...)))
+		return out.String()
+	}
+
+	out := run()
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 4)
+	require.Regexp(t, `^1\s+2023-05-01T12:00:00Z\s+`+adCids[0].String()+`\s+put\s+ZmlzaA==\s+7\s+transport-bitswap\s+1/2$`, lines[1])
+	require.Regexp(t, `^2\s+.*\s+remove\s+ZmlzaA==\s+unknown\s+-\s+no$`, lines[2])
+	require.Regexp(t, `^3\s+`, lines[3])
+
+	lines = strings.Split(strings.TrimSpace(run("-o", "ndjson", "--limit", "1")), "\n")
+	require.Len(t, lines, 1)
+	var record adminserver.AuditRecord
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	require.Equal(t, adCids[0], record.AdID)
+	require.Len(t, record.Announces, 2)
+}
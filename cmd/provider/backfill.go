@@ -0,0 +1,521 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/go-libipni/metadata"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/cmd/provider/internal/adminclient"
+	adminserver "github.com/ipni/index-provider/server/admin/http"
+	"github.com/ipni/index-provider/supplier"
+	"github.com/multiformats/go-multicodec"
+	"github.com/multiformats/go-multihash"
+	"github.com/urfave/cli/v2"
+)
+
+// Types of the multihash sources listed in a backfill manifest.
+const (
+	backfillSourceCar         = "car"
+	backfillSourceMultihashes = "multihashes"
+	backfillSourcePiece       = "piece"
+)
+
+var BackfillCmd = &cli.Command{
+	Name:  "backfill",
+	Usage: "Advertises an existing dataset listed in a manifest via a running provider",
+	Description: `Drives the initial advertisement of an existing dataset, listed in a manifest of context IDs and
+the sources of their multihashes, via the admin server of a running provider. Each source is one of:
+  car          the path to a CAR file, or the http, https or s3 URL of a remote CAR file, which the
+               provider imports as the import car command does;
+  multihashes  the path to a file listing multihashes, one base58 encoded multihash or CID per line,
+               whose multihashes are advertised;
+  piece        the CID of a Filecoin piece, which the provider imports as a remote CAR from the
+               piece-url option, with {pieceCid} standing for the piece CID, and advertises with
+               graphsync filecoin metadata. Since pieces are padded with zeros, the provider must run
+               with the carZeroLengthAsEOF option.
+
+A manifest ending in .json is a JSON array of objects with one of the "car", "multihashes" or "piece"
+fields, set to the source, and an optional "context_id" field, set to the base64 encoded context ID.
+Any other manifest is a CSV file whose rows are the type of source, the source, and optionally the
+base64 encoded context ID. A header row starting with "type" and rows starting with # are skipped.
+Without a context ID, the context ID of a piece is its CID, and that of any other source the SHA-256
+hash of its absolute path or URL, as with the import car command.
+
+With the workers option, that many sources are advertised concurrently. A source that fails does not
+stop the others. Each source that is advertised, or that the provider already advertises, is
+recorded in the checkpoint file, and sources recorded there are skipped, so that an interrupted or
+partly failed backfill is resumed by running the same command again. Once every source is processed,
+a report of the backfill is printed.`,
+	Flags: []cli.Flag{
+		adminAPIFlag,
+		&cli.StringFlag{
+			Name:     "manifest",
+			Aliases:  []string{"i"},
+			Usage:    "Path to the manifest of the sources to advertise, either a .json or a CSV file.",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "checkpoint",
+			Usage: "Path to the checkpoint file recording the advertised sources. Defaults to the manifest path with a .checkpoint suffix.",
+		},
+		&cli.IntFlag{
+			Name:  "workers",
+			Usage: "Number of sources to advertise concurrently.",
+			Value: 4,
+		},
+		&cli.StringFlag{
+			Name:  "piece-url",
+			Usage: "URL from which the provider fetches pieces, with {pieceCid} standing for the piece CID, e.g. http://localhost:7777/piece/{pieceCid}. Required if the manifest lists pieces.",
+		},
+		metadataFlag,
+		bitswapFlag,
+		httpGatewayFlag,
+	},
+	Action: doBackfill,
+}
+
+// backfillEntry is an entry of a backfill manifest.
+type backfillEntry struct {
+	ContextID   []byte `json:"context_id,omitempty"`
+	Car         string `json:"car,omitempty"`
+	Multihashes string `json:"multihashes,omitempty"`
+	Piece       string `json:"piece,omitempty"`
+
+	kind     string
+	source   string
+	pieceCid cid.Cid
+}
+
+// backfillReport reports the outcome of a backfill.
+type backfillReport struct {
+	entries      int
+	checkpointed int
+	advertised   int
+	already      int
+	failed       map[string]error
+	elapsed      time.Duration
+}
+
+// backfiller advertises the entries of a backfill manifest via the admin
+// server.
+type backfiller struct {
+	cctx     *cli.Context
+	client   *adminclient.Client
+	md       metadata.Metadata
+	pieceURL string
+
+	lock       sync.Mutex
+	checkpoint io.Writer
+	report     backfillReport
+}
+
+func doBackfill(cctx *cli.Context) error {
+	manifestPath := cctx.String("manifest")
+	entries, err := readBackfillManifest(manifestPath)
+	if err != nil {
+		return withErrorClass(errClassUsage, err)
+	}
+	pieceURL := cctx.String("piece-url")
+	if pieceURL != "" && !strings.Contains(pieceURL, "{pieceCid}") {
+		pieceURL = strings.TrimSuffix(pieceURL, "/") + "/{pieceCid}"
+	}
+	for _, entry := range entries {
+		if entry.kind == backfillSourcePiece && pieceURL == "" {
+			return withErrorClass(errClassUsage, errors.New("piece-url must be set to backfill pieces"))
+		}
+	}
+	// If no metadata is set, the provider uses its default metadata.
+	bfMd, err := flagMetadata(cctx, metadata.Default)
+	if err != nil {
+		return withErrorClass(errClassUsage, err)
+	}
+
+	checkpointPath := cctx.String("checkpoint")
+	if checkpointPath == "" {
+		checkpointPath = manifestPath + ".checkpoint"
+	}
+	done, err := readBackfillCheckpoint(checkpointPath)
+	if err != nil {
+		return err
+	}
+	checkpoint, err := os.OpenFile(checkpointPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("cannot open checkpoint: %w", err)
+	}
+	defer checkpoint.Close()
+
+	client, err := newAdminClient()
+	if err != nil {
+		return err
+	}
+	bf := &backfiller{
+		cctx:       cctx,
+		client:     client,
+		md:         bfMd,
+		pieceURL:   pieceURL,
+		checkpoint: checkpoint,
+		report: backfillReport{
+			entries: len(entries),
+			failed:  make(map[string]error),
+		},
+	}
+
+	var pending []*backfillEntry
+	for _, entry := range entries {
+		if done[base64.StdEncoding.EncodeToString(entry.ContextID)] {
+			bf.report.checkpointed++
+			continue
+		}
+		pending = append(pending, entry)
+	}
+	if bf.report.checkpointed != 0 {
+		fmt.Fprintf(cctx.App.Writer, "Resuming backfill: %d of %d sources already checkpointed.\n", bf.report.checkpointed, len(entries))
+	}
+
+	start := time.Now()
+	bf.run(pending, cctx.Int("workers"))
+	bf.report.elapsed = time.Since(start)
+	if err = bf.printReport(); err != nil {
+		return err
+	}
+	if err = cctx.Context.Err(); err != nil {
+		return fmt.Errorf("backfill interrupted: %w", err)
+	}
+	if len(bf.report.failed) != 0 {
+		return fmt.Errorf("failed to backfill %d sources; run again to retry them", len(bf.report.failed))
+	}
+	return nil
+}
+
+// readBackfillManifest reads the entries of the manifest at the given path,
+// and checks that each has a valid source and a distinct context ID.
+func readBackfillManifest(path string) ([]*backfillEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open manifest: %w", err)
+	}
+	defer f.Close()
+
+	var entries []*backfillEntry
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		if err = json.NewDecoder(f).Decode(&entries); err != nil {
+			return nil, fmt.Errorf("cannot decode manifest: %w", err)
+		}
+	} else if entries, err = readBackfillCsv(f); err != nil {
+		return nil, err
+	}
+
+	contextIDs := make(map[string]int, len(entries))
+	for i, entry := range entries {
+		if err = entry.normalize(); err != nil {
+			return nil, fmt.Errorf("invalid manifest entry %d: %w", i+1, err)
+		}
+		key := string(entry.ContextID)
+		if prev, ok := contextIDs[key]; ok {
+			return nil, fmt.Errorf("invalid manifest entry %d: same context ID as entry %d", i+1, prev)
+		}
+		contextIDs[key] = i + 1
+	}
+	return entries, nil
+}
+
+func readBackfillCsv(r io.Reader) ([]*backfillEntry, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	var entries []*backfillEntry
+	for first := true; ; first = false {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read manifest: %w", err)
+		}
+		if first && strings.EqualFold(record[0], "type") {
+			continue
+		}
+		if len(record) < 2 || len(record) > 3 {
+			line, _ := cr.FieldPos(0)
+			return nil, fmt.Errorf("manifest line %d must have 2 or 3 fields: type, source and optional context ID", line)
+		}
+		entry := &backfillEntry{}
+		switch record[0] {
+		case backfillSourceCar:
+			entry.Car = record[1]
+		case backfillSourceMultihashes:
+			entry.Multihashes = record[1]
+		case backfillSourcePiece:
+			entry.Piece = record[1]
+		default:
+			line, _ := cr.FieldPos(0)
+			return nil, fmt.Errorf("manifest line %d has unknown type of source %q; must be one of %s, %s or %s",
+				line, record[0], backfillSourceCar, backfillSourceMultihashes, backfillSourcePiece)
+		}
+		if len(record) == 3 && record[2] != "" {
+			if entry.ContextID, err = base64.StdEncoding.DecodeString(record[2]); err != nil {
+				line, _ := cr.FieldPos(2)
+				return nil, fmt.Errorf("manifest line %d has context ID that is not a valid base64 encoded string", line)
+			}
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// normalize checks that exactly one source is set, resolves its path, and
+// sets its default context ID if none is set.
+func (e *backfillEntry) normalize() error {
+	var sources int
+	for kind, source := range map[string]string{
+		backfillSourceCar:         e.Car,
+		backfillSourceMultihashes: e.Multihashes,
+		backfillSourcePiece:       e.Piece,
+	} {
+		if source != "" {
+			e.kind, e.source = kind, source
+			sources++
+		}
+	}
+	if sources != 1 {
+		return fmt.Errorf("exactly one of %s, %s or %s must be set", backfillSourceCar, backfillSourceMultihashes, backfillSourcePiece)
+	}
+
+	switch e.kind {
+	case backfillSourcePiece:
+		pieceCid, err := cid.Decode(e.source)
+		if err != nil {
+			return fmt.Errorf("invalid piece CID %q: %w", e.source, err)
+		}
+		e.pieceCid = pieceCid
+		if len(e.ContextID) == 0 {
+			e.ContextID = pieceCid.Bytes()
+		}
+	default:
+		if e.kind == backfillSourceMultihashes || !supplier.IsRemoteCar(e.source) {
+			abs, err := filepath.Abs(e.source)
+			if err != nil {
+				return err
+			}
+			e.source = abs
+		}
+		if len(e.ContextID) == 0 {
+			e.ContextID = carContextID(e.source)
+		}
+	}
+	if len(e.ContextID) > provider.MaxContextIDLen {
+		return fmt.Errorf("context ID must not be longer than %d bytes", provider.MaxContextIDLen)
+	}
+	return nil
+}
+
+// readBackfillCheckpoint reads the base64 encoded context IDs recorded in the
+// checkpoint file at the given path, one per line. No context IDs are
+// returned if the file does not exist.
+func readBackfillCheckpoint(path string) (map[string]bool, error) {
+	done := make(map[string]bool)
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot open checkpoint: %w", err)
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if line := strings.TrimSpace(s.Text()); line != "" {
+			done[line] = true
+		}
+	}
+	if err = s.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read checkpoint: %w", err)
+	}
+	return done, nil
+}
+
+// run advertises the given entries, up to workers of them concurrently, until
+// all are processed or the command is interrupted.
+func (bf *backfiller) run(entries []*backfillEntry, workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+	ctx := bf.cctx.Context
+	var wg sync.WaitGroup
+	entriesChan := make(chan *backfillEntry)
+	for i := 0; i < workers && i < len(entries); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for entry := range entriesChan {
+				already, err := bf.advertise(ctx, entry)
+				if ctx.Err() != nil {
+					// Interrupted entries are retried when resumed.
+					continue
+				}
+				bf.lock.Lock()
+				switch {
+				case err != nil:
+					fmt.Fprintf(bf.cctx.App.ErrWriter, "failed to backfill %s: %s\n", entry.source, err)
+					bf.report.failed[entry.source] = err
+				default:
+					if err = bf.recordCheckpoint(entry); err != nil {
+						fmt.Fprintln(bf.cctx.App.ErrWriter, err)
+					}
+					if already {
+						bf.report.already++
+					} else {
+						bf.report.advertised++
+					}
+				}
+				processed := bf.report.advertised + bf.report.already + len(bf.report.failed)
+				fmt.Fprintf(bf.cctx.App.Writer, "Progress: %d of %d sources processed.\n", processed, len(entries))
+				bf.lock.Unlock()
+			}
+		}()
+	}
+feed:
+	for _, entry := range entries {
+		select {
+		case entriesChan <- entry:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(entriesChan)
+	wg.Wait()
+}
+
+// recordCheckpoint records in the checkpoint file that the given entry is
+// advertised. It must be called with the lock held.
+func (bf *backfiller) recordCheckpoint(entry *backfillEntry) error {
+	line := base64.StdEncoding.EncodeToString(entry.ContextID) + "\n"
+	if _, err := io.WriteString(bf.checkpoint, line); err != nil {
+		return fmt.Errorf("cannot record checkpoint of %s: %w", entry.source, err)
+	}
+	return nil
+}
+
+// advertise advertises the multihashes of the given entry, and returns
+// whether the provider already advertised them.
+func (bf *backfiller) advertise(ctx context.Context, entry *backfillEntry) (bool, error) {
+	var err error
+	switch entry.kind {
+	case backfillSourceCar:
+		err = bf.importCar(ctx, entry.source, entry.ContextID, bf.md)
+	case backfillSourcePiece:
+		md := bf.md
+		if md.Get(multicodec.TransportGraphsyncFilecoinv1) == nil {
+			protocols := []metadata.Protocol{&metadata.GraphsyncFilecoinV1{PieceCID: entry.pieceCid}}
+			for _, id := range md.Protocols() {
+				protocols = append(protocols, md.Get(id))
+			}
+			md = metadata.Default.New(protocols...)
+		}
+		pieceURL := strings.ReplaceAll(bf.pieceURL, "{pieceCid}", entry.pieceCid.String())
+		err = bf.importCar(ctx, pieceURL, entry.ContextID, md)
+	case backfillSourceMultihashes:
+		err = bf.advertiseMultihashes(ctx, entry)
+	}
+	if errors.Is(err, adminclient.ErrConflict) {
+		return true, nil
+	}
+	return false, err
+}
+
+func (bf *backfiller) importCar(ctx context.Context, path string, contextID []byte, md metadata.Metadata) error {
+	mdBytes, err := md.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = bf.client.ImportCar(ctx, &adminserver.ImportCarReq{
+		Path:     path,
+		Key:      contextID,
+		Metadata: mdBytes,
+	})
+	return err
+}
+
+func (bf *backfiller) advertiseMultihashes(ctx context.Context, entry *backfillEntry) error {
+	f, err := os.Open(entry.source)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	mhIter := provider.LinesMultihashIterator(f)
+	var mhs []multihash.Multihash
+	for {
+		mh, err := mhIter.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		mhs = append(mhs, mh)
+	}
+	if len(mhs) == 0 {
+		return errors.New("no multihashes listed")
+	}
+	mdBytes, err := bf.md.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = bf.client.Advertise(ctx, &adminserver.AdvertiseReq{
+		ContextID:   entry.ContextID,
+		Metadata:    mdBytes,
+		Multihashes: mhs,
+	})
+	return err
+}
+
+// printReport prints the report of the backfill, followed by the sources that
+// failed, if any.
+func (bf *backfiller) printReport() error {
+	r := bf.report
+	var b bytes.Buffer
+	tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Backfill report:\n")
+	fmt.Fprintf(tw, "  Sources:\t%d\n", r.entries)
+	fmt.Fprintf(tw, "  Already checkpointed:\t%d\n", r.checkpointed)
+	fmt.Fprintf(tw, "  Advertised:\t%d\n", r.advertised)
+	fmt.Fprintf(tw, "  Already advertised:\t%d\n", r.already)
+	fmt.Fprintf(tw, "  Failed:\t%d\n", len(r.failed))
+	if remaining := r.entries - r.checkpointed - r.advertised - r.already - len(r.failed); remaining != 0 {
+		fmt.Fprintf(tw, "  Not processed:\t%d\n", remaining)
+	}
+	fmt.Fprintf(tw, "  Elapsed:\t%s\n", r.elapsed.Round(time.Millisecond))
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(r.failed) != 0 {
+		sources := make([]string, 0, len(r.failed))
+		for source := range r.failed {
+			sources = append(sources, source)
+		}
+		sort.Strings(sources)
+		b.WriteString("Failed sources:\n")
+		for _, source := range sources {
+			fmt.Fprintf(&b, "  %s: %s\n", source, r.failed[source])
+		}
+	}
+	_, err := bf.cctx.App.Writer.Write(b.Bytes())
+	return err
+}
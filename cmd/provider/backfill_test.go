@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	adminserver "github.com/ipni/index-provider/server/admin/http"
+	"github.com/multiformats/go-multicodec"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestBackfillCmd(t *testing.T) {
+	dir := t.TempDir()
+	mhs := test.RandomMultihashes(3)
+	var mhsFile strings.Builder
+	for _, mh := range mhs {
+		mhsFile.WriteString(mh.B58String() + "\n")
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "fish.txt"), []byte(mhsFile.String()), 0o644))
+	pieceCid := test.RandomCids(1)[0]
+	lobsterKey := base64.StdEncoding.EncodeToString([]byte("lobster"))
+	manifest := filepath.Join(dir, "manifest.csv")
+	require.NoError(t, os.WriteFile(manifest, []byte(strings.Join([]string{
+		"type,source,context_id",
+		"# Comments are skipped.",
+		"car," + filepath.Join(dir, "lobster.car") + "," + lobsterKey,
+		"car," + filepath.Join(dir, "already.car"),
+		"car," + filepath.Join(dir, "broken.car"),
+		"multihashes," + filepath.Join(dir, "fish.txt"),
+		"piece," + pieceCid.String(),
+	}, "\n")), 0o644))
+
+	var mu sync.Mutex
+	imported := make(map[string]*adminserver.ImportCarReq)
+	advertised := make(map[string][]multihash.Multihash)
+	broken := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/admin/import/car":
+			var req adminserver.ImportCarReq
+			_, err := req.ReadFrom(r.Body)
+			require.NoError(t, err)
+			switch filepath.Base(req.Path) {
+			case "already.car":
+				http.Error(w, "already advertised", http.StatusConflict)
+				return
+			case "broken.car":
+				if broken {
+					http.Error(w, "broken", http.StatusBadRequest)
+					return
+				}
+			}
+			imported[req.Path] = &req
+			_, err = (&adminserver.ImportCarRes{Key: req.Key, AdvId: test.RandomCids(1)[0]}).WriteTo(w)
+			require.NoError(t, err)
+		case "/admin/advertise":
+			var req adminserver.AdvertiseReq
+			_, err := req.ReadFrom(r.Body)
+			require.NoError(t, err)
+			advertised[string(req.ContextID)] = req.Multihashes
+			_, err = (&adminserver.AdvertiseRes{AdvId: test.RandomCids(1)[0]}).WriteTo(w)
+			require.NoError(t, err)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	run := func() (string, error) {
+		var out bytes.Buffer
+		app := &cli.App{
+			Writer:    &out,
+			ErrWriter: &out,
+			Commands:  []*cli.Command{BackfillCmd},
+		}
+		err := app.Run([]string{"provider", "backfill", "-l", server.URL, "-i", manifest,
+			"--piece-url", "http://localhost:7777/piece", "--workers", "2"})
+		return out.String(), err
+	}
+
+	out, err := run()
+	require.ErrorContains(t, err, "failed to backfill 1 sources")
+	require.Regexp(t, `Advertised:\s+3\n`, out)
+	require.Regexp(t, `Already advertised:\s+1\n`, out)
+	require.Regexp(t, `Failed:\s+1\n`, out)
+	require.Contains(t, out, "Failed sources:\n  "+filepath.Join(dir, "broken.car")+": Bad Request: broken")
+
+	lobster := imported[filepath.Join(dir, "lobster.car")]
+	require.NotNil(t, lobster)
+	require.Equal(t, []byte("lobster"), lobster.Key)
+	fishPath := filepath.Join(dir, "fish.txt")
+	fishKey := sha256.Sum256([]byte(fishPath))
+	require.Equal(t, mhs, advertised[string(fishKey[:])])
+	piece := imported["http://localhost:7777/piece/"+pieceCid.String()]
+	require.NotNil(t, piece)
+	require.Equal(t, pieceCid.Bytes(), piece.Key)
+	md := metadata.Default.New()
+	require.NoError(t, md.UnmarshalBinary(piece.Metadata))
+	gs, ok := md.Get(multicodec.TransportGraphsyncFilecoinv1).(*metadata.GraphsyncFilecoinV1)
+	require.True(t, ok)
+	require.Equal(t, pieceCid, gs.PieceCID)
+
+	// Resuming skips the checkpointed sources and retries the failed one.
+	mu.Lock()
+	broken = false
+	imported = make(map[string]*adminserver.ImportCarReq)
+	mu.Unlock()
+	out, err = run()
+	require.NoError(t, err)
+	require.Contains(t, out, "Resuming backfill: 4 of 5 sources already checkpointed.")
+	require.Regexp(t, `Advertised:\s+1\n`, out)
+	require.Len(t, imported, 1)
+	require.Contains(t, imported, filepath.Join(dir, "broken.car"))
+
+	out, err = run()
+	require.NoError(t, err)
+	require.Regexp(t, `Already checkpointed:\s+5\n`, out)
+}
+
+func TestReadBackfillManifest(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		return path
+	}
+
+	pieceCid := test.RandomCids(1)[0]
+	entries, err := readBackfillManifest(write("manifest.json",
+		`[{"car": "https://example.com/fish.car"}, {"piece": "`+pieceCid.String()+`", "context_id": "bG9ic3Rlcg=="}]`))
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, "https://example.com/fish.car", entries[0].source)
+	require.Equal(t, carContextID("https://example.com/fish.car"), entries[0].ContextID)
+	require.Equal(t, backfillSourcePiece, entries[1].kind)
+	require.Equal(t, []byte("lobster"), entries[1].ContextID)
+
+	_, err = readBackfillManifest(write("both.json", `[{"car": "fish.car", "piece": "`+pieceCid.String()+`"}]`))
+	require.ErrorContains(t, err, "invalid manifest entry 1: exactly one of")
+	_, err = readBackfillManifest(write("dup.csv", "car,fish.car,bG9ic3Rlcg==\ncar,lobster.car,bG9ic3Rlcg==\n"))
+	require.ErrorContains(t, err, "invalid manifest entry 2: same context ID as entry 1")
+	_, err = readBackfillManifest(write("kind.csv", "crab,fish.car\n"))
+	require.ErrorContains(t, err, `manifest line 1 has unknown type of source "crab"`)
+	_, err = readBackfillManifest(write("piece.csv", "piece,fish\n"))
+	require.ErrorContains(t, err, `invalid piece CID "fish"`)
+}
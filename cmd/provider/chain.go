@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+
+	leveldb "github.com/ipfs/go-ds-leveldb"
+	"github.com/ipni/index-provider/cmd/provider/internal/config"
+	"github.com/ipni/index-provider/engine"
+	"github.com/ipni/index-provider/supplier"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/urfave/cli/v2"
+)
+
+var ExportChainCmd = &cli.Command{
+	Name:      "export-chain",
+	Usage:     "Exports the advertisement chain of the provider to a CAR file",
+	ArgsUsage: "<out.car>",
+	Description: `Writes the advertisement chain published by the provider to a CARv1 file, with the latest
+advertisement as the CAR root. The exported chain can be imported via the import-chain command.
+
+The provider datastore is read directly and therefore the provider daemon must not be running.`,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:    "entries",
+			Aliases: []string{"e"},
+			Usage: "Whether to also export the entries of each advertisement. The content of every " +
+				"advertisement must be available to the provider.",
+		},
+	},
+	Action: doExportChain,
+}
+
+var ImportChainCmd = &cli.Command{
+	Name:      "import-chain",
+	Usage:     "Imports an advertisement chain from a CAR file",
+	ArgsUsage: "<in.car>",
+	Description: `Reads an advertisement chain from a CAR file, such as one written by the export-chain command,
+and sets its root as the latest advertisement published by the provider. Only advertisements are
+imported; entries are generated from the content known to the provider when requested by indexers.
+The context IDs advertised in the imported chain are known to the provider once imported, and can
+be listed and removed.
+
+If the provider has already published advertisements, its latest advertisement must be part of
+the imported chain.
+
+The provider datastore is written directly and therefore the provider daemon must not be running.`,
+	Action: doImportChain,
+}
+
+func doExportChain(cctx *cli.Context) error {
+	if cctx.NArg() != 1 {
+		return cli.Exit("output CAR file must be specified", exitCodeUsage)
+	}
+	cfg, err := loadInitializedConfig()
+	if err != nil {
+		return err
+	}
+	eng, closer, err := openOfflineEngine(cctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer closer()
+
+	outPath := cctx.Args().First()
+	f, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	head, err := eng.ExportChain(cctx.Context, w, cctx.Bool("entries"))
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		// Do not leave a partially written CAR behind.
+		_ = os.Remove(outPath)
+		return err
+	}
+	_, err = fmt.Fprintln(cctx.App.Writer, "Exported advertisement chain with head", head)
+	return err
+}
+
+func doImportChain(cctx *cli.Context) error {
+	if cctx.NArg() != 1 {
+		return cli.Exit("input CAR file must be specified", exitCodeUsage)
+	}
+	cfg, err := loadInitializedConfig()
+	if err != nil {
+		return err
+	}
+	eng, closer, err := openOfflineEngine(cctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer closer()
+
+	f, err := os.Open(cctx.Args().First())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	head, err := eng.ImportChain(cctx.Context, bufio.NewReader(f))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(cctx.App.Writer, "Imported advertisement chain with head", head)
+	return err
+}
+
+// loadInitializedConfig loads the provider configuration at the provider
+// path, which must have been initialized.
+func loadInitializedConfig() (*config.Config, error) {
+	cfg, err := config.Load("")
+	if err != nil {
+		if errors.Is(err, config.ErrNotInitialized) {
+			return nil, errors.New("reference provider is not initialized\nTo initialize, run using the \"init\" command")
+		}
+		return nil, fmt.Errorf("cannot load config file: %w", err)
+	}
+	return cfg, nil
+}
+
+// openOfflineEngine instantiates an engine that does not publish
+// advertisements, backed by the datastore of the provider with the given
+// config. The returned closer shuts down the engine and closes the datastore.
+func openOfflineEngine(cctx *cli.Context, cfg *config.Config) (*engine.Engine, func(), error) {
+	peerID, privKey, err := cfg.Identity.DecodeOrCreate(cctx.App.Writer)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.Datastore.Type != "levelds" {
+		return nil, nil, fmt.Errorf("only levelds datastore type supported, %q not supported", cfg.Datastore.Type)
+	}
+	dataStorePath, err := config.Path("", cfg.Datastore.Dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = dirWritable(dataStorePath); err != nil {
+		return nil, nil, err
+	}
+	ds, err := leveldb.NewDatastore(dataStorePath, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot open datastore; check that the provider daemon is not running: %w", err)
+	}
+
+	// The retrieval addresses are set explicitly so that the engine does not
+	// start a libp2p host.
+	retrievalAddrs := cfg.ProviderServer.RetrievalMultiaddrs
+	if len(retrievalAddrs) == 0 {
+		retrievalAddrs = []string{cfg.ProviderServer.ListenMultiaddr}
+	}
+	maddrs := make([]multiaddr.Multiaddr, 0, len(retrievalAddrs))
+	for _, addr := range retrievalAddrs {
+		maddr, err := multiaddr.NewMultiaddr(addr)
+		if err != nil {
+			ds.Close()
+			return nil, nil, fmt.Errorf("bad retrieval address in config %s: %w", addr, err)
+		}
+		maddrs = append(maddrs, maddr)
+	}
+
+	eng, err := engine.New(
+		engine.WithDatastore(ds),
+		engine.WithPrivateKey(privKey),
+		engine.WithProvider(peer.AddrInfo{ID: peerID, Addrs: maddrs}),
+		engine.WithPublisherKind(engine.NoPublisher),
+		engine.WithEntriesCacheCapacity(cfg.Ingest.LinkCacheSize),
+		engine.WithChainedEntries(cfg.Ingest.LinkedChunkSize),
+	)
+	if err == nil {
+		err = eng.Start(cctx.Context)
+	}
+	if err != nil {
+		ds.Close()
+		return nil, nil, err
+	}
+	// Register the CAR supplier so that entries can be regenerated.
+	supplier.NewCarSupplier(eng, ds)
+
+	return eng, func() {
+		if err := eng.Shutdown(); err != nil {
+			log.Errorw("Failed to shut down engine", "err", err)
+		}
+		if err := ds.Close(); err != nil {
+			log.Errorw("Failed to close datastore", "err", err)
+		}
+	}, nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ipni/index-provider/cmd/provider/internal/config"
+	"github.com/ipni/index-provider/engine"
+	"github.com/mitchellh/go-homedir"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/urfave/cli/v2"
+)
+
+// announceURLCheckTimeout is the time within which an announce URL must
+// respond to be considered reachable.
+const announceURLCheckTimeout = 10 * time.Second
+
+var ConfigCmd = &cli.Command{
+	Name:        "config",
+	Usage:       "Inspects the provider config",
+	Subcommands: []*cli.Command{configValidateSubCmd},
+}
+
+var configValidateSubCmd = &cli.Command{
+	Name:  "validate",
+	Usage: "Checks the provider config for problems",
+	Description: `Checks the config of the provider, including overrides from PROVIDER_* environment variables,
+for problems that would otherwise make the daemon fail at startup or misbehave: invalid multiaddrs,
+URLs and peer IDs, inconsistent publisher settings, announce URLs that cannot be reached, and a
+datastore directory that cannot be written to. Each problem is printed along with the config
+field that has it. Warnings are printed for settings that are valid but likely unintended.`,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "offline",
+			Usage: "Skip checks that access the network, such as whether announce URLs are reachable.",
+		},
+	},
+	Action: doConfigValidate,
+}
+
+func doConfigValidate(cctx *cli.Context) error {
+	cfg, err := loadInitializedConfig()
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	var verr *config.ValidationError
+	if err = cfg.Validate(); errors.As(err, &verr) {
+		problems = append(problems, verr.Problems...)
+	} else if err != nil {
+		return err
+	}
+	if err = checkDatastoreDir(cfg.Datastore.Dir); err != nil {
+		problems = append(problems, "Datastore.Dir: "+err.Error())
+	}
+	if !cctx.Bool("offline") {
+		for _, u := range cfg.DirectAnnounce.URLs {
+			if err = checkURLReachable(cctx.Context, u); err != nil {
+				problems = append(problems, fmt.Sprintf("DirectAnnounce.URLs: cannot reach %s: %v", u, err))
+			}
+		}
+	}
+
+	for _, warning := range configWarnings(cfg) {
+		fmt.Fprintln(cctx.App.Writer, "warning:", warning)
+	}
+	for _, problem := range problems {
+		fmt.Fprintln(cctx.App.Writer, "error:", problem)
+	}
+	if len(problems) != 0 {
+		return fmt.Errorf("config has %d problems", len(problems))
+	}
+	fmt.Fprintln(cctx.App.Writer, "Config is valid")
+	return nil
+}
+
+// configWarnings returns descriptions of settings in the given valid config
+// that are likely unintended.
+func configWarnings(cfg *config.Config) []string {
+	var warnings []string
+	if cfg.Ingest.PublisherKind == "" {
+		warnings = append(warnings, "Ingest.PublisherKind: not set; advertisements are only stored locally and are not published")
+	} else if cfg.DirectAnnounce.NoPubsubAnnounce && len(cfg.DirectAnnounce.URLs) == 0 {
+		warnings = append(warnings, "DirectAnnounce: NoPubsubAnnounce is set and no URLs are specified; advertisements are not announced to indexers")
+	}
+	if cfg.Ingest.PublisherKind == config.HttpPublisherKind && cfg.Ingest.HttpPublisher.AnnounceMultiaddr == "" {
+		warnings = append(warnings, "Ingest.HttpPublisher.AnnounceMultiaddr: not set; the listen address is announced, which may not be reachable by indexers")
+	}
+	if !cfg.ProviderServer.AllowPrivateRetrievalAddrs {
+		for _, addr := range cfg.ProviderServer.RetrievalMultiaddrs {
+			if maddr, err := multiaddr.NewMultiaddr(addr); err == nil && engine.IsPrivateAddr(maddr) {
+				warnings = append(warnings, fmt.Sprintf("ProviderServer.RetrievalMultiaddrs: %s is private, and so is not advertised; set ProviderServer.AllowPrivateRetrievalAddrs to advertise it", addr))
+			}
+		}
+	}
+	if cfg.ProviderServer.ExternalRetrievalAddrs && (len(cfg.ProviderServer.RetrievalMultiaddrs) != 0 || cfg.Retrieval.HttpGatewayURL != "") {
+		warnings = append(warnings, "ProviderServer.ExternalRetrievalAddrs: has no effect, since the retrieval addresses are set by ProviderServer.RetrievalMultiaddrs or Retrieval.HttpGatewayURL")
+	}
+	if cfg.Retrieval.Bitswap && len(cfg.ProviderServer.RetrievalMultiaddrs) == 0 {
+		warnings = append(warnings, "Retrieval.Bitswap: ProviderServer.RetrievalMultiaddrs not set; the provider's own addresses are advertised for bitswap retrieval, but it does not serve bitswap")
+	}
+	return warnings
+}
+
+// checkDatastoreDir checks that the datastore directory with the given path,
+// relative to the config root, is writable, or can be created if it does not
+// exist. Unlike dirWritable, it does not create the directory.
+func checkDatastoreDir(dir string) error {
+	path, err := config.Path("", dir)
+	if err != nil {
+		return err
+	}
+	if path, err = homedir.Expand(path); err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		// The daemon creates the directory, provided that its parent exists.
+		path = filepath.Dir(path)
+		if info, err = os.Stat(path); err != nil {
+			return fmt.Errorf("cannot create datastore directory: %w", err)
+		}
+	} else if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", path)
+	}
+	file, err := os.CreateTemp(path, "test")
+	if err != nil {
+		return fmt.Errorf("cannot write to %s: %w", path, err)
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+// checkURLReachable checks that the server of the given URL responds to HTTP
+// requests, regardless of the response status.
+func checkURLReachable(ctx context.Context, u string) error {
+	ctx, cancel := context.WithTimeout(ctx, announceURLCheckTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
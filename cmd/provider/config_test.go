@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipni/index-provider/cmd/provider/internal/config"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestConfigValidateCmd(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv(config.EnvDir, root)
+	indexer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+	defer indexer.Close()
+
+	cfg, err := config.Init(io.Discard)
+	require.NoError(t, err)
+	cfg.DirectAnnounce.URLs = []string{indexer.URL}
+	cfg.Retrieval.Bitswap = true
+	require.NoError(t, cfg.Save(""))
+
+	run := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		app := &cli.App{
+			Writer:   &out,
+			Commands: []*cli.Command{ConfigCmd},
+		}
+		err := app.Run(append([]string{"provider", "config", "validate"}, args...))
+		return out.String(), err
+	}
+
+	out, err := run()
+	require.NoError(t, err)
+	require.Contains(t, out, "warning: Ingest.HttpPublisher.AnnounceMultiaddr: not set")
+	require.Contains(t, out, "warning: Retrieval.Bitswap: ProviderServer.RetrievalMultiaddrs not set")
+	require.Contains(t, out, "Config is valid")
+
+	cfg.ProviderServer.RetrievalMultiaddrs = []string{"/ip4/127.0.0.1/tcp/3104", "/dns4/fish.example/tcp/443/https"}
+	cfg.ProviderServer.ExternalRetrievalAddrs = true
+	require.NoError(t, cfg.Save(""))
+	out, err = run()
+	require.NoError(t, err)
+	require.Contains(t, out, "warning: ProviderServer.ExternalRetrievalAddrs: has no effect")
+	require.Contains(t, out, "warning: ProviderServer.RetrievalMultiaddrs: /ip4/127.0.0.1/tcp/3104 is private, and so is not advertised")
+	require.NotContains(t, out, "fish.example")
+
+	indexer.Close()
+	cfg.Datastore.Dir = filepath.Join(root, "missing", "datastore")
+	cfg.Ingest.PublisherKind = "fish"
+	require.NoError(t, cfg.Save(""))
+	out, err = run()
+	require.EqualError(t, err, "config has 3 problems")
+	require.Contains(t, out, `error: Ingest.PublisherKind: unknown publisher kind "fish"`)
+	require.Contains(t, out, "error: Datastore.Dir: cannot create datastore directory")
+	require.Contains(t, out, "error: DirectAnnounce.URLs: cannot reach "+indexer.URL)
+
+	out, err = run("--offline")
+	require.EqualError(t, err, "config has 2 problems")
+	require.NotContains(t, out, "cannot reach")
+}
@@ -1,12 +1,6 @@
 package main
 
-import (
-	"fmt"
-	"net/http"
-
-	adminserver "github.com/ipni/index-provider/server/admin/http"
-	"github.com/urfave/cli/v2"
-)
+import "github.com/urfave/cli/v2"
 
 var ConnectCmd = &cli.Command{
 	Name:   "connect",
@@ -27,23 +21,15 @@ var connectFlags = []cli.Flag{
 
 func connectCommand(cctx *cli.Context) error {
 	iaddr := cctx.String("indexermaddr")
-	req := &adminserver.ConnectReq{Maddr: iaddr}
-	resp, err := doHttpPostReq(cctx.Context, adminAPIFlagValue+"/admin/connect", req)
+	client, err := newAdminClient()
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-
-	// Handle failed requests
-	if resp.StatusCode != http.StatusOK {
-		return errFromHttpResp(resp)
+	if err = client.Connect(cctx.Context, iaddr); err != nil {
+		return err
 	}
 
 	log.Infof("connected to peer successfully")
-	var res adminserver.ConnectRes
-	if _, err := res.ReadFrom(resp.Body); err != nil {
-		return fmt.Errorf("received OK response from server but cannot decode response body: %w", err)
-	}
 	_, err = cctx.App.Writer.Write([]byte("Connected to peer successfully"))
 	return err
 }
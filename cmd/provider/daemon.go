@@ -4,7 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
+	"slices"
+	"syscall"
 	"time"
 
 	datatransfer "github.com/filecoin-project/go-data-transfer/v2/impl"
@@ -17,16 +21,24 @@ import (
 	logging "github.com/ipfs/go-log/v2"
 	"github.com/ipld/go-car/v2"
 	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipni/go-libipni/metadata"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/alert"
 	"github.com/ipni/index-provider/cardatatransfer"
 	"github.com/ipni/index-provider/cmd/provider/internal/config"
 	"github.com/ipni/index-provider/engine"
 	"github.com/ipni/index-provider/engine/policy"
+	"github.com/ipni/index-provider/metrics"
+	"github.com/ipni/index-provider/selfcheck"
+	admingrpc "github.com/ipni/index-provider/server/admin/grpc"
 	adminserver "github.com/ipni/index-provider/server/admin/http"
 	droutingserver "github.com/ipni/index-provider/server/delegatedrouting/server"
 	"github.com/ipni/index-provider/supplier"
 	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/mitchellh/go-homedir"
 	"github.com/multiformats/go-multiaddr"
+	"github.com/syndtr/goleveldb/leveldb/util"
 	"github.com/urfave/cli/v2"
 )
 
@@ -50,7 +62,13 @@ var DaemonCmd = &cli.Command{
 	Action: daemonCommand,
 }
 
-var carZeroLengthAsEOFFlagValue bool
+var (
+	carZeroLengthAsEOFFlagValue bool
+	persistCarIndexesFlagValue  bool
+	dedupCarsFlagValue          bool
+	reconcileCarsFlagValue      time.Duration
+	auditLogFlagValue           bool
+)
 
 var daemonFlags = []cli.Flag{
 	&cli.BoolFlag{
@@ -60,6 +78,26 @@ var daemonFlags = []cli.Flag{
 		Value:       false, // Default to disabled, consistent with go-car/v2 defaults.
 		Destination: &carZeroLengthAsEOFFlagValue,
 	},
+	&cli.BoolFlag{
+		Name:        "persist-car-indexes",
+		Usage:       "Persist the indexes generated for CARs without a usable index as detached index files next to the CARs, so that they are not scanned again.",
+		Destination: &persistCarIndexesFlagValue,
+	},
+	&cli.BoolFlag{
+		Name:        "dedup-cars",
+		Usage:       "Deduplicate imported CARs by the digest of their content, such that identical CARs are indexed once.",
+		Destination: &dedupCarsFlagValue,
+	},
+	&cli.DurationFlag{
+		Name:        "reconcile-cars-interval",
+		Usage:       "Interval at which imported CARs are checked, and removed if deleted or no longer readable. Disabled if zero.",
+		Destination: &reconcileCarsFlagValue,
+	},
+	&cli.BoolFlag{
+		Name:        "audit-log",
+		Usage:       "Record every published advertisement in an append-only audit log, listed by the audit command.",
+		Destination: &auditLogFlagValue,
+	},
 	&cli.StringFlag{
 		Name:     "log-level",
 		Usage:    "Set the log level",
@@ -82,6 +120,44 @@ func daemonCommand(cctx *cli.Context) error {
 		}
 		return fmt.Errorf("cannot load config file: %w", err)
 	}
+	if err = cfg.Validate(); err != nil {
+		return fmt.Errorf("%w\nRun \"provider config validate\" for details", err)
+	}
+	if err = applyLogLevels(cctx.String("log-level"), cfg.Logging); err != nil {
+		return err
+	}
+
+	// Collect metrics before anything is instrumented.
+	var metricsExporter *metrics.Exporter
+	var metricsSvr *metrics.Server
+	if cfg.Metrics.Enabled {
+		metricsExporter, err = metrics.NewExporter()
+		if err != nil {
+			return err
+		}
+		metricsAddr, err := cfg.Metrics.ListenNetAddr()
+		if err != nil {
+			return err
+		}
+		if metricsAddr != "" {
+			metricsSvr, err = metrics.NewServerWithExporter(metricsAddr, metricsExporter)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cctx.App.ErrWriter, "Starting metrics server on %s ...", cfg.Metrics.ListenMultiaddr)
+			if err = metricsSvr.Start(); err != nil {
+				return err
+			}
+		}
+	}
+
+	var traceExporter *metrics.TraceExporter
+	if cfg.Tracing.Enabled {
+		traceExporter, err = metrics.NewTraceExporter(cctx.Context, cfg.Tracing.Endpoint, cfg.Tracing.SampleRatio)
+		if err != nil {
+			return fmt.Errorf("cannot export traces: %w", err)
+		}
+	}
 
 	// Initialize libp2p host
 	ctx, cancelp2p := context.WithCancel(cctx.Context)
@@ -96,6 +172,14 @@ func daemonCommand(cctx *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	indexerPeers, err := cfg.DirectAnnounce.ParseIndexerPeers()
+	if err != nil {
+		return err
+	}
+	trustedIndexers, err := cfg.DirectAnnounce.ParseTrustedIndexers()
+	if err != nil {
+		return err
+	}
 
 	p2pmaddr, err := multiaddr.NewMultiaddr(cfg.ProviderServer.ListenMultiaddr)
 	if err != nil {
@@ -124,10 +208,11 @@ func daemonCommand(cctx *cli.Context) error {
 	if err != nil {
 		return err
 	}
-	ds, err := leveldb.NewDatastore(dataStorePath, nil)
+	ldb, err := leveldb.NewDatastore(dataStorePath, nil)
 	if err != nil {
 		return err
 	}
+	ds := compactingDatastore{ldb}
 
 	gsnet := gsnet.NewFromLibp2pHost(h)
 	dtNet := dtnetwork.NewFromLibp2pHost(h)
@@ -147,21 +232,72 @@ func daemonCommand(cctx *cli.Context) error {
 		return err
 	}
 
+	retrievalAddrs, err := daemonRetrievalAddrs(cfg, h)
+	if err != nil {
+		return err
+	}
+
 	// Starting provider core
-	eng, err := engine.New(
+	engineOpts := []engine.Option{
 		engine.WithDatastore(ds),
 		engine.WithDirectAnnounce(cfg.DirectAnnounce.URLs...),
+		engine.WithMessageBusAnnounce(cfg.DirectAnnounce.MessageBusURLs...),
 		engine.WithHost(h),
 		engine.WithEntriesCacheCapacity(cfg.Ingest.LinkCacheSize),
-		engine.WithChainedEntries(cfg.Ingest.LinkedChunkSize),
+		engine.WithEntriesMemoryCacheSize(cfg.Ingest.LinkMemoryCacheSize),
+		engine.WithSortedEntries(cfg.Ingest.SortEntries),
+		engine.WithLazyEntries(cfg.Ingest.LazyEntries),
+		engine.WithListerPrefetch(cfg.Ingest.ListerPrefetch),
+		engine.WithMaxAdMultihashes(cfg.Ingest.MaxAdMultihashes),
+		engine.WithAdSequence(cfg.Ingest.AdSequence),
+		engine.WithStaticCarDir(cfg.Ingest.StaticCarDir),
 		engine.WithTopicName(cfg.Ingest.PubSubTopic),
 		engine.WithPublisherKind(engine.PublisherKind(cfg.Ingest.PublisherKind)),
 		engine.WithHttpPublisherListenAddr(httpListenAddr),
 		engine.WithHttpPublisherAnnounceAddr(cfg.Ingest.HttpPublisher.AnnounceMultiaddr),
 		engine.WithPubsubAnnounce(!cfg.DirectAnnounce.NoPubsubAnnounce),
+		engine.WithGossipMesh(cfg.DirectAnnounce.GossipMesh),
+		engine.WithIndexerPeers(indexerPeers...),
+		engine.WithTrustedIndexers(trustedIndexers...),
+		engine.WithAnnounceAuthTokens(cfg.DirectAnnounce.AuthTokens),
+		engine.WithAnnounceDebounce(time.Duration(cfg.DirectAnnounce.AnnounceDebounce), time.Duration(cfg.DirectAnnounce.AnnounceMaxDelay)),
 		engine.WithSyncPolicy(syncPolicy),
-		engine.WithRetrievalAddrs(cfg.ProviderServer.RetrievalMultiaddrs...),
-	)
+		engine.WithRetrievalAddrs(retrievalAddrs...),
+		engine.WithMaxRetrievalAddrs(cfg.ProviderServer.MaxRetrievalAddrs),
+		engine.WithPrivateRetrievalAddrs(cfg.ProviderServer.AllowPrivateRetrievalAddrs),
+		engine.WithExternalRetrievalAddrs(externalAddrsQuiet(cfg.ProviderServer)),
+		engine.WithAuditLog(auditLogFlagValue),
+		engine.WithRetention(cfg.Ingest.Retention.MaxAds, time.Duration(cfg.Ingest.Retention.MaxAge)),
+	}
+	if cfg.Ingest.Network != "" {
+		engineOpts = append(engineOpts, engine.WithNetwork(cfg.Ingest.Network))
+	}
+	if cfg.Ingest.SortedChunks {
+		engineOpts = append(engineOpts, engine.WithSortedChainedEntries(cfg.Ingest.LinkedChunkSize))
+	} else {
+		engineOpts = append(engineOpts, engine.WithChainedEntries(cfg.Ingest.LinkedChunkSize))
+	}
+	// Optionally serve advertisements on the admin server instead of on a
+	// listener of their own.
+	if cfg.AdminServer.HostPublisher {
+		engineOpts = append(engineOpts, engine.WithHttpPublisherWithoutServer())
+		if cfg.Ingest.HttpPublisher.AnnounceMultiaddr == "" {
+			pubAddr, err := cfg.AdminServer.PublisherMultiaddr()
+			if err != nil {
+				return err
+			}
+			engineOpts = append(engineOpts, engine.WithHttpPublisherAnnounceAddr(pubAddr.String()))
+		}
+	}
+	if cfg.Testing != nil {
+		engineOpts = append(engineOpts, engine.WithFaults(engine.Faults{
+			AnnounceFailureRate:       cfg.Testing.AnnounceFailureRate,
+			SlowListerRate:            cfg.Testing.SlowListerRate,
+			SlowListerDelay:           time.Duration(cfg.Testing.SlowListerDelay),
+			DatastoreWriteFailureRate: cfg.Testing.DatastoreWriteFailureRate,
+		}))
+	}
+	eng, err := engine.New(engineOpts...)
 	if err != nil {
 		return err
 	}
@@ -173,6 +309,78 @@ func daemonCommand(cctx *cli.Context) error {
 
 	// Instantiate CAR supplier and register it as the multihash lister onto the engine.
 	cs := supplier.NewCarSupplier(eng, ds, car.ZeroLengthSectionAsEOF(carZeroLengthAsEOFFlagValue))
+	cs.SetPersistDetachedIndexes(persistCarIndexesFlagValue)
+	cs.SetDeduplicate(dedupCarsFlagValue)
+	ms := supplier.NewMultihashSupplier(eng, ds)
+	listers := []provider.MultihashLister{cs.ListMultihashes, ms.ListMultihashes}
+	// Optionally advertise the pieces of the active deals of a storage provider.
+	var syncer *dealSyncer
+	if cfg.FilecoinDeals.Enabled() {
+		dealSupplier := newDealSupplier(eng, ds, cfg.FilecoinDeals)
+		listers = append(listers, dealSupplier.ListMultihashes)
+		syncer = startDealSyncer(dealSupplier, time.Duration(cfg.FilecoinDeals.SyncInterval))
+		log.Infow("Advertising pieces of Filecoin deals", "interval", cfg.FilecoinDeals.SyncInterval)
+	}
+	eng.RegisterMultihashLister(supplier.ChainListers(listers...))
+
+	// Optionally prune old advertisements.
+	var prune *pruner
+	if cfg.Ingest.Retention.Enabled() {
+		prune = startPruner(eng, time.Duration(cfg.Ingest.Retention.PruneInterval))
+		log.Infow("Pruning advertisement chain", "maxAds", cfg.Ingest.Retention.MaxAds,
+			"maxAge", cfg.Ingest.Retention.MaxAge, "interval", cfg.Ingest.Retention.PruneInterval)
+	}
+
+	// Optionally host the advertisement chains of other providers.
+	var tenants *engine.Tenants
+	var tenantsSvr *http.Server
+	tenantsErrChan := make(chan error, 1)
+	if cfg.Tenants.Enabled() {
+		if tenants, err = newTenants(cfg, ds); err != nil {
+			return err
+		}
+		if err = tenants.Start(ctx); err != nil {
+			return err
+		}
+		if tenantsSvr, err = serveTenants(cfg.Tenants, tenants, tenantsErrChan); err != nil {
+			return err
+		}
+		log.Infow("Hosting tenants", "count", tenants.Len(), "address", cfg.Tenants.ListenMultiaddr)
+	}
+
+	// Optionally notify operators when publishing advertisements runs into trouble.
+	var alerts *alert.Monitor
+	if cfg.Alerts.Enabled() {
+		if alerts, err = newAlertMonitor(eng, peerID, cfg.Alerts); err != nil {
+			return err
+		}
+		alerts.Start()
+		log.Infow("Sending alerts", "webhook", cfg.Alerts.WebhookURL, "command", cfg.Alerts.Command)
+	}
+
+	// Optionally check that the advertised content can be retrieved.
+	var selfChecker *selfcheck.Checker
+	if cfg.Retrieval.SelfCheck.Enabled {
+		if selfChecker, err = newSelfChecker(eng, cfg.Retrieval.SelfCheck); err != nil {
+			return err
+		}
+		selfChecker.Start()
+		log.Info("Checking retrieval of advertised content")
+	}
+
+	// Optionally remove CARs that were deleted or became unreadable.
+	reconcileCtx, stopReconcile := context.WithCancel(context.Background())
+	defer stopReconcile()
+	reconcileDone := make(chan struct{})
+	if reconcileCarsFlagValue > 0 {
+		go func() {
+			defer close(reconcileDone)
+			cs.ReconcileEvery(reconcileCtx, reconcileCarsFlagValue)
+		}()
+		log.Infow("Reconciling imported CARs", "interval", reconcileCarsFlagValue)
+	} else {
+		close(reconcileDone)
+	}
 
 	// Start serving CAR files for retrieval requests
 	err = cardatatransfer.StartCarDataTransfer(dt, cs)
@@ -186,18 +394,69 @@ func daemonCommand(cctx *cli.Context) error {
 		return err
 	}
 
-	adminSvr, err := adminserver.New(
-		h,
-		eng,
-		cs,
+	adminTLSConfig, err := cfg.AdminServer.TLSConfig()
+	if err != nil {
+		return err
+	}
+	// Advertisements requested without metadata get the configured default.
+	var defaultMetadata func(contextID []byte) (metadata.Metadata, error)
+	if cfg.Retrieval.Enabled() {
+		defaultMetadata = cfg.Retrieval.Metadata
+	}
+	adminOpts := []adminserver.Option{
 		adminserver.WithListenAddr(addr),
 		adminserver.WithReadTimeout(time.Duration(cfg.AdminServer.ReadTimeout)),
 		adminserver.WithWriteTimeout(time.Duration(cfg.AdminServer.WriteTimeout)),
-	)
-
+		adminserver.WithBearerToken(cfg.AdminServer.BearerToken),
+		adminserver.WithTLSConfig(adminTLSConfig),
+		adminserver.WithMultihashSupplier(ms),
+		adminserver.WithDatastore(ds),
+		adminserver.WithDefaultMetadata(defaultMetadata),
+		adminserver.WithProfiling(cfg.AdminServer.EnableProfiling),
+		adminserver.WithDebugBlocks(cfg.AdminServer.EnableDebugBlocks),
+		adminserver.WithPublicMetrics(cfg.AdminServer.PublicMetrics),
+	}
+	if cfg.AdminServer.HostPublisher {
+		pubHandler, err := eng.GetPublisherHttpFunc()
+		if err != nil {
+			return err
+		}
+		adminOpts = append(adminOpts, adminserver.WithPublisherHandler(pubHandler))
+	}
+	if tenants != nil {
+		adminOpts = append(adminOpts, adminserver.WithTenants(tenants))
+	}
+	if metricsExporter != nil && metricsSvr == nil {
+		adminOpts = append(adminOpts, adminserver.WithMetricsHandler(metricsExporter))
+	}
+	if cfg.AdminServer.MaxRequestBodySize > 0 {
+		adminOpts = append(adminOpts, adminserver.WithMaxRequestBodySize("/", cfg.AdminServer.MaxRequestBodySize))
+	}
+	for route, size := range cfg.AdminServer.MaxRequestBodySizes {
+		adminOpts = append(adminOpts, adminserver.WithMaxRequestBodySize(route, size))
+	}
+	if cfg.AdminServer.IdempotencyKeyTTL > 0 {
+		adminOpts = append(adminOpts, adminserver.WithIdempotencyKeyTTL(time.Duration(cfg.AdminServer.IdempotencyKeyTTL)))
+	}
+	for route, limit := range adminRateLimits(cfg.AdminServer.RateLimits) {
+		adminOpts = append(adminOpts, adminserver.WithRateLimit(route, limit.Rate, limit.Burst))
+	}
+	reannouncer := startReannouncer(eng, time.Duration(cfg.DirectAnnounce.ReannounceInterval))
+	defer reannouncer.stop()
+	reloader := &reloader{
+		cfg:         cfg,
+		logLevel:    cctx.String("log-level"),
+		eng:         eng,
+		h:           h,
+		syncPolicy:  syncPolicy,
+		reannouncer: reannouncer,
+	}
+	adminOpts = append(adminOpts, adminserver.WithReloadFunc(reloader.reload))
+	adminSvr, err := adminserver.New(h, eng, cs, adminOpts...)
 	if err != nil {
 		return err
 	}
+	reloader.adminSvr = adminSvr
 	log.Infow("admin server initialized", "address", cfg.AdminServer.ListenMultiaddr)
 
 	adminErrChan := make(chan error, 1)
@@ -206,6 +465,32 @@ func daemonCommand(cctx *cli.Context) error {
 		adminErrChan <- adminSvr.Start()
 	}()
 
+	// Optionally serve the admin API over gRPC too.
+	var adminGRPCSvr *admingrpc.Server
+	adminGRPCErrChan := make(chan error, 1)
+	grpcAddr, err := cfg.AdminServer.GRPCListenNetAddr()
+	if err != nil {
+		return err
+	}
+	if grpcAddr != "" {
+		adminGRPCSvr, err = admingrpc.New(h, eng, cs,
+			admingrpc.WithListenAddr(grpcAddr),
+			admingrpc.WithBearerToken(cfg.AdminServer.BearerToken),
+			admingrpc.WithTLSConfig(adminTLSConfig),
+			admingrpc.WithMultihashSupplier(ms),
+			admingrpc.WithDefaultMetadata(defaultMetadata),
+		)
+		if err != nil {
+			return err
+		}
+		log.Infow("admin grpc server initialized", "address", cfg.AdminServer.GRPCListenMultiaddr)
+
+		fmt.Fprintf(cctx.App.ErrWriter, "Starting admin grpc server on %s ...", cfg.AdminServer.GRPCListenMultiaddr)
+		go func() {
+			adminGRPCErrChan <- adminGRPCSvr.Start()
+		}()
+	}
+
 	droutingErrChan := make(chan error, 1)
 	// If there are bootstrap peers and bootstrapping is enabled, then try to
 	// connect to the minimum set of peers.
@@ -246,6 +531,8 @@ func daemonCommand(cctx *cli.Context) error {
 			droutingserver.WithReadTimeout(time.Duration(cfg.DelegatedRouting.ReadTimeout)),
 			droutingserver.WithWriteTimeout(time.Duration(cfg.DelegatedRouting.WriteTimeout)),
 			droutingserver.WithAdFlushFrequency(time.Duration(cfg.DelegatedRouting.AdFlushFrequency)),
+			droutingserver.WithChunkMaxBytes(cfg.DelegatedRouting.ChunkMaxBytes),
+			droutingserver.WithCompactionInterval(time.Duration(cfg.DelegatedRouting.CompactionInterval)),
 		)
 
 		if err != nil {
@@ -259,16 +546,40 @@ func daemonCommand(cctx *cli.Context) error {
 		}()
 	}
 
+	// Reload the config upon SIGHUP.
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+
 	var finalErr error
 	// Keep process running.
-	select {
-	case <-cctx.Done():
-	case err = <-adminErrChan:
-		log.Errorw("Failed to start admin server", "err", err)
-		finalErr = ErrDaemonStart
-	case err = <-droutingErrChan:
-		log.Errorw("Failed to start delegated routing server", "err", err)
-		finalErr = ErrDaemonStart
+wait:
+	for {
+		select {
+		case <-sighup:
+			log.Info("Received SIGHUP, reloading config")
+			if _, err = reloader.reload(ctx); err != nil {
+				log.Errorw("Failed to reload config", "err", err)
+			}
+		case <-cctx.Done():
+			break wait
+		case err = <-adminErrChan:
+			log.Errorw("Failed to start admin server", "err", err)
+			finalErr = ErrDaemonStart
+			break wait
+		case err = <-adminGRPCErrChan:
+			log.Errorw("Failed to start admin grpc server", "err", err)
+			finalErr = ErrDaemonStart
+			break wait
+		case err = <-droutingErrChan:
+			log.Errorw("Failed to start delegated routing server", "err", err)
+			finalErr = ErrDaemonStart
+			break wait
+		case err = <-tenantsErrChan:
+			log.Errorw("Failed to serve tenants", "err", err)
+			finalErr = ErrDaemonStart
+			break wait
+		}
 	}
 
 	log.Infow("Shutting down daemon")
@@ -284,6 +595,33 @@ func daemonCommand(cctx *cli.Context) error {
 		}
 	}()
 
+	reannouncer.stop()
+	if syncer != nil {
+		syncer.stop()
+	}
+	if prune != nil {
+		prune.stop()
+	}
+	stopReconcile()
+	<-reconcileDone
+	if alerts != nil {
+		alerts.Close()
+	}
+	if selfChecker != nil {
+		if err = selfChecker.Close(); err != nil {
+			log.Errorw("Error closing retrieval self-check", "err", err)
+		}
+	}
+	if tenantsSvr != nil {
+		if err = tenantsSvr.Shutdown(shutdownCtx); err != nil {
+			log.Errorw("Error shutting down tenants server.", "err", err)
+			finalErr = ErrDaemonStop
+		}
+		if err = tenants.Shutdown(); err != nil {
+			log.Errorw("Error shutting down tenants.", "err", err)
+			finalErr = ErrDaemonStop
+		}
+	}
 	if err = eng.Shutdown(); err != nil {
 		log.Errorf("Error closing provider core: %s", err)
 		finalErr = ErrDaemonStop
@@ -301,16 +639,78 @@ func daemonCommand(cctx *cli.Context) error {
 		log.Errorw("Error shutting down admin server: %s", err)
 		finalErr = ErrDaemonStop
 	}
+	if adminGRPCSvr != nil {
+		if err = adminGRPCSvr.Shutdown(shutdownCtx); err != nil {
+			log.Errorw("Error shutting down admin grpc server.", "err", err)
+			finalErr = ErrDaemonStop
+		}
+	}
 	if droutingSrv != nil {
 		if err = droutingSrv.Shutdown(shutdownCtx); err != nil {
 			log.Errorw("Error shutting down delegated routing server.", "err", err)
 			finalErr = ErrDaemonStop
 		}
 	}
+	var metricsErr error
+	if metricsSvr != nil {
+		metricsErr = metricsSvr.Shutdown(shutdownCtx)
+	} else if metricsExporter != nil {
+		metricsErr = metricsExporter.Shutdown(shutdownCtx)
+	}
+	if metricsErr != nil {
+		log.Errorw("Error shutting down metrics.", "err", metricsErr)
+		finalErr = ErrDaemonStop
+	}
+	if traceExporter != nil {
+		if err = traceExporter.Shutdown(shutdownCtx); err != nil {
+			log.Errorw("Error shutting down trace exporter.", "err", err)
+			finalErr = ErrDaemonStop
+		}
+	}
 	log.Infow("node stopped")
 	return finalErr
 }
 
+// daemonRetrievalAddrs returns the retrieval addresses to advertise, i.e. the
+// configured ones, or the listen addresses of the host if none are configured,
+// along with the address of the configured HTTP gateway, if any.
+func daemonRetrievalAddrs(cfg *config.Config, h host.Host) ([]string, error) {
+	gatewayAddr, err := cfg.Retrieval.HttpGatewayMultiaddr()
+	if err != nil {
+		return nil, fmt.Errorf("bad Retrieval.HttpGatewayURL: %w", err)
+	}
+	if gatewayAddr == nil {
+		return cfg.ProviderServer.RetrievalMultiaddrs, nil
+	}
+	addrs := slices.Clone(cfg.ProviderServer.RetrievalMultiaddrs)
+	if len(addrs) == 0 {
+		for _, addr := range h.Addrs() {
+			addrs = append(addrs, addr.String())
+		}
+	}
+	return append(addrs, gatewayAddr.String()), nil
+}
+
+// externalAddrsQuiet returns the quiet period after which changes of the
+// external addresses of the host are advertised, or zero if they are not
+// advertised.
+func externalAddrsQuiet(cfg config.ProviderServer) time.Duration {
+	if !cfg.ExternalRetrievalAddrs {
+		return 0
+	}
+	return time.Minute
+}
+
+// compactingDatastore is a leveldb datastore whose garbage collection compacts
+// the database, such that the space used by deleted items is reclaimed.
+type compactingDatastore struct {
+	*leveldb.Datastore
+}
+
+func (ds compactingDatastore) CollectGarbage(context.Context) error {
+	return ds.DB.CompactRange(util.Range{})
+}
+
 // dirWritable checks if a directory is writable. If the directory does
 // not exist it is created with writable permission.
 func dirWritable(dir string) error {
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipni/index-provider/cmd/provider/internal/config"
+	"github.com/ipni/index-provider/engine"
+	"github.com/ipni/index-provider/supplier"
+)
+
+// dealSyncer periodically syncs the advertised pieces with the active deals of
+// a Filecoin storage provider.
+type dealSyncer struct {
+	supplier *supplier.DealSupplier
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// newDealSupplier instantiates the supplier of the pieces of the deals
+// configured by cfg.
+func newDealSupplier(eng *engine.Engine, ds datastore.Datastore, cfg config.FilecoinDeals) *supplier.DealSupplier {
+	deals := supplier.MarketDealLister(http.DefaultClient, cfg.MarketAPIURL, cfg.MarketAPIToken)
+	return supplier.NewDealSupplier(eng, ds, deals, cfg.PieceURL, cfg.FastRetrieval)
+}
+
+func startDealSyncer(s *supplier.DealSupplier, interval time.Duration) *dealSyncer {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &dealSyncer{
+		supplier: s,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	go d.run(ctx, interval)
+	return d
+}
+
+func (d *dealSyncer) run(ctx context.Context, interval time.Duration) {
+	defer close(d.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		added, removed, err := d.supplier.Sync(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Errorw("Failed to sync pieces of deals", "err", err)
+		} else if added != 0 || removed != 0 {
+			log.Infow("Synced pieces of deals", "added", added, "removed", removed)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// stop stops syncing and waits for an ongoing sync to finish.
+func (d *dealSyncer) stop() {
+	d.cancel()
+	<-d.done
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/go-libipni/apierror"
+	findclient "github.com/ipni/go-libipni/find/client"
+	"github.com/ipni/index-provider/cmd/provider/internal"
+	"github.com/urfave/cli/v2"
+)
+
+var DiffCmd = &cli.Command{
+	Name:  "diff",
+	Usage: "Shows how far behind the local advertisement chain an indexer is",
+	Description: `Fetches the latest advertisement that the given indexer has processed for this provider, and walks
+the local advertisement chain from the current head back to that advertisement. Prints how many
+advertisements, contexts, and optionally multihashes, the indexer has yet to process.
+
+The provider ID and publisher address are read from the provider configuration unless specified
+via --provider-id and --provider-addr-info.`,
+	Action: doDiff,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "indexer",
+			Usage:    "The URL of the indexer to compare against.",
+			Aliases:  []string{"i"},
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:        "provider-id",
+			Usage:       "The ID of the provider. Defaults to the ID in the provider configuration.",
+			Destination: &statusProviderID,
+		},
+		optionalProviderAddrInfoFlag,
+		&cli.BoolFlag{
+			Name:    "entries",
+			Usage:   "Whether to fetch the entries of advertisements to count the multihashes the indexer is behind.",
+			Aliases: []string{"e"},
+		},
+		&cli.UintFlag{
+			Name:  "max-ads",
+			Usage: "The maximum number of advertisements to walk back from the local head. Zero means no limit.",
+			Value: 10000,
+		},
+	},
+}
+
+// chainDiff summarises the advertisements between two advertisements in a
+// chain.
+type chainDiff struct {
+	ads         int
+	removals    int
+	contexts    int
+	multihashes int
+}
+
+func doDiff(cctx *cli.Context) error {
+	providerID, err := statusLocalProviderID()
+	if err != nil {
+		return err
+	}
+
+	client, err := findclient.New(cctx.String("indexer"))
+	if err != nil {
+		return err
+	}
+	var ingested cid.Cid
+	info, err := client.GetProvider(cctx.Context, providerID)
+	if err != nil {
+		var apiErr *apierror.Error
+		if !errors.As(err, &apiErr) || apiErr.Status() != http.StatusNotFound {
+			return fmt.Errorf("failed to get provider info from indexer: %w", err)
+		}
+	} else {
+		ingested = info.LastAdvertisement
+	}
+
+	pc, localHead, err := statusLocalHead(cctx, providerID)
+	if pc != nil {
+		defer pc.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get local head: %w", err)
+	}
+
+	w := cctx.App.Writer
+	fmt.Fprintln(w, "Provider:            ", providerID)
+	fmt.Fprintln(w, "Local head:          ", localHead)
+	if ingested == cid.Undef {
+		fmt.Fprintln(w, "Indexer last ingested: none")
+	} else {
+		fmt.Fprintln(w, "Indexer last ingested:", ingested)
+	}
+
+	countEntries := cctx.Bool("entries")
+	diff, err := diffChain(cctx, pc, localHead, ingested, cctx.Uint("max-ads"), countEntries)
+	if err != nil {
+		return err
+	}
+	printChainDiff(w, diff, countEntries)
+	return nil
+}
+
+// diffChain walks the chain back from head until the advertisement ingested,
+// exclusive, and summarises the advertisements visited. The whole chain is
+// walked if ingested is cid.Undef. An error is returned if ingested is not
+// found within max advertisements of head.
+func diffChain(cctx *cli.Context, pc internal.ProviderClient, head, ingested cid.Cid, max uint, countEntries bool) (*chainDiff, error) {
+	var diff chainDiff
+	if head == ingested {
+		return &diff, nil
+	}
+
+	contexts := make(map[string]struct{})
+	var last *internal.Advertisement
+	err := walkAds(cctx.Context, pc, head, ingested, max, func(ad *internal.Advertisement) error {
+		last = ad
+		diff.ads++
+		if ad.IsRemove {
+			diff.removals++
+		}
+		if len(ad.ContextID) != 0 {
+			contexts[string(ad.ContextID)] = struct{}{}
+		}
+		if countEntries && !ad.IsRemove {
+			for {
+				_, err := ad.Entries.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					return fmt.Errorf("failed to fetch entries of advertisement %s: %w", ad.ID, err)
+				}
+				diff.multihashes++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if last != nil && last.PreviousID != ingested {
+		if last.PreviousID == cid.Undef {
+			return nil, fmt.Errorf("advertisement %s ingested by the indexer is not in the local chain", ingested)
+		}
+		return nil, fmt.Errorf("advertisement %s ingested by the indexer not found within %d advertisements of the local head", ingested, max)
+	}
+	diff.contexts = len(contexts)
+	return &diff, nil
+}
+
+func printChainDiff(w io.Writer, diff *chainDiff, countEntries bool) {
+	if diff.ads == 0 {
+		fmt.Fprintln(w, "The indexer is up to date.")
+		return
+	}
+	fmt.Fprintln(w, "The indexer is behind by:")
+	fmt.Fprintf(w, "  Advertisements: %d (%d removals)\n", diff.ads, diff.removals)
+	fmt.Fprintln(w, "  Contexts:      ", diff.contexts)
+	if countEntries {
+		fmt.Fprintln(w, "  Multihashes:   ", diff.multihashes)
+	}
+}
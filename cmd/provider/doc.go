@@ -16,17 +16,31 @@ Usage:
 	   announce-http  Publish an announcement message for the latest advertisement to a specific indexer via http
 	   connect        Connects to an indexer through its multiaddr
 	   daemon         Starts a reference provider
+	   diff           Shows how far behind the local advertisement chain an indexer is
+	   export-chain   Exports the advertisement chain of the provider to a CAR file
+	   fetch-entries  Fetches the entries of an advertisement and writes out the multihashes it advertises
 	   import, i      Imports sources of multihashes to the index provider.
+	   import-chain   Imports an advertisement chain from a CAR file
 	   index          Push a single content index into an indexer
 	   init           Initialize reference provider config file and identity
-	   list, ls       List local paths to data
+	   list, ls       List local paths to data or advertisements
+	   probe          Checks that a CID is indexed and retrievable from the providers found by indexers
 	   remove, rm     Removes previously advertised multihashes by the provider.
+	   rotate-key     Rotates the identity of the provider to a new key
+	   stats          Shows statistics of a running provider
+	   status         Shows the status of the provider as seen by indexers
+	   verify         Verifies the advertisement chain of a provider
 	   mirror         Mirrors the advertisement chain from an existing index provider.
 	   help, h        Shows a list of commands or help for one command
 
 	GLOBAL OPTIONS:
-	   --help, -h     show help
-	   --version, -v  print the version
+	   --error-format value       The format in which errors are written to stderr, one of: text, json. (default: "text") [$PROVIDER_ERROR_FORMAT]
+	   --admin-token value        Bearer token with which to authenticate to the admin HTTP API [$PROVIDER_ADMIN_TOKEN]
+	   --admin-ca-cert value      Path to the PEM encoded CA certificates with which to verify the admin HTTP API certificate [$PROVIDER_ADMIN_CA_CERT]
+	   --admin-client-cert value  Path to the PEM encoded client certificate to present to the admin HTTP API [$PROVIDER_ADMIN_CLIENT_CERT]
+	   --admin-client-key value   Path to the PEM encoded key of the client certificate to present to the admin HTTP API [$PROVIDER_ADMIN_CLIENT_KEY]
+	   --help, -h                 show help
+	   --version, -v              print the version
 
 To run a provider daemon it must first be initialized. To initialize the provider, execute:
 
@@ -37,6 +51,12 @@ generated identity keypair. The configuration is stored at user home
 under ".index-provider/config" in JSON format. The root configuration path can be overridden by
 setting the "PROVIDER_PATH" environment variable.
 
+Instead of the default configuration, a configuration suited to a common kind of deployment can be
+generated from one of the "filecoin-sp", "ipfs-node" or "http-only" presets, and the most commonly
+changed settings can be prompted for by initializing interactively:
+
+	provider init --preset filecoin-sp --interactive
+
 Once initialized, the daemon can be started by executing:
 
 	provider daemon
@@ -48,6 +68,41 @@ allows
 Additionally, it starts an admin HTTP server at "http://localhost:3102" that enables administrative
 operations using the "provider" CLI tool.
 
+The admin server is unauthenticated by default, and only listens on localhost. Before exposing it
+more widely, require a bearer token by setting "AdminServer.BearerToken" in the configuration, and
+serve HTTPS by setting "AdminServer.TLSCertPath" and "AdminServer.TLSKeyPath". Setting
+"AdminServer.ClientCAPath" additionally requires clients to present a certificate signed by one of
+the given CAs. The CLI authenticates to the admin server with the "--admin-token",
+"--admin-client-cert" and "--admin-client-key" global options, or the corresponding environment
+variables:
+
+	PROVIDER_ADMIN_TOKEN=<token> provider list ctx -l https://<admin-host>:3102
+
+Unless disabled by setting "Metrics.Enabled" to false in the configuration, the daemon exposes
+metrics in Prometheus format at "/metrics" on the admin server, or on a separate server listening
+on "Metrics.ListenMultiaddr" if set.
+
+The admin server also serves "/healthz" and "/readyz" probes, which are not subject to the bearer
+token. Readiness reflects whether the engine has started, the publisher is listening and the
+datastore is writable. Health fails when an announcement has been in flight for over 5 minutes.
+
+Space used by the daemon can be reclaimed without a restart: "POST /admin/cache/purge" deletes all
+cached advertisement entries, and "POST /admin/gc" deletes unreferenced cache items and compacts
+the datastore. Both respond with a job whose status, including the number of bytes reclaimed, is
+reported by "GET /admin/jobs/<job-id>".
+
+Log levels can be changed at runtime, without restarting the daemon with GOLOG_LOG_LEVEL set.
+"GET /admin/log" lists the level of each logging subsystem, and "PUT /admin/log/<subsystem>" sets
+the level of a subsystem, or of all subsystems if "*" is given:
+
+	curl -X PUT -d '{"level":"debug"}' http://localhost:3102/admin/log/provider/engine
+
+To verify connectivity to indexers, "GET /admin/peers" lists the connected libp2p peers along with
+their addresses and supported protocols. Peers are connected to with "POST /admin/connect", or the
+"connect" command, and disconnected from with "POST /admin/disconnect". "POST /admin/protect" and
+"POST /admin/unprotect" protect the connections to a peer from being trimmed by the connection
+manager.
+
 To advertise the availability of content by the daemon to indexer nodes, run:
 
 	provider import car -l http://localhost:3102 -i <path-to-car-file>
@@ -56,8 +111,23 @@ This command will generate a new advertisement for the list of multihashes in th
 publishes that advertisement onto the configured gossipsub channel for indexer nodes to see.
 Additionally, it makes the CAR content available for retrieval over GraphSync.
 
+A directory of CAR files can be imported by specifying its path instead. With the "--watch" option,
+the directory is then watched for changes until interrupted: CAR files added to the directory are
+imported, and the removal of CAR files deleted from it is advertised:
+
+	provider import car -l http://localhost:3102 -i <path-to-car-directory> --watch
+
 Similarly to advertise that the content is no longer available for retrieval by the daemon, use
-"provider remove car" command.
+"provider remove car" command. Content advertised under arbitrary context IDs can be removed by
+specifying the base64 encoded context IDs, or all currently advertised content by specifying --all:
+
+	provider remove -l http://localhost:3102 --context-id <base64-context-id>
+
+Common failures exit with distinct status codes, so that scripts can branch on the type of failure:
+1 for any other failure, 2 for invalid usage, 3 if the provider or indexer is unreachable, 4 if an
+advertisement or other resource is not found, 5 if the entries recursion limit is reached, and 6 if
+an advertisement signature is invalid. With "--error-format json", the error is written to stderr as
+a JSON object with "error", "class" and "exit_code" fields.
 
 For a full list of available commands and options run:
 
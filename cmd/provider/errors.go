@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+
+	"github.com/ipfs/go-datastore"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/cmd/provider/internal"
+	"github.com/ipni/index-provider/cmd/provider/internal/adminclient"
+	"github.com/libp2p/go-libp2p/p2p/net/swarm"
+	"github.com/urfave/cli/v2"
+)
+
+// Exit codes of the provider CLI. Common failure modes have distinct exit
+// codes so that scripts can branch on the type of failure.
+const (
+	exitCodeOK               = 0
+	exitCodeFailure          = 1
+	exitCodeUsage            = 2
+	exitCodeUnreachable      = 3
+	exitCodeNotFound         = 4
+	exitCodeRecursionLimit   = 5
+	exitCodeInvalidSignature = 6
+)
+
+const (
+	errorFormatText = "text"
+	errorFormatJson = "json"
+)
+
+// errorClass classifies a failure of the CLI.
+type errorClass struct {
+	name     string
+	exitCode int
+}
+
+var (
+	errClassFailure          = errorClass{"failure", exitCodeFailure}
+	errClassUsage            = errorClass{"usage", exitCodeUsage}
+	errClassUnreachable      = errorClass{"unreachable", exitCodeUnreachable}
+	errClassNotFound         = errorClass{"not_found", exitCodeNotFound}
+	errClassRecursionLimit   = errorClass{"recursion_limit", exitCodeRecursionLimit}
+	errClassInvalidSignature = errorClass{"invalid_signature", exitCodeInvalidSignature}
+
+	errorClasses = []errorClass{
+		errClassFailure,
+		errClassUsage,
+		errClassUnreachable,
+		errClassNotFound,
+		errClassRecursionLimit,
+		errClassInvalidSignature,
+	}
+)
+
+// errInvalidSignature signals that an advertisement has an invalid signature.
+var errInvalidSignature = errors.New("invalid signature")
+
+var (
+	errorFormatFlagValue string
+	errorFormatFlag      = &cli.StringFlag{
+		Name:        "error-format",
+		Usage:       "The format in which errors are written to stderr, one of: text, json.",
+		EnvVars:     []string{"PROVIDER_ERROR_FORMAT"},
+		Value:       errorFormatText,
+		Destination: &errorFormatFlagValue,
+	}
+)
+
+// classifiedError is an error explicitly assigned a class.
+type classifiedError struct {
+	class errorClass
+	err   error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() error {
+	return e.err
+}
+
+// withErrorClass assigns the given class to err.
+func withErrorClass(class errorClass, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{class: class, err: err}
+}
+
+// classifyError returns the class of the given error, which is the class
+// explicitly assigned to it if any, and is otherwise derived from the errors
+// it wraps.
+func classifyError(err error) errorClass {
+	var ce *classifiedError
+	if errors.As(err, &ce) {
+		return ce.class
+	}
+	var exitCoder cli.ExitCoder
+	if errors.As(err, &exitCoder) {
+		for _, class := range errorClasses {
+			if class.exitCode == exitCoder.ExitCode() {
+				return class
+			}
+		}
+		return errorClass{errClassFailure.name, exitCoder.ExitCode()}
+	}
+
+	var netErr net.Error
+	var dialErr *swarm.DialError
+	switch {
+	case errors.Is(err, internal.ErrEntriesRecursionLimit),
+		errors.Is(err, internal.ErrEntriesBudgetExhausted):
+		return errClassRecursionLimit
+	case errors.Is(err, errInvalidSignature):
+		return errClassInvalidSignature
+	case errors.Is(err, internal.ErrNotFound),
+		errors.Is(err, adminclient.ErrNotFound),
+		errors.Is(err, internal.ErrNoHead),
+		errors.Is(err, datastore.ErrNotFound),
+		errors.Is(err, provider.ErrContextIDNotFound):
+		return errClassNotFound
+	case errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.EHOSTUNREACH),
+		errors.Is(err, syscall.ENETUNREACH),
+		errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, os.ErrDeadlineExceeded),
+		errors.As(err, &dialErr),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return errClassUnreachable
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return errClassUnreachable
+	}
+	return errClassFailure
+}
+
+// writeError writes the error to w in the given format, and returns the exit
+// code corresponding to its class.
+func writeError(w io.Writer, format string, err error) int {
+	class := classifyError(err)
+	if format == errorFormatJson {
+		_ = json.NewEncoder(w).Encode(struct {
+			Error    string `json:"error"`
+			Class    string `json:"class"`
+			ExitCode int    `json:"exit_code"`
+		}{err.Error(), class.name, class.exitCode})
+	} else {
+		fmt.Fprintln(w, err)
+	}
+	return class.exitCode
+}
+
+// classifyUsageErrors sets the usage error handler of the given commands and
+// their subcommands, so that usage errors are classified as such.
+func classifyUsageErrors(cmds []*cli.Command) {
+	for _, cmd := range cmds {
+		if cmd.OnUsageError == nil {
+			cmd.OnUsageError = onUsageError
+		}
+		classifyUsageErrors(cmd.Subcommands)
+	}
+}
+
+func onUsageError(_ *cli.Context, err error, _ bool) error {
+	return withErrorClass(errClassUsage, err)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipni/index-provider/cmd/provider/internal"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func Test_classifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want errorClass
+	}{
+		{"generic", errors.New("fish"), errClassFailure},
+		{"explicit", withErrorClass(errClassNotFound, errors.New("fish")), errClassNotFound},
+		{"exit coder", cli.Exit("fish", exitCodeUsage), errClassUsage},
+		{"recursion limit", fmt.Errorf("lobster: %w", internal.ErrEntriesRecursionLimit), errClassRecursionLimit},
+		{"budget exhausted", &internal.EntriesBudgetError{}, errClassRecursionLimit},
+		{"invalid signature", fmt.Errorf("%w: %w", errInvalidSignature, errors.New("fish")), errClassInvalidSignature},
+		{"not found", fmt.Errorf("lobster: %w", internal.ErrNotFound), errClassNotFound},
+		{"no head", internal.ErrNoHead, errClassNotFound},
+		{"datastore not found", datastore.ErrNotFound, errClassNotFound},
+		{"connection refused", &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, errClassUnreachable},
+		{"dns", &net.DNSError{Err: "no such host", Name: "fish"}, errClassUnreachable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, classifyError(tt.err))
+		})
+	}
+}
+
+func Test_writeError(t *testing.T) {
+	err := fmt.Errorf("lobster: %w", internal.ErrNotFound)
+
+	var buf bytes.Buffer
+	require.Equal(t, exitCodeNotFound, writeError(&buf, errorFormatText, err))
+	require.Equal(t, "lobster: not found\n", buf.String())
+
+	buf.Reset()
+	require.Equal(t, exitCodeNotFound, writeError(&buf, errorFormatJson, err))
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	require.Equal(t, map[string]any{
+		"error":     "lobster: not found",
+		"class":     "not_found",
+		"exit_code": float64(exitCodeNotFound),
+	}, got)
+}
+
+func Test_classifyUsageErrors(t *testing.T) {
+	app := &cli.App{
+		Writer:    &bytes.Buffer{},
+		ErrWriter: &bytes.Buffer{},
+		Commands: []*cli.Command{{
+			Name: "fish",
+			Subcommands: []*cli.Command{{
+				Name:   "lobster",
+				Action: func(*cli.Context) error { return nil },
+			}},
+		}},
+	}
+	classifyUsageErrors(app.Commands)
+	err := app.Run([]string{"provider", "fish", "lobster", "--undefined"})
+	require.Error(t, err)
+	require.Equal(t, errClassUsage, classifyError(err))
+}
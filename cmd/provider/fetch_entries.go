@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	carv2 "github.com/ipld/go-car/v2"
+	carstorage "github.com/ipld/go-car/v2/storage"
+	"github.com/ipni/index-provider/cmd/provider/internal"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	entriesFormatText = "text"
+	entriesFormatCar  = "car"
+)
+
+var FetchEntriesCmd = &cli.Command{
+	Name:  "fetch-entries",
+	Usage: "Fetches the entries of an advertisement and writes out the multihashes it advertises",
+	Description: `Syncs the full entries DAG of an advertisement from the given provider, and writes the
+multihashes advertised to a file, or to standard output if no file is specified. Entries are
+fetched for the latest advertisement unless --ad-cid is specified.
+
+The text format writes one base58 encoded multihash per line. The car format writes the entries
+DAG blocks as fetched to a CARv1 file, with the entries root as the CAR root.
+
+Use --recursion-limit to limit the number of entry chunks fetched. If the limit is reached, the
+entries written so far are kept, the output is incomplete, and the command exits with status 5.
+
+Use --auto-page to fetch entry chunks in pages of at most --recursion-limit chunks instead, until
+all entries are fetched or the budget given by --max-multihashes or --max-bytes is exhausted. If the
+budget is exhausted, the command reports the entry chunk from which the remaining entries start and
+exits with status 5. Fetching is resumed from that chunk with --entries-cid.`,
+	Action: doFetchEntries,
+	Flags: []cli.Flag{
+		providerAddrInfoFlag,
+		adCidFlag,
+		&cli.PathFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Usage:   "The file to write the entries to. Defaults to standard output.",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Usage: "The output format, one of: text, car.",
+			Value: entriesFormatText,
+		},
+		&cli.UintFlag{
+			Name:        "recursion-limit",
+			Usage:       "The maximum number of entry chunks to fetch, or to fetch per page with --auto-page.",
+			DefaultText: "No limit",
+		},
+		&cli.BoolFlag{
+			Name:  "auto-page",
+			Usage: "Whether to keep fetching entry chunks in pages once the recursion limit is reached, until the budget is exhausted.",
+		},
+		&cli.UintFlag{
+			Name:        "max-multihashes",
+			Usage:       "The multihash budget of --auto-page. Implies --auto-page.",
+			DefaultText: "No limit",
+		},
+		&cli.Uint64Flag{
+			Name:        "max-bytes",
+			Usage:       "The budget of --auto-page in bytes of entry chunks fetched. Implies --auto-page.",
+			DefaultText: "No limit",
+		},
+		&cli.StringFlag{
+			Name:  "entries-cid",
+			Usage: "The CID of the entry chunk from which to fetch entries, e.g. to resume fetching once the budget is exhausted. Overrides --ad-cid.",
+		},
+	},
+}
+
+func doFetchEntries(cctx *cli.Context) error {
+	format := cctx.String("format")
+	if format != entriesFormatText && format != entriesFormatCar {
+		return fmt.Errorf("unknown format: %s", format)
+	}
+	var adCid cid.Cid
+	if adCidFlagValue != "" {
+		var err error
+		if adCid, err = cid.Decode(adCidFlagValue); err != nil {
+			return fmt.Errorf("invalid ad-cid: %w", err)
+		}
+	}
+
+	var entriesCid cid.Cid
+	if v := cctx.String("entries-cid"); v != "" {
+		var err error
+		if entriesCid, err = cid.Decode(v); err != nil {
+			return fmt.Errorf("invalid entries-cid: %w", err)
+		}
+	}
+
+	ds := &recordingDatastore{Batching: dssync.MutexWrap(datastore.NewMapDatastore())}
+	opts := []internal.Option{
+		internal.WithDatastore(ds),
+		internal.WithEntriesRecursionLimit(int(cctx.Uint("recursion-limit"))),
+	}
+	if cctx.Bool("auto-page") || cctx.IsSet("max-multihashes") || cctx.IsSet("max-bytes") {
+		opts = append(opts, internal.WithEntriesAutoPaging(int(cctx.Uint("max-multihashes")), int64(cctx.Uint64("max-bytes"))))
+	}
+	pc, err := newProviderClient(opts...)
+	if err != nil {
+		return err
+	}
+	defer pc.Close()
+
+	// entriesOf describes the fetched entries in messages.
+	var entries *internal.EntriesIterator
+	var entriesOf string
+	if entriesCid != cid.Undef {
+		entries = pc.GetEntries(cctx.Context, entriesCid)
+		entriesOf = "entries " + entriesCid.String()
+	} else {
+		ad, err := pc.GetAdvertisement(cctx.Context, adCid)
+		if err != nil {
+			return err
+		}
+		if format == entriesFormatCar && !ad.HasEntries() {
+			return fmt.Errorf("advertisement %s has no entries", ad.ID)
+		}
+		entries = ad.Entries
+		entriesOf = "advertisement " + ad.ID.String()
+	}
+	// Only record the entries blocks fetched from now on.
+	ds.reset()
+
+	var w io.Writer = cctx.App.Writer
+	outPath := cctx.Path("output")
+	if outPath != "" {
+		f, err := os.Create(outPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+
+	var count int
+	var limitErr error
+	for {
+		mh, err := entries.Next()
+		if err == io.EOF {
+			break
+		}
+		if errors.Is(err, internal.ErrEntriesRecursionLimit) {
+			limitErr = fmt.Errorf("entries are incomplete after %d entry chunks: %w", entries.ChunkCount(), err)
+			break
+		}
+		if errors.Is(err, internal.ErrEntriesBudgetExhausted) {
+			limitErr = fmt.Errorf("entries are incomplete: %w", err)
+			fmt.Fprintln(cctx.App.ErrWriter, "More entries available; fetch them with --entries-cid", entries.Remaining())
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to fetch entries of %s: %w", entriesOf, err)
+		}
+		if format == entriesFormatText {
+			if _, err = fmt.Fprintln(bw, mh.B58String()); err != nil {
+				return err
+			}
+		}
+		count++
+	}
+	if format == entriesFormatCar {
+		if err = ds.writeCar(cctx.Context, bw, entries.Root()); err != nil {
+			return err
+		}
+	}
+	if err = bw.Flush(); err != nil {
+		return err
+	}
+
+	if outPath != "" {
+		fmt.Fprintf(cctx.App.ErrWriter, "Wrote %d multihashes of %s to %s\n", count, entriesOf, outPath)
+	}
+	return limitErr
+}
+
+// recordingDatastore records the keys of the blocks put into it, in order.
+type recordingDatastore struct {
+	datastore.Batching
+	mu   sync.Mutex
+	keys []datastore.Key
+}
+
+func (d *recordingDatastore) Put(ctx context.Context, key datastore.Key, value []byte) error {
+	if err := d.Batching.Put(ctx, key, value); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.keys = append(d.keys, key)
+	d.mu.Unlock()
+	return nil
+}
+
+func (d *recordingDatastore) reset() {
+	d.mu.Lock()
+	d.keys = nil
+	d.mu.Unlock()
+}
+
+// writeCar writes the recorded blocks to a CARv1 with the given root.
+func (d *recordingDatastore) writeCar(ctx context.Context, w io.Writer, root cid.Cid) error {
+	out, err := carstorage.NewWritable(w, []cid.Cid{root}, carv2.WriteAsCarV1(true))
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, key := range d.keys {
+		c, err := cid.Decode(strings.TrimPrefix(key.String(), "/"))
+		if err != nil {
+			return fmt.Errorf("invalid block key %s: %w", key, err)
+		}
+		data, err := d.Batching.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+		if err = out.Put(ctx, c.KeyString(), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	carv2 "github.com/ipld/go-car/v2"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/cmd/provider/internal"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestFetchEntriesCmd(t *testing.T) {
+	ctx := context.Background()
+	eng, err := engine.New(
+		engine.WithPublisherKind(engine.HttpPublisher),
+		engine.WithHttpPublisherWithoutServer(),
+		engine.WithPubsubAnnounce(false),
+		engine.WithChainedEntries(3))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+	mhs := test.RandomMultihashes(10)
+	eng.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(mhs), nil
+	})
+	adCid, err := eng.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	ad, err := eng.GetAdv(ctx, adCid)
+	require.NoError(t, err)
+	handler, err := eng.GetPublisherHttpFunc()
+	require.NoError(t, err)
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	runErr := func(args ...string) (string, string, error) {
+		var out, errOut bytes.Buffer
+		app := &cli.App{
+			Writer:    &out,
+			ErrWriter: &errOut,
+			Commands:  []*cli.Command{FetchEntriesCmd},
+		}
+		args = append([]string{"provider", "fetch-entries", "-p", server.URL}, args...)
+		err := app.Run(args)
+		return out.String(), errOut.String(), err
+	}
+	run := func(args ...string) (string, string) {
+		out, errOut, err := runErr(args...)
+		require.NoError(t, err)
+		return out, errOut
+	}
+
+	out, _ := run("--ad-cid", adCid.String())
+	var got []multihash.Multihash
+	for _, line := range strings.Fields(out) {
+		mh, err := multihash.FromB58String(line)
+		require.NoError(t, err)
+		got = append(got, mh)
+	}
+	require.ElementsMatch(t, mhs, got)
+
+	out, _, err = runErr("--recursion-limit", "2")
+	require.ErrorIs(t, err, internal.ErrEntriesRecursionLimit)
+	require.ErrorContains(t, err, "incomplete after 2 entry chunks")
+	require.Equal(t, exitCodeRecursionLimit, classifyError(err).exitCode)
+	require.Len(t, strings.Fields(out), 4)
+
+	carPath := filepath.Join(t.TempDir(), "entries.car")
+	_, errOut := run("--format", "car", "-o", carPath)
+	require.Contains(t, errOut, "Wrote 10 multihashes")
+	f, err := os.Open(carPath)
+	require.NoError(t, err)
+	defer f.Close()
+	br, err := carv2.NewBlockReader(f)
+	require.NoError(t, err)
+	require.Len(t, br.Roots, 1)
+	require.Equal(t, ad.Entries.(cidlink.Link).Cid, br.Roots[0])
+	var blocks int
+	for {
+		if _, err = br.Next(); err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		blocks++
+	}
+	require.Equal(t, 4, blocks)
+
+	// With auto paging, fetching continues past the recursion limit until
+	// the budget is exhausted, and is resumed from the remaining entries.
+	out, errOut, err = runErr("--recursion-limit", "1", "--max-multihashes", "2")
+	require.ErrorIs(t, err, internal.ErrEntriesBudgetExhausted)
+	require.Equal(t, exitCodeRecursionLimit, classifyError(err).exitCode)
+	got = nil
+	for _, line := range strings.Fields(out) {
+		mh, err := multihash.FromB58String(line)
+		require.NoError(t, err)
+		got = append(got, mh)
+	}
+	require.Len(t, got, 4)
+	_, remaining, ok := strings.Cut(strings.TrimSpace(errOut), "--entries-cid ")
+	require.True(t, ok)
+	out, _ = run("--entries-cid", remaining, "--auto-page")
+	for _, line := range strings.Fields(out) {
+		mh, err := multihash.FromB58String(line)
+		require.NoError(t, err)
+		got = append(got, mh)
+	}
+	require.ElementsMatch(t, mhs, got)
+}
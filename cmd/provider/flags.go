@@ -1,6 +1,12 @@
 package main
 
 import (
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/multiformats/go-multicodec"
 	"github.com/urfave/cli/v2"
 )
 
@@ -29,6 +35,16 @@ var (
 	}
 )
 
+var bitswapFlag = &cli.BoolFlag{
+	Name:  "bitswap",
+	Usage: "Advertise the content as retrievable over bitswap, in addition to the transports in the metadata, if any.",
+}
+
+var httpGatewayFlag = &cli.BoolFlag{
+	Name:  "http-gateway",
+	Usage: "Advertise the content as retrievable from the IPFS trustless HTTP gateway among the provider addresses, in addition to the transports in the metadata, if any.",
+}
+
 var (
 	keyFlagValue string
 	keyFlag      = &cli.StringFlag{
@@ -45,7 +61,7 @@ var (
 	carPathFlag      = &cli.StringFlag{
 		Name:        "input",
 		Aliases:     []string{"i"},
-		Usage:       "Path to the CAR file to import",
+		Usage:       "Path to the CAR file to import, to a directory of CAR files to import, or the http, https or s3 URL of a remote CAR file to import",
 		Destination: &carPathFlagValue,
 		Required:    true,
 	}
@@ -72,3 +88,153 @@ var (
 		Destination: &adminAPIFlagValue,
 	}
 )
+
+var (
+	adminTokenFlagValue string
+	adminTokenFlag      = &cli.StringFlag{
+		Name:        "admin-token",
+		Usage:       "Bearer token with which to authenticate to the admin HTTP API",
+		EnvVars:     []string{"PROVIDER_ADMIN_TOKEN"},
+		Destination: &adminTokenFlagValue,
+	}
+	adminCACertFlagValue string
+	adminCACertFlag      = &cli.PathFlag{
+		Name:        "admin-ca-cert",
+		Usage:       "Path to the PEM encoded CA certificates with which to verify the admin HTTP API certificate",
+		EnvVars:     []string{"PROVIDER_ADMIN_CA_CERT"},
+		Destination: &adminCACertFlagValue,
+	}
+	adminClientCertFlagValue string
+	adminClientCertFlag      = &cli.PathFlag{
+		Name:        "admin-client-cert",
+		Usage:       "Path to the PEM encoded client certificate to present to the admin HTTP API",
+		EnvVars:     []string{"PROVIDER_ADMIN_CLIENT_CERT"},
+		Destination: &adminClientCertFlagValue,
+	}
+	adminClientKeyFlagValue string
+	adminClientKeyFlag      = &cli.PathFlag{
+		Name:        "admin-client-key",
+		Usage:       "Path to the PEM encoded key of the client certificate to present to the admin HTTP API",
+		EnvVars:     []string{"PROVIDER_ADMIN_CLIENT_KEY"},
+		Destination: &adminClientKeyFlagValue,
+	}
+)
+
+var (
+	providerAddrInfoFlagValue string
+	providerAddrInfoFlag      = &cli.StringFlag{
+		Name:        "provider-addr-info",
+		Usage:       `Provider publisher address, either as multiaddr string including peer ID, example: "/ip4/127.0.0.1/tcp/3104/http/p2p/12D3KooW...", or as HTTP URL`,
+		Aliases:     []string{"p"},
+		Required:    true,
+		Destination: &providerAddrInfoFlagValue,
+	}
+)
+
+var (
+	publisherTokenFlagValue string
+	publisherTokenFlag      = &cli.StringFlag{
+		Name:        "publisher-token",
+		Usage:       "Bearer token with which to authenticate to provider publishers served over HTTP",
+		EnvVars:     []string{"PROVIDER_PUBLISHER_TOKEN"},
+		Destination: &publisherTokenFlagValue,
+	}
+	publisherHeaderFlagValue = cli.NewStringSlice()
+	publisherHeaderFlag      = &cli.StringSliceFlag{
+		Name:        "publisher-header",
+		Usage:       `Header to send to provider publishers served over HTTP, as "Key: Value". May be repeated.`,
+		Destination: publisherHeaderFlagValue,
+	}
+	publisherCACertFlagValue string
+	publisherCACertFlag      = &cli.PathFlag{
+		Name:        "publisher-ca-cert",
+		Usage:       "Path to the PEM encoded CA certificates with which to verify the certificate of provider publishers served over HTTPS",
+		EnvVars:     []string{"PROVIDER_PUBLISHER_CA_CERT"},
+		Destination: &publisherCACertFlagValue,
+	}
+	publisherClientCertFlagValue string
+	publisherClientCertFlag      = &cli.PathFlag{
+		Name:        "publisher-client-cert",
+		Usage:       "Path to the PEM encoded client certificate to present to provider publishers served over HTTPS",
+		EnvVars:     []string{"PROVIDER_PUBLISHER_CLIENT_CERT"},
+		Destination: &publisherClientCertFlagValue,
+	}
+	publisherClientKeyFlagValue string
+	publisherClientKeyFlag      = &cli.PathFlag{
+		Name:        "publisher-client-key",
+		Usage:       "Path to the PEM encoded key of the client certificate to present to provider publishers served over HTTPS",
+		EnvVars:     []string{"PROVIDER_PUBLISHER_CLIENT_KEY"},
+		Destination: &publisherClientKeyFlagValue,
+	}
+)
+
+var (
+	dialTimeoutFlagValue time.Duration
+	dialTimeoutFlag      = &cli.DurationFlag{
+		Name:        "dial-timeout",
+		Usage:       "The timeout for connecting to provider publishers. Zero uses the default of the transport.",
+		EnvVars:     []string{"PROVIDER_DIAL_TIMEOUT"},
+		Destination: &dialTimeoutFlagValue,
+	}
+	requestTimeoutFlagValue time.Duration
+	requestTimeoutFlag      = &cli.DurationFlag{
+		Name:        "request-timeout",
+		Usage:       "The deadline for each request to provider publishers, e.g. to fetch a block. Zero means no deadline.",
+		EnvVars:     []string{"PROVIDER_REQUEST_TIMEOUT"},
+		Destination: &requestTimeoutFlagValue,
+	}
+	reuseSessionFlagValue bool
+	reuseSessionFlag      = &cli.BoolFlag{
+		Name: "reuse-session",
+		Usage: "Whether the clients of provider publishers created within the process share one libp2p host, " +
+			"so that connections are reused, and one temporary datastore, so that blocks are fetched at most once.",
+		EnvVars:     []string{"PROVIDER_REUSE_SESSION"},
+		Destination: &reuseSessionFlagValue,
+	}
+)
+
+var (
+	adCidFlagValue string
+	adCidFlag      = &cli.StringFlag{
+		Name:        "ad-cid",
+		Usage:       "The CID of the advertisement to start from. Defaults to the latest advertisement.",
+		Destination: &adCidFlagValue,
+	}
+)
+
+var optionalProviderAddrInfoFlag = &cli.StringFlag{
+	Name:        "provider-addr-info",
+	Usage:       `Provider publisher address, either as multiaddr string including peer ID, example: "/ip4/127.0.0.1/tcp/3104/http/p2p/12D3KooW...", or as HTTP URL`,
+	Aliases:     []string{"p"},
+	Destination: &providerAddrInfoFlagValue,
+}
+
+// flagMetadata returns the metadata specified by the metadata, bitswap and
+// http-gateway flags, decoded with the given metadata context. The metadata is
+// empty if none of the flags is set.
+func flagMetadata(cctx *cli.Context, mc metadata.MetadataContext) (metadata.Metadata, error) {
+	md := mc.New()
+	if cctx.IsSet(metadataFlag.Name) {
+		decoded, err := base64.StdEncoding.DecodeString(metadataFlagValue)
+		if err != nil {
+			return md, errors.New("metadata is not a valid base64 encoded string")
+		}
+		if err = md.UnmarshalBinary(decoded); err != nil {
+			return md, err
+		}
+	}
+	var added []metadata.Protocol
+	if cctx.Bool(bitswapFlag.Name) && md.Get(multicodec.TransportBitswap) == nil {
+		added = append(added, metadata.Bitswap{})
+	}
+	if cctx.Bool(httpGatewayFlag.Name) && md.Get(multicodec.TransportIpfsGatewayHttp) == nil {
+		added = append(added, metadata.IpfsGatewayHttp{})
+	}
+	if len(added) == 0 {
+		return md, nil
+	}
+	for _, id := range md.Protocols() {
+		added = append(added, md.Get(id))
+	}
+	return mc.New(added...), nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+var GossipCmd = &cli.Command{
+	Name:  "gossip",
+	Usage: "Shows whether announcements over gossip pubsub reach anyone",
+	Description: `Fetches diagnostics of announcing over gossip pubsub from the admin server of a running provider:
+the peers subscribed to the announce topic, to which announcements are published, the peers in the
+gossipsub mesh of the topic, if the provider joins the mesh, whether each indexer peer listed in
+DirectAnnounce.IndexerPeers is connected, subscribed and in the mesh, and when an announcement was
+last published while any peer was subscribed.`,
+	Action: doGossip,
+	Flags: []cli.Flag{
+		adminAPIFlag,
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Usage:   "The output format, one of: table, json.",
+			Value:   outputTable,
+		},
+	},
+}
+
+func doGossip(cctx *cli.Context) error {
+	output := cctx.String("output")
+	if output != outputTable && output != outputJson {
+		return fmt.Errorf("unknown output format %q; must be one of %s or %s", output, outputTable, outputJson)
+	}
+
+	client, err := newAdminClient()
+	if err != nil {
+		return err
+	}
+	res, err := client.GetGossip(cctx.Context)
+	if err != nil {
+		return err
+	}
+
+	if output == outputJson {
+		enc := json.NewEncoder(cctx.App.Writer)
+		enc.SetIndent("", "  ")
+		return enc.Encode(&res)
+	}
+
+	if !res.Enabled {
+		fmt.Fprintf(cctx.App.Writer, "Announcements over gossip pubsub are disabled.\n")
+		return nil
+	}
+	mesh := "unknown; set DirectAnnounce.GossipMesh to join the mesh"
+	if res.MeshKnown {
+		mesh = fmt.Sprint(len(res.MeshPeers))
+	}
+	lastPropagated := "never"
+	if res.LastPropagated != nil {
+		lastPropagated = fmt.Sprintf("%s (%s to %d peers)", res.LastPropagated.Format(time.RFC3339), res.LastPropagatedAd, res.LastPropagatedPeers)
+	}
+	tw := tabwriter.NewWriter(cctx.App.Writer, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Topic:\t%s\n", res.Topic)
+	fmt.Fprintf(tw, "Topic peers:\t%d\n", len(res.TopicPeers))
+	fmt.Fprintf(tw, "Mesh peers:\t%s\n", mesh)
+	fmt.Fprintf(tw, "Last propagated:\t%s\n", lastPropagated)
+	if err = tw.Flush(); err != nil {
+		return err
+	}
+	if len(res.Indexers) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(cctx.App.Writer)
+	tw = tabwriter.NewWriter(cctx.App.Writer, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "INDEXER\tCONNECTED\tIN TOPIC\tIN MESH\n")
+	for _, indexer := range res.Indexers {
+		fmt.Fprintf(tw, "%s\t%t\t%t\t%t\n", indexer.ID, indexer.Connected, indexer.InTopic, indexer.InMesh)
+	}
+	return tw.Flush()
+}
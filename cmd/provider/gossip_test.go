@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ipni/go-libipni/test"
+	adminserver "github.com/ipni/index-provider/server/admin/http"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestGossipCmd(t *testing.T) {
+	adCid := test.RandomCids(1)[0]
+	indexer, _, _ := test.RandomIdentity()
+	other, _, _ := test.RandomIdentity()
+	lastPropagated := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/admin/gossip", r.URL.Path)
+		res := &adminserver.GossipRes{
+			Topic:      "/indexer/ingest/mainnet",
+			Enabled:    true,
+			TopicPeers: []peer.ID{indexer, other},
+			Indexers: []adminserver.GossipIndexer{
+				{ID: indexer, Connected: true, InTopic: true},
+			},
+			LastPropagated:      &lastPropagated,
+			LastPropagatedAd:    &adCid,
+			LastPropagatedPeers: 2,
+		}
+		_, err := res.WriteTo(w)
+		require.NoError(t, err)
+	}))
+	defer server.Close()
+
+	run := func(args ...string) string {
+		var out bytes.Buffer
+		app := &cli.App{
+			Writer:   &out,
+			Commands: []*cli.Command{GossipCmd},
+		}
+		require.NoError(t, app.Run(append([]string{"provider", "gossip", "-l", server.URL}, args...)))
+		return out.String()
+	}
+
+	out := run()
+	require.Regexp(t, `Topic:\s+/indexer/ingest/mainnet\n`, out)
+	require.Regexp(t, `Topic peers:\s+2\n`, out)
+	require.Regexp(t, `Mesh peers:\s+unknown`, out)
+	require.Regexp(t, `Last propagated:\s+2023-05-01T12:00:00Z \(`+adCid.String()+` to 2 peers\)\n`, out)
+	require.Regexp(t, indexer.String()+`\s+true\s+true\s+false\n`, out)
+
+	var res adminserver.GossipRes
+	require.NoError(t, json.Unmarshal([]byte(run("-o", "json")), &res))
+	require.Equal(t, []peer.ID{indexer, other}, res.TopicPeers)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipni/go-libipni/dagsync/ipnisync"
+	headschema "github.com/ipni/go-libipni/dagsync/ipnisync/head"
+	"github.com/ipni/index-provider/cmd/provider/internal"
+	adminserver "github.com/ipni/index-provider/server/admin/http"
+	"github.com/urfave/cli/v2"
+)
+
+var HeadCmd = &cli.Command{
+	Name:      "head",
+	Usage:     "Shows the head of the advertisement chain of a provider",
+	ArgsUsage: "<endpoint>",
+	Description: `Fetches the head of the advertisement chain from the /head endpoint of the given provider, which
+is served by its admin server and by its HTTP publisher when hosted on the admin server, and
+checks that it is signed by the provider. Prints the CID of the head, the ID of the provider
+that signed it, the topic on which it is announced, its height and when it was published.
+
+If the endpoint does not serve /head, the signed head of the IPNI HTTP publisher at
+/ipni/v1/ad/head is fetched instead, in which case the height and publish time are unknown.`,
+	Action: doHead,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Usage:   "The output format, one of: table, json.",
+			Value:   outputTable,
+		},
+		&cli.DurationFlag{
+			Name:  "timeout",
+			Usage: "The maximum time that fetching the head may take.",
+			Value: 30 * time.Second,
+		},
+	},
+}
+
+func doHead(cctx *cli.Context) error {
+	if cctx.NArg() != 1 {
+		return cli.Exit("expected exactly one endpoint", exitCodeUsage)
+	}
+	endpoint, err := url.Parse(cctx.Args().First())
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return cli.Exit(fmt.Sprintf("invalid endpoint %q: must be an HTTP URL", cctx.Args().First()), exitCodeUsage)
+	}
+	output := cctx.String("output")
+	if output != outputTable && output != outputJson {
+		return fmt.Errorf("unknown output format %q; must be one of %s or %s", output, outputTable, outputJson)
+	}
+
+	client := &http.Client{Timeout: cctx.Duration("timeout")}
+	res, err := fetchHead(cctx, client, endpoint)
+	if errors.Is(err, internal.ErrNotFound) {
+		res, err = fetchSignedHead(cctx, client, endpoint)
+	}
+	if err != nil {
+		return err
+	}
+	signerID, err := headschema.SignedHead{
+		Head:   cidlink.Link{Cid: res.Head},
+		Topic:  &res.Topic,
+		Pubkey: res.PubKey,
+		Sig:    res.Sig,
+	}.Validate()
+	if err != nil {
+		return fmt.Errorf("head %s: %w: %s", res.Head, errInvalidSignature, err)
+	}
+
+	if output == outputJson {
+		enc := json.NewEncoder(cctx.App.Writer)
+		enc.SetIndent("", "  ")
+		return enc.Encode(res)
+	}
+
+	height := "unknown"
+	if res.Height != 0 {
+		height = fmt.Sprint(res.Height)
+	}
+	published := "unknown"
+	if res.Published != nil {
+		published = res.Published.Format(time.RFC3339)
+	}
+	tw := tabwriter.NewWriter(cctx.App.Writer, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Head:\t%s\n", res.Head)
+	fmt.Fprintf(tw, "Provider:\t%s\n", signerID)
+	fmt.Fprintf(tw, "Topic:\t%s\n", res.Topic)
+	fmt.Fprintf(tw, "Height:\t%s\n", height)
+	fmt.Fprintf(tw, "Published:\t%s\n", published)
+	return tw.Flush()
+}
+
+// fetchHead fetches the head of the advertisement chain from the /head
+// endpoint of a provider.
+func fetchHead(cctx *cli.Context, client *http.Client, endpoint *url.URL) (*adminserver.HeadRes, error) {
+	var res adminserver.HeadRes
+	err := getHead(cctx, client, endpoint.JoinPath("head"), func(r io.Reader) error {
+		_, err := res.ReadFrom(r)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// fetchSignedHead fetches the signed head of the advertisement chain from the
+// IPNI HTTP publisher of a provider, whose height and publish time are unknown.
+func fetchSignedHead(cctx *cli.Context, client *http.Client, endpoint *url.URL) (*adminserver.HeadRes, error) {
+	var res adminserver.HeadRes
+	err := getHead(cctx, client, endpoint.JoinPath(ipnisync.IPNIPath, "head"), func(r io.Reader) error {
+		signed, err := headschema.Decode(r)
+		if err != nil {
+			return fmt.Errorf("failed to decode signed head: %w", err)
+		}
+		link, ok := signed.Head.(cidlink.Link)
+		if !ok || signed.Topic == nil {
+			return errors.New("signed head has no CID or topic")
+		}
+		res.Head = link.Cid
+		res.Topic = *signed.Topic
+		res.PubKey = signed.Pubkey
+		res.Sig = signed.Sig
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.Head == cid.Undef {
+		return nil, internal.ErrNoHead
+	}
+	return &res, nil
+}
+
+// getHead gets the given head URL and handles the response body if found.
+func getHead(cctx *cli.Context, client *http.Client, u *url.URL, handle func(io.Reader) error) error {
+	req, err := http.NewRequestWithContext(cctx.Context, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return handle(resp.Body)
+	case http.StatusNoContent:
+		return internal.ErrNoHead
+	case http.StatusNotFound:
+		return fmt.Errorf("failed to get %s: %w", u, internal.ErrNotFound)
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to get %s: %d %s", u, resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	headschema "github.com/ipni/go-libipni/dagsync/ipnisync/head"
+	"github.com/ipni/go-libipni/test"
+	adminserver "github.com/ipni/index-provider/server/admin/http"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestHeadCmd(t *testing.T) {
+	priv, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	providerID, err := peer.IDFromPrivateKey(priv)
+	require.NoError(t, err)
+	head := test.RandomCids(1)[0]
+	signed, err := headschema.NewSignedHead(head, "/indexer/ingest/mainnet", priv)
+	require.NoError(t, err)
+	published := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	var servesHead bool
+	var sig []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/head" && servesHead:
+			res := &adminserver.HeadRes{
+				Head:      head,
+				Topic:     *signed.Topic,
+				PubKey:    signed.Pubkey,
+				Sig:       sig,
+				Height:    42,
+				Published: &published,
+			}
+			_, err := res.WriteTo(w)
+			require.NoError(t, err)
+		case r.URL.Path == "/ipni/v1/ad/head":
+			data, err := signed.Encode()
+			require.NoError(t, err)
+			_, _ = w.Write(data)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	run := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		app := &cli.App{
+			Writer:   &out,
+			Commands: []*cli.Command{HeadCmd},
+		}
+		err := app.Run(append([]string{"provider", "head"}, append(args, server.URL)...))
+		return out.String(), err
+	}
+
+	servesHead, sig = true, signed.Sig
+	out, err := run()
+	require.NoError(t, err)
+	require.Regexp(t, `Head:\s+`+head.String()+`\n`, out)
+	require.Regexp(t, `Provider:\s+`+providerID.String()+`\n`, out)
+	require.Regexp(t, `Height:\s+42\n`, out)
+	require.Regexp(t, `Published:\s+2023-05-01T12:00:00Z\n`, out)
+
+	out, err = run("-o", "json")
+	require.NoError(t, err)
+	var res adminserver.HeadRes
+	require.NoError(t, json.Unmarshal([]byte(out), &res))
+	require.Equal(t, head, res.Head)
+	require.Equal(t, uint64(42), res.Height)
+
+	// A head whose signature does not match is rejected.
+	sig = append([]byte{}, signed.Sig...)
+	sig[0] ^= 0xff
+	_, err = run()
+	require.ErrorIs(t, err, errInvalidSignature)
+
+	// Falls back on the signed head of the IPNI HTTP publisher.
+	servesHead = false
+	out, err = run()
+	require.NoError(t, err)
+	require.Regexp(t, `Head:\s+`+head.String()+`\n`, out)
+	require.Regexp(t, `Provider:\s+`+providerID.String()+`\n`, out)
+	require.Regexp(t, `Height:\s+unknown\n`, out)
+}
@@ -1,44 +1,59 @@
 package main
 
 import (
-	"bytes"
-	"context"
-	"encoding/json"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
-	"io"
-	"net/http"
+	"os"
+
+	"github.com/ipni/index-provider/cmd/provider/internal/adminclient"
 )
 
-// doHttpPostReq marshals the req to JSON and sends a POST request with content type
-// application/json to the given path.
+// newAdminClient instantiates a client of the admin server at the address
+// specified by the admin API flag, authenticating with the bearer token and
+// client certificate specified by the global admin flags.
 //
 // This function is intended for internal use in CLI to interact with the admin server.
-func doHttpPostReq(ctx context.Context, path string, req interface{}) (resp *http.Response, err error) {
-	reqBody, err := json.Marshal(req)
-	if err != nil {
-		return nil, err
+func newAdminClient() (*adminclient.Client, error) {
+	var opts []adminclient.Option
+	if adminTokenFlagValue != "" {
+		opts = append(opts, adminclient.WithBearerToken(adminTokenFlagValue))
+	}
+	if adminCACertFlagValue != "" || adminClientCertFlagValue != "" || adminClientKeyFlagValue != "" {
+		tlsConfig, err := loadTLSConfig("admin", adminCACertFlagValue, adminClientCertFlagValue, adminClientKeyFlagValue)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, adminclient.WithTLSConfig(tlsConfig))
 	}
-	bodyReader := bytes.NewReader(reqBody)
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, path, bodyReader)
+	client, err := adminclient.New(adminAPIFlagValue, opts...)
 	if err != nil {
-		return nil, err
+		return nil, withErrorClass(errClassUsage, err)
 	}
-	httpReq.Header.Set("Content-Type", "application/json")
-	cl := &http.Client{}
-	return cl.Do(httpReq)
+	return client, nil
 }
 
-// errFromHttpResp constructs an error from a HTTP response.
-// The error message consists of the textual value of HTTP status, followed by a whitespace,
-// followed and the fully read response body.
-//
-// This function is intended to generate consistent textual output in CLI for a response received
-// from the admin server.
-func errFromHttpResp(resp *http.Response) error {
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed  to read response: %w", err)
+// loadTLSConfig loads the TLS configuration with the given CA certificates and
+// client certificate, if any, of the server described by the given name.
+func loadTLSConfig(name, caCertPath, clientCertPath, clientKeyPath string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caCertPath != "" {
+		caPEM, err := os.ReadFile(caCertPath)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read %s CA certificates: %w", name, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in %s", caCertPath)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if clientCertPath != "" || clientKeyPath != "" {
+		cert, err := tls.LoadX509KeyPair(clientCertPath, clientKeyPath)
+		if err != nil {
+			return nil, withErrorClass(errClassUsage, fmt.Errorf("cannot load %s client certificate: %w", name, err))
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
 	}
-	statusText := http.StatusText(resp.StatusCode)
-	return fmt.Errorf("%s: %s", statusText, respBody)
+	return tlsConfig, nil
 }
@@ -2,45 +2,47 @@ package main
 
 import (
 	"context"
-	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/ipni/index-provider/cmd/provider/internal/adminclient"
 	"github.com/stretchr/testify/require"
 )
 
-func Test_doHttpPostReq_PostsExpectedRequest(t *testing.T) {
-	wantBody := `{"This":"fish","That":1413,"AndTheOther":true}`
-	req := struct {
-		This        string
-		That        int
-		AndTheOther bool
-	}{
-		"fish", 1413, true,
-	}
-	handler := http.HandlerFunc(func(_ http.ResponseWriter, httpReq *http.Request) {
-		require.Equal(t, "application/json", httpReq.Header["Content-Type"][0])
-		reqBody, err := io.ReadAll(httpReq.Body)
-		require.NoError(t, err)
-		require.Equal(t, wantBody, string(reqBody))
-	})
-	server := httptest.NewServer(handler)
+func Test_newAdminClient_SendsBearerToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer fish" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte("{}"))
+	}))
 	defer server.Close()
-	_, err := doHttpPostReq(context.Background(), server.URL, req)
+
+	defer func(api, token string) {
+		adminAPIFlagValue, adminTokenFlagValue = api, token
+	}(adminAPIFlagValue, adminTokenFlagValue)
+	adminAPIFlagValue = server.URL
+
+	client, err := newAdminClient()
 	require.NoError(t, err)
-}
+	err = client.Connect(context.Background(), "/ip4/127.0.0.1/tcp/1")
+	var apiErr *adminclient.Error
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
 
-func Test_doHttpPostReq_FailsToPostWhenServerIsNotAvailable(t *testing.T) {
-	_, err := doHttpPostReq(context.Background(), "http://localhost:47891/saw-me-nothin-boss", nil)
-	require.Error(t, err)
+	adminTokenFlagValue = "fish"
+	client, err = newAdminClient()
+	require.NoError(t, err)
+	require.NoError(t, client.Connect(context.Background(), "/ip4/127.0.0.1/tcp/1"))
 }
 
-func Test_errFromHttpResp(t *testing.T) {
-	r := httptest.NewRecorder()
-	_, err := r.WriteString("fish")
-	r.Code = http.StatusBadRequest
-	r.Flush()
-	require.NoError(t, err)
-	require.Equal(t, "Bad Request: fish", errFromHttpResp(r.Result()).Error())
+func Test_newAdminClient_FailsOnInvalidAddress(t *testing.T) {
+	defer func(api string) { adminAPIFlagValue = api }(adminAPIFlagValue)
+	adminAPIFlagValue = "://saw-me-nothin-boss"
+
+	_, err := newAdminClient()
+	require.Error(t, err)
+	require.Equal(t, errClassUsage, classifyError(err))
 }
@@ -5,13 +5,11 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"errors"
-	"fmt"
-	"net/http"
 	"path/filepath"
 
 	"github.com/ipni/go-libipni/metadata"
-	"github.com/ipni/index-provider/cardatatransfer"
 	adminserver "github.com/ipni/index-provider/server/admin/http"
+	"github.com/ipni/index-provider/supplier"
 	"github.com/urfave/cli/v2"
 )
 
@@ -28,9 +26,24 @@ var (
 		Name:    "car",
 		Aliases: []string{"c"},
 		Usage:   "Imports CAR from a path",
-		Flags:   importCarFlags,
-		Before:  beforeImportCar,
-		Action:  doImportCar,
+		Description: `Imports a CAR file, or all the CAR files in a directory, and advertises the multihashes they
+contain. The context ID of each CAR file is the key option if set, or the SHA-256 hash of the
+absolute path to the CAR file otherwise. The key option cannot be set when importing a directory.
+Without the metadata option, CAR files are advertised with the default retrieval metadata of the
+provider. The bitswap option advertises CAR files as retrievable over bitswap, e.g. from an IPFS
+node that serves their blocks, and the http-gateway option as retrievable from the IPFS trustless
+HTTP gateway among the provider addresses, in addition to the transports in the metadata option, if
+set.
+
+With the workers option, that many CAR files in a directory are imported concurrently, such that the
+provider indexes them in parallel. A CAR file that fails to import does not stop the others.
+
+With the watch option, the directory keeps being watched once all the CAR files in it are imported.
+CAR files added to the directory are then imported, and the removal of CAR files that are deleted
+from, or moved out of, the directory is advertised. Watching continues until interrupted.`,
+		Flags:  importCarFlags,
+		Before: beforeImportCar,
+		Action: doImportCar,
 	}
 	md = metadata.Default.New()
 )
@@ -39,10 +52,29 @@ var importCarFlags = []cli.Flag{
 	adminAPIFlag,
 	carPathFlag,
 	metadataFlag,
+	bitswapFlag,
+	httpGatewayFlag,
 	keyFlag,
+	&cli.IntFlag{
+		Name:  "workers",
+		Usage: "Number of CAR files in a directory to import concurrently. The provider indexes them in parallel, and advertises each as soon as it is indexed.",
+		Value: 1,
+	},
+	&cli.BoolFlag{
+		Name:    "watch",
+		Aliases: []string{"w"},
+		Usage:   "Keep watching the directory, importing added and removing deleted CAR files.",
+	},
 }
 
 func beforeImportCar(cctx *cli.Context) error {
+	if isDir(carPathFlagValue) {
+		return beforeImportCarDir(cctx)
+	}
+	if cctx.Bool("watch") {
+		return errors.New("watch can only be set when importing a directory")
+	}
+
 	if cctx.IsSet(keyFlag.Name) {
 		decoded, err := base64.StdEncoding.DecodeString(keyFlagValue)
 		if err != nil {
@@ -50,67 +82,49 @@ func beforeImportCar(cctx *cli.Context) error {
 		}
 		importCarKey = decoded
 	} else {
-		absCarPath, err := filepath.Abs(carPathFlagValue)
+		carPath, err := importCarPath()
 		if err != nil {
 			return err
 		}
 		h := sha256.New()
-		h.Write([]byte(absCarPath))
+		h.Write([]byte(carPath))
 		importCarKey = h.Sum(nil)
 	}
-	if cctx.IsSet(metadataFlag.Name) {
-		decoded, err := base64.StdEncoding.DecodeString(metadataFlagValue)
-		if err != nil {
-			return errors.New("metadata is not a valid base64 encoded string")
-		}
-		err = md.UnmarshalBinary(decoded)
-		if err != nil {
-			return err
-		}
-	} else {
-		// If no metadata is set, generate metadata that is compatible for FileCoin retrieval base
-		// on the context ID
-		var err error
-		tp, err := cardatatransfer.TransportFromContextID(importCarKey)
-		if err != nil {
-			return err
-		}
-		md = metadata.Default.New(tp)
-	}
-	return nil
+	// If no metadata is set, the provider uses its default metadata.
+	var err error
+	md, err = flagMetadata(cctx, metadata.Default)
+	return err
 }
 
 func doImportCar(cctx *cli.Context) error {
+	if isDir(carPathFlagValue) {
+		return doImportCarDir(cctx)
+	}
+
 	mdBytes, err := md.MarshalBinary()
 	if err != nil {
 		return err
 	}
 
-	absCarPath, err := filepath.Abs(carPathFlagValue)
+	carPath, err := importCarPath()
 	if err != nil {
 		return err
 	}
 
-	req := adminserver.ImportCarReq{
-		Path:     absCarPath,
+	client, err := newAdminClient()
+	if err != nil {
+		return err
+	}
+	res, err := client.ImportCar(cctx.Context, &adminserver.ImportCarReq{
+		Path:     carPath,
 		Key:      importCarKey,
 		Metadata: mdBytes,
-	}
-	resp, err := doHttpPostReq(cctx.Context, adminAPIFlagValue+"/admin/import/car", req)
+	})
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return errFromHttpResp(resp)
-	}
 
 	log.Infof("imported car successfully")
-	var res adminserver.ImportCarRes
-	if _, err := res.ReadFrom(resp.Body); err != nil {
-		return fmt.Errorf("received ok response from server but cannot decode response body. %v", err)
-	}
 	var b bytes.Buffer
 	b.WriteString("Successfully imported CAR.\n")
 	b.WriteString("\t Advertisement ID: ")
@@ -121,3 +135,12 @@ func doImportCar(cctx *cli.Context) error {
 	_, err = cctx.App.Writer.Write(b.Bytes())
 	return err
 }
+
+// importCarPath returns the absolute path of the CAR file to import, or its URL
+// if it is remote.
+func importCarPath() (string, error) {
+	if supplier.IsRemoteCar(carPathFlagValue) {
+		return carPathFlagValue, nil
+	}
+	return filepath.Abs(carPathFlagValue)
+}
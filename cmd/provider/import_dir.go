@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/index-provider/cmd/provider/internal/adminclient"
+	adminserver "github.com/ipni/index-provider/server/admin/http"
+	"github.com/urfave/cli/v2"
+)
+
+// carDirSettleDelay is how long a CAR file added to a watched directory must
+// go unmodified before it is imported, so that CAR files that are still being
+// written are not imported.
+var carDirSettleDelay = 2 * time.Second
+
+// carDirImporter imports the CAR files in a directory via the admin server.
+type carDirImporter struct {
+	cctx   *cli.Context
+	client *adminclient.Client
+}
+
+func isDir(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
+
+func isCarFile(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".car")
+}
+
+func beforeImportCarDir(cctx *cli.Context) error {
+	if cctx.IsSet(keyFlag.Name) {
+		return errors.New("key cannot be set when importing a directory")
+	}
+	var err error
+	md, err = flagMetadata(cctx, metadata.Default)
+	return err
+}
+
+func doImportCarDir(cctx *cli.Context) error {
+	dir, err := filepath.Abs(carPathFlagValue)
+	if err != nil {
+		return err
+	}
+	client, err := newAdminClient()
+	if err != nil {
+		return err
+	}
+	imp := &carDirImporter{
+		cctx:   cctx,
+		client: client,
+	}
+
+	// Start watching before listing the directory so that no CAR files added
+	// in between are missed.
+	var watcher *fsnotify.Watcher
+	if cctx.Bool("watch") {
+		if watcher, err = fsnotify.NewWatcher(); err != nil {
+			return fmt.Errorf("cannot watch directory: %w", err)
+		}
+		defer watcher.Close()
+		if err = watcher.Add(dir); err != nil {
+			return fmt.Errorf("cannot watch directory: %w", err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var paths []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && isCarFile(entry.Name()) {
+			paths = append(paths, filepath.Join(dir, entry.Name()))
+		}
+	}
+	imported, failed := imp.importCars(paths, cctx.Int("workers"))
+	fmt.Fprintf(cctx.App.Writer, "Imported %d CAR files from %s.\n", imported, dir)
+
+	if watcher == nil {
+		if failed != 0 {
+			return fmt.Errorf("failed to import %d CAR files", failed)
+		}
+		return nil
+	}
+	fmt.Fprintf(cctx.App.Writer, "Watching %s for changes...\n", dir)
+	return imp.watch(cctx.Context, watcher)
+}
+
+// importCars imports the CAR files at the given paths, up to workers of them
+// concurrently, and returns the numbers of CAR files imported and failed.
+func (imp *carDirImporter) importCars(paths []string, workers int) (imported, failed int) {
+	if workers < 1 {
+		workers = 1
+	}
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	pathsChan := make(chan string)
+	for i := 0; i < workers && i < len(paths); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range pathsChan {
+				err := imp.importCar(path)
+				mutex.Lock()
+				if err != nil {
+					fmt.Fprintln(imp.cctx.App.ErrWriter, err)
+					failed++
+				} else {
+					imported++
+				}
+				fmt.Fprintf(imp.cctx.App.Writer, "Progress: %d of %d CAR files processed.\n", imported+failed, len(paths))
+				mutex.Unlock()
+			}
+		}()
+	}
+	for _, path := range paths {
+		pathsChan <- path
+	}
+	close(pathsChan)
+	wg.Wait()
+	return imported, failed
+}
+
+// watch imports CAR files added to, and removes CAR files deleted from, the
+// watched directory until the context is canceled.
+func (imp *carDirImporter) watch(ctx context.Context, watcher *fsnotify.Watcher) error {
+	// Paths of added CAR files, and when they were last modified.
+	pending := make(map[string]time.Time)
+	ticker := time.NewTicker(carDirSettleDelay / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !isCarFile(event.Name) {
+				continue
+			}
+			switch {
+			case event.Has(fsnotify.Remove), event.Has(fsnotify.Rename):
+				delete(pending, event.Name)
+				if err := imp.removeCar(event.Name); err != nil {
+					fmt.Fprintln(imp.cctx.App.ErrWriter, err)
+				}
+			case event.Has(fsnotify.Create), event.Has(fsnotify.Write):
+				pending[event.Name] = time.Now()
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Errorw("Error watching directory", "err", err)
+		case now := <-ticker.C:
+			for path, modified := range pending {
+				if now.Sub(modified) < carDirSettleDelay {
+					continue
+				}
+				delete(pending, path)
+				if fi, err := os.Stat(path); err != nil || !fi.Mode().IsRegular() {
+					continue
+				}
+				if err := imp.importCar(path); err != nil {
+					fmt.Fprintln(imp.cctx.App.ErrWriter, err)
+				}
+			}
+		}
+	}
+}
+
+// carContextID returns the context ID of a CAR file imported from a directory,
+// i.e. the SHA-256 hash of its absolute path.
+func carContextID(absPath string) []byte {
+	h := sha256.Sum256([]byte(absPath))
+	return h[:]
+}
+
+// importCar imports the CAR file at the given absolute path. CAR files that
+// are already advertised are skipped.
+func (imp *carDirImporter) importCar(path string) error {
+	key := carContextID(path)
+	// If no metadata is set, the provider uses its default metadata.
+	mdBytes, err := md.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	res, err := imp.client.ImportCar(imp.cctx.Context, &adminserver.ImportCarReq{
+		Path:     path,
+		Key:      key,
+		Metadata: mdBytes,
+	})
+	if errors.Is(err, adminclient.ErrConflict) {
+		fmt.Fprintf(imp.cctx.App.Writer, "Already advertised %s.\n", path)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to import %s: %w", path, err)
+	}
+	fmt.Fprintf(imp.cctx.App.Writer, "Imported %s.\n\t Advertisement ID: %s\n\t Context ID: %s\n",
+		path, res.AdvId, base64.StdEncoding.EncodeToString(key))
+	return nil
+}
+
+// removeCar removes the CAR file at the given absolute path. CAR files that
+// are not known to the provider are ignored.
+func (imp *carDirImporter) removeCar(path string) error {
+	adCid, err := imp.client.RemoveCar(imp.cctx.Context, carContextID(path))
+	if errors.Is(err, adminclient.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to remove %s: %w", path, err)
+	}
+	fmt.Fprintf(imp.cctx.App.Writer, "Removed %s.\n\t Advertisement ID: %s\n", path, adCid)
+	return nil
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	"github.com/ipni/index-provider/cardatatransfer"
+	adminserver "github.com/ipni/index-provider/server/admin/http"
+	"github.com/multiformats/go-multicodec"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+// syncBuffer is a bytes.Buffer that is safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestImportCarCmd_WatchesDirectory(t *testing.T) {
+	carDirSettleDelay = 50 * time.Millisecond
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing.car")
+	require.NoError(t, os.WriteFile(existing, []byte("fish"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ignored.txt"), []byte("fish"), 0o644))
+
+	var mu sync.Mutex
+	imported := make(map[string][]byte)
+	removed := make(map[string]bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/admin/import/car":
+			var req adminserver.ImportCarReq
+			_, err := req.ReadFrom(r.Body)
+			require.NoError(t, err)
+			imported[req.Path] = req.Key
+			_, err = (&adminserver.ImportCarRes{Key: req.Key, AdvId: test.RandomCids(1)[0]}).WriteTo(w)
+			require.NoError(t, err)
+		case "/admin/remove/car":
+			var req adminserver.RemoveCarReq
+			_, err := req.ReadFrom(r.Body)
+			require.NoError(t, err)
+			for path, key := range imported {
+				if bytes.Equal(key, req.Key) {
+					removed[path] = true
+					_, err = (&adminserver.RemoveCarRes{AdvId: test.RandomCids(1)[0]}).WriteTo(w)
+					require.NoError(t, err)
+					return
+				}
+			}
+			http.Error(w, "not found", http.StatusNotFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	out := &syncBuffer{}
+	app := &cli.App{
+		Writer:    out,
+		ErrWriter: out,
+		Commands:  []*cli.Command{ImportCmd},
+	}
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- app.RunContext(ctx, []string{"provider", "import", "car", "-l", server.URL, "-i", dir, "--watch"})
+	}()
+
+	hasImported := func(path string) func() bool {
+		return func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			_, ok := imported[path]
+			return ok
+		}
+	}
+	require.Eventually(t, hasImported(existing), 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool { return strings.Contains(out.String(), "Watching") }, 5*time.Second, 10*time.Millisecond)
+
+	added := filepath.Join(dir, "added.car")
+	require.NoError(t, os.WriteFile(added, []byte("lobster"), 0o644))
+	require.Eventually(t, hasImported(added), 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, os.Remove(existing))
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return removed[existing]
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-errChan)
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, imported, 2)
+	require.Equal(t, carContextID(added), imported[added])
+}
+
+func TestImportCarCmd_Workers(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"fish.car", "lobster.car", "crab.car", "squid.car", "broken.car"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644))
+	}
+
+	var mu sync.Mutex
+	var inFlight, maxInFlight int
+	imported := make(map[string]bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req adminserver.ImportCarReq
+		_, err := req.ReadFrom(r.Body)
+		require.NoError(t, err)
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+		// Give other workers time to send their requests.
+		time.Sleep(50 * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		inFlight--
+		if filepath.Base(req.Path) == "broken.car" {
+			http.Error(w, "not a CAR", http.StatusInternalServerError)
+			return
+		}
+		imported[req.Path] = true
+		_, err = (&adminserver.ImportCarRes{Key: req.Key, AdvId: test.RandomCids(1)[0]}).WriteTo(w)
+		require.NoError(t, err)
+	}))
+	defer server.Close()
+
+	out := &syncBuffer{}
+	app := &cli.App{
+		Writer:    out,
+		ErrWriter: out,
+		Commands:  []*cli.Command{ImportCmd},
+	}
+	err := app.Run([]string{"provider", "import", "car", "-l", server.URL, "-i", dir, "--workers", "2"})
+	require.ErrorContains(t, err, "failed to import 1 CAR files")
+	require.Len(t, imported, 4)
+	require.Equal(t, 2, maxInFlight)
+	require.Contains(t, out.String(), "Progress: 5 of 5 CAR files processed.")
+	require.Contains(t, out.String(), "Imported 4 CAR files")
+}
+
+func TestImportCarCmd_WatchRequiresDirectory(t *testing.T) {
+	carPath := filepath.Join(t.TempDir(), "fish.car")
+	require.NoError(t, os.WriteFile(carPath, []byte("fish"), 0o644))
+	app := &cli.App{
+		Writer:   &bytes.Buffer{},
+		Commands: []*cli.Command{ImportCmd},
+	}
+	err := app.Run([]string{"provider", "import", "car", "-l", "http://localhost:0", "-i", carPath, "--watch"})
+	require.ErrorContains(t, err, "watch can only be set when importing a directory")
+}
+
+func TestImportCarCmd_Transports(t *testing.T) {
+	carPath := filepath.Join(t.TempDir(), "fish.car")
+	require.NoError(t, os.WriteFile(carPath, []byte("fish"), 0o644))
+	var gotMetadata []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req adminserver.ImportCarReq
+		_, err := req.ReadFrom(r.Body)
+		require.NoError(t, err)
+		gotMetadata = req.Metadata
+		_, err = (&adminserver.ImportCarRes{Key: req.Key, AdvId: test.RandomCids(1)[0]}).WriteTo(w)
+		require.NoError(t, err)
+	}))
+	defer server.Close()
+	app := &cli.App{
+		Writer:   &bytes.Buffer{},
+		Commands: []*cli.Command{ImportCmd},
+	}
+	protocols := func() []multicodec.Code {
+		md := metadata.Default.New()
+		require.NoError(t, md.UnmarshalBinary(gotMetadata))
+		return md.Protocols()
+	}
+
+	// Without metadata, the provider uses its default.
+	require.NoError(t, app.Run([]string{"provider", "import", "car", "-l", server.URL, "-i", carPath}))
+	require.Empty(t, gotMetadata)
+
+	require.NoError(t, app.Run([]string{"provider", "import", "car", "-l", server.URL, "-i", carPath, "--bitswap"}))
+	require.Equal(t, []multicodec.Code{multicodec.TransportBitswap}, protocols())
+
+	tp, err := cardatatransfer.TransportFromContextID([]byte("fish"))
+	require.NoError(t, err)
+	graphsyncMd := metadata.Default.New(tp)
+	graphsync, err := graphsyncMd.MarshalBinary()
+	require.NoError(t, err)
+	b64Graphsync := base64.StdEncoding.EncodeToString(graphsync)
+	require.NoError(t, app.Run([]string{"provider", "import", "car", "-l", server.URL, "-i", carPath, "-m", b64Graphsync, "--bitswap"}))
+	require.Equal(t, []multicodec.Code{multicodec.TransportBitswap, multicodec.TransportGraphsyncFilecoinv1}, protocols())
+
+	require.NoError(t, app.Run([]string{"provider", "import", "car", "-l", server.URL, "-i", carPath, "--http-gateway"}))
+	require.Equal(t, []multicodec.Code{multicodec.TransportIpfsGatewayHttp}, protocols())
+}
+
+func TestImportCarCmd_RemoteCar(t *testing.T) {
+	var gotReq adminserver.ImportCarReq
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := gotReq.ReadFrom(r.Body)
+		require.NoError(t, err)
+		_, err = (&adminserver.ImportCarRes{Key: gotReq.Key, AdvId: test.RandomCids(1)[0]}).WriteTo(w)
+		require.NoError(t, err)
+	}))
+	defer server.Close()
+	app := &cli.App{
+		Writer:   &bytes.Buffer{},
+		Commands: []*cli.Command{ImportCmd},
+	}
+
+	// The URL of a remote CAR is imported as is.
+	for _, carURL := range []string{"https://example.com/fish.car", "s3://bucket/fish.car"} {
+		require.NoError(t, app.Run([]string{"provider", "import", "car", "-l", server.URL, "-i", carURL}))
+		require.Equal(t, carURL, gotReq.Path)
+		wantKey := sha256.Sum256([]byte(carURL))
+		require.Equal(t, wantKey[:], gotReq.Key)
+	}
+}
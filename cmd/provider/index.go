@@ -1,7 +1,6 @@
 package main
 
 import (
-	"encoding/base64"
 	"errors"
 	"fmt"
 
@@ -40,6 +39,8 @@ var indexFlags = []cli.Flag{
 		Required: true,
 	},
 	metadataFlag,
+	bitswapFlag,
+	httpGatewayFlag,
 }
 
 func indexCommand(cctx *cli.Context) error {
@@ -83,17 +84,19 @@ func indexCommand(cctx *cli.Context) error {
 		return err
 	}
 
-	decoded, err := base64.StdEncoding.DecodeString(metadataFlagValue)
+	md, err := flagMetadata(cctx, metadata.Default.WithProtocol(multicodec.Http, metadata.HTTPV1))
 	if err != nil {
-		return errors.New("metadata is not a valid base64 encoded string")
+		return err
+	}
+	if md.Len() == 0 {
+		return errors.New("must specify --metadata, --bitswap or --http-gateway")
 	}
-	md = metadata.Default.WithProtocol(multicodec.Http, metadata.HTTPV1).New()
-	err = md.UnmarshalBinary(decoded)
+	mdBytes, err := md.MarshalBinary()
 	if err != nil {
 		return err
 	}
 
-	err = client.IndexContent(cctx.Context, peerID, privKey, mh, []byte(cctx.String("ctxid")), decoded, cctx.StringSlice("addr"))
+	err = client.IndexContent(cctx.Context, peerID, privKey, mh, []byte(cctx.String("ctxid")), mdBytes, cctx.StringSlice("addr"))
 	if err != nil {
 		return err
 	}
@@ -1,18 +1,33 @@
 package main
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
+	"strings"
 
 	"github.com/ipni/index-provider/cmd/provider/internal/config"
 	"github.com/urfave/cli/v2"
 )
 
 var InitCmd = &cli.Command{
-	Name:   "init",
-	Usage:  "Initialize reference provider config file and identity",
+	Name:  "init",
+	Usage: "Initialize reference provider config file and identity",
+	Description: `Generates a new identity and writes the provider configuration. The configuration can be based on
+one of the presets:
+
+   filecoin-sp  Serves advertisements over HTTP and libp2p, and announces them over gossip pubsub
+                and directly to cid.contact.
+   ipfs-node    Serves advertisements over libp2p, and enables the delegated routing server for an
+                IPFS node to provide its CIDs through.
+   http-only    Serves advertisements over plain HTTP, and announces them directly to cid.contact
+                without gossip pubsub.
+
+With --interactive, the values of the most commonly changed settings are prompted for, with the
+values of the preset offered as defaults.`,
 	Flags:  initFlags,
 	Action: initCommand,
 }
@@ -23,6 +38,15 @@ var initFlags = []cli.Flag{
 		Usage: "Set publisher kind in config. Must be one of 'http', 'libp2p', 'libp2phttp'",
 		Value: "libp2p",
 	},
+	&cli.StringFlag{
+		Name:  "preset",
+		Usage: "Generate the config from a preset. Must be one of 'filecoin-sp', 'ipfs-node', 'http-only'",
+	},
+	&cli.BoolFlag{
+		Name:    "interactive",
+		Aliases: []string{"i"},
+		Usage:   "Prompt for config values instead of using defaults",
+	},
 }
 
 func initCommand(cctx *cli.Context) error {
@@ -53,15 +77,182 @@ func initCommand(cctx *cli.Context) error {
 		return err
 	}
 
-	pubkind := config.PublisherKind(cctx.String("pubkind"))
+	if name := cctx.String("preset"); name != "" {
+		preset, err := config.ParsePreset(name)
+		if err != nil {
+			return err
+		}
+		if err = cfg.ApplyPreset(preset); err != nil {
+			return err
+		}
+	}
+	// Only override the publisher kind of a preset if explicitly specified.
+	if cctx.IsSet("pubkind") || cctx.String("preset") == "" {
+		if cfg.Ingest.PublisherKind, err = parsePublisherKind(cctx.String("pubkind")); err != nil {
+			return err
+		}
+	}
+
+	if cctx.Bool("interactive") {
+		p := &prompter{r: bufio.NewReader(cctx.App.Reader), w: cctx.App.Writer}
+		if err = promptConfig(p, cfg, !cctx.IsSet("preset")); err != nil {
+			return err
+		}
+	}
+
+	return cfg.Save(configFile)
+}
+
+func parsePublisherKind(s string) (config.PublisherKind, error) {
+	pubkind := config.PublisherKind(s)
 	switch pubkind {
 	case "":
 		pubkind = config.Libp2pPublisherKind
 	case config.Libp2pPublisherKind, config.HttpPublisherKind, config.Libp2pHttpPublisherKind:
 	default:
-		return fmt.Errorf("unknown publisher kind: %s", pubkind)
+		return "", fmt.Errorf("unknown publisher kind: %s", pubkind)
 	}
-	cfg.Ingest.PublisherKind = pubkind
+	return pubkind, nil
+}
 
-	return cfg.Save(configFile)
+// promptConfig prompts for the values of the most commonly changed config
+// settings, offering the current values as defaults. The preset is prompted
+// for first if askPreset is true.
+func promptConfig(p *prompter, cfg *config.Config, askPreset bool) error {
+	if askPreset {
+		presets := make([]string, len(config.Presets))
+		for i, preset := range config.Presets {
+			presets[i] = string(preset)
+		}
+		name, err := p.ask(fmt.Sprintf("Preset (%s, or none)", strings.Join(presets, ", ")), "none")
+		if err != nil {
+			return err
+		}
+		if name != "none" {
+			preset, err := config.ParsePreset(name)
+			if err != nil {
+				return err
+			}
+			if err = cfg.ApplyPreset(preset); err != nil {
+				return err
+			}
+		}
+	}
+
+	answer, err := p.ask("Publisher kind (http, libp2p, libp2phttp)", string(cfg.Ingest.PublisherKind))
+	if err != nil {
+		return err
+	}
+	if cfg.Ingest.PublisherKind, err = parsePublisherKind(answer); err != nil {
+		return err
+	}
+	if cfg.Ingest.PublisherKind != config.Libp2pPublisherKind {
+		if cfg.Ingest.HttpPublisher.ListenMultiaddr, err = p.ask("HTTP publisher listen address", cfg.Ingest.HttpPublisher.ListenMultiaddr); err != nil {
+			return err
+		}
+	}
+	if cfg.ProviderServer.ListenMultiaddr, err = p.ask("libp2p listen address", cfg.ProviderServer.ListenMultiaddr); err != nil {
+		return err
+	}
+
+	if answer, err = p.ask("Indexer URLs to announce to directly (comma separated, or none)", joinOrNone(cfg.DirectAnnounce.URLs)); err != nil {
+		return err
+	}
+	cfg.DirectAnnounce.URLs = splitOrNone(answer)
+	if _, err = cfg.DirectAnnounce.ParseURLs(); err != nil {
+		return err
+	}
+	pubsub, err := p.confirm("Announce over gossip pubsub", !cfg.DirectAnnounce.NoPubsubAnnounce)
+	if err != nil {
+		return err
+	}
+	cfg.DirectAnnounce.NoPubsubAnnounce = !pubsub
+	if pubsub {
+		if cfg.Ingest.PubSubTopic, err = p.ask("Pubsub topic", cfg.Ingest.PubSubTopic); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Datastore.Dir, err = p.ask("Datastore directory", cfg.Datastore.Dir); err != nil {
+		return err
+	}
+
+	if cfg.DelegatedRouting.ListenMultiaddr != "" {
+		if cfg.DelegatedRouting.ListenMultiaddr, err = p.ask("Delegated routing listen address", cfg.DelegatedRouting.ListenMultiaddr); err != nil {
+			return err
+		}
+		if cfg.DelegatedRouting.ProviderID, err = p.ask("Peer ID of the IPFS node", cfg.DelegatedRouting.ProviderID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func joinOrNone(values []string) string {
+	if len(values) == 0 {
+		return "none"
+	}
+	return strings.Join(values, ",")
+}
+
+func splitOrNone(s string) []string {
+	if s == "none" {
+		return nil
+	}
+	var values []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// prompter asks questions on w and reads the answers from r, one per line.
+type prompter struct {
+	r *bufio.Reader
+	w io.Writer
+}
+
+// ask prompts the question and returns the answer, or def if the answer is
+// empty or there is no more input.
+func (p *prompter) ask(question, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(p.w, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(p.w, "%s: ", question)
+	}
+	line, err := p.r.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	if errors.Is(err, io.EOF) {
+		fmt.Fprintln(p.w)
+	}
+	if line = strings.TrimSpace(line); line == "" {
+		return def, nil
+	}
+	return line, nil
+}
+
+// confirm prompts a yes or no question and returns the answer, or def if the
+// answer is empty.
+func (p *prompter) confirm(question string, def bool) (bool, error) {
+	defAnswer := "n"
+	if def {
+		defAnswer = "y"
+	}
+	for {
+		answer, err := p.ask(question+" (y/n)", defAnswer)
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(answer) {
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+		fmt.Fprintln(p.w, "Please answer y or n.")
+	}
 }
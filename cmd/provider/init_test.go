@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ipni/index-provider/cmd/provider/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_promptConfig(t *testing.T) {
+	cfg, err := config.Init(io.Discard)
+	require.NoError(t, err)
+
+	// Choose the ipfs-node preset, switch to libp2phttp publisher, and accept
+	// defaults for everything else apart from the pubsub and IPFS node peer ID
+	// questions.
+	answers := strings.Join([]string{
+		"ipfs-node",
+		"libp2phttp",
+		"",
+		"",
+		"https://example.com/announce, https://cid.contact/ingest/announce",
+		"maybe",
+		"n",
+		"",
+		"",
+		"12D3KooWPMGfQs5CaJKG4yCxVWizWBRtB85gEUwiX2ekStvYvqgp",
+	}, "\n")
+	p := &prompter{r: bufio.NewReader(strings.NewReader(answers)), w: io.Discard}
+	require.NoError(t, promptConfig(p, cfg, true))
+
+	require.Equal(t, config.Libp2pHttpPublisherKind, cfg.Ingest.PublisherKind)
+	require.Equal(t, config.NewHttpPublisher().ListenMultiaddr, cfg.Ingest.HttpPublisher.ListenMultiaddr)
+	require.Equal(t, []string{"https://example.com/announce", "https://cid.contact/ingest/announce"}, cfg.DirectAnnounce.URLs)
+	require.True(t, cfg.DirectAnnounce.NoPubsubAnnounce)
+	require.Equal(t, config.NewDatastore(), cfg.Datastore)
+	require.NotEmpty(t, cfg.DelegatedRouting.ListenMultiaddr)
+	require.Equal(t, "12D3KooWPMGfQs5CaJKG4yCxVWizWBRtB85gEUwiX2ekStvYvqgp", cfg.DelegatedRouting.ProviderID)
+}
+
+func Test_promptConfigNoInput(t *testing.T) {
+	cfg, err := config.Init(io.Discard)
+	require.NoError(t, err)
+	want := *cfg
+
+	p := &prompter{r: bufio.NewReader(strings.NewReader("")), w: io.Discard}
+	require.NoError(t, promptConfig(p, cfg, true))
+	require.Equal(t, want, *cfg)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/ipfs/go-cid"
+	"github.com/urfave/cli/v2"
+)
+
+var InspectCmd = &cli.Command{
+	Name:  "inspect",
+	Usage: "Shows statistics of the advertisement chain of a provider",
+	Description: `Walks the advertisement chain of a provider backwards from its latest advertisement, or from the
+advertisement specified by --ad-cid, and shows statistics of the advertisements walked: how many
+there are, how many are removals or have extended providers, how many distinct providers and context
+IDs they advertise, and how many context IDs are still active. The first entry chunk of each
+advertisement is fetched to sample the number of entries, so that operators of indexers can size up
+a provider before allowing it without fetching all its entries.
+
+Use --depth to limit the number of advertisements walked.`,
+	Action: doInspect,
+	Flags: []cli.Flag{
+		providerAddrInfoFlag,
+		adCidFlag,
+		&cli.UintFlag{
+			Name:  "depth",
+			Usage: "The maximum number of advertisements to walk. Zero walks the entire chain.",
+			Value: 1000,
+		},
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Usage:   "The output format, one of: table, json.",
+			Value:   outputTable,
+		},
+	},
+}
+
+func doInspect(cctx *cli.Context) error {
+	output := cctx.String("output")
+	if output != outputTable && output != outputJson {
+		return fmt.Errorf("unknown output format %q; must be one of %s or %s", output, outputTable, outputJson)
+	}
+	var from cid.Cid
+	if adCidFlagValue != "" {
+		var err error
+		if from, err = cid.Decode(adCidFlagValue); err != nil {
+			return fmt.Errorf("invalid ad-cid: %w", err)
+		}
+	}
+
+	pc, err := newProviderClient()
+	if err != nil {
+		return err
+	}
+	defer pc.Close()
+	stats, err := pc.ChainStats(cctx.Context, from, int(cctx.Uint("depth")))
+	if err != nil {
+		return err
+	}
+
+	if output == outputJson {
+		enc := json.NewEncoder(cctx.App.Writer)
+		enc.SetIndent("", "  ")
+		return enc.Encode(stats)
+	}
+
+	walked := "until depth limit"
+	if stats.Complete {
+		walked = "entire chain"
+	}
+	tw := tabwriter.NewWriter(cctx.App.Writer, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "From:\t%s\n", stats.From)
+	fmt.Fprintf(tw, "Last:\t%s (%s)\n", stats.Last, walked)
+	fmt.Fprintf(tw, "Advertisements:\t%d\n", stats.Ads)
+	fmt.Fprintf(tw, "Removals:\t%d\n", stats.Removals)
+	fmt.Fprintf(tw, "Extended providers:\t%d\n", stats.ExtendedProviders)
+	fmt.Fprintf(tw, "Providers:\t%d\n", stats.Providers)
+	fmt.Fprintf(tw, "Context IDs:\t%d (%d active)\n", stats.ContextIDs, stats.ActiveContextIDs)
+	fmt.Fprintf(tw, "With entries:\t%d\n", stats.AdsWithEntries)
+	fmt.Fprintf(tw, "Entries sampled:\t%d\n", stats.EntriesSampled)
+	return tw.Flush()
+}
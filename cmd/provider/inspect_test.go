@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/cmd/provider/internal"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestInspectCmd(t *testing.T) {
+	ctx := context.Background()
+	eng, err := engine.New(
+		engine.WithPublisherKind(engine.HttpPublisher),
+		engine.WithHttpPublisherWithoutServer(),
+		engine.WithPubsubAnnounce(false))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+	eng.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(4)), nil
+	})
+	md := metadata.Default.New(metadata.Bitswap{})
+	for _, contextID := range []string{"fish", "lobster", "crab"} {
+		_, err = eng.NotifyPut(ctx, nil, []byte(contextID), md)
+		require.NoError(t, err)
+	}
+	_, err = eng.NotifyRemove(ctx, "", []byte("lobster"))
+	require.NoError(t, err)
+	handler, err := eng.GetPublisherHttpFunc()
+	require.NoError(t, err)
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	run := func(args ...string) string {
+		var out bytes.Buffer
+		app := &cli.App{Writer: &out, Commands: []*cli.Command{InspectCmd}}
+		err := app.Run(append([]string{"provider", "inspect", "-p", server.URL}, args...))
+		require.NoError(t, err)
+		return out.String()
+	}
+
+	var stats internal.ChainStats
+	require.NoError(t, json.Unmarshal([]byte(run("-o", "json")), &stats))
+	require.True(t, stats.Complete)
+	require.Equal(t, 4, stats.Ads)
+	require.Equal(t, 1, stats.Removals)
+	require.Equal(t, 3, stats.ContextIDs)
+	require.Equal(t, 2, stats.ActiveContextIDs)
+	require.Equal(t, 3, stats.AdsWithEntries)
+	require.Equal(t, 12, stats.EntriesSampled)
+
+	out := run("--depth", "2")
+	require.Contains(t, out, "until depth limit")
+	require.Contains(t, out, "Removals:")
+}
@@ -0,0 +1,579 @@
+// Package adminclient provides a typed client for the admin HTTP API of the
+// provider daemon. Each method of Client corresponds to an operation of the
+// OpenAPI document served by the admin server at "/admin/openapi.json", and is
+// named after its operation ID.
+package adminclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	adminserver "github.com/ipni/index-provider/server/admin/http"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+var (
+	// ErrNotFound is matched by errors of requests to which the server
+	// responded with 404 Not Found.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is matched by errors of requests to which the server
+	// responded with 409 Conflict, e.g. when content is already advertised.
+	ErrConflict = errors.New("conflict")
+)
+
+// Error is returned when the admin server responds with an unexpected status.
+type Error struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Message is the body of the response.
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", http.StatusText(e.StatusCode), e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusConflict:
+		return ErrConflict
+	default:
+		return nil
+	}
+}
+
+type idempotencyKeyKey struct{}
+
+// WithIdempotencyKey returns a context with which requests to publish or
+// remove content are sent with the given idempotency key, such that retrying a
+// request with the same key, e.g. after it timed out, does not publish again
+// but responds with the outcome of the original request.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyKey{}, key)
+}
+
+// Client sends requests to the admin server of a provider daemon.
+type Client struct {
+	*options
+	baseURL string
+}
+
+// New instantiates a client of the admin server at the given base URL, e.g.
+// "http://localhost:3102".
+func New(baseURL string, o ...Option) (*Client, error) {
+	opts, err := newOptions(o...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = url.ParseRequestURI(baseURL); err != nil {
+		return nil, fmt.Errorf("invalid admin server url %q: %w", baseURL, err)
+	}
+	return &Client{
+		options: opts,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}, nil
+}
+
+// Healthz checks that announcements of the provider are not stuck.
+func (c *Client) Healthz(ctx context.Context) error {
+	return c.probe(ctx, "/healthz")
+}
+
+// Readyz checks that the provider engine is started, its publisher is
+// listening and its datastore is writable.
+func (c *Client) Readyz(ctx context.Context) error {
+	return c.probe(ctx, "/readyz")
+}
+
+// GetOpenAPI gets the OpenAPI document of the admin server.
+func (c *Client) GetOpenAPI(ctx context.Context) ([]byte, error) {
+	resp, err := c.send(ctx, http.MethodGet, "/admin/openapi.json", nil, nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// Announce announces the latest advertisement, and returns its CID.
+func (c *Client) Announce(ctx context.Context) (cid.Cid, error) {
+	var res adminserver.AnnounceRes
+	if err := c.do(ctx, http.MethodPost, "/admin/announce", nil, nil, &res); err != nil {
+		return cid.Undef, err
+	}
+	return res.AdvId, nil
+}
+
+// AnnounceHttp announces the latest advertisement to the indexers at the given
+// URLs via HTTP, and returns its CID.
+func (c *Client) AnnounceHttp(ctx context.Context, indexers []string) (cid.Cid, error) {
+	req := &adminserver.AnnounceHttpReq{Indexers: indexers}
+	var res adminserver.AnnounceRes
+	if err := c.do(ctx, http.MethodPost, "/admin/announcehttp", nil, req, &res); err != nil {
+		return cid.Undef, err
+	}
+	return res.AdvId, nil
+}
+
+// Connect connects the provider to the libp2p peer at the given multiaddr,
+// which must include the peer ID.
+func (c *Client) Connect(ctx context.Context, maddr string) error {
+	req := &adminserver.ConnectReq{Maddr: maddr}
+	return c.do(ctx, http.MethodPost, "/admin/connect", nil, req, &adminserver.ConnectRes{})
+}
+
+// Disconnect disconnects the provider from the given libp2p peer.
+func (c *Client) Disconnect(ctx context.Context, p peer.ID) error {
+	req := &adminserver.PeerReq{PeerID: p}
+	return c.do(ctx, http.MethodPost, "/admin/disconnect", nil, req, &adminserver.PeerRes{})
+}
+
+// Protect protects the connections to the given peer with the given tag from
+// being trimmed. The default tag is used if tag is empty.
+func (c *Client) Protect(ctx context.Context, p peer.ID, tag string) error {
+	req := &adminserver.PeerReq{PeerID: p, Tag: tag}
+	return c.do(ctx, http.MethodPost, "/admin/protect", nil, req, &adminserver.PeerRes{})
+}
+
+// Unprotect removes the protection of the connections to the given peer with
+// the given tag. The default tag is used if tag is empty.
+func (c *Client) Unprotect(ctx context.Context, p peer.ID, tag string) error {
+	req := &adminserver.PeerReq{PeerID: p, Tag: tag}
+	return c.do(ctx, http.MethodPost, "/admin/unprotect", nil, req, &adminserver.PeerRes{})
+}
+
+// ListPeers lists the libp2p peers connected to the provider.
+func (c *Client) ListPeers(ctx context.Context) ([]adminserver.PeerInfo, error) {
+	var res adminserver.ListPeersRes
+	if err := c.do(ctx, http.MethodGet, "/admin/peers", nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return res.Peers, nil
+}
+
+// GetStats gets the statistics of the provider engine.
+func (c *Client) GetStats(ctx context.Context) (*adminserver.StatsRes, error) {
+	var res adminserver.StatsRes
+	if err := c.do(ctx, http.MethodGet, "/admin/stats", nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// GetHead gets the head of the advertisement chain signed by the provider, or
+// nil if no advertisements have been published.
+func (c *Client) GetHead(ctx context.Context) (*adminserver.HeadRes, error) {
+	var res adminserver.HeadRes
+	err := c.do(ctx, http.MethodGet, "/head", nil, nil, &res)
+	var apiErr *Error
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// GetGossip gets the diagnostics of announcing over gossip pubsub.
+func (c *Client) GetGossip(ctx context.Context) (*adminserver.GossipRes, error) {
+	var res adminserver.GossipRes
+	if err := c.do(ctx, http.MethodGet, "/admin/gossip", nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ListPublishQueue lists the publish in progress, if any, followed by the
+// waiting publishes in the order in which they will be published.
+func (c *Client) ListPublishQueue(ctx context.Context) ([]adminserver.QueuedPublishInfo, error) {
+	var res adminserver.ListPublishQueueRes
+	if err := c.do(ctx, http.MethodGet, "/admin/queue", nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return res.Queue, nil
+}
+
+// ReorderPublish sets the priority of the waiting publish with the given ID,
+// and or moves it to the front of the publish queue, as requested.
+func (c *Client) ReorderPublish(ctx context.Context, id uint64, req *adminserver.ReorderPublishReq) (*adminserver.QueuedPublishInfo, error) {
+	var res adminserver.QueuedPublishInfo
+	if err := c.do(ctx, http.MethodPost, "/admin/queue/"+strconv.FormatUint(id, 10), nil, req, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Reload reloads the configuration of the provider, and returns which changed
+// settings were applied and which require a restart.
+func (c *Client) Reload(ctx context.Context) (*adminserver.ReloadRes, error) {
+	var res adminserver.ReloadRes
+	if err := c.do(ctx, http.MethodPost, "/admin/reload", nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ListLogLevels lists the log level of each logging subsystem, keyed by
+// subsystem name.
+func (c *Client) ListLogLevels(ctx context.Context) (map[string]string, error) {
+	var res adminserver.ListLogLevelsRes
+	if err := c.do(ctx, http.MethodGet, "/admin/log", nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return res.Levels, nil
+}
+
+// GetLogLevel gets the log level of the given logging subsystem.
+func (c *Client) GetLogLevel(ctx context.Context, subsystem string) (string, error) {
+	var res adminserver.LogLevelRes
+	if err := c.do(ctx, http.MethodGet, "/admin/log/"+subsystem, nil, nil, &res); err != nil {
+		return "", err
+	}
+	return res.Level, nil
+}
+
+// SetLogLevel sets the log level of the given logging subsystem, or of all
+// subsystems if subsystem is "*".
+func (c *Client) SetLogLevel(ctx context.Context, subsystem, level string) error {
+	req := &adminserver.SetLogLevelReq{Level: level}
+	return c.do(ctx, http.MethodPut, "/admin/log/"+subsystem, nil, req, &adminserver.LogLevelRes{})
+}
+
+// PurgeCache starts a job that deletes all cached advertisement entries, and
+// returns its initial status.
+func (c *Client) PurgeCache(ctx context.Context) (*adminserver.JobRes, error) {
+	return c.startJob(ctx, "/admin/cache/purge", nil, nil)
+}
+
+// CollectGarbage starts a job that garbage collects the entries cache and the
+// datastore of the provider, and returns its initial status.
+func (c *Client) CollectGarbage(ctx context.Context) (*adminserver.JobRes, error) {
+	return c.startJob(ctx, "/admin/gc", nil, nil)
+}
+
+// GetJob gets the status of the job with the given ID.
+func (c *Client) GetJob(ctx context.Context, id string) (*adminserver.JobRes, error) {
+	var res adminserver.JobRes
+	if err := c.do(ctx, http.MethodGet, "/admin/jobs/"+url.PathEscape(id), nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ListAds lists at most limit advertisements published by the provider,
+// newest first, starting after the advertisement with the given CID or from
+// the latest advertisement if after is cid.Undef. The server default limit is
+// used if limit is zero. Only the advertisements that have all the given
+// labels are listed.
+func (c *Client) ListAds(ctx context.Context, after cid.Cid, limit int, labels map[string]string) (*adminserver.ListAdsRes, error) {
+	query := url.Values{}
+	if after != cid.Undef {
+		query.Set("after", after.String())
+	}
+	if limit != 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	for k, v := range labels {
+		query.Add("label", k+"="+v)
+	}
+	var res adminserver.ListAdsRes
+	if err := c.do(ctx, http.MethodGet, "/admin/ads", query, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ListAudit lists up to limit records of the audit log of published
+// advertisements, oldest first, after the record with the given sequence
+// number. Use 0 to list from the first record, and a limit of 0 for the
+// server default.
+func (c *Client) ListAudit(ctx context.Context, after uint64, limit int) (*adminserver.ListAuditRes, error) {
+	query := url.Values{}
+	if after != 0 {
+		query.Set("after", strconv.FormatUint(after, 10))
+	}
+	if limit != 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var res adminserver.ListAuditRes
+	if err := c.do(ctx, http.MethodGet, "/admin/audit", query, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// GetAd gets the advertisement with the given CID.
+func (c *Client) GetAd(ctx context.Context, adCid cid.Cid) (*adminserver.AdInfo, error) {
+	var res adminserver.AdInfo
+	if err := c.do(ctx, http.MethodGet, "/admin/ads/"+adCid.String(), nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// SetAdLabels replaces the labels of the advertisement with the given CID, or
+// removes them if none are given, and returns the labeled advertisement.
+func (c *Client) SetAdLabels(ctx context.Context, adCid cid.Cid, labels map[string]string) (*adminserver.AdInfo, error) {
+	var res adminserver.AdInfo
+	req := &adminserver.SetAdLabelsReq{Labels: labels}
+	if err := c.do(ctx, http.MethodPut, "/admin/ads/"+adCid.String()+"/labels", nil, req, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ImportCar imports a CAR file and advertises its multihashes.
+func (c *Client) ImportCar(ctx context.Context, req *adminserver.ImportCarReq) (*adminserver.ImportCarRes, error) {
+	var res adminserver.ImportCarRes
+	if err := c.do(ctx, http.MethodPost, "/admin/import/car", nil, req, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ImportCarAsync starts a job that imports a CAR file and advertises its
+// multihashes, and returns its initial status. The result of the job is an
+// ImportCarRes.
+func (c *Client) ImportCarAsync(ctx context.Context, req *adminserver.ImportCarReq) (*adminserver.JobRes, error) {
+	return c.startJob(ctx, "/admin/import/car", asyncQuery(), req)
+}
+
+// RemoveCar advertises the removal of the CAR file imported with the given
+// key, and returns the CID of the removal advertisement.
+func (c *Client) RemoveCar(ctx context.Context, key []byte) (cid.Cid, error) {
+	req := &adminserver.RemoveCarReq{Key: key}
+	var res adminserver.RemoveCarRes
+	if err := c.do(ctx, http.MethodPost, "/admin/remove/car", nil, req, &res); err != nil {
+		return cid.Undef, err
+	}
+	return res.AdvId, nil
+}
+
+// ListCars lists the paths of the imported CAR files.
+func (c *Client) ListCars(ctx context.Context) ([]string, error) {
+	var res adminserver.ListCarRes
+	if err := c.do(ctx, http.MethodGet, "/admin/list/car", nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return res.Paths, nil
+}
+
+// Advertise advertises the multihashes of a context ID, and returns the CID
+// of the advertisement.
+func (c *Client) Advertise(ctx context.Context, req *adminserver.AdvertiseReq) (cid.Cid, error) {
+	var res adminserver.AdvertiseRes
+	if err := c.do(ctx, http.MethodPost, "/admin/advertise", nil, req, &res); err != nil {
+		return cid.Undef, err
+	}
+	return res.AdvId, nil
+}
+
+// AdvertiseDryRun lists, chunks, signs and validates the advertisement of
+// the multihashes of a context ID without publishing it, and describes it.
+func (c *Client) AdvertiseDryRun(ctx context.Context, req *adminserver.AdvertiseReq) (*adminserver.DryRunRes, error) {
+	query := url.Values{"dry_run": []string{"true"}}
+	var res adminserver.DryRunRes
+	if err := c.do(ctx, http.MethodPost, "/admin/advertise", query, req, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Remove advertises the removal of a context ID, and returns the CID of the
+// removal advertisement.
+func (c *Client) Remove(ctx context.Context, req *adminserver.RemoveReq) (cid.Cid, error) {
+	var res adminserver.RemoveRes
+	if err := c.do(ctx, http.MethodPost, "/admin/remove", nil, req, &res); err != nil {
+		return cid.Undef, err
+	}
+	return res.AdvId, nil
+}
+
+// RemoveContexts advertises the removal of the given context IDs, or of all
+// advertised context IDs.
+func (c *Client) RemoveContexts(ctx context.Context, req *adminserver.RemoveContextReq) (*adminserver.RemoveContextRes, error) {
+	var res adminserver.RemoveContextRes
+	if err := c.do(ctx, http.MethodPost, "/admin/remove/context", nil, req, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// RemoveContextsAsync starts a job that advertises the removal of the given
+// context IDs, or of all advertised context IDs, and returns its initial
+// status. The result of the job is a RemoveContextRes.
+func (c *Client) RemoveContextsAsync(ctx context.Context, req *adminserver.RemoveContextReq) (*adminserver.JobRes, error) {
+	return c.startJob(ctx, "/admin/remove/context", asyncQuery(), req)
+}
+
+// ListContexts lists at most limit advertised context IDs in ascending byte
+// order, starting after the given context ID. The server default limit is used
+// if limit is zero.
+func (c *Client) ListContexts(ctx context.Context, after []byte, limit uint) (*adminserver.ListContextsRes, error) {
+	query := url.Values{}
+	if len(after) != 0 {
+		query.Set("after", base64.StdEncoding.EncodeToString(after))
+	}
+	if limit != 0 {
+		query.Set("limit", strconv.FormatUint(uint64(limit), 10))
+	}
+	var res adminserver.ListContextsRes
+	if err := c.do(ctx, http.MethodGet, "/admin/list/contexts", query, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ListTenants lists the provider identities hosted by the provider.
+func (c *Client) ListTenants(ctx context.Context) ([]adminserver.TenantInfo, error) {
+	var res adminserver.ListTenantsRes
+	if err := c.do(ctx, http.MethodGet, "/admin/tenants", nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return res.Tenants, nil
+}
+
+// CreateTenant creates a tenant that publishes its own advertisement chain
+// under its own identity.
+func (c *Client) CreateTenant(ctx context.Context, req *adminserver.CreateTenantReq) (*adminserver.TenantInfo, error) {
+	var res adminserver.TenantInfo
+	if err := c.do(ctx, http.MethodPost, "/admin/tenants", nil, req, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// GetTenant gets the tenant with the given ID.
+func (c *Client) GetTenant(ctx context.Context, id peer.ID) (*adminserver.TenantInfo, error) {
+	var res adminserver.TenantInfo
+	if err := c.do(ctx, http.MethodGet, "/admin/tenants/"+id.String(), nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// DeleteTenant deletes the tenant with the given ID, along with its identity
+// and advertisements.
+func (c *Client) DeleteTenant(ctx context.Context, id peer.ID) error {
+	return c.do(ctx, http.MethodDelete, "/admin/tenants/"+id.String(), nil, nil, &adminserver.DeleteTenantRes{})
+}
+
+// AdvertiseTenant advertises the multihashes of a context ID on behalf of the
+// tenant with the given ID, and returns the CID of the advertisement.
+func (c *Client) AdvertiseTenant(ctx context.Context, id peer.ID, req *adminserver.AdvertiseReq) (cid.Cid, error) {
+	var res adminserver.AdvertiseRes
+	if err := c.do(ctx, http.MethodPost, "/admin/tenants/"+id.String()+"/advertise", nil, req, &res); err != nil {
+		return cid.Undef, err
+	}
+	return res.AdvId, nil
+}
+
+// RemoveTenant advertises the removal of a context ID on behalf of the tenant
+// with the given ID, and returns the CID of the removal advertisement.
+func (c *Client) RemoveTenant(ctx context.Context, id peer.ID, req *adminserver.RemoveReq) (cid.Cid, error) {
+	var res adminserver.RemoveRes
+	if err := c.do(ctx, http.MethodPost, "/admin/tenants/"+id.String()+"/remove", nil, req, &res); err != nil {
+		return cid.Undef, err
+	}
+	return res.AdvId, nil
+}
+
+func (c *Client) startJob(ctx context.Context, path string, query url.Values, req io.WriterTo) (*adminserver.JobRes, error) {
+	resp, err := c.send(ctx, http.MethodPost, path, query, req, http.StatusAccepted)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var res adminserver.JobRes
+	if err = decode(resp, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func asyncQuery() url.Values {
+	return url.Values{"async": []string{"true"}}
+}
+
+func (c *Client) probe(ctx context.Context, path string) error {
+	resp, err := c.send(ctx, http.MethodGet, path, nil, nil, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// do sends a request with the given JSON body, if any, and decodes the JSON
+// body of a 200 OK response into res.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, req io.WriterTo, res io.ReaderFrom) error {
+	resp, err := c.send(ctx, method, path, query, req, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decode(resp, res)
+}
+
+// send sends a request with the given JSON body, if any, and returns the
+// response if its status is the expected status. Otherwise, an *Error is
+// returned.
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, req io.WriterTo, status int) (*http.Response, error) {
+	u := c.baseURL + path
+	if len(query) != 0 {
+		u += "?" + query.Encode()
+	}
+	var body io.Reader
+	if req != nil {
+		var buf bytes.Buffer
+		if _, err := req.WriteTo(&buf); err != nil {
+			return nil, err
+		}
+		body = &buf
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if req != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	if c.bearerToken != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.bearerToken)
+	}
+	if key, ok := ctx.Value(idempotencyKeyKey{}).(string); ok && key != "" && method == http.MethodPost {
+		httpReq.Header.Set("Idempotency-Key", key)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != status {
+		defer resp.Body.Close()
+		msg, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+		return nil, &Error{StatusCode: resp.StatusCode, Message: string(msg)}
+	}
+	return resp, nil
+}
+
+func decode(resp *http.Response, res io.ReaderFrom) error {
+	if _, err := res.ReadFrom(resp.Body); err != nil {
+		return fmt.Errorf("received ok response from server but cannot decode response body: %w", err)
+	}
+	return nil
+}
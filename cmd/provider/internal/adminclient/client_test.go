@@ -0,0 +1,209 @@
+package adminclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	"github.com/ipni/index-provider/cmd/provider/internal/adminclient"
+	"github.com/ipni/index-provider/engine"
+	adminserver "github.com/ipni/index-provider/server/admin/http"
+	"github.com/ipni/index-provider/supplier"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_HasMethodPerOpenAPIOperation(t *testing.T) {
+	var doc struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(adminserver.OpenAPI, &doc))
+	clientType := reflect.TypeOf(&adminclient.Client{})
+	var count int
+	for path, ops := range doc.Paths {
+		for method, raw := range ops {
+			if method == "parameters" {
+				continue
+			}
+			var op struct {
+				OperationID string `json:"operationId"`
+			}
+			require.NoError(t, json.Unmarshal(raw, &op))
+			name := strings.ToUpper(op.OperationID[:1]) + op.OperationID[1:]
+			_, ok := clientType.MethodByName(name)
+			require.True(t, ok, "no client method %s for %s %s", name, method, path)
+			count++
+		}
+	}
+	require.NotZero(t, count)
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	eng, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	cs := supplier.NewCarSupplier(eng, ds)
+	ms := supplier.NewMultihashSupplier(eng, ds)
+	eng.RegisterMultihashLister(supplier.ChainListers(cs.ListMultihashes, ms.ListMultihashes))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+	server, err := adminserver.New(nil, eng, cs,
+		adminserver.WithListenAddr(addr),
+		adminserver.WithBearerToken("fish"),
+		adminserver.WithMultihashSupplier(ms))
+	require.NoError(t, err)
+	go func() { _ = server.Start() }()
+	t.Cleanup(func() { _ = server.Shutdown(ctx) })
+
+	unauthorized, err := adminclient.New("http://" + addr)
+	require.NoError(t, err)
+	require.NoError(t, unauthorized.Readyz(ctx))
+	_, err = unauthorized.GetStats(ctx)
+	var apiErr *adminclient.Error
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+
+	subject, err := adminclient.New("http://"+addr+"/", adminclient.WithBearerToken("fish"))
+	require.NoError(t, err)
+	bitswap := metadata.Default.New(metadata.Bitswap{})
+	md, err := bitswap.MarshalBinary()
+	require.NoError(t, err)
+	dryRun, err := subject.AdvertiseDryRun(ctx, &adminserver.AdvertiseReq{
+		ContextID:   []byte("fish"),
+		Metadata:    md,
+		Multihashes: test.RandomMultihashes(3),
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, *dryRun.MultihashCount)
+	adCid, err := subject.Advertise(ctx, &adminserver.AdvertiseReq{
+		ContextID:   []byte("fish"),
+		Metadata:    md,
+		Multihashes: test.RandomMultihashes(3),
+	})
+	require.NoError(t, err)
+	_, err = subject.Advertise(ctx, &adminserver.AdvertiseReq{ContextID: []byte("fish"), Metadata: md})
+	require.ErrorIs(t, err, adminclient.ErrConflict)
+
+	stats, err := subject.GetStats(ctx)
+	require.NoError(t, err)
+	require.Equal(t, adCid, *stats.Head)
+	head, err := unauthorized.GetHead(ctx)
+	require.NoError(t, err)
+	require.Equal(t, adCid, head.Head)
+	contexts, err := subject.ListContexts(ctx, nil, 0)
+	require.NoError(t, err)
+	require.Len(t, contexts.Contexts, 1)
+	ads, err := subject.ListAds(ctx, cid.Undef, 1, nil)
+	require.NoError(t, err)
+	require.Equal(t, adCid, ads.Ads[0].ID)
+	ad, err := subject.GetAd(ctx, adCid)
+	require.NoError(t, err)
+	require.Equal(t, []byte("fish"), ad.ContextID)
+	labels := map[string]string{"dataset": "wikipedia"}
+	ad, err = subject.SetAdLabels(ctx, adCid, labels)
+	require.NoError(t, err)
+	require.Equal(t, labels, ad.Labels)
+	ads, err = subject.ListAds(ctx, cid.Undef, 0, labels)
+	require.NoError(t, err)
+	require.Len(t, ads.Ads, 1)
+	ads, err = subject.ListAds(ctx, cid.Undef, 0, map[string]string{"dataset": "gutenberg"})
+	require.NoError(t, err)
+	require.Empty(t, ads.Ads)
+	audit, err := subject.ListAudit(ctx, 0, 0)
+	require.NoError(t, err)
+	require.Empty(t, audit.Records)
+
+	rmCid, err := subject.Remove(ctx, &adminserver.RemoveReq{ContextID: []byte("fish")})
+	require.NoError(t, err)
+	require.NotEqual(t, adCid, rmCid)
+	_, err = subject.Remove(ctx, &adminserver.RemoveReq{ContextID: []byte("fish")})
+	require.ErrorIs(t, err, adminclient.ErrNotFound)
+
+	// Retrying a request with the same idempotency key does not publish again.
+	idempotentCtx := adminclient.WithIdempotencyKey(ctx, "lobster-1")
+	lobster := &adminserver.AdvertiseReq{ContextID: []byte("lobster"), Metadata: md, Multihashes: test.RandomMultihashes(3)}
+	adCid, err = subject.Advertise(idempotentCtx, lobster)
+	require.NoError(t, err)
+	retriedCid, err := subject.Advertise(idempotentCtx, lobster)
+	require.NoError(t, err)
+	require.Equal(t, adCid, retriedCid)
+
+	job, err := subject.CollectGarbage(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "gc", job.Kind)
+	_, err = subject.GetJob(ctx, job.ID)
+	require.NoError(t, err)
+	job, err = subject.RemoveContextsAsync(ctx, &adminserver.RemoveContextReq{ContextIDs: [][]byte{[]byte("fish")}})
+	require.NoError(t, err)
+	require.Equal(t, "remove-contexts", job.Kind)
+	require.Eventually(t, func() bool {
+		job, err = subject.GetJob(ctx, job.ID)
+		return err == nil && job.Status != adminserver.JobRunning
+	}, 10*time.Second, 10*time.Millisecond)
+	require.Equal(t, adminserver.JobFailed, job.Status)
+	require.Equal(t, http.StatusNotFound, job.Code)
+	_, err = subject.GetJob(ctx, "unknown")
+	require.ErrorIs(t, err, adminclient.ErrNotFound)
+
+	doc, err := subject.GetOpenAPI(ctx)
+	require.NoError(t, err)
+	require.Equal(t, adminserver.OpenAPI, doc)
+}
+
+func TestClient_PostsJsonRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "/admin/disconnect", r.URL.Path)
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"peer_id":"12D3KooWLjeDyvuv7rbfG2wWNvWn7ybmmU88PirmSckuqCgXBAph"}`, string(body))
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	subject, err := adminclient.New(server.URL)
+	require.NoError(t, err)
+	p, err := peer.Decode("12D3KooWLjeDyvuv7rbfG2wWNvWn7ybmmU88PirmSckuqCgXBAph")
+	require.NoError(t, err)
+	require.NoError(t, subject.Disconnect(context.Background(), p))
+}
+
+func TestClient_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("fish"))
+	}))
+	defer server.Close()
+
+	subject, err := adminclient.New(server.URL)
+	require.NoError(t, err)
+	_, err = subject.GetStats(context.Background())
+	require.EqualError(t, err, "Bad Request: fish")
+
+	subject, err = adminclient.New("http://localhost:47891")
+	require.NoError(t, err)
+	_, err = subject.ListCars(context.Background())
+	require.Error(t, err)
+
+	_, err = adminclient.New("fish")
+	require.Error(t, err)
+}
@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/ipfs/go-cid"
+)
+
+// ChainStats aggregates the advertisements of a chain walked from a provider
+// publisher. See: ProviderClient.ChainStats.
+type ChainStats struct {
+	// From is the CID of the advertisement from which the chain was walked,
+	// and Last the CID of the last advertisement walked.
+	From cid.Cid `json:"from"`
+	Last cid.Cid `json:"last"`
+	// Complete is whether the start of the chain was reached.
+	Complete bool `json:"complete"`
+	// Ads is the number of advertisements walked, of which Removals are
+	// removal advertisements and ExtendedProviders have extended providers.
+	Ads               int `json:"ads"`
+	Removals          int `json:"removals"`
+	ExtendedProviders int `json:"extended_providers"`
+	// Providers is the number of distinct provider IDs advertised.
+	Providers int `json:"providers"`
+	// ContextIDs is the number of distinct context IDs, per provider, of
+	// which ActiveContextIDs are not removed by the latest advertisement of
+	// the context ID walked.
+	ContextIDs       int `json:"context_ids"`
+	ActiveContextIDs int `json:"active_context_ids"`
+	// AdsWithEntries is the number of advertisements with entries.
+	AdsWithEntries int `json:"ads_with_entries"`
+	// EntriesSampled is the number of multihashes in the first entry chunk of
+	// each advertisement with entries, or in all its entries if they are a
+	// HAMT.
+	EntriesSampled int `json:"entries_sampled"`
+}
+
+func (c *providerClient) ChainStats(ctx context.Context, from cid.Cid, depth int) (*ChainStats, error) {
+	if depth < 0 {
+		return nil, fmt.Errorf("depth must not be negative: %d", depth)
+	}
+	stats := &ChainStats{}
+	providers := make(map[string]struct{})
+	// removed records whether each context ID, keyed by provider and context
+	// ID, is removed by the latest advertisement of the context ID.
+	removed := make(map[string]bool)
+	next := from
+	for {
+		ad, err := c.GetAdvertisement(ctx, next)
+		if err != nil {
+			return nil, err
+		}
+		if stats.Ads == 0 {
+			stats.From = ad.ID
+		}
+		stats.Last = ad.ID
+		stats.Ads++
+		if ad.IsRemove {
+			stats.Removals++
+		}
+		if ad.ExtendedProvider != nil {
+			stats.ExtendedProviders++
+		}
+		providers[string(ad.ProviderID)] = struct{}{}
+		key := string(ad.ProviderID) + "/" + string(ad.ContextID)
+		if _, seen := removed[key]; !seen && len(ad.ContextID) != 0 {
+			removed[key] = ad.IsRemove
+		}
+		if ad.HasEntries() {
+			stats.AdsWithEntries++
+			mhs, err := ad.Entries.NextChunk()
+			if err != nil && err != io.EOF {
+				return nil, fmt.Errorf("failed to sample entries of advertisement %s: %w", ad.ID, err)
+			}
+			stats.EntriesSampled += len(mhs)
+		}
+
+		if ad.PreviousID == cid.Undef {
+			stats.Complete = true
+			break
+		}
+		if depth != 0 && stats.Ads >= depth {
+			break
+		}
+		next = ad.PreviousID
+	}
+
+	stats.Providers = len(providers)
+	stats.ContextIDs = len(removed)
+	for _, isRm := range removed {
+		if !isRm {
+			stats.ActiveContextIDs++
+		}
+	}
+	return stats, nil
+}
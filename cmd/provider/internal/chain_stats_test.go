@@ -0,0 +1,49 @@
+package internal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/go-libipni/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderClient_ChainStats(t *testing.T) {
+	pub := newTestPublisher(t)
+	first := pub.publish(test.RandomMultihashes(10), false)
+	second := pub.publish(nil, true)
+	head := pub.publish(test.RandomMultihashes(2), false)
+
+	client, err := NewHttpProviderClient(pub.addrInfo())
+	require.NoError(t, err)
+	defer client.Close()
+	ctx := context.Background()
+
+	stats, err := client.ChainStats(ctx, cid.Undef, 0)
+	require.NoError(t, err)
+	require.Equal(t, &ChainStats{
+		From:             head,
+		Last:             first,
+		Complete:         true,
+		Ads:              3,
+		Removals:         1,
+		Providers:        1,
+		ContextIDs:       1,
+		ActiveContextIDs: 1,
+		AdsWithEntries:   2,
+		// The first entry chunk of the first advertisement has 1 multihash.
+		EntriesSampled: 3,
+	}, stats)
+
+	stats, err = client.ChainStats(ctx, second, 1)
+	require.NoError(t, err)
+	require.Equal(t, second, stats.From)
+	require.Equal(t, second, stats.Last)
+	require.False(t, stats.Complete)
+	require.Equal(t, 1, stats.Removals)
+	require.Zero(t, stats.ActiveContextIDs)
+
+	_, err = client.ChainStats(ctx, cid.Undef, -1)
+	require.Error(t, err)
+}
@@ -1,6 +1,11 @@
 package config
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
 	"time"
 
 	"github.com/multiformats/go-multiaddr"
@@ -8,9 +13,10 @@ import (
 )
 
 const (
-	defaultAdminServerAddr = "/ip4/127.0.0.1/tcp/3102"
-	defaultReadTimeout     = Duration(30 * time.Second)
-	defaultWriteTimeout    = Duration(30 * time.Second)
+	defaultAdminServerAddr    = "/ip4/127.0.0.1/tcp/3102"
+	defaultReadTimeout        = Duration(30 * time.Second)
+	defaultWriteTimeout       = Duration(30 * time.Second)
+	defaultMaxRequestBodySize = 16 << 20
 )
 
 type AdminServer struct {
@@ -18,19 +24,114 @@ type AdminServer struct {
 	ListenMultiaddr string
 	ReadTimeout     Duration
 	WriteTimeout    Duration
+	// BearerToken, if set, is the token that requests to the admin API must
+	// present in an "Authorization: Bearer <token>" header.
+	BearerToken string `json:",omitempty"`
+	// TLSCertPath and TLSKeyPath are the paths of the PEM encoded certificate
+	// and key with which the admin API serves HTTPS. If not set, the admin API
+	// serves plain HTTP.
+	TLSCertPath string `json:",omitempty"`
+	TLSKeyPath  string `json:",omitempty"`
+	// ClientCAPath is the path of the PEM encoded certificates of the CAs that
+	// client certificates must be signed by. If set, clients must present a
+	// certificate signed by one of these CAs. Requires TLSCertPath and
+	// TLSKeyPath to be set.
+	ClientCAPath string `json:",omitempty"`
+	// GRPCListenMultiaddr, if set, is the address on which the admin API is
+	// also served over gRPC, e.g. "/ip4/127.0.0.1/tcp/3105". The gRPC admin
+	// API uses the same bearer token and TLS configuration as the HTTP one.
+	GRPCListenMultiaddr string `json:",omitempty"`
+	// RateLimits limits the rate of requests to the routes of the admin HTTP
+	// API, keyed by route. A route ending in "/" limits all paths under it
+	// that are not limited by a more specific route, e.g. "/admin/" limits
+	// all admin routes. Requests exceeding the limit are rejected with 429
+	// Too Many Requests.
+	RateLimits map[string]RateLimit `json:",omitempty"`
+	// MaxRequestBodySize is the maximum size in bytes of the bodies of
+	// requests to the admin HTTP API. Requests with larger bodies are
+	// rejected with 413 Request Entity Too Large. A negative value lifts the
+	// limit.
+	MaxRequestBodySize int64
+	// MaxRequestBodySizes overrides MaxRequestBodySize for specific routes,
+	// keyed by route as in RateLimits. A value of zero lifts the limit for
+	// the route, e.g. to allow large lists of multihashes to be advertised.
+	MaxRequestBodySizes map[string]int64 `json:",omitempty"`
+	// EnableProfiling serves the runtime profiling data of the provider under
+	// /debug/pprof/, for use with "go tool pprof". The duration of CPU
+	// profiles and traces must be shorter than WriteTimeout.
+	EnableProfiling bool `json:",omitempty"`
+	// EnableDebugBlocks serves the advertisements and entries chunks stored by
+	// the provider as dag-json under /debug/block/{cid}.
+	EnableDebugBlocks bool `json:",omitempty"`
+	// HostPublisher serves the advertisements of the HTTP publisher under
+	// /ipni/v1/ad/ on the admin server, instead of on
+	// Ingest.HttpPublisher.ListenMultiaddr, so that a single port serves the
+	// admin API, metrics, health checks and advertisements. Advertisements and
+	// health checks are served without the bearer token and client
+	// certificate required by the admin API. Requires Ingest.PublisherKind to
+	// be "http".
+	HostPublisher bool `json:",omitempty"`
+	// PublicMetrics serves the metrics on the admin server without the bearer
+	// token and client certificate required by the admin API. Metrics are
+	// only served on the admin server if Metrics.ListenMultiaddr is empty.
+	PublicMetrics bool `json:",omitempty"`
+	// IdempotencyKeyTTL is how long the response to a request to publish or
+	// remove content with an Idempotency-Key header is kept, during which
+	// retries of the request are responded to with that response instead of
+	// publishing again. Defaults to 24 hours if zero.
+	IdempotencyKeyTTL Duration `json:",omitempty"`
+}
+
+// RateLimit is the rate limit of requests to an admin API route.
+type RateLimit struct {
+	// Rate is the number of requests allowed per second.
+	Rate float64
+	// Burst is the number of requests allowed in bursts above the rate.
+	// Defaults to 1.
+	Burst int `json:",omitempty"`
 }
 
 // NewAdminServer instantiates a new AdminServer config with default values.
 func NewAdminServer() AdminServer {
 	return AdminServer{
-		ListenMultiaddr: defaultAdminServerAddr,
-		ReadTimeout:     defaultReadTimeout,
-		WriteTimeout:    defaultWriteTimeout,
+		ListenMultiaddr:    defaultAdminServerAddr,
+		ReadTimeout:        defaultReadTimeout,
+		WriteTimeout:       defaultWriteTimeout,
+		MaxRequestBodySize: defaultMaxRequestBodySize,
 	}
 }
 
 func (as *AdminServer) ListenNetAddr() (string, error) {
+	return toNetAddr(as.ListenMultiaddr)
+}
+
+// GRPCListenNetAddr returns the net address on which the admin API is served
+// over gRPC, or the empty string if it is not.
+func (as *AdminServer) GRPCListenNetAddr() (string, error) {
+	if as.GRPCListenMultiaddr == "" {
+		return "", nil
+	}
+	return toNetAddr(as.GRPCListenMultiaddr)
+}
+
+// PublisherMultiaddr returns the address at which the advertisements of the
+// HTTP publisher are served when hosted by the admin server, i.e. the listen
+// address of the admin server over HTTP, or HTTPS if it has a certificate.
+// See: HostPublisher.
+func (as *AdminServer) PublisherMultiaddr() (multiaddr.Multiaddr, error) {
 	maddr, err := multiaddr.NewMultiaddr(as.ListenMultiaddr)
+	if err != nil {
+		return nil, err
+	}
+	scheme := "/http"
+	if as.TLSCertPath != "" {
+		scheme = "/https"
+	}
+	return maddr.Encapsulate(multiaddr.StringCast(scheme)), nil
+}
+
+func toNetAddr(addr string) (string, error) {
+	maddr, err := multiaddr.NewMultiaddr(addr)
 	if err != nil {
 		return "", err
 	}
@@ -42,6 +143,38 @@ func (as *AdminServer) ListenNetAddr() (string, error) {
 	return netAddr.String(), nil
 }
 
+// TLSConfig returns the TLS configuration with which to serve the admin API,
+// or nil if the admin API serves plain HTTP.
+func (as *AdminServer) TLSConfig() (*tls.Config, error) {
+	if as.TLSCertPath == "" && as.TLSKeyPath == "" {
+		if as.ClientCAPath != "" {
+			return nil, errors.New("admin server ClientCAPath requires TLSCertPath and TLSKeyPath to be set")
+		}
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(as.TLSCertPath, as.TLSKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load admin server certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if as.ClientCAPath != "" {
+		caPEM, err := os.ReadFile(as.ClientCAPath)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read admin server client CAs: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in %s", as.ClientCAPath)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
 // PopulateDefaults replaces zero-values in the config with default values.
 func (c *AdminServer) PopulateDefaults() {
 	if c.ListenMultiaddr == "" {
@@ -53,4 +186,7 @@ func (c *AdminServer) PopulateDefaults() {
 	if c.WriteTimeout == 0 {
 		c.WriteTimeout = defaultWriteTimeout
 	}
+	if c.MaxRequestBodySize == 0 {
+		c.MaxRequestBodySize = defaultMaxRequestBodySize
+	}
 }
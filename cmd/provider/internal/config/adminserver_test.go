@@ -0,0 +1,88 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminServer_TLSConfig(t *testing.T) {
+	as := NewAdminServer()
+	tlsConfig, err := as.TLSConfig()
+	require.NoError(t, err)
+	require.Nil(t, tlsConfig)
+
+	certPath, keyPath := writeTestCert(t)
+	as.ClientCAPath = certPath
+	_, err = as.TLSConfig()
+	require.ErrorContains(t, err, "requires TLSCertPath and TLSKeyPath")
+
+	as.TLSCertPath = certPath
+	as.TLSKeyPath = keyPath
+	tlsConfig, err = as.TLSConfig()
+	require.NoError(t, err)
+	require.Len(t, tlsConfig.Certificates, 1)
+	require.Equal(t, tls.RequireAndVerifyClientCert, tlsConfig.ClientAuth)
+	require.NotNil(t, tlsConfig.ClientCAs)
+
+	as.ClientCAPath = ""
+	tlsConfig, err = as.TLSConfig()
+	require.NoError(t, err)
+	require.Equal(t, tls.NoClientCert, tlsConfig.ClientAuth)
+
+	as.TLSKeyPath = filepath.Join(t.TempDir(), "missing.pem")
+	_, err = as.TLSConfig()
+	require.Error(t, err)
+}
+
+func writeTestCert(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600))
+	return certPath, keyPath
+}
+
+func TestAdminServer_GRPCListenNetAddr(t *testing.T) {
+	as := NewAdminServer()
+	addr, err := as.GRPCListenNetAddr()
+	require.NoError(t, err)
+	require.Empty(t, addr)
+
+	as.GRPCListenMultiaddr = "/ip4/127.0.0.1/tcp/3105"
+	addr, err = as.GRPCListenNetAddr()
+	require.NoError(t, err)
+	require.Equal(t, "127.0.0.1:3105", addr)
+
+	as.GRPCListenMultiaddr = "fish"
+	_, err = as.GRPCListenNetAddr()
+	require.Error(t, err)
+}
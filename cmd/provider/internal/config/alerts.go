@@ -0,0 +1,62 @@
+package config
+
+import "time"
+
+const (
+	defaultAlertsCheckInterval       = Duration(time.Minute)
+	defaultAlertsMaxAnnounceFailures = 3
+	defaultAlertsMaxIngestionLag     = 10
+)
+
+// Alerts configures the notification of operators when the publishing of
+// advertisements runs into trouble: when announcements fail repeatedly, when
+// indexers lag behind in ingesting advertisements, or when announcements pile
+// up. Alerts are sent once when raised and once when resolved, to WebhookURL,
+// Command, or both. Alerting is disabled if neither is specified.
+type Alerts struct {
+	// WebhookURL is the URL to which alerts are posted as JSON.
+	WebhookURL string `json:",omitempty"`
+	// Command is the program, followed by its arguments, that is run for each
+	// alert. The alert is passed as JSON via its standard input, and via the
+	// ALERT_KIND, ALERT_SUBJECT, ALERT_PROVIDER, ALERT_MESSAGE and
+	// ALERT_RESOLVED environment variables.
+	Command []string `json:",omitempty"`
+	// MaxAnnounceFailures is the number of consecutive announce failures at
+	// which an alert is raised. Announce failures are not alerted if 0.
+	MaxAnnounceFailures int
+	// Indexers are the URLs of the indexers whose ingestion lag is checked.
+	Indexers []string `json:",omitempty"`
+	// MaxIngestionLag is the number of advertisements that Indexers may lag
+	// behind the latest advertisement before an alert is raised.
+	MaxIngestionLag int
+	// MaxPendingAnnounces is the number of announcements being sent at once at
+	// which an alert is raised. Pending announcements are not alerted if 0.
+	MaxPendingAnnounces int `json:",omitempty"`
+	// CheckInterval is the interval at which the ingestion lag of Indexers and
+	// the pending announcements are checked.
+	CheckInterval Duration
+}
+
+// NewAlerts instantiates a new Alerts config with default values.
+func NewAlerts() Alerts {
+	return Alerts{
+		MaxAnnounceFailures: defaultAlertsMaxAnnounceFailures,
+		MaxIngestionLag:     defaultAlertsMaxIngestionLag,
+		CheckInterval:       defaultAlertsCheckInterval,
+	}
+}
+
+// Enabled returns whether alerting is enabled.
+func (c Alerts) Enabled() bool {
+	return c.WebhookURL != "" || len(c.Command) != 0
+}
+
+// PopulateDefaults replaces zero-values in the config with default values.
+func (c *Alerts) PopulateDefaults() {
+	if c.MaxIngestionLag == 0 {
+		c.MaxIngestionLag = defaultAlertsMaxIngestionLag
+	}
+	if c.CheckInterval == 0 {
+		c.CheckInterval = defaultAlertsCheckInterval
+	}
+}
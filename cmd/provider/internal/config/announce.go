@@ -3,6 +3,9 @@ package config
 import (
 	"fmt"
 	"net/url"
+	"strings"
+
+	"github.com/libp2p/go-libp2p/core/peer"
 )
 
 // DirectAnnounce configures the target indexers that advertisement announce
@@ -12,8 +15,45 @@ type DirectAnnounce struct {
 	// behavior (false) is to enable sending advertisement announcements via
 	// gossib pubsub.
 	NoPubsubAnnounce bool
+	// GossipMesh makes the provider join the gossipsub mesh of the announce
+	// topic, so that its announcements are also relayed by the peers in the
+	// mesh, and the mesh is reported by "provider gossip".
+	GossipMesh bool `json:",omitempty"`
+	// IndexerPeers is a list of the peer IDs of the indexers expected to
+	// receive announcements over gossip pubsub, whose presence in the announce
+	// topic is reported by "provider gossip".
+	IndexerPeers []string `json:",omitempty"`
+	// TrustedIndexers is a list of the indexers of a private IPNI network,
+	// each given as a multiaddr ending in /p2p/<peer ID>, or as a peer ID if
+	// the indexer connects to the provider. When set, announcements over gossip
+	// pubsub are only sent to these indexers, directly, and their presence in
+	// the announce topic is reported as that of IndexerPeers.
+	TrustedIndexers []string `json:",omitempty"`
 	// URLs is a list of indexer URLs to send HTTP announce messages to.
 	URLs []string
+	// AuthTokens are the bearer tokens sent with HTTP announce messages to
+	// indexers that require authentication, keyed by the scheme and host of
+	// their URLs, e.g. "https://indexer.example.com".
+	AuthTokens map[string]string `json:",omitempty"`
+	// MessageBusURLs is a list of message buses to publish announce messages
+	// to, for indexers that ingest announcements from a queue. Each is an SQS
+	// queue URL with the sqs scheme instead of https, an SNS topic ARN, or a
+	// NATS URL with the subject as path. AWS credentials are read from the
+	// standard AWS environment variables.
+	MessageBusURLs []string `json:",omitempty"`
+	// ReannounceInterval is the interval at which the latest advertisement is
+	// periodically re-announced, so that indexers that missed announcements
+	// catch up. Periodic re-announcement is disabled if zero.
+	ReannounceInterval Duration `json:",omitempty"`
+	// AnnounceDebounce is the quiet period for which announcing a newly
+	// published advertisement is deferred, so that only the last of the
+	// advertisements published in quick succession is announced. Each is
+	// announced as it is published if zero.
+	AnnounceDebounce Duration `json:",omitempty"`
+	// AnnounceMaxDelay is the maximum time for which an announcement is
+	// deferred by AnnounceDebounce while advertisements keep being published.
+	// Announcements may be deferred indefinitely if zero.
+	AnnounceMaxDelay Duration `json:",omitempty"`
 }
 
 // NewDirectAnnounce returns DirectAnnounce with values set to their defaults.
@@ -38,3 +78,36 @@ func (d DirectAnnounce) ParseURLs() ([]*url.URL, error) {
 
 	return urls, nil
 }
+
+// ParseIndexerPeers returns the decoded indexer peer IDs.
+func (d DirectAnnounce) ParseIndexerPeers() ([]peer.ID, error) {
+	ids := make([]peer.ID, len(d.IndexerPeers))
+	var err error
+	for i, p := range d.IndexerPeers {
+		ids[i], err = peer.Decode(p)
+		if err != nil {
+			return nil, fmt.Errorf("bad DirectAnnounce indexer peer %q: %w", p, err)
+		}
+	}
+	return ids, nil
+}
+
+// ParseTrustedIndexers returns the address info of the trusted indexers.
+func (d DirectAnnounce) ParseTrustedIndexers() ([]peer.AddrInfo, error) {
+	infos := make([]peer.AddrInfo, len(d.TrustedIndexers))
+	for i, indexer := range d.TrustedIndexers {
+		var err error
+		if strings.HasPrefix(indexer, "/") {
+			var info *peer.AddrInfo
+			if info, err = peer.AddrInfoFromString(indexer); err == nil {
+				infos[i] = *info
+			}
+		} else {
+			infos[i].ID, err = peer.Decode(indexer)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("bad DirectAnnounce trusted indexer %q: %w", indexer, err)
+		}
+	}
+	return infos, nil
+}
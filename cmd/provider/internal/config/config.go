@@ -5,6 +5,7 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
 
 	"github.com/mitchellh/go-homedir"
 )
@@ -19,6 +20,14 @@ type Config struct {
 	Bootstrap        Bootstrap
 	DirectAnnounce   DirectAnnounce
 	DelegatedRouting DelegatedRouting
+	Metrics          Metrics
+	Tracing          Tracing
+	Alerts           Alerts
+	Logging          Logging
+	Retrieval        Retrieval
+	FilecoinDeals    FilecoinDeals
+	Tenants          Tenants
+	Testing          *Testing `json:",omitempty"`
 }
 
 const (
@@ -75,8 +84,26 @@ func PathRoot() (string, error) {
 	return homedir.Expand(DefaultPathRoot)
 }
 
-// Load reads the json-serialized config at the specified path.
+// Load reads the json-serialized config at the specified path, and overrides
+// its fields with the values of the PROVIDER_* environment variables. See
+// ApplyEnv.
 func Load(filePath string) (*Config, error) {
+	cfg, err := LoadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if err = cfg.ApplyEnv(); err != nil {
+		return nil, err
+	}
+	// Replace any zero-values set by the environment with defaults.
+	cfg.PopulateDefaults()
+	return cfg, nil
+}
+
+// LoadFile reads the json-serialized config at the specified path, without
+// applying overrides from environment variables. Use it to load a config that
+// is going to be saved.
+func LoadFile(filePath string) (*Config, error) {
 	var err error
 	if filePath == "" {
 		filePath, err = Filename("")
@@ -103,6 +130,12 @@ func Load(filePath string) (*Config, error) {
 		ProviderServer:   NewProviderServer(),
 		DirectAnnounce:   NewDirectAnnounce(),
 		DelegatedRouting: NewDelegatedRouting(),
+		Metrics:          NewMetrics(),
+		Tracing:          NewTracing(),
+		Alerts:           NewAlerts(),
+		Logging:          NewLogging(),
+		Retrieval:        NewRetrieval(),
+		FilecoinDeals:    NewFilecoinDeals(),
 	}
 
 	if err = json.NewDecoder(f).Decode(&cfg); err != nil {
@@ -153,10 +186,27 @@ func (c *Config) String() string {
 	return string(b)
 }
 
+// Diff returns the paths of the fields whose values differ between the config
+// and the other config, e.g. "DirectAnnounce.URLs", in the order in which the
+// fields are declared.
+func (c *Config) Diff(other *Config) []string {
+	var changed []string
+	a, b := reflect.ValueOf(c).Elem(), reflect.ValueOf(other).Elem()
+	walkFields(a.Type(), "", "", func(_, path string, index []int) {
+		if !reflect.DeepEqual(fieldByIndex(a, index, false).Interface(), fieldByIndex(b, index, false).Interface()) {
+			changed = append(changed, path)
+		}
+	})
+	return changed
+}
+
 func (c *Config) PopulateDefaults() {
 	c.AdminServer.PopulateDefaults()
 	c.Datastore.PopulateDefaults()
 	c.Ingest.PopulateDefaults()
 	c.ProviderServer.PopulateDefaults()
 	c.DelegatedRouting.PopulateDefaults()
+	c.FilecoinDeals.PopulateDefaults()
+	c.Tracing.PopulateDefaults()
+	c.Alerts.PopulateDefaults()
 }
@@ -1,7 +1,9 @@
 package config
 
 import (
+	"io"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"testing"
 )
@@ -46,3 +48,23 @@ func TestPath(t *testing.T) {
 		t.Fatalf("wrong path %s:", path)
 	}
 }
+
+func TestConfig_Diff(t *testing.T) {
+	a, err := Init(io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := *a
+	b.DirectAnnounce.URLs = []string{"https://example.com/announce"}
+	b.Ingest.HttpPublisher.ListenMultiaddr = "/ip4/0.0.0.0/tcp/3104"
+	b.Logging.Levels = map[string]string{"engine": "debug"}
+
+	changed := a.Diff(&b)
+	want := []string{"Ingest.HttpPublisher.ListenMultiaddr", "DirectAnnounce.URLs", "Logging.Levels"}
+	if !reflect.DeepEqual(changed, want) {
+		t.Fatalf("unexpected changed fields: %v", changed)
+	}
+	if changed = a.Diff(a); len(changed) != 0 {
+		t.Fatalf("unexpected changed fields: %v", changed)
+	}
+}
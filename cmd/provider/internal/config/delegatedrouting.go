@@ -28,9 +28,15 @@ type DelegatedRouting struct {
 	// AdFlushFrequency defines a frequency of a flush operation that is going to be performed on the current chunk. In other words a non empty
 	// current chunk will be converted to an advertisement and published if it's older than this value. Set to 0 to disable.
 	AdFlushFrequency Duration
+	// CompactionInterval defines a frequency at which advertised chunks that hold fewer CIDs than ChunkSize are merged into fewer
+	// advertisements, and the advertisements they supersede are removed. Set to 0 to disable.
+	CompactionInterval Duration
 	// ChunkSize is size of a chunk before it gets advertised to an indexer.
 	// In other words it's a number of CIDs per advertisement
 	ChunkSize int
+	// ChunkMaxBytes is the maximum total size in bytes of the multihashes in a chunk. A chunk gets advertised before it would
+	// exceed this size, even if it has fewer CIDs than ChunkSize. Set to 0 to limit chunks by ChunkSize only.
+	ChunkMaxBytes int
 	// SnapshotSize is the maximum number of records in the Provide payload after which it is considered a snapshot.
 	// Snapshots don't have individual timestamps recorded into the datastore. Instead, timestamps are recorded as a binary blob after processing is done.
 	SnapshotSize int
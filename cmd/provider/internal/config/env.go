@@ -0,0 +1,180 @@
+package config
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// EnvPrefix is the prefix of the environment variables that override config
+// fields.
+const EnvPrefix = "PROVIDER_"
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// ApplyEnv overrides the fields of the config with the values of the
+// corresponding environment variables, if set.
+//
+// The name of the environment variable that corresponds to a field is
+// EnvPrefix followed by the path of the field, where the name of each section
+// and field is converted to upper snake case and separated by "_". For
+// example, Ingest.HttpPublisher.ListenMultiaddr is overridden by
+// PROVIDER_INGEST_HTTP_PUBLISHER_LISTEN_MULTIADDR. See EnvVars for the names
+// of all such environment variables.
+//
+// Lists of strings are specified as comma separated values, and maps as JSON
+// objects. Durations are specified in the format accepted by
+// time.ParseDuration.
+func (c *Config) ApplyEnv() error {
+	return applyEnv(reflect.ValueOf(c).Elem(), EnvPrefix, os.LookupEnv)
+}
+
+// EnvVars returns the names of the environment variables that override config
+// fields, mapped to the path of the field they override.
+func EnvVars() map[string]string {
+	vars := make(map[string]string)
+	walkFields(reflect.TypeOf(Config{}), EnvPrefix, "", func(name, path string, _ []int) {
+		vars[name] = path
+	})
+	return vars
+}
+
+func applyEnv(v reflect.Value, prefix string, lookup func(string) (string, bool)) error {
+	var err error
+	walkFields(v.Type(), prefix, "", func(name, path string, index []int) {
+		value, ok := lookup(name)
+		if !ok || err != nil {
+			return
+		}
+		if perr := setFromEnv(fieldByIndex(v, index, true), value); perr != nil {
+			err = fmt.Errorf("invalid value of environment variable %s for %s: %w", name, path, perr)
+		}
+	})
+	return err
+}
+
+// walkFields calls f with the environment variable name, path and index of
+// each settable field of the given struct type, recursing into nested structs
+// and pointers to structs, such as optional sections.
+func walkFields(t reflect.Type, prefix, path string, f func(name, path string, index []int)) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := prefix + toUpperSnake(field.Name)
+		fieldPath := field.Name
+		if path != "" {
+			fieldPath = path + "." + field.Name
+		}
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType.Kind() == reflect.Struct && !reflect.PointerTo(fieldType).Implements(textUnmarshalerType) {
+			walkFields(fieldType, name+"_", fieldPath, func(name, path string, index []int) {
+				f(name, path, append([]int{i}, index...))
+			})
+			continue
+		}
+		f(name, fieldPath, []int{i})
+	}
+}
+
+// fieldByIndex returns the nested field of v at the given index, as walked by
+// walkFields. Nil pointers to structs along the way are allocated if alloc is
+// true, and are otherwise read as zero values.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if alloc {
+					v.Set(reflect.New(v.Type().Elem()))
+				} else {
+					v = reflect.New(v.Type().Elem())
+				}
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+func setFromEnv(v reflect.Value, value string) error {
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(value))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return json.Unmarshal([]byte(value), v.Addr().Interface())
+		}
+		values := reflect.MakeSlice(v.Type(), 0, 0)
+		for _, s := range strings.Split(value, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				values = reflect.Append(values, reflect.ValueOf(s).Convert(v.Type().Elem()))
+			}
+		}
+		v.Set(values)
+	default:
+		// Replace rather than merge into the current value.
+		fresh := reflect.New(v.Type())
+		if err := json.Unmarshal([]byte(value), fresh.Interface()); err != nil {
+			return err
+		}
+		v.Set(fresh.Elem())
+	}
+	return nil
+}
+
+// toUpperSnake converts a camel case name to upper snake case, keeping
+// acronyms together, including plural ones, e.g. GRPCListenMultiaddr to
+// GRPC_LISTEN_MULTIADDR and URLs to URLS.
+func toUpperSnake(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prevLower := !unicode.IsUpper(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1]) &&
+				!(runes[i+1] == 's' && (i+2 == len(runes) || unicode.IsUpper(runes[i+2])))
+			if prevLower || nextLower {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
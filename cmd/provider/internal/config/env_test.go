@@ -0,0 +1,96 @@
+package config
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyEnv(t *testing.T) {
+	cfg, err := Init(io.Discard)
+	require.NoError(t, err)
+
+	t.Setenv("PROVIDER_INGEST_PUBLISHER_KIND", "libp2phttp")
+	t.Setenv("PROVIDER_INGEST_HTTP_PUBLISHER_LISTEN_MULTIADDR", "/ip4/0.0.0.0/tcp/3104")
+	t.Setenv("PROVIDER_INGEST_PUB_SUB_TOPIC", "/indexer/ingest/testnet")
+	t.Setenv("PROVIDER_INGEST_LINKED_CHUNK_SIZE", "1024")
+	t.Setenv("PROVIDER_INGEST_PURGE_LINK_CACHE", "true")
+	t.Setenv("PROVIDER_DIRECT_ANNOUNCE_URLS", "https://a.example/announce, https://b.example/announce")
+	t.Setenv("PROVIDER_DATASTORE_DIR", "/data/datastore")
+	t.Setenv("PROVIDER_ADMIN_SERVER_GRPC_LISTEN_MULTIADDR", "/ip4/127.0.0.1/tcp/3105")
+	t.Setenv("PROVIDER_ADMIN_SERVER_READ_TIMEOUT", "1m")
+	t.Setenv("PROVIDER_ADMIN_SERVER_RATE_LIMITS", `{"/admin/":{"Rate":2,"Burst":4}}`)
+	require.NoError(t, cfg.ApplyEnv())
+
+	require.Equal(t, Libp2pHttpPublisherKind, cfg.Ingest.PublisherKind)
+	require.Equal(t, "/ip4/0.0.0.0/tcp/3104", cfg.Ingest.HttpPublisher.ListenMultiaddr)
+	require.Equal(t, "/indexer/ingest/testnet", cfg.Ingest.PubSubTopic)
+	require.Equal(t, 1024, cfg.Ingest.LinkedChunkSize)
+	require.True(t, cfg.Ingest.PurgeLinkCache)
+	require.Equal(t, []string{"https://a.example/announce", "https://b.example/announce"}, cfg.DirectAnnounce.URLs)
+	require.Equal(t, "/data/datastore", cfg.Datastore.Dir)
+	require.Equal(t, "/ip4/127.0.0.1/tcp/3105", cfg.AdminServer.GRPCListenMultiaddr)
+	require.Equal(t, Duration(time.Minute), cfg.AdminServer.ReadTimeout)
+	require.Equal(t, map[string]RateLimit{"/admin/": {Rate: 2, Burst: 4}}, cfg.AdminServer.RateLimits)
+
+	t.Setenv("PROVIDER_INGEST_LINKED_CHUNK_SIZE", "fish")
+	require.ErrorContains(t, cfg.ApplyEnv(), "PROVIDER_INGEST_LINKED_CHUNK_SIZE")
+}
+
+func TestApplyEnv_OptionalSection(t *testing.T) {
+	cfg, err := Init(io.Discard)
+	require.NoError(t, err)
+	require.Nil(t, cfg.Testing)
+	require.NotContains(t, cfg.String(), "Testing")
+	other := *cfg
+
+	t.Setenv("PROVIDER_TESTING_ANNOUNCE_FAILURE_RATE", "0.5")
+	t.Setenv("PROVIDER_TESTING_SLOW_LISTER_DELAY", "10s")
+	require.NoError(t, cfg.ApplyEnv())
+	require.Equal(t, &Testing{AnnounceFailureRate: 0.5, SlowListerDelay: Duration(10 * time.Second)}, cfg.Testing)
+	require.Equal(t, []string{"Testing.AnnounceFailureRate", "Testing.SlowListerDelay"}, cfg.Diff(&other))
+}
+
+func TestLoad_AppliesEnv(t *testing.T) {
+	cfg, err := Init(io.Discard)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, cfg.Save(path))
+
+	t.Setenv("PROVIDER_INGEST_PUBLISHER_KIND", "libp2p")
+	t.Setenv("PROVIDER_INGEST_LINK_CACHE_SIZE", "0")
+	loaded, err := Load(path)
+	require.NoError(t, err)
+	require.Equal(t, Libp2pPublisherKind, loaded.Ingest.PublisherKind)
+	// Zero values set by the environment are replaced with defaults.
+	require.Equal(t, defaultLinkCacheSize, loaded.Ingest.LinkCacheSize)
+
+	loaded, err = LoadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, HttpPublisherKind, loaded.Ingest.PublisherKind)
+}
+
+func TestEnvVars(t *testing.T) {
+	vars := EnvVars()
+	require.Equal(t, "Ingest.HttpPublisher.ListenMultiaddr", vars["PROVIDER_INGEST_HTTP_PUBLISHER_LISTEN_MULTIADDR"])
+	require.Equal(t, "Identity.PeerID", vars["PROVIDER_IDENTITY_PEER_ID"])
+	require.Equal(t, "AdminServer.TLSCertPath", vars["PROVIDER_ADMIN_SERVER_TLS_CERT_PATH"])
+	require.Equal(t, "Ingest.SyncPolicy.Except", vars["PROVIDER_INGEST_SYNC_POLICY_EXCEPT"])
+	require.NotContains(t, vars, EnvDir)
+}
+
+func TestToUpperSnake(t *testing.T) {
+	for name, want := range map[string]string{
+		"PeerID":              "PEER_ID",
+		"URLs":                "URLS",
+		"GRPCListenMultiaddr": "GRPC_LISTEN_MULTIADDR",
+		"PubSubTopic":         "PUB_SUB_TOPIC",
+		"NoPubsubAnnounce":    "NO_PUBSUB_ANNOUNCE",
+		"TLSCertPath":         "TLS_CERT_PATH",
+	} {
+		require.Equal(t, want, toUpperSnake(name), name)
+	}
+}
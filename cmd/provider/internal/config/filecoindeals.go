@@ -0,0 +1,49 @@
+package config
+
+import "time"
+
+const defaultFilecoinDealsSyncInterval = Duration(10 * time.Minute)
+
+// FilecoinDeals configures the advertisement of the pieces of the active
+// storage deals of a Filecoin storage provider. If MarketAPIURL is specified,
+// the daemon periodically lists the deals via the market API, such as that of
+// lotus-miner or boost, advertises the pieces of active deals, and advertises
+// the removal of pieces once their deals expire or are slashed.
+type FilecoinDeals struct {
+	// MarketAPIURL is the URL of the JSON-RPC market API of the storage
+	// provider, e.g. http://127.0.0.1:2345/rpc/v0. Advertising deals is
+	// disabled if it is empty.
+	MarketAPIURL string `json:",omitempty"`
+	// MarketAPIToken is the token with which requests to the market API are
+	// authorized.
+	MarketAPIToken string `json:",omitempty"`
+	// PieceURL is the URL from which pieces are fetched to list their
+	// multihashes, in which {pieceCid} stands for the piece CID, e.g.
+	// http://127.0.0.1:7777/piece/{pieceCid} of booster-http.
+	PieceURL string `json:",omitempty"`
+	// FastRetrieval declares whether unsealed copies of pieces are available
+	// for fast retrieval.
+	FastRetrieval bool
+	// SyncInterval is the interval at which deals are listed.
+	SyncInterval Duration
+}
+
+// NewFilecoinDeals instantiates a new FilecoinDeals config with default values.
+func NewFilecoinDeals() FilecoinDeals {
+	return FilecoinDeals{
+		FastRetrieval: true,
+		SyncInterval:  defaultFilecoinDealsSyncInterval,
+	}
+}
+
+// Enabled returns whether advertising deals is enabled.
+func (c FilecoinDeals) Enabled() bool {
+	return c.MarketAPIURL != ""
+}
+
+// PopulateDefaults replaces zero-values in the config with default values.
+func (c *FilecoinDeals) PopulateDefaults() {
+	if c.SyncInterval == 0 {
+		c.SyncInterval = defaultFilecoinDealsSyncInterval
+	}
+}
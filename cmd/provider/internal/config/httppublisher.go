@@ -14,6 +14,12 @@ type HttpPublisher struct {
 	// If not specified, the ListenMultiaddr is used with HttpPubliser, the
 	// libp2p host address is used with Libp2pPublisher and both are used with
 	// Libp2pHttpPublisher.
+	//
+	// An http or https URL, such as "https://example.com", may be given
+	// instead of a multiaddr, and is converted to the equivalent multiaddr.
+	// This is useful when the publisher is fronted by a reverse proxy serving
+	// a domain. With HttpPublisher, the address must be an HTTP address, such
+	// as "/dns4/example.com/tcp/443/https".
 	AnnounceMultiaddr string
 	// ListenMultiaddr is the address of the interface to listen for HTTP
 	// requests for advertisements. Set this to "" to disable serving plain
@@ -5,7 +5,11 @@ const (
 	defaultLinkCacheSize = 1024
 	// Multihashes are 128 bytes so 16384 results in 0.25MiB chunk when full.
 	defaultLinkedChunkSize = 16384
-	defaultPubSubTopic     = "/indexer/ingest/mainnet"
+	// Keep up to 64MiB of served chunks in memory.
+	defaultLinkMemoryCacheSize = 64 << 20
+	// Read ahead up to one full chunk of multihashes while chunking.
+	defaultListerPrefetch = defaultLinkedChunkSize
+	defaultPubSubTopic    = "/indexer/ingest/mainnet"
 )
 
 type PublisherKind string
@@ -29,8 +33,55 @@ type Ingest struct {
 	LinkedChunkSize int
 	// PubSubTopic used to advertise ingestion announcements.
 	PubSubTopic string
+	// Network is the name of the private IPNI network to announce to, if any.
+	// Announcements are then published on the pubsub topic of the network,
+	// /indexer/ingest/<Network>, instead of PubSubTopic, such that only the
+	// indexers of the network receive them. See also
+	// DirectAnnounce.TrustedIndexers and DirectAnnounce.AuthTokens.
+	Network string `json:",omitempty"`
 	// PurgeLinkCache tells whether to purge the link cache on daemon startup.
 	PurgeLinkCache bool
+	// LinkMemoryCacheSize is the maximum total size in bytes of the chunks
+	// kept in memory as they are served to indexers, so that chunks synced by
+	// many indexers are not read from the link cache every time. No chunks
+	// are kept in memory if 0.
+	LinkMemoryCacheSize int64
+	// LazyEntries tells whether to generate the chunks of advertised entries
+	// only once an indexer first requests them, rather than when publishing.
+	// The multihashes are still listed when publishing to compute the link to
+	// the entries.
+	LazyEntries bool
+	// SortEntries tells whether to sort and deduplicate the multihashes of
+	// each context ID before chunking them into advertised entries, so that
+	// the same multihashes always result in the same entries CID. Only change
+	// this before any advertisements are published, as entries published
+	// otherwise may no longer be regenerated for indexers.
+	SortEntries bool
+	// SortedChunks tells whether to chunk the multihashes of each context ID
+	// into runs of exactly LinkedChunkSize sorted multihashes, except for the
+	// root chunk, so that indexers can binary search each chunk. Multihashes
+	// are then sorted and deduplicated regardless of SortEntries, and the same
+	// caution as for changing SortEntries applies.
+	SortedChunks bool `json:",omitempty"`
+	// ListerPrefetch is the number of multihashes listed ahead of those being
+	// chunked, so that listing multihashes overlaps with encoding and storing
+	// chunks. Multihashes are listed as they are chunked if 0.
+	ListerPrefetch int
+	// MaxAdMultihashes is the maximum number of multihashes advertised by a
+	// single advertisement. Content of more multihashes is split across
+	// several advertisements under context IDs derived from its context ID,
+	// which bounds the time indexers take to ingest each advertisement.
+	// Content is never split if 0.
+	MaxAdMultihashes int
+	// StaticCarDir, if set, is the directory to which each published
+	// advertisement is additionally written as a CAR file with its entries,
+	// along with a signed head file, for serving from a static HTTP server or
+	// CDN.
+	StaticCarDir string `json:",omitempty"`
+	// AdSequence tells whether to embed a sequence number and a timestamp in
+	// the metadata of each advertisement, signed along with it, so that
+	// downstream systems can order advertisements without walking the chain.
+	AdSequence bool `json:",omitempty"`
 
 	// HttpPublisher configures the dagsync ipnisync publisher.
 	HttpPublisher HttpPublisher
@@ -46,17 +97,23 @@ type Ingest struct {
 	// SyncPolicy configures which indexers are allowed to sync advertisements
 	// with this provider over a data transfer session.
 	SyncPolicy Policy
+
+	// Retention configures the pruning of old advertisements.
+	Retention Retention
 }
 
 // NewIngest instantiates a new Ingest configuration with default values.
 func NewIngest() Ingest {
 	return Ingest{
-		LinkCacheSize:   defaultLinkCacheSize,
-		LinkedChunkSize: defaultLinkedChunkSize,
-		PubSubTopic:     defaultPubSubTopic,
-		HttpPublisher:   NewHttpPublisher(),
-		PublisherKind:   HttpPublisherKind,
-		SyncPolicy:      NewPolicy(),
+		LinkCacheSize:       defaultLinkCacheSize,
+		LinkedChunkSize:     defaultLinkedChunkSize,
+		LinkMemoryCacheSize: defaultLinkMemoryCacheSize,
+		ListerPrefetch:      defaultListerPrefetch,
+		PubSubTopic:         defaultPubSubTopic,
+		HttpPublisher:       NewHttpPublisher(),
+		PublisherKind:       HttpPublisherKind,
+		SyncPolicy:          NewPolicy(),
+		Retention:           NewRetention(),
 	}
 }
 
@@ -71,4 +128,5 @@ func (c *Ingest) PopulateDefaults() {
 	if c.PubSubTopic == "" {
 		c.PubSubTopic = defaultPubSubTopic
 	}
+	c.Retention.PopulateDefaults()
 }
@@ -27,6 +27,12 @@ func InitWithIdentity(identity Identity) (*Config, error) {
 		ProviderServer:   NewProviderServer(),
 		AdminServer:      NewAdminServer(),
 		DelegatedRouting: NewDelegatedRouting(),
+		Metrics:          NewMetrics(),
+		Tracing:          NewTracing(),
+		Alerts:           NewAlerts(),
+		Logging:          NewLogging(),
+		Retrieval:        NewRetrieval(),
+		FilecoinDeals:    NewFilecoinDeals(),
 	}, nil
 }
 
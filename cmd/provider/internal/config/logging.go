@@ -0,0 +1,16 @@
+package config
+
+// Logging configures the log levels of the logging subsystems of the provider.
+type Logging struct {
+	// Level is the log level of all subsystems, e.g. "info" or "debug". If
+	// not specified, the level given to the daemon command is used.
+	Level string `json:",omitempty"`
+	// Levels maps the names of logging subsystems to their log level, which
+	// overrides Level for those subsystems.
+	Levels map[string]string `json:",omitempty"`
+}
+
+// NewLogging returns Logging with values set to their defaults.
+func NewLogging() Logging {
+	return Logging{}
+}
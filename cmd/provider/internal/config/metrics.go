@@ -0,0 +1,39 @@
+package config
+
+import (
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// Metrics configures the exposition of metrics in Prometheus format.
+type Metrics struct {
+	// Enabled, if true, exposes metrics at the "/metrics" path.
+	Enabled bool
+	// ListenMultiaddr is the address on which to expose metrics. If not
+	// specified, metrics are exposed by the admin server.
+	ListenMultiaddr string `json:",omitempty"`
+}
+
+// NewMetrics instantiates a new Metrics config with default values.
+func NewMetrics() Metrics {
+	return Metrics{
+		Enabled: true,
+	}
+}
+
+// ListenNetAddr returns the net address on which to expose metrics, or the
+// empty string if metrics are exposed by the admin server.
+func (m *Metrics) ListenNetAddr() (string, error) {
+	if m.ListenMultiaddr == "" {
+		return "", nil
+	}
+	maddr, err := multiaddr.NewMultiaddr(m.ListenMultiaddr)
+	if err != nil {
+		return "", err
+	}
+	netAddr, err := manet.ToNetAddr(maddr)
+	if err != nil {
+		return "", err
+	}
+	return netAddr.String(), nil
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Preset names a set of configuration values suited to a particular kind of
+// deployment.
+type Preset string
+
+const (
+	// FilecoinSPPreset configures a provider run alongside a Filecoin storage
+	// provider. Advertisements are served over both plain HTTP and libp2p, and
+	// are announced over gossip pubsub as well as directly to cid.contact.
+	FilecoinSPPreset Preset = "filecoin-sp"
+	// IpfsNodePreset configures a provider that advertises content on behalf of
+	// an IPFS node, which provides its CIDs via the delegated routing server.
+	IpfsNodePreset Preset = "ipfs-node"
+	// HttpOnlyPreset configures a provider that serves advertisements over
+	// plain HTTP only, and announces them directly to cid.contact over HTTP
+	// without using gossip pubsub.
+	HttpOnlyPreset Preset = "http-only"
+)
+
+const (
+	// DefaultAnnounceURL is the indexer URL that presets send HTTP announce
+	// messages to.
+	DefaultAnnounceURL = "https://cid.contact/ingest/announce"
+
+	defaultDelegatedRoutingListenMultiaddr = "/ip4/127.0.0.1/tcp/50617"
+	// Filecoin storage providers advertise large amounts of content, so keep
+	// more chunks cached.
+	filecoinSPLinkCacheSize = 4 * defaultLinkCacheSize
+)
+
+// Presets lists the names of all known presets.
+var Presets = []Preset{FilecoinSPPreset, IpfsNodePreset, HttpOnlyPreset}
+
+// ParsePreset returns the preset with the given name, or an error if there is
+// no such preset.
+func ParsePreset(name string) (Preset, error) {
+	for _, p := range Presets {
+		if string(p) == name {
+			return p, nil
+		}
+	}
+	names := make([]string, len(Presets))
+	for i, p := range Presets {
+		names[i] = string(p)
+	}
+	return "", fmt.Errorf("unknown preset %q, must be one of: %s", name, strings.Join(names, ", "))
+}
+
+// ApplyPreset overwrites the publisher, announce, datastore and delegated
+// routing settings in the config with the values of the given preset. The
+// identity and all other settings are left unchanged.
+func (c *Config) ApplyPreset(p Preset) error {
+	c.Datastore = NewDatastore()
+	c.Ingest.PubSubTopic = defaultPubSubTopic
+	c.Ingest.HttpPublisher = NewHttpPublisher()
+	c.DirectAnnounce = DirectAnnounce{
+		URLs: []string{DefaultAnnounceURL},
+	}
+	c.DelegatedRouting.ListenMultiaddr = ""
+
+	switch p {
+	case FilecoinSPPreset:
+		c.Ingest.PublisherKind = Libp2pHttpPublisherKind
+		c.Ingest.LinkCacheSize = filecoinSPLinkCacheSize
+	case IpfsNodePreset:
+		c.Ingest.PublisherKind = Libp2pPublisherKind
+		c.DelegatedRouting.ListenMultiaddr = defaultDelegatedRoutingListenMultiaddr
+	case HttpOnlyPreset:
+		c.Ingest.PublisherKind = HttpPublisherKind
+		c.DirectAnnounce.NoPubsubAnnounce = true
+	default:
+		return fmt.Errorf("unknown preset %q", p)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"io"
+	"testing"
+)
+
+func TestApplyPreset(t *testing.T) {
+	for _, preset := range Presets {
+		t.Run(string(preset), func(t *testing.T) {
+			cfg, err := Init(io.Discard)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = cfg.ApplyPreset(preset); err != nil {
+				t.Fatal(err)
+			}
+			if len(cfg.DirectAnnounce.URLs) != 1 || cfg.DirectAnnounce.URLs[0] != DefaultAnnounceURL {
+				t.Fatal("unexpected announce URLs:", cfg.DirectAnnounce.URLs)
+			}
+			if _, err = cfg.DirectAnnounce.ParseURLs(); err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Datastore != NewDatastore() {
+				t.Fatal("unexpected datastore config:", cfg.Datastore)
+			}
+
+			var wantKind PublisherKind
+			switch preset {
+			case FilecoinSPPreset:
+				wantKind = Libp2pHttpPublisherKind
+			case IpfsNodePreset:
+				wantKind = Libp2pPublisherKind
+				if cfg.DelegatedRouting.ListenMultiaddr == "" {
+					t.Fatal("delegated routing not enabled")
+				}
+				if _, err = cfg.DelegatedRouting.ListenNetAddr(); err != nil {
+					t.Fatal(err)
+				}
+			case HttpOnlyPreset:
+				wantKind = HttpPublisherKind
+				if !cfg.DirectAnnounce.NoPubsubAnnounce {
+					t.Fatal("pubsub announce not disabled")
+				}
+			}
+			if cfg.Ingest.PublisherKind != wantKind {
+				t.Fatal("unexpected publisher kind:", cfg.Ingest.PublisherKind)
+			}
+		})
+	}
+}
+
+func TestParsePreset(t *testing.T) {
+	preset, err := ParsePreset("http-only")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preset != HttpOnlyPreset {
+		t.Fatal("unexpected preset:", preset)
+	}
+	if _, err = ParsePreset("fish"); err == nil {
+		t.Fatal("expected error for unknown preset")
+	}
+}
@@ -6,6 +6,22 @@ type ProviderServer struct {
 	// RetrievalMultiaddrs are the addresses to advertise for data retrieval.
 	// Defaults to the provider's libp2p host listen addresses.
 	RetrievalMultiaddrs []string
+	// MaxRetrievalAddrs is the maximum number of retrieval addresses put into
+	// an advertisement, after leaving out duplicate and private addresses.
+	// Zero means no limit other than the 32 addresses accepted by indexers.
+	MaxRetrievalAddrs int `json:",omitempty"`
+	// AllowPrivateRetrievalAddrs puts loopback, link-local and private
+	// network retrieval addresses, such as 127.0.0.1 and docker-internal
+	// addresses, into advertisements. They are left out by default, since
+	// retrieval clients handed them by indexers cannot reach them. Set it
+	// when retrieval clients are on the same host or network as the provider.
+	AllowPrivateRetrievalAddrs bool `json:",omitempty"`
+	// ExternalRetrievalAddrs advertises the external addresses of the libp2p
+	// host, as observed by its peers or mapped in the NAT device, instead of
+	// its listen addresses, unless the retrieval addresses are set by
+	// RetrievalMultiaddrs or Retrieval.HttpGatewayURL. Advertised content is
+	// republished whenever the external addresses change.
+	ExternalRetrievalAddrs bool `json:",omitempty"`
 }
 
 // NewProviderServer instantiates a new ProviderServer config with default values.
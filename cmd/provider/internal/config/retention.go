@@ -0,0 +1,43 @@
+package config
+
+import "time"
+
+const defaultRetentionPruneInterval = Duration(time.Hour)
+
+// Retention configures the pruning of old advertisements, along with their
+// cached entries, from the datastore. Advertisements that are neither among
+// the latest MaxAds advertisements, nor published within MaxAge, are pruned
+// every PruneInterval. Pruning is disabled if neither MaxAds nor MaxAge is
+// specified.
+//
+// Indexers are unable to sync advertisements past the pruned part of the
+// chain, so only prune the chain when all indexers of interest have ingested
+// the advertisements that are pruned.
+type Retention struct {
+	// MaxAds is the number of latest advertisements to keep.
+	MaxAds int `json:",omitempty"`
+	// MaxAge is the age up to which advertisements are kept.
+	MaxAge Duration `json:",omitempty"`
+	// PruneInterval is the interval at which the advertisement chain is
+	// pruned.
+	PruneInterval Duration
+}
+
+// NewRetention instantiates a new Retention config with default values.
+func NewRetention() Retention {
+	return Retention{
+		PruneInterval: defaultRetentionPruneInterval,
+	}
+}
+
+// Enabled returns whether pruning is enabled.
+func (c Retention) Enabled() bool {
+	return c.MaxAds != 0 || c.MaxAge != 0
+}
+
+// PopulateDefaults replaces zero-values in the config with default values.
+func (c *Retention) PopulateDefaults() {
+	if c.PruneInterval == 0 {
+		c.PruneInterval = defaultRetentionPruneInterval
+	}
+}
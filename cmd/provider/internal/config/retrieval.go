@@ -0,0 +1,142 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/index-provider/cardatatransfer"
+	"github.com/ipni/index-provider/httpgateway"
+	"github.com/multiformats/go-multiaddr"
+)
+
+const (
+	// PieceCIDFromContextID is the PieceCID template that stands for the CID
+	// that wraps the context ID of an advertisement with the identity hash, as
+	// the built-in CAR data transfer expects.
+	PieceCIDFromContextID = "{contextID}"
+	// PieceCIDContextIDCid is the PieceCID template that stands for the
+	// context ID of an advertisement decoded as a binary CID, for providers
+	// that use piece CIDs as context IDs.
+	PieceCIDContextIDCid = "{contextIDCid}"
+)
+
+// Retrieval configures the retrieval metadata of advertisements created by the
+// daemon without metadata being specified, e.g. for CARs imported, or contexts
+// advertised, via the admin API without metadata. Such advertisements declare
+// that their content is retrievable over each enabled transport.
+type Retrieval struct {
+	// Graphsync configures retrieval over graphsync filecoinv1.
+	Graphsync GraphsyncRetrieval
+	// Bitswap enables retrieval over bitswap.
+	Bitswap bool `json:",omitempty"`
+	// HttpGatewayURL, if specified, enables retrieval from the IPFS trustless
+	// HTTP gateway at this URL. The multiaddr of the URL is added to the
+	// retrieval addresses of the provider.
+	HttpGatewayURL string `json:",omitempty"`
+	// SelfCheck configures checking that the content of advertisements
+	// published by the daemon can be retrieved.
+	SelfCheck RetrievalSelfCheck
+}
+
+// RetrievalSelfCheck configures checking that the content of each
+// advertisement published by the daemon can be retrieved, by sampling a few of
+// its multihashes and retrieving them from each of its addresses over each of
+// its retrieval protocols. Failures are logged and counted in metrics.
+type RetrievalSelfCheck struct {
+	// Enabled enables the self-check.
+	Enabled bool
+	// Samples is the number of multihashes of each advertisement that are
+	// retrieved. Defaults to 3 if 0.
+	Samples int `json:",omitempty"`
+	// Timeout is the maximum time that retrieving a multihash from one
+	// address may take. Defaults to 30s if 0.
+	Timeout Duration `json:",omitempty"`
+}
+
+// GraphsyncRetrieval configures retrieval over graphsync filecoinv1.
+type GraphsyncRetrieval struct {
+	// Enabled enables retrieval over graphsync filecoinv1.
+	Enabled bool
+	// PieceCID is the piece CID of the content of advertisements. It is either
+	// a CID, or one of the templates PieceCIDFromContextID and
+	// PieceCIDContextIDCid that derive the piece CID from the context ID. If
+	// not specified, PieceCIDFromContextID is used.
+	PieceCID string `json:",omitempty"`
+	// VerifiedDeal declares whether the deal of the content is verified.
+	VerifiedDeal bool
+	// FastRetrieval declares whether an unsealed copy of the content is
+	// available for fast retrieval.
+	FastRetrieval bool
+}
+
+// NewRetrieval returns Retrieval with values set to their defaults.
+func NewRetrieval() Retrieval {
+	return Retrieval{
+		Graphsync: GraphsyncRetrieval{
+			VerifiedDeal:  true,
+			FastRetrieval: true,
+		},
+	}
+}
+
+// Enabled returns whether retrieval over any transport is enabled.
+func (r Retrieval) Enabled() bool {
+	return r.Graphsync.Enabled || r.Bitswap || r.HttpGatewayURL != ""
+}
+
+// Metadata returns the retrieval metadata of the advertisement with the given
+// context ID, listing each enabled transport.
+func (r Retrieval) Metadata(contextID []byte) (metadata.Metadata, error) {
+	var protocols []metadata.Protocol
+	if r.Graphsync.Enabled {
+		pieceCid, err := r.Graphsync.pieceCid(contextID)
+		if err != nil {
+			return metadata.Metadata{}, err
+		}
+		protocols = append(protocols, &metadata.GraphsyncFilecoinV1{
+			PieceCID:      pieceCid,
+			VerifiedDeal:  r.Graphsync.VerifiedDeal,
+			FastRetrieval: r.Graphsync.FastRetrieval,
+		})
+	}
+	if r.Bitswap {
+		protocols = append(protocols, metadata.Bitswap{})
+	}
+	if r.HttpGatewayURL != "" {
+		protocols = append(protocols, metadata.IpfsGatewayHttp{})
+	}
+	if len(protocols) == 0 {
+		return metadata.Metadata{}, errors.New("no retrieval transport is enabled")
+	}
+	return metadata.Default.New(protocols...), nil
+}
+
+// HttpGatewayMultiaddr returns the multiaddr of HttpGatewayURL, or nil if it is
+// not specified.
+func (r Retrieval) HttpGatewayMultiaddr() (multiaddr.Multiaddr, error) {
+	if r.HttpGatewayURL == "" {
+		return nil, nil
+	}
+	return httpgateway.Multiaddr(r.HttpGatewayURL)
+}
+
+func (g GraphsyncRetrieval) pieceCid(contextID []byte) (cid.Cid, error) {
+	switch g.PieceCID {
+	case "", PieceCIDFromContextID:
+		tp, err := cardatatransfer.TransportFromContextID(contextID)
+		if err != nil {
+			return cid.Undef, err
+		}
+		return tp.(*metadata.GraphsyncFilecoinV1).PieceCID, nil
+	case PieceCIDContextIDCid:
+		_, c, err := cid.CidFromBytes(contextID)
+		if err != nil {
+			return cid.Undef, fmt.Errorf("context ID is not a CID: %w", err)
+		}
+		return c, nil
+	default:
+		return cid.Decode(g.PieceCID)
+	}
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/index-provider/cardatatransfer"
+	"github.com/multiformats/go-multicodec"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetrieval_Metadata(t *testing.T) {
+	contextID := []byte("fish")
+
+	r := NewRetrieval()
+	require.False(t, r.Enabled())
+	_, err := r.Metadata(contextID)
+	require.ErrorContains(t, err, "no retrieval transport is enabled")
+
+	r.Graphsync.Enabled = true
+	r.Bitswap = true
+	r.HttpGatewayURL = "https://gateway.example"
+	require.True(t, r.Enabled())
+	md, err := r.Metadata(contextID)
+	require.NoError(t, err)
+	require.Equal(t, []multicodec.Code{
+		multicodec.TransportBitswap,
+		multicodec.TransportGraphsyncFilecoinv1,
+		multicodec.TransportIpfsGatewayHttp,
+	}, md.Protocols())
+	// By default, the metadata matches that expected by the CAR data transfer.
+	tp, err := cardatatransfer.TransportFromContextID(contextID)
+	require.NoError(t, err)
+	require.Equal(t, tp, md.Get(multicodec.TransportGraphsyncFilecoinv1))
+
+	pieceCid := cid.MustParse("baga6ea4seaqjtovkwk4myyzj56eztkh5pzsk5upksan6f5outesy62bsvl4dsha")
+	r = Retrieval{Graphsync: GraphsyncRetrieval{Enabled: true, PieceCID: PieceCIDContextIDCid}}
+	md, err = r.Metadata(pieceCid.Bytes())
+	require.NoError(t, err)
+	require.Equal(t, &metadata.GraphsyncFilecoinV1{PieceCID: pieceCid}, md.Get(multicodec.TransportGraphsyncFilecoinv1))
+	_, err = r.Metadata(contextID)
+	require.ErrorContains(t, err, "context ID is not a CID")
+
+	r.Graphsync.PieceCID = pieceCid.String()
+	md, err = r.Metadata(contextID)
+	require.NoError(t, err)
+	require.Equal(t, &metadata.GraphsyncFilecoinV1{PieceCID: pieceCid}, md.Get(multicodec.TransportGraphsyncFilecoinv1))
+}
+
+func TestRetrieval_HttpGatewayMultiaddr(t *testing.T) {
+	addr, err := Retrieval{}.HttpGatewayMultiaddr()
+	require.NoError(t, err)
+	require.Nil(t, addr)
+
+	addr, err = Retrieval{HttpGatewayURL: "https://gateway.example"}.HttpGatewayMultiaddr()
+	require.NoError(t, err)
+	require.Equal(t, "/dns/gateway.example/https", addr.String())
+}
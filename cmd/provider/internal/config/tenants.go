@@ -0,0 +1,54 @@
+package config
+
+import (
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// Tenants configures the hosting of multiple provider identities, each of
+// which publishes its own chain of advertisements under its own key. Tenants
+// are created and deleted via the admin API, and their advertisements are
+// served over HTTP at ListenMultiaddr. Multi-tenant hosting is disabled if
+// ListenMultiaddr is not specified.
+type Tenants struct {
+	// ListenMultiaddr is the address of the interface to listen for HTTP
+	// requests for the advertisements of tenants.
+	ListenMultiaddr string `json:",omitempty"`
+	// PublisherURL is the external HTTP URL at which the advertisements of
+	// tenants are served, as put into announcements. If not specified, the
+	// URL of ListenMultiaddr is used, which may not be reachable by indexers.
+	PublisherURL string `json:",omitempty"`
+}
+
+// Enabled returns whether multi-tenant hosting is enabled.
+func (c Tenants) Enabled() bool {
+	return c.ListenMultiaddr != ""
+}
+
+// ListenNetAddr returns the net address of ListenMultiaddr.
+func (c Tenants) ListenNetAddr() (string, error) {
+	maddr, err := multiaddr.NewMultiaddr(c.ListenMultiaddr)
+	if err != nil {
+		return "", err
+	}
+	httpMultiaddr, _ := multiaddr.NewMultiaddr("/http")
+	maddr = maddr.Decapsulate(httpMultiaddr)
+
+	netAddr, err := manet.ToNetAddr(maddr)
+	if err != nil {
+		return "", err
+	}
+	return netAddr.String(), nil
+}
+
+// URL returns PublisherURL, or the URL of ListenMultiaddr if not specified.
+func (c Tenants) URL() (string, error) {
+	if c.PublisherURL != "" {
+		return c.PublisherURL, nil
+	}
+	netAddr, err := c.ListenNetAddr()
+	if err != nil {
+		return "", err
+	}
+	return "http://" + netAddr, nil
+}
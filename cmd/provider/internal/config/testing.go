@@ -0,0 +1,22 @@
+package config
+
+// Testing configures failures that the daemon injects at random, so that
+// operators can check that their alerting and retries handle them in staging
+// deployments. Each rate is the probability, between 0 and 1, that the
+// failure is injected into each operation.
+//
+// The section is left out of the config unless set, e.g. via the
+// PROVIDER_TESTING_* environment variables, and must not be set in
+// production.
+type Testing struct {
+	// AnnounceFailureRate is the rate at which sending an announcement to
+	// each target fails, without it being sent.
+	AnnounceFailureRate float64 `json:",omitempty"`
+	// SlowListerRate is the rate at which listing the multihashes of a
+	// context ID is delayed by SlowListerDelay.
+	SlowListerRate  float64  `json:",omitempty"`
+	SlowListerDelay Duration `json:",omitempty"`
+	// DatastoreWriteFailureRate is the rate at which writes to the datastore
+	// of the engine fail.
+	DatastoreWriteFailureRate float64 `json:",omitempty"`
+}
@@ -0,0 +1,34 @@
+package config
+
+const defaultTracingSampleRatio = 1.0
+
+// Tracing configures the export of OpenTelemetry traces of the publishing of
+// advertisements, from the admin API and the suppliers down to the
+// announcement of advertisements, via OTLP over HTTP.
+type Tracing struct {
+	// Enabled, if true, exports traces.
+	Enabled bool
+	// Endpoint is the URL of the OTLP/HTTP traces endpoint of the collector to
+	// export traces to, e.g. http://localhost:4318/v1/traces. If not
+	// specified, the standard OTEL_EXPORTER_OTLP_ENDPOINT and
+	// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT environment variables apply.
+	Endpoint string `json:",omitempty"`
+	// SampleRatio is the fraction of traces that are sampled, greater than 0
+	// and at most 1. Traces continued from a client are sampled if the client
+	// sampled them.
+	SampleRatio float64
+}
+
+// NewTracing instantiates a new Tracing config with default values.
+func NewTracing() Tracing {
+	return Tracing{
+		SampleRatio: defaultTracingSampleRatio,
+	}
+}
+
+// PopulateDefaults replaces zero-values in the config with default values.
+func (c *Tracing) PopulateDefaults() {
+	if c.SampleRatio == 0 {
+		c.SampleRatio = defaultTracingSampleRatio
+	}
+}
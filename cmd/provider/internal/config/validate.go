@@ -0,0 +1,370 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log/v2"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/ipni/index-provider/msgbus"
+	ic "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// ValidationError lists the problems found in a config by Config.Validate.
+type ValidationError struct {
+	// Problems describes each problem, prefixed with the path of the field
+	// that has the problem.
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Problems) == 1 {
+		return "invalid config: " + e.Problems[0]
+	}
+	return fmt.Sprintf("invalid config: %d problems:\n  %s", len(e.Problems), strings.Join(e.Problems, "\n  "))
+}
+
+// Validate checks the syntax of the addresses, URLs and peer IDs in the
+// config, and that its settings are consistent with each other, without
+// accessing the network or the file system other than to load TLS
+// certificates. If the config is invalid, a *ValidationError that lists all
+// problems is returned.
+func (c *Config) Validate() error {
+	var v validator
+
+	c.validateIdentity(&v)
+
+	if c.Datastore.Type != defaultDatastoreType {
+		v.addf("Datastore.Type", "unsupported datastore type %q; only %q is supported", c.Datastore.Type, defaultDatastoreType)
+	}
+	if c.Datastore.Dir == "" {
+		v.addf("Datastore.Dir", "must be specified")
+	}
+
+	switch c.Ingest.PublisherKind {
+	case "", HttpPublisherKind, Libp2pPublisherKind, Libp2pHttpPublisherKind:
+	default:
+		v.addf("Ingest.PublisherKind", "unknown publisher kind %q; must be one of %q, %q or %q",
+			c.Ingest.PublisherKind, HttpPublisherKind, Libp2pPublisherKind, Libp2pHttpPublisherKind)
+	}
+	if c.Ingest.PublisherKind == HttpPublisherKind && c.Ingest.HttpPublisher.ListenMultiaddr == "" && !c.AdminServer.HostPublisher {
+		v.addf("Ingest.HttpPublisher.ListenMultiaddr", "must be specified when Ingest.PublisherKind is %q; use %q to serve advertisements over libp2p only",
+			HttpPublisherKind, Libp2pPublisherKind)
+	}
+	if _, err := c.Ingest.HttpPublisher.ListenNetAddr(); err != nil {
+		v.addf("Ingest.HttpPublisher.ListenMultiaddr", "%v", err)
+	}
+	if c.Ingest.HttpPublisher.AnnounceMultiaddr != "" {
+		maddr, err := engine.ParseAnnounceAddr(c.Ingest.HttpPublisher.AnnounceMultiaddr)
+		if err != nil {
+			v.addf("Ingest.HttpPublisher.AnnounceMultiaddr", "invalid address %q: %v", c.Ingest.HttpPublisher.AnnounceMultiaddr, err)
+		} else if c.Ingest.PublisherKind == HttpPublisherKind && !engine.IsHttpAddr(maddr) {
+			v.addf("Ingest.HttpPublisher.AnnounceMultiaddr", "%s is not an HTTP address, as required when Ingest.PublisherKind is %q; use e.g. \"/dns4/example.com/tcp/443/https\" or \"https://example.com\"",
+				maddr, HttpPublisherKind)
+		}
+	}
+	if c.Ingest.LinkCacheSize < 0 {
+		v.addf("Ingest.LinkCacheSize", "must not be negative")
+	}
+	if c.Ingest.LinkedChunkSize < 0 {
+		v.addf("Ingest.LinkedChunkSize", "must not be negative")
+	}
+	if c.Ingest.LinkMemoryCacheSize < 0 {
+		v.addf("Ingest.LinkMemoryCacheSize", "must not be negative")
+	}
+	if c.Ingest.ListerPrefetch < 0 {
+		v.addf("Ingest.ListerPrefetch", "must not be negative")
+	}
+	if c.Ingest.MaxAdMultihashes < 0 {
+		v.addf("Ingest.MaxAdMultihashes", "must not be negative")
+	}
+	if c.Ingest.PubSubTopic == "" {
+		v.addf("Ingest.PubSubTopic", "must be specified")
+	}
+	if strings.Contains(c.Ingest.Network, "/") {
+		v.addf("Ingest.Network", "invalid network name %q: must not contain '/'", c.Ingest.Network)
+	}
+	for _, p := range c.Ingest.SyncPolicy.Except {
+		v.checkPeerID("Ingest.SyncPolicy.Except", p)
+	}
+	if c.Ingest.Retention.MaxAds < 0 {
+		v.addf("Ingest.Retention.MaxAds", "must not be negative")
+	}
+	if c.Ingest.Retention.MaxAge < 0 {
+		v.addf("Ingest.Retention.MaxAge", "must not be negative")
+	}
+	if c.Ingest.Retention.Enabled() && c.Ingest.Retention.PruneInterval <= 0 {
+		v.addf("Ingest.Retention.PruneInterval", "must be positive when pruning is enabled")
+	}
+
+	for _, u := range c.DirectAnnounce.URLs {
+		v.checkHttpURL("DirectAnnounce.URLs", u)
+	}
+	for _, p := range c.DirectAnnounce.IndexerPeers {
+		v.checkPeerID("DirectAnnounce.IndexerPeers", p)
+	}
+	if _, err := c.DirectAnnounce.ParseTrustedIndexers(); err != nil {
+		v.addf("DirectAnnounce.TrustedIndexers", "%v", err)
+	}
+	tokenURLs := make([]string, 0, len(c.DirectAnnounce.AuthTokens))
+	for rawURL := range c.DirectAnnounce.AuthTokens {
+		tokenURLs = append(tokenURLs, rawURL)
+	}
+	sort.Strings(tokenURLs)
+	for _, rawURL := range tokenURLs {
+		if u, err := url.Parse(rawURL); err != nil || u.Scheme == "" || u.Host == "" {
+			v.addf("DirectAnnounce.AuthTokens", "invalid URL %q: must have a scheme and host", rawURL)
+		} else if c.DirectAnnounce.AuthTokens[rawURL] == "" {
+			v.addf("DirectAnnounce.AuthTokens", "empty token for %s", rawURL)
+		}
+	}
+	for _, u := range c.DirectAnnounce.MessageBusURLs {
+		if _, err := msgbus.Open(u); err != nil {
+			v.addf("DirectAnnounce.MessageBusURLs", "%s", err)
+		}
+	}
+	if c.DirectAnnounce.ReannounceInterval < 0 {
+		v.addf("DirectAnnounce.ReannounceInterval", "must not be negative")
+	}
+	if c.DirectAnnounce.AnnounceDebounce < 0 {
+		v.addf("DirectAnnounce.AnnounceDebounce", "must not be negative")
+	}
+	if c.DirectAnnounce.AnnounceMaxDelay < 0 {
+		v.addf("DirectAnnounce.AnnounceMaxDelay", "must not be negative")
+	}
+
+	v.checkMultiaddr("ProviderServer.ListenMultiaddr", c.ProviderServer.ListenMultiaddr)
+	var privateAddrs int
+	for _, addr := range c.ProviderServer.RetrievalMultiaddrs {
+		maddr, err := multiaddr.NewMultiaddr(addr)
+		if err != nil {
+			v.addf("ProviderServer.RetrievalMultiaddrs", "invalid multiaddr %q: %v", addr, err)
+		} else if engine.IsPrivateAddr(maddr) {
+			privateAddrs++
+		}
+	}
+	if privateAddrs != 0 && privateAddrs == len(c.ProviderServer.RetrievalMultiaddrs) && !c.ProviderServer.AllowPrivateRetrievalAddrs {
+		v.addf("ProviderServer.RetrievalMultiaddrs", "all addresses are private, and so are not advertised; set ProviderServer.AllowPrivateRetrievalAddrs to advertise them")
+	}
+	if c.ProviderServer.MaxRetrievalAddrs < 0 || c.ProviderServer.MaxRetrievalAddrs > provider.MaxAddrs {
+		v.addf("ProviderServer.MaxRetrievalAddrs", "must be between 0 and %d", provider.MaxAddrs)
+	}
+
+	if _, err := c.AdminServer.ListenNetAddr(); err != nil {
+		v.addf("AdminServer.ListenMultiaddr", "%v", err)
+	}
+	if _, err := c.AdminServer.GRPCListenNetAddr(); err != nil {
+		v.addf("AdminServer.GRPCListenMultiaddr", "%v", err)
+	}
+	if _, err := c.AdminServer.TLSConfig(); err != nil {
+		v.addf("AdminServer", "%v", err)
+	}
+	for route, limit := range c.AdminServer.RateLimits {
+		if limit.Rate <= 0 {
+			v.addf("AdminServer.RateLimits", "rate of route %s must be positive", route)
+		}
+		if limit.Burst < 0 {
+			v.addf("AdminServer.RateLimits", "burst of route %s must not be negative", route)
+		}
+	}
+	for route, size := range c.AdminServer.MaxRequestBodySizes {
+		if size < 0 {
+			v.addf("AdminServer.MaxRequestBodySizes", "size of route %s must not be negative", route)
+		}
+	}
+	if c.AdminServer.IdempotencyKeyTTL < 0 {
+		v.addf("AdminServer.IdempotencyKeyTTL", "must not be negative")
+	}
+	if c.AdminServer.HostPublisher && c.Ingest.PublisherKind != HttpPublisherKind {
+		v.addf("AdminServer.HostPublisher", "requires Ingest.PublisherKind to be %q", HttpPublisherKind)
+	}
+
+	if _, err := c.Bootstrap.PeerAddrs(); err != nil {
+		v.addf("Bootstrap.Peers", "%v", err)
+	}
+	if c.Bootstrap.MinimumPeers < 0 {
+		v.addf("Bootstrap.MinimumPeers", "must not be negative")
+	}
+
+	if c.DelegatedRouting.ListenMultiaddr != "" {
+		if _, err := c.DelegatedRouting.ListenNetAddr(); err != nil {
+			v.addf("DelegatedRouting.ListenMultiaddr", "%v", err)
+		}
+		if c.DelegatedRouting.ProviderID != "" {
+			v.checkPeerID("DelegatedRouting.ProviderID", c.DelegatedRouting.ProviderID)
+		}
+		for _, addr := range c.DelegatedRouting.Addrs {
+			v.checkMultiaddr("DelegatedRouting.Addrs", addr)
+		}
+	}
+
+	if c.Tenants.Enabled() {
+		if _, err := c.Tenants.ListenNetAddr(); err != nil {
+			v.addf("Tenants.ListenMultiaddr", "%v", err)
+		}
+		if c.Tenants.PublisherURL != "" {
+			v.checkHttpURL("Tenants.PublisherURL", c.Tenants.PublisherURL)
+		}
+	}
+
+	if _, err := c.Metrics.ListenNetAddr(); err != nil {
+		v.addf("Metrics.ListenMultiaddr", "%v", err)
+	}
+
+	if c.Tracing.Endpoint != "" {
+		v.checkHttpURL("Tracing.Endpoint", c.Tracing.Endpoint)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		v.addf("Tracing.SampleRatio", "must be between 0 and 1, got %v", c.Tracing.SampleRatio)
+	}
+
+	if c.Alerts.WebhookURL != "" {
+		v.checkHttpURL("Alerts.WebhookURL", c.Alerts.WebhookURL)
+	}
+	for _, indexer := range c.Alerts.Indexers {
+		v.checkHttpURL("Alerts.Indexers", indexer)
+	}
+	if c.Alerts.MaxAnnounceFailures < 0 {
+		v.addf("Alerts.MaxAnnounceFailures", "must not be negative, got %d", c.Alerts.MaxAnnounceFailures)
+	}
+	if c.Alerts.MaxIngestionLag < 0 {
+		v.addf("Alerts.MaxIngestionLag", "must not be negative, got %d", c.Alerts.MaxIngestionLag)
+	}
+	if c.Alerts.MaxPendingAnnounces < 0 {
+		v.addf("Alerts.MaxPendingAnnounces", "must not be negative, got %d", c.Alerts.MaxPendingAnnounces)
+	}
+
+	if c.Logging.Level != "" {
+		if _, err := logging.LevelFromString(c.Logging.Level); err != nil {
+			v.addf("Logging.Level", "invalid log level %q", c.Logging.Level)
+		}
+	}
+	for subsystem, level := range c.Logging.Levels {
+		if _, err := logging.LevelFromString(level); err != nil {
+			v.addf("Logging.Levels", "invalid log level %q of logging subsystem %q", level, subsystem)
+		}
+	}
+
+	if c.Retrieval.Graphsync.Enabled {
+		switch c.Retrieval.Graphsync.PieceCID {
+		case "", PieceCIDFromContextID, PieceCIDContextIDCid:
+		default:
+			if _, err := cid.Decode(c.Retrieval.Graphsync.PieceCID); err != nil {
+				v.addf("Retrieval.Graphsync.PieceCID", "invalid CID %q; must be a CID, %q or %q",
+					c.Retrieval.Graphsync.PieceCID, PieceCIDFromContextID, PieceCIDContextIDCid)
+			}
+		}
+	}
+	if c.Retrieval.HttpGatewayURL != "" {
+		v.checkHttpURL("Retrieval.HttpGatewayURL", c.Retrieval.HttpGatewayURL)
+	}
+	if c.Retrieval.SelfCheck.Samples < 0 {
+		v.addf("Retrieval.SelfCheck.Samples", "must not be negative, got %d", c.Retrieval.SelfCheck.Samples)
+	}
+	if c.Retrieval.SelfCheck.Timeout < 0 {
+		v.addf("Retrieval.SelfCheck.Timeout", "must not be negative, got %s", c.Retrieval.SelfCheck.Timeout)
+	}
+	if c.FilecoinDeals.Enabled() {
+		v.checkHttpURL("FilecoinDeals.MarketAPIURL", c.FilecoinDeals.MarketAPIURL)
+		if c.FilecoinDeals.PieceURL == "" {
+			v.addf("FilecoinDeals.PieceURL", "must be specified when FilecoinDeals.MarketAPIURL is specified")
+		} else {
+			v.checkHttpURL("FilecoinDeals.PieceURL", c.FilecoinDeals.PieceURL)
+		}
+	}
+
+	if c.Testing != nil {
+		v.checkRate("Testing.AnnounceFailureRate", c.Testing.AnnounceFailureRate)
+		v.checkRate("Testing.SlowListerRate", c.Testing.SlowListerRate)
+		if c.Testing.SlowListerDelay < 0 {
+			v.addf("Testing.SlowListerDelay", "must not be negative, got %s", c.Testing.SlowListerDelay)
+		}
+		v.checkRate("Testing.DatastoreWriteFailureRate", c.Testing.DatastoreWriteFailureRate)
+	}
+
+	if len(v.problems) != 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+func (c *Config) validateIdentity(v *validator) {
+	var peerID peer.ID
+	if c.Identity.PeerID != "" {
+		var err error
+		if peerID, err = peer.Decode(c.Identity.PeerID); err != nil {
+			v.addf("Identity.PeerID", "invalid peer ID %q: %v", c.Identity.PeerID, err)
+			return
+		}
+	}
+	if c.Identity.PrivKey == "" {
+		if os.Getenv(PrivateKeyPathEnvVar) == "" {
+			v.addf("Identity.PrivKey", "must be specified, or the path of the private key file must be set via %s", PrivateKeyPathEnvVar)
+		}
+		return
+	}
+	data, err := base64.StdEncoding.DecodeString(c.Identity.PrivKey)
+	if err != nil {
+		v.addf("Identity.PrivKey", "not valid base64: %v", err)
+		return
+	}
+	privKey, err := ic.UnmarshalPrivateKey(data)
+	if err != nil {
+		v.addf("Identity.PrivKey", "cannot decode private key: %v", err)
+		return
+	}
+	keyID, err := peer.IDFromPrivateKey(privKey)
+	if err != nil {
+		v.addf("Identity.PrivKey", "cannot get peer ID from private key: %v", err)
+		return
+	}
+	if peerID != "" && peerID != keyID {
+		v.addf("Identity.PeerID", "%s does not match the peer ID %s of the private key; correct it or remove it", peerID, keyID)
+	}
+}
+
+// validator collects the problems found in a config.
+type validator struct {
+	problems []string
+}
+
+func (v *validator) addf(field, format string, args ...any) {
+	v.problems = append(v.problems, field+": "+fmt.Sprintf(format, args...))
+}
+
+func (v *validator) checkMultiaddr(field, addr string) {
+	if _, err := multiaddr.NewMultiaddr(addr); err != nil {
+		v.addf(field, "invalid multiaddr %q: %v", addr, err)
+	}
+}
+
+func (v *validator) checkPeerID(field, id string) {
+	if _, err := peer.Decode(id); err != nil {
+		v.addf(field, "invalid peer ID %q: %v", id, err)
+	}
+}
+
+func (v *validator) checkHttpURL(field, rawURL string) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		v.addf(field, "invalid URL %q: %v", rawURL, err)
+		return
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.addf(field, "invalid URL %q: must be an absolute http or https URL", rawURL)
+	}
+}
+
+func (v *validator) checkRate(field string, rate float64) {
+	if rate < 0 || rate > 1 {
+		v.addf(field, "must be between 0 and 1, got %v", rate)
+	}
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	cfg, err := Init(io.Discard)
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+	for _, preset := range Presets {
+		presetCfg := *cfg
+		require.NoError(t, presetCfg.ApplyPreset(preset))
+		require.NoError(t, presetCfg.Validate(), preset)
+	}
+
+	keyID, _, err := cfg.Identity.DecodeOrCreate(io.Discard)
+	require.NoError(t, err)
+	cfg.Identity.PeerID = "12D3KooWPMGfQs5CaJKG4yCxVWizWBRtB85gEUwiX2ekStvYvqgp"
+	cfg.Datastore.Type = "badger"
+	cfg.Ingest.PublisherKind = HttpPublisherKind
+	cfg.Ingest.HttpPublisher.ListenMultiaddr = ""
+	cfg.Ingest.HttpPublisher.AnnounceMultiaddr = "/dns4/example.com/tcp/443"
+	cfg.Ingest.SyncPolicy.Except = []string{"fish"}
+	cfg.Ingest.Network = "acme/private"
+	cfg.Ingest.Retention.MaxAds = -1
+	cfg.DirectAnnounce.URLs = []string{"cid.contact/ingest/announce"}
+	cfg.DirectAnnounce.MessageBusURLs = []string{"kafka://localhost/announce"}
+	cfg.DirectAnnounce.IndexerPeers = []string{"lobster"}
+	cfg.DirectAnnounce.TrustedIndexers = []string{"crab"}
+	cfg.DirectAnnounce.AuthTokens = map[string]string{"indexer.example": "secret"}
+	cfg.ProviderServer.ListenMultiaddr = "/ip4/0.0.0.0/tcp"
+	cfg.ProviderServer.RetrievalMultiaddrs = []string{"/ip4/127.0.0.1/tcp/3104", "/ip4/172.17.0.2/tcp/3104"}
+	cfg.ProviderServer.MaxRetrievalAddrs = 33
+	cfg.AdminServer.RateLimits = map[string]RateLimit{"/admin/": {Rate: 0}}
+	cfg.Tracing.Endpoint = "localhost:4318"
+	cfg.Tracing.SampleRatio = 2
+	cfg.Alerts.Indexers = []string{"cid.contact"}
+	cfg.Alerts.MaxIngestionLag = -1
+	cfg.Logging.Level = "loud"
+	cfg.Retrieval.Graphsync.Enabled = true
+	cfg.Retrieval.Graphsync.PieceCID = "{pieceCid}"
+	cfg.Retrieval.HttpGatewayURL = "gateway.example"
+	cfg.FilecoinDeals.MarketAPIURL = "ws://127.0.0.1:2345/rpc/v0"
+	cfg.Testing = &Testing{AnnounceFailureRate: 1.5}
+
+	err = cfg.Validate()
+	var verr *ValidationError
+	require.True(t, errors.As(err, &verr))
+	want := []string{
+		"Identity.PeerID: 12D3KooWPMGfQs5CaJKG4yCxVWizWBRtB85gEUwiX2ekStvYvqgp does not match the peer ID " + keyID.String() + " of the private key",
+		`Datastore.Type: unsupported datastore type "badger"`,
+		`Ingest.HttpPublisher.ListenMultiaddr: must be specified when Ingest.PublisherKind is "http"`,
+		`Ingest.HttpPublisher.AnnounceMultiaddr: /dns4/example.com/tcp/443 is not an HTTP address`,
+		`Ingest.Network: invalid network name "acme/private"`,
+		`Ingest.SyncPolicy.Except: invalid peer ID "fish"`,
+		"Ingest.Retention.MaxAds: must not be negative",
+		`DirectAnnounce.URLs: invalid URL "cid.contact/ingest/announce": must be an absolute http or https URL`,
+		`DirectAnnounce.IndexerPeers: invalid peer ID "lobster"`,
+		`DirectAnnounce.TrustedIndexers: bad DirectAnnounce trusted indexer "crab"`,
+		`DirectAnnounce.AuthTokens: invalid URL "indexer.example": must have a scheme and host`,
+		`DirectAnnounce.MessageBusURLs: unsupported message bus URL "kafka://localhost/announce"; expected an sqs:// URL, an SNS topic ARN or a nats:// URL`,
+		`ProviderServer.ListenMultiaddr: invalid multiaddr "/ip4/0.0.0.0/tcp"`,
+		"ProviderServer.RetrievalMultiaddrs: all addresses are private",
+		"ProviderServer.MaxRetrievalAddrs: must be between 0 and 32",
+		"AdminServer.RateLimits: rate of route /admin/ must be positive",
+		`Tracing.Endpoint: invalid URL "localhost:4318": must be an absolute http or https URL`,
+		"Tracing.SampleRatio: must be between 0 and 1",
+		`Alerts.Indexers: invalid URL "cid.contact"`,
+		"Alerts.MaxIngestionLag: must not be negative",
+		`Logging.Level: invalid log level "loud"`,
+		`Retrieval.Graphsync.PieceCID: invalid CID "{pieceCid}"`,
+		`Retrieval.HttpGatewayURL: invalid URL "gateway.example"`,
+		`FilecoinDeals.MarketAPIURL: invalid URL "ws://127.0.0.1:2345/rpc/v0"`,
+		"FilecoinDeals.PieceURL: must be specified",
+		"Testing.AnnounceFailureRate: must be between 0 and 1",
+	}
+	require.Len(t, verr.Problems, len(want))
+	for i, problem := range verr.Problems {
+		require.True(t, strings.HasPrefix(problem, want[i]), problem)
+	}
+	require.ErrorContains(t, err, "invalid config: 26 problems:")
+}
+
+func TestConfig_ValidateHostPublisher(t *testing.T) {
+	cfg, err := Init(io.Discard)
+	require.NoError(t, err)
+	cfg.AdminServer.HostPublisher = true
+	cfg.Ingest.PublisherKind = Libp2pPublisherKind
+	require.ErrorContains(t, cfg.Validate(), `AdminServer.HostPublisher: requires Ingest.PublisherKind to be "http"`)
+
+	// The publisher needs no listen address of its own.
+	cfg.Ingest.PublisherKind = HttpPublisherKind
+	cfg.Ingest.HttpPublisher.ListenMultiaddr = ""
+	require.NoError(t, cfg.Validate())
+	maddr, err := cfg.AdminServer.PublisherMultiaddr()
+	require.NoError(t, err)
+	require.Equal(t, "/ip4/127.0.0.1/tcp/3102/http", maddr.String())
+}
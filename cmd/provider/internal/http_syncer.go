@@ -0,0 +1,152 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipni/go-libipni/dagsync/ipnisync"
+	"github.com/ipni/go-libipni/dagsync/ipnisync/head"
+	"github.com/ipni/go-libipni/maurl"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+var _ syncer = (*httpSyncer)(nil)
+
+// errNoContent is returned by httpSyncer.get when the publisher responds
+// with no content, which is how the absence of a head is signalled.
+var errNoContent = errors.New("no content")
+
+// httpSyncer fetches blocks from an IPNI HTTP publisher using plain HTTP
+// requests.
+type httpSyncer struct {
+	*options
+	publisherID peer.ID
+	baseURL     *url.URL
+	client      *http.Client
+}
+
+// NewHttpProviderClient instantiates a ProviderClient that fetches
+// advertisements from a provider publisher over HTTP. The first HTTP address
+// in the given publisher addr info is used.
+func NewHttpProviderClient(publisher peer.AddrInfo, o ...Option) (ProviderClient, error) {
+	opts, err := newOptions(o...)
+	if err != nil {
+		return nil, err
+	}
+	var baseURL *url.URL
+	for _, addr := range publisher.Addrs {
+		if baseURL, err = maurl.ToURL(addr); err == nil {
+			break
+		}
+	}
+	if baseURL == nil {
+		return nil, errors.New("no HTTP address found for publisher")
+	}
+	s := &httpSyncer{
+		options:     opts,
+		publisherID: publisher.ID,
+		baseURL:     baseURL.JoinPath(ipnisync.IPNIPath),
+		client: &http.Client{
+			Timeout: opts.httpTimeout,
+		},
+	}
+	if opts.dialTimeout != 0 || opts.tlsConfig != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		if opts.dialTimeout != 0 {
+			transport.DialContext = (&net.Dialer{
+				Timeout:   opts.dialTimeout,
+				KeepAlive: 30 * time.Second,
+			}).DialContext
+		}
+		if opts.tlsConfig != nil {
+			transport.TLSClientConfig = opts.tlsConfig
+		}
+		s.client.Transport = transport
+	}
+	return newProviderClient(publisher, opts, s), nil
+}
+
+func (s *httpSyncer) head(ctx context.Context) (cid.Cid, error) {
+	var headCid cid.Cid
+	err := s.get(ctx, "head", func(r io.Reader) error {
+		signed, err := head.Decode(r)
+		if err != nil {
+			return fmt.Errorf("failed to decode signed head: %w", err)
+		}
+		signerID, err := signed.Validate()
+		if err != nil {
+			return fmt.Errorf("invalid signed head: %w", err)
+		}
+		if s.publisherID != "" && signerID != s.publisherID {
+			return fmt.Errorf("head signed by %s, expected publisher %s", signerID, s.publisherID)
+		}
+		headCid = signed.Head.(cidlink.Link).Cid
+		return nil
+	})
+	if errors.Is(err, errNoContent) {
+		return cid.Undef, nil
+	}
+	return headCid, err
+}
+
+func (s *httpSyncer) fetch(ctx context.Context, c cid.Cid) error {
+	return s.get(ctx, c.String(), func(r io.Reader) error {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		// Check that the returned data hashes to the requested CID, since
+		// the publisher cannot be trusted.
+		got, err := c.Prefix().Sum(data)
+		if err != nil {
+			return err
+		}
+		if !got.Equals(c) {
+			return fmt.Errorf("block data does not match requested cid %s, got %s", c, got)
+		}
+		return s.ds.Put(ctx, blockKey(c), data)
+	})
+}
+
+func (s *httpSyncer) get(ctx context.Context, resource string, handle func(io.Reader) error) error {
+	u := s.baseURL.JoinPath(resource)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	for key, values := range s.httpHeader {
+		req.Header[key] = values
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return handle(resp.Body)
+	case http.StatusNoContent:
+		return errNoContent
+	case http.StatusNotFound:
+		return fmt.Errorf("failed to get %s: %w", u, ErrNotFound)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("failed to get %s: %d %s: %w", u, resp.StatusCode, http.StatusText(resp.StatusCode), ErrUnauthorized)
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to get %s: %d %s: %s", u, resp.StatusCode, http.StatusText(resp.StatusCode), body)
+	}
+}
+
+func (s *httpSyncer) close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
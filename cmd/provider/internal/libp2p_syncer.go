@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/ipld/go-ipld-prime/traversal/selector"
+	"github.com/ipld/go-ipld-prime/traversal/selector/builder"
+	"github.com/ipni/go-libipni/dagsync/ipnisync"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+var _ syncer = (*libp2pSyncer)(nil)
+
+// selectorOne selects a single block without traversing any of its links.
+var selectorOne ipld.Node
+
+func init() {
+	ssb := builder.NewSelectorSpecBuilder(basicnode.Prototype.Any)
+	selectorOne = ssb.ExploreRecursive(selector.RecursionLimitDepth(0),
+		ssb.ExploreAll(ssb.ExploreRecursiveEdge())).Node()
+}
+
+// libp2pSyncer fetches blocks from an IPNI publisher over libp2p, falling
+// back on HTTP if the publisher only serves plain HTTP.
+type libp2pSyncer struct {
+	*options
+	h      host.Host
+	ownH   bool
+	sync   *ipnisync.Sync
+	syncer *ipnisync.Syncer
+}
+
+// NewLibp2pProviderClient instantiates a ProviderClient that fetches
+// advertisements from a provider publisher over libp2p. Unless a host is given
+// via WithHost, a new libp2p host with no listen addresses is created and
+// closed along with the client.
+func NewLibp2pProviderClient(publisher peer.AddrInfo, o ...Option) (ProviderClient, error) {
+	opts, err := newOptions(o...)
+	if err != nil {
+		return nil, err
+	}
+	h := opts.host
+	ownH := h == nil
+	if ownH {
+		if h, err = libp2p.New(libp2p.NoListenAddrs); err != nil {
+			return nil, err
+		}
+	}
+	closeHost := func() {
+		if ownH {
+			h.Close()
+		}
+	}
+	h.Peerstore().AddAddrs(publisher.ID, publisher.Addrs, time.Hour)
+
+	sync := ipnisync.NewSync(storeLinkSystem(opts.ds), nil,
+		ipnisync.ClientStreamHost(h),
+		ipnisync.ClientHTTPTimeout(opts.httpTimeout))
+	syncer, err := sync.NewSyncer(publisher)
+	if err != nil {
+		sync.Close()
+		closeHost()
+		return nil, err
+	}
+	s := &libp2pSyncer{
+		options: opts,
+		h:       h,
+		ownH:    ownH,
+		sync:    sync,
+		syncer:  syncer,
+	}
+	return newProviderClient(publisher, opts, s), nil
+}
+
+func (s *libp2pSyncer) head(ctx context.Context) (cid.Cid, error) {
+	return s.syncer.GetHead(s.dialContext(ctx))
+}
+
+func (s *libp2pSyncer) fetch(ctx context.Context, c cid.Cid) error {
+	return s.syncer.Sync(s.dialContext(ctx), c, selectorOne)
+}
+
+// dialContext returns the given context with the dial timeout, if any.
+func (s *libp2pSyncer) dialContext(ctx context.Context) context.Context {
+	if s.dialTimeout == 0 {
+		return ctx
+	}
+	return network.WithDialPeerTimeout(ctx, s.dialTimeout)
+}
+
+func (s *libp2pSyncer) close() error {
+	s.sync.Close()
+	if !s.ownH {
+		return nil
+	}
+	return s.h.Close()
+}
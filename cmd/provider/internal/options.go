@@ -0,0 +1,181 @@
+package internal
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/libp2p/go-libp2p/core/host"
+)
+
+const defaultHttpTimeout = 10 * time.Second
+
+type (
+	// Option sets a configuration parameter for the provider client.
+	Option func(*options) error
+
+	options struct {
+		ds                    datastore.Batching
+		httpTimeout           time.Duration
+		dialTimeout           time.Duration
+		requestTimeout        time.Duration
+		host                  host.Host
+		httpHeader            http.Header
+		tlsConfig             *tls.Config
+		entriesRecursionLimit int
+		entriesAutoPaging     bool
+		entriesMaxMultihashes int
+		entriesMaxBytes       int64
+	}
+)
+
+func newOptions(o ...Option) (*options, error) {
+	opts := &options{
+		httpTimeout: defaultHttpTimeout,
+		httpHeader:  make(http.Header),
+	}
+	for _, apply := range o {
+		if err := apply(opts); err != nil {
+			return nil, err
+		}
+	}
+	if opts.ds == nil {
+		opts.ds = dssync.MutexWrap(datastore.NewMapDatastore())
+	}
+	return opts, nil
+}
+
+// WithDatastore sets the datastore in which the blocks synced from the
+// provider are stored. Defaults to an in-memory datastore.
+func WithDatastore(ds datastore.Batching) Option {
+	return func(o *options) error {
+		o.ds = ds
+		return nil
+	}
+}
+
+// WithHttpTimeout sets the timeout for each HTTP request made to the provider
+// publisher. Defaults to 10 seconds.
+func WithHttpTimeout(timeout time.Duration) Option {
+	return func(o *options) error {
+		if timeout <= 0 {
+			return fmt.Errorf("http timeout must be greater than zero: %s", timeout)
+		}
+		o.httpTimeout = timeout
+		return nil
+	}
+}
+
+// WithDialTimeout sets the timeout for establishing a connection to the
+// provider publisher, over either libp2p or HTTP. Defaults to zero, meaning the
+// default timeout of the transport.
+func WithDialTimeout(timeout time.Duration) Option {
+	return func(o *options) error {
+		if timeout < 0 {
+			return fmt.Errorf("dial timeout must not be negative: %s", timeout)
+		}
+		o.dialTimeout = timeout
+		return nil
+	}
+}
+
+// WithRequestTimeout sets the deadline for each request made to the provider
+// publisher, i.e. for getting the head advertisement and for fetching each
+// block, including the time to connect. Unlike WithHttpTimeout, it applies
+// to libp2p too. Defaults to zero, meaning no deadline other than that of the
+// context of the request.
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(o *options) error {
+		if timeout < 0 {
+			return fmt.Errorf("request timeout must not be negative: %s", timeout)
+		}
+		o.requestTimeout = timeout
+		return nil
+	}
+}
+
+// WithHost sets the libp2p host with which a libp2p provider client connects
+// to the provider publisher, so that connections are reused across clients.
+// The host is not closed along with the client. Defaults to a new host with no
+// listen addresses, that is closed along with the client.
+func WithHost(h host.Host) Option {
+	return func(o *options) error {
+		o.host = h
+		return nil
+	}
+}
+
+// WithHttpHeader adds a header to the HTTP requests made to the provider
+// publisher, e.g. to authenticate to a reverse proxy in front of it. Ignored by
+// libp2p provider clients.
+func WithHttpHeader(key, value string) Option {
+	return func(o *options) error {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("http header key must not be empty")
+		}
+		o.httpHeader.Add(key, value)
+		return nil
+	}
+}
+
+// WithBearerToken sets the bearer token with which HTTP requests made to the
+// provider publisher are authenticated. Ignored by libp2p provider clients.
+func WithBearerToken(token string) Option {
+	return func(o *options) error {
+		o.httpHeader.Set("Authorization", "Bearer "+token)
+		return nil
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used to connect to a provider
+// publisher served over HTTPS, e.g. to verify its certificate or to present a
+// client certificate. Ignored by libp2p provider clients.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(o *options) error {
+		o.tlsConfig = config
+		return nil
+	}
+}
+
+// WithEntriesRecursionLimit sets the maximum number of entry chunks fetched
+// for each advertisement. Iterating over the entries of an advertisement with
+// more chunks than the limit fails with ErrEntriesRecursionLimit once the
+// limit is reached. The limit does not apply to HAMT entries. Defaults to zero,
+// meaning no limit.
+func WithEntriesRecursionLimit(limit int) Option {
+	return func(o *options) error {
+		if limit < 0 {
+			return fmt.Errorf("entries recursion limit must not be negative: %d", limit)
+		}
+		o.entriesRecursionLimit = limit
+		return nil
+	}
+}
+
+// WithEntriesAutoPaging enables fetching the entry chunks of advertisements in
+// pages of at most the entries recursion limit, so that reaching the limit does
+// not fail iteration but moves on to the next page instead. Pages are fetched
+// until either the given number of multihashes have been returned or the given
+// number of bytes of entry chunks have been fetched, at which point iteration
+// fails with an *EntriesBudgetError that reports the entry chunk from which the
+// remaining entries start. A budget of zero means no limit. Budgets are checked
+// between chunks, and so may be exceeded by up to one chunk. Budgets do not
+// apply to HAMT entries.
+func WithEntriesAutoPaging(maxMultihashes int, maxBytes int64) Option {
+	return func(o *options) error {
+		if maxMultihashes < 0 {
+			return fmt.Errorf("entries multihash budget must not be negative: %d", maxMultihashes)
+		}
+		if maxBytes < 0 {
+			return fmt.Errorf("entries byte budget must not be negative: %d", maxBytes)
+		}
+		o.entriesAutoPaging = true
+		o.entriesMaxMultihashes = maxMultihashes
+		o.entriesMaxBytes = maxBytes
+		return nil
+	}
+}
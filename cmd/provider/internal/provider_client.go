@@ -0,0 +1,465 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipld/go-ipld-adl-hamt"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/ipld/go-ipld-prime/node/bindnode"
+	"github.com/ipni/go-libipni/ingest/schema"
+	provider "github.com/ipni/index-provider"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multihash"
+)
+
+// ErrNoHead is returned when the latest advertisement is requested from a
+// provider that has not published any advertisements.
+var ErrNoHead = errors.New("provider has not published any advertisements")
+
+// ErrNotFound is returned when a block or advertisement is not found on the
+// provider.
+var ErrNotFound = errors.New("not found")
+
+// ErrUnauthorized is returned when the provider publisher rejects requests
+// as unauthenticated or forbidden. See: WithBearerToken.
+var ErrUnauthorized = errors.New("unauthorized")
+
+// ErrEntriesRecursionLimit is returned when iterating over the entries of an
+// advertisement would fetch more entry chunks than the recursion limit.
+var ErrEntriesRecursionLimit = errors.New("entries recursion limit reached")
+
+// ErrEntriesBudgetExhausted is returned when iterating over the entries of an
+// advertisement with auto paging stops because the budget is exhausted. See:
+// WithEntriesAutoPaging.
+var ErrEntriesBudgetExhausted = errors.New("entries budget exhausted")
+
+type (
+	// ProviderClient fetches advertisements and their entries from the
+	// publisher of an index provider.
+	ProviderClient interface {
+		// GetAdvertisement fetches the advertisement with the given CID. The
+		// latest advertisement is fetched if id is cid.Undef.
+		GetAdvertisement(ctx context.Context, id cid.Cid) (*Advertisement, error)
+		// GetEntries returns an iterator over the entries with the given
+		// root, e.g. to resume fetching from the entry chunk reported by an
+		// EntriesBudgetError.
+		GetEntries(ctx context.Context, root cid.Cid) *EntriesIterator
+		// ChainStats walks backwards through the advertisement chain from
+		// the advertisement with the given CID, or from the latest one if
+		// from is cid.Undef, and aggregates the advertisements walked. The
+		// walk stops once depth advertisements are walked, or at the start
+		// of the chain if depth is zero.
+		ChainStats(ctx context.Context, from cid.Cid, depth int) (*ChainStats, error)
+		// Close releases the resources used by the client.
+		Close() error
+	}
+
+	// Advertisement is an advertisement fetched from a provider.
+	Advertisement struct {
+		ID               cid.Cid
+		PreviousID       cid.Cid
+		ProviderID       peer.ID
+		ContextID        []byte
+		Metadata         []byte
+		Addresses        []string
+		Signature        []byte
+		IsRemove         bool
+		ExtendedProvider *schema.ExtendedProvider
+		// Entries iterates over the multihashes advertised, fetching the
+		// entries from the provider as they are needed.
+		Entries *EntriesIterator
+
+		ad *schema.Advertisement
+	}
+
+	// EntriesIterator iterates over the multihashes of an advertisement,
+	// fetching the entries blocks from the provider lazily. Both entry chunk
+	// chains and HAMT entries are supported.
+	EntriesIterator struct {
+		ctx    context.Context
+		client *providerClient
+		root   cid.Cid
+
+		// chunk is the current entry chunk and offset the position of the
+		// next multihash in it.
+		chunk  *schema.EntryChunk
+		offset int
+		chunks int
+		// pageChunks is the number of chunks fetched in the current page, and
+		// pages the number of pages started. See: WithEntriesAutoPaging.
+		pageChunks  int
+		pages       int
+		multihashes int
+		bytes       int64
+		// hamtIter is set instead of chunk when entries are a HAMT.
+		hamtIter provider.MultihashIterator
+		started  bool
+	}
+
+	// syncer fetches blocks from a provider publisher into the client store.
+	syncer interface {
+		// head returns the CID of the latest advertisement published.
+		head(ctx context.Context) (cid.Cid, error)
+		// fetch syncs the single block with the given CID.
+		fetch(ctx context.Context, c cid.Cid) error
+		close() error
+	}
+
+	// EntriesBudgetError is returned by EntriesIterator.Next when it stops
+	// because the budget set by WithEntriesAutoPaging is exhausted. It wraps
+	// ErrEntriesBudgetExhausted.
+	EntriesBudgetError struct {
+		// Multihashes is the number of multihashes returned.
+		Multihashes int
+		// Chunks is the number of entry chunks fetched, across Pages pages.
+		Chunks int
+		Pages  int
+		// Bytes is the number of bytes of entry chunks fetched.
+		Bytes int64
+		// Remaining is the CID of the first entry chunk not fetched, from
+		// which the remaining entries can be fetched with
+		// ProviderClient.GetEntries.
+		Remaining cid.Cid
+	}
+
+	providerClient struct {
+		*options
+		publisher peer.AddrInfo
+		syncer    syncer
+	}
+)
+
+func newProviderClient(publisher peer.AddrInfo, opts *options, s syncer) *providerClient {
+	return &providerClient{
+		options:   opts,
+		publisher: publisher,
+		syncer:    s,
+	}
+}
+
+func (c *providerClient) GetAdvertisement(ctx context.Context, id cid.Cid) (*Advertisement, error) {
+	if id == cid.Undef {
+		var err error
+		id, err = c.head(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get head advertisement: %w", err)
+		}
+		if id == cid.Undef {
+			return nil, ErrNoHead
+		}
+	}
+
+	lsys := c.linkSystem(ctx)
+	n, err := lsys.Load(ipld.LinkContext{Ctx: ctx}, cidlink.Link{Cid: id}, schema.AdvertisementPrototype)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load advertisement %s: %w", id, err)
+	}
+	ad, err := schema.UnwrapAdvertisement(n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode advertisement %s: %w", id, err)
+	}
+
+	providerID, err := peer.Decode(ad.Provider)
+	if err != nil {
+		return nil, fmt.Errorf("invalid provider ID in advertisement %s: %w", id, err)
+	}
+	var entriesRoot cid.Cid
+	if ad.Entries != nil {
+		entriesRoot = ad.Entries.(cidlink.Link).Cid
+	}
+
+	return &Advertisement{
+		ID:               id,
+		PreviousID:       ad.PreviousCid(),
+		ProviderID:       providerID,
+		ContextID:        ad.ContextID,
+		Metadata:         ad.Metadata,
+		Addresses:        ad.Addresses,
+		Signature:        ad.Signature,
+		IsRemove:         ad.IsRm,
+		ExtendedProvider: ad.ExtendedProvider,
+		Entries:          c.GetEntries(ctx, entriesRoot),
+		ad:               ad,
+	}, nil
+}
+
+func (c *providerClient) GetEntries(ctx context.Context, root cid.Cid) *EntriesIterator {
+	return &EntriesIterator{
+		ctx:    ctx,
+		client: c,
+		root:   root,
+	}
+}
+
+func (c *providerClient) Close() error {
+	return c.syncer.close()
+}
+
+// head gets the CID of the latest advertisement within the request timeout.
+func (c *providerClient) head(ctx context.Context) (cid.Cid, error) {
+	if c.requestTimeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.requestTimeout)
+		defer cancel()
+	}
+	return c.syncer.head(ctx)
+}
+
+// fetch fetches the block with the given CID within the request timeout.
+func (c *providerClient) fetch(ctx context.Context, blk cid.Cid) error {
+	if c.requestTimeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.requestTimeout)
+		defer cancel()
+	}
+	return c.syncer.fetch(ctx, blk)
+}
+
+// linkSystem returns a link system that loads blocks from the client store,
+// fetching them from the provider first if they are not already stored.
+func (c *providerClient) linkSystem(ctx context.Context) ipld.LinkSystem {
+	lsys := cidlink.DefaultLinkSystem()
+	lsys.StorageReadOpener = func(_ ipld.LinkContext, lnk ipld.Link) (io.Reader, error) {
+		key := blockKey(lnk.(cidlink.Link).Cid)
+		val, err := c.ds.Get(ctx, key)
+		if errors.Is(err, datastore.ErrNotFound) {
+			if err = c.fetch(ctx, lnk.(cidlink.Link).Cid); err != nil {
+				return nil, err
+			}
+			val, err = c.ds.Get(ctx, key)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewBuffer(val), nil
+	}
+	return lsys
+}
+
+// storeLinkSystem returns a link system that reads and writes blocks to the
+// client store without fetching anything from the provider.
+func storeLinkSystem(ds datastore.Batching) ipld.LinkSystem {
+	lsys := cidlink.DefaultLinkSystem()
+	lsys.StorageReadOpener = func(lctx ipld.LinkContext, lnk ipld.Link) (io.Reader, error) {
+		val, err := ds.Get(lctx.Ctx, blockKey(lnk.(cidlink.Link).Cid))
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewBuffer(val), nil
+	}
+	lsys.StorageWriteOpener = func(lctx ipld.LinkContext) (io.Writer, ipld.BlockWriteCommitter, error) {
+		buf := bytes.NewBuffer(nil)
+		return buf, func(lnk ipld.Link) error {
+			return ds.Put(lctx.Ctx, blockKey(lnk.(cidlink.Link).Cid), buf.Bytes())
+		}, nil
+	}
+	return lsys
+}
+
+func blockKey(c cid.Cid) datastore.Key {
+	return datastore.NewKey(c.String())
+}
+
+// VerifySignature verifies the advertisement signature, returning the ID of
+// the signer.
+func (a *Advertisement) VerifySignature() (peer.ID, error) {
+	return a.ad.VerifySignature()
+}
+
+// Validate checks that the advertisement fields are well-formed.
+func (a *Advertisement) Validate() error {
+	return a.ad.Validate()
+}
+
+// HasEntries reports whether the advertisement has any entries.
+func (a *Advertisement) HasEntries() bool {
+	return a.Entries.IsPresent()
+}
+
+// Root returns the CID of the entries root block.
+func (e *EntriesIterator) Root() cid.Cid {
+	return e.root
+}
+
+// IsPresent reports whether there are entries to iterate over.
+func (e *EntriesIterator) IsPresent() bool {
+	return e.root != cid.Undef && e.root != schema.NoEntries.Cid
+}
+
+// ChunkCount returns the number of entry chunks fetched so far. It is always
+// zero for HAMT entries.
+func (e *EntriesIterator) ChunkCount() int {
+	return e.chunks
+}
+
+// Remaining returns the CID of the next entry chunk to fetch, or cid.Undef if
+// there is none, e.g. once all entry chunks are fetched or for HAMT entries.
+func (e *EntriesIterator) Remaining() cid.Cid {
+	if !e.started {
+		if e.IsPresent() {
+			return e.root
+		}
+		return cid.Undef
+	}
+	if e.chunk == nil || e.chunk.Next == nil {
+		return cid.Undef
+	}
+	return e.chunk.Next.(cidlink.Link).Cid
+}
+
+// Next returns the next multihash, or io.EOF once all multihashes have been
+// returned.
+func (e *EntriesIterator) Next() (multihash.Multihash, error) {
+	if !e.IsPresent() {
+		return nil, io.EOF
+	}
+	if !e.started {
+		if err := e.start(); err != nil {
+			return nil, err
+		}
+		e.started = true
+	}
+	if e.hamtIter != nil {
+		return e.hamtIter.Next()
+	}
+	for e.offset >= len(e.chunk.Entries) {
+		if e.chunk.Next == nil {
+			return nil, io.EOF
+		}
+		if e.budgetExhausted() {
+			return nil, &EntriesBudgetError{
+				Multihashes: e.multihashes,
+				Chunks:      e.chunks,
+				Pages:       e.pages,
+				Bytes:       e.bytes,
+				Remaining:   e.Remaining(),
+			}
+		}
+		if limit := e.client.entriesRecursionLimit; limit != 0 && e.pageChunks >= limit {
+			if !e.client.entriesAutoPaging {
+				return nil, ErrEntriesRecursionLimit
+			}
+			e.pageChunks = 0
+			e.pages++
+		}
+		if err := e.loadChunk(e.chunk.Next.(cidlink.Link).Cid); err != nil {
+			return nil, err
+		}
+	}
+	mh := e.chunk.Entries[e.offset]
+	e.offset++
+	e.multihashes++
+	return mh, nil
+}
+
+// budgetExhausted reports whether auto paging is enabled and its budget is
+// exhausted.
+func (e *EntriesIterator) budgetExhausted() bool {
+	if !e.client.entriesAutoPaging {
+		return false
+	}
+	maxMhs, maxBytes := e.client.entriesMaxMultihashes, e.client.entriesMaxBytes
+	return (maxMhs != 0 && e.multihashes >= maxMhs) || (maxBytes != 0 && e.bytes >= maxBytes)
+}
+
+// NextChunk returns the remaining multihashes of the current entry chunk,
+// moving on to the next chunk first if the current one is exhausted. It
+// returns io.EOF once all multihashes have been returned. All the remaining
+// multihashes are returned at once for HAMT entries.
+func (e *EntriesIterator) NextChunk() ([]multihash.Multihash, error) {
+	mh, err := e.Next()
+	if err != nil {
+		return nil, err
+	}
+	if e.hamtIter != nil {
+		mhs, err := e.Drain()
+		if err != nil {
+			return nil, err
+		}
+		return append([]multihash.Multihash{mh}, mhs...), nil
+	}
+	mhs := append([]multihash.Multihash{mh}, e.chunk.Entries[e.offset:]...)
+	e.multihashes += len(e.chunk.Entries) - e.offset
+	e.offset = len(e.chunk.Entries)
+	return mhs, nil
+}
+
+// Drain returns all the remaining multihashes.
+func (e *EntriesIterator) Drain() ([]multihash.Multihash, error) {
+	var mhs []multihash.Multihash
+	for {
+		mh, err := e.Next()
+		if err == io.EOF {
+			return mhs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		mhs = append(mhs, mh)
+	}
+}
+
+func (e *EntriesIterator) start() error {
+	lsys := e.client.linkSystem(e.ctx)
+	n, err := lsys.Load(ipld.LinkContext{Ctx: e.ctx}, cidlink.Link{Cid: e.root}, basicnode.Prototype.Any)
+	if err != nil {
+		return fmt.Errorf("failed to load entries root %s: %w", e.root, err)
+	}
+	if isHAMT(n) {
+		n, err = lsys.Load(ipld.LinkContext{Ctx: e.ctx}, cidlink.Link{Cid: e.root}, hamt.HashMapRootPrototype)
+		if err != nil {
+			return fmt.Errorf("failed to decode HAMT entries root %s: %w", e.root, err)
+		}
+		root := bindnode.Unwrap(n).(*hamt.HashMapRoot)
+		e.hamtIter = provider.HamtMultihashIterator(root, lsys)
+		return nil
+	}
+	return e.loadChunk(e.root)
+}
+
+func (e *EntriesIterator) loadChunk(c cid.Cid) error {
+	lsys := e.client.linkSystem(e.ctx)
+	n, err := lsys.Load(ipld.LinkContext{Ctx: e.ctx}, cidlink.Link{Cid: c}, schema.EntryChunkPrototype)
+	if err != nil {
+		return fmt.Errorf("failed to load entry chunk %s: %w", c, err)
+	}
+	chunk, err := schema.UnwrapEntryChunk(n)
+	if err != nil {
+		return fmt.Errorf("failed to decode entry chunk %s: %w", c, err)
+	}
+	e.chunk = chunk
+	e.offset = 0
+	e.chunks++
+	e.pageChunks++
+	if e.pages == 0 {
+		e.pages = 1
+	}
+	if size, err := e.client.ds.GetSize(e.ctx, blockKey(c)); err == nil {
+		e.bytes += int64(size)
+	}
+	return nil
+}
+
+func (e *EntriesBudgetError) Error() string {
+	return fmt.Sprintf("%s after %d multihashes in %d entry chunks of %d bytes; remaining entries start at entry chunk %s",
+		ErrEntriesBudgetExhausted, e.Multihashes, e.Chunks, e.Bytes, e.Remaining)
+}
+
+func (e *EntriesBudgetError) Unwrap() error {
+	return ErrEntriesBudgetExhausted
+}
+
+// isHAMT reports whether the given node looks like a HAMT root, i.e. has a
+// "hamt" field rather than the "Entries" field of an entry chunk.
+func isHAMT(n ipld.Node) bool {
+	_, err := n.LookupByString("hamt")
+	return err == nil
+}
@@ -0,0 +1,316 @@
+package internal
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipni/go-libipni/dagsync/ipnisync"
+	"github.com/ipni/go-libipni/ingest/schema"
+	"github.com/ipni/go-libipni/maurl"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine/chunker"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+// testPublisher serves an advertisement chain over HTTP using an ipnisync
+// publisher.
+type testPublisher struct {
+	t       *testing.T
+	lsys    ipld.LinkSystem
+	key     crypto.PrivKey
+	id      peer.ID
+	pub     *ipnisync.Publisher
+	server  *httptest.Server
+	head    cid.Cid
+	addr    multiaddr.Multiaddr
+	entries map[cid.Cid][]multihash.Multihash
+}
+
+func newTestPublisher(t *testing.T) *testPublisher {
+	id, key, _ := test.RandomIdentity()
+	lsys := storeLinkSystem(dssync.MutexWrap(datastore.NewMapDatastore()))
+	pub, err := ipnisync.NewPublisher(lsys, key, ipnisync.WithStartServer(false))
+	require.NoError(t, err)
+	server := httptest.NewServer(pub)
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	addr, err := maurl.FromURL(u)
+	require.NoError(t, err)
+	return &testPublisher{
+		t:       t,
+		lsys:    lsys,
+		key:     key,
+		id:      id,
+		pub:     pub,
+		server:  server,
+		addr:    addr,
+		entries: make(map[cid.Cid][]multihash.Multihash),
+	}
+}
+
+func (p *testPublisher) publish(mhs []multihash.Multihash, isRm bool) cid.Cid {
+	ctx := context.Background()
+	var entries ipld.Link = schema.NoEntries
+	if len(mhs) != 0 {
+		chunks, err := chunker.NewChainChunker(&p.lsys, 3)
+		require.NoError(p.t, err)
+		entries, err = chunks.Chunk(ctx, provider.SliceMultihashIterator(mhs))
+		require.NoError(p.t, err)
+	}
+	ad := schema.Advertisement{
+		Provider:  p.id.String(),
+		Addresses: []string{"/ip4/127.0.0.1/tcp/9999"},
+		Entries:   entries,
+		ContextID: []byte("fish"),
+		Metadata:  []byte("lobster"),
+		IsRm:      isRm,
+	}
+	if p.head != cid.Undef {
+		ad.PreviousID = cidlink.Link{Cid: p.head}
+	}
+	require.NoError(p.t, ad.Sign(p.key))
+	n, err := ad.ToNode()
+	require.NoError(p.t, err)
+	lnk, err := p.lsys.Store(ipld.LinkContext{Ctx: ctx}, schema.Linkproto, n)
+	require.NoError(p.t, err)
+	p.head = lnk.(cidlink.Link).Cid
+	p.entries[p.head] = mhs
+	p.pub.SetRoot(p.head)
+	return p.head
+}
+
+func (p *testPublisher) addrInfo() peer.AddrInfo {
+	return peer.AddrInfo{ID: p.id, Addrs: []multiaddr.Multiaddr{p.addr}}
+}
+
+func TestHttpProviderClient_NoHead(t *testing.T) {
+	pub := newTestPublisher(t)
+	client, err := NewHttpProviderClient(pub.addrInfo())
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.GetAdvertisement(context.Background(), cid.Undef)
+	require.ErrorIs(t, err, ErrNoHead)
+}
+
+func TestHttpProviderClient_WalksChain(t *testing.T) {
+	pub := newTestPublisher(t)
+	first := pub.publish(test.RandomMultihashes(10), false)
+	second := pub.publish(nil, true)
+	head := pub.publish(test.RandomMultihashes(2), false)
+
+	client, err := NewHttpProviderClient(pub.addrInfo())
+	require.NoError(t, err)
+	defer client.Close()
+	ctx := context.Background()
+
+	ad, err := client.GetAdvertisement(ctx, cid.Undef)
+	require.NoError(t, err)
+	require.Equal(t, head, ad.ID)
+	require.Equal(t, second, ad.PreviousID)
+	require.Equal(t, pub.id, ad.ProviderID)
+	require.Equal(t, []byte("fish"), ad.ContextID)
+	signerID, err := ad.VerifySignature()
+	require.NoError(t, err)
+	require.Equal(t, pub.id, signerID)
+	mhs, err := ad.Entries.Drain()
+	require.NoError(t, err)
+	require.Equal(t, pub.entries[head], mhs)
+	require.Equal(t, 1, ad.Entries.ChunkCount())
+
+	ad, err = client.GetAdvertisement(ctx, ad.PreviousID)
+	require.NoError(t, err)
+	require.True(t, ad.IsRemove)
+	require.False(t, ad.HasEntries())
+	require.Equal(t, first, ad.PreviousID)
+
+	ad, err = client.GetAdvertisement(ctx, ad.PreviousID)
+	require.NoError(t, err)
+	require.Equal(t, cid.Undef, ad.PreviousID)
+	chunk, err := ad.Entries.NextChunk()
+	require.NoError(t, err)
+	require.Len(t, chunk, 1)
+	require.Equal(t, 1, ad.Entries.ChunkCount())
+	mhs, err = ad.Entries.Drain()
+	require.NoError(t, err)
+	require.ElementsMatch(t, pub.entries[first], append(chunk, mhs...))
+	require.Equal(t, 4, ad.Entries.ChunkCount())
+}
+
+func TestHttpProviderClient_RejectsUnexpectedPublisher(t *testing.T) {
+	pub := newTestPublisher(t)
+	pub.publish(test.RandomMultihashes(1), false)
+
+	otherID, _, _ := test.RandomIdentity()
+	client, err := NewHttpProviderClient(peer.AddrInfo{ID: otherID, Addrs: []multiaddr.Multiaddr{pub.addr}})
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.GetAdvertisement(context.Background(), cid.Undef)
+	require.ErrorContains(t, err, "expected publisher")
+}
+
+func TestHttpProviderClient_EntriesRecursionLimit(t *testing.T) {
+	pub := newTestPublisher(t)
+	mhs := test.RandomMultihashes(10)
+	pub.publish(mhs, false)
+
+	client, err := NewHttpProviderClient(pub.addrInfo(), WithEntriesRecursionLimit(2))
+	require.NoError(t, err)
+	defer client.Close()
+
+	ad, err := client.GetAdvertisement(context.Background(), cid.Undef)
+	require.NoError(t, err)
+	var got []multihash.Multihash
+	for {
+		mh, err := ad.Entries.Next()
+		if err != nil {
+			require.ErrorIs(t, err, ErrEntriesRecursionLimit)
+			break
+		}
+		got = append(got, mh)
+	}
+	require.Len(t, got, 4)
+	require.Equal(t, 2, ad.Entries.ChunkCount())
+
+	_, err = NewHttpProviderClient(pub.addrInfo(), WithEntriesRecursionLimit(-1))
+	require.Error(t, err)
+}
+
+func TestHttpProviderClient_EntriesAutoPaging(t *testing.T) {
+	pub := newTestPublisher(t)
+	mhs := test.RandomMultihashes(10)
+	pub.publish(mhs, false)
+	ctx := context.Background()
+
+	// Without budget, all entries are fetched in pages of 2 chunks.
+	client, err := NewHttpProviderClient(pub.addrInfo(), WithEntriesRecursionLimit(2), WithEntriesAutoPaging(0, 0))
+	require.NoError(t, err)
+	defer client.Close()
+	ad, err := client.GetAdvertisement(ctx, cid.Undef)
+	require.NoError(t, err)
+	got, err := ad.Entries.Drain()
+	require.NoError(t, err)
+	require.ElementsMatch(t, mhs, got)
+	require.Equal(t, 4, ad.Entries.ChunkCount())
+	require.Equal(t, cid.Undef, ad.Entries.Remaining())
+
+	// With a budget, fetching stops once it is exhausted, reporting where the
+	// remaining entries start.
+	client, err = NewHttpProviderClient(pub.addrInfo(), WithEntriesRecursionLimit(1), WithEntriesAutoPaging(5, 0))
+	require.NoError(t, err)
+	defer client.Close()
+	ad, err = client.GetAdvertisement(ctx, cid.Undef)
+	require.NoError(t, err)
+	got = nil
+	var budgetErr *EntriesBudgetError
+	for {
+		mh, err := ad.Entries.Next()
+		if err != nil {
+			require.ErrorIs(t, err, ErrEntriesBudgetExhausted)
+			require.ErrorAs(t, err, &budgetErr)
+			break
+		}
+		got = append(got, mh)
+	}
+	// The chunks have 1, 3, 3 and 3 multihashes.
+	require.Len(t, got, 7)
+	require.Equal(t, 7, budgetErr.Multihashes)
+	require.Equal(t, 3, budgetErr.Chunks)
+	require.Equal(t, 3, budgetErr.Pages)
+	require.NotZero(t, budgetErr.Bytes)
+	require.Equal(t, ad.Entries.Remaining(), budgetErr.Remaining)
+
+	rest, err := client.GetEntries(ctx, budgetErr.Remaining).Drain()
+	require.NoError(t, err)
+	require.ElementsMatch(t, mhs, append(got, rest...))
+
+	_, err = NewHttpProviderClient(pub.addrInfo(), WithEntriesAutoPaging(-1, 0))
+	require.Error(t, err)
+	_, err = NewHttpProviderClient(pub.addrInfo(), WithEntriesAutoPaging(0, -1))
+	require.Error(t, err)
+}
+
+func TestHttpProviderClient_RequestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	addr, err := maurl.FromURL(u)
+	require.NoError(t, err)
+
+	client, err := NewHttpProviderClient(peer.AddrInfo{Addrs: []multiaddr.Multiaddr{addr}},
+		WithHttpTimeout(time.Minute), WithRequestTimeout(50*time.Millisecond), WithDialTimeout(time.Second))
+	require.NoError(t, err)
+	defer client.Close()
+	_, err = client.GetAdvertisement(context.Background(), cid.Undef)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	_, err = NewHttpProviderClient(peer.AddrInfo{Addrs: []multiaddr.Multiaddr{addr}}, WithRequestTimeout(-1))
+	require.Error(t, err)
+	_, err = NewHttpProviderClient(peer.AddrInfo{Addrs: []multiaddr.Multiaddr{addr}}, WithDialTimeout(-1))
+	require.Error(t, err)
+}
+
+func TestHttpProviderClient_AuthenticatedPublisher(t *testing.T) {
+	pub := newTestPublisher(t)
+	head := pub.publish(test.RandomMultihashes(1), false)
+	// Serve the publisher over HTTPS behind a proxy that requires a bearer
+	// token and a header.
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer fish" || r.Header.Get("X-Tenant") != "lobster" {
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+		pub.pub.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	addr, err := maurl.FromURL(u)
+	require.NoError(t, err)
+	addrInfo := peer.AddrInfo{ID: pub.id, Addrs: []multiaddr.Multiaddr{addr}}
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	tlsConfig := &tls.Config{RootCAs: pool}
+
+	client, err := NewHttpProviderClient(addrInfo, WithTLSConfig(tlsConfig), WithBearerToken("fish"))
+	require.NoError(t, err)
+	defer client.Close()
+	_, err = client.GetAdvertisement(context.Background(), cid.Undef)
+	require.ErrorIs(t, err, ErrUnauthorized)
+
+	client, err = NewHttpProviderClient(addrInfo, WithTLSConfig(tlsConfig), WithBearerToken("fish"), WithHttpHeader("X-Tenant", "lobster"))
+	require.NoError(t, err)
+	defer client.Close()
+	ad, err := client.GetAdvertisement(context.Background(), cid.Undef)
+	require.NoError(t, err)
+	require.Equal(t, head, ad.ID)
+
+	_, err = NewHttpProviderClient(addrInfo, WithHttpHeader(" ", "lobster"))
+	require.Error(t, err)
+}
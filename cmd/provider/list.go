@@ -2,18 +2,25 @@ package main
 
 import (
 	"bytes"
+	"encoding/base64"
+	"errors"
 	"fmt"
-	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
-	adminserver "github.com/ipni/index-provider/server/admin/http"
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/index-provider/cmd/provider/internal"
 	"github.com/urfave/cli/v2"
 )
 
 var ListCmd = &cli.Command{
 	Name:        "list",
-	Usage:       "List local paths to data",
+	Usage:       "List local paths to data or advertisements",
 	Aliases:     []string{"ls"},
-	Subcommands: []*cli.Command{listCarSubCmd},
+	Subcommands: []*cli.Command{listCarSubCmd, listAdSubCmd, listAdsSubCmd, listContextsSubCmd},
 }
 
 var listCarSubCmd = &cli.Command{
@@ -25,22 +32,310 @@ var listCarSubCmd = &cli.Command{
 	},
 }
 
-func doListCars(cctx *cli.Context) error {
-	resp, err := http.Get(adminAPIFlagValue + "/admin/list/car")
+var listAdSubCmd = &cli.Command{
+	Name:  "ad",
+	Usage: "Lists advertisements fetched from a provider.",
+	Description: `Fetches advertisements from the given provider and prints them. Listing starts from the latest
+advertisement unless --ad-cid is specified, and walks backwards through the chain following the
+PreviousID links until --count advertisements are listed, the advertisement given by --until-cid
+is reached, or the start of the chain is reached. Use --output to choose between human-readable
+text, a JSON array, or newline-delimited JSON with one advertisement per line.
+
+Synced blocks are persisted on disk until listing completes, so that re-running the command after
+a failure does not fetch them again. Progress is reported periodically to standard error.`,
+	Action: doListAd,
+	Flags: []cli.Flag{
+		providerAddrInfoFlag,
+		adCidFlag,
+		&cli.UintFlag{
+			Name:        "count",
+			Aliases:     []string{"depth", "n"},
+			Usage:       "The maximum number of advertisements to list. Zero lists all advertisements.",
+			Value:       1,
+			Destination: &listAdCount,
+		},
+		&cli.StringFlag{
+			Name:        "until-cid",
+			Usage:       "The CID of the advertisement at which to stop listing, exclusive.",
+			Destination: &listAdUntilCid,
+		},
+		&cli.StringFlag{
+			Name:        "output",
+			Aliases:     []string{"o"},
+			Usage:       "The output format, one of: text, json, ndjson.",
+			Value:       outputText,
+			Destination: &listAdOutput,
+		},
+		&cli.BoolFlag{
+			Name:        "print-entries",
+			Aliases:     []string{"e"},
+			Usage:       "Whether to fetch and print the advertisement entries.",
+			Destination: &listAdPrintEntries,
+		},
+		syncDirFlag,
+		progressIntervalFlag,
+	},
+}
+
+var listAdsSubCmd = &cli.Command{
+	Name:  "ads",
+	Usage: "Lists the advertisements published by an standalone instance of index-provider daemon.",
+	Description: `Lists the advertisements published by the provider, newest first, along with their context ID,
+whether they are removal advertisements, and their labels. Labels are key/value pairs that operators
+attach to advertisements when advertising, or afterwards via the admin API, and are kept by the
+provider without being published. Use --label to list only the advertisements that have the given
+label, e.g. --label dataset=wikipedia; if repeated, the advertisements must have all the labels.`,
+	Action: doListAds,
+	Flags: []cli.Flag{
+		adminAPIFlag,
+		&cli.StringSliceFlag{
+			Name:  "label",
+			Usage: "A label of the form key=value that the listed advertisements must have. May be repeated.",
+		},
+		&cli.UintFlag{
+			Name:        "limit",
+			Aliases:     []string{"n"},
+			Usage:       "The maximum number of advertisements to list. Zero lists all advertisements.",
+			Value:       100,
+			Destination: &listAdsLimit,
+		},
+		&cli.StringFlag{
+			Name:        "after",
+			Usage:       "The CID of the advertisement after which to start listing.",
+			Destination: &listAdsAfter,
+		},
+	},
+}
+
+// maxListAdsPageSize is the maximum number of advertisements that the admin
+// server lists per request.
+const maxListAdsPageSize = 1000
+
+var (
+	listAdsLimit uint
+	listAdsAfter string
+)
+
+var listContextsSubCmd = &cli.Command{
+	Name:  "contexts",
+	Usage: "Lists the context IDs currently advertised by an standalone instance of index-provider daemon.",
+	Description: `Lists the context IDs currently advertised by the provider along with the CID of their entries,
+the number of advertised multihashes, the retrieval protocols in their metadata, and the time at
+which they were last advertised. Context IDs are listed in ascending byte order, fetched from the
+admin server one page at a time. Use --limit and --after to list a subset of context IDs.`,
+	Action: doListContexts,
+	Flags: []cli.Flag{
+		adminAPIFlag,
+		&cli.UintFlag{
+			Name:        "page-size",
+			Usage:       "The number of context IDs to fetch from the admin server per request.",
+			Value:       1000,
+			Destination: &listContextsPageSize,
+		},
+		&cli.UintFlag{
+			Name:        "limit",
+			Aliases:     []string{"n"},
+			Usage:       "The maximum number of context IDs to list. Zero lists all context IDs.",
+			Destination: &listContextsLimit,
+		},
+		&cli.StringFlag{
+			Name:        "after",
+			Usage:       "Base64 encoded context ID after which to start listing.",
+			Destination: &listContextsAfter,
+		},
+	},
+}
+
+var (
+	listContextsPageSize uint
+	listContextsLimit    uint
+	listContextsAfter    string
+)
+
+var (
+	listAdCount        uint
+	listAdUntilCid     string
+	listAdOutput       string
+	listAdPrintEntries bool
+)
+
+func doListAd(cctx *cli.Context) error {
+	printer, err := newAdPrinter(cctx.App.Writer, listAdOutput, listAdPrintEntries)
 	if err != nil {
 		return err
 	}
+	var adCid, untilCid cid.Cid
+	if adCidFlagValue != "" {
+		if adCid, err = cid.Decode(adCidFlagValue); err != nil {
+			return fmt.Errorf("invalid ad-cid: %w", err)
+		}
+	}
+	if listAdUntilCid != "" {
+		if untilCid, err = cid.Decode(listAdUntilCid); err != nil {
+			return fmt.Errorf("invalid until-cid: %w", err)
+		}
+	}
 
-	if resp.StatusCode != http.StatusOK {
-		return errFromHttpResp(resp)
+	state, err := openSyncState(cctx)
+	if err != nil {
+		return err
+	}
+	pc, err := newProviderClient(internal.WithDatastore(state))
+	if err != nil {
+		state.close(false)
+		return err
 	}
 
-	var res adminserver.ListCarRes
-	if _, err := res.ReadFrom(resp.Body); err != nil {
-		return fmt.Errorf("received ok response from server but cannot decode response body. %v", err)
+	err = walkAds(cctx.Context, pc, adCid, untilCid, listAdCount, func(ad *internal.Advertisement) error {
+		state.visitedAd()
+		return printer.print(ad)
+	})
+	pc.Close()
+	if closeErr := state.close(err == nil); closeErr != nil {
+		log.Errorw("Failed to close sync state", "err", closeErr)
+	}
+	if err != nil {
+		return err
+	}
+	return printer.close()
+}
+
+func doListContexts(cctx *cli.Context) error {
+	if listContextsPageSize == 0 {
+		return errors.New("page-size must be greater than zero")
+	}
+	var after []byte
+	if listContextsAfter != "" {
+		var err error
+		after, err = base64.StdEncoding.DecodeString(listContextsAfter)
+		if err != nil {
+			return errors.New("after is not a valid base64 encoded string")
+		}
+	}
+	client, err := newAdminClient()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(cctx.App.Writer, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CONTEXT ID\tENTRIES\tMULTIHASHES\tPROTOCOLS\tPUBLISHED")
+	var listed uint
+	for {
+		pageSize := listContextsPageSize
+		if listContextsLimit != 0 && listContextsLimit-listed < pageSize {
+			pageSize = listContextsLimit - listed
+		}
+		res, err := client.ListContexts(cctx.Context, after, pageSize)
+		if err != nil {
+			return err
+		}
+		for _, info := range res.Contexts {
+			mhCount := "unknown"
+			if info.MultihashCount != nil {
+				mhCount = strconv.Itoa(*info.MultihashCount)
+			}
+			publishedAt := "unknown"
+			if info.PublishedAt != nil {
+				publishedAt = info.PublishedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", base64.StdEncoding.EncodeToString(info.ContextID),
+				info.Entries, mhCount, strings.Join(info.Protocols, ","), publishedAt)
+		}
+		listed += uint(len(res.Contexts))
+		if res.Next == nil {
+			break
+		}
+		after = res.Next
+		if listContextsLimit != 0 && listed >= listContextsLimit {
+			if err = tw.Flush(); err != nil {
+				return err
+			}
+			fmt.Fprintln(cctx.App.ErrWriter, "More context IDs available; list them with --after", base64.StdEncoding.EncodeToString(after))
+			return nil
+		}
+	}
+	return tw.Flush()
+}
+
+func doListAds(cctx *cli.Context) error {
+	labels := make(map[string]string)
+	for _, label := range cctx.StringSlice("label") {
+		k, v, ok := strings.Cut(label, "=")
+		if !ok || k == "" {
+			return fmt.Errorf("label %q is not of the form key=value", label)
+		}
+		labels[k] = v
+	}
+	after := cid.Undef
+	if listAdsAfter != "" {
+		var err error
+		if after, err = cid.Decode(listAdsAfter); err != nil {
+			return fmt.Errorf("after is not a valid CID: %w", err)
+		}
+	}
+	client, err := newAdminClient()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(cctx.App.Writer, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CID\tSEQUENCE\tCONTEXT ID\tREMOVAL\tLABELS")
+	var listed uint
+	for {
+		pageSize := uint(maxListAdsPageSize)
+		if listAdsLimit != 0 && listAdsLimit-listed < pageSize {
+			pageSize = listAdsLimit - listed
+		}
+		res, err := client.ListAds(cctx.Context, after, int(pageSize), labels)
+		if err != nil {
+			return err
+		}
+		for _, ad := range res.Ads {
+			seq := "-"
+			if ad.Sequence != nil {
+				seq = strconv.FormatUint(*ad.Sequence, 10)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%t\t%s\n", ad.ID, seq, base64.StdEncoding.EncodeToString(ad.ContextID),
+				ad.IsRm, formatLabels(ad.Labels))
+		}
+		listed += uint(len(res.Ads))
+		if res.Next == nil {
+			break
+		}
+		after = *res.Next
+		if listAdsLimit != 0 && listed >= listAdsLimit {
+			if err = tw.Flush(); err != nil {
+				return err
+			}
+			fmt.Fprintln(cctx.App.ErrWriter, "More advertisements available; list them with --after", after)
+			return nil
+		}
+	}
+	return tw.Flush()
+}
+
+// formatLabels formats the given labels as comma separated key=value pairs,
+// sorted by key.
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func doListCars(cctx *cli.Context) error {
+	client, err := newAdminClient()
+	if err != nil {
+		return err
+	}
+	paths, err := client.ListCars(cctx.Context)
+	if err != nil {
+		return err
 	}
 	var b bytes.Buffer
-	for _, path := range res.Paths {
+	for _, path := range paths {
 		b.WriteString(path)
 		b.WriteString(fmt.Sprintln())
 	}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ipni/go-libipni/test"
+	adminserver "github.com/ipni/index-provider/server/admin/http"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestListContexts_Pages(t *testing.T) {
+	entries := test.RandomCids(5)
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/admin/list/contexts", r.URL.Path)
+		requests++
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		require.NoError(t, err)
+		start := 0
+		if after := r.URL.Query().Get("after"); after != "" {
+			afterID, err := base64.StdEncoding.DecodeString(after)
+			require.NoError(t, err)
+			start, err = strconv.Atoi(string(afterID))
+			require.NoError(t, err)
+			start++
+		}
+		res := &adminserver.ListContextsRes{}
+		for i := start; i < len(entries) && len(res.Contexts) < limit; i++ {
+			count := i * 10
+			res.Contexts = append(res.Contexts, adminserver.ContextInfo{
+				ContextID:      []byte(strconv.Itoa(i)),
+				Entries:        entries[i],
+				MultihashCount: &count,
+				Protocols:      []string{"transport-bitswap"},
+			})
+		}
+		if n := len(res.Contexts); n != 0 && start+n < len(entries) {
+			res.Next = res.Contexts[n-1].ContextID
+		}
+		_, err = res.WriteTo(w)
+		require.NoError(t, err)
+	}))
+	defer server.Close()
+
+	run := func(args ...string) (string, string) {
+		var out, errOut bytes.Buffer
+		app := &cli.App{
+			Writer:    &out,
+			ErrWriter: &errOut,
+			Commands:  []*cli.Command{ListCmd},
+		}
+		err := app.Run(append([]string{"provider", "ls", "contexts", "-l", server.URL}, args...))
+		require.NoError(t, err)
+		return out.String(), errOut.String()
+	}
+
+	out, _ := run("--page-size", "2")
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 6)
+	require.Contains(t, lines[0], "CONTEXT ID")
+	for i, line := range lines[1:] {
+		require.Contains(t, line, base64.StdEncoding.EncodeToString([]byte(strconv.Itoa(i))))
+		require.Contains(t, line, entries[i].String())
+		require.Contains(t, line, "transport-bitswap")
+		require.Contains(t, line, "unknown")
+	}
+	require.Equal(t, 3, requests)
+
+	out, errOut := run("--page-size", "2", "--limit", "3")
+	require.Len(t, strings.Split(strings.TrimSpace(out), "\n"), 4)
+	require.Contains(t, errOut, "--after "+base64.StdEncoding.EncodeToString([]byte("2")))
+}
+
+func TestListAds_Labels(t *testing.T) {
+	adCids := test.RandomCids(3)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/admin/ads", r.URL.Path)
+		require.Equal(t, []string{"dataset=wikipedia"}, r.URL.Query()["label"])
+		res := &adminserver.ListAdsRes{}
+		for i, adCid := range adCids {
+			res.Ads = append(res.Ads, adminserver.AdInfo{
+				ID:        adCid,
+				ContextID: []byte(strconv.Itoa(i)),
+				Labels:    map[string]string{"dataset": "wikipedia", "tier": "hot"},
+			})
+			if i != 0 {
+				seq := uint64(i)
+				res.Ads[i].Sequence = &seq
+			}
+		}
+		_, err := res.WriteTo(w)
+		require.NoError(t, err)
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	app := &cli.App{Writer: &out, Commands: []*cli.Command{ListCmd}}
+	err := app.Run([]string{"provider", "ls", "ads", "-l", server.URL, "--label", "dataset=wikipedia"})
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 4)
+	require.Contains(t, lines[0], "LABELS")
+	for i, line := range lines[1:] {
+		require.Contains(t, line, adCids[i].String())
+		require.Contains(t, line, "dataset=wikipedia,tier=hot")
+		if i == 0 {
+			require.Contains(t, line, adCids[i].String()+"  -  ")
+		} else {
+			require.Contains(t, line, adCids[i].String()+"  "+strconv.Itoa(i)+"  ")
+		}
+	}
+
+	err = app.Run([]string{"provider", "ls", "ads", "-l", server.URL, "--label", "dataset"})
+	require.ErrorContains(t, err, "not of the form key=value")
+}
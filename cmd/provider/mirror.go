@@ -71,7 +71,7 @@ func init() {
 	}
 	Mirror.flags.storePath = &cli.PathFlag{
 		Name:        "storePath",
-		Usage:       "The path at which to persist the mirror state.",
+		Usage:       "The path at which to persist the mirror state. Mirroring resumes from the persisted state when restarted.",
 		DefaultText: "Ephemeral in-memory storage",
 	}
 	Mirror.flags.initAdRecurLimit = &cli.UintFlag{
@@ -260,6 +260,7 @@ func doMirror(cctx *cli.Context) error {
 	if err = m.Start(); err != nil {
 		return err
 	}
+	log.Infow("Mirror started", "source", Mirror.source.ID, "publisherAddrs", m.PublisherAddrs())
 
 	<-cctx.Done()
 	if err := msvr.Shutdown(context.Background()); err != nil {
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	findclient "github.com/ipni/go-libipni/find/client"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/index-provider/cmd/provider/internal/config"
+	"github.com/ipni/index-provider/selfcheck"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/multiformats/go-multicodec"
+	"github.com/multiformats/go-multihash"
+	"github.com/urfave/cli/v2"
+)
+
+var ProbeCmd = &cli.Command{
+	Name:  "probe",
+	Usage: "Checks that a CID is indexed and retrievable from the providers found by indexers",
+	Description: `Queries the given indexers for the providers of the CID, and attempts to retrieve the CID from
+each address of each provider over each retrieval protocol declared by its metadata, printing
+whether each attempt passed or failed. Blocks are fetched from HTTP gateways and over bitswap and
+checked against the CID, while graphsync addresses are only checked to be reachable and to speak
+graphsync. Fails unless every attempt passes.
+
+The indexers are read from Alerts.Indexers and DirectAnnounce.URLs of the provider configuration
+unless specified via --indexer.`,
+	Action: doProbe,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "cid",
+			Usage:    "The CID to probe.",
+			Required: true,
+		},
+		&cli.StringSliceFlag{
+			Name:    "indexer",
+			Usage:   "The URL of an indexer to query. Can be specified multiple times.",
+			Aliases: []string{"i"},
+		},
+		&cli.DurationFlag{
+			Name:  "timeout",
+			Usage: "The maximum time that each retrieval attempt may take.",
+			Value: 30 * time.Second,
+		},
+	},
+}
+
+// probeResult is the outcome of querying an indexer for a multihash, or of
+// attempting to retrieve it from an address of a provider found by an indexer.
+type probeResult struct {
+	indexer  string
+	provider peer.ID
+	protocol multicodec.Code
+	addr     multiaddr.Multiaddr
+	// skipped is the reason for which retrieval was not attempted, if any.
+	skipped string
+	err     error
+}
+
+func doProbe(cctx *cli.Context) error {
+	c, err := cid.Decode(cctx.String("cid"))
+	if err != nil {
+		return fmt.Errorf("invalid CID: %w", err)
+	}
+	indexers := cctx.StringSlice("indexer")
+	if len(indexers) == 0 {
+		if indexers, err = probeConfiguredIndexers(); err != nil {
+			return err
+		}
+	}
+
+	h, err := libp2p.New(libp2p.NoListenAddrs)
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+	prober := selfcheck.NewProber(h, nil)
+	defer prober.Close()
+
+	results := probeMultihash(cctx.Context, prober, indexers, c.Hash(), cctx.Duration("timeout"))
+	printProbeResults(cctx.App.Writer, results)
+
+	var failed, attempted int
+	for _, r := range results {
+		if r.err != nil {
+			failed++
+		}
+		if r.skipped == "" && r.protocol != 0 {
+			attempted++
+		}
+	}
+	if failed != 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(results))
+	}
+	if attempted == 0 {
+		return errors.New("no retrieval was attempted")
+	}
+	return nil
+}
+
+// probeConfiguredIndexers returns the indexers in the provider configuration.
+func probeConfiguredIndexers() ([]string, error) {
+	cfg, err := config.Load("")
+	if err != nil {
+		return nil, fmt.Errorf("cannot load config file; specify the indexers instead: %w", err)
+	}
+	var indexers []string
+	for _, indexer := range append(slices.Clone(cfg.Alerts.Indexers), cfg.DirectAnnounce.URLs...) {
+		if !slices.Contains(indexers, indexer) {
+			indexers = append(indexers, indexer)
+		}
+	}
+	if len(indexers) == 0 {
+		return nil, errors.New("no indexers are configured; specify the indexers instead")
+	}
+	return indexers, nil
+}
+
+// probeMultihash queries each indexer for the providers of the multihash, and
+// attempts to retrieve it from each of their addresses over each of their
+// retrieval protocols. Retrieval is attempted once for the same provider,
+// protocol and address found by several indexers.
+func probeMultihash(ctx context.Context, prober *selfcheck.Prober, indexers []string, mh multihash.Multihash, timeout time.Duration) []probeResult {
+	type probeKey struct {
+		provider peer.ID
+		protocol multicodec.Code
+		addr     string
+	}
+	probed := make(map[probeKey]error)
+
+	var results []probeResult
+	for _, indexer := range indexers {
+		client, err := findclient.New(indexer)
+		if err != nil {
+			results = append(results, probeResult{indexer: indexer, err: err})
+			continue
+		}
+		resp, err := client.Find(ctx, mh)
+		if err != nil {
+			results = append(results, probeResult{indexer: indexer, err: err})
+			continue
+		}
+		var found bool
+		for _, mhResult := range resp.MultihashResults {
+			for _, pr := range mhResult.ProviderResults {
+				if pr.Provider == nil {
+					continue
+				}
+				found = true
+				base := probeResult{indexer: indexer, provider: pr.Provider.ID}
+				md := metadata.Default.New()
+				if err = md.UnmarshalBinary(pr.Metadata); err != nil {
+					base.err = fmt.Errorf("cannot decode metadata: %w", err)
+					results = append(results, base)
+					continue
+				}
+				for _, protocol := range md.Protocols() {
+					r := base
+					r.protocol = protocol
+					var attempted bool
+					for _, addr := range pr.Provider.Addrs {
+						if !selfcheck.Supports(protocol, addr) {
+							continue
+						}
+						attempted = true
+						r.addr = addr
+						key := probeKey{pr.Provider.ID, protocol, addr.String()}
+						var ok bool
+						if r.err, ok = probed[key]; !ok {
+							probeCtx, cancel := context.WithTimeout(ctx, timeout)
+							r.err = prober.Probe(probeCtx, protocol, pr.Provider.ID, addr, mh)
+							cancel()
+							probed[key] = r.err
+						}
+						results = append(results, r)
+					}
+					if !attempted {
+						r.skipped = "no address to retrieve from"
+						if !slices.Contains(selfcheck.Protocols, protocol) {
+							r.skipped = "protocol cannot be probed"
+						}
+						results = append(results, r)
+					}
+				}
+			}
+		}
+		if !found {
+			results = append(results, probeResult{indexer: indexer, skipped: "not found"})
+		}
+	}
+	return results
+}
+
+// printProbeResults prints the results as a matrix of each indexer, provider,
+// protocol and address with whether the check passed or failed.
+func printProbeResults(w io.Writer, results []probeResult) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "INDEXER\tPROVIDER\tPROTOCOL\tADDRESS\tRESULT")
+	for _, r := range results {
+		provider, protocol, addr := "-", "-", "-"
+		if r.provider != "" {
+			provider = r.provider.String()
+		}
+		if r.protocol != 0 {
+			protocol = r.protocol.String()
+		}
+		if r.addr != nil {
+			addr = r.addr.String()
+		}
+		result := "PASS"
+		switch {
+		case r.err != nil:
+			result = "FAIL: " + r.err.Error()
+		case r.skipped != "":
+			result = "SKIP: " + r.skipped
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.indexer, provider, protocol, addr, result)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/go-libipni/find/model"
+	"github.com/ipni/go-libipni/test"
+	"github.com/ipni/index-provider/httpgateway"
+	"github.com/ipni/index-provider/selfcheck"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/multiformats/go-multihash"
+	"github.com/multiformats/go-varint"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_probeMultihash(t *testing.T) {
+	data := []byte("fish")
+	mh, err := multihash.Sum(data, multihash.SHA2_256, -1)
+	require.NoError(t, err)
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ipfs/"+cid.NewCidV1(cid.Raw, mh).String() {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(data)
+	}))
+	t.Cleanup(gateway.Close)
+	brokenGateway := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(brokenGateway.Close)
+
+	providerID, _, _ := test.RandomIdentity()
+	gatewayAddrs, err := httpgateway.AddrInfo(providerID, gateway.URL, brokenGateway.URL)
+	require.NoError(t, err)
+	md := httpgateway.Metadata()
+	gatewayMetadata, err := md.MarshalBinary()
+	require.NoError(t, err)
+	unknownMetadata := append(append(varint.ToUvarint(0x3f0000), varint.ToUvarint(7)...), "lobster"...)
+	otherID, _, _ := test.RandomIdentity()
+
+	// The indexer returns the gateways of one provider, and another provider
+	// that is retrievable over a protocol that cannot be probed.
+	indexer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/multihash/"+mh.B58String() {
+			http.NotFound(w, r)
+			return
+		}
+		body, err := model.MarshalFindResponse(&model.FindResponse{
+			MultihashResults: []model.MultihashResult{{
+				Multihash: mh,
+				ProviderResults: []model.ProviderResult{
+					{ContextID: []byte("fish"), Metadata: gatewayMetadata, Provider: gatewayAddrs},
+					{ContextID: []byte("fish"), Metadata: unknownMetadata, Provider: &peer.AddrInfo{
+						ID:    otherID,
+						Addrs: []multiaddr.Multiaddr{multiaddr.StringCast("/ip4/127.0.0.1/tcp/1")},
+					}},
+				},
+			}},
+		})
+		require.NoError(t, err)
+		_, _ = w.Write(body)
+	}))
+	t.Cleanup(indexer.Close)
+	emptyIndexer := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(emptyIndexer.Close)
+
+	prober := selfcheck.NewProber(nil, nil)
+	results := probeMultihash(context.Background(), prober, []string{indexer.URL, emptyIndexer.URL}, mh, time.Second)
+	require.Len(t, results, 4)
+	require.NoError(t, results[0].err)
+	require.Equal(t, gatewayAddrs.Addrs[0], results[0].addr)
+	require.ErrorContains(t, results[1].err, "status 404")
+	require.Equal(t, gatewayAddrs.Addrs[1], results[1].addr)
+	require.Equal(t, otherID, results[2].provider)
+	require.Equal(t, "protocol cannot be probed", results[2].skipped)
+	require.Equal(t, emptyIndexer.URL, results[3].indexer)
+	require.Equal(t, "not found", results[3].skipped)
+
+	var out bytes.Buffer
+	printProbeResults(&out, results)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 5)
+	require.Regexp(t, `^INDEXER\s+PROVIDER\s+PROTOCOL\s+ADDRESS\s+RESULT$`, lines[0])
+	require.Contains(t, lines[1], "transport-ipfs-gateway-http")
+	require.True(t, strings.HasSuffix(lines[1], "PASS"))
+	require.Contains(t, lines[2], "FAIL: gateway responded with status 404")
+	require.True(t, strings.HasSuffix(lines[4], "SKIP: not found"))
+}
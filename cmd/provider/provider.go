@@ -53,20 +53,65 @@ func run() int {
 		Commands: []*cli.Command{
 			AnnounceCmd,
 			AnnounceHttpCmd,
+			AuditCmd,
+			BackfillCmd,
+			ConfigCmd,
 			ConnectCmd,
 			DaemonCmd,
+			DiffCmd,
+			ExportChainCmd,
+			FetchEntriesCmd,
+			GossipCmd,
+			HeadCmd,
 			ImportCmd,
+			ImportChainCmd,
 			IndexCmd,
 			InitCmd,
+			InspectCmd,
 			ListCmd,
+			ProbeCmd,
+			QueueCmd,
+			ReloadCmd,
 			RemoveCmd,
+			RotateKeyCmd,
+			StatsCmd,
+			StatusCmd,
+			VerifyCmd,
 			Mirror.Command,
 		},
+		Flags: []cli.Flag{
+			errorFormatFlag,
+			adminTokenFlag,
+			adminCACertFlag,
+			adminClientCertFlag,
+			adminClientKeyFlag,
+			publisherTokenFlag,
+			publisherHeaderFlag,
+			publisherCACertFlag,
+			publisherClientCertFlag,
+			publisherClientKeyFlag,
+			dialTimeoutFlag,
+			requestTimeoutFlag,
+			reuseSessionFlag,
+		},
+		Before: func(cctx *cli.Context) error {
+			if errorFormatFlagValue != errorFormatText && errorFormatFlagValue != errorFormatJson {
+				return withErrorClass(errClassUsage, fmt.Errorf("unknown error format: %s", errorFormatFlagValue))
+			}
+			return nil
+		},
+		After: func(*cli.Context) error {
+			return closeProviderClientSession()
+		},
+		OnUsageError: onUsageError,
+		// Exit codes are determined from returned errors below, rather than
+		// by exiting from within the app.
+		ExitErrHandler: func(*cli.Context, error) {},
 	}
+	classifyUsageErrors(app.Commands)
 
 	if err := app.RunContext(ctx, os.Args); err != nil {
-		fmt.Fprintln(app.ErrWriter, err)
-		return 1
+		return writeError(app.ErrWriter, errorFormatFlagValue, err)
 	}
-	return 0
+	return exitCodeOK
 }
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipni/go-libipni/maurl"
+	"github.com/ipni/go-libipni/mautil"
+	"github.com/ipni/index-provider/cmd/provider/internal"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// parseProviderAddrInfo parses the publisher address given either as a
+// multiaddr with peer ID or as an HTTP URL.
+func parseProviderAddrInfo(s string) (peer.AddrInfo, error) {
+	if strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") {
+		u, err := url.Parse(s)
+		if err != nil {
+			return peer.AddrInfo{}, fmt.Errorf("invalid provider URL: %w", err)
+		}
+		addr, err := maurl.FromURL(u)
+		if err != nil {
+			return peer.AddrInfo{}, fmt.Errorf("invalid provider URL: %w", err)
+		}
+		return peer.AddrInfo{Addrs: []multiaddr.Multiaddr{addr}}, nil
+	}
+	addrInfo, err := peer.AddrInfoFromString(s)
+	if err != nil {
+		return peer.AddrInfo{}, fmt.Errorf("invalid provider addr info: %w", err)
+	}
+	return *addrInfo, nil
+}
+
+// providerClientSession holds the libp2p host and the datastore shared by the
+// provider clients created within the process if the reuse-session flag is
+// set. Both are created once needed, and closed once the command completes.
+var providerClientSession struct {
+	mu sync.Mutex
+	h  host.Host
+	ds datastore.Batching
+}
+
+// newProviderClient instantiates a provider client for the publisher given
+// by the provider-addr-info flag. HTTP is used if the publisher only has HTTP
+// addresses, and libp2p otherwise. The client authenticates to HTTP publishers
+// as set by the publisher flags, times out as set by the dial-timeout and
+// request-timeout flags, and uses the session shared within
+// the process if the reuse-session flag is set. The given options take
+// precedence.
+func newProviderClient(o ...internal.Option) (internal.ProviderClient, error) {
+	addrInfo, err := parseProviderAddrInfo(providerAddrInfoFlagValue)
+	if err != nil {
+		return nil, err
+	}
+	opts := []internal.Option{
+		internal.WithDialTimeout(dialTimeoutFlagValue),
+		internal.WithRequestTimeout(requestTimeoutFlagValue),
+	}
+	authOpts, err := publisherAuthOptions()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, authOpts...)
+	if reuseSessionFlagValue {
+		sessionOpts, err := providerClientSessionOptions()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, sessionOpts...)
+	}
+	o = append(opts, o...)
+	if len(addrInfo.Addrs) != 0 && len(mautil.FindHTTPAddrs(addrInfo.Addrs)) == len(addrInfo.Addrs) {
+		return internal.NewHttpProviderClient(addrInfo, o...)
+	}
+	return internal.NewLibp2pProviderClient(addrInfo, o...)
+}
+
+// publisherAuthOptions returns the options with which provider clients
+// authenticate to HTTP publishers, as set by the publisher flags.
+func publisherAuthOptions() ([]internal.Option, error) {
+	var opts []internal.Option
+	if publisherTokenFlagValue != "" {
+		opts = append(opts, internal.WithBearerToken(publisherTokenFlagValue))
+	}
+	for _, header := range publisherHeaderFlagValue.Value() {
+		key, value, ok := strings.Cut(header, ":")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, withErrorClass(errClassUsage, fmt.Errorf("publisher header %q is not of the form \"Key: Value\"", header))
+		}
+		opts = append(opts, internal.WithHttpHeader(strings.TrimSpace(key), strings.TrimSpace(value)))
+	}
+	if publisherCACertFlagValue != "" || publisherClientCertFlagValue != "" || publisherClientKeyFlagValue != "" {
+		tlsConfig, err := loadTLSConfig("publisher", publisherCACertFlagValue, publisherClientCertFlagValue, publisherClientKeyFlagValue)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, internal.WithTLSConfig(tlsConfig))
+	}
+	return opts, nil
+}
+
+// providerClientSessionOptions returns the options with which provider clients
+// use the session shared within the process, creating it if needed.
+func providerClientSessionOptions() ([]internal.Option, error) {
+	providerClientSession.mu.Lock()
+	defer providerClientSession.mu.Unlock()
+	if providerClientSession.h == nil {
+		h, err := libp2p.New(libp2p.NoListenAddrs)
+		if err != nil {
+			return nil, err
+		}
+		providerClientSession.h = h
+		providerClientSession.ds = dssync.MutexWrap(datastore.NewMapDatastore())
+	}
+	return []internal.Option{
+		internal.WithHost(providerClientSession.h),
+		internal.WithDatastore(providerClientSession.ds),
+	}, nil
+}
+
+// closeProviderClientSession closes the session shared by provider clients, if
+// any.
+func closeProviderClientSession() error {
+	providerClientSession.mu.Lock()
+	defer providerClientSession.mu.Unlock()
+	if providerClientSession.h == nil {
+		return nil
+	}
+	err := providerClientSession.h.Close()
+	providerClientSession.h = nil
+	providerClientSession.ds = nil
+	return err
+}
+
+// walkAds walks backwards through the advertisement chain starting from the
+// given advertisement, or the latest one if start is cid.Undef, calling visit
+// for each advertisement. The walk stops once count advertisements are
+// visited, the advertisement until is reached, exclusive, or the start of the
+// chain is reached. A count of zero visits all advertisements.
+func walkAds(ctx context.Context, pc internal.ProviderClient, start, until cid.Cid, count uint, visit func(*internal.Advertisement) error) error {
+	next := start
+	var visited uint
+	for {
+		ad, err := pc.GetAdvertisement(ctx, next)
+		if err != nil {
+			return err
+		}
+		if ad.ID == until {
+			return nil
+		}
+		if err = visit(ad); err != nil {
+			return err
+		}
+		visited++
+		if ad.PreviousID == cid.Undef || ad.PreviousID == until {
+			return nil
+		}
+		if count != 0 && visited >= count {
+			return nil
+		}
+		next = ad.PreviousID
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/index-provider/cmd/provider/internal"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+// fakeProviderClient serves advertisements from a map, with the first ad in
+// the given chain as head.
+type fakeProviderClient struct {
+	head cid.Cid
+	ads  map[cid.Cid]*internal.Advertisement
+}
+
+func newFakeProviderClient(chain []*internal.Advertisement) *fakeProviderClient {
+	pc := &fakeProviderClient{ads: make(map[cid.Cid]*internal.Advertisement)}
+	for _, ad := range chain {
+		pc.ads[ad.ID] = ad
+	}
+	if len(chain) != 0 {
+		pc.head = chain[0].ID
+	}
+	return pc
+}
+
+func (pc *fakeProviderClient) GetAdvertisement(_ context.Context, id cid.Cid) (*internal.Advertisement, error) {
+	if id == cid.Undef {
+		if pc.head == cid.Undef {
+			return nil, internal.ErrNoHead
+		}
+		id = pc.head
+	}
+	ad, ok := pc.ads[id]
+	if !ok {
+		return nil, context.DeadlineExceeded
+	}
+	return ad, nil
+}
+
+// GetEntries returns an iterator over no entries, since the fake serves
+// advertisements only.
+func (pc *fakeProviderClient) GetEntries(context.Context, cid.Cid) *internal.EntriesIterator {
+	return &internal.EntriesIterator{}
+}
+
+func (pc *fakeProviderClient) ChainStats(context.Context, cid.Cid, int) (*internal.ChainStats, error) {
+	return nil, errors.New("not supported by fake")
+}
+
+func (pc *fakeProviderClient) Close() error { return nil }
+
+func Test_walkAds(t *testing.T) {
+	chain := testAds(t, 5)
+	pc := newFakeProviderClient(chain)
+
+	walk := func(start, until cid.Cid, count uint) []cid.Cid {
+		var got []cid.Cid
+		err := walkAds(context.Background(), pc, start, until, count, func(ad *internal.Advertisement) error {
+			got = append(got, ad.ID)
+			return nil
+		})
+		require.NoError(t, err)
+		return got
+	}
+	ids := func(ads []*internal.Advertisement) []cid.Cid {
+		var got []cid.Cid
+		for _, ad := range ads {
+			got = append(got, ad.ID)
+		}
+		return got
+	}
+
+	require.Equal(t, ids(chain[:1]), walk(cid.Undef, cid.Undef, 1))
+	require.Equal(t, ids(chain), walk(cid.Undef, cid.Undef, 0))
+	require.Equal(t, ids(chain[1:3]), walk(chain[1].ID, cid.Undef, 2))
+	require.Equal(t, ids(chain[:3]), walk(cid.Undef, chain[3].ID, 0))
+	require.Equal(t, ids(chain[:2]), walk(cid.Undef, chain[3].ID, 2))
+	require.Empty(t, walk(cid.Undef, chain[0].ID, 0))
+}
+
+func Test_providerClientSession(t *testing.T) {
+	t.Cleanup(func() { require.NoError(t, closeProviderClientSession()) })
+
+	first, err := providerClientSessionOptions()
+	require.NoError(t, err)
+	h := providerClientSession.h
+	require.NotNil(t, h)
+	second, err := providerClientSessionOptions()
+	require.NoError(t, err)
+	require.Len(t, second, len(first))
+	require.Equal(t, h, providerClientSession.h)
+
+	require.NoError(t, closeProviderClientSession())
+	require.Nil(t, providerClientSession.h)
+	require.Nil(t, providerClientSession.ds)
+	require.NoError(t, closeProviderClientSession())
+}
+
+func Test_publisherAuthOptions(t *testing.T) {
+	t.Cleanup(func() {
+		publisherTokenFlagValue = ""
+		publisherHeaderFlagValue = cli.NewStringSlice()
+	})
+
+	opts, err := publisherAuthOptions()
+	require.NoError(t, err)
+	require.Empty(t, opts)
+
+	publisherTokenFlagValue = "fish"
+	publisherHeaderFlagValue = cli.NewStringSlice("X-Tenant: lobster")
+	opts, err = publisherAuthOptions()
+	require.NoError(t, err)
+	require.Len(t, opts, 2)
+
+	publisherHeaderFlagValue = cli.NewStringSlice("X-Tenant")
+	_, err = publisherAuthOptions()
+	require.Equal(t, errClassUsage, classifyError(err))
+}
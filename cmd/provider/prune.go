@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipni/index-provider/engine"
+)
+
+// pruner periodically prunes the advertisement chain according to the
+// retention policy of the engine.
+type pruner struct {
+	eng    *engine.Engine
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func startPruner(eng *engine.Engine, interval time.Duration) *pruner {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &pruner{
+		eng:    eng,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go p.run(ctx, interval)
+	return p
+}
+
+func (p *pruner) run(ctx context.Context, interval time.Duration) {
+	defer close(p.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := p.eng.Prune(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Errorw("Failed to prune advertisement chain", "err", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// stop stops pruning and waits for ongoing pruning to finish.
+func (p *pruner) stop() {
+	p.cancel()
+	<-p.done
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	adminserver "github.com/ipni/index-provider/server/admin/http"
+	"github.com/urfave/cli/v2"
+)
+
+var QueueCmd = &cli.Command{
+	Name:  "queue",
+	Usage: "Shows and reorders the publish queue of a running provider",
+	Description: `Fetches the publish queue from the admin server of a running provider: the advertisement being
+published, if any, followed by the publishes waiting for it in the order in which they will be
+published. Waiting publishes are ordered by priority, removals first, then advertisements of new
+context IDs, then metadata updates of advertised context IDs, and then by when they were queued.
+Use the reorder subcommand to change the priority of a waiting publish or to publish it next.`,
+	Action: doQueue,
+	Flags: []cli.Flag{
+		adminAPIFlag,
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Usage:   "The output format, one of: table, json.",
+			Value:   outputTable,
+		},
+	},
+	Subcommands: []*cli.Command{queueReorderSubCmd},
+}
+
+var queueReorderSubCmd = &cli.Command{
+	Name:  "reorder",
+	Usage: "Changes the priority of a waiting publish",
+	Description: `Sets the priority of the waiting publish with the given ID, as listed by the queue command, to
+one of removal, new-data or metadata, and or moves it to the front of the queue, so that it is the
+next to be published.`,
+	Action: doQueueReorder,
+	Flags: []cli.Flag{
+		adminAPIFlag,
+		&cli.Uint64Flag{
+			Name:     "id",
+			Usage:    "The ID of the waiting publish.",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "priority",
+			Usage: "The priority to set, one of: removal, new-data, metadata.",
+		},
+		&cli.BoolFlag{
+			Name:  "front",
+			Usage: "Whether to move the publish to the front of the queue.",
+		},
+	},
+}
+
+func doQueue(cctx *cli.Context) error {
+	output := cctx.String("output")
+	if output != outputTable && output != outputJson {
+		return fmt.Errorf("unknown output format %q; must be one of %s or %s", output, outputTable, outputJson)
+	}
+
+	client, err := newAdminClient()
+	if err != nil {
+		return err
+	}
+	queue, err := client.ListPublishQueue(cctx.Context)
+	if err != nil {
+		return err
+	}
+
+	if output == outputJson {
+		enc := json.NewEncoder(cctx.App.Writer)
+		enc.SetIndent("", "  ")
+		return enc.Encode(queue)
+	}
+	if len(queue) == 0 {
+		fmt.Fprintf(cctx.App.Writer, "The publish queue is empty.\n")
+		return nil
+	}
+	tw := tabwriter.NewWriter(cctx.App.Writer, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "ID\tSTATE\tKIND\tPRIORITY\tQUEUED\tCONTEXT ID\n")
+	for _, pub := range queue {
+		printQueuedPublish(tw, pub)
+	}
+	return tw.Flush()
+}
+
+func doQueueReorder(cctx *cli.Context) error {
+	req := &adminserver.ReorderPublishReq{
+		Priority: cctx.String("priority"),
+		Front:    cctx.Bool("front"),
+	}
+	if req.Priority == "" && !req.Front {
+		return errors.New("priority or front must be specified")
+	}
+
+	client, err := newAdminClient()
+	if err != nil {
+		return err
+	}
+	pub, err := client.ReorderPublish(cctx.Context, cctx.Uint64("id"), req)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(cctx.App.Writer, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "ID\tSTATE\tKIND\tPRIORITY\tQUEUED\tCONTEXT ID\n")
+	printQueuedPublish(tw, *pub)
+	return tw.Flush()
+}
+
+func printQueuedPublish(tw *tabwriter.Writer, pub adminserver.QueuedPublishInfo) {
+	state := "waiting"
+	if pub.Publishing {
+		state = "publishing"
+	}
+	fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", pub.ID, state, pub.Kind, pub.Priority,
+		pub.Queued.Format(time.RFC3339), base64.StdEncoding.EncodeToString(pub.ContextID))
+}
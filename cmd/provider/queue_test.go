@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	adminserver "github.com/ipni/index-provider/server/admin/http"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestQueueCmd(t *testing.T) {
+	queued := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	queue := []adminserver.QueuedPublishInfo{
+		{ID: 1, Kind: "put", Priority: "new-data", ContextID: []byte("fish"), Queued: queued, Publishing: true},
+		{ID: 3, Kind: "remove", Priority: "removal", ContextID: []byte("lobster"), Queued: queued},
+	}
+	var reorderReq adminserver.ReorderPublishReq
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/admin/queue":
+			res := &adminserver.ListPublishQueueRes{Queue: queue}
+			_, err := res.WriteTo(w)
+			require.NoError(t, err)
+		case "/admin/queue/3":
+			require.Equal(t, http.MethodPost, r.Method)
+			_, err := reorderReq.ReadFrom(r.Body)
+			require.NoError(t, err)
+			res := queue[1]
+			res.Priority = "metadata"
+			_, err = res.WriteTo(w)
+			require.NoError(t, err)
+		default:
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	run := func(args ...string) string {
+		var out bytes.Buffer
+		app := &cli.App{
+			Writer:   &out,
+			Commands: []*cli.Command{QueueCmd},
+		}
+		require.NoError(t, app.Run(append([]string{"provider", "queue"}, args...)))
+		return out.String()
+	}
+
+	out := run("-l", server.URL)
+	require.Regexp(t, `1\s+publishing\s+put\s+new-data\s+2023-05-01T12:00:00Z\s+ZmlzaA==\n`, out)
+	require.Regexp(t, `3\s+waiting\s+remove\s+removal\s+2023-05-01T12:00:00Z\s+bG9ic3Rlcg==\n`, out)
+
+	var res []adminserver.QueuedPublishInfo
+	require.NoError(t, json.Unmarshal([]byte(run("-l", server.URL, "-o", "json")), &res))
+	require.Equal(t, queue, res)
+
+	out = run("reorder", "-l", server.URL, "--id", "3", "--priority", "metadata")
+	require.Equal(t, adminserver.ReorderPublishReq{Priority: "metadata"}, reorderReq)
+	require.Regexp(t, `3\s+waiting\s+remove\s+metadata\s+`, out)
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/ipni/index-provider/cmd/provider/internal/config"
+	"github.com/ipni/index-provider/engine"
+	"github.com/ipni/index-provider/engine/policy"
+	adminserver "github.com/ipni/index-provider/server/admin/http"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/urfave/cli/v2"
+)
+
+var ReloadCmd = &cli.Command{
+	Name:  "reload",
+	Usage: "Reloads the config of a running provider",
+	Description: `Makes a running provider re-read its config file and apply the changed settings that can be
+changed at runtime: announce URLs, the re-announce interval, the sync policy, log levels and admin
+API rate limits and the retrieval addresses, upon which the advertised content is republished with
+the new addresses. Changed settings that require a restart are reported. Sending SIGHUP to the
+daemon has the same effect.`,
+	Action: doReload,
+	Flags: []cli.Flag{
+		adminAPIFlag,
+	},
+}
+
+func doReload(cctx *cli.Context) error {
+	client, err := newAdminClient()
+	if err != nil {
+		return err
+	}
+	res, err := client.Reload(cctx.Context)
+	if err != nil {
+		return err
+	}
+	if len(res.Applied) == 0 && len(res.RestartRequired) == 0 {
+		fmt.Fprintln(cctx.App.Writer, "Config unchanged")
+		return nil
+	}
+	for _, field := range res.Applied {
+		fmt.Fprintln(cctx.App.Writer, "Applied:", field)
+	}
+	for _, field := range res.RestartRequired {
+		fmt.Fprintln(cctx.App.Writer, "Restart required:", field)
+	}
+	return nil
+}
+
+// reloadableFields are the config fields that are applied when the config is
+// reloaded. Changes to other fields require a restart.
+var reloadableFields = map[string]bool{
+	"DirectAnnounce.URLs":                true,
+	"DirectAnnounce.ReannounceInterval":  true,
+	"Ingest.SyncPolicy.Allow":            true,
+	"Ingest.SyncPolicy.Except":           true,
+	"Logging.Level":                      true,
+	"Logging.Levels":                     true,
+	"AdminServer.RateLimits":             true,
+	"ProviderServer.RetrievalMultiaddrs": true,
+}
+
+// reloader reloads the config of the daemon, and applies the changed settings
+// that can be changed at runtime.
+type reloader struct {
+	mutex sync.Mutex
+	// cfg is the config in effect, i.e. the loaded config with the reloaded
+	// changes that were applied.
+	cfg *config.Config
+	// logLevel is the log level given to the daemon command.
+	logLevel string
+
+	eng         *engine.Engine
+	h           host.Host
+	syncPolicy  *policy.Policy
+	adminSvr    *adminserver.Server
+	reannouncer *reannouncer
+}
+
+// reload re-reads the config file, and applies its changes to the fields in
+// reloadableFields. The changes to other fields are reported as requiring a
+// restart. No changes are applied if the config is invalid.
+func (r *reloader) reload(ctx context.Context) (*adminserver.ReloadRes, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	cfg, err := config.Load("")
+	if err != nil {
+		return nil, fmt.Errorf("cannot load config file: %w", err)
+	}
+	res := &adminserver.ReloadRes{
+		Applied:         []string{},
+		RestartRequired: []string{},
+	}
+	changed := make(map[string]bool)
+	for _, field := range r.cfg.Diff(cfg) {
+		if reloadableFields[field] {
+			res.Applied = append(res.Applied, field)
+			changed[field] = true
+		} else {
+			res.RestartRequired = append(res.RestartRequired, field)
+		}
+	}
+
+	// Validate all changes before applying any.
+	if changed["Logging.Level"] || changed["Logging.Levels"] {
+		if err = validateLogLevels(cfg.Logging); err != nil {
+			return nil, err
+		}
+	}
+	if changed["DirectAnnounce.URLs"] {
+		if _, err = cfg.DirectAnnounce.ParseURLs(); err != nil {
+			return nil, err
+		}
+	}
+	var syncPolicy *policy.Policy
+	if changed["Ingest.SyncPolicy.Allow"] || changed["Ingest.SyncPolicy.Except"] {
+		if syncPolicy, err = policy.New(cfg.Ingest.SyncPolicy.Allow, cfg.Ingest.SyncPolicy.Except); err != nil {
+			return nil, err
+		}
+	}
+	var retrievalAddrs []multiaddr.Multiaddr
+	if changed["ProviderServer.RetrievalMultiaddrs"] {
+		if retrievalAddrs, err = reloadedRetrievalAddrs(cfg, r.h); err != nil {
+			return nil, err
+		}
+	}
+
+	if changed["AdminServer.RateLimits"] {
+		if err = r.adminSvr.SetRateLimits(adminRateLimits(cfg.AdminServer.RateLimits)); err != nil {
+			return nil, err
+		}
+		r.cfg.AdminServer.RateLimits = cfg.AdminServer.RateLimits
+	}
+	if changed["Logging.Level"] || changed["Logging.Levels"] {
+		if err = applyLogLevels(r.logLevel, cfg.Logging); err != nil {
+			return nil, err
+		}
+		r.cfg.Logging = cfg.Logging
+	}
+	if changed["DirectAnnounce.URLs"] {
+		if err = r.eng.SetDirectAnnounce(cfg.DirectAnnounce.URLs...); err != nil {
+			return nil, err
+		}
+		r.cfg.DirectAnnounce.URLs = cfg.DirectAnnounce.URLs
+	}
+	if syncPolicy != nil {
+		r.syncPolicy.Copy(syncPolicy)
+		r.cfg.Ingest.SyncPolicy = cfg.Ingest.SyncPolicy
+	}
+	if changed["DirectAnnounce.ReannounceInterval"] {
+		r.reannouncer.setInterval(time.Duration(cfg.DirectAnnounce.ReannounceInterval))
+		r.cfg.DirectAnnounce.ReannounceInterval = cfg.DirectAnnounce.ReannounceInterval
+	}
+	if retrievalAddrs != nil {
+		// Republish the advertised content with the new addresses.
+		if _, err = r.eng.NotifyAddrsChanged(ctx, retrievalAddrs); err != nil {
+			return nil, fmt.Errorf("cannot republish content with new retrieval addresses: %w", err)
+		}
+		r.cfg.ProviderServer.RetrievalMultiaddrs = cfg.ProviderServer.RetrievalMultiaddrs
+	}
+
+	if len(res.RestartRequired) != 0 {
+		log.Warnw("Reloaded config has changes that require a restart", "fields", res.RestartRequired)
+	}
+	log.Infow("Reloaded config", "applied", res.Applied)
+	return res, nil
+}
+
+// reloadedRetrievalAddrs returns the retrieval addresses to advertise once the
+// given config is reloaded. See: daemonRetrievalAddrs.
+func reloadedRetrievalAddrs(cfg *config.Config, h host.Host) ([]multiaddr.Multiaddr, error) {
+	addrs, err := daemonRetrievalAddrs(cfg, h)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		if h == nil {
+			return nil, errors.New("no retrieval addresses configured")
+		}
+		return h.Addrs(), nil
+	}
+	maddrs := make([]multiaddr.Multiaddr, len(addrs))
+	for i, addr := range addrs {
+		if maddrs[i], err = multiaddr.NewMultiaddr(addr); err != nil {
+			return nil, fmt.Errorf("bad retrieval address %q: %w", addr, err)
+		}
+	}
+	return maddrs, nil
+}
+
+// adminRateLimits converts the configured rate limits of admin API routes to
+// those of the admin server.
+func adminRateLimits(limits map[string]config.RateLimit) map[string]adminserver.RateLimit {
+	converted := make(map[string]adminserver.RateLimit, len(limits))
+	for route, limit := range limits {
+		burst := limit.Burst
+		if burst == 0 {
+			burst = 1
+		}
+		converted[route] = adminserver.RateLimit{Rate: limit.Rate, Burst: burst}
+	}
+	return converted
+}
+
+func validateLogLevels(cfg config.Logging) error {
+	if cfg.Level != "" {
+		if _, err := logging.LevelFromString(cfg.Level); err != nil {
+			return fmt.Errorf("invalid log level %q", cfg.Level)
+		}
+	}
+	subsystems := make(map[string]bool)
+	for _, subsystem := range logging.GetSubsystems() {
+		subsystems[subsystem] = true
+	}
+	for subsystem, level := range cfg.Levels {
+		if !subsystems[subsystem] {
+			return fmt.Errorf("logging subsystem %q not found", subsystem)
+		}
+		if _, err := logging.LevelFromString(level); err != nil {
+			return fmt.Errorf("invalid log level %q of logging subsystem %q", level, subsystem)
+		}
+	}
+	return nil
+}
+
+// applyLogLevels sets the log level of all subsystems to the configured level,
+// or to defaultLevel if there is none, and then sets the configured levels of
+// specific subsystems.
+func applyLogLevels(defaultLevel string, cfg config.Logging) error {
+	if err := validateLogLevels(cfg); err != nil {
+		return err
+	}
+	level := cfg.Level
+	if level == "" {
+		level = defaultLevel
+	}
+	if err := logging.SetLogLevel("*", level); err != nil {
+		return err
+	}
+	for subsystem, level := range cfg.Levels {
+		if err := logging.SetLogLevel(subsystem, level); err != nil {
+			return fmt.Errorf("cannot set log level of logging subsystem %q: %w", subsystem, err)
+		}
+	}
+	return nil
+}
+
+// reannouncer periodically re-announces the latest advertisement.
+type reannouncer struct {
+	eng      *engine.Engine
+	interval chan time.Duration
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func startReannouncer(eng *engine.Engine, interval time.Duration) *reannouncer {
+	r := &reannouncer{
+		eng:      eng,
+		interval: make(chan time.Duration),
+		done:     make(chan struct{}),
+	}
+	go r.run(interval)
+	return r
+}
+
+func (r *reannouncer) run(interval time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var ticker *time.Ticker
+	var tick <-chan time.Time
+	reset := func(interval time.Duration) {
+		if ticker != nil {
+			ticker.Stop()
+			ticker, tick = nil, nil
+		}
+		if interval > 0 {
+			ticker = time.NewTicker(interval)
+			tick = ticker.C
+			log.Infow("Re-announcing latest advertisement periodically", "interval", interval)
+		}
+	}
+	reset(interval)
+	defer reset(0)
+
+	for {
+		select {
+		case <-tick:
+			if _, err := r.eng.PublishLatest(ctx); err != nil {
+				log.Errorw("Failed to re-announce latest advertisement", "err", err)
+			}
+		case interval = <-r.interval:
+			reset(interval)
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// setInterval sets the interval at which the latest advertisement is
+// re-announced. Periodic re-announcement is disabled if interval is zero.
+func (r *reannouncer) setInterval(interval time.Duration) {
+	select {
+	case r.interval <- interval:
+	case <-r.done:
+	}
+}
+
+func (r *reannouncer) stop() {
+	r.stopOnce.Do(func() { close(r.done) })
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/ipni/index-provider/cmd/provider/internal/config"
+	"github.com/ipni/index-provider/engine"
+	"github.com/ipni/index-provider/engine/policy"
+	adminserver "github.com/ipni/index-provider/server/admin/http"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func Test_reloader(t *testing.T) {
+	t.Setenv(config.EnvDir, t.TempDir())
+	cfg, err := config.Init(io.Discard)
+	require.NoError(t, err)
+	require.NoError(t, cfg.Save(""))
+	loaded, err := config.Load("")
+	require.NoError(t, err)
+
+	eng, err := engine.New()
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(context.Background()))
+	t.Cleanup(func() { eng.Shutdown() })
+	syncPolicy, err := policy.New(true, nil)
+	require.NoError(t, err)
+	reannouncer := startReannouncer(eng, 0)
+	t.Cleanup(reannouncer.stop)
+	subject := &reloader{
+		cfg:         loaded,
+		logLevel:    "info",
+		eng:         eng,
+		syncPolicy:  syncPolicy,
+		reannouncer: reannouncer,
+	}
+
+	res, err := subject.reload(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, res.Applied)
+	require.Empty(t, res.RestartRequired)
+
+	blocked, err := peer.Decode("12D3KooWPMGfQs5CaJKG4yCxVWizWBRtB85gEUwiX2ekStvYvqgp")
+	require.NoError(t, err)
+	cfg.DirectAnnounce.URLs = []string{"https://example.com/announce"}
+	cfg.DirectAnnounce.ReannounceInterval = config.Duration(time.Hour)
+	cfg.Ingest.SyncPolicy.Except = []string{blocked.String()}
+	cfg.Logging.Levels = map[string]string{"command/reference-provider": "debug"}
+	cfg.Ingest.PublisherKind = config.Libp2pPublisherKind
+	cfg.ProviderServer.RetrievalMultiaddrs = []string{"/dns4/fish.example/tcp/443/https"}
+	require.NoError(t, cfg.Save(""))
+
+	res, err = subject.reload(context.Background())
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{
+		"DirectAnnounce.URLs",
+		"DirectAnnounce.ReannounceInterval",
+		"Ingest.SyncPolicy.Except",
+		"Logging.Levels",
+		"ProviderServer.RetrievalMultiaddrs",
+	}, res.Applied)
+	require.Equal(t, []string{"Ingest.PublisherKind"}, res.RestartRequired)
+	require.False(t, syncPolicy.Allowed(blocked))
+	require.Equal(t, "debug", logging.Logger("command/reference-provider").Level().String())
+	require.Equal(t, []string{"/dns4/fish.example/tcp/443/https"}, subject.cfg.ProviderServer.RetrievalMultiaddrs)
+
+	// Fields that require a restart are reported until the daemon restarts.
+	res, err = subject.reload(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, res.Applied)
+	require.Equal(t, []string{"Ingest.PublisherKind"}, res.RestartRequired)
+
+	// Invalid configs are not applied.
+	cfg.Logging.Levels = map[string]string{"command/reference-provider": "loud"}
+	cfg.DirectAnnounce.URLs = nil
+	require.NoError(t, cfg.Save(""))
+	_, err = subject.reload(context.Background())
+	require.ErrorContains(t, err, "loud")
+	require.Equal(t, []string{"https://example.com/announce"}, subject.cfg.DirectAnnounce.URLs)
+
+	require.NoError(t, applyLogLevels("info", config.Logging{}))
+}
+
+func TestReloadCmd(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "/admin/reload", r.URL.Path)
+		res := &adminserver.ReloadRes{
+			Applied:         []string{"DirectAnnounce.URLs"},
+			RestartRequired: []string{"Ingest.PublisherKind"},
+		}
+		_, err := res.WriteTo(w)
+		require.NoError(t, err)
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	app := &cli.App{
+		Writer:   &out,
+		Commands: []*cli.Command{ReloadCmd},
+	}
+	require.NoError(t, app.Run([]string{"provider", "reload", "-l", server.URL}))
+	require.Equal(t, "Applied: DirectAnnounce.URLs\nRestart required: Ingest.PublisherKind\n", out.String())
+}
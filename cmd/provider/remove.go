@@ -6,19 +6,76 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
-	"net/http"
 
 	adminserver "github.com/ipni/index-provider/server/admin/http"
 	"github.com/urfave/cli/v2"
 )
 
 var RemoveCmd = &cli.Command{
-	Name:        "remove",
-	Aliases:     []string{"rm"},
-	Usage:       "Removes previously advertised multihashes by the provider.",
+	Name:    "remove",
+	Aliases: []string{"rm"},
+	Usage:   "Removes previously advertised multihashes by the provider.",
+	Description: `Publishes advertisements signalling that the provider no longer provides the
+multihashes advertised with the given context IDs, or with all context IDs if --all is set.
+
+Context IDs are specified as base64 encoded strings. See the car subcommand to remove
+multihashes previously advertised via a CAR file.`,
+	Flags: []cli.Flag{
+		adminAPIFlag,
+		&cli.StringSliceFlag{
+			Name:    "context-id",
+			Usage:   "Base64 encoded context ID to remove. Can be specified multiple times.",
+			Aliases: []string{"ctx"},
+		},
+		&cli.BoolFlag{
+			Name:  "all",
+			Usage: "Remove all the context IDs currently advertised by the provider.",
+		},
+	},
+	Action:      doRemoveContext,
 	Subcommands: []*cli.Command{removeCarSubCmd},
 }
 
+func doRemoveContext(cctx *cli.Context) error {
+	b64ContextIDs := cctx.StringSlice("context-id")
+	all := cctx.Bool("all")
+	if all == (len(b64ContextIDs) != 0) {
+		return errors.New("either context-id or all must be set")
+	}
+
+	req := adminserver.RemoveContextReq{All: all}
+	for _, b64ContextID := range b64ContextIDs {
+		contextID, err := base64.StdEncoding.DecodeString(b64ContextID)
+		if err != nil {
+			return fmt.Errorf("context ID %q is not a valid base64 encoded string", b64ContextID)
+		}
+		req.ContextIDs = append(req.ContextIDs, contextID)
+	}
+	client, err := newAdminClient()
+	if err != nil {
+		return err
+	}
+	res, err := client.RemoveContexts(cctx.Context, &req)
+	if err != nil {
+		return err
+	}
+	if len(res.Removed) == 0 {
+		_, err = fmt.Fprintln(cctx.App.Writer, "No context IDs to remove.")
+		return err
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "Successfully removed %d context IDs.\n", len(res.Removed))
+	for _, removed := range res.Removed {
+		b.WriteString("\t Context ID: ")
+		b.WriteString(base64.StdEncoding.EncodeToString(removed.ContextID))
+		b.WriteString("\n\t Advertisement ID: ")
+		b.WriteString(removed.AdvId.String())
+		b.WriteString("\n")
+	}
+	_, err = cctx.App.Writer.Write(b.Bytes())
+	return err
+}
+
 var (
 	removeCarKey    []byte
 	removeCarSubCmd = &cli.Command{
@@ -72,27 +129,18 @@ func beforeRemoveCar(cctx *cli.Context) error {
 }
 
 func doRemoveCar(cctx *cli.Context) error {
-	req := adminserver.RemoveCarReq{
-		Key: removeCarKey,
-	}
-	resp, err := doHttpPostReq(cctx.Context, adminAPIFlagValue+"/admin/remove/car", req)
+	client, err := newAdminClient()
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return errFromHttpResp(resp)
-	}
-
-	var res adminserver.RemoveCarRes
-	if _, err := res.ReadFrom(resp.Body); err != nil {
-		return fmt.Errorf("received ok response from server but cannot decode response body. %v", err)
+	adCid, err := client.RemoveCar(cctx.Context, removeCarKey)
+	if err != nil {
+		return err
 	}
 	var b bytes.Buffer
 	b.WriteString("Successfully removed CAR.\n")
 	b.WriteString("\t Advertisement ID: ")
-	b.WriteString(res.AdvId.String())
+	b.WriteString(adCid.String())
 	b.WriteString("\n\t Context ID: ")
 	b.WriteString(base64.StdEncoding.EncodeToString(removeCarKey))
 	b.WriteString("\n")
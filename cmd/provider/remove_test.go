@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ipni/go-libipni/test"
+	adminserver "github.com/ipni/index-provider/server/admin/http"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestRemoveCmd_RemovesContextIDs(t *testing.T) {
+	adCids := test.RandomCids(2)
+	var gotReq adminserver.RemoveContextReq
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/admin/remove/context", r.URL.Path)
+		_, err := gotReq.ReadFrom(r.Body)
+		require.NoError(t, err)
+		res := &adminserver.RemoveContextRes{}
+		for i, contextID := range gotReq.ContextIDs {
+			res.Removed = append(res.Removed, adminserver.RemovedContext{ContextID: contextID, AdvId: adCids[i]})
+		}
+		_, err = res.WriteTo(w)
+		require.NoError(t, err)
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	app := &cli.App{
+		Writer:   &out,
+		Commands: []*cli.Command{RemoveCmd},
+	}
+	fish := base64.StdEncoding.EncodeToString([]byte("fish"))
+	lobster := base64.StdEncoding.EncodeToString([]byte("lobster"))
+	err := app.Run([]string{"provider", "remove", "-l", server.URL, "--context-id", fish, "--context-id", lobster})
+	require.NoError(t, err)
+	require.False(t, gotReq.All)
+	require.Equal(t, [][]byte{[]byte("fish"), []byte("lobster")}, gotReq.ContextIDs)
+	require.Contains(t, out.String(), "Successfully removed 2 context IDs.")
+	require.Contains(t, out.String(), adCids[0].String())
+	require.Contains(t, out.String(), adCids[1].String())
+}
+
+func TestRemoveCmd_RequiresEitherContextIDOrAll(t *testing.T) {
+	app := &cli.App{
+		Writer:   &bytes.Buffer{},
+		Commands: []*cli.Command{RemoveCmd},
+	}
+	err := app.Run([]string{"provider", "remove", "-l", "http://localhost:0"})
+	require.ErrorContains(t, err, "either context-id or all must be set")
+	err = app.Run([]string{"provider", "remove", "-l", "http://localhost:0", "--all", "--context-id", "ZmlzaA=="})
+	require.ErrorContains(t, err, "either context-id or all must be set")
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ipni/index-provider/cmd/provider/internal/config"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/urfave/cli/v2"
+)
+
+var RotateKeyCmd = &cli.Command{
+	Name:  "rotate-key",
+	Usage: "Rotates the identity of the provider to a new key",
+	Description: `Publishes a hand-off advertisement, signed by both the current and the new key, that lists the
+new identity as an extended provider of all content advertised by the current identity. The
+advertisement chain then continues under the new identity, and the provider configuration is
+updated to use the new key.
+
+The new key is generated unless --key-file is specified. The provider datastore is written directly
+and therefore the provider daemon must not be running. Once rotated, start the daemon and
+announce the latest advertisement for indexers to learn about the hand-off.`,
+	Flags: []cli.Flag{
+		&cli.PathFlag{
+			Name:  "key-file",
+			Usage: "The path to the file containing the marshalled libp2p private key to rotate to.",
+		},
+	},
+	Action: doRotateKey,
+}
+
+func doRotateKey(cctx *cli.Context) error {
+	cfg, err := loadInitializedConfig()
+	if err != nil {
+		return err
+	}
+	oldID, oldKey, err := cfg.Identity.DecodeOrCreate(io.Discard)
+	if err != nil {
+		return err
+	}
+	oldIdentity := cfg.Identity
+	if oldIdentity.PrivKey == "" {
+		keyData, err := crypto.MarshalPrivateKey(oldKey)
+		if err != nil {
+			return err
+		}
+		oldIdentity.PrivKey = base64.StdEncoding.EncodeToString(keyData)
+	}
+
+	var newIdentity config.Identity
+	if keyFile := cctx.Path("key-file"); keyFile != "" {
+		newIdentity, err = identityFromKeyFile(keyFile)
+	} else {
+		newIdentity, err = config.CreateIdentity(cctx.App.Writer)
+	}
+	if err != nil {
+		return err
+	}
+	newID, newKey, err := newIdentity.DecodeOrCreate(io.Discard)
+	if err != nil {
+		return err
+	}
+
+	// The engine is opened with the current key before the new identity is
+	// saved, which happens before the hand-off is published, so that the new
+	// key is never lost once the chain is handed off to it.
+	eng, closer, err := openOfflineEngine(cctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer closer()
+	if err = saveIdentity(cfg, newIdentity); err != nil {
+		return fmt.Errorf("cannot save new identity: %w", err)
+	}
+	adCid, err := eng.RotateKey(cctx.Context, newKey)
+	if err != nil {
+		if restoreErr := saveIdentity(cfg, oldIdentity); restoreErr != nil {
+			fmt.Fprintln(cctx.App.ErrWriter, "Failed to restore the current identity; its base64 encoded private key is:", oldIdentity.PrivKey)
+			return fmt.Errorf("%w; cannot restore current identity: %w", err, restoreErr)
+		}
+		return err
+	}
+
+	fmt.Fprintln(cctx.App.Writer, "Published hand-off advertisement", adCid)
+	fmt.Fprintln(cctx.App.Writer, "Old peer ID:", oldID)
+	fmt.Fprintln(cctx.App.Writer, "New peer ID:", newID)
+	return nil
+}
+
+func identityFromKeyFile(path string) (config.Identity, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config.Identity{}, err
+	}
+	key, err := crypto.UnmarshalPrivateKey(data)
+	if err != nil {
+		return config.Identity{}, fmt.Errorf("cannot unmarshal private key: %w", err)
+	}
+	id, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		return config.Identity{}, err
+	}
+	return config.Identity{
+		PeerID:  id.String(),
+		PrivKey: base64.StdEncoding.EncodeToString(data),
+	}, nil
+}
+
+// saveIdentity saves the given identity to where the current identity of the
+// provider is stored; either in the config file, or in the file at the path
+// specified by the private key environment variable.
+func saveIdentity(cfg *config.Config, identity config.Identity) error {
+	if cfg.Identity.PrivKey == "" {
+		keyData, err := base64.StdEncoding.DecodeString(identity.PrivKey)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(os.Getenv(config.PrivateKeyPathEnvVar), keyData, 0o600)
+	}
+	// Reload the config so that overrides from environment variables are not
+	// saved to the config file.
+	fileCfg, err := config.LoadFile("")
+	if err != nil {
+		return err
+	}
+	fileCfg.Identity = identity
+	return fileCfg.Save("")
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipni/index-provider/cmd/provider/internal/config"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestRotateKeyCmd(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv(config.EnvDir, root)
+	cfg, err := config.Init(io.Discard)
+	require.NoError(t, err)
+	require.NoError(t, cfg.Save(""))
+	oldIdentity := cfg.Identity
+
+	run := func(args ...string) (string, error) {
+		var out, errOut bytes.Buffer
+		app := &cli.App{
+			Writer:    &out,
+			ErrWriter: &errOut,
+			Commands:  []*cli.Command{RotateKeyCmd},
+		}
+		err := app.Run(append([]string{"provider", "rotate-key"}, args...))
+		return out.String(), err
+	}
+	loadIdentity := func() config.Identity {
+		cfg, err := config.Load("")
+		require.NoError(t, err)
+		return cfg.Identity
+	}
+
+	// The current identity is kept if the hand-off fails to be published.
+	oldKey, err := oldIdentity.DecodeOrCreatePrivateKey(io.Discard, "")
+	require.NoError(t, err)
+	keyData, err := crypto.MarshalPrivateKey(oldKey)
+	require.NoError(t, err)
+	keyFile := filepath.Join(root, "key")
+	require.NoError(t, os.WriteFile(keyFile, keyData, 0o600))
+	_, err = run("--key-file", keyFile)
+	require.ErrorContains(t, err, "new key must differ")
+	require.Equal(t, oldIdentity, loadIdentity())
+
+	newKey, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	keyData, err = crypto.MarshalPrivateKey(newKey)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(keyFile, keyData, 0o600))
+	out, err := run("--key-file", keyFile)
+	require.NoError(t, err)
+	newIdentity := loadIdentity()
+	require.Contains(t, out, "Published hand-off advertisement")
+	require.Contains(t, out, "Old peer ID: "+oldIdentity.PeerID)
+	require.Contains(t, out, "New peer ID: "+newIdentity.PeerID)
+	require.NotEqual(t, oldIdentity.PeerID, newIdentity.PeerID)
+	gotKey, err := newIdentity.DecodeOrCreatePrivateKey(io.Discard, "")
+	require.NoError(t, err)
+	require.True(t, newKey.Equals(gotKey))
+}
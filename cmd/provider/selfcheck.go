@@ -0,0 +1,22 @@
+package main
+
+import (
+	"time"
+
+	"github.com/ipni/index-provider/cmd/provider/internal/config"
+	"github.com/ipni/index-provider/engine"
+	"github.com/ipni/index-provider/selfcheck"
+)
+
+// newSelfChecker instantiates the checker of the retrieval of the content
+// advertised by the engine, as configured by cfg.
+func newSelfChecker(eng *engine.Engine, cfg config.RetrievalSelfCheck) (*selfcheck.Checker, error) {
+	var opts []selfcheck.Option
+	if cfg.Samples != 0 {
+		opts = append(opts, selfcheck.WithSamples(cfg.Samples))
+	}
+	if cfg.Timeout != 0 {
+		opts = append(opts, selfcheck.WithTimeout(time.Duration(cfg.Timeout)))
+	}
+	return selfcheck.New(eng, opts...)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+const outputTable = "table"
+
+var StatsCmd = &cli.Command{
+	Name:  "stats",
+	Usage: "Shows statistics of a running provider",
+	Description: `Fetches statistics from the admin server of a running provider, including the length of the
+advertisement chain, the number of advertised context IDs, the usage of the entries cache, the
+number of announcements sent and failed, overall and to each indexer URL and gossip pubsub topic,
+and when an advertisement was last published. Counts of announcements and the last publish time are
+since the provider started.`,
+	Action: doStats,
+	Flags: []cli.Flag{
+		adminAPIFlag,
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Usage:   "The output format, one of: table, json.",
+			Value:   outputTable,
+		},
+	},
+}
+
+func doStats(cctx *cli.Context) error {
+	output := cctx.String("output")
+	if output != outputTable && output != outputJson {
+		return fmt.Errorf("unknown output format %q; must be one of %s or %s", output, outputTable, outputJson)
+	}
+
+	client, err := newAdminClient()
+	if err != nil {
+		return err
+	}
+	res, err := client.GetStats(cctx.Context)
+	if err != nil {
+		return err
+	}
+
+	if output == outputJson {
+		enc := json.NewEncoder(cctx.App.Writer)
+		enc.SetIndent("", "  ")
+		return enc.Encode(&res)
+	}
+
+	head := "none"
+	if res.Head != nil {
+		head = res.Head.String()
+	}
+	lastPublished := "never"
+	if res.LastPublished != nil {
+		lastPublished = res.LastPublished.Format(time.RFC3339)
+	}
+	tw := tabwriter.NewWriter(cctx.App.Writer, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Head:\t%s\n", head)
+	fmt.Fprintf(tw, "Chain length:\t%d\n", res.ChainLength)
+	fmt.Fprintf(tw, "Contexts:\t%d\n", res.Contexts)
+	fmt.Fprintf(tw, "Multihashes:\t%d\n", res.Multihashes)
+	fmt.Fprintf(tw, "Cached chunks:\t%d/%d\n", res.CachedChunks, res.CacheCapacity)
+	fmt.Fprintf(tw, "Announce successes:\t%d\n", res.AnnounceSuccesses)
+	fmt.Fprintf(tw, "Announce failures:\t%d\n", res.AnnounceFailures)
+	fmt.Fprintf(tw, "Last published:\t%s\n", lastPublished)
+	if err = tw.Flush(); err != nil {
+		return err
+	}
+	if len(res.AnnounceTargets) == 0 {
+		return nil
+	}
+
+	targets := make([]string, 0, len(res.AnnounceTargets))
+	for target := range res.AnnounceTargets {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	fmt.Fprintln(cctx.App.Writer)
+	tw = tabwriter.NewWriter(cctx.App.Writer, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "ANNOUNCE TARGET\tATTEMPTS\tSUCCESSES\tFAILURES\tMEAN LATENCY\tLAST ERROR\n")
+	for _, target := range targets {
+		ts := res.AnnounceTargets[target]
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%s\t%s\n", target, ts.Attempts, ts.Successes, ts.Failures,
+			time.Duration(ts.MeanLatencyMs)*time.Millisecond, ts.LastError)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ipni/go-libipni/test"
+	adminserver "github.com/ipni/index-provider/server/admin/http"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestStatsCmd(t *testing.T) {
+	head := test.RandomCids(1)[0]
+	lastPublished := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/admin/stats", r.URL.Path)
+		res := &adminserver.StatsRes{
+			Head:              &head,
+			ChainLength:       42,
+			Contexts:          7,
+			Multihashes:       1234,
+			CachedChunks:      3,
+			CacheCapacity:     1024,
+			AnnounceSuccesses: 40,
+			AnnounceFailures:  2,
+			LastPublished:     &lastPublished,
+			AnnounceTargets: map[string]adminserver.AnnounceTargetStats{
+				"https://cid.contact/announce": {Attempts: 40, Successes: 40, MeanLatencyMs: 120},
+				"https://broken.example/announce": {
+					Attempts:      2,
+					Failures:      2,
+					MeanLatencyMs: 1500,
+					LastError:     "502 Bad Gateway",
+				},
+			},
+		}
+		_, err := res.WriteTo(w)
+		require.NoError(t, err)
+	}))
+	defer server.Close()
+
+	run := func(args ...string) string {
+		var out bytes.Buffer
+		app := &cli.App{
+			Writer:   &out,
+			Commands: []*cli.Command{StatsCmd},
+		}
+		require.NoError(t, app.Run(append([]string{"provider", "stats", "-l", server.URL}, args...)))
+		return out.String()
+	}
+
+	out := run()
+	require.Contains(t, out, head.String())
+	require.Regexp(t, `Chain length:\s+42\n`, out)
+	require.Regexp(t, `Multihashes:\s+1234\n`, out)
+	require.Regexp(t, `Cached chunks:\s+3/1024\n`, out)
+	require.Regexp(t, `Announce failures:\s+2\n`, out)
+	require.Regexp(t, `Last published:\s+2023-05-01T12:00:00Z\n`, out)
+	require.Regexp(t, `https://broken.example/announce\s+2\s+0\s+2\s+1.5s\s+502 Bad Gateway\n`, out)
+	require.Regexp(t, `https://cid.contact/announce\s+40\s+40\s+0\s+120ms\s+\n`, out)
+
+	var res adminserver.StatsRes
+	require.NoError(t, json.Unmarshal([]byte(run("-o", "json")), &res))
+	require.Equal(t, head, *res.Head)
+	require.Equal(t, 7, res.Contexts)
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/go-libipni/apierror"
+	findclient "github.com/ipni/go-libipni/find/client"
+	"github.com/ipni/go-libipni/find/model"
+	"github.com/ipni/index-provider/cmd/provider/internal"
+	"github.com/ipni/index-provider/cmd/provider/internal/config"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+	"github.com/urfave/cli/v2"
+)
+
+// statusMaxBehind is the maximum number of advertisements walked back from the
+// local head when looking for the latest advertisement ingested by an indexer.
+const statusMaxBehind = 1000
+
+var StatusCmd = &cli.Command{
+	Name:  "status",
+	Usage: "Shows the status of the provider as seen by indexers",
+	Description: `Queries the given indexers for the information they hold about this provider, including the
+latest advertisement ingested, the ingestion lag and any ingestion error, and compares it with the
+latest advertisement published by the provider.
+
+The provider ID and publisher address are read from the provider configuration unless specified
+via --provider-id and --provider-addr-info.`,
+	Action: doStatus,
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:     "indexer",
+			Usage:    "The URL of an indexer to query. Can be specified multiple times.",
+			Aliases:  []string{"i"},
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:        "provider-id",
+			Usage:       "The ID of the provider. Defaults to the ID in the provider configuration.",
+			Destination: &statusProviderID,
+		},
+		optionalProviderAddrInfoFlag,
+	},
+}
+
+var statusProviderID string
+
+type indexerStatus struct {
+	indexer string
+	info    *model.ProviderInfo
+	err     error
+}
+
+func doStatus(cctx *cli.Context) error {
+	providerID, err := statusLocalProviderID()
+	if err != nil {
+		return err
+	}
+
+	pc, localHead, localErr := statusLocalHead(cctx, providerID)
+	if pc != nil {
+		defer pc.Close()
+	}
+
+	var statuses []indexerStatus
+	for _, indexer := range cctx.StringSlice("indexer") {
+		s := indexerStatus{indexer: indexer}
+		client, err := findclient.New(indexer)
+		if err != nil {
+			s.err = err
+		} else {
+			s.info, s.err = client.GetProvider(cctx.Context, providerID)
+		}
+		statuses = append(statuses, s)
+	}
+
+	w := cctx.App.Writer
+	fmt.Fprintln(w, "Provider:  ", providerID)
+	if localErr != nil {
+		fmt.Fprintln(w, "Local head:", "unknown:", localErr)
+	} else {
+		fmt.Fprintln(w, "Local head:", localHead)
+	}
+	var failed int
+	for _, s := range statuses {
+		fmt.Fprintln(w)
+		if !printIndexerStatus(cctx, w, s, pc, localHead) {
+			failed++
+		}
+	}
+	if failed != 0 {
+		return fmt.Errorf("failed to get provider status from %d of %d indexers", failed, len(statuses))
+	}
+	return nil
+}
+
+// printIndexerStatus prints the status of the provider at an indexer, and
+// returns false if the indexer could not be queried.
+func printIndexerStatus(cctx *cli.Context, w io.Writer, s indexerStatus, pc internal.ProviderClient, localHead cid.Cid) bool {
+	fmt.Fprintln(w, "Indexer:", s.indexer)
+	if s.err != nil {
+		var apiErr *apierror.Error
+		if errors.As(s.err, &apiErr) && apiErr.Status() == http.StatusNotFound {
+			fmt.Fprintln(w, "  Provider is not known to the indexer")
+			return true
+		}
+		fmt.Fprintln(w, "  Error:", s.err)
+		return false
+	}
+
+	info := s.info
+	if info.LastAdvertisement == cid.Undef {
+		fmt.Fprintln(w, "  Last advertisement:   none")
+	} else {
+		fmt.Fprintf(w, "  Last advertisement:   %s (received %s)\n", info.LastAdvertisement, info.LastAdvertisementTime)
+	}
+	fmt.Fprintln(w, "  Ingestion lag:       ", info.Lag)
+	if info.Publisher != nil {
+		fmt.Fprintln(w, "  Publisher:           ", info.Publisher)
+	}
+	fmt.Fprintln(w, "  Inactive:            ", info.Inactive)
+	if info.FrozenAt != cid.Undef {
+		fmt.Fprintf(w, "  Frozen at:            %s (received %s)\n", info.FrozenAt, info.FrozenAtTime)
+	}
+	if info.LastError != "" {
+		fmt.Fprintf(w, "  Last error:           %s (at %s)\n", info.LastError, info.LastErrorTime)
+	}
+	if localHead != cid.Undef {
+		fmt.Fprintln(w, "  In sync with local:  ", describeSync(cctx, pc, localHead, info.LastAdvertisement))
+	}
+	return true
+}
+
+// describeSync describes how far behind the local head the given
+// advertisement ingested by an indexer is.
+func describeSync(cctx *cli.Context, pc internal.ProviderClient, localHead, ingested cid.Cid) string {
+	if localHead == ingested {
+		return "yes"
+	}
+	if ingested == cid.Undef {
+		return "no, nothing ingested yet"
+	}
+
+	var behind int
+	err := walkAds(cctx.Context, pc, localHead, ingested, statusMaxBehind, func(*internal.Advertisement) error {
+		behind++
+		return nil
+	})
+	switch {
+	case err != nil:
+		return "no"
+	case behind >= statusMaxBehind:
+		return fmt.Sprintf("no, more than %d advertisements behind", statusMaxBehind)
+	default:
+		return fmt.Sprintf("no, %d advertisements behind", behind)
+	}
+}
+
+func statusLocalProviderID() (peer.ID, error) {
+	if statusProviderID != "" {
+		return peer.Decode(statusProviderID)
+	}
+	cfg, err := config.Load("")
+	if err != nil {
+		return "", fmt.Errorf("cannot load config file; specify the provider ID instead: %w", err)
+	}
+	peerID, _, err := cfg.Identity.DecodeOrCreate(io.Discard)
+	return peerID, err
+}
+
+// statusLocalHead gets the latest advertisement from the provider publisher,
+// returning the client used to do so. The publisher address is derived from
+// the provider configuration unless it is specified explicitly.
+func statusLocalHead(cctx *cli.Context, providerID peer.ID) (internal.ProviderClient, cid.Cid, error) {
+	if providerAddrInfoFlagValue == "" {
+		cfg, err := config.Load("")
+		if err != nil {
+			return nil, cid.Undef, fmt.Errorf("cannot load config file: %w", err)
+		}
+		addr, err := localPublisherAddr(cfg)
+		if err != nil {
+			return nil, cid.Undef, err
+		}
+		providerAddrInfoFlagValue = addr.Encapsulate(multiaddr.StringCast("/p2p/" + providerID.String())).String()
+	}
+	pc, err := newProviderClient()
+	if err != nil {
+		return nil, cid.Undef, err
+	}
+	ad, err := pc.GetAdvertisement(cctx.Context, cid.Undef)
+	if err != nil {
+		return pc, cid.Undef, err
+	}
+	return pc, ad.ID, nil
+}
+
+// localPublisherAddr returns the address at which the publisher of a provider
+// running on the local host with the given config is reachable.
+func localPublisherAddr(cfg *config.Config) (multiaddr.Multiaddr, error) {
+	listenAddr := cfg.ProviderServer.ListenMultiaddr
+	if cfg.AdminServer.HostPublisher {
+		pubAddr, err := cfg.AdminServer.PublisherMultiaddr()
+		if err != nil {
+			return nil, fmt.Errorf("invalid publisher listen address: %w", err)
+		}
+		listenAddr = pubAddr.String()
+	} else if cfg.Ingest.PublisherKind != config.Libp2pPublisherKind && cfg.Ingest.HttpPublisher.ListenMultiaddr != "" {
+		listenAddr = cfg.Ingest.HttpPublisher.ListenMultiaddr
+	}
+	maddr, err := multiaddr.NewMultiaddr(listenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid publisher listen address: %w", err)
+	}
+	// Replace unspecified listen IPs with loopback.
+	first, rest := multiaddr.SplitFirst(maddr)
+	if first != nil {
+		if ip, err := manet.ToIP(first); err == nil && ip.IsUnspecified() {
+			loopback := "/ip4/127.0.0.1"
+			if strings.HasPrefix(first.String(), "/ip6") {
+				loopback = "/ip6/::1"
+			}
+			maddr = multiaddr.StringCast(loopback)
+			if rest != nil {
+				maddr = maddr.Encapsulate(rest)
+			}
+		}
+	}
+	return maddr, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/index-provider/cmd/provider/internal/config"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func Test_localPublisherAddr(t *testing.T) {
+	cfg := config.Config{
+		Ingest:         config.NewIngest(),
+		ProviderServer: config.NewProviderServer(),
+	}
+	addr, err := localPublisherAddr(&cfg)
+	require.NoError(t, err)
+	require.Equal(t, "/ip4/127.0.0.1/tcp/3104/http", addr.String())
+
+	cfg.Ingest.PublisherKind = config.Libp2pPublisherKind
+	addr, err = localPublisherAddr(&cfg)
+	require.NoError(t, err)
+	require.Equal(t, "/ip4/127.0.0.1/tcp/3103", addr.String())
+
+	cfg.ProviderServer.ListenMultiaddr = "/ip6/::/tcp/3103"
+	addr, err = localPublisherAddr(&cfg)
+	require.NoError(t, err)
+	require.Equal(t, "/ip6/::1/tcp/3103", addr.String())
+
+	cfg.Ingest.PublisherKind = config.HttpPublisherKind
+	cfg.AdminServer = config.NewAdminServer()
+	cfg.AdminServer.HostPublisher = true
+	cfg.AdminServer.TLSCertPath = "cert.pem"
+	addr, err = localPublisherAddr(&cfg)
+	require.NoError(t, err)
+	require.Equal(t, "/ip4/127.0.0.1/tcp/3102/https", addr.String())
+}
+
+func Test_describeSync(t *testing.T) {
+	chain := testAds(t, 4)
+	pc := newFakeProviderClient(chain)
+	cctx := cli.NewContext(&cli.App{}, nil, nil)
+
+	require.Equal(t, "yes", describeSync(cctx, pc, chain[0].ID, chain[0].ID))
+	require.Equal(t, "no, nothing ingested yet", describeSync(cctx, pc, chain[0].ID, cid.Undef))
+	require.Equal(t, "no, 2 advertisements behind", describeSync(cctx, pc, chain[0].ID, chain[2].ID))
+}
+
+func Test_diffChain(t *testing.T) {
+	chain := testAds(t, 5)
+	chain[3].ContextID = []byte("lobster")
+	pc := newFakeProviderClient(chain)
+	cctx := cli.NewContext(&cli.App{}, nil, nil)
+
+	diff, err := diffChain(cctx, pc, chain[0].ID, chain[0].ID, 0, true)
+	require.NoError(t, err)
+	require.Equal(t, chainDiff{}, *diff)
+
+	diff, err = diffChain(cctx, pc, chain[0].ID, chain[3].ID, 0, true)
+	require.NoError(t, err)
+	require.Equal(t, chainDiff{ads: 3, removals: 1, contexts: 1}, *diff)
+
+	diff, err = diffChain(cctx, pc, chain[0].ID, cid.Undef, 0, false)
+	require.NoError(t, err)
+	require.Equal(t, chainDiff{ads: 5, removals: 2, contexts: 2}, *diff)
+
+	_, err = diffChain(cctx, pc, chain[0].ID, chain[4].ID, 2, false)
+	require.ErrorContains(t, err, "not found within 2 advertisements")
+
+	_, err = diffChain(cctx, pc, chain[0].ID, testAds(t, 1)[0].ID, 0, false)
+	require.ErrorContains(t, err, "not in the local chain")
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	leveldb "github.com/ipfs/go-ds-leveldb"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	syncDirFlag = &cli.PathFlag{
+		Name: "sync-dir",
+		Usage: "The directory in which to persist the blocks synced from the provider. Re-running the command " +
+			"with the same directory resumes from where a previous run left off. Defaults to a temporary " +
+			"directory that is removed once the command succeeds.",
+	}
+	progressIntervalFlag = &cli.DurationFlag{
+		Name:  "progress-interval",
+		Usage: "The interval at which to report sync progress. Zero disables progress reporting.",
+		Value: 10 * time.Second,
+	}
+)
+
+// syncState persists the blocks synced from a provider in a datastore on disk,
+// so that a failed sync resumes from where it left off when retried, and
+// periodically reports the progress of the sync.
+type syncState struct {
+	datastore.Batching
+	dir     string
+	keepDir bool
+	w       io.Writer
+
+	start  time.Time
+	ads    atomic.Int64
+	blocks atomic.Int64
+	bytes  atomic.Int64
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// openSyncState opens the sync state in the directory given by the sync-dir
+// flag, or in a temporary directory derived from the provider address. Progress
+// is reported to the error writer of the app.
+func openSyncState(cctx *cli.Context) (*syncState, error) {
+	dir := cctx.Path(syncDirFlag.Name)
+	keepDir := dir != ""
+	if !keepDir {
+		h := sha256.Sum256([]byte(providerAddrInfoFlagValue))
+		dir = filepath.Join(os.TempDir(), "provider-sync-"+hex.EncodeToString(h[:8]))
+	}
+	if _, err := os.Stat(dir); err == nil {
+		fmt.Fprintln(cctx.App.ErrWriter, "Resuming sync using state in", dir)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	ds, err := leveldb.NewDatastore(dir, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open sync state: %w", err)
+	}
+
+	s := &syncState{
+		Batching: ds,
+		dir:      dir,
+		keepDir:  keepDir,
+		w:        cctx.App.ErrWriter,
+		start:    time.Now(),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	interval := cctx.Duration(progressIntervalFlag.Name)
+	if interval <= 0 {
+		close(s.done)
+		return s, nil
+	}
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.report()
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+	return s, nil
+}
+
+// Put stores a block synced from the provider, counting it towards progress.
+func (s *syncState) Put(ctx context.Context, key datastore.Key, value []byte) error {
+	if err := s.Batching.Put(ctx, key, value); err != nil {
+		return err
+	}
+	s.blocks.Add(1)
+	s.bytes.Add(int64(len(value)))
+	return nil
+}
+
+// visitedAd counts an advertisement towards progress.
+func (s *syncState) visitedAd() {
+	s.ads.Add(1)
+}
+
+func (s *syncState) report() {
+	fmt.Fprintf(s.w, "Synced %d advertisements, fetched %d blocks (%d bytes) in %s\n",
+		s.ads.Load(), s.blocks.Load(), s.bytes.Load(), time.Since(s.start).Round(time.Second))
+}
+
+// close stops reporting progress and closes the datastore. The sync state is
+// removed if the sync succeeded and its directory was not specified explicitly,
+// and kept otherwise for the sync to be resumed.
+func (s *syncState) close(succeeded bool) error {
+	select {
+	case <-s.stop:
+		return nil
+	default:
+		close(s.stop)
+	}
+	<-s.done
+	err := s.Batching.Close()
+	if !succeeded {
+		fmt.Fprintf(s.w, "Sync state kept in %s; re-run the command to resume.\n", s.dir)
+		return err
+	}
+	if !s.keepDir {
+		if rmErr := os.RemoveAll(s.dir); rmErr != nil && err == nil {
+			err = rmErr
+		}
+	}
+	return err
+}
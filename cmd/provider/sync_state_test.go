@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func newSyncStateContext(t *testing.T, errOut io.Writer, args ...string) *cli.Context {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	require.NoError(t, syncDirFlag.Apply(fs))
+	require.NoError(t, progressIntervalFlag.Apply(fs))
+	require.NoError(t, fs.Parse(args))
+	return cli.NewContext(&cli.App{ErrWriter: errOut}, fs, nil)
+}
+
+func TestSyncState_ResumesFromDir(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "sync")
+	errOut := &syncBuffer{}
+	cctx := newSyncStateContext(t, errOut, "--sync-dir", dir, "--progress-interval", "10ms")
+
+	state, err := openSyncState(cctx)
+	require.NoError(t, err)
+	require.NoError(t, state.Put(ctx, datastore.NewKey("fish"), []byte("lobster")))
+	state.visitedAd()
+	require.Eventually(t, func() bool {
+		return strings.Contains(errOut.String(), "Synced 1 advertisements, fetched 1 blocks (7 bytes)")
+	}, time.Second, 10*time.Millisecond)
+	require.NoError(t, state.close(false))
+	require.Contains(t, errOut.String(), "re-run the command to resume")
+
+	errOut = &syncBuffer{}
+	cctx.App.ErrWriter = errOut
+	state, err = openSyncState(cctx)
+	require.NoError(t, err)
+	require.Contains(t, errOut.String(), "Resuming sync")
+	value, err := state.Get(ctx, datastore.NewKey("fish"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("lobster"), value)
+	require.NoError(t, state.close(true))
+
+	// Explicitly specified directories are kept.
+	_, err = os.Stat(dir)
+	require.NoError(t, err)
+}
+
+func TestSyncState_RemovesTempDirOnSuccess(t *testing.T) {
+	providerAddrInfoFlagValue = "http://localhost:" + t.Name()
+	var errOut bytes.Buffer
+	state, err := openSyncState(newSyncStateContext(t, &errOut, "--progress-interval", "0"))
+	require.NoError(t, err)
+	dir := state.dir
+	require.NoError(t, state.close(true))
+	_, err = os.Stat(dir)
+	require.ErrorIs(t, err, os.ErrNotExist)
+	require.Empty(t, errOut.String())
+}
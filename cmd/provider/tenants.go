@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipni/index-provider/cmd/provider/internal/config"
+	"github.com/ipni/index-provider/engine"
+	"github.com/ipni/index-provider/supplier"
+)
+
+// newTenants instantiates the tenants configured by cfg, whose engines publish
+// advertisements with the same ingest and announce settings as the engine of
+// the daemon. Multihashes advertised on behalf of each tenant via the admin
+// API are stored in its datastore namespace and listed from there.
+func newTenants(cfg *config.Config, ds datastore.Batching) (*engine.Tenants, error) {
+	pubURL, err := cfg.Tenants.URL()
+	if err != nil {
+		return nil, err
+	}
+	setup := func(_ context.Context, tenant *engine.Tenant) error {
+		ms := supplier.NewMultihashSupplier(tenant.Engine, tenant.Datastore)
+		tenant.Engine.RegisterMultihashLister(ms.ListMultihashes)
+		return nil
+	}
+	opts := []engine.Option{
+		engine.WithDirectAnnounce(cfg.DirectAnnounce.URLs...),
+		engine.WithAnnounceAuthTokens(cfg.DirectAnnounce.AuthTokens),
+		engine.WithEntriesCacheCapacity(cfg.Ingest.LinkCacheSize),
+		engine.WithEntriesMemoryCacheSize(cfg.Ingest.LinkMemoryCacheSize),
+		engine.WithChainedEntries(cfg.Ingest.LinkedChunkSize),
+		engine.WithSortedEntries(cfg.Ingest.SortEntries),
+		engine.WithLazyEntries(cfg.Ingest.LazyEntries),
+		engine.WithListerPrefetch(cfg.Ingest.ListerPrefetch),
+		engine.WithMaxAdMultihashes(cfg.Ingest.MaxAdMultihashes),
+		engine.WithAdSequence(cfg.Ingest.AdSequence),
+		engine.WithTopicName(cfg.Ingest.PubSubTopic),
+		engine.WithMaxRetrievalAddrs(cfg.ProviderServer.MaxRetrievalAddrs),
+		engine.WithPrivateRetrievalAddrs(cfg.ProviderServer.AllowPrivateRetrievalAddrs),
+	}
+	if cfg.Ingest.Network != "" {
+		opts = append(opts, engine.WithNetwork(cfg.Ingest.Network))
+	}
+	return engine.NewTenants(ds, pubURL, setup, opts...)
+}
+
+// serveTenants starts serving the advertisements of the given tenants over
+// HTTP at the listen address configured by cfg. Errors serving are sent to
+// errChan.
+func serveTenants(cfg config.Tenants, tenants *engine.Tenants, errChan chan<- error) (*http.Server, error) {
+	addr, err := cfg.ListenNetAddr()
+	if err != nil {
+		return nil, err
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	server := &http.Server{Handler: tenants}
+	go func() {
+		if err := server.Serve(l); err != http.ErrServerClosed {
+			errChan <- err
+		}
+	}()
+	return server, nil
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/index-provider/cmd/provider/internal"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/urfave/cli/v2"
+)
+
+var VerifyCmd = &cli.Command{
+	Name:  "verify",
+	Usage: "Verifies the advertisement chain of a provider",
+	Description: `Fetches the advertisement chain of a provider starting from its latest advertisement, or from
+the advertisement specified by --ad-cid, and verifies the signature and well-formedness of each
+advertisement, along with the integrity of the links between them, and that the sequence numbers
+and timestamps embedded in advertisements, if any, are consecutive and increasing. The entries of a
+sample of advertisements are optionally fetched and checked as well. A summary report is printed once done.
+
+Synced blocks are persisted on disk until the chain is verified without problems, so that
+re-running the command after a failure does not fetch them again. Progress is reported
+periodically to standard error.`,
+	Action: doVerify,
+	Flags: []cli.Flag{
+		providerAddrInfoFlag,
+		adCidFlag,
+		&cli.UintFlag{
+			Name:        "depth",
+			Usage:       "The maximum number of advertisements to verify. Zero verifies the entire chain.",
+			Destination: &verifyDepth,
+		},
+		&cli.Float64Flag{
+			Name:        "sample-entries",
+			Usage:       "The fraction of advertisements, between 0 and 1, whose entries are fetched and checked.",
+			Destination: &verifySampleEntries,
+		},
+		&cli.UintFlag{
+			Name:        "sample-chunks",
+			Usage:       "The maximum number of entry chunks to check per sampled advertisement. Zero checks all chunks.",
+			Value:       1,
+			Destination: &verifySampleChunks,
+		},
+		&cli.DurationFlag{
+			Name:        "timeout",
+			Usage:       "The timeout for each request made to the provider.",
+			Value:       10 * time.Second,
+			Destination: &verifyTimeout,
+		},
+		syncDirFlag,
+		progressIntervalFlag,
+	},
+}
+
+var (
+	verifyDepth         uint
+	verifySampleEntries float64
+	verifySampleChunks  uint
+	verifyTimeout       time.Duration
+)
+
+type (
+	verifyProblem struct {
+		adCid cid.Cid
+		err   error
+	}
+
+	verifyReport struct {
+		publisherID peer.ID
+		head        cid.Cid
+		ads         int
+		removals    int
+		sampledAds  int
+		chunks      int
+		multihashes int
+		complete    bool
+		problems    []verifyProblem
+		state       *syncState
+		// seqs is the number of advertisements with a sequence, first the
+		// sequence of the latest of them, and last that of the earliest.
+		seqs  int
+		first engine.AdSequence
+		last  engine.AdSequence
+		// sinceSeq is the number of advertisements verified since the last
+		// one with a sequence.
+		sinceSeq uint64
+	}
+)
+
+func doVerify(cctx *cli.Context) error {
+	if verifySampleEntries < 0 || verifySampleEntries > 1 {
+		return errors.New("sample-entries must be between 0 and 1")
+	}
+	var start cid.Cid
+	if adCidFlagValue != "" {
+		var err error
+		if start, err = cid.Decode(adCidFlagValue); err != nil {
+			return fmt.Errorf("invalid ad-cid: %w", err)
+		}
+	}
+
+	publisher, err := parseProviderAddrInfo(providerAddrInfoFlagValue)
+	if err != nil {
+		return err
+	}
+	state, err := openSyncState(cctx)
+	if err != nil {
+		return err
+	}
+	pc, err := newProviderClient(internal.WithHttpTimeout(verifyTimeout), internal.WithDatastore(state))
+	if err != nil {
+		state.close(false)
+		return err
+	}
+
+	report := &verifyReport{publisherID: publisher.ID, state: state}
+	err = report.verifyChain(cctx, pc, start)
+	pc.Close()
+	if closeErr := state.close(err == nil && len(report.problems) == 0); closeErr != nil {
+		log.Errorw("Failed to close sync state", "err", closeErr)
+	}
+	if err != nil {
+		return err
+	}
+	report.print(cctx.App.Writer)
+	if len(report.problems) != 0 {
+		err = fmt.Errorf("found %d problems in advertisement chain", len(report.problems))
+		for _, p := range report.problems {
+			if errors.Is(p.err, errInvalidSignature) {
+				return withErrorClass(errClassInvalidSignature, err)
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *verifyReport) verifyChain(cctx *cli.Context, pc internal.ProviderClient, start cid.Cid) error {
+	ad, err := pc.GetAdvertisement(cctx.Context, start)
+	if err != nil {
+		return err
+	}
+
+	r.head = ad.ID
+	seen := make(map[cid.Cid]struct{})
+	for {
+		seen[ad.ID] = struct{}{}
+		r.ads++
+		if r.state != nil {
+			r.state.visitedAd()
+		}
+		r.verifyAd(ad)
+
+		if ad.PreviousID == cid.Undef {
+			r.complete = true
+			break
+		}
+		if verifyDepth != 0 && uint(r.ads) >= verifyDepth {
+			break
+		}
+		if _, ok := seen[ad.PreviousID]; ok {
+			r.addProblem(ad.ID, fmt.Errorf("previous advertisement %s forms a cycle", ad.PreviousID))
+			break
+		}
+		prevID := ad.PreviousID
+		if ad, err = pc.GetAdvertisement(cctx.Context, prevID); err != nil {
+			if cctx.Context.Err() != nil {
+				return cctx.Context.Err()
+			}
+			r.addProblem(prevID, fmt.Errorf("chain is broken: %w", err))
+			break
+		}
+	}
+	return nil
+}
+
+func (r *verifyReport) verifyAd(ad *internal.Advertisement) {
+	if ad.IsRemove {
+		r.removals++
+	}
+	if err := ad.Validate(); err != nil {
+		r.addProblem(ad.ID, fmt.Errorf("invalid advertisement: %w", err))
+	}
+	r.verifySequence(ad)
+	signerID, err := ad.VerifySignature()
+	if err != nil {
+		r.addProblem(ad.ID, fmt.Errorf("%w: %w", errInvalidSignature, err))
+	} else if signerID != ad.ProviderID && r.publisherID != "" && signerID != r.publisherID {
+		// Advertisements may be signed by a publisher on behalf of the
+		// provider, in which case the signer must be the publisher.
+		r.addProblem(ad.ID, fmt.Errorf("signed by %s which is neither the provider nor the publisher", signerID))
+	}
+
+	if ad.IsRemove || !ad.HasEntries() || verifySampleEntries == 0 || rand.Float64() >= verifySampleEntries {
+		return
+	}
+	r.sampledAds++
+	for verifySampleChunks == 0 || uint(ad.Entries.ChunkCount()) < verifySampleChunks {
+		mhs, err := ad.Entries.NextChunk()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			r.addProblem(ad.ID, fmt.Errorf("invalid entries: %w", err))
+			break
+		}
+		r.multihashes += len(mhs)
+	}
+	r.chunks += ad.Entries.ChunkCount()
+}
+
+// verifySequence checks that the sequence embedded in the advertisement, if
+// any, precedes that of the later advertisement verified before it by the
+// number of advertisements between them, and that its timestamp is earlier.
+func (r *verifyReport) verifySequence(ad *internal.Advertisement) {
+	r.sinceSeq++
+	seq, err := engine.GetAdSequence(ad.Metadata)
+	if err != nil {
+		r.addProblem(ad.ID, fmt.Errorf("invalid sequence: %w", err))
+		return
+	}
+	if seq == nil {
+		return
+	}
+	if r.seqs == 0 {
+		r.first = *seq
+	} else {
+		if seq.Seq+r.sinceSeq != r.last.Seq {
+			r.addProblem(ad.ID, fmt.Errorf("sequence %d does not precede sequence %d by %d advertisements", seq.Seq, r.last.Seq, r.sinceSeq))
+		}
+		if !seq.Time.Before(r.last.Time) {
+			r.addProblem(ad.ID, fmt.Errorf("timestamp %s is not before timestamp %s of later advertisement",
+				seq.Time.Format(time.RFC3339Nano), r.last.Time.Format(time.RFC3339Nano)))
+		}
+	}
+	r.seqs++
+	r.last = *seq
+	r.sinceSeq = 0
+}
+
+func (r *verifyReport) addProblem(adCid cid.Cid, err error) {
+	r.problems = append(r.problems, verifyProblem{adCid: adCid, err: err})
+}
+
+func (r *verifyReport) print(w io.Writer) {
+	fmt.Fprintf(w, "Verified advertisement chain starting at %s\n", r.head)
+	fmt.Fprintf(w, "  Advertisements: %d (%d removals)\n", r.ads, r.removals)
+	if r.complete {
+		fmt.Fprintln(w, "  Reached start of chain: yes")
+	} else {
+		fmt.Fprintln(w, "  Reached start of chain: no")
+	}
+	if r.seqs != 0 {
+		fmt.Fprintf(w, "  Sequences: %d advertisements, from %d at %s to %d at %s\n", r.seqs,
+			r.last.Seq, r.last.Time.Format(time.RFC3339), r.first.Seq, r.first.Time.Format(time.RFC3339))
+	}
+	if verifySampleEntries != 0 {
+		fmt.Fprintf(w, "  Entries sampled: %d advertisements, %d chunks, %d multihashes\n", r.sampledAds, r.chunks, r.multihashes)
+	}
+	if len(r.problems) == 0 {
+		fmt.Fprintln(w, "  Problems: none")
+		return
+	}
+	fmt.Fprintf(w, "  Problems: %d\n", len(r.problems))
+	for _, p := range r.problems {
+		fmt.Fprintf(w, "    %s: %s\n", p.adCid, p.err)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/ipni/go-libipni/test"
+	"github.com/ipni/index-provider/cmd/provider/internal"
+	"github.com/ipni/index-provider/engine"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyReport_Sequence(t *testing.T) {
+	start := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	newAd := func(seq uint64, at time.Time) *internal.Advertisement {
+		ad := &internal.Advertisement{ID: test.RandomCids(1)[0]}
+		if seq != 0 {
+			var err error
+			ad.Metadata, err = (&engine.AdSequence{Seq: seq, Time: at}).MarshalBinary()
+			require.NoError(t, err)
+		}
+		return ad
+	}
+
+	// Advertisements are verified from the latest to the earliest, and those
+	// without a sequence count towards the gap between sequences.
+	r := &verifyReport{}
+	r.verifySequence(newAd(5, start.Add(5*time.Second)))
+	r.verifySequence(newAd(0, time.Time{}))
+	r.verifySequence(newAd(3, start.Add(3*time.Second)))
+	r.verifySequence(newAd(2, start.Add(2*time.Second)))
+	require.Empty(t, r.problems)
+	var out bytes.Buffer
+	r.print(&out)
+	require.Contains(t, out.String(), "Sequences: 3 advertisements, from 2 at 2023-05-01T12:00:02Z to 5 at 2023-05-01T12:00:05Z\n")
+
+	gap := newAd(0, start)
+	r.verifySequence(gap)
+	notBefore := newAd(1, start.Add(2*time.Second))
+	r.verifySequence(notBefore)
+	require.Len(t, r.problems, 2)
+	require.Equal(t, notBefore.ID, r.problems[0].adCid)
+	require.ErrorContains(t, r.problems[0].err, "sequence 1 does not precede sequence 2 by 2 advertisements")
+	require.ErrorContains(t, r.problems[1].err, "is not before timestamp")
+}
@@ -0,0 +1,105 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ipni/go-libipni/ingest/schema"
+)
+
+// MaxContextIDLen is the maximum number of bytes of a context ID accepted by
+// indexers.
+const MaxContextIDLen = schema.MaxContextIDLen
+
+// ContextID identifies a set of advertised multihashes, such that they are
+// updated or removed together. Since its underlying type is []byte, a
+// ContextID is passed as is wherever a context ID is taken as []byte, such as
+// to Interface.NotifyPut.
+//
+// Context IDs built by NewContextID are namespaced and versioned, which
+// prevents the context IDs of different kinds of content, or of different
+// schemes of deriving context IDs from the same kind of content, from
+// colliding.
+type ContextID []byte
+
+// NewContextID builds a context ID made of the given namespace, version and
+// ID, such as the UUID of a deal, in the form "<namespace>/v<version>/<id>".
+// The namespace must not be empty nor contain "/". The namespace, version and
+// ID are recovered from the context ID by ContextID.Split.
+//
+// An error wrapping ErrContextIDTooLong is returned if the context ID is
+// longer than MaxContextIDLen, in which case a shorter namespace, or a hash
+// of the ID, should be used.
+func NewContextID(namespace string, version uint, id []byte) (ContextID, error) {
+	if namespace == "" {
+		return nil, fmt.Errorf("context ID namespace must not be empty")
+	}
+	if strings.Contains(namespace, "/") {
+		return nil, fmt.Errorf("context ID namespace %q must not contain \"/\"", namespace)
+	}
+	if len(id) == 0 {
+		return nil, ErrEmptyContextID
+	}
+	c := make(ContextID, 0, len(namespace)+len(id)+8)
+	c = append(c, namespace...)
+	c = append(c, "/v"...)
+	c = strconv.AppendUint(c, uint64(version), 10)
+	c = append(c, '/')
+	c = append(c, id...)
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Split splits a context ID built by NewContextID into its namespace, version
+// and ID. ok is false if the context ID was not built by NewContextID.
+func (c ContextID) Split() (namespace string, version uint, id []byte, ok bool) {
+	ns, rest, found := bytes.Cut(c, []byte("/v"))
+	if !found || len(ns) == 0 || bytes.IndexByte(ns, '/') != -1 {
+		return "", 0, nil, false
+	}
+	v, id, found := bytes.Cut(rest, []byte("/"))
+	if !found || len(id) == 0 {
+		return "", 0, nil, false
+	}
+	parsed, err := strconv.ParseUint(string(v), 10, 0)
+	if err != nil || strconv.FormatUint(parsed, 10) != string(v) {
+		return "", 0, nil, false
+	}
+	return string(ns), uint(parsed), id, true
+}
+
+// HasNamespace returns whether the context ID was built by NewContextID with
+// the given namespace.
+func (c ContextID) HasNamespace(namespace string) bool {
+	ns, _, _, ok := c.Split()
+	return ok && ns == namespace
+}
+
+// Validate checks that the context ID is accepted by indexers, i.e. that it
+// is not empty and no longer than MaxContextIDLen.
+func (c ContextID) Validate() error {
+	if len(c) == 0 {
+		return ErrEmptyContextID
+	}
+	return validateContextIDLen(c)
+}
+
+// String encodes the context ID as unpadded base64url, which is safe to use
+// in datastore keys, file names and URLs. See: ParseContextID.
+func (c ContextID) String() string {
+	return base64.RawURLEncoding.EncodeToString(c)
+}
+
+// ParseContextID decodes a context ID encoded by ContextID.String.
+func ParseContextID(s string) (ContextID, error) {
+	c, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid context ID encoding: %w", err)
+	}
+	return c, nil
+}
@@ -0,0 +1,66 @@
+package provider
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewContextID(t *testing.T) {
+	dealUUID := []byte("4f5b1ec9-3b4a-4a4e-9b0e-0d7d6f1c2a3b")
+	c, err := NewContextID("deal", 2, dealUUID)
+	require.NoError(t, err)
+	require.Equal(t, "deal/v2/"+string(dealUUID), string(c))
+	ns, version, id, ok := c.Split()
+	require.True(t, ok)
+	require.Equal(t, "deal", ns)
+	require.Equal(t, uint(2), version)
+	require.Equal(t, dealUUID, id)
+	require.True(t, c.HasNamespace("deal"))
+	require.False(t, c.HasNamespace("piece"))
+
+	// IDs may contain the separator.
+	c, err = NewContextID("file", 0, []byte("dir/v1/fish"))
+	require.NoError(t, err)
+	ns, version, id, ok = c.Split()
+	require.True(t, ok)
+	require.Equal(t, "file", ns)
+	require.Equal(t, uint(0), version)
+	require.Equal(t, []byte("dir/v1/fish"), id)
+
+	_, err = NewContextID("", 1, dealUUID)
+	require.ErrorContains(t, err, "namespace must not be empty")
+	_, err = NewContextID("deal/piece", 1, dealUUID)
+	require.ErrorContains(t, err, "must not contain")
+	_, err = NewContextID("deal", 1, nil)
+	require.ErrorIs(t, err, ErrEmptyContextID)
+	_, err = NewContextID("deal", 1, bytes.Repeat([]byte{1}, MaxContextIDLen))
+	require.ErrorIs(t, err, ErrContextIDTooLong)
+
+	for _, raw := range []string{"fish", "/v1/fish", "deal/v1/", "deal/vx/fish", "deal/v01/fish", "de/al/v1/fish"} {
+		_, _, _, ok = ContextID(raw).Split()
+		require.False(t, ok, raw)
+	}
+}
+
+func TestContextID_Validate(t *testing.T) {
+	require.ErrorIs(t, ContextID(nil).Validate(), ErrEmptyContextID)
+	require.NoError(t, ContextID("fish").Validate())
+	require.NoError(t, ContextID(strings.Repeat("f", MaxContextIDLen)).Validate())
+	err := ContextID(strings.Repeat("f", MaxContextIDLen+1)).Validate()
+	require.ErrorIs(t, err, ErrContextIDTooLong)
+	require.ErrorContains(t, err, "65 bytes, maximum is 64")
+}
+
+func TestContextID_String(t *testing.T) {
+	c := ContextID([]byte{0xfb, 0xff, '/', 'f'})
+	s := c.String()
+	require.Equal(t, "-_8vZg", s)
+	parsed, err := ParseContextID(s)
+	require.NoError(t, err)
+	require.Equal(t, c, parsed)
+	_, err = ParseContextID("fish!")
+	require.Error(t, err)
+}
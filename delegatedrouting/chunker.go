@@ -14,8 +14,13 @@ type chunker struct {
 	chunkByContextId map[string]*cidsChunk
 	currentChunk     *cidsChunk
 	currentChunkTime time.Time
-	chunkSizeFunc    func() int
-	nonceGen         func() []byte
+	// currentChunkBytes is the total size of the multihashes in the current chunk.
+	currentChunkBytes int
+	chunkSizeFunc     func() int
+	// chunkMaxBytes is the maximum total size of the multihashes in a chunk, or
+	// zero if unlimited.
+	chunkMaxBytes int
+	nonceGen      func() []byte
 }
 
 type cidsChunk struct {
@@ -35,13 +40,14 @@ func defaultNonceGen() []byte {
 	return nonce
 }
 
-func newChunker(chunkSizeFunc func() int, nonceGenFunc func() []byte) *chunker {
+func newChunker(chunkSizeFunc func() int, chunkMaxBytes int, nonceGenFunc func() []byte) *chunker {
 	if nonceGenFunc == nil {
 		nonceGenFunc = defaultNonceGen
 	}
 	ch := &chunker{
 		chunkByContextId: make(map[string]*cidsChunk),
 		chunkSizeFunc:    chunkSizeFunc,
+		chunkMaxBytes:    chunkMaxBytes,
 		nonceGen:         nonceGenFunc,
 	}
 	ch.setNewCurrentChunk()
@@ -66,6 +72,7 @@ func (ch *chunker) removeChunk(chunk *cidsChunk) {
 func (ch *chunker) setNewCurrentChunk() {
 	ch.currentChunk = &cidsChunk{Cids: make(map[cid.Cid]struct{}, ch.chunkSizeFunc()), Removed: false}
 	ch.currentChunkTime = time.Now()
+	ch.currentChunkBytes = 0
 }
 
 func (ch *chunker) addCidToCurrentChunk(ctx context.Context, c cid.Cid, chunkFullFunc func(*cidsChunk) error) error {
@@ -75,18 +82,31 @@ func (ch *chunker) addCidToCurrentChunk(ctx context.Context, c cid.Cid, chunkFul
 	}
 
 	// if the current chunk is full - publish it and create a new one
-	if len(ch.currentChunk.Cids) >= ch.chunkSizeFunc() {
+	size := len(c.Hash())
+	if len(ch.currentChunk.Cids) >= ch.chunkSizeFunc() ||
+		(ch.chunkMaxBytes > 0 && len(ch.currentChunk.Cids) > 0 && ch.currentChunkBytes+size > ch.chunkMaxBytes) {
 		err := ch.flushCurrentChunk(ctx, chunkFullFunc)
 		if err != nil {
 			return err
 		}
 	}
 
-	ch.currentChunk.Cids[c] = struct{}{}
+	ch.restoreCid(c)
 
 	return nil
 }
 
+// restoreCid adds the cid to the current chunk without publishing the chunk if
+// it is full, e.g. when restoring the pending cids of the current chunk from the
+// datastore. The chunk gets published when the next cid is added.
+func (ch *chunker) restoreCid(c cid.Cid) {
+	if _, ok := ch.currentChunk.Cids[c]; ok {
+		return
+	}
+	ch.currentChunk.Cids[c] = struct{}{}
+	ch.currentChunkBytes += len(c.Hash())
+}
+
 func (ch *chunker) flushCurrentChunk(ctx context.Context, chunkFullFunc func(*cidsChunk) error) error {
 	ch.currentChunk.ContextID = ch.generateContextID(ch.currentChunk.Cids)
 	err := chunkFullFunc(ch.currentChunk)
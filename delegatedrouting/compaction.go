@@ -0,0 +1,122 @@
+package delegatedrouting
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// Compact merges the advertised chunks that hold fewer CIDs than the chunk
+// size, such as chunks flushed before they got full, into as few chunks as the
+// chunk size and the ChunkMaxBytes option allow. Each merged chunk is
+// advertised before the chunks that it supersedes are retracted, so that their
+// CIDs remain discoverable throughout. The number of chunks retracted is
+// returned.
+//
+// Compacting keeps the number of contexts that indexers have to track for the
+// provider, and the time it takes to ingest them, bounded by the number of CIDs
+// provided rather than by the number of provide requests.
+func (listener *Listener) Compact(ctx context.Context) (int, error) {
+	listener.lock.Lock()
+	defer listener.lock.Unlock()
+
+	if listener.provider() == "" {
+		return 0, nil
+	}
+
+	var compacted int
+	for _, group := range listener.compactionGroups() {
+		if err := ctx.Err(); err != nil {
+			return compacted, err
+		}
+
+		merged := &cidsChunk{Cids: make(map[cid.Cid]struct{}, listener.chunkSize)}
+		for _, chunk := range group {
+			for c := range chunk.Cids {
+				merged.Cids[c] = struct{}{}
+			}
+		}
+		merged.ContextID = listener.chunker.generateContextID(merged.Cids)
+		err := listener.notifyPutAndPersist(ctx, merged)
+		if err != nil {
+			log.Warnw("Error advertising compacted chunk. Continuing.", "contextID", contextIDToStr(merged.ContextID), "err", err)
+			continue
+		}
+
+		for _, chunk := range group {
+			// the cids have already been reassigned to the merged chunk, so that a failed removal only leaves the old
+			// chunk advertised until it expires
+			err = listener.notifyRemoveAndPersist(ctx, chunk)
+			if err != nil {
+				log.Warnw("Error removing compacted chunk. Continuing.", "contextID", contextIDToStr(chunk.ContextID), "err", err)
+				continue
+			}
+			compacted++
+			listener.stats.incChunksCompacted()
+		}
+	}
+	if compacted > 0 {
+		log.Infow("Finished compacting chunks.", "chunksCompacted", compacted)
+	}
+	return compacted, nil
+}
+
+// compactionGroups groups the advertised chunks that are not full, oldest
+// first, such that each group fits into a single chunk. Groups of a single chunk
+// are left out as there is nothing to merge them with.
+func (listener *Listener) compactionGroups() [][]*cidsChunk {
+	var groups [][]*cidsChunk
+	var group []*cidsChunk
+	var groupCids, groupBytes int
+	closeGroup := func() {
+		if len(group) > 1 {
+			groups = append(groups, group)
+		}
+		group = nil
+		groupCids, groupBytes = 0, 0
+	}
+
+	seen := make(map[*cidsChunk]struct{})
+	for elem := listener.cidQueue.nodesLl.Back(); elem != nil; elem = elem.Prev() {
+		chunk := elem.Value.(*cidNode).chunk
+		if chunk == nil || len(chunk.Cids) >= listener.chunkSize {
+			continue
+		}
+		if _, ok := seen[chunk]; ok {
+			continue
+		}
+		seen[chunk] = struct{}{}
+
+		var chunkBytes int
+		for c := range chunk.Cids {
+			chunkBytes += len(c.Hash())
+		}
+		if groupCids+len(chunk.Cids) > listener.chunkSize ||
+			(listener.chunker.chunkMaxBytes > 0 && groupBytes+chunkBytes > listener.chunker.chunkMaxBytes) {
+			closeGroup()
+		}
+		group = append(group, chunk)
+		groupCids += len(chunk.Cids)
+		groupBytes += chunkBytes
+	}
+	closeGroup()
+	return groups
+}
+
+func (listener *Listener) compactionWorker(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		_, err := listener.Compact(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Warnw("Error compacting chunks", "err", err)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package delegatedrouting_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	drouting "github.com/ipni/index-provider/delegatedrouting"
+	"github.com/ipni/index-provider/engine"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompactMergesChunksThatAreNotFull(t *testing.T) {
+	ctx := context.Background()
+	pID, priv, _ := test.RandomIdentity()
+	testCid1 := newCid("test1")
+	testCid2 := newCid("test2")
+	testCid3 := newCid("test3")
+	testCid4 := newCid("test4")
+
+	eng, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	defer eng.Shutdown()
+
+	listener, err := drouting.New(ctx, eng, time.Hour, 3, 1000, "", nil, datastore.NewMapDatastore(), testNonceGen, drouting.WithAdFlushFrequency(0))
+	require.NoError(t, err)
+	defer listener.Shutdown()
+	c, s := createClientAndServer(t, listener, newAddrInfo(t, pID), priv)
+	defer s.Close()
+
+	// nothing to compact
+	n, err := listener.Compact(ctx)
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	for _, cids := range [][]cid.Cid{{testCid1}, {testCid2}, {testCid3, testCid4}} {
+		provideMany(t, c, ctx, cids)
+		_, err = listener.Flush(ctx)
+		require.NoError(t, err)
+	}
+
+	// the two chunks of one cid are merged, and the chunk of two cids is left alone as it doesn't fit with them
+	n, err = listener.Compact(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+
+	merged := generateContextID([]string{testCid1.String(), testCid2.String()}, testNonceGen())
+	info, err := eng.GetContextInfo(ctx, pID, merged)
+	require.NoError(t, err)
+	require.Equal(t, 2, info.MultihashCount)
+	_, err = eng.GetContextInfo(ctx, pID, generateContextID([]string{testCid3.String(), testCid4.String()}, testNonceGen()))
+	require.NoError(t, err)
+	for _, old := range []cid.Cid{testCid1, testCid2} {
+		_, err = eng.GetContextInfo(ctx, pID, generateContextID([]string{old.String()}, testNonceGen()))
+		require.ErrorIs(t, err, provider.ErrContextIDNotFound)
+		require.True(t, drouting.ChunkNotExist(ctx, listener, []cid.Cid{old}, testNonceGen))
+	}
+
+	n, err = listener.Compact(ctx)
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
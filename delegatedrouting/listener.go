@@ -28,8 +28,9 @@ import (
 )
 
 /**
-index-provider integrates with Kubo by utilising HTTP Delegated Routing API. The API currently supports only GETs requests.
-See [IPIP-378](https://github.com/ipfs/specs/pull/378) for the latest updates on PUTs.
+index-provider integrates with Kubo by implementing the HTTP Delegated Routing /routing/v1 API. Provide requests, i.e. PUT /routing/v1/providers,
+are handled by the ProvideBitswap method. See [IPIP-378](https://github.com/ipfs/specs/pull/378) for the latest updates on PUTs. Find providers and
+find peers requests are answered with the peer record of the provider, for the CIDs it has provided only. IPNS requests are not supported.
 
 index-provider listens to annnouncement from Kubo that are handled by the ProvideBitswap method.
 Provide announcements can come either for individual CIDs, for example when a new file gets added to Kubo or for Snapshots.
@@ -50,6 +51,8 @@ After the chunk has been requested at least once it gets evicted from RAM.
 There can be significant time gaps between ProvideBitswap calls, for example when Kubo doesn't have any new data. That can result into
 long time before the current chunk gets full. To prevent the current chunk from being stuck, index-provider periodically flushes it - adds whatever CIDs
 are in it into the new Advertisement and replaces it with a new current chunk.  Flush frequency is driven by the Listener.adFlushFrequency parameter.
+The current chunk is also considered full before the total size of its multihashes exceeds the ChunkMaxBytes option, and can be flushed on demand via
+Listener.Flush. The current chunk isn't persisted as such. Instead, on restart it is restored from the CIDs in the expiry queue that have no chunk assigned.
 
 Kubo doesn't give any context on which CIDs have been removed. A CID is considered to be removed if it disappears between
 two consequitive Snapshots. To remove a CID index-provider needs to find the Advertisement where that CID has been announced, send IsRM
@@ -58,6 +61,10 @@ index-provider maintains an ordered queue of CIDs by their expiry time (cid_queu
 gets pushed to the end of the queue. In the end of each ProvideBitswap invocation index-provider checks whether any CIDs have expired by looking at the head of the queue
 and generates IsRm advertisements for them. This is handled in Listener.removeExpiredCids method. CID "time to live" is driven by Listener.cidTtl parameter.
 
+Flushed chunks, and replacement chunks generated on removal, can hold far fewer CIDs than the chunk size. To keep the number of contexts that indexers
+track bounded, index-provider can periodically compact chunks that are not full: it merges them into as few chunks as possible, advertises the merged
+chunks and then retracts the superseded ones. Compaction frequency is driven by the CompactionInterval option. The logic is located in compaction.go.
+
 index-provider offers persistence too. It is handled in ds_wrapper.go. index-provider persists two different datasets: 1. Chunks and 2. CIDs with their expiry times.
 Chunks are persisted as a map by their ContextID. CIDs are persisted as "snapshots" - that was done because persisting each CID individually resulted into
 significant database load for large nodes. CID snapshot is a binary blob of all CIDs with their expiry times. CIDs snapshot gets persisted only when Kubo reprovides
@@ -168,7 +175,7 @@ func New(ctx context.Context, engine provider.Interface,
 		dsWrapper:              newDSWrapper(namespace.Wrap(ds, datastore.NewKey(delegatedRoutingDSName)), options.SnapshotMaxChunkSize, options.PageSize),
 		lastSeenProviderInfo:   &peer.AddrInfo{},
 		configuredProviderInfo: nil,
-		chunker:                newChunker(func() int { return chunkSize }, nonceGen),
+		chunker:                newChunker(func() int { return chunkSize }, options.ChunkMaxBytes, nonceGen),
 		cidQueue:               newCidQueue(),
 		adFlushFrequency:       options.AdFlushFrequency,
 		contextCancelFunc:      cancelFunc,
@@ -236,7 +243,14 @@ func New(ctx context.Context, engine provider.Interface,
 		listener.dsWrapper.recordTimestampsSnapshot(ctx, listener.cidQueue.getTimestampsSnapshot())
 	}
 
-	log.Infof("Loaded up %d cids and %d chunks from the datastore.", len(listener.cidQueue.listNodeByCid), len(listener.chunker.chunkByContextId))
+	// restoring the current chunk from the cids that haven't been advertised before the restart
+	for elem := listener.cidQueue.nodesLl.Front(); elem != nil; elem = elem.Next() {
+		if node := elem.Value.(*cidNode); node.chunk == nil {
+			listener.chunker.restoreCid(node.C)
+		}
+	}
+
+	log.Infof("Loaded up %d cids, %d chunks and %d pending cids from the datastore.", len(listener.cidQueue.listNodeByCid), len(listener.chunker.chunkByContextId), len(listener.chunker.currentChunk.Cids))
 
 	if providerId != "" {
 		p, err := peer.Decode(providerId)
@@ -266,6 +280,11 @@ func New(ctx context.Context, engine provider.Interface,
 		go listener.flushWorker(cctx)
 	}
 
+	// start compaction worker
+	if options.CompactionInterval > 0 {
+		go listener.compactionWorker(cctx, options.CompactionInterval)
+	}
+
 	return listener, nil
 }
 
@@ -284,14 +303,48 @@ func (listener *Listener) PutIPNS(ctx context.Context, name ipns.Name, record *i
 	return errors.New("unsupported put ipns request")
 }
 
+// FindPeers returns the peer record of the provider whose CIDs are advertised
+// by the listener, if pid is that provider. Otherwise, no records are returned.
 func (listener *Listener) FindPeers(ctx context.Context, pid peer.ID, limit int) (iter.ResultIter[*types.PeerRecord], error) {
-	log.Warn("Received unsupported FindPeers request")
-	return nil, errors.New("unsupported find peers request")
+	listener.lock.Lock()
+	defer listener.lock.Unlock()
+
+	var records []iter.Result[*types.PeerRecord]
+	if pid != "" && pid == listener.provider() {
+		records = append(records, iter.Result[*types.PeerRecord]{Val: listener.peerRecord()})
+	}
+	return iter.FromSlice(records), nil
 }
 
+// FindProviders returns the peer record of the provider whose CIDs are
+// advertised by the listener, if the given CID is one of them. Otherwise, no
+// records are returned. Providers of other CIDs are to be found via IPNI or the
+// DHT.
 func (listener *Listener) FindProviders(ctx context.Context, key cid.Cid, limit int) (iter.ResultIter[types.Record], error) {
-	log.Warn("Received unsupported FindProviders request")
-	return nil, errors.New("unsupported find providers request")
+	listener.lock.Lock()
+	defer listener.lock.Unlock()
+
+	var records []iter.Result[types.Record]
+	if listener.provider() != "" && listener.cidQueue.getNodeByCid(key) != nil {
+		records = append(records, iter.Result[types.Record]{Val: listener.peerRecord()})
+	}
+	return iter.FromSlice(records), nil
+}
+
+// peerRecord returns the peer record of the provider whose CIDs are advertised
+// by the listener, which retrieves the CIDs over bitswap.
+func (listener *Listener) peerRecord() *types.PeerRecord {
+	pid := listener.provider()
+	var addrs []types.Multiaddr
+	for _, a := range listener.addrs() {
+		addrs = append(addrs, types.Multiaddr{Multiaddr: a})
+	}
+	return &types.PeerRecord{
+		Schema:    types.SchemaPeer,
+		ID:        &pid,
+		Addrs:     addrs,
+		Protocols: []string{"transfer-bitswap"},
+	}
 }
 
 func (listener *Listener) ProvideBitswap(ctx context.Context, req *server.BitswapWriteProvideRequest) (time.Duration, error) {
@@ -343,9 +396,7 @@ func (listener *Listener) ProvideBitswap(ctx context.Context, req *server.Bitswa
 				C:         c,
 				Timestamp: startTime,
 			})
-			err := listener.chunker.addCidToCurrentChunk(ctx, c, func(cc *cidsChunk) error {
-				return listener.notifyPutAndPersist(ctx, cc)
-			})
+			err := listener.addCidToCurrentChunk(ctx, c)
 			if err != nil {
 				log.Errorw("Error adding a cid to the current chunk. Continuing.", "cid", c, "err", err)
 				listener.cidQueue.removeCidNode(c)
@@ -357,13 +408,11 @@ func (listener *Listener) ProvideBitswap(ctx context.Context, req *server.Bitswa
 			listener.cidQueue.recordCidNode(node)
 			// if no existing chunk has been found for the cid - adding it to the current one
 			// This can happen in the following cases:
-			//     * when currentChunk disappears between restarts as it doesn't get persisted until it's advertised
+			//     * when the cid is in the current chunk, which gets restored from the expiry queue on restart
 			//     * when the same cid comes multiple times within the lifespan of the same chunk
 			//	   * after a error to generate a replacement chunk
 			if node.chunk == nil {
-				err := listener.chunker.addCidToCurrentChunk(ctx, c, func(cc *cidsChunk) error {
-					return listener.notifyPutAndPersist(ctx, cc)
-				})
+				err := listener.addCidToCurrentChunk(ctx, c)
 				if err != nil {
 					log.Errorw("Error adding a cid to the current chunk. Continuing.", "cid", c, "err", err)
 					continue
@@ -550,6 +599,38 @@ func (listener *Listener) notifyPutAndPersist(ctx context.Context, chunk *cidsCh
 	return nil
 }
 
+// Flush converts the current chunk to an advertisement and publishes it right
+// away, regardless of its size and age, and returns the number of CIDs in it.
+// Nothing is published if the current chunk is empty, or if no provide request
+// has been received since start and no provider is configured.
+func (listener *Listener) Flush(ctx context.Context) (int, error) {
+	listener.lock.Lock()
+	defer listener.lock.Unlock()
+	return listener.flushCurrentChunk(ctx)
+}
+
+func (listener *Listener) flushCurrentChunk(ctx context.Context) (int, error) {
+	n := len(listener.chunker.currentChunk.Cids)
+	if n == 0 || listener.provider() == "" {
+		return 0, nil
+	}
+	err := listener.chunker.flushCurrentChunk(ctx, func(cc *cidsChunk) error {
+		return listener.notifyPutAndPersist(ctx, cc)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// addCidToCurrentChunk adds the cid to the current chunk, publishing the chunk
+// first if adding the cid would exceed its size limits.
+func (listener *Listener) addCidToCurrentChunk(ctx context.Context, c cid.Cid) error {
+	return listener.chunker.addCidToCurrentChunk(ctx, c, func(cc *cidsChunk) error {
+		return listener.notifyPutAndPersist(ctx, cc)
+	})
+}
+
 func (listener *Listener) provider() peer.ID {
 	if listener.configuredProviderInfo == nil {
 		return listener.lastSeenProviderInfo.ID
@@ -579,11 +660,8 @@ func (listener *Listener) flushWorker(ctx context.Context) {
 		defer listener.lock.Unlock()
 		// flush only if the current chunk has some cids in it and the time since the current chunk has been created is
 		// greater than the flush frequency
-		if len(listener.chunker.currentChunk.Cids) > 0 &&
-			time.Since(listener.chunker.currentChunkTime) > listener.adFlushFrequency {
-			err := listener.chunker.flushCurrentChunk(ctx, func(cc *cidsChunk) error {
-				return listener.notifyPutAndPersist(ctx, cc)
-			})
+		if time.Since(listener.chunker.currentChunkTime) > listener.adFlushFrequency {
+			_, err := listener.flushCurrentChunk(ctx)
 			if err != nil {
 				log.Warnw("Error flushing current chunk", "err", err)
 			}
@@ -13,6 +13,8 @@ import (
 	"github.com/ipfs/boxo/routing/http/client"
 	"github.com/ipfs/boxo/routing/http/contentrouter"
 	"github.com/ipfs/boxo/routing/http/server"
+	"github.com/ipfs/boxo/routing/http/types"
+	"github.com/ipfs/boxo/routing/http/types/iter"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
 	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
@@ -171,6 +173,59 @@ func TestProvideRoundtrip(t *testing.T) {
 	require.Equal(t, secondAd.ContextID, generateContextID([]string{testCid3.String(), testCid4.String()}, testNonceGen()))
 }
 
+func TestFindProvidersAndPeers(t *testing.T) {
+	ctx := context.Background()
+	pID, priv, _ := test.RandomIdentity()
+	otherID, _, _ := test.RandomIdentity()
+
+	engine, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, engine.Start(ctx))
+	defer engine.Shutdown()
+
+	ip, err := drouting.New(ctx, engine, time.Hour, 2, 1000, "", nil, datastore.NewMapDatastore(), testNonceGen)
+	require.NoError(t, err)
+	defer ip.Shutdown()
+	addrInfo := newAddrInfo(t, pID)
+	client, server := createClientAndServer(t, ip, addrInfo, priv)
+	defer server.Close()
+
+	testCid1 := newCid("test1")
+	testCid2 := newCid("test2")
+	provide(t, client, ctx, testCid1)
+
+	it, err := client.FindProviders(ctx, testCid1)
+	require.NoError(t, err)
+	records, err := iter.ReadAllResults(it)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	record := records[0].(*types.PeerRecord)
+	require.Equal(t, types.SchemaPeer, record.Schema)
+	require.Equal(t, pID, *record.ID)
+	require.Equal(t, []string{"transfer-bitswap"}, record.Protocols)
+	require.Len(t, record.Addrs, 1)
+	require.True(t, addrInfo.Addrs[0].Equal(record.Addrs[0].Multiaddr))
+
+	it, err = client.FindProviders(ctx, testCid2)
+	require.NoError(t, err)
+	records, err = iter.ReadAllResults(it)
+	require.NoError(t, err)
+	require.Empty(t, records)
+
+	peers, err := client.FindPeers(ctx, pID)
+	require.NoError(t, err)
+	peerRecords, err := iter.ReadAllResults(peers)
+	require.NoError(t, err)
+	require.Len(t, peerRecords, 1)
+	require.Equal(t, pID, *peerRecords[0].ID)
+
+	peers, err = client.FindPeers(ctx, otherID)
+	require.NoError(t, err)
+	peerRecords, err = iter.ReadAllResults(peers)
+	require.NoError(t, err)
+	require.Empty(t, peerRecords)
+}
+
 func TestProvideRoundtripWithRemove(t *testing.T) {
 	ttl := time.Second
 	chunkSize := 2
@@ -1375,6 +1430,87 @@ func TestAdsFlush(t *testing.T) {
 	time.Sleep(2 * adFlusFreq)
 }
 
+func TestAdvertiseChunkBeforeExceedingMaxBytes(t *testing.T) {
+	ttl := 1 * time.Hour
+	chunkSize := 100
+	snapshotSize := 1000
+	pID, priv, _ := test.RandomIdentity()
+	ctx := context.Background()
+	testCid1 := newCid("test1")
+	testCid2 := newCid("test2")
+	testCid3 := newCid("test3")
+
+	mc := gomock.NewController(t)
+	defer mc.Finish()
+	mockEng := mock_provider.NewMockInterface(mc)
+	mockEng.EXPECT().RegisterMultihashLister(gomock.Any())
+	mockEng.EXPECT().NotifyPut(gomock.Any(), gomock.Any(), gomock.Eq(generateContextID([]string{testCid1.String(), testCid2.String()}, testNonceGen())), gomock.Eq(defaultMetadata))
+
+	// each multihash is 7 bytes long, so that only two of them fit into a chunk
+	listener, err := drouting.New(ctx, mockEng, ttl, chunkSize, snapshotSize, "", nil, datastore.NewMapDatastore(), testNonceGen,
+		drouting.WithAdFlushFrequency(0), drouting.WithChunkMaxBytes(2*len(testCid1.Hash())+1))
+	require.NoError(t, err)
+	defer listener.Shutdown()
+
+	c, s := createClientAndServer(t, listener, newAddrInfo(t, pID), priv)
+	defer s.Close()
+
+	provideMany(t, c, ctx, []cid.Cid{testCid1, testCid2, testCid3})
+	require.Equal(t, map[cid.Cid]struct{}{testCid3: {}}, drouting.GetCurrentChunk(ctx, listener).Cids)
+}
+
+func TestFlushAndRestorePendingCids(t *testing.T) {
+	ttl := 1 * time.Hour
+	chunkSize := 10
+	snapshotSize := 1000
+	pID, priv, _ := test.RandomIdentity()
+	ctx := context.Background()
+	testCid1 := newCid("test1")
+	testCid2 := newCid("test2")
+	ds := datastore.NewMapDatastore()
+
+	mc := gomock.NewController(t)
+	defer mc.Finish()
+	mockEng := mock_provider.NewMockInterface(mc)
+	mockEng.EXPECT().RegisterMultihashLister(gomock.Any()).Times(4)
+	mockEng.EXPECT().NotifyPut(gomock.Any(), gomock.Any(), gomock.Eq(generateContextID([]string{testCid1.String(), testCid2.String()}, testNonceGen())), gomock.Eq(defaultMetadata))
+
+	listener, err := drouting.New(ctx, mockEng, ttl, chunkSize, snapshotSize, "", nil, ds, testNonceGen, drouting.WithAdFlushFrequency(0))
+	require.NoError(t, err)
+	c, s := createClientAndServer(t, listener, newAddrInfo(t, pID), priv)
+	provideMany(t, c, ctx, []cid.Cid{testCid1, testCid2})
+	s.Close()
+	listener.Shutdown()
+
+	// the pending cids are restored after a restart, but can't be flushed until the provider is known
+	listener, err = drouting.New(ctx, mockEng, ttl, chunkSize, snapshotSize, "", nil, ds, testNonceGen, drouting.WithAdFlushFrequency(0))
+	require.NoError(t, err)
+	require.Equal(t, map[cid.Cid]struct{}{testCid1: {}, testCid2: {}}, drouting.GetCurrentChunk(ctx, listener).Cids)
+	require.ElementsMatch(t, []cid.Cid{testCid1, testCid2}, drouting.GetExpiryQueue(ctx, listener))
+	n, err := listener.Flush(ctx)
+	require.NoError(t, err)
+	require.Zero(t, n)
+	listener.Shutdown()
+
+	listener, err = drouting.New(ctx, mockEng, ttl, chunkSize, snapshotSize, pID.String(), nil, ds, testNonceGen, drouting.WithAdFlushFrequency(0))
+	require.NoError(t, err)
+	n, err = listener.Flush(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Empty(t, drouting.GetCurrentChunk(ctx, listener).Cids)
+	require.True(t, drouting.ChunkExists(ctx, listener, []cid.Cid{testCid1, testCid2}, testNonceGen))
+	n, err = listener.Flush(ctx)
+	require.NoError(t, err)
+	require.Zero(t, n)
+	listener.Shutdown()
+
+	// nothing is pending once flushed
+	listener, err = drouting.New(ctx, mockEng, ttl, chunkSize, snapshotSize, pID.String(), nil, ds, testNonceGen, drouting.WithAdFlushFrequency(0))
+	require.NoError(t, err)
+	defer listener.Shutdown()
+	require.Empty(t, drouting.GetCurrentChunk(ctx, listener).Cids)
+}
+
 func provide(t *testing.T, cc contentrouter.Client, ctx context.Context, c cid.Cid) time.Duration {
 	return provideMany(t, cc, ctx, []cid.Cid{c})
 }
@@ -20,6 +20,15 @@ type Options struct {
 	// to be performed on the current chunk. In other words a non empty current
 	// chunk will be converted to an ad and published.
 	AdFlushFrequency time.Duration
+	// ChunkMaxBytes defines the maximum total size in bytes of the multihashes
+	// in a chunk. The current chunk is converted to an ad and published before
+	// it would exceed this size, even if it has fewer CIDs than the chunk size.
+	// Zero means unlimited.
+	ChunkMaxBytes int
+	// CompactionInterval defines a frequency of a compaction operation that
+	// merges advertised chunks that are not full into fewer chunks, and
+	// retracts the chunks they supersede. Zero disables compaction.
+	CompactionInterval time.Duration
 }
 
 type Option func(*Options)
@@ -42,6 +51,18 @@ func WithAdFlushFrequency(d time.Duration) Option {
 	}
 }
 
+func WithChunkMaxBytes(i int) Option {
+	return func(o *Options) {
+		o.ChunkMaxBytes = i
+	}
+}
+
+func WithCompactionInterval(d time.Duration) Option {
+	return func(o *Options) {
+		o.CompactionInterval = d
+	}
+}
+
 func ApplyOptions(opt ...Option) Options {
 	opts := Options{
 		SnapshotMaxChunkSize: defaultSnapshotMaxChunkSize,
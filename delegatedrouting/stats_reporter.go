@@ -23,6 +23,7 @@ type stats struct {
 	delegatedRoutingCallsProcessed int64
 	chunkCacheMisses               int64
 	chunksNotFound                 int64
+	chunksCompacted                int64
 }
 
 func newStatsReporter(totalCidsFunc func() int, totalChunksFunc func() int, currentChunkSizeFunc func() int) *statsReporter {
@@ -71,6 +72,10 @@ func (reporter *statsReporter) incChunksNotFound() {
 	reporter.s.chunksNotFound++
 }
 
+func (reporter *statsReporter) incChunksCompacted() {
+	reporter.s.chunksCompacted++
+}
+
 func (reporter *statsReporter) start() {
 	reporter.statsTicker = make(chan bool)
 	ticker := time.NewTicker(statsPrintFrequency)
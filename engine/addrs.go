@@ -0,0 +1,223 @@
+package engine
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipni/go-libipni/ingest/schema"
+	provider "github.com/ipni/index-provider"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// NotifyAddrsChanged changes the retrieval addresses of the default provider
+// to the given addresses, and republishes the content currently advertised by
+// the default provider so that indexers retrieve it from the new addresses,
+// e.g. after a change of IP address or port, or a migration to HTTPS.
+//
+// One advertisement is published per context ID, with the same entries and
+// metadata as currently advertised. The entries are not listed or chunked
+// again, since the advertisements link to the entries already published. All
+// advertisements published from then on carry the new addresses.
+//
+// The CID of the last published advertisement is returned, or cid.Undef if no
+// content is currently advertised. If republishing fails part way, the new
+// addresses are kept, and the advertisements already published remain
+// published. Calling NotifyAddrsChanged again republishes all content.
+//
+// See: WithRetrievalAddrs.
+func (e *Engine) NotifyAddrsChanged(ctx context.Context, addrs []multiaddr.Multiaddr) (cid.Cid, error) {
+	if len(addrs) == 0 {
+		return cid.Undef, errors.New("at least one retrieval address is required")
+	}
+	if _, err := e.adRetrievalAddrs(addrs); err != nil {
+		return cid.Undef, err
+	}
+	e.publishLock.Lock()
+	e.provider.Addrs = addrs
+	p := e.provider.ID
+	e.publishLock.Unlock()
+	log.Infow("Retrieval addresses changed", "retrievalAddrs", addrs)
+
+	contextIDs, err := e.listAllContextIDs(ctx, p)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("cannot list advertised context IDs: %w", err)
+	}
+	latest := cid.Undef
+	for _, contextID := range contextIDs {
+		adCid, err := e.republish(ctx, contextID)
+		if err != nil {
+			if errors.Is(err, provider.ErrContextIDNotFound) {
+				// Removed since listed.
+				continue
+			}
+			return latest, fmt.Errorf("cannot republish context ID %s: %w", base64.StdEncoding.EncodeToString(contextID), err)
+		}
+		if adCid != cid.Undef {
+			latest = adCid
+		}
+	}
+	return latest, nil
+}
+
+// IsPrivateAddr returns whether the given multiaddr is a loopback,
+// link-local, unspecified or private network address, or a localhost DNS
+// name, which retrieval clients outside of the host or its network cannot
+// reach. See: WithPrivateRetrievalAddrs.
+func IsPrivateAddr(maddr multiaddr.Multiaddr) bool {
+	return manet.IsPrivateAddr(maddr) || manet.IsIPUnspecified(maddr)
+}
+
+// adRetrievalAddrs returns the given retrieval addresses as put into
+// advertisements: without duplicates and, unless allowed, private addresses,
+// and at most as many as set via WithMaxRetrievalAddrs. An error is returned
+// if no address is left, unless none is given.
+func (e *Engine) adRetrievalAddrs(addrs []multiaddr.Multiaddr) ([]multiaddr.Multiaddr, error) {
+	if len(addrs) == 0 {
+		return addrs, nil
+	}
+	adAddrs := make([]multiaddr.Multiaddr, 0, len(addrs))
+	seen := make(map[string]struct{}, len(addrs))
+	for _, addr := range addrs {
+		if _, ok := seen[string(addr.Bytes())]; ok {
+			continue
+		}
+		seen[string(addr.Bytes())] = struct{}{}
+		if !e.privateRetrievalAddrs && IsPrivateAddr(addr) {
+			continue
+		}
+		adAddrs = append(adAddrs, addr)
+	}
+	if len(adAddrs) == 0 {
+		return nil, fmt.Errorf("no retrieval address to advertise: all of %v are private", addrs)
+	}
+	if e.maxRetrievalAddrs != 0 && len(adAddrs) > e.maxRetrievalAddrs {
+		adAddrs = adAddrs[:e.maxRetrievalAddrs]
+	}
+	return adAddrs, nil
+}
+
+// republish publishes an advertisement of the given context ID of the default
+// provider with its current addresses, linking to the entries and carrying
+// the metadata with which the context ID is currently advertised.
+func (e *Engine) republish(ctx context.Context, contextID []byte) (cid.Cid, error) {
+	e.publishLock.Lock()
+	unlock := sync.OnceFunc(e.publishLock.Unlock)
+	defer unlock()
+	p := e.provider.ID
+	log := log.With("contextID", base64.StdEncoding.EncodeToString(contextID))
+
+	c, err := e.getKeyCidMap(ctx, p, contextID)
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return cid.Undef, provider.ErrContextIDNotFound
+		}
+		return cid.Undef, err
+	}
+	md, err := e.getKeyMetadataMap(ctx, p, contextID)
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			log.Warn("No metadata for advertised context ID, skipping republishing it")
+			return cid.Undef, nil
+		}
+		return cid.Undef, err
+	}
+	mdBytes, err := md.MarshalBinary()
+	if err != nil {
+		return cid.Undef, err
+	}
+	info, err := e.getKeyInfoMap(ctx, p, contextID)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	batch, err := e.ds.Batch(ctx)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("cannot create datastore batch: %w", err)
+	}
+	info.PublishedAt = time.Now().UnixNano()
+	if err = e.putKeyInfoMap(ctx, batch, p, contextID, info); err != nil {
+		return cid.Undef, fmt.Errorf("failed to write provider + context id to info mapping: %s", err)
+	}
+
+	addrs, err := e.adRetrievalAddrs(e.provider.Addrs)
+	if err != nil {
+		return cid.Undef, err
+	}
+	stringAddrs := make([]string, len(addrs))
+	for i, addr := range addrs {
+		stringAddrs[i] = addr.String()
+	}
+
+	adv := schema.Advertisement{
+		Provider:  p.String(),
+		Addresses: stringAddrs,
+		Entries:   cidlink.Link{Cid: c},
+		ContextID: contextID,
+		Metadata:  mdBytes,
+	}
+	if err = e.linkAndSign(ctx, &adv); err != nil {
+		return cid.Undef, err
+	}
+	log.Info("Republishing advertisement with changed retrieval addresses")
+	return e.publish(ctx, adv, info.MultihashCount, 0, batch, unlock)
+}
+
+// listAllContextIDs lists the context IDs currently advertised by the given
+// provider, including those that reuse the entries of another context ID,
+// which Engine.ListContextIDs does not list.
+func (e *Engine) listAllContextIDs(ctx context.Context, p peer.ID) ([][]byte, error) {
+	contextIDs, err := e.ListContextIDs(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]struct{}, len(contextIDs))
+	for _, contextID := range contextIDs {
+		seen[string(contextID)] = struct{}{}
+	}
+
+	results, err := e.ds.Query(ctx, query.Query{Prefix: cidToReusingKeyMapPrefix})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		var pAndC providerAndContext
+		if err = json.Unmarshal(r.Value, &pAndC); err != nil {
+			return nil, err
+		}
+		if _, ok := seen[string(pAndC.ContextID)]; ok {
+			continue
+		}
+		if peer.ID(pAndC.Provider) != p {
+			continue
+		}
+		// Skip stale mappings of context IDs that no longer reuse the entries.
+		c, err := e.getKeyCidMap(ctx, p, pAndC.ContextID)
+		if err != nil {
+			if errors.Is(err, datastore.ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		if c.String() != datastore.RawKey(r.Key).Parent().BaseNamespace() {
+			continue
+		}
+		seen[string(pAndC.ContextID)] = struct{}{}
+		contextIDs = append(contextIDs, pAndC.ContextID)
+	}
+	return contextIDs, nil
+}
@@ -0,0 +1,152 @@
+package engine_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_NotifyAddrsChanged(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	subject, err := engine.New(engine.WithRetrievalAddrs("/ip4/127.0.0.1/tcp/9999"))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	var listed int
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		listed++
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+
+	newAddrs := []multiaddr.Multiaddr{multiaddr.StringCast("/dns4/fish.example/tcp/443/https")}
+	adCid, err := subject.NotifyAddrsChanged(ctx, newAddrs)
+	require.NoError(t, err)
+	require.Equal(t, cid.Undef, adCid, "nothing is republished without content")
+	_, err = subject.NotifyAddrsChanged(ctx, nil)
+	require.ErrorContains(t, err, "at least one")
+
+	md := metadata.Default.New(metadata.Bitswap{})
+	fishCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.NoError(t, err)
+	fish, err := subject.GetAdv(ctx, fishCid)
+	require.NoError(t, err)
+	require.Equal(t, []string{"/dns4/fish.example/tcp/443/https"}, fish.Addresses)
+	_, err = subject.NotifyPutWithEntries(ctx, nil, []byte("lobster"), md, fish.Entries.(cidlink.Link).Cid)
+	require.NoError(t, err)
+	_, err = subject.NotifyPut(ctx, nil, []byte("crab"), md)
+	require.NoError(t, err)
+	_, err = subject.NotifyRemove(ctx, "", []byte("crab"))
+	require.NoError(t, err)
+	require.Equal(t, 2, listed)
+
+	newAddrs = []multiaddr.Multiaddr{multiaddr.StringCast("/ip4/127.0.0.1/tcp/8080/http")}
+	adCid, err = subject.NotifyAddrsChanged(ctx, newAddrs)
+	require.NoError(t, err)
+	// Each advertised context ID is republished with the new addresses and
+	// the same entries, without listing multihashes.
+	require.Equal(t, 2, listed)
+	republished := make(map[string]bool)
+	for c := adCid; len(republished) < 2; {
+		ad, err := subject.GetAdv(ctx, c)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/ip4/127.0.0.1/tcp/8080/http"}, ad.Addresses)
+		require.Equal(t, fish.Entries, ad.Entries)
+		require.False(t, ad.IsRm)
+		republished[string(ad.ContextID)] = true
+		c = ad.PreviousCid()
+	}
+	require.Equal(t, map[string]bool{"fish": true, "lobster": true}, republished)
+
+	info, err := subject.GetContextInfo(ctx, "", []byte("lobster"))
+	require.NoError(t, err)
+	require.Equal(t, 3, info.MultihashCount)
+
+	// Advertisements published from then on carry the new addresses.
+	squidCid, err := subject.NotifyPut(ctx, nil, []byte("squid"), md)
+	require.NoError(t, err)
+	squid, err := subject.GetAdv(ctx, squidCid)
+	require.NoError(t, err)
+	require.Equal(t, []string{"/ip4/127.0.0.1/tcp/8080/http"}, squid.Addresses)
+}
+
+func TestEngine_RetrievalAddrsLimits(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+	lister := func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	}
+	md := metadata.Default.New(metadata.Bitswap{})
+
+	subject, err := engine.New(
+		engine.WithRetrievalAddrs(
+			"/ip4/127.0.0.1/tcp/3104",
+			"/dns4/fish.example/tcp/443/https",
+			"/ip4/172.17.0.2/tcp/3104",
+			"/dns4/fish.example/tcp/443/https",
+			"/ip4/1.2.3.4/tcp/3104",
+			"/ip4/5.6.7.8/tcp/3104"),
+		engine.WithPrivateRetrievalAddrs(false),
+		engine.WithMaxRetrievalAddrs(2))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	subject.RegisterMultihashLister(lister)
+
+	// Duplicate and private addresses are left out, and then those in excess.
+	fishCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.NoError(t, err)
+	fish, err := subject.GetAdv(ctx, fishCid)
+	require.NoError(t, err)
+	require.Equal(t, []string{"/dns4/fish.example/tcp/443/https", "/ip4/1.2.3.4/tcp/3104"}, fish.Addresses)
+
+	private := []multiaddr.Multiaddr{multiaddr.StringCast("/ip4/192.168.1.2/tcp/3104"), multiaddr.StringCast("/dns4/localhost/tcp/3104")}
+	_, err = subject.NotifyPut(ctx, &peer.AddrInfo{ID: subject.ProviderID(), Addrs: private}, []byte("lobster"), md)
+	require.ErrorContains(t, err, "no retrieval address to advertise")
+	_, err = subject.NotifyAddrsChanged(ctx, private)
+	require.ErrorContains(t, err, "no retrieval address to advertise")
+	rmCid, err := subject.NotifyRemove(ctx, "", []byte("fish"))
+	require.NoError(t, err)
+	require.NotEqual(t, cid.Undef, rmCid)
+
+	// Private addresses are advertised by default.
+	subject, err = engine.New(engine.WithRetrievalAddrs("/ip4/127.0.0.1/tcp/3104", "/ip4/127.0.0.1/tcp/3104"))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	subject.RegisterMultihashLister(lister)
+	fishCid, err = subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.NoError(t, err)
+	fish, err = subject.GetAdv(ctx, fishCid)
+	require.NoError(t, err)
+	require.Equal(t, []string{"/ip4/127.0.0.1/tcp/3104"}, fish.Addresses)
+
+	_, err = engine.New(engine.WithMaxRetrievalAddrs(provider.MaxAddrs + 1))
+	require.ErrorContains(t, err, "must be between 0 and 32")
+}
+
+func TestIsPrivateAddr(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"/ip4/127.0.0.1/tcp/3104":          true,
+		"/ip4/0.0.0.0/tcp/3104":            true,
+		"/ip4/10.1.2.3/tcp/3104":           true,
+		"/ip4/172.17.0.2/tcp/3104":         true,
+		"/ip6/::1/tcp/3104":                true,
+		"/ip6/fe80::1/tcp/3104":            true,
+		"/dns4/localhost/tcp/3104":         true,
+		"/ip4/1.2.3.4/tcp/3104":            false,
+		"/dns4/fish.example/tcp/443/https": false,
+	} {
+		require.Equal(t, want, engine.IsPrivateAddr(multiaddr.StringCast(addr)), addr)
+	}
+}
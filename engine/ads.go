@@ -0,0 +1,103 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipni/go-libipni/ingest/schema"
+)
+
+// ErrAdNotFound signals that an advertisement is not found in the datastore of
+// the engine.
+var ErrAdNotFound = errors.New("advertisement not found")
+
+// AdInfo describes an advertisement published by the engine, along with the
+// local presence of its entries.
+type AdInfo struct {
+	// ID is the CID of the advertisement.
+	ID cid.Cid
+	// Advertisement is the decoded advertisement.
+	Advertisement *schema.Advertisement
+	// ChunkCount is the number of entries chunks of the advertisement present
+	// locally, or -1 if the entries are not a chain of chunks, e.g. when
+	// chunked as a HAMT.
+	ChunkCount int
+	// EntriesPresent is whether all the entries of the advertisement are
+	// present locally, such that they are served without regenerating them
+	// from the multihash lister.
+	EntriesPresent bool
+	// Labels are the labels of the advertisement, if any. See:
+	// Engine.SetAdLabels.
+	Labels map[string]string
+}
+
+// GetAdInfo gets information about the advertisement with the given CID.
+// ErrAdNotFound is returned if the engine has no such advertisement.
+//
+// Entries are looked up in the entries cache only, and are never regenerated.
+func (e *Engine) GetAdInfo(ctx context.Context, adCid cid.Cid) (*AdInfo, error) {
+	ad, err := e.loadAd(ctx, adCid)
+	if err != nil {
+		return nil, err
+	}
+	labels, err := e.GetAdLabels(ctx, adCid)
+	if err != nil {
+		return nil, err
+	}
+	info := &AdInfo{
+		ID:            adCid,
+		Advertisement: ad,
+		Labels:        labels,
+	}
+	if ad.Entries == nil || ad.Entries == schema.NoEntries || e.entriesChunker == nil {
+		return info, nil
+	}
+
+	next := ad.Entries
+	for {
+		raw, err := e.entriesChunker.GetRawCachedChunk(ctx, next)
+		if err != nil {
+			return nil, err
+		}
+		if raw == nil {
+			// The rest of the entries are not cached.
+			return info, nil
+		}
+		info.ChunkCount++
+		chunk, err := schema.BytesToEntryChunk(next.(cidlink.Link).Cid, raw)
+		if err != nil {
+			// Not a chain of entries chunks; presence of the root is all that
+			// is known.
+			info.ChunkCount = -1
+			info.EntriesPresent = true
+			return info, nil
+		}
+		if chunk.Next == nil || chunk.Next == schema.NoEntries {
+			info.EntriesPresent = true
+			return info, nil
+		}
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		next = chunk.Next
+	}
+}
+
+// loadAd loads the advertisement with the given CID from the datastore without
+// logging, as done when walking the advertisement chain.
+func (e *Engine) loadAd(ctx context.Context, adCid cid.Cid) (*schema.Advertisement, error) {
+	lsys := e.vanillaLinkSystem()
+	n, err := lsys.Load(ipld.LinkContext{Ctx: ctx}, cidlink.Link{Cid: adCid}, schema.AdvertisementPrototype)
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return nil, ErrAdNotFound
+		}
+		return nil, fmt.Errorf("cannot load advertisement %s: %w", adCid, err)
+	}
+	return schema.UnwrapAdvertisement(n)
+}
@@ -0,0 +1,97 @@
+package engine_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_GetAdInfo(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	subject, err := engine.New(
+		engine.WithPublisherKind(engine.NoPublisher),
+		engine.WithChainedEntries(3),
+		engine.WithEntriesCacheCapacity(1))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(7)), nil
+	})
+
+	_, err = subject.GetAdInfo(ctx, test.RandomCids(1)[0])
+	require.ErrorIs(t, err, engine.ErrAdNotFound)
+
+	md := metadata.Default.New(metadata.Bitswap{})
+	fishCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.NoError(t, err)
+	info, err := subject.GetAdInfo(ctx, fishCid)
+	require.NoError(t, err)
+	require.Equal(t, fishCid, info.ID)
+	require.Equal(t, []byte("fish"), info.Advertisement.ContextID)
+	require.Equal(t, 3, info.ChunkCount)
+	require.True(t, info.EntriesPresent)
+
+	// Evict the entries of the first advertisement from the cache.
+	_, err = subject.NotifyPut(ctx, nil, []byte("lobster"), md)
+	require.NoError(t, err)
+	info, err = subject.GetAdInfo(ctx, fishCid)
+	require.NoError(t, err)
+	require.Zero(t, info.ChunkCount)
+	require.False(t, info.EntriesPresent)
+
+	rmCid, err := subject.NotifyRemove(ctx, "", []byte("lobster"))
+	require.NoError(t, err)
+	info, err = subject.GetAdInfo(ctx, rmCid)
+	require.NoError(t, err)
+	require.True(t, info.Advertisement.IsRm)
+	require.Zero(t, info.ChunkCount)
+}
+
+func TestEngine_AdLabels(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	subject, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+
+	labels := map[string]string{"dataset": "wikipedia"}
+	err = subject.SetAdLabels(ctx, test.RandomCids(1)[0], labels)
+	require.ErrorIs(t, err, engine.ErrAdNotFound)
+
+	fishCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	got, err := subject.GetAdLabels(ctx, fishCid)
+	require.NoError(t, err)
+	require.Nil(t, got)
+
+	require.NoError(t, subject.SetAdLabels(ctx, fishCid, labels))
+	info, err := subject.GetAdInfo(ctx, fishCid)
+	require.NoError(t, err)
+	require.Equal(t, labels, info.Labels)
+	require.True(t, engine.MatchLabels(info.Labels, labels))
+	require.False(t, engine.MatchLabels(info.Labels, map[string]string{"dataset": "gutenberg"}))
+
+	err = subject.SetAdLabels(ctx, fishCid, map[string]string{"a=b": "c"})
+	require.ErrorIs(t, err, engine.ErrInvalidLabels)
+	err = subject.SetAdLabels(ctx, fishCid, map[string]string{"": "c"})
+	require.ErrorIs(t, err, engine.ErrInvalidLabels)
+
+	require.NoError(t, subject.SetAdLabels(ctx, fishCid, nil))
+	got, err = subject.GetAdLabels(ctx, fishCid)
+	require.NoError(t, err)
+	require.Nil(t, got)
+}
@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/ipni/go-libipni/maurl"
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// ParseAnnounceAddr parses an address to put into announce messages. The
+// address is either a multiaddr, such as "/dns4/example.com/tcp/443/https",
+// or an http or https URL, such as "https://example.com", which is converted
+// to the equivalent multiaddr.
+//
+// The default port of the scheme is added to HTTP multiaddrs that do not
+// specify a TCP port, so "/dns/example.com/https" is announced as
+// "/dns/example.com/tcp/443/https", which indexers are able to fetch
+// advertisements from.
+func ParseAnnounceAddr(addr string) (multiaddr.Multiaddr, error) {
+	var maddr multiaddr.Multiaddr
+	if strings.HasPrefix(addr, "http://") || strings.HasPrefix(addr, "https://") {
+		u, err := url.Parse(addr)
+		if err != nil {
+			return nil, err
+		}
+		if u.Hostname() == "" {
+			return nil, fmt.Errorf("announce URL %q has no host", addr)
+		}
+		if u.Path == "/" {
+			u.Path = ""
+		}
+		maddr, err = maurl.FromURL(u)
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert announce URL %q to multiaddr: %w", addr, err)
+		}
+	} else {
+		var err error
+		maddr, err = multiaddr.NewMultiaddr(addr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !IsHttpAddr(maddr) {
+		return maddr, nil
+	}
+	maddr = withDefaultHttpPort(maddr)
+	if _, _, err := manet.DialArgs(maddr); err != nil {
+		return nil, fmt.Errorf("announce address %s is not a valid HTTP address: %w", maddr, err)
+	}
+	return maddr, nil
+}
+
+// IsHttpAddr returns true if the multiaddr is an address that advertisements
+// are fetched from using HTTP, i.e. it has an http or https component.
+func IsHttpAddr(maddr multiaddr.Multiaddr) bool {
+	for _, p := range maddr.Protocols() {
+		if p.Code == multiaddr.P_HTTP || p.Code == multiaddr.P_HTTPS {
+			return true
+		}
+	}
+	return false
+}
+
+// withDefaultHttpPort inserts the default TCP port of the HTTP scheme after
+// the host component of a multiaddr that has no TCP port.
+func withDefaultHttpPort(maddr multiaddr.Multiaddr) multiaddr.Multiaddr {
+	var hasTCP, secure bool
+	for _, p := range maddr.Protocols() {
+		switch p.Code {
+		case multiaddr.P_TCP:
+			hasTCP = true
+		case multiaddr.P_HTTPS, multiaddr.P_TLS:
+			secure = true
+		}
+	}
+	if hasTCP {
+		return maddr
+	}
+	port := "/tcp/80"
+	if secure {
+		port = "/tcp/443"
+	}
+	host, rest := multiaddr.SplitFirst(maddr)
+	if host == nil {
+		return maddr
+	}
+	switch host.Protocol().Code {
+	case multiaddr.P_IP4, multiaddr.P_IP6, multiaddr.P_DNS, multiaddr.P_DNS4, multiaddr.P_DNS6:
+	default:
+		return maddr
+	}
+	out := multiaddr.Join(host, multiaddr.StringCast(port))
+	if rest != nil {
+		out = out.Encapsulate(rest)
+	}
+	return out
+}
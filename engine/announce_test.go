@@ -0,0 +1,304 @@
+package engine_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/go-libipni/announce/message"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_SetDirectAnnounce(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	startIndexer := func(announces *atomic.Int32) *httptest.Server {
+		indexer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			announces.Add(1)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		t.Cleanup(indexer.Close)
+		return indexer
+	}
+	var oldAnnounces, newAnnounces atomic.Int32
+	oldIndexer := startIndexer(&oldAnnounces)
+	newIndexer := startIndexer(&newAnnounces)
+
+	subject, err := engine.New(
+		engine.WithPublisherKind(engine.HttpPublisher),
+		engine.WithHttpPublisherWithoutServer(),
+		engine.WithHttpPublisherAnnounceAddr("/ip4/127.0.0.1/tcp/3104/http"),
+		engine.WithPubsubAnnounce(false),
+		engine.WithDirectAnnounce(oldIndexer.URL))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	t.Cleanup(func() { subject.Shutdown() })
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+
+	_, err = subject.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	require.Equal(t, int32(1), oldAnnounces.Load())
+
+	require.NoError(t, subject.SetDirectAnnounce(newIndexer.URL))
+	_, err = subject.PublishLatest(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), oldAnnounces.Load())
+	require.Equal(t, int32(1), newAnnounces.Load())
+
+	require.NoError(t, subject.SetDirectAnnounce())
+	_, err = subject.PublishLatest(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), newAnnounces.Load())
+
+	require.Error(t, subject.SetDirectAnnounce("://fish"))
+}
+
+// recordingSender records the CIDs of the advertisements it announces.
+type recordingSender struct {
+	lock   sync.Mutex
+	sent   []cid.Cid
+	closed bool
+}
+
+func (s *recordingSender) Send(_ context.Context, msg message.Message) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.sent = append(s.sent, msg.Cid)
+	return nil
+}
+
+func (s *recordingSender) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.closed = true
+	return nil
+}
+
+func (s *recordingSender) String() string { return "queue:fish" }
+
+func TestEngine_WithAnnounceAuthTokens(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	startIndexer := func(auth chan<- string) *httptest.Server {
+		indexer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth <- r.Header.Get("Authorization")
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		t.Cleanup(indexer.Close)
+		return indexer
+	}
+	privateAuth := make(chan string, 1)
+	publicAuth := make(chan string, 1)
+	private := startIndexer(privateAuth)
+	public := startIndexer(publicAuth)
+
+	subject, err := engine.New(
+		engine.WithPublisherKind(engine.HttpPublisher),
+		engine.WithHttpPublisherWithoutServer(),
+		engine.WithHttpPublisherAnnounceAddr("/ip4/127.0.0.1/tcp/3104/http"),
+		engine.WithPubsubAnnounce(false),
+		engine.WithDirectAnnounce(private.URL+"/ingest/announce", public.URL),
+		engine.WithAnnounceAuthTokens(map[string]string{private.URL: "fish-token"}))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	t.Cleanup(func() { subject.Shutdown() })
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+
+	// Only the indexer with a token is sent it.
+	_, err = subject.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	require.Equal(t, "Bearer fish-token", <-privateAuth)
+	require.Empty(t, <-publicAuth)
+
+	_, err = engine.New(engine.WithAnnounceAuthTokens(map[string]string{"indexer.example.com": "fish-token"}))
+	require.ErrorContains(t, err, "must have a scheme and host")
+}
+
+func TestEngine_WithAnnounceSender(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	var announces atomic.Int32
+	indexer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		announces.Add(1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(indexer.Close)
+
+	sender := &recordingSender{}
+	subject, err := engine.New(
+		engine.WithPublisherKind(engine.HttpPublisher),
+		engine.WithHttpPublisherWithoutServer(),
+		engine.WithHttpPublisherAnnounceAddr("/ip4/127.0.0.1/tcp/3104/http"),
+		engine.WithPubsubAnnounce(false),
+		engine.WithDirectAnnounce(indexer.URL),
+		engine.WithAnnounceSender(sender))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+	events, cancelEvents := subject.SubscribePublishEvents()
+	defer cancelEvents()
+
+	adCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	require.Equal(t, int32(1), announces.Load())
+	require.Equal(t, []cid.Cid{adCid}, sender.sent)
+	for event := range events {
+		if event.Kind == engine.AdAnnounced {
+			require.NoError(t, event.Err)
+			break
+		}
+	}
+
+	// The sender is kept when the direct announce URLs change.
+	require.NoError(t, subject.SetDirectAnnounce())
+	_, err = subject.PublishLatest(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), announces.Load())
+	require.Equal(t, []cid.Cid{adCid, adCid}, sender.sent)
+
+	require.NoError(t, subject.Shutdown())
+	require.True(t, sender.closed)
+
+	_, err = engine.New(engine.WithAnnounceSender(nil))
+	require.ErrorContains(t, err, "must not be nil")
+}
+
+func TestEngine_WithMessageBusAnnounce(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	messages := make(chan string, 1)
+	sns := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		messages <- r.FormValue("Message")
+	}))
+	t.Cleanup(sns.Close)
+	t.Setenv("AWS_ACCESS_KEY_ID", "fish")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "lobster")
+	t.Setenv("AWS_ENDPOINT_URL", sns.URL)
+
+	subject, err := engine.New(
+		engine.WithPublisherKind(engine.HttpPublisher),
+		engine.WithHttpPublisherWithoutServer(),
+		engine.WithHttpPublisherAnnounceAddr("/ip4/127.0.0.1/tcp/3104/http"),
+		engine.WithPubsubAnnounce(false),
+		engine.WithMessageBusAnnounce("arn:aws:sns:us-east-1:123456789012:announce"))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+
+	adCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	var msg message.Message
+	require.NoError(t, json.Unmarshal([]byte(<-messages), &msg))
+	require.Equal(t, adCid, msg.Cid)
+	addrs, err := msg.GetAddrs()
+	require.NoError(t, err)
+	require.Equal(t, "/ip4/127.0.0.1/tcp/3104/http", addrs[0].String())
+
+	_, err = engine.New(engine.WithMessageBusAnnounce("kafka://localhost/announce"))
+	require.ErrorContains(t, err, "unsupported message bus URL")
+}
+
+func TestParseAnnounceAddr(t *testing.T) {
+	for addr, want := range map[string]string{
+		"/dns4/example.com/tcp/443/https":     "/dns4/example.com/tcp/443/https",
+		"/dns/example.com/https":              "/dns/example.com/tcp/443/https",
+		"/dns6/example.com/tls/http":          "/dns6/example.com/tcp/443/tls/http",
+		"/ip4/192.0.2.1/http":                 "/ip4/192.0.2.1/tcp/80/http",
+		"https://example.com":                 "/dns/example.com/tcp/443/https",
+		"https://example.com/":                "/dns/example.com/tcp/443/https",
+		"http://example.com:8080":             "/dns/example.com/tcp/8080/http",
+		"http://192.0.2.1:3104":               "/ip4/192.0.2.1/tcp/3104/http",
+		"/ip4/192.0.2.1/tcp/3103":             "/ip4/192.0.2.1/tcp/3103",
+		"/dnsaddr/example.com":                "/dnsaddr/example.com",
+		"/ip4/192.0.2.1/udp/1234/quic-v1":     "/ip4/192.0.2.1/udp/1234/quic-v1",
+		"/dns4/example.com/tcp/3104/http/p2p": "",
+		"https://":                            "",
+		"fish":                                "",
+	} {
+		maddr, err := engine.ParseAnnounceAddr(addr)
+		if want == "" {
+			require.Error(t, err, addr)
+			continue
+		}
+		require.NoError(t, err, addr)
+		require.Equal(t, want, maddr.String(), addr)
+	}
+}
+
+func TestEngine_PublishLatestHTTPWithDomainAnnounceAddr(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	msgs := make(chan message.Message, 1)
+	indexer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg message.Message
+		if err := msg.UnmarshalCBOR(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		msgs <- msg
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(indexer.Close)
+	indexerURL, err := url.Parse(indexer.URL)
+	require.NoError(t, err)
+
+	providerID, key, _ := test.RandomIdentity()
+	// An HTTP publisher cannot announce a non-HTTP address.
+	notHttp, err := engine.New(
+		engine.WithPrivateKey(key),
+		engine.WithPublisherKind(engine.HttpPublisher),
+		engine.WithHttpPublisherWithoutServer(),
+		engine.WithHttpPublisherAnnounceAddr("/ip4/192.0.2.1/tcp/3103"),
+		engine.WithPubsubAnnounce(false))
+	require.NoError(t, err)
+	require.ErrorContains(t, notHttp.Start(ctx), "not an HTTP address")
+
+	subject, err := engine.New(
+		engine.WithPrivateKey(key),
+		engine.WithPublisherKind(engine.HttpPublisher),
+		engine.WithHttpPublisherWithoutServer(),
+		engine.WithHttpPublisherAnnounceAddr("https://example.com"),
+		engine.WithPubsubAnnounce(false))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	t.Cleanup(func() { subject.Shutdown() })
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+
+	adCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	_, err = subject.PublishLatestHTTP(ctx, indexerURL)
+	require.NoError(t, err)
+	msg := <-msgs
+	require.Equal(t, adCid, msg.Cid)
+	addrs, err := msg.GetAddrs()
+	require.NoError(t, err)
+	require.Len(t, addrs, 1)
+	require.Equal(t, "/dns/example.com/tcp/443/https/p2p/"+providerID.String(), addrs[0].String())
+}
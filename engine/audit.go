@@ -0,0 +1,199 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/ipni/go-libipni/announce"
+	"github.com/ipni/go-libipni/announce/httpsender"
+	"github.com/ipni/go-libipni/announce/p2psender"
+	"github.com/ipni/go-libipni/ingest/schema"
+)
+
+const auditLogPrefix = "audit/"
+
+// AuditRecord records an advertisement published by the engine, along with the
+// outcome of its announcement. See: WithAuditLog.
+type AuditRecord struct {
+	// Seq is the sequence number of the record. Records are numbered from 1 in
+	// the order in which advertisements are published.
+	Seq uint64
+	// Time is the time at which the advertisement was published.
+	Time time.Time
+	// AdCid is the CID of the advertisement.
+	AdCid cid.Cid
+	// Provider is the ID of the provider of the advertised content.
+	Provider string
+	// ContextID is the context ID of the advertisement.
+	ContextID []byte
+	// IsRm is whether the advertisement is a removal advertisement.
+	IsRm bool
+	// IsUpdate is whether the advertisement only updates the metadata of
+	// content already advertised. See: Engine.UpdateMetadata.
+	IsUpdate bool `json:",omitempty"`
+	// MultihashCount is the number of multihashes advertised, or -1 if unknown,
+	// such as for removal advertisements.
+	MultihashCount int
+	// SkippedCount is the number of multihashes that the multihash lister
+	// failed to list, and that are therefore not advertised. See:
+	// provider.SkipMultihash. For split content, the skipped multihashes are
+	// reported by the record of the advertisement of its last part.
+	SkippedCount int `json:",omitempty"`
+	// Protocols are the names of the retrieval protocols in the metadata of
+	// the advertisement.
+	Protocols []string
+	// Announces are the outcomes of announcing the advertisement, one per
+	// announce sender. There are none if the advertisement was only published
+	// locally.
+	Announces []AnnounceResult
+}
+
+// AnnounceResult is the outcome of sending the announcement of an
+// advertisement via one announce sender.
+type AnnounceResult struct {
+	// Targets are where the announcement was sent to: the URLs of the
+	// indexers that direct HTTP announce messages are sent to, or the gossip
+	// pubsub topic prefixed with "pubsub:".
+	Targets []string
+	// Err is the error with which sending the announcement failed, if any.
+	Err string `json:",omitempty"`
+}
+
+// ListAuditRecords lists up to limit records of the audit log, oldest first,
+// starting after the record with the given sequence number. Use 0 to list
+// from the first record. All remaining records are listed if limit is not
+// positive. No records are listed unless the audit log is enabled via
+// WithAuditLog.
+func (e *Engine) ListAuditRecords(ctx context.Context, after uint64, limit int) ([]AuditRecord, error) {
+	results, err := e.ds.Query(ctx, query.Query{
+		Prefix: auditLogPrefix,
+		Orders: []query.Order{query.OrderByKey{}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	start := auditKey(after + 1).String()
+	var records []AuditRecord
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		if r.Key < start {
+			continue
+		}
+		var record AuditRecord
+		if err = json.Unmarshal(r.Value, &record); err != nil {
+			return nil, fmt.Errorf("cannot decode audit record %s: %w", r.Key, err)
+		}
+		records = append(records, record)
+		if len(records) == limit {
+			break
+		}
+	}
+	return records, nil
+}
+
+// audit appends a record of the published advertisement to the audit log, if
+// enabled. Failing to do so is logged but does not fail the publishing of the
+// advertisement, which has already happened.
+func (e *Engine) audit(ctx context.Context, adCid cid.Cid, adv *schema.Advertisement, mhCount, skipped int, announces []AnnounceResult) {
+	if !e.auditLog {
+		return
+	}
+	record := AuditRecord{
+		Time:           time.Now().UTC(),
+		AdCid:          adCid,
+		Provider:       adv.Provider,
+		ContextID:      adv.ContextID,
+		IsRm:           adv.IsRm,
+		IsUpdate:       isMetadataUpdate(ctx),
+		MultihashCount: mhCount,
+		SkippedCount:   skipped,
+		Announces:      announces,
+	}
+	if !adv.IsRm {
+		md := e.metadataContext.New()
+		if err := md.UnmarshalBinary(adv.Metadata); err == nil {
+			for _, p := range md.Protocols() {
+				if p != AdSequenceProtocolID {
+					record.Protocols = append(record.Protocols, p.String())
+				}
+			}
+		}
+	}
+
+	e.auditLock.Lock()
+	defer e.auditLock.Unlock()
+	record.Seq = e.auditSeq + 1
+	data, err := json.Marshal(&record)
+	if err == nil {
+		err = e.ds.Put(ctx, auditKey(record.Seq), data)
+	}
+	if err != nil {
+		log.Errorw("Failed to write audit record", "adCid", adCid, "err", err)
+		return
+	}
+	e.auditSeq = record.Seq
+}
+
+// loadAuditSeq loads the sequence number of the last record of the audit log.
+func (e *Engine) loadAuditSeq(ctx context.Context) error {
+	results, err := e.ds.Query(ctx, query.Query{
+		Prefix:   auditLogPrefix,
+		KeysOnly: true,
+		Orders:   []query.Order{query.OrderByKeyDescending{}},
+		Limit:    1,
+	})
+	if err != nil {
+		return err
+	}
+	defer results.Close()
+	r, ok := results.NextSync()
+	if !ok {
+		return nil
+	}
+	if r.Error != nil {
+		return r.Error
+	}
+	seq, err := strconv.ParseUint(strings.TrimPrefix(r.Key, "/"+auditLogPrefix), 10, 64)
+	if err != nil {
+		return fmt.Errorf("bad audit record key %s: %w", r.Key, err)
+	}
+	e.auditSeq = seq
+	return nil
+}
+
+// auditKey returns the datastore key of the audit record with the given
+// sequence number, which is zero-padded such that keys sort in sequence.
+func auditKey(seq uint64) datastore.Key {
+	return datastore.NewKey(fmt.Sprintf("%s%020d", auditLogPrefix, seq))
+}
+
+// announceTargets describes where the given sender sends announcements to.
+// Senders added via WithAnnounceSender are described by their String method,
+// if any, or otherwise by their type.
+func announceTargets(sender announce.Sender, announceURLs []*url.URL) []string {
+	switch s := sender.(type) {
+	case *httpsender.Sender:
+		targets := make([]string, 0, len(announceURLs))
+		for _, u := range announceURLs {
+			targets = append(targets, u.String())
+		}
+		return targets
+	case *p2psender.Sender:
+		return []string{"pubsub:" + s.TopicName()}
+	case fmt.Stringer:
+		return []string{s.String()}
+	}
+	return []string{fmt.Sprintf("%T", sender)}
+}
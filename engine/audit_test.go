@@ -0,0 +1,165 @@
+package engine_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_AuditLog(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	indexer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(indexer.Close)
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(down.Close)
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	newEngine := func() *engine.Engine {
+		subject, err := engine.New(
+			engine.WithDatastore(ds),
+			engine.WithAuditLog(true),
+			engine.WithPublisherKind(engine.HttpPublisher),
+			engine.WithHttpPublisherWithoutServer(),
+			engine.WithHttpPublisherAnnounceAddr("/ip4/127.0.0.1/tcp/3104/http"),
+			engine.WithPubsubAnnounce(false),
+			engine.WithDirectAnnounce(indexer.URL))
+		require.NoError(t, err)
+		require.NoError(t, subject.Start(ctx))
+		subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+			return provider.SliceMultihashIterator(test.RandomMultihashes(7)), nil
+		})
+		return subject
+	}
+	subject := newEngine()
+
+	fishCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	require.NoError(t, subject.SetDirectAnnounce(down.URL))
+	rmCid, err := subject.NotifyRemove(ctx, "", []byte("fish"))
+	require.NoError(t, err)
+
+	records, err := subject.ListAuditRecords(ctx, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	fish := records[0]
+	require.Equal(t, uint64(1), fish.Seq)
+	require.Equal(t, fishCid, fish.AdCid)
+	require.Equal(t, []byte("fish"), fish.ContextID)
+	require.Equal(t, subject.ProviderID().String(), fish.Provider)
+	require.False(t, fish.IsRm)
+	require.Equal(t, 7, fish.MultihashCount)
+	require.Equal(t, []string{"transport-bitswap"}, fish.Protocols)
+	require.Equal(t, []engine.AnnounceResult{{Targets: []string{indexer.URL + "/announce"}}}, fish.Announces)
+
+	rm := records[1]
+	require.Equal(t, uint64(2), rm.Seq)
+	require.Equal(t, rmCid, rm.AdCid)
+	require.True(t, rm.IsRm)
+	require.Equal(t, -1, rm.MultihashCount)
+	require.Empty(t, rm.Protocols)
+	require.Len(t, rm.Announces, 1)
+	require.Equal(t, []string{down.URL + "/announce"}, rm.Announces[0].Targets)
+	require.NotEmpty(t, rm.Announces[0].Err)
+
+	// Records are numbered on from the last one once the engine restarts.
+	require.NoError(t, subject.Shutdown())
+	subject = newEngine()
+	t.Cleanup(func() { subject.Shutdown() })
+	_, err = subject.NotifyPut(ctx, nil, []byte("lobster"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+
+	records, err = subject.ListAuditRecords(ctx, 1, 1)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, uint64(2), records[0].Seq)
+	records, err = subject.ListAuditRecords(ctx, 2, 10)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, uint64(3), records[0].Seq)
+	require.Equal(t, []byte("lobster"), records[0].ContextID)
+}
+
+func TestEngine_SkipsMultihashesReportedByLister(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	subject, err := engine.New(engine.WithAuditLog(true), engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	t.Cleanup(func() { subject.Shutdown() })
+	mhs := test.RandomMultihashes(5)
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return &skippingIterator{mhs: mhs, skip: map[int]bool{1: true, 3: true}}, nil
+	})
+	events, unsubscribe := subject.SubscribePublishEvents()
+	t.Cleanup(unsubscribe)
+
+	adCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+
+	// The skipped multihashes are left out of the entries.
+	ad, err := subject.GetAdv(ctx, adCid)
+	require.NoError(t, err)
+	chunk := requireLoadEntryChunkFromEngine(t, subject, ad.Entries)[0]
+	require.Equal(t, []multihash.Multihash{mhs[0], mhs[2], mhs[4]}, chunk.Entries)
+
+	event := <-events
+	require.Equal(t, engine.AdPublished, event.Kind)
+	require.Equal(t, adCid, event.AdCid)
+	require.Equal(t, 2, event.Skipped)
+	records, err := subject.ListAuditRecords(ctx, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, 3, records[0].MultihashCount)
+	require.Equal(t, 2, records[0].SkippedCount)
+
+	// Other errors still fail the advertisement.
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return &skippingIterator{mhs: mhs, fail: 2}, nil
+	})
+	_, err = subject.NotifyPut(ctx, nil, []byte("lobster"), metadata.Default.New(metadata.Bitswap{}))
+	require.ErrorContains(t, err, "lister failed")
+}
+
+// skippingIterator iterates over multihashes, reporting those at the indices
+// in skip as skipped, and failing at the index fail if positive.
+type skippingIterator struct {
+	mhs  []multihash.Multihash
+	skip map[int]bool
+	fail int
+	next int
+}
+
+func (i *skippingIterator) Next() (multihash.Multihash, error) {
+	if i.next == len(i.mhs) {
+		return nil, io.EOF
+	}
+	n := i.next
+	i.next++
+	switch {
+	case i.skip[n]:
+		return nil, provider.SkipMultihash(errors.New("block is unreadable"))
+	case i.fail > 0 && n == i.fail:
+		return nil, errors.New("lister failed")
+	}
+	return i.mhs[n], nil
+}
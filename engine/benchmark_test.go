@@ -0,0 +1,83 @@
+package engine_test
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	leveldb "github.com/ipfs/go-ds-leveldb"
+	"github.com/ipni/go-libipni/metadata"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+// BenchmarkEngine_NotifyPut measures the throughput of publishing
+// advertisements of new content, from listing its multihashes to storing the
+// advertisement, with the datastore backends that the provider supports.
+func BenchmarkEngine_NotifyPut(b *testing.B) {
+	backends := []struct {
+		name string
+		new  func(b *testing.B) datastore.Batching
+	}{
+		{"map", func(*testing.B) datastore.Batching {
+			return dssync.MutexWrap(datastore.NewMapDatastore())
+		}},
+		{"leveldb", func(b *testing.B) datastore.Batching {
+			ds, err := leveldb.NewDatastore(b.TempDir(), nil)
+			require.NoError(b, err)
+			b.Cleanup(func() { ds.Close() })
+			return ds
+		}},
+	}
+	for _, backend := range backends {
+		for _, mhCount := range []int{1_000, 100_000} {
+			b.Run(fmt.Sprintf("%s/%d", backend.name, mhCount), func(b *testing.B) {
+				subject, err := engine.New(engine.WithDatastore(backend.new(b)), engine.WithPublisherKind(engine.NoPublisher))
+				require.NoError(b, err)
+				require.NoError(b, subject.Start(context.Background()))
+				b.Cleanup(func() { subject.Shutdown() })
+				subject.RegisterMultihashLister(func(_ context.Context, _ peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+					return &generatedMultihashIterator{seed: binary.BigEndian.Uint64(contextID), count: mhCount}, nil
+				})
+				md := metadata.Default.New(metadata.Bitswap{})
+				contextID := make([]byte, 8)
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					binary.BigEndian.PutUint64(contextID, uint64(i))
+					_, err := subject.NotifyPut(context.Background(), nil, contextID, md)
+					require.NoError(b, err)
+				}
+				b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "ads/s")
+				b.ReportMetric(float64(b.N*mhCount)/b.Elapsed().Seconds(), "multihashes/s")
+			})
+		}
+	}
+}
+
+// generatedMultihashIterator iterates over distinct multihashes, derived from
+// the seed, that are generated as they are iterated over.
+type generatedMultihashIterator struct {
+	seed   uint64
+	count  int
+	next   int
+	digest [32]byte
+}
+
+func (i *generatedMultihashIterator) Next() (multihash.Multihash, error) {
+	if i.next == i.count {
+		return nil, io.EOF
+	}
+	binary.BigEndian.PutUint64(i.digest[:], i.seed)
+	binary.BigEndian.PutUint64(i.digest[8:], uint64(i.next))
+	i.next++
+	return multihash.Encode(i.digest[:], multihash.SHA2_256)
+}
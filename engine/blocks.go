@@ -0,0 +1,41 @@
+package engine
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+)
+
+// ErrBlockNotFound signals that a block is not stored locally by the engine.
+var ErrBlockNotFound = errors.New("block not found")
+
+// GetLocalBlock gets the raw data of the block with the given CID, which is
+// either an advertisement or an entries chunk. Unlike the link system of the
+// engine, only the blocks stored locally are returned, i.e. entries are not
+// regenerated from the multihash lister if they are not cached. ErrBlockNotFound
+// is returned if there is no such block.
+func (e *Engine) GetLocalBlock(ctx context.Context, c cid.Cid) ([]byte, error) {
+	if data, ok := e.memCache.get(c); ok {
+		return data, nil
+	}
+	data, err := e.ds.Get(ctx, datastore.NewKey(c.String()))
+	if err == nil {
+		return data, nil
+	}
+	if !errors.Is(err, datastore.ErrNotFound) {
+		return nil, err
+	}
+	if e.entriesChunker != nil {
+		data, err = e.entriesChunker.GetRawCachedChunk(ctx, cidlink.Link{Cid: c})
+		if err != nil {
+			return nil, err
+		}
+		if data != nil {
+			return data, nil
+		}
+	}
+	return nil, ErrBlockNotFound
+}
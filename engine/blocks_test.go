@@ -0,0 +1,55 @@
+package engine_test
+
+import (
+	"context"
+	"testing"
+
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_GetLocalBlock(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	subject, err := engine.New(
+		engine.WithPublisherKind(engine.NoPublisher),
+		engine.WithEntriesCacheCapacity(1))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	var listed int
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		listed++
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+
+	md := metadata.Default.New(metadata.Bitswap{})
+	fishCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.NoError(t, err)
+	fish, err := subject.GetAdv(ctx, fishCid)
+	require.NoError(t, err)
+	lobsterCid, err := subject.NotifyPut(ctx, nil, []byte("lobster"), md)
+	require.NoError(t, err)
+	lobster, err := subject.GetAdv(ctx, lobsterCid)
+	require.NoError(t, err)
+
+	data, err := subject.GetLocalBlock(ctx, fishCid)
+	require.NoError(t, err)
+	require.NotEmpty(t, data)
+	data, err = subject.GetLocalBlock(ctx, lobster.Entries.(cidlink.Link).Cid)
+	require.NoError(t, err)
+	require.NotEmpty(t, data)
+
+	// Entries evicted from the cache are not regenerated.
+	_, err = subject.GetLocalBlock(ctx, fish.Entries.(cidlink.Link).Cid)
+	require.ErrorIs(t, err, engine.ErrBlockNotFound)
+	require.Equal(t, 2, listed)
+	_, err = subject.GetLocalBlock(ctx, test.RandomCids(1)[0])
+	require.ErrorIs(t, err, engine.ErrBlockNotFound)
+}
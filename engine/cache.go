@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ipfs/go-datastore"
+)
+
+var errNotStarted = errors.New("engine is not started")
+
+// PurgeCache deletes all the cached advertisement entries, including those
+// cached in memory, without requiring a restart with WithPurgeCacheOnStart.
+// Entries are regenerated from the registered provider.MultihashLister when
+// next requested. The number of bytes reclaimed is returned.
+func (e *Engine) PurgeCache(ctx context.Context) (int64, error) {
+	if e.entriesChunker == nil {
+		return 0, errNotStarted
+	}
+	e.memCache.clear()
+	reclaimed, err := e.entriesChunker.Purge(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge entries cache: %w", err)
+	}
+	return reclaimed, nil
+}
+
+// CollectGarbage deletes the items of the entries cache that are not part of
+// any cached entries DAG, then runs the garbage collection of the engine
+// datastore if it implements datastore.GCFeature. The number of bytes
+// reclaimed is returned, including the reduction in disk usage from the
+// datastore garbage collection if the datastore implements
+// datastore.PersistentFeature.
+func (e *Engine) CollectGarbage(ctx context.Context) (int64, error) {
+	if e.entriesChunker == nil {
+		return 0, errNotStarted
+	}
+	reclaimed, err := e.entriesChunker.GC(ctx)
+	if err != nil {
+		return reclaimed, fmt.Errorf("failed to garbage collect entries cache: %w", err)
+	}
+
+	gcds, ok := e.ds.(datastore.GCFeature)
+	if !ok {
+		return reclaimed, nil
+	}
+	before, err := datastore.DiskUsage(ctx, e.ds)
+	if err != nil {
+		return reclaimed, fmt.Errorf("failed to get datastore disk usage: %w", err)
+	}
+	if err = gcds.CollectGarbage(ctx); err != nil {
+		return reclaimed, fmt.Errorf("failed to garbage collect datastore: %w", err)
+	}
+	after, err := datastore.DiskUsage(ctx, e.ds)
+	if err != nil {
+		return reclaimed, fmt.Errorf("failed to get datastore disk usage: %w", err)
+	}
+	if after < before {
+		reclaimed += int64(before - after)
+	}
+	return reclaimed, nil
+}
@@ -0,0 +1,93 @@
+package engine_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipni/go-libipni/ingest/schema"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+type gcDatastore struct {
+	datastore.Batching
+	collected int
+}
+
+func (ds *gcDatastore) CollectGarbage(context.Context) error {
+	ds.collected++
+	return nil
+}
+
+func TestEngine_PurgeCacheAndCollectGarbage(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	ds := &gcDatastore{Batching: dssync.MutexWrap(datastore.NewMapDatastore())}
+	subject, err := engine.New(engine.WithDatastore(ds))
+	require.NoError(t, err)
+	_, err = subject.PurgeCache(ctx)
+	require.ErrorContains(t, err, "not started")
+
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+	_, err = subject.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+
+	reclaimed, err := subject.CollectGarbage(ctx)
+	require.NoError(t, err)
+	require.Zero(t, reclaimed)
+	require.Equal(t, 1, ds.collected)
+
+	stats, err := subject.Stats(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, stats.CachedChunks)
+	reclaimed, err = subject.PurgeCache(ctx)
+	require.NoError(t, err)
+	require.Positive(t, reclaimed)
+	stats, err = subject.Stats(ctx)
+	require.NoError(t, err)
+	require.Zero(t, stats.CachedChunks)
+}
+
+func TestEngine_EntriesMemoryCache(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	subject, err := engine.New(engine.WithEntriesMemoryCacheSize(1 << 20))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+	adCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	ad, err := subject.GetAdv(ctx, adCid)
+	require.NoError(t, err)
+	want := requireLoadEntryChunkFromEngine(t, subject, ad.Entries)
+
+	// Once served, the chunk is served from memory even though it is no longer
+	// in the entries cache, and the lister would regenerate other entries.
+	entries := ad.Entries.(cidlink.Link).Cid.String()
+	require.NoError(t, subject.Datastore().Delete(ctx, datastore.NewKey(entries)))
+	require.NoError(t, subject.Datastore().Delete(ctx, datastore.NewKey("/cache/links/"+entries)))
+	require.Equal(t, want, requireLoadEntryChunkFromEngine(t, subject, ad.Entries))
+
+	// Purging the cache purges the chunks cached in memory too.
+	_, err = subject.PurgeCache(ctx)
+	require.NoError(t, err)
+	_, err = subject.LinkSystem().Load(ipld.LinkContext{Ctx: ctx}, ad.Entries, schema.EntryChunkPrototype)
+	require.Error(t, err)
+}
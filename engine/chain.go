@@ -0,0 +1,386 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	carv2 "github.com/ipld/go-car/v2"
+	carstorage "github.com/ipld/go-car/v2/storage"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/ipld/go-ipld-prime/traversal"
+	"github.com/ipld/go-ipld-prime/traversal/selector"
+	selectorbuilder "github.com/ipld/go-ipld-prime/traversal/selector/builder"
+	"github.com/ipni/go-libipni/ingest/schema"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+var (
+	// ErrNoAdvertisements signals that there are no advertisements to export.
+	ErrNoAdvertisements = errors.New("no advertisements published")
+	// ErrChainDiverged signals that an imported advertisement chain does not
+	// contain the latest advertisement published by the engine.
+	ErrChainDiverged = errors.New("imported chain does not contain the latest advertisement")
+
+	// exploreAllRecursively explores all the nodes reachable from a root.
+	exploreAllRecursively ipld.Node
+)
+
+func init() {
+	ssb := selectorbuilder.NewSelectorSpecBuilder(basicnode.Prototype.Any)
+	exploreAllRecursively = ssb.ExploreRecursive(selector.RecursionLimitNone(),
+		ssb.ExploreAll(ssb.ExploreRecursiveEdge())).Node()
+}
+
+// ExportChain writes the chain of advertisements published by the engine to
+// the given writer in CARv1 format, with the latest advertisement as the CAR
+// root. Advertisements are written in order from latest to earliest. If
+// withEntries is set, the entries of each advertisement are written right
+// after it, in which case the engine must have been started and the entries
+// must be available from the registered provider.MultihashLister. The chain is
+// written down to the pruned horizon; see Engine.Prune.
+//
+// The CID of the latest advertisement is returned. ErrNoAdvertisements is
+// returned if no advertisements have been published.
+//
+// See: Engine.ImportChain.
+func (e *Engine) ExportChain(ctx context.Context, w io.Writer, withEntries bool) (cid.Cid, error) {
+	head, err := e.getLatestAdCid(ctx)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("could not get latest advertisement: %w", err)
+	}
+	if head == cid.Undef {
+		return cid.Undef, ErrNoAdvertisements
+	}
+	horizon, err := e.getPrunedHorizon(ctx)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	out, err := carstorage.NewWritable(w, []cid.Cid{head}, carv2.WriteAsCarV1(true))
+	if err != nil {
+		return cid.Undef, err
+	}
+	put := func(c cid.Cid, data []byte) error {
+		has, err := out.Has(ctx, c.KeyString())
+		if err != nil || has {
+			return err
+		}
+		return out.Put(ctx, c.KeyString(), data)
+	}
+
+	entriesLsys := e.copyingLinkSystem(put)
+	lsys := e.vanillaLinkSystem()
+	for c := head; c != cid.Undef && c != horizon; {
+		if err = ctx.Err(); err != nil {
+			return cid.Undef, err
+		}
+		data, err := e.ds.Get(ctx, datastore.NewKey(c.String()))
+		if err != nil {
+			return cid.Undef, fmt.Errorf("cannot get advertisement %s: %w", c, err)
+		}
+		if err = put(c, data); err != nil {
+			return cid.Undef, err
+		}
+		n, err := lsys.Load(ipld.LinkContext{Ctx: ctx}, cidlink.Link{Cid: c}, schema.AdvertisementPrototype)
+		if err != nil {
+			return cid.Undef, fmt.Errorf("cannot load advertisement %s: %w", c, err)
+		}
+		ad, err := schema.UnwrapAdvertisement(n)
+		if err != nil {
+			return cid.Undef, fmt.Errorf("invalid advertisement %s: %w", c, err)
+		}
+		if withEntries && ad.Entries != nil && ad.Entries != schema.NoEntries {
+			if err = walkAll(ctx, entriesLsys, ad.Entries); err != nil {
+				return cid.Undef, fmt.Errorf("cannot export entries of advertisement %s: %w", c, err)
+			}
+		}
+		c = cid.Undef
+		if ad.PreviousID != nil {
+			c = ad.PreviousID.(cidlink.Link).Cid
+		}
+	}
+	return head, nil
+}
+
+// ImportChain reads a chain of advertisements in CAR format from the given
+// reader, such as one written by Engine.ExportChain, and sets its root as the
+// latest advertisement published by the engine. Blocks other than
+// advertisements are ignored, since the entries are generated on demand from
+// the registered provider.MultihashLister. The signature of each imported
+// advertisement is verified.
+//
+// The chain must be complete, i.e. every advertisement in the chain must be
+// either in the CAR or already stored by the engine, down to the pruned
+// horizon if the engine has pruned its chain. If the engine has
+// already published advertisements, the latest one must be part of the
+// imported chain; otherwise ErrChainDiverged is returned. Nothing is written
+// unless the chain is imported.
+//
+// The mappings of the context IDs advertised in the chain since the latest
+// advertisement of the engine are updated as if the advertisements were
+// published by the engine, so that the content can be listed, updated and
+// removed. The multihash count and publish time of the imported content are
+// unknown.
+//
+// The CID of the imported latest advertisement is returned.
+func (e *Engine) ImportChain(ctx context.Context, r io.Reader) (cid.Cid, error) {
+	br, err := carv2.NewBlockReader(r)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("cannot read CAR: %w", err)
+	}
+	if len(br.Roots) != 1 {
+		return cid.Undef, fmt.Errorf("CAR must have exactly one root, found %d", len(br.Roots))
+	}
+	head := br.Roots[0]
+
+	imported := make(map[cid.Cid][]byte)
+	for {
+		blk, err := br.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return cid.Undef, fmt.Errorf("cannot read CAR block: %w", err)
+		}
+		c := blk.Cid()
+		if c.Prefix().Codec != cid.DagJSON {
+			continue
+		}
+		n, err := decodeIPLDNode(bytes.NewReader(blk.RawData()))
+		if err != nil || !isAdvertisement(n) {
+			continue
+		}
+		if err = verifyBlock(c, blk.RawData()); err != nil {
+			return cid.Undef, err
+		}
+		imported[c] = blk.RawData()
+	}
+
+	e.publishLock.Lock()
+	defer e.publishLock.Unlock()
+	latest, err := e.getLatestAdCid(ctx)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("could not get latest advertisement: %w", err)
+	}
+	horizon, err := e.getPrunedHorizon(ctx)
+	if err != nil {
+		return cid.Undef, err
+	}
+	// Load the imported advertisements ahead of those stored by the engine.
+	lsys := e.vanillaLinkSystem()
+	storageReadOpener := lsys.StorageReadOpener
+	lsys.StorageReadOpener = func(lctx ipld.LinkContext, lnk ipld.Link) (io.Reader, error) {
+		if data, ok := imported[lnk.(cidlink.Link).Cid]; ok {
+			return bytes.NewReader(data), nil
+		}
+		return storageReadOpener(lctx, lnk)
+	}
+	// ads are the advertisements published since the latest advertisement of
+	// the engine, from latest to earliest.
+	var ads []*schema.Advertisement
+	var foundLatest bool
+	for c := head; c != cid.Undef && c != horizon; {
+		if err = ctx.Err(); err != nil {
+			return cid.Undef, err
+		}
+		if c == latest {
+			foundLatest = true
+		}
+		n, err := lsys.Load(ipld.LinkContext{Ctx: ctx}, cidlink.Link{Cid: c}, schema.AdvertisementPrototype)
+		if err != nil {
+			return cid.Undef, fmt.Errorf("cannot load advertisement %s; chain is incomplete: %w", c, err)
+		}
+		ad, err := schema.UnwrapAdvertisement(n)
+		if err != nil {
+			return cid.Undef, fmt.Errorf("invalid advertisement %s: %w", c, err)
+		}
+		if _, err = ad.VerifySignature(); err != nil {
+			return cid.Undef, fmt.Errorf("invalid signature of advertisement %s: %w", c, err)
+		}
+		if !foundLatest {
+			ads = append(ads, ad)
+		}
+		c = cid.Undef
+		if ad.PreviousID != nil {
+			c = ad.PreviousID.(cidlink.Link).Cid
+		}
+	}
+	if latest != cid.Undef && !foundLatest {
+		return cid.Undef, ErrChainDiverged
+	}
+
+	batch, err := e.ds.Batch(ctx)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("cannot create datastore batch: %w", err)
+	}
+	for c, data := range imported {
+		if err = batch.Put(ctx, datastore.NewKey(c.String()), data); err != nil {
+			return cid.Undef, err
+		}
+	}
+	slices.Reverse(ads)
+	mhDelta, err := e.importMappings(ctx, batch, ads)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("cannot import context ID mappings: %w", err)
+	}
+	if err = e.putLatestAdv(ctx, batch, head.Bytes()); err != nil {
+		return cid.Undef, fmt.Errorf("failed to update reference to the latest advertisement: %w", err)
+	}
+	if err = batch.Commit(ctx); err != nil {
+		return cid.Undef, fmt.Errorf("cannot commit imported advertisements: %w", err)
+	}
+	if mhDelta != 0 {
+		e.stats.multihashesChanged(ctx, mhDelta)
+	}
+	if e.publisher != nil {
+		e.publisher.SetRoot(head)
+	}
+	log.Infow("Imported advertisement chain", "head", head, "advertisements", len(ads))
+	return head, nil
+}
+
+// importMappings writes to w the mappings of the context IDs advertised by the
+// given advertisements, from earliest to latest, as left by publishing them.
+// The change in the number of advertised multihashes is returned.
+func (e *Engine) importMappings(ctx context.Context, w datastore.Write, ads []*schema.Advertisement) (int64, error) {
+	type providerContext struct {
+		p         peer.ID
+		contextID string
+	}
+	// Only the last advertisement of each context ID determines its mappings.
+	var keys []providerContext
+	last := make(map[providerContext]*schema.Advertisement)
+	for _, ad := range ads {
+		if len(ad.ContextID) == 0 {
+			// Not content, e.g. extended providers of all content.
+			continue
+		}
+		p, err := peer.Decode(ad.Provider)
+		if err != nil {
+			return 0, fmt.Errorf("invalid provider %q: %w", ad.Provider, err)
+		}
+		key := providerContext{p: p, contextID: string(ad.ContextID)}
+		if _, ok := last[key]; !ok {
+			keys = append(keys, key)
+		}
+		last[key] = ad
+	}
+
+	var mhDelta int64
+	for _, key := range keys {
+		ad := last[key]
+		p, contextID := key.p, []byte(key.contextID)
+		var entries cid.Cid
+		if !ad.IsRm {
+			entries = ad.Entries.(cidlink.Link).Cid
+		}
+		prev, err := e.getKeyCidMap(ctx, p, contextID)
+		if err != nil && !errors.Is(err, datastore.ErrNotFound) {
+			return 0, err
+		}
+		if prev != cid.Undef && prev != entries {
+			// Release the entries advertised before the import.
+			prevInfo, err := e.getKeyInfoMap(ctx, p, contextID)
+			if err != nil {
+				return 0, err
+			}
+			if err = e.releaseEntries(ctx, w, p, contextID, prev); err != nil {
+				return 0, err
+			}
+			if prevInfo.Parts > 1 {
+				if err = e.deletePartMap(ctx, w, p, contextID); err != nil {
+					return 0, err
+				}
+			}
+			if prevInfo.MultihashCount > 0 {
+				mhDelta -= int64(prevInfo.MultihashCount)
+			}
+		}
+		if ad.IsRm {
+			if prev == cid.Undef {
+				continue
+			}
+			if err = e.deleteKeyCidMap(ctx, w, p, contextID); err != nil {
+				return 0, err
+			}
+			if err = e.deleteKeyMetadataMap(ctx, w, p, contextID); err != nil {
+				return 0, err
+			}
+			if err = e.deleteKeyInfoMap(ctx, w, p, contextID); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if prev != entries {
+			if err = e.putKeyCidMap(ctx, w, p, contextID, entries); err != nil {
+				return 0, err
+			}
+			if err = e.putKeyInfoMap(ctx, w, p, contextID, &contextInfo{MultihashCount: -1}); err != nil {
+				return 0, err
+			}
+		}
+		if err = w.Put(ctx, e.keyToMetadataKey(p, contextID), ad.Metadata); err != nil {
+			return 0, err
+		}
+	}
+	return mhDelta, nil
+}
+
+// copyingLinkSystem returns the link system of the engine, which copies every
+// block that it loads by calling put, e.g. to copy entries to a CAR.
+func (e *Engine) copyingLinkSystem(put func(cid.Cid, []byte) error) ipld.LinkSystem {
+	lsys := e.lsys
+	lsys.StorageReadOpener = func(lctx ipld.LinkContext, lnk ipld.Link) (io.Reader, error) {
+		r, err := e.lsys.StorageReadOpener(lctx, lnk)
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		if err = put(lnk.(cidlink.Link).Cid, data); err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(data), nil
+	}
+	return lsys
+}
+
+func walkAll(ctx context.Context, lsys ipld.LinkSystem, root ipld.Link) error {
+	n, err := lsys.Load(ipld.LinkContext{Ctx: ctx}, root, basicnode.Prototype.Any)
+	if err != nil {
+		return err
+	}
+	sel, err := selector.CompileSelector(exploreAllRecursively)
+	if err != nil {
+		return err
+	}
+	progress := traversal.Progress{
+		Cfg: &traversal.Config{
+			Ctx:                            ctx,
+			LinkSystem:                     lsys,
+			LinkTargetNodePrototypeChooser: basicnode.Chooser,
+		},
+	}
+	return progress.WalkMatching(n, sel, func(traversal.Progress, ipld.Node) error { return nil })
+}
+
+func verifyBlock(c cid.Cid, data []byte) error {
+	got, err := c.Prefix().Sum(data)
+	if err != nil {
+		return err
+	}
+	if !got.Equals(c) {
+		return fmt.Errorf("block data does not match CID %s", c)
+	}
+	return nil
+}
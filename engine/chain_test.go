@@ -0,0 +1,133 @@
+package engine_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	carv2 "github.com/ipld/go-car/v2"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_ExportThenImportChain(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	source := newNoPublisherEngine(t)
+	var buf bytes.Buffer
+	_, err := source.ExportChain(ctx, &buf, false)
+	require.ErrorIs(t, err, engine.ErrNoAdvertisements)
+
+	md := metadata.Default.New(metadata.Bitswap{})
+	var adCids []cid.Cid
+	for _, contextID := range []string{"fish", "lobster", "crab"} {
+		adCid, err := source.NotifyPut(ctx, nil, []byte(contextID), md)
+		require.NoError(t, err)
+		adCids = append(adCids, adCid)
+	}
+	adCid, err := source.NotifyRemove(ctx, "", []byte("fish"))
+	require.NoError(t, err)
+	adCids = append(adCids, adCid)
+
+	// Export with entries; each of the three puts has a single entry chunk.
+	head, err := source.ExportChain(ctx, &buf, true)
+	require.NoError(t, err)
+	require.Equal(t, adCids[3], head)
+	roots, blockCids := readCar(t, buf.Bytes())
+	require.Equal(t, []cid.Cid{head}, roots)
+	require.Len(t, blockCids, 7)
+	require.Equal(t, adCids[3], blockCids[0])
+	require.Equal(t, adCids[2], blockCids[1])
+
+	buf.Reset()
+	_, err = source.ExportChain(ctx, &buf, false)
+	require.NoError(t, err)
+	_, blockCids = readCar(t, buf.Bytes())
+	require.Equal(t, []cid.Cid{adCids[3], adCids[2], adCids[1], adCids[0]}, blockCids)
+
+	// Import into an engine with no advertisements, which takes over the
+	// content advertised in the chain.
+	target := newNoPublisherEngine(t, engine.WithPrivateKey(source.Key()))
+	require.Equal(t, source.ProviderID(), target.ProviderID())
+	gotHead, err := target.ImportChain(ctx, bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, head, gotHead)
+	gotLatest, _, err := target.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	require.Equal(t, head, gotLatest)
+	contextIDs, err := target.ListContextIDs(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("crab"), []byte("lobster")}, contextIDs)
+	info, err := target.GetContextInfo(ctx, "", []byte("crab"))
+	require.NoError(t, err)
+	require.True(t, md.Equal(info.Metadata))
+	require.Equal(t, -1, info.MultihashCount)
+	_, err = target.NotifyRemove(ctx, "", []byte("fish"))
+	require.ErrorIs(t, err, provider.ErrContextIDNotFound)
+	rmAdCid, err := target.NotifyRemove(ctx, "", []byte("lobster"))
+	require.NoError(t, err)
+	rmAd, err := target.GetAdv(ctx, rmAdCid)
+	require.NoError(t, err)
+	require.True(t, rmAd.IsRm)
+	require.Equal(t, head, rmAd.PreviousID.(cidlink.Link).Cid)
+
+	// Import the chain extended since into an engine that has imported it
+	// before, which only updates the context IDs advertised since.
+	target = newNoPublisherEngine(t, engine.WithPrivateKey(source.Key()))
+	_, err = target.ImportChain(ctx, bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	_, err = source.NotifyRemove(ctx, "", []byte("crab"))
+	require.NoError(t, err)
+	buf.Reset()
+	_, err = source.ExportChain(ctx, &buf, false)
+	require.NoError(t, err)
+	_, err = target.ImportChain(ctx, bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	contextIDs, err = target.ListContextIDs(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("lobster")}, contextIDs)
+
+	// Import into an engine whose latest advertisement is not in the chain,
+	// without writing any of the imported advertisements.
+	diverged := newNoPublisherEngine(t)
+	_, err = diverged.NotifyPut(ctx, nil, []byte("barreleye"), md)
+	require.NoError(t, err)
+	_, err = diverged.ImportChain(ctx, bytes.NewReader(buf.Bytes()))
+	require.ErrorIs(t, err, engine.ErrChainDiverged)
+	_, err = diverged.GetAdv(ctx, head)
+	require.Error(t, err)
+}
+
+func newNoPublisherEngine(t *testing.T, o ...engine.Option) *engine.Engine {
+	subject, err := engine.New(append(o, engine.WithPublisherKind(engine.NoPublisher))...)
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(context.Background()))
+	t.Cleanup(func() { subject.Shutdown() })
+	subject.RegisterMultihashLister(func(_ context.Context, _ peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+	return subject
+}
+
+func readCar(t *testing.T, data []byte) ([]cid.Cid, []cid.Cid) {
+	br, err := carv2.NewBlockReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	var cids []cid.Cid
+	for {
+		blk, err := br.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		cids = append(cids, blk.Cid())
+	}
+	return br.Roots, cids
+}
@@ -1,12 +1,18 @@
 package chunker_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
+	"io"
 	"testing"
 	"time"
 
 	"github.com/ipfs/go-datastore"
 	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipni/go-libipni/ingest/schema"
 	"github.com/ipni/go-libipni/test"
 	provider "github.com/ipni/index-provider"
 	"github.com/ipni/index-provider/engine/chunker"
@@ -94,3 +100,121 @@ func BenchmarkRestoreCache_ChainChunker(b *testing.B) {
 		}
 	})
 }
+
+// BenchmarkChainChunker_Chunk measures the time taken to chunk large numbers of
+// multihashes into a chain of entries chunks, excluding the time taken to
+// store them. The larger sizes take a while; select them explicitly, e.g.
+// with -bench 'ChainChunker_Chunk/100M'.
+func BenchmarkChainChunker_Chunk(b *testing.B) {
+	lsys := cidlink.DefaultLinkSystem()
+	lsys.StorageWriteOpener = func(ipld.LinkContext) (io.Writer, ipld.BlockWriteCommitter, error) {
+		return io.Discard, func(ipld.Link) error { return nil }, nil
+	}
+	for _, size := range []struct {
+		name    string
+		mhCount int
+	}{
+		{"1M", 1_000_000},
+		{"10M", 10_000_000},
+		{"100M", 100_000_000},
+	} {
+		b.Run(size.name, func(b *testing.B) {
+			subject, err := chunker.NewChainChunker(&lsys, 16384)
+			require.NoError(b, err)
+			b.SetBytes(int64(size.mhCount) * 34) // Sha2_256 multihash length
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				lnk, err := subject.Chunk(context.Background(), &generatedMultihashIterator{count: size.mhCount})
+				require.NoError(b, err)
+				require.NotNil(b, lnk)
+			}
+		})
+	}
+}
+
+// BenchmarkSortedChainChunker_Chunk measures the time taken to chunk large
+// numbers of sorted multihashes into a chain of fixed-size sorted runs, to
+// compare with BenchmarkChainChunker_Chunk.
+func BenchmarkSortedChainChunker_Chunk(b *testing.B) {
+	lsys := cidlink.DefaultLinkSystem()
+	lsys.StorageWriteOpener = func(ipld.LinkContext) (io.Writer, ipld.BlockWriteCommitter, error) {
+		return io.Discard, func(ipld.Link) error { return nil }, nil
+	}
+	for _, size := range []struct {
+		name    string
+		mhCount int
+	}{
+		{"1M", 1_000_000},
+		{"10M", 10_000_000},
+		{"100M", 100_000_000},
+	} {
+		b.Run(size.name, func(b *testing.B) {
+			subject, err := chunker.NewSortedChainChunker(&lsys, 16384)
+			require.NoError(b, err)
+			b.SetBytes(int64(size.mhCount) * 34) // Sha2_256 multihash length
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				lnk, err := subject.Chunk(context.Background(), &generatedMultihashIterator{count: size.mhCount})
+				require.NoError(b, err)
+				require.NotNil(b, lnk)
+			}
+		})
+	}
+}
+
+// BenchmarkSearchEntryChunk measures the time taken to look up a multihash in
+// a sorted entries chunk, compared with scanning the chunk.
+func BenchmarkSearchEntryChunk(b *testing.B) {
+	it := &generatedMultihashIterator{count: 16384}
+	chunk := &schema.EntryChunk{}
+	for {
+		mh, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(b, err)
+		chunk.Entries = append(chunk.Entries, mh)
+	}
+	targets := test.RandomMultihashes(64)
+	for i := range targets {
+		if i%2 == 0 {
+			targets[i] = chunk.Entries[i*255]
+		}
+	}
+
+	b.Run("BinarySearch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			chunker.SearchEntryChunk(chunk, targets[i%len(targets)])
+		}
+	})
+	b.Run("Scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			target := targets[i%len(targets)]
+			for _, mh := range chunk.Entries {
+				if bytes.Equal(mh, target) {
+					break
+				}
+			}
+		}
+	})
+}
+
+// generatedMultihashIterator iterates over distinct multihashes that are
+// generated as they are iterated over, so that arbitrarily many multihashes
+// need not be held in memory.
+type generatedMultihashIterator struct {
+	count  int
+	next   int
+	digest [32]byte
+}
+
+func (i *generatedMultihashIterator) Next() (multihash.Multihash, error) {
+	if i.next == i.count {
+		return nil, io.EOF
+	}
+	binary.BigEndian.PutUint64(i.digest[:], uint64(i.next))
+	i.next++
+	return multihash.Encode(i.digest[:], multihash.SHA2_256)
+}
@@ -300,16 +300,46 @@ func (ls *CachedEntriesChunker) GetRawCachedChunk(ctx context.Context, l ipld.Li
 	return raw, nil
 }
 
+// Remove removes the DAG with the given root from the cache, deleting its chunks from the backing
+// datastore unless they overlap with other cached DAGs. It returns whether the DAG was cached.
+func (ls *CachedEntriesChunker) Remove(ctx context.Context, root ipld.Link) (bool, error) {
+	ls.lock.Lock()
+	defer ls.lock.Unlock()
+
+	var found bool
+	err := ls.performOnCache(ctx, func(cache *lru.Cache) {
+		if _, found = cache.Get(root); found {
+			cache.Remove(root)
+		}
+	})
+	if err != nil || !found {
+		return found, err
+	}
+	return true, ls.sync(ctx)
+}
+
 // Clear purges all stored items from the CachedEntriesChunker.
 func (ls *CachedEntriesChunker) Clear(ctx context.Context) error {
+	_, err := ls.Purge(ctx)
+	return err
+}
+
+// Purge purges all stored items from the CachedEntriesChunker, and returns the number of bytes
+// reclaimed, i.e. the total size of the keys and values deleted from the backing datastore.
+func (ls *CachedEntriesChunker) Purge(ctx context.Context) (int64, error) {
 	ls.lock.Lock()
 	defer ls.lock.Unlock()
 
+	before, err := ls.size(ctx)
+	if err != nil {
+		return 0, err
+	}
+
 	// Clear loaded cache entries first, which calls OnEvict per entry.
 	if err := ls.performOnCache(ctx, func(cache *lru.Cache) {
 		cache.Clear()
 	}); err != nil {
-		return err
+		return 0, err
 	}
 
 	// Delete all datastore entries in case the cache was partially loaded.
@@ -320,27 +350,118 @@ func (ls *CachedEntriesChunker) Clear(ctx context.Context) error {
 	results, err := ls.ds.Query(ctx, q)
 	if err != nil {
 		log.Errorw("Failed to query keys while clearing cache", "err", err)
-		return err
+		return 0, err
 	}
 	defer results.Close()
 
 	for r := range results.Next() {
 		if ctx.Err() != nil {
-			return ctx.Err()
+			return 0, ctx.Err()
 		}
 		if r.Error != nil {
-			return fmt.Errorf("cannot list cache key to clear: %w", r.Error)
+			return 0, fmt.Errorf("cannot list cache key to clear: %w", r.Error)
 		}
 
 		rawKey := datastore.RawKey(r.Key)
 		err := ls.ds.Delete(ctx, rawKey)
 		if err != nil {
 			log.Errorw("Failed to delete key while clearing cache", "err", err)
-			return err
+			return 0, err
 		}
 	}
-	log.Info("Cleared the cache successfully")
-	return nil
+	if err = ls.sync(ctx); err != nil {
+		return 0, err
+	}
+	log.Infow("Cleared the cache successfully", "bytesReclaimed", before)
+	return before, nil
+}
+
+// GC deletes the items in the backing datastore that are not part of any cached DAG, such as
+// chunks left behind by an interrupted chunking or eviction, and returns the number of bytes
+// reclaimed, i.e. the total size of the keys and values deleted. Cached DAGs are left intact.
+func (ls *CachedEntriesChunker) GC(ctx context.Context) (int64, error) {
+	ls.lock.Lock()
+	defer ls.lock.Unlock()
+
+	// Collect the keys of the roots of cached DAGs, and of the chunks and overlap counters of
+	// their links.
+	live := make(map[string]struct{})
+	roots, err := ls.ds.Query(ctx, dsq.Query{Prefix: rootKeyPrefix.String()})
+	if err != nil {
+		return 0, err
+	}
+	for r := range roots.Next() {
+		if r.Error != nil {
+			roots.Close()
+			return 0, fmt.Errorf("cannot read cache key: %w", r.Error)
+		}
+		live[r.Key] = struct{}{}
+		vr := bytes.NewReader(r.Value)
+		for {
+			_, c, err := cid.CidFromReader(vr)
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				roots.Close()
+				return 0, fmt.Errorf("cannot read links of cache key %s: %w", r.Key, err)
+			}
+			lnk := cidlink.Link{Cid: c}
+			live[dsKey(lnk).String()] = struct{}{}
+			live[ls.dsOverlapPrefixedKey(lnk).String()] = struct{}{}
+		}
+	}
+	roots.Close()
+
+	results, err := ls.ds.Query(ctx, dsq.Query{KeysOnly: true, ReturnsSizes: true})
+	if err != nil {
+		return 0, err
+	}
+	var dead []dsq.Entry
+	for r := range results.Next() {
+		if r.Error != nil {
+			results.Close()
+			return 0, fmt.Errorf("cannot list cache key: %w", r.Error)
+		}
+		if _, ok := live[r.Key]; !ok {
+			dead = append(dead, r.Entry)
+		}
+	}
+	results.Close()
+
+	var reclaimed int64
+	for _, entry := range dead {
+		if ctx.Err() != nil {
+			return reclaimed, ctx.Err()
+		}
+		if err := ls.ds.Delete(ctx, datastore.RawKey(entry.Key)); err != nil {
+			return reclaimed, err
+		}
+		reclaimed += int64(len(entry.Key) + entry.Size)
+	}
+	if err = ls.sync(ctx); err != nil {
+		return reclaimed, err
+	}
+	log.Infow("Garbage collected the cache", "deletedKeys", len(dead), "bytesReclaimed", reclaimed)
+	return reclaimed, nil
+}
+
+// size returns the total size of the keys and values in the backing datastore.
+func (ls *CachedEntriesChunker) size(ctx context.Context) (int64, error) {
+	results, err := ls.ds.Query(ctx, dsq.Query{KeysOnly: true, ReturnsSizes: true})
+	if err != nil {
+		return 0, err
+	}
+	defer results.Close()
+
+	var size int64
+	for r := range results.Next() {
+		if r.Error != nil {
+			return 0, fmt.Errorf("cannot list cache key: %w", r.Error)
+		}
+		size += int64(len(r.Key) + r.Size)
+	}
+	return size, nil
 }
 
 // Close syncs the backing datastore but does not close it.
@@ -102,6 +102,12 @@ func TestCachedEntriesChunker(t *testing.T) {
 		t.Run("PurgesCacheSuccessfullyEvenIfCorrupted", func(t *testing.T) {
 			testCachedEntriesChunker_PurgesCacheSuccessfullyEvenIfCorrupted(t, test.capacity, test.c)
 		})
+		t.Run("PurgeReportsBytesReclaimed", func(t *testing.T) {
+			testCachedEntriesChunker_PurgeReportsBytesReclaimed(t, test.capacity, test.c)
+		})
+		t.Run("GCDeletesUnreferencedItems", func(t *testing.T) {
+			testCachedEntriesChunker_GCDeletesUnreferencedItems(t, test.capacity, test.c)
+		})
 	}
 }
 
@@ -296,6 +302,39 @@ func TestCachedEntriesChunker_OverlappingDagIsNotEvicted(t *testing.T) {
 	require.Equal(t, 1, subject.Len())
 }
 
+func TestCachedEntriesChunker_RemoveKeepsOverlappingChunks(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	subject, err := chunker.NewCachedEntriesChunker(ctx, datastore.NewMapDatastore(), 10, chunker.NewChainChunkerFunc(10), false)
+	require.NoError(t, err)
+	defer subject.Close()
+
+	c1Mhs := test.RandomMultihashes(10)
+	c1Lnk, err := subject.Chunk(ctx, provider.SliceMultihashIterator(c1Mhs))
+	require.NoError(t, err)
+	c2Lnk, err := subject.Chunk(ctx, provider.SliceMultihashIterator(append(test.RandomMultihashes(10), c1Mhs...)))
+	require.NoError(t, err)
+	require.Equal(t, 2, subject.Len())
+
+	// Removing c2 keeps its next chunk, which is c1.
+	removed, err := subject.Remove(ctx, c2Lnk)
+	require.NoError(t, err)
+	require.True(t, removed)
+	require.Equal(t, 1, subject.Len())
+	requireChunkIsNotCached(t, subject, c2Lnk)
+	requireChunkIsCached(t, subject, c1Lnk)
+
+	removed, err = subject.Remove(ctx, c2Lnk)
+	require.NoError(t, err)
+	require.False(t, removed)
+	removed, err = subject.Remove(ctx, c1Lnk)
+	require.NoError(t, err)
+	require.True(t, removed)
+	require.Equal(t, 0, subject.Len())
+	requireChunkIsNotCached(t, subject, c1Lnk)
+}
+
 func testCachedEntriesChunker_RecoversFromCorruptCacheGracefully(t *testing.T, capacity int, c chunker.NewChunkerFunc) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -430,6 +469,63 @@ func testCachedEntriesChunker_PurgesCacheSuccessfully(t *testing.T, capacity int
 	require.Equal(t, 0, subject.Len())
 }
 
+func testCachedEntriesChunker_PurgeReportsBytesReclaimed(t *testing.T, capacity int, c chunker.NewChunkerFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	store := dssync.MutexWrap(datastore.NewMapDatastore())
+	subject, err := chunker.NewCachedEntriesChunker(ctx, store, capacity, c, false)
+	require.NoError(t, err)
+	defer subject.Close()
+
+	chunkLink, err := subject.Chunk(ctx, provider.SliceMultihashIterator(test.RandomMultihashes(10)))
+	require.NoError(t, err)
+	raw, err := subject.GetRawCachedChunk(ctx, chunkLink)
+	require.NoError(t, err)
+
+	reclaimed, err := subject.Purge(ctx)
+	require.NoError(t, err)
+	require.Greater(t, reclaimed, int64(len(raw)))
+	require.Equal(t, 0, subject.Len())
+	raw, err = subject.GetRawCachedChunk(ctx, chunkLink)
+	require.NoError(t, err)
+	require.Nil(t, raw)
+
+	reclaimed, err = subject.Purge(ctx)
+	require.NoError(t, err)
+	require.Zero(t, reclaimed)
+}
+
+func testCachedEntriesChunker_GCDeletesUnreferencedItems(t *testing.T, capacity int, c chunker.NewChunkerFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	store := dssync.MutexWrap(datastore.NewMapDatastore())
+	subject, err := chunker.NewCachedEntriesChunker(ctx, store, capacity, c, false)
+	require.NoError(t, err)
+	defer subject.Close()
+
+	chunkLink, err := subject.Chunk(ctx, provider.SliceMultihashIterator(test.RandomMultihashes(10)))
+	require.NoError(t, err)
+
+	// Nothing to collect when all items are referenced.
+	reclaimed, err := subject.GC(ctx)
+	require.NoError(t, err)
+	require.Zero(t, reclaimed)
+
+	// Leave behind an item that is not part of any cached DAG.
+	orphan := datastore.NewKey("bafkqaaa")
+	require.NoError(t, store.Put(ctx, orphan, []byte("fish")))
+
+	reclaimed, err = subject.GC(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(orphan.String())+len("fish")), reclaimed)
+	_, err = store.Get(ctx, orphan)
+	require.ErrorIs(t, err, datastore.ErrNotFound)
+	requireDecodeAllMultihashes(t, chunkLink, subject.LinkSystem())
+	require.Equal(t, 1, subject.Len())
+}
+
 func testCachedEntriesChunker_OldFormatIsHandledGracefully(t *testing.T, capacity int, c chunker.NewChunkerFunc) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
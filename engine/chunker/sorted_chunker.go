@@ -0,0 +1,132 @@
+package chunker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/ipni/go-libipni/ingest/schema"
+	provider "github.com/ipni/index-provider"
+	"github.com/multiformats/go-multihash"
+)
+
+// ErrUnsortedMultihashes signals that the multihashes given to a
+// SortedChainChunker are not in ascending byte order without duplicates.
+var ErrUnsortedMultihashes = errors.New("multihashes not in ascending order without duplicates")
+
+var _ EntriesChunker = (*SortedChainChunker)(nil)
+
+// SortedChainChunker chunks advertisement entries as a chained series of
+// schema.EntryChunk nodes that each hold a fixed-size run of sorted
+// multihashes. See: NewSortedChainChunker
+type SortedChainChunker struct {
+	ls        *ipld.LinkSystem
+	chunkSize int
+}
+
+// NewSortedChainChunker instantiates a new sorted chain chunker that given a
+// provider.MultihashIterator of multihashes in ascending byte order without
+// duplicates, e.g. one returned by provider.SortedMultihashIterator, drains it
+// and stores its multihashes in the given link system as a chain of
+// schema.EntryChunk nodes, where each chunk contains exactly chunkSize
+// multihashes, except for the root chunk which contains the remainder.
+//
+// The chunks are schema compatible with those of ChainChunker, but have a
+// predictable layout: the multihashes of each chunk are in ascending order,
+// so that they can be binary searched, see SearchEntryChunk, and the chunks
+// hold consecutive runs of multihashes, such that the chain, traversed from
+// its root, lists the runs in descending order. Whether a multihash is in a
+// chunk can therefore be told from its first and last multihashes.
+//
+// See: schema.EntryChunk.
+func NewSortedChainChunker(ls *ipld.LinkSystem, chunkSize int) (*SortedChainChunker, error) {
+	if chunkSize < 1 {
+		return nil, fmt.Errorf("chunk size must be at least 1; got: %d", chunkSize)
+	}
+	return &SortedChainChunker{
+		ls:        ls,
+		chunkSize: chunkSize,
+	}, nil
+}
+
+func NewSortedChainChunkerFunc(chunkSize int) NewChunkerFunc {
+	return func(ls *ipld.LinkSystem) (EntriesChunker, error) {
+		return NewSortedChainChunker(ls, chunkSize)
+	}
+}
+
+// Chunk chunks all the mulithashes returned by the given iterator into a chain
+// of schema.EntryChunk nodes of chunkSize multihashes each, and returns the
+// link to the root chunk node. ErrUnsortedMultihashes is returned if the
+// multihashes are not in ascending byte order without duplicates.
+//
+// See: schema.EntryChunk.
+func (sc *SortedChainChunker) Chunk(ctx context.Context, mhi provider.MultihashIterator) (ipld.Link, error) {
+	mhs := make([]multihash.Multihash, 0, sc.chunkSize)
+	var next ipld.Link
+	var last multihash.Multihash
+	var mhCount, chunkCount int
+	store := func() error {
+		cNode, err := newEntriesChunkNode(mhs, next)
+		if err != nil {
+			return err
+		}
+		next, err = sc.ls.Store(ipld.LinkContext{Ctx: ctx}, schema.Linkproto, cNode)
+		if err != nil {
+			return err
+		}
+		chunkCount++
+		// Keep the last multihash to compare the next one with, since the
+		// backing array of mhs is reused.
+		last = append(last[:0], mhs[len(mhs)-1]...)
+		mhs = mhs[:0]
+		return nil
+	}
+	for {
+		mh, err := mhi.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		prev := last
+		if len(mhs) != 0 {
+			prev = mhs[len(mhs)-1]
+		}
+		if prev != nil && bytes.Compare(prev, mh) >= 0 {
+			return nil, fmt.Errorf("%w: %s after %s", ErrUnsortedMultihashes, mh.B58String(), prev.B58String())
+		}
+		mhs = append(mhs, mh)
+		mhCount++
+		if len(mhs) == sc.chunkSize {
+			if err = store(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(mhs) != 0 {
+		if err := store(); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Infow("Generated sorted linked chunks of multihashes", "totalMhCount", mhCount, "chunkCount", chunkCount)
+	return next, nil
+}
+
+// SearchEntryChunk binary searches the multihashes of an entries chunk, which
+// must be in ascending byte order as they are in chunks generated by
+// SortedChainChunker, for the given multihash. It returns the index of the
+// multihash in the chunk and true if found, or else the index at which it
+// would be inserted and false.
+func SearchEntryChunk(chunk *schema.EntryChunk, mh multihash.Multihash) (int, bool) {
+	i := sort.Search(len(chunk.Entries), func(i int) bool {
+		return bytes.Compare(chunk.Entries[i], mh) >= 0
+	})
+	return i, i < len(chunk.Entries) && bytes.Equal(chunk.Entries[i], mh)
+}
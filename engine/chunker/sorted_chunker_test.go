@@ -0,0 +1,76 @@
+package chunker_test
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/storage/memstore"
+	"github.com/ipni/go-libipni/ingest/schema"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine/chunker"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortedChainChunker_Chunk(t *testing.T) {
+	ctx := context.TODO()
+	store := &memstore.Store{}
+	ls := cidlink.DefaultLinkSystem()
+	ls.SetReadStorage(store)
+	ls.SetWriteStorage(store)
+	subject, err := chunker.NewSortedChainChunkerFunc(7)(&ls)
+	require.NoError(t, err)
+
+	mhs := test.RandomMultihashes(100)
+	slices.SortFunc(mhs, func(a, b multihash.Multihash) int { return bytes.Compare(a, b) })
+	l, err := subject.Chunk(ctx, provider.SliceMultihashIterator(mhs))
+	require.NoError(t, err)
+
+	// The chain lists runs of 7 multihashes in descending order from its root,
+	// which holds the remaining 2.
+	var chunks []*schema.EntryChunk
+	for next := l; next != nil; {
+		n, err := ls.Load(ipld.LinkContext{Ctx: ctx}, next, schema.EntryChunkPrototype)
+		require.NoError(t, err)
+		chunk, err := schema.UnwrapEntryChunk(n)
+		require.NoError(t, err)
+		chunks = append(chunks, chunk)
+		next = nil
+		if chunk.Next != nil {
+			next = chunk.Next
+		}
+	}
+	require.Len(t, chunks, 15)
+	require.Len(t, chunks[0].Entries, 2)
+	var got []multihash.Multihash
+	for i := len(chunks) - 1; i >= 0; i-- {
+		if i != 0 {
+			require.Len(t, chunks[i].Entries, 7)
+		}
+		got = append(got, chunks[i].Entries...)
+	}
+	require.Equal(t, mhs, got)
+
+	for i, mh := range mhs {
+		chunk := chunks[len(chunks)-1-i/7]
+		index, found := chunker.SearchEntryChunk(chunk, mh)
+		require.True(t, found)
+		require.Equal(t, i%7, index)
+	}
+	_, found := chunker.SearchEntryChunk(chunks[0], test.RandomMultihashes(1)[0])
+	require.False(t, found)
+
+	// Unsorted and duplicate multihashes are rejected.
+	_, err = subject.Chunk(ctx, provider.SliceMultihashIterator([]multihash.Multihash{mhs[1], mhs[0]}))
+	require.ErrorIs(t, err, chunker.ErrUnsortedMultihashes)
+	_, err = subject.Chunk(ctx, provider.SliceMultihashIterator(append(mhs[:7:7], mhs[6])))
+	require.ErrorIs(t, err, chunker.ErrUnsortedMultihashes)
+
+	_, err = chunker.NewSortedChainChunker(&ls, 0)
+	require.Error(t, err)
+}
@@ -0,0 +1,137 @@
+package engine_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_ConcurrentPublishing(t *testing.T) {
+	const (
+		publishers  = 8
+		contextIDs  = 10
+		multihashes = 3
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	indexer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer indexer.Close()
+
+	subject, err := engine.New(
+		engine.WithPublisherKind(engine.HttpPublisher),
+		engine.WithHttpPublisherWithoutServer(),
+		engine.WithHttpPublisherAnnounceAddr("/ip4/127.0.0.1/tcp/3104/http"),
+		engine.WithPubsubAnnounce(false),
+		engine.WithDirectAnnounce(indexer.URL))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+
+	mhs := make(map[string][]multihash.Multihash)
+	for i := 0; i < publishers; i++ {
+		for j := 0; j < contextIDs; j++ {
+			mhs[fmt.Sprintf("%d/%d", i, j)] = test.RandomMultihashes(multihashes)
+		}
+	}
+	lister := func(_ context.Context, _ peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(mhs[string(contextID)]), nil
+	}
+	subject.RegisterMultihashLister(lister)
+
+	var published sync.Map
+	var wg sync.WaitGroup
+	errs := make(chan error, publishers)
+	for i := 0; i < publishers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < contextIDs; j++ {
+				contextID := []byte(fmt.Sprintf("%d/%d", i, j))
+				adCid, err := subject.NotifyPut(ctx, nil, contextID, metadata.Default.New(metadata.Bitswap{}))
+				if err != nil {
+					errs <- err
+					return
+				}
+				published.Store(adCid, struct{}{})
+				if j%2 != 0 {
+					continue
+				}
+				adCid, err = subject.NotifyRemove(ctx, "", contextID)
+				if err != nil {
+					errs <- err
+					return
+				}
+				published.Store(adCid, struct{}{})
+			}
+		}(i)
+	}
+
+	// Exercise the other methods while advertisements are published.
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			subject.RegisterMultihashLister(lister)
+			_, _ = subject.PublishLatest(ctx)
+			_, _, _ = subject.GetLatestAdv(ctx)
+			_, _ = subject.ListContextIDs(ctx, "")
+			_, _ = subject.Stats(ctx)
+			_ = subject.Ready(ctx)
+		}
+	}()
+
+	wg.Wait()
+	close(done)
+	readers.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	// Every advertisement is in a single chain, i.e. each links to the one
+	// published before it.
+	var chainLen int
+	head, _, err := subject.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	for c := head; c != cid.Undef; chainLen++ {
+		_, ok := published.Load(c)
+		require.True(t, ok, "unexpected advertisement %s in chain", c)
+		ad, err := subject.GetAdv(ctx, c)
+		require.NoError(t, err)
+		c = cid.Undef
+		if ad.PreviousID != nil {
+			c = ad.PreviousID.(cidlink.Link).Cid
+		}
+	}
+	require.Equal(t, publishers*contextIDs*3/2, chainLen)
+
+	got, err := subject.ListContextIDs(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, got, publishers*contextIDs/2)
+	stats, err := subject.Stats(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, publishers*contextIDs/2*multihashes, stats.Multihashes)
+}
@@ -0,0 +1,99 @@
+package engine
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// announceDebouncer defers the announcement of the head of the advertisement
+// chain until no advertisement has been published for the quiet period. See:
+// WithAnnounceDebounce.
+type announceDebouncer struct {
+	lock  sync.Mutex
+	timer *time.Timer
+	// pending is the CID of the advertisement to announce, and first the time
+	// at which an announcement was first deferred since the last was sent.
+	pending cid.Cid
+	first   time.Time
+	// closed is set once the engine is shut down, and inflight tracks the
+	// announcements being sent by the timer, which shutdown waits for.
+	closed   bool
+	inflight sync.WaitGroup
+}
+
+// deferAnnounce defers announcing the given advertisement, replacing any
+// pending announcement, until the quiet period elapses without another
+// advertisement being published, or the maximum delay since the announcement
+// was first deferred elapses.
+func (e *Engine) deferAnnounce(c cid.Cid) {
+	d := &e.debouncer
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.closed {
+		return
+	}
+
+	now := time.Now()
+	if d.pending == cid.Undef {
+		d.first = now
+	}
+	d.pending = c
+	delay := e.announceQuiet
+	if e.announceMaxDelay > 0 {
+		delay = max(min(delay, d.first.Add(e.announceMaxDelay).Sub(now)), 0)
+	}
+	if d.timer == nil {
+		d.timer = time.AfterFunc(delay, e.flushAnnounce)
+	} else {
+		d.timer.Reset(delay)
+	}
+	log.Debugw("Deferred announcement", "adCid", c, "delay", delay)
+}
+
+// flushAnnounce sends the pending announcement, if any.
+func (e *Engine) flushAnnounce() {
+	d := &e.debouncer
+	d.lock.Lock()
+	c := d.pending
+	d.pending = cid.Undef
+	if c == cid.Undef || d.closed {
+		d.lock.Unlock()
+		return
+	}
+	d.inflight.Add(1)
+	d.lock.Unlock()
+	defer d.inflight.Done()
+
+	e.announce(context.Background(), c)
+}
+
+// dropPendingAnnounce drops the pending announcement, if any, for when the
+// head is announced otherwise.
+func (e *Engine) dropPendingAnnounce() {
+	d := &e.debouncer
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.pending = cid.Undef
+}
+
+// closeDebouncer stops deferring announcements, waits for any being sent, and
+// returns the pending announcement, if any, for the caller to send.
+func (e *Engine) closeDebouncer() cid.Cid {
+	d := &e.debouncer
+	d.lock.Lock()
+	d.closed = true
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	c := d.pending
+	d.pending = cid.Undef
+	d.lock.Unlock()
+	d.inflight.Wait()
+	return c
+}
@@ -0,0 +1,113 @@
+package engine_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/go-libipni/announce/message"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_AnnounceDebounce(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	var lock sync.Mutex
+	var announced []cid.Cid
+	indexer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg message.Message
+		require.NoError(t, msg.UnmarshalCBOR(r.Body))
+		lock.Lock()
+		announced = append(announced, msg.Cid)
+		lock.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(indexer.Close)
+	getAnnounced := func() []cid.Cid {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]cid.Cid{}, announced...)
+	}
+
+	newEngine := func(quiet, maxDelay time.Duration) *engine.Engine {
+		subject, err := engine.New(
+			engine.WithPublisherKind(engine.HttpPublisher),
+			engine.WithHttpPublisherWithoutServer(),
+			engine.WithHttpPublisherAnnounceAddr("/ip4/127.0.0.1/tcp/3104/http"),
+			engine.WithPubsubAnnounce(false),
+			engine.WithDirectAnnounce(indexer.URL),
+			engine.WithAnnounceDebounce(quiet, maxDelay))
+		require.NoError(t, err)
+		require.NoError(t, subject.Start(ctx))
+		subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+			return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+		})
+		return subject
+	}
+	put := func(subject *engine.Engine, contextID string) cid.Cid {
+		adCid, err := subject.NotifyPut(ctx, nil, []byte(contextID), metadata.Default.New(metadata.Bitswap{}))
+		require.NoError(t, err)
+		return adCid
+	}
+
+	// Only the last of the advertisements published in quick succession is
+	// announced, once the quiet period elapses, while each is the head as it
+	// is published.
+	subject := newEngine(200*time.Millisecond, 0)
+	for _, contextID := range []string{"fish", "lobster"} {
+		adCid := put(subject, contextID)
+		head, _, err := subject.GetLatestAdv(ctx)
+		require.NoError(t, err)
+		require.Equal(t, adCid, head)
+	}
+	last := put(subject, "crab")
+	require.Empty(t, getAnnounced())
+	require.Eventually(t, func() bool { return len(getAnnounced()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, []cid.Cid{last}, getAnnounced())
+
+	// A pending announcement is sent on shutdown.
+	last = put(subject, "shrimp")
+	require.NoError(t, subject.Shutdown())
+	require.Equal(t, last, getAnnounced()[1])
+
+	// Announcements are deferred no longer than the maximum delay while
+	// advertisements are published continuously.
+	lock.Lock()
+	announced = nil
+	lock.Unlock()
+	subject = newEngine(time.Hour, 300*time.Millisecond)
+	defer subject.Shutdown()
+	start := time.Now()
+	for i := 0; len(getAnnounced()) == 0; i++ {
+		require.Less(t, time.Since(start), 5*time.Second)
+		put(subject, fmt.Sprint("fish", i))
+		time.Sleep(20 * time.Millisecond)
+	}
+	require.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+
+	// Announcing the head drops the pending announcement.
+	time.Sleep(400 * time.Millisecond)
+	lock.Lock()
+	announced = nil
+	lock.Unlock()
+	last = put(subject, "octopus")
+	adCid, err := subject.PublishLatest(ctx)
+	require.NoError(t, err)
+	require.Equal(t, last, adCid)
+	time.Sleep(400 * time.Millisecond)
+	require.Equal(t, []cid.Cid{last}, getAnnounced())
+
+	_, err = engine.New(engine.WithAnnounceDebounce(-time.Second, 0))
+	require.ErrorContains(t, err, "must not be negative")
+}
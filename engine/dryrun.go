@@ -0,0 +1,200 @@
+package engine
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/storage/memstore"
+	"github.com/ipni/go-libipni/ingest/schema"
+	"github.com/ipni/go-libipni/metadata"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/metrics"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DryRunResult describes the advertisement that Engine.NotifyPut would
+// publish, as determined by Engine.DryRunPut.
+type DryRunResult struct {
+	// AdCid is the CID the advertisement would have if it were published
+	// next. It changes once another advertisement is published.
+	AdCid cid.Cid
+	// Entries is the CID of the entries of the advertisement.
+	Entries cid.Cid
+	// MultihashCount is the number of advertised multihashes, or -1 if
+	// unknown.
+	MultihashCount int
+	// Skipped is the number of multihashes that the lister failed to list.
+	Skipped int
+	// Parts is the number of advertisements that the content would be split
+	// across. See: WithMaxAdMultihashes.
+	Parts int
+	// Size is the estimated number of bytes that would be published, i.e. of
+	// the advertisement and of the entries generated for it. The
+	// advertisements of the other parts of split content are not counted.
+	Size int64
+}
+
+// DryRunPut does everything that Engine.NotifyPut does to publish an
+// advertisement of the given context ID, i.e. listing the multihashes,
+// chunking them into entries, and signing and validating the advertisement,
+// except that the entries are chunked in memory, and the advertisement is
+// neither stored, nor appended to the chain, nor announced. The multihashes
+// are listed by the registered provider.MultihashLister unless mhIter is not
+// nil, in which case they are read from mhIter instead.
+//
+// The same errors as Engine.NotifyPut are returned, e.g.
+// provider.ErrAlreadyAdvertised if the context ID is already advertised with
+// the same metadata.
+func (e *Engine) DryRunPut(ctx context.Context, addrInfo *peer.AddrInfo, contextID []byte, md metadata.Metadata, mhIter provider.MultihashIterator) (_ *DryRunResult, err error) {
+	var p peer.ID
+	var addrs []multiaddr.Multiaddr
+	if addrInfo != nil {
+		p = addrInfo.ID
+		addrs = addrInfo.Addrs
+	}
+	if p == "" {
+		p, _ = e.identity()
+		addrs = e.options.provider.Addrs
+	}
+	if addrs, err = e.adRetrievalAddrs(addrs); err != nil {
+		return nil, err
+	}
+	if err = provider.ValidatePut(contextID, md, addrs); err != nil {
+		return nil, err
+	}
+	if err = e.checkMetadata(md); err != nil {
+		return nil, err
+	}
+
+	ctx, span := metrics.Tracer.Start(ctx, "engine.DryRunPut", trace.WithAttributes(
+		attribute.Stringer("providerID", p),
+		attribute.String("contextID", base64.StdEncoding.EncodeToString(contextID)),
+	))
+	defer func() { metrics.EndSpan(span, err) }()
+
+	derived, err := e.isDerivedPart(ctx, p, contextID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get part by provider + context id: %s", err)
+	}
+	if derived {
+		return nil, fmt.Errorf("context ID is part of split content; update or remove the context ID that is split instead")
+	}
+	c, err := e.getKeyCidMap(ctx, p, contextID)
+	if err != nil && !errors.Is(err, datastore.ErrNotFound) {
+		return nil, fmt.Errorf("cound not not get entries cid by provider + context id: %s", err)
+	}
+
+	// Entries are chunked into a store that is discarded once done.
+	store := &memstore.Store{}
+	lsys := cidlink.DefaultLinkSystem()
+	lsys.SetReadStorage(store)
+	lsys.SetWriteStorage(store)
+
+	res := &DryRunResult{MultihashCount: -1, Parts: 1}
+	if c != cid.Undef {
+		prevMetadata, err := e.getKeyMetadataMap(ctx, p, contextID)
+		if err != nil && !errors.Is(err, datastore.ErrNotFound) {
+			return nil, fmt.Errorf("could not get metadata for provider + context id: %s", err)
+		}
+		if md.Equal(prevMetadata) {
+			return nil, provider.ErrAlreadyAdvertised
+		}
+		// Only the metadata would change.
+		prevInfo, err := e.getKeyInfoMap(ctx, p, contextID)
+		if err != nil {
+			return nil, fmt.Errorf("could not get info for provider + context id: %s", err)
+		}
+		res.Entries = c
+		res.MultihashCount = prevInfo.MultihashCount
+		res.Parts = max(prevInfo.Parts, 1)
+	} else {
+		var skipped atomic.Int64
+		if mhIter == nil {
+			if mhIter, err = e.listMultihashes(ctx, p, contextID, &skipped); err != nil {
+				return nil, err
+			}
+		} else if e.sortEntries || e.sortedChunks {
+			if mhIter, err = provider.SortedMultihashIterator(mhIter, e.sortMemory, e.sortTempDir); err != nil {
+				return nil, fmt.Errorf("cannot sort multihashes: %w", err)
+			}
+		}
+		defer closeMultihashIterator(mhIter)
+		entriesChunker, err := e.chunker(&lsys)
+		if err != nil {
+			return nil, err
+		}
+		countingIter := &countingMultihashIterator{MultihashIterator: mhIter}
+		var lnk ipld.Link
+		if e.maxAdMultihashes > 0 {
+			var chunked []chunkedPart
+			if chunked, err = e.chunkParts(ctx, entriesChunker, contextID, countingIter); err == nil {
+				lnk = chunked[0].Entries
+				res.Parts = len(chunked)
+			}
+		} else {
+			lnk, err = entriesChunker.Chunk(ctx, countingIter)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not generate entries list: %s", err)
+		}
+		if lnk == nil {
+			lnk = schema.NoEntries
+		}
+		res.Entries = lnk.(cidlink.Link).Cid
+		res.MultihashCount = countingIter.count
+		res.Skipped = int(skipped.Load())
+	}
+
+	mdBytes, err := md.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	var stringAddrs []string
+	for _, addr := range addrs {
+		stringAddrs = append(stringAddrs, addr.String())
+	}
+	adv := schema.Advertisement{
+		Provider:  p.String(),
+		Addresses: stringAddrs,
+		Entries:   cidlink.Link{Cid: res.Entries},
+		ContextID: contextID,
+		Metadata:  mdBytes,
+	}
+	e.publishLock.Lock()
+	err = e.linkAndSign(ctx, &adv)
+	e.publishLock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if err = provider.ValidateAdvertisement(adv); err != nil {
+		return nil, fmt.Errorf("invalid advertisement: %w", err)
+	}
+	if _, err = adv.VerifySignature(); err != nil {
+		return nil, fmt.Errorf("invalid advertisement signature: %w", err)
+	}
+	adNode, err := adv.ToNode()
+	if err != nil {
+		return nil, err
+	}
+	lnk, err := lsys.Store(ipld.LinkContext{Ctx: ctx}, schema.Linkproto, adNode)
+	if err != nil {
+		return nil, fmt.Errorf("cannot generate advertisement link: %s", err)
+	}
+	res.AdCid = lnk.(cidlink.Link).Cid
+	for _, data := range store.Bag {
+		res.Size += int64(len(data))
+	}
+	log.Infow("Dry run of advertisement", "providerID", p, "contextID", base64.StdEncoding.EncodeToString(contextID),
+		"adCid", res.AdCid, "multihashes", res.MultihashCount, "size", res.Size)
+	return res, nil
+}
@@ -0,0 +1,63 @@
+package engine_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_DryRunPutDoesNotPublish(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	subject, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	t.Cleanup(func() { subject.Shutdown() })
+	mhs := test.RandomMultihashes(5)
+	subject.RegisterMultihashLister(func(_ context.Context, _ peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(mhs), nil
+	})
+
+	md := metadata.Default.New(metadata.Bitswap{})
+	got, err := subject.DryRunPut(ctx, nil, []byte("fish"), md, nil)
+	require.NoError(t, err)
+	require.Equal(t, 5, got.MultihashCount)
+	require.Equal(t, 1, got.Parts)
+	require.NotZero(t, got.Size)
+	latest, _, err := subject.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	require.Equal(t, cid.Undef, latest)
+	_, err = subject.GetContextInfo(ctx, "", []byte("fish"))
+	require.ErrorIs(t, err, provider.ErrContextIDNotFound)
+
+	// The advertisement that is published is the one of the dry run.
+	adCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.NoError(t, err)
+	require.Equal(t, got.AdCid, adCid)
+	ad, err := subject.GetAdv(ctx, adCid)
+	require.NoError(t, err)
+	require.Equal(t, got.Entries.String(), ad.Entries.String())
+
+	_, err = subject.DryRunPut(ctx, nil, []byte("fish"), md, nil)
+	require.ErrorIs(t, err, provider.ErrAlreadyAdvertised)
+	got, err = subject.DryRunPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.IpfsGatewayHttp{}), nil)
+	require.NoError(t, err)
+	require.Equal(t, ad.Entries.String(), got.Entries.String())
+	require.Equal(t, 5, got.MultihashCount)
+
+	// Multihashes can be given instead of listed.
+	got, err = subject.DryRunPut(ctx, nil, []byte("lobster"), md, provider.SliceMultihashIterator(test.RandomMultihashes(3)))
+	require.NoError(t, err)
+	require.Equal(t, 3, got.MultihashCount)
+	latest, _, err = subject.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	require.Equal(t, adCid, latest)
+}
@@ -1,22 +1,32 @@
 package engine
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"os"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
 	dsn "github.com/ipfs/go-datastore/namespace"
+	"github.com/ipfs/go-datastore/query"
 	logging "github.com/ipfs/go-log/v2"
 	"github.com/ipld/go-ipld-prime"
 	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/storage/memstore"
 	"github.com/ipni/go-libipni/announce"
 	"github.com/ipni/go-libipni/announce/httpsender"
 	"github.com/ipni/go-libipni/announce/p2psender"
@@ -26,8 +36,13 @@ import (
 	"github.com/ipni/go-libipni/metadata"
 	provider "github.com/ipni/index-provider"
 	"github.com/ipni/index-provider/engine/chunker"
+	"github.com/ipni/index-provider/metrics"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/multiformats/go-multiaddr"
+	"github.com/multiformats/go-multihash"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -35,14 +50,23 @@ const (
 	cidToKeyMapPrefix            = "map/cidKey/"
 	cidToProviderAndKeyMapPrefix = "map/cidProvAndKey/"
 	keyToMetadataMapPrefix       = "map/keyMD/"
+	keyToInfoMapPrefix           = "map/keyInfo/"
+	cidToReusingKeyMapPrefix     = "map/cidReuse/"
+	keyToPartMapPrefix           = "map/keyPart/"
+	mappingsProviderKey          = "map/provider"
 	latestAdvKey                 = "sync/adv/"
 	linksCachePath               = "/cache/links"
 )
 
+// ErrUnknownEntries signals that the entries to reuse for an advertisement are
+// not currently advertised by the engine.
+var ErrUnknownEntries = errors.New("entries not advertised by engine")
+
 var (
 	log = logging.Logger("provider/engine")
 
-	dsLatestAdvKey = datastore.NewKey(latestAdvKey)
+	dsLatestAdvKey        = datastore.NewKey(latestAdvKey)
+	dsMappingsProviderKey = datastore.NewKey(mappingsProviderKey)
 )
 
 // Engine is an implementation of the core reference provider interface.
@@ -51,14 +75,57 @@ type Engine struct {
 	lsys ipld.LinkSystem
 
 	entriesChunker *chunker.CachedEntriesChunker
-
+	// mappingsID is the identity of the default provider under which the
+	// mappings of its context IDs are stored without provider ID. It differs
+	// from the identity of the default provider once the key is rotated, and
+	// the mappings of context IDs put since are stored with provider ID.
+	// See: Engine.RotateKey.
+	mappingsID peer.ID
+	// memCache caches the entries chunks served to indexers in memory.
+	memCache *memCache
+
+	// publishLock serializes the updates of the head of the advertisement
+	// chain, along with the mappings of context IDs published in it. It is
+	// held until the head is set as the root of the publisher, and released
+	// before announcing it. Updates waiting for it acquire it in the order of
+	// their priority. See: Engine.PublishQueue.
+	publishLock publishQueue
+
+	// idLock guards the identity of the default provider and the key with
+	// which advertisements are signed, which are changed while the engine is
+	// running only by Engine.RotateKey, while holding publishLock. Holding
+	// either is therefore enough to read them. See: Engine.identity.
+	idLock sync.RWMutex
+
+	// publisher is replaced while the engine is running only by
+	// Engine.RotateKey, which holds both publishLock and sendersLock. Holding
+	// either is therefore enough to use it.
 	publisher dagsync.Publisher
-	senders   []announce.Sender
+	// sendersLock guards senders, announceMsg and announceURLs, which may be
+	// changed while the engine is running. See: Engine.SetDirectAnnounce.
+	sendersLock sync.RWMutex
+	senders     []announce.Sender
 	// announceMsg is the message that is logged when an announcement is sent.
 	announceMsg string
 
+	// cblk guards mhLister, which may be registered while the engine is
+	// running. See: Engine.multihashLister.
 	mhLister provider.MultihashLister
 	cblk     sync.Mutex
+
+	stats  engineStats
+	events publishEvents
+	gossip gossip
+	// debouncer defers announcements. See: WithAnnounceDebounce.
+	debouncer announceDebouncer
+	// externalAddrs tracks the external addresses of the host, if enabled.
+	// See: WithExternalRetrievalAddrs.
+	externalAddrs *externalAddrsTracker
+
+	// auditLock serializes the writing of audit records, numbered after
+	// auditSeq, the sequence number of the last one.
+	auditLock sync.Mutex
+	auditSeq  uint64
 }
 
 var _ provider.Interface = (*Engine)(nil)
@@ -86,6 +153,16 @@ var _ provider.Interface = (*Engine)(nil)
 //
 // The engine must be started via Engine.Start before use and discarded via
 // Engine.Shutdown when no longer needed.
+//
+// Once started, and until shut down, the methods of the engine are safe to
+// call from multiple goroutines. Advertisements are published one at a time:
+// Engine.NotifyPut, Engine.NotifyRemove, Engine.Publish and the other methods
+// that publish advertisements wait for one another, so that each
+// advertisement links to the one published before it and the mappings of
+// context IDs are consistent with the advertisement chain. Announcements are
+// sent concurrently, once the advertisement is published. The registered
+// provider.MultihashLister is called while publishing, so it must not itself
+// publish advertisements.
 func New(o ...Option) (*Engine, error) {
 	opts, err := newOptions(o...)
 	if err != nil {
@@ -93,7 +170,9 @@ func New(o ...Option) (*Engine, error) {
 	}
 
 	e := &Engine{
-		options: opts,
+		options:    opts,
+		memCache:   newMemCache(opts.entMemCacheSize),
+		mappingsID: opts.provider.ID,
 	}
 
 	e.lsys = e.mkLinkSystem()
@@ -107,6 +186,10 @@ func New(o ...Option) (*Engine, error) {
 // The context is used to instantiate the internal LRU cache storage. See:
 // Engine.Shutdown, chunker.NewCachedEntriesChunker.
 func (e *Engine) Start(ctx context.Context) error {
+	if e.faults.enabled() {
+		log.Warnw("Injecting faults for testing; must not be used in production", "faults", e.faults)
+	}
+
 	go cleanupDTTempData(ctx, e.ds)
 
 	var err error
@@ -117,6 +200,32 @@ func (e *Engine) Start(ctx context.Context) error {
 		return err
 	}
 
+	if err = e.loadMappingsID(ctx); err != nil {
+		return fmt.Errorf("could not load provider of context ID mappings: %w", err)
+	}
+
+	if err = e.loadMultihashCount(ctx); err != nil {
+		return fmt.Errorf("could not count advertised multihashes: %w", err)
+	}
+
+	if e.auditLog {
+		if err = e.loadAuditSeq(ctx); err != nil {
+			return fmt.Errorf("could not load audit log: %w", err)
+		}
+	}
+
+	if addrs, err := e.adRetrievalAddrs(e.provider.Addrs); err != nil {
+		log.Warnw("Content cannot be advertised with the retrieval addresses of the provider", "err", err)
+	} else if len(addrs) != len(e.provider.Addrs) {
+		log.Infow("Leaving retrieval addresses out of advertisements", "retrievalAddrs", e.provider.Addrs, "advertised", addrs)
+	}
+
+	if e.staticCarDir != "" {
+		if err = os.MkdirAll(e.staticCarDir, 0755); err != nil {
+			return fmt.Errorf("cannot create static CAR directory: %w", err)
+		}
+	}
+
 	e.publisher, err = e.newPublisher(e.pubHttpListenAddr, e.pubHttpHandlerPath)
 	if err != nil {
 		log.Errorw("Failed to create publisher", "err", err)
@@ -133,6 +242,14 @@ func (e *Engine) Start(ctx context.Context) error {
 			e.publisher.SetRoot(adCid)
 		}
 
+		// Join the announce topic with a traced gossipsub mesh, unless a topic
+		// is given.
+		if e.pubsubAnnounce && e.h != nil && e.pubTopic == nil {
+			if err = e.joinGossipTopic(); err != nil {
+				return err
+			}
+		}
+
 		// If publisher created, then create announcement senders.
 		e.senders, err = e.createSenders(e.announceURLs, e.pubsubAnnounce, e.pubsubExtraGossipData)
 		if err != nil {
@@ -140,7 +257,7 @@ func (e *Engine) Start(ctx context.Context) error {
 		}
 	}
 
-	return nil
+	return e.startExternalAddrs()
 }
 
 func (e *Engine) newPublisher(httpListenAddr, httpPath string) (dagsync.Publisher, error) {
@@ -157,6 +274,11 @@ func (e *Engine) newPublisher(httpListenAddr, httpPath string) (dagsync.Publishe
 		if err != nil {
 			return nil, fmt.Errorf("cannot create publisher: %w", err)
 		}
+		for _, addr := range e.pubHttpAnnounceAddrs {
+			if !IsHttpAddr(addr) {
+				return nil, fmt.Errorf("announce address %s is not an HTTP address, as required by the HTTP publisher", addr)
+			}
+		}
 		if len(e.pubHttpAnnounceAddrs) == 0 {
 			e.pubHttpAnnounceAddrs = append(e.pubHttpAnnounceAddrs, httpPub.Addrs()...)
 			log.Warn("HTTP publisher in use without address for announcements. Using publisher listen addresses, but external address may be needed.", "addrs", httpPub.Addrs())
@@ -197,22 +319,16 @@ func (e *Engine) newPublisher(httpListenAddr, httpPath string) (dagsync.Publishe
 
 func (e *Engine) createSenders(announceURLs []*url.URL, pubsubOK bool, extraGossipData []byte) ([]announce.Sender, error) {
 	var senders []announce.Sender
-	var hasHttpSender, hasP2pSender bool
+	var hasP2pSender bool
 
 	// If there are announce URLs, then creage an announce sender to send
 	// direct HTTP announce messages to these URLs.
-	if len(announceURLs) != 0 {
-		id, err := peer.IDFromPrivateKey(e.key)
-		if err != nil {
-			return nil, fmt.Errorf("cannot get peer ID from private key: %w", err)
-		}
-		httpSender, err := httpsender.New(announceURLs, id)
-		if err != nil {
-			return nil, fmt.Errorf("cannot create http announce sender: %w", err)
-		}
+	httpSender, err := e.createHttpSender(announceURLs)
+	if err != nil {
+		return nil, err
+	}
+	if httpSender != nil {
 		senders = append(senders, httpSender)
-		hasHttpSender = true
-		log.Info("HTTP announcements enabled")
 	}
 
 	// If pubsub announcements are enabled and there is a libp2p host, then
@@ -230,30 +346,216 @@ func (e *Engine) createSenders(announceURLs []*url.URL, pubsubOK bool, extraGoss
 		log.Info("Pubsub announcements enabled")
 	}
 
+	e.setAnnounceMsg(httpSender != nil, hasP2pSender)
+	return senders, nil
+}
+
+// createHttpSender creates a sender of direct HTTP announce messages to the
+// given URLs, or returns nil if there are no URLs.
+func (e *Engine) createHttpSender(announceURLs []*url.URL) (*httpsender.Sender, error) {
+	if len(announceURLs) == 0 {
+		return nil, nil
+	}
+	_, key := e.identity()
+	id, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get peer ID from private key: %w", err)
+	}
+	transport := http.DefaultTransport
+	if len(e.announceAuthTokens) != 0 {
+		transport = &announceAuthTransport{tokens: e.announceAuthTokens, base: transport}
+	}
+	httpSender, err := httpsender.New(announceURLs, id, httpsender.WithClient(&http.Client{
+		Timeout:   announceTimeout,
+		Transport: &announceTransport{e: e, base: transport},
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("cannot create http announce sender: %w", err)
+	}
+	log.Info("HTTP announcements enabled")
+	return httpSender, nil
+}
+
+// announceTimeout is the time within which indexers must respond to direct
+// HTTP announcements, as by default for go-libipni announce senders.
+const announceTimeout = time.Minute
+
+// sendTracked sends an announcement via the given sender, and records its
+// outcome for each of the targets of the sender.
+func (e *Engine) sendTracked(ctx context.Context, c cid.Cid, sender announce.Sender, targets []string) error {
+	for _, target := range targets {
+		e.stats.targetStarted(target)
+	}
+	start := time.Now()
+	var err error
+	if injectFault(e.faults.AnnounceFailureRate) {
+		err = fmt.Errorf("cannot send announcement: %w", ErrInjectedFault)
+	} else {
+		err = announce.Send(ctx, c, e.pubHttpAnnounceAddrs, sender)
+	}
+	latency := time.Since(start)
+	for _, target := range targets {
+		e.stats.targetAnnounced(ctx, target, latency, err)
+	}
+	return err
+}
+
+// announceTransport records the outcome of each direct HTTP announcement
+// per indexer URL, and injects the announce failures set via WithFaults.
+type announceTransport struct {
+	e    *Engine
+	base http.RoundTripper
+}
+
+func (t *announceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	target := req.URL.String()
+	t.e.stats.targetStarted(target)
+	start := time.Now()
+	var resp *http.Response
+	var err error
+	if injectFault(t.e.faults.AnnounceFailureRate) {
+		err = fmt.Errorf("cannot send announcement: %w", ErrInjectedFault)
+	} else {
+		resp, err = t.base.RoundTrip(req)
+	}
+	failure := err
+	if err == nil && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		failure = fmt.Errorf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	t.e.stats.targetAnnounced(req.Context(), target, time.Since(start), failure)
+	return resp, err
+}
+
+// announceAuthTransport authenticates direct HTTP announcements with the
+// bearer token of the indexer they are sent to, if any. See:
+// WithAnnounceAuthTokens.
+type announceAuthTransport struct {
+	tokens map[string]string
+	base   http.RoundTripper
+}
+
+func (t *announceAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, ok := t.tokens[urlOrigin(req.URL)]
+	if !ok {
+		return t.base.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return t.base.RoundTrip(req)
+}
+
+// urlOrigin returns the scheme and host of the given URL, by which
+// announcement tokens are looked up.
+func urlOrigin(u *url.URL) string {
+	return strings.ToLower(u.Scheme) + "://" + strings.ToLower(u.Host)
+}
+
+func (e *Engine) setAnnounceMsg(hasHttpSender, hasP2pSender bool) {
 	if hasHttpSender && hasP2pSender {
 		e.announceMsg = "Announcing advertisement in pubsub channel and via http"
 	} else if hasHttpSender {
 		e.announceMsg = "Announcing advertisement via http"
 	} else if hasP2pSender {
 		e.announceMsg = "Announcing advertisement in pubsub channel"
-	} else {
+	} else if len(e.extraSenders) == 0 {
 		e.announceMsg = "Cannot announce advertisement, no http or pubsub senders configured"
+		return
+	} else {
+		e.announceMsg = "Announcing advertisement via custom senders"
+		return
+	}
+	if len(e.extraSenders) != 0 {
+		e.announceMsg += " and via custom senders"
 	}
+}
 
-	return senders, nil
+// SetDirectAnnounce replaces the indexer URLs that direct HTTP announce
+// messages are sent to, while the engine is running. Announcements are no
+// longer sent directly over HTTP if no URLs are given. Announcements over
+// gossip pubsub are unaffected.
+func (e *Engine) SetDirectAnnounce(announceURLs ...string) error {
+	urls := make([]*url.URL, 0, len(announceURLs))
+	for _, urlStr := range announceURLs {
+		u, err := url.Parse(urlStr)
+		if err != nil {
+			return err
+		}
+		urls = append(urls, u)
+	}
+
+	e.sendersLock.Lock()
+	defer e.sendersLock.Unlock()
+	e.announceURLs = urls
+	if e.publisher == nil {
+		return nil
+	}
+	httpSender, err := e.createHttpSender(urls)
+	if err != nil {
+		return err
+	}
+	senders := make([]announce.Sender, 0, len(e.senders)+1)
+	if httpSender != nil {
+		senders = append(senders, httpSender)
+	}
+	var hasP2pSender bool
+	for _, sender := range e.senders {
+		if _, ok := sender.(*httpsender.Sender); ok {
+			if err = sender.Close(); err != nil {
+				log.Errorw("Failed to close announce sender", "err", err)
+			}
+			continue
+		}
+		senders = append(senders, sender)
+		hasP2pSender = true
+	}
+	e.senders = senders
+	e.setAnnounceMsg(httpSender != nil, hasP2pSender)
+	log.Infow("Direct HTTP announce URLs changed", "urls", urls)
+	return nil
 }
 
-// announce uses the engines senders to send advertisement announcement messages.
-func (e *Engine) announce(ctx context.Context, c cid.Cid) {
+// announce uses the engines senders to send advertisement announcement
+// messages, and returns the outcome of sending them via each sender.
+func (e *Engine) announce(ctx context.Context, c cid.Cid) []AnnounceResult {
 	// If announcements disabled.
 	if e.pubKind == NoPublisher {
-		return
+		return nil
 	}
 
-	err := announce.Send(ctx, c, e.pubHttpAnnounceAddrs, e.senders...)
+	e.sendersLock.RLock()
+	senders := append(e.senders[:len(e.senders):len(e.senders)], e.extraSenders...)
+	announceURLs := e.announceURLs
+	e.sendersLock.RUnlock()
+
+	ctx, span := metrics.Tracer.Start(ctx, "engine.Announce", trace.WithAttributes(attribute.Stringer("adCid", c)))
+	id := e.stats.announceStarted()
+	results := make([]AnnounceResult, 0, len(senders))
+	var err error
+	for _, sender := range senders {
+		result := AnnounceResult{Targets: announceTargets(sender, announceURLs)}
+		var sendErr error
+		if _, ok := sender.(*httpsender.Sender); ok {
+			// Direct HTTP announcements are tracked per indexer URL by
+			// announceTransport.
+			sendErr = announce.Send(ctx, c, e.pubHttpAnnounceAddrs, sender)
+		} else {
+			sendErr = e.sendTracked(ctx, c, sender, result.Targets)
+		}
+		if sendErr != nil {
+			result.Err = sendErr.Error()
+			err = multierror.Append(err, sendErr)
+		} else if _, ok := sender.(*p2psender.Sender); ok {
+			e.gossip.propagated(c, e.pubTopic)
+		}
+		results = append(results, result)
+	}
+	e.stats.announced(ctx, id, err)
+	metrics.EndSpan(span, err)
+	e.events.emit(PublishEvent{Kind: AdAnnounced, AdCid: c, Time: time.Now(), Err: err})
 	if err != nil {
 		log.Errorw("Failed to announce advertisement", "err", err)
 	}
+	return results
 }
 
 // PublishLocal stores the advertisement in the local link system and marks it
@@ -264,9 +566,29 @@ func (e *Engine) announce(ctx context.Context, c cid.Cid) {
 //
 // See: Engine.Publish.
 func (e *Engine) PublishLocal(ctx context.Context, adv schema.Advertisement) (cid.Cid, error) {
-	if err := adv.Validate(); err != nil {
+	e.publishLock.Lock()
+	c, err := e.publishLocal(ctx, adv, 0, nil)
+	e.publishLock.Unlock()
+	if err != nil {
 		return cid.Undef, err
 	}
+	e.audit(ctx, c, &adv, -1, 0, nil)
+	return c, nil
+}
+
+// publishLocal stores the given advertisement and updates the reference to the
+// latest advertisement, which is written in the given batch if not nil. The
+// batch is committed once the advertisement is stored, so that any other
+// writes in it take effect atomically with the reference update. The number of
+// multihashes skipped by the lister is reported along with the advertisement
+// being published. The caller must hold publishLock.
+func (e *Engine) publishLocal(ctx context.Context, adv schema.Advertisement, skipped int, batch datastore.Batch) (_ cid.Cid, err error) {
+	ctx, span := metrics.Tracer.Start(ctx, "engine.PublishLocal")
+	defer func() { metrics.EndSpan(span, err) }()
+
+	if err = provider.ValidateAdvertisement(adv); err != nil {
+		return cid.Undef, fmt.Errorf("invalid advertisement: %w", err)
+	}
 
 	adNode, err := adv.ToNode()
 	if err != nil {
@@ -278,14 +600,36 @@ func (e *Engine) PublishLocal(ctx context.Context, adv schema.Advertisement) (ci
 		return cid.Undef, fmt.Errorf("cannot generate advertisement link: %s", err)
 	}
 	c := lnk.(cidlink.Link).Cid
+	span.SetAttributes(attribute.Stringer("adCid", c))
 	log := log.With("adCid", c)
 	log.Info("Stored ad in local link system")
 
-	if err = e.putLatestAdv(ctx, c.Bytes()); err != nil {
+	if batch == nil {
+		if batch, err = e.ds.Batch(ctx); err != nil {
+			return cid.Undef, fmt.Errorf("cannot create datastore batch: %w", err)
+		}
+	}
+	now := time.Now()
+	if err = e.putAdTime(ctx, batch, c, now); err == nil {
+		if err = e.putLatestAdv(ctx, batch, c.Bytes()); err == nil {
+			err = batch.Commit(ctx)
+		}
+	}
+	if err != nil {
 		log.Errorw("Failed to update reference to the latest advertisement", "err", err)
 		return cid.Undef, fmt.Errorf("failed to update reference to latest advertisement: %w", err)
 	}
 	log.Info("Updated reference to the latest advertisement successfully")
+	e.stats.lastPublished.Store(now.UnixNano())
+	update := isMetadataUpdate(ctx)
+	e.events.emit(PublishEvent{Kind: AdPublished, AdCid: c, IsRm: adv.IsRm, IsUpdate: update, Skipped: skipped, Time: now})
+	adKind := metrics.Attributes.AdKindPut
+	if adv.IsRm {
+		adKind = metrics.Attributes.AdKindRemove
+	} else if update {
+		adKind = metrics.Attributes.AdKindUpdate
+	}
+	metrics.Engine.AdsPublished.Add(ctx, 1, metric.WithAttributeSet(attribute.NewSet(adKind)))
 	return c, nil
 }
 
@@ -297,23 +641,61 @@ func (e *Engine) PublishLocal(ctx context.Context, adv schema.Advertisement) (ci
 // The publication mechanism uses dagsync.Publisher internally.
 // See: https://github.com/ipni/go-libipni/tree/main/dagsync
 func (e *Engine) Publish(ctx context.Context, adv schema.Advertisement) (cid.Cid, error) {
-	c, err := e.PublishLocal(ctx, adv)
+	e.publishLock.Lock()
+	return e.publish(ctx, adv, -1, 0, nil, e.publishLock.Unlock)
+}
+
+// publish publishes the given advertisement of mhCount multihashes, or of an
+// unknown number of multihashes if mhCount is -1, listed with skipped
+// multihashes skipped. Writes in the given batch, if not nil, are committed
+// along with the reference to the advertisement. See: Engine.Publish.
+//
+// The caller must hold publishLock, which is released by calling unlock once
+// the advertisement is set as the root of the publisher, before it is
+// announced.
+func (e *Engine) publish(ctx context.Context, adv schema.Advertisement, mhCount, skipped int, batch datastore.Batch, unlock func()) (_ cid.Cid, err error) {
+	unlock = sync.OnceFunc(unlock)
+	defer unlock()
+
+	ctx, span := metrics.Tracer.Start(ctx, "engine.Publish")
+	defer func() { metrics.EndSpan(span, err) }()
+
+	c, err := e.publishLocal(ctx, adv, skipped, batch)
 	if err != nil {
 		log.Errorw("Failed to store advertisement locally", "err", err)
 		return cid.Undef, fmt.Errorf("failed to publish advertisement locally: %w", err)
 	}
+	e.writeStatic(ctx)
 
 	// Only announce the advertisement CID if publisher is configured.
+	var announces []AnnounceResult
 	if e.publisher != nil {
+		e.sendersLock.RLock()
 		log.Infow(e.announceMsg, "adCid", c)
+		e.sendersLock.RUnlock()
 		e.publisher.SetRoot(c)
-		e.announce(ctx, c)
+		unlock()
+		if isAnnounceHeld(ctx) {
+			log.Debugw("Held announcement", "adCid", c)
+		} else if e.announceQuiet > 0 {
+			e.deferAnnounce(c)
+		} else {
+			announces = e.announce(ctx, c)
+		}
 	}
+	unlock()
+	e.audit(ctx, c, &adv, mhCount, skipped, announces)
 
 	return c, nil
 }
 
-func (e *Engine) latestAdToPublish(ctx context.Context) (cid.Cid, error) {
+// setLatestAdAsRoot sets the latest advertisement as the root of the
+// publisher, and returns its CID to announce. cid.Undef is returned if there
+// is nothing to announce.
+func (e *Engine) setLatestAdAsRoot(ctx context.Context) (cid.Cid, error) {
+	e.publishLock.Lock()
+	defer e.publishLock.Unlock()
+
 	// Skip announcing the latest advertisement CID if there is no publisher.
 	if e.publisher == nil {
 		log.Infow("Skipped announcing the latest: remote announcements are disabled.")
@@ -330,19 +712,20 @@ func (e *Engine) latestAdToPublish(ctx context.Context) (cid.Cid, error) {
 		return cid.Undef, nil
 	}
 
+	e.publisher.SetRoot(adCid)
 	return adCid, nil
 }
 
 // PublishLatest re-publishes the latest existing advertisement and send
 // announcements using the engine's configured senders.
 func (e *Engine) PublishLatest(ctx context.Context) (cid.Cid, error) {
-	adCid, err := e.latestAdToPublish(ctx)
-	if err != nil {
+	adCid, err := e.setLatestAdAsRoot(ctx)
+	if err != nil || adCid == cid.Undef {
 		return cid.Undef, err
 	}
 	log.Infow("Publishing latest advertisement", "cid", adCid)
 
-	e.publisher.SetRoot(adCid)
+	e.dropPendingAnnounce()
 	e.announce(ctx, adCid)
 
 	return adCid, nil
@@ -351,12 +734,11 @@ func (e *Engine) PublishLatest(ctx context.Context) (cid.Cid, error) {
 // PublishLatestHTTP publishes the latest existing advertisement and sends
 // direct HTTP announcements to the specified URLs.
 func (e *Engine) PublishLatestHTTP(ctx context.Context, announceURLs ...*url.URL) (cid.Cid, error) {
-	adCid, err := e.latestAdToPublish(ctx)
-	if err != nil {
+	adCid, err := e.setLatestAdAsRoot(ctx)
+	if err != nil || adCid == cid.Undef {
 		return cid.Undef, err
 	}
 
-	e.publisher.SetRoot(adCid)
 	err = e.httpAnnounce(ctx, adCid, announceURLs)
 	if err != nil {
 		return adCid, err
@@ -375,19 +757,28 @@ func (e *Engine) httpAnnounce(ctx context.Context, adCid cid.Cid, announceURLs [
 	if len(announceURLs) == 0 {
 		return nil
 	}
-	if e.publisher == nil {
+	if e.pubKind == NoPublisher {
 		log.Info("No publisher to announce advertisements for")
 		return nil
 	}
 
-	// Create the http announce sender.
-	httpSender, err := httpsender.New(announceURLs, e.h.ID())
+	// Create the http announce sender. The provider ID is taken from the
+	// engine key, as for the engine's own senders, since an engine that only
+	// publishes over HTTP need not have a libp2p host.
+	httpSender, err := e.createHttpSender(announceURLs)
 	if err != nil {
-		return fmt.Errorf("cannot create http announce sender: %w", err)
+		return err
 	}
+	defer httpSender.Close()
 
 	log.Infow("Announcing advertisements over HTTP", "urls", announceURLs)
-	return announce.Send(ctx, adCid, e.pubHttpAnnounceAddrs, httpSender)
+	ctx, span := metrics.Tracer.Start(ctx, "engine.Announce", trace.WithAttributes(attribute.Stringer("adCid", adCid)))
+	id := e.stats.announceStarted()
+	err = announce.Send(ctx, adCid, e.pubHttpAnnounceAddrs, httpSender)
+	e.stats.announced(ctx, id, err)
+	metrics.EndSpan(span, err)
+	e.events.emit(PublishEvent{Kind: AdAnnounced, AdCid: adCid, Time: time.Now(), Err: err})
+	return err
 }
 
 // RegisterMultihashLister registers a provider.MultihashLister that is used to
@@ -406,6 +797,14 @@ func (e *Engine) RegisterMultihashLister(mhl provider.MultihashLister) {
 	e.mhLister = mhl
 }
 
+// multihashLister returns the registered provider.MultihashLister, or nil if
+// none is registered.
+func (e *Engine) multihashLister() provider.MultihashLister {
+	e.cblk.Lock()
+	defer e.cblk.Unlock()
+	return e.mhLister
+}
+
 // NotifyPut publishes an advertisement that signals the list of multihashes
 // associated to the given contextID is available by this provider with the
 // given metadata. A provider.MultihashLister is required, and is used to look
@@ -414,21 +813,61 @@ func (e *Engine) RegisterMultihashLister(mhl provider.MultihashLister) {
 // Note that prior to calling this function a provider.MultihashLister must be
 // registered.
 //
+// Advertisements that exceed the limits accepted by indexers are rejected
+// before the multihashes are listed, with an error wrapping
+// provider.ErrContextIDTooLong, provider.ErrMetadataTooLong or
+// provider.ErrTooManyAddrs. See: provider.ValidatePut. Duplicate retrieval
+// addresses are left out of the advertisement beforehand, as are those left
+// out by WithPrivateRetrievalAddrs and WithMaxRetrievalAddrs.
+//
+// Content of more multihashes than set via WithMaxAdMultihashes is split
+// across several advertisements, in which case the CID of the last one is
+// returned.
+//
 // See: Engine.RegisterMultihashLister, Engine.Publish.
 func (e *Engine) NotifyPut(ctx context.Context, provider *peer.AddrInfo, contextID []byte, md metadata.Metadata) (cid.Cid, error) {
 	// The multihash lister must have been registered for the linkSystem to
 	// know how to go from contextID to list of CIDs.
-	pID := e.options.provider.ID
-	addrs := e.options.provider.Addrs
+	var pID peer.ID
+	var addrs []multiaddr.Multiaddr
 	if provider != nil {
 		pID = provider.ID
 		addrs = provider.Addrs
 	}
-	return e.publishAdvForIndex(ctx, pID, addrs, contextID, md, false)
+	return e.publishAdvForIndex(ctx, pID, addrs, contextID, md, cid.Undef, opPut)
+}
+
+// NotifyPutWithEntries is like NotifyPut, except that the advertisement links
+// to the given entries, which are currently advertised under another context
+// ID, instead of entries generated from the multihashes listed by the
+// provider.MultihashLister. This allows content that is already advertised to
+// be advertised under a new context ID without listing its multihashes again.
+// ErrUnknownEntries is returned if the entries are not currently advertised.
+//
+// If the context ID is already advertised, the given entries are ignored and
+// only the metadata is updated, as with NotifyPut. The multihashes of reused
+// entries are listed under the context ID they were first advertised under, or
+// under a context ID reusing them once that context ID is removed.
+//
+// See: Engine.GetContextInfo.
+func (e *Engine) NotifyPutWithEntries(ctx context.Context, provider *peer.AddrInfo, contextID []byte, md metadata.Metadata, entries cid.Cid) (cid.Cid, error) {
+	if entries == cid.Undef {
+		return cid.Undef, ErrUnknownEntries
+	}
+	var pID peer.ID
+	var addrs []multiaddr.Multiaddr
+	if provider != nil {
+		pID = provider.ID
+		addrs = provider.Addrs
+	}
+	return e.publishAdvForIndex(ctx, pID, addrs, contextID, md, entries, opPut)
 }
 
 // NotifyRemove publishes an advertisement that signals the list of multihashes
 // associated to the given contextID is no longer available by this provider.
+// Content split across several advertisements is removed by publishing a
+// removal advertisement for each part, and the CID of the last one is
+// returned.
 //
 // Note that prior to calling this function a provider.MultihashLister must be
 // registered.
@@ -436,10 +875,191 @@ func (e *Engine) NotifyPut(ctx context.Context, provider *peer.AddrInfo, context
 // See: Engine.RegisterMultihashLister, Engine.Publish.
 func (e *Engine) NotifyRemove(ctx context.Context, provider peer.ID, contextID []byte) (cid.Cid, error) {
 	// TODO: add support for "delete all" for provider
+	return e.publishAdvForIndex(ctx, provider, nil, contextID, metadata.Metadata{}, cid.Undef, opRemove)
+}
+
+// UpdateMetadata publishes an advertisement that replaces the metadata of the
+// content already advertised under the given context ID, and links to the
+// same entries, without listing its multihashes. Unlike NotifyPut, it never
+// advertises new content: provider.ErrContextIDNotFound is returned if the
+// context ID is not advertised, and provider.ErrAlreadyAdvertised if it is
+// already advertised with the same metadata. If provider is nil, the default
+// provider is assumed.
+//
+// The advertisement is published with metadata priority, and reported as a
+// metadata update by PublishEvent.IsUpdate and AuditRecord.IsUpdate, as are
+// metadata updates made via NotifyPut. Split content is updated by publishing
+// an advertisement for each part, and the CID of the last one is returned.
+func (e *Engine) UpdateMetadata(ctx context.Context, provider *peer.AddrInfo, contextID []byte, md metadata.Metadata) (cid.Cid, error) {
+	var pID peer.ID
+	var addrs []multiaddr.Multiaddr
+	if provider != nil {
+		pID = provider.ID
+		addrs = provider.Addrs
+	}
+	return e.publishAdvForIndex(ctx, pID, addrs, contextID, md, cid.Undef, opUpdate)
+}
+
+// ListContextIDs lists the context IDs currently advertised by the given
+// provider, i.e. the context IDs that have been put and not since removed. If
+// provider is empty, the default provider is assumed. The returned context IDs
+// are sorted in ascending byte order.
+//
+// Note that context IDs are looked up via the CID of their advertised entries.
+// Therefore, only one of several context IDs advertised with identical entries
+// is listed.
+func (e *Engine) ListContextIDs(ctx context.Context, provider peer.ID) ([][]byte, error) {
 	if provider == "" {
-		provider = e.options.provider.ID
+		provider, _ = e.identity()
+	}
+
+	var contextIDs [][]byte
+	seen := make(map[string]struct{})
+	add := func(p peer.ID, contextID []byte, entries string) error {
+		if p != provider {
+			return nil
+		}
+		if _, ok := seen[string(contextID)]; ok {
+			return nil
+		}
+		// Skip stale mappings by checking the context ID still maps to the
+		// same entries.
+		c, err := e.getKeyCidMap(ctx, p, contextID)
+		if err != nil {
+			if errors.Is(err, datastore.ErrNotFound) {
+				return nil
+			}
+			return err
+		}
+		if c.String() != entries {
+			return nil
+		}
+		// Parts of split content are listed as the context ID that is split.
+		derived, err := e.isDerivedPart(ctx, p, contextID)
+		if err != nil || derived {
+			return err
+		}
+		seen[string(contextID)] = struct{}{}
+		contextIDs = append(contextIDs, contextID)
+		return nil
 	}
-	return e.publishAdvForIndex(ctx, provider, nil, contextID, metadata.Metadata{}, true)
+
+	// Look up the legacy index first, in which all context IDs belong to the
+	// identity under which mappings are stored without provider ID.
+	results, err := e.ds.Query(ctx, query.Query{Prefix: cidToKeyMapPrefix})
+	if err != nil {
+		return nil, err
+	}
+	for r := range results.Next() {
+		if r.Error != nil {
+			results.Close()
+			return nil, r.Error
+		}
+		entries := datastore.RawKey(r.Key).BaseNamespace()
+		if err = add(e.mappingsID, r.Value, entries); err != nil {
+			results.Close()
+			return nil, err
+		}
+	}
+	results.Close()
+
+	results, err = e.ds.Query(ctx, query.Query{Prefix: cidToProviderAndKeyMapPrefix})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		var pAndC providerAndContext
+		if err = json.Unmarshal(r.Value, &pAndC); err != nil {
+			return nil, err
+		}
+		p := e.mappingsID
+		if len(pAndC.Provider) != 0 {
+			if p, err = peer.IDFromBytes(pAndC.Provider); err != nil {
+				return nil, err
+			}
+		}
+		entries := datastore.RawKey(r.Key).BaseNamespace()
+		if err = add(p, pAndC.ContextID, entries); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(contextIDs, func(i, j int) bool {
+		return bytes.Compare(contextIDs[i], contextIDs[j]) < 0
+	})
+	return contextIDs, nil
+}
+
+// ContextInfo describes the content currently advertised under a context ID.
+type ContextInfo struct {
+	// ContextID is the context ID under which the content is advertised.
+	ContextID []byte
+	// Provider is the provider of the content.
+	Provider peer.ID
+	// Entries is the CID of the advertised entries, or schema.NoEntries if
+	// the content has no multihashes.
+	Entries cid.Cid
+	// Metadata is the metadata with which the content was last advertised.
+	Metadata metadata.Metadata
+	// MultihashCount is the number of advertised multihashes, or -1 if
+	// unknown, e.g. for content advertised by earlier versions of the engine.
+	// Only the multihashes of the first part of split content are counted.
+	MultihashCount int
+	// Parts is the number of advertisements the content is split across,
+	// which is 1 unless the content is split. See: WithMaxAdMultihashes.
+	Parts int
+	// PublishedAt is the time at which the content was last advertised, or
+	// zero time if unknown.
+	PublishedAt time.Time
+}
+
+// GetContextInfo gets information about the content currently advertised by
+// the given provider ID under the given context ID. If providerID is empty, the
+// default provider is assumed. provider.ErrContextIDNotFound is returned if
+// there is no content advertised under the context ID.
+//
+// See: Engine.ListContextIDs.
+func (e *Engine) GetContextInfo(ctx context.Context, providerID peer.ID, contextID []byte) (*ContextInfo, error) {
+	if providerID == "" {
+		providerID, _ = e.identity()
+	}
+	c, err := e.getKeyCidMap(ctx, providerID, contextID)
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return nil, provider.ErrContextIDNotFound
+		}
+		return nil, err
+	}
+	md, err := e.getKeyMetadataMap(ctx, providerID, contextID)
+	if err != nil && !errors.Is(err, datastore.ErrNotFound) {
+		return nil, err
+	}
+	info, err := e.getKeyInfoMap(ctx, providerID, contextID)
+	if err != nil {
+		return nil, err
+	}
+	ci := &ContextInfo{
+		ContextID:      contextID,
+		Provider:       providerID,
+		Entries:        c,
+		Metadata:       md,
+		MultihashCount: info.MultihashCount,
+		Parts:          max(info.Parts, 1),
+	}
+	if info.PublishedAt != 0 {
+		ci.PublishedAt = time.Unix(0, info.PublishedAt)
+	}
+	return ci, nil
+}
+
+// MetadataContext returns the context with which the engine decodes metadata,
+// which includes the protocols registered via WithMetadataProtocol.
+func (e *Engine) MetadataContext() metadata.MetadataContext {
+	return e.metadataContext
 }
 
 // LinkSystem gets the link system used by the engine to store and retrieve
@@ -452,12 +1072,27 @@ func (e *Engine) LinkSystem() *ipld.LinkSystem {
 // engine. The engine is no longer usable after the call to this function.
 func (e *Engine) Shutdown() error {
 	var err, errs error
+	if err = e.stopExternalAddrs(); err != nil {
+		errs = multierror.Append(errs, fmt.Errorf("error closing subscription to host addresses: %s", err))
+	}
 	if e.publisher != nil {
+		// Send the announcement deferred by debouncing, if any.
+		if c := e.closeDebouncer(); c != cid.Undef {
+			e.announce(context.Background(), c)
+		}
 		for i := range e.senders {
 			if err = e.senders[i].Close(); err != nil {
 				errs = multierror.Append(errs, fmt.Errorf("error closing sender: %s", err))
 			}
 		}
+		for i := range e.extraSenders {
+			if err = e.extraSenders[i].Close(); err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("error closing sender: %s", err))
+			}
+		}
+		if err = e.leaveGossipTopic(); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("error leaving announce topic: %s", err))
+		}
 		if err = e.publisher.Close(); err != nil {
 			errs = multierror.Append(errs, fmt.Errorf("error closing leg publisher: %s", err))
 		}
@@ -469,25 +1104,51 @@ func (e *Engine) Shutdown() error {
 		}
 		e.entriesChunker = nil
 	}
+	if e.ownHost && e.h != nil {
+		if err = e.h.Close(); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("error closing libp2p host: %s", err))
+		}
+	}
+	e.events.close()
 	return errs
 }
 
 // GetPublisherHttpFunc gets the http.HandlerFunc that can be used to serve
 // advertisements over HTTP. The returned handler is only valid if the
 // PublisherKind is HttpPublisher and the HttpPublisherWithoutServer option is
-// set.
+// set. It serves each request with the current publisher of the engine, which
+// is replaced when the key is rotated. See: Engine.RotateKey.
 func (e *Engine) GetPublisherHttpFunc() (http.HandlerFunc, error) {
-	if e.publisher == nil {
+	if _, err := e.httpPublisher(); err != nil {
+		return nil, err
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		hp, err := e.httpPublisher()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		hp.ServeHTTP(w, r)
+	}, nil
+}
+
+// httpPublisher returns the current publisher of the engine, if it is an HTTP
+// publisher whose server is not started by the engine.
+func (e *Engine) httpPublisher() (*ipnisync.Publisher, error) {
+	e.sendersLock.RLock()
+	publisher := e.publisher
+	e.sendersLock.RUnlock()
+	if publisher == nil {
 		return nil, errors.New("no publisher configured")
 	}
 	if !e.pubHttpWithoutServer {
 		return nil, errors.New("HttpPublisherWithoutServer option not set")
 	}
-	hp, ok := e.publisher.(*ipnisync.Publisher)
+	hp, ok := publisher.(*ipnisync.Publisher)
 	if !ok {
 		return nil, errors.New("publisher is not an http publisher")
 	}
-	return hp.ServeHTTP, nil
+	return hp, nil
 }
 
 // GetAdv gets the advertisement associated to the given cid c. The context is
@@ -524,9 +1185,100 @@ func (e *Engine) GetLatestAdv(ctx context.Context) (cid.Cid, *schema.Advertiseme
 	return latestAdCid, ad, nil
 }
 
-func (e *Engine) publishAdvForIndex(ctx context.Context, p peer.ID, addrs []multiaddr.Multiaddr, contextID []byte, md metadata.Metadata, isRm bool) (cid.Cid, error) {
-	var err error
+// queuedPublish describes the publish of an advertisement of the given
+// context ID of provider p in the publish queue. Removals are queued ahead of
+// new context IDs, which are queued ahead of updates of the metadata of
+// advertised context IDs, unless the context sets the priority. See:
+// ContextWithPublishPriority.
+func (e *Engine) queuedPublish(ctx context.Context, p peer.ID, contextID []byte, isRm bool) QueuedPublish {
+	if p == "" {
+		p, _ = e.identity()
+	}
+	pub := QueuedPublish{
+		Kind:      "put",
+		Priority:  PriorityNewData,
+		Provider:  p,
+		ContextID: contextID,
+	}
+	if isRm {
+		pub.Kind = "remove"
+		pub.Priority = PriorityRemoval
+	} else if _, err := e.getKeyCidMap(ctx, p, contextID); err == nil {
+		pub.Kind = "update"
+		pub.Priority = PriorityMetadata
+	}
+	if priority, ok := ctx.Value(publishPriorityKey{}).(PublishPriority); ok {
+		pub.Priority = priority
+	}
+	return pub
+}
+
+// publishOp is the operation by which publishAdvForIndex publishes an
+// advertisement.
+type publishOp int
+
+const (
+	// opPut advertises content, or updates its metadata if already
+	// advertised.
+	opPut publishOp = iota
+	// opUpdate only updates the metadata of advertised content.
+	opUpdate
+	// opRemove advertises the removal of content.
+	opRemove
+)
+
+// metadataUpdateKey marks the context of publishing an advertisement that
+// only updates the metadata of advertised content.
+type metadataUpdateKey struct{}
+
+func isMetadataUpdate(ctx context.Context) bool {
+	update, _ := ctx.Value(metadataUpdateKey{}).(bool)
+	return update
+}
+
+// publishAdvForIndex publishes an advertisement of the given context ID of
+// provider p with the given addresses by the given operation. If p is empty,
+// the default provider and its addresses are assumed.
+func (e *Engine) publishAdvForIndex(ctx context.Context, p peer.ID, addrs []multiaddr.Multiaddr, contextID []byte, md metadata.Metadata, entries cid.Cid, op publishOp) (_ cid.Cid, err error) {
 	var cidsLnk cidlink.Link
+	mhCount := -1
+	isRm := op == opRemove
+
+	if err = e.publishLock.acquire(ctx, e.queuedPublish(ctx, p, contextID, isRm)); err != nil {
+		return cid.Undef, err
+	}
+	unlock := sync.OnceFunc(e.publishLock.Unlock)
+	defer unlock()
+	if p == "" {
+		p = e.options.provider.ID
+		if !isRm {
+			addrs = e.options.provider.Addrs
+		}
+	}
+	if !isRm {
+		if addrs, err = e.adRetrievalAddrs(addrs); err != nil {
+			return cid.Undef, err
+		}
+	}
+	// Reject advertisements that indexers would reject before listing their
+	// multihashes, and before they are put into the chain. Removals have
+	// neither metadata nor addresses.
+	if err = provider.ValidatePut(contextID, md, addrs); err != nil {
+		return cid.Undef, err
+	}
+
+	spanName := "engine.NotifyPut"
+	switch op {
+	case opUpdate:
+		spanName = "engine.UpdateMetadata"
+	case opRemove:
+		spanName = "engine.NotifyRemove"
+	}
+	ctx, span := metrics.Tracer.Start(ctx, spanName, trace.WithAttributes(
+		attribute.Stringer("providerID", p),
+		attribute.String("contextID", base64.StdEncoding.EncodeToString(contextID)),
+	))
+	defer func() { metrics.EndSpan(span, err) }()
 
 	log := log.With("providerID", p).With("contextID", base64.StdEncoding.EncodeToString(contextID))
 
@@ -536,30 +1288,92 @@ func (e *Engine) publishAdvForIndex(ctx context.Context, p peer.ID, addrs []mult
 			return cid.Undef, fmt.Errorf("cound not not get entries cid by provider + context id: %s", err)
 		}
 	}
+	derived, err := e.isDerivedPart(ctx, p, contextID)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("could not get part by provider + context id: %s", err)
+	}
+	if derived {
+		return cid.Undef, fmt.Errorf("context ID is part of split content; update or remove the context ID that is split instead")
+	}
+
+	// The mappings of the context ID are written in a batch that is committed
+	// along with the reference to the new advertisement. This way they are
+	// written atomically and with far fewer syncs, and are not left behind if
+	// the advertisement fails to be published. Note that reads are not
+	// affected by the batch until it is committed.
+	batch, err := e.ds.Batch(ctx)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("cannot create datastore batch: %w", err)
+	}
+	// mhDelta is the change in the number of advertised multihashes, applied
+	// to stats once published.
+	var mhDelta int64
+	// parts are the advertisements of the parts of split content that follow
+	// the advertisement of the context ID, and nParts the number of parts.
+	var parts []partAd
+	var nParts int
+	// skipped is the number of multihashes that the lister failed to list.
+	var skipped int
 
 	// If not removing, then generate the link for the list of CIDs from the
 	// contextID using the multihash lister, and store the relationship.
 	if !isRm {
 		log.Info("Creating advertisement")
 
+		if err = e.checkMetadata(md); err != nil {
+			return cid.Undef, err
+		}
+		if c == cid.Undef && op == opUpdate {
+			return cid.Undef, provider.ErrContextIDNotFound
+		}
+
 		// If no previously-published ad for this context ID.
-		if c == cid.Undef {
-			log.Info("Generating entries linked list for advertisement")
-			// If no lister registered return error.
-			if e.mhLister == nil {
-				return cid.Undef, provider.ErrNoMultihashLister
+		if c == cid.Undef && entries != cid.Undef {
+			log.Infow("Reusing entries linked list for advertisement", "entries", entries)
+			cidsLnk = cidlink.Link{Cid: entries}
+			if mhCount, err = e.reuseEntries(ctx, batch, p, contextID, entries); err != nil {
+				return cid.Undef, err
 			}
-
+		} else if c == cid.Undef {
+			log.Info("Generating entries linked list for advertisement")
 			// Call the lister.
-			mhIter, err := e.mhLister(ctx, p, contextID)
+			listCtx, listSpan := metrics.Tracer.Start(ctx, "engine.ListMultihashes")
+			var skippedCount atomic.Int64
+			mhIter, err := e.listMultihashes(listCtx, p, contextID, &skippedCount)
+			metrics.EndSpan(listSpan, err)
 			if err != nil {
 				return cid.Undef, err
 			}
+			defer closeMultihashIterator(mhIter)
+			countingIter := &countingMultihashIterator{MultihashIterator: mhIter}
 			// Generate the linked list ipld.Link that is added to the
-			// advertisement and used for ingestion.
-			lnk, err := e.entriesChunker.Chunk(ctx, mhIter)
+			// advertisement and used for ingestion. The multihashes are
+			// iterated over while chunking, so the time taken by the lister
+			// to iterate over them counts towards chunking.
+			var entriesChunker chunker.EntriesChunker = e.entriesChunker
+			if e.lazyEntries {
+				// Only compute the link; chunks are generated once requested.
+				if entriesChunker, err = e.newLinkChunker(); err != nil {
+					return cid.Undef, err
+				}
+			}
+			chunkCtx, chunkSpan := metrics.Tracer.Start(ctx, "engine.Chunk")
+			chunkStart := time.Now()
+			var lnk ipld.Link
+			var chunked []chunkedPart
+			if e.maxAdMultihashes > 0 {
+				chunked, err = e.chunkParts(chunkCtx, entriesChunker, contextID, countingIter)
+				if err == nil {
+					lnk = chunked[0].Entries
+				}
+			} else {
+				lnk, err = entriesChunker.Chunk(chunkCtx, countingIter)
+			}
+			metrics.Engine.ChunkingDuration.Record(ctx, time.Since(chunkStart).Milliseconds())
+			chunkSpan.SetAttributes(attribute.Int("multihashCount", countingIter.count))
+			metrics.EndSpan(chunkSpan, err)
 			if err != nil {
-				return cid.Undef, fmt.Errorf("could not generate entries list: %s", err)
+				return cid.Undef, fmt.Errorf("could not generate entries list: %w", err)
 			}
 			if lnk == nil {
 				log.Warnw("chunking for context ID resulted in no link", "contextID", contextID)
@@ -569,10 +1383,27 @@ func (e *Engine) publishAdvForIndex(ctx context.Context, p peer.ID, addrs []mult
 
 			// Store the relationship between providerID, contextID and CID of the
 			// advertised list of Cids.
-			err = e.putKeyCidMap(ctx, p, contextID, cidsLnk.Cid)
+			err = e.putKeyCidMap(ctx, batch, p, contextID, cidsLnk.Cid)
 			if err != nil {
 				return cid.Undef, fmt.Errorf("failed to write provider + context id to entries cid mapping: %s", err)
 			}
+			mhCount = countingIter.count
+			if skipped = int(skippedCount.Load()); skipped != 0 {
+				log.Warnw("Skipped multihashes that could not be listed", "contextID", contextID, "skipped", skipped)
+			}
+			if len(chunked) > 1 {
+				if err = e.putPartMap(ctx, batch, p, contextID, &chunked[0].splitPart); err != nil {
+					return cid.Undef, fmt.Errorf("failed to write provider + context id to part mapping: %s", err)
+				}
+				if parts, err = e.putPartAds(ctx, p, addrs, md, chunked); err != nil {
+					return cid.Undef, err
+				}
+				for _, part := range parts {
+					mhDelta += int64(part.mhCount)
+				}
+				mhCount = chunked[0].Count
+				nParts = len(chunked)
+			}
 		} else {
 			// Lookup metadata for this providerID and contextID.
 			prevMetadata, err := e.getKeyMetadataMap(ctx, p, contextID)
@@ -592,11 +1423,31 @@ func (e *Engine) publishAdvForIndex(ctx context.Context, p peer.ID, addrs []mult
 			// Linked list is the same, but metadata is different, so generate
 			// new advertisement with same linked list, but new metadata.
 			cidsLnk = cidlink.Link{Cid: c}
+			ctx = context.WithValue(ctx, metadataUpdateKey{}, true)
+
+			// Keep the multihash count of the existing entries, if known.
+			prevInfo, err := e.getKeyInfoMap(ctx, p, contextID)
+			if err != nil {
+				return cid.Undef, fmt.Errorf("could not get info for provider + context id: %s", err)
+			}
+			mhCount = prevInfo.MultihashCount
+			if nParts = prevInfo.Parts; nParts > 1 {
+				if parts, err = e.updatePartAds(ctx, p, addrs, contextID, md, nParts); err != nil {
+					return cid.Undef, err
+				}
+			}
 		}
 
-		if err = e.putKeyMetadataMap(ctx, p, contextID, &md); err != nil {
+		if err = e.putKeyMetadataMap(ctx, batch, p, contextID, &md); err != nil {
 			return cid.Undef, fmt.Errorf("failed to write provider + context id to metadata mapping: %s", err)
 		}
+		info := &contextInfo{MultihashCount: mhCount, PublishedAt: time.Now().UnixNano(), Parts: nParts}
+		if err = e.putKeyInfoMap(ctx, batch, p, contextID, info); err != nil {
+			return cid.Undef, fmt.Errorf("failed to write provider + context id to info mapping: %s", err)
+		}
+		if c == cid.Undef && mhCount > 0 {
+			mhDelta += int64(mhCount)
+		}
 	} else {
 		log.Info("Creating removal advertisement")
 
@@ -606,18 +1457,40 @@ func (e *Engine) publishAdvForIndex(ctx context.Context, p peer.ID, addrs []mult
 
 		// If removing by context ID, it means the list of CIDs is not needed
 		// anymore, so we can remove the entry from the datastore.
-		err = e.deleteKeyCidMap(ctx, p, contextID)
+		err = e.deleteKeyCidMap(ctx, batch, p, contextID)
 		if err != nil {
 			return cid.Undef, fmt.Errorf("failed to delete provider + context id to entries cid mapping: %s", err)
 		}
-		err = e.deleteCidKeyMap(ctx, c)
+		err = e.releaseEntries(ctx, batch, p, contextID, c)
 		if err != nil {
 			return cid.Undef, fmt.Errorf("failed to delete entries cid to provider + context id mapping: %s", err)
 		}
-		err = e.deleteKeyMetadataMap(ctx, p, contextID)
+		err = e.deleteKeyMetadataMap(ctx, batch, p, contextID)
 		if err != nil {
 			return cid.Undef, fmt.Errorf("failed to delete provider + context id to metadata mapping: %s", err)
 		}
+		prevInfo, err := e.getKeyInfoMap(ctx, p, contextID)
+		if err != nil {
+			return cid.Undef, fmt.Errorf("could not get info for provider + context id: %s", err)
+		}
+		err = e.deleteKeyInfoMap(ctx, batch, p, contextID)
+		if err != nil {
+			return cid.Undef, fmt.Errorf("failed to delete provider + context id to info mapping: %s", err)
+		}
+		if prevInfo.MultihashCount > 0 {
+			mhDelta = -int64(prevInfo.MultihashCount)
+		}
+		if prevInfo.Parts > 1 {
+			err = e.deletePartMap(ctx, batch, p, contextID)
+			if err != nil {
+				return cid.Undef, fmt.Errorf("failed to delete provider + context id to part mapping: %s", err)
+			}
+			var partsDelta int64
+			if parts, partsDelta, err = e.removePartAds(ctx, p, contextID, prevInfo.Parts); err != nil {
+				return cid.Undef, err
+			}
+			mhDelta += partsDelta
+		}
 
 		// Create an advertisement to delete content by contextID by specifying
 		// that advertisement has no entries.
@@ -625,7 +1498,7 @@ func (e *Engine) publishAdvForIndex(ctx context.Context, p peer.ID, addrs []mult
 
 		// The advertisement still requires a valid metadata even though
 		// metadata is not used for removal. Create a valid empty metadata.
-		md = metadata.Default.New()
+		md = e.metadataContext.New()
 	}
 
 	mdBytes, err := md.MarshalBinary()
@@ -647,10 +1520,27 @@ func (e *Engine) publishAdvForIndex(ctx context.Context, p peer.ID, addrs []mult
 		IsRm:      isRm,
 	}
 
+	if err = e.linkAndSign(ctx, &adv); err != nil {
+		return cid.Undef, err
+	}
+	adCid, err := e.publishWithParts(ctx, adv, mhCount, skipped, parts, batch, unlock)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if mhDelta != 0 {
+		e.stats.multihashesChanged(ctx, mhDelta)
+	}
+	return adCid, nil
+}
+
+// linkAndSign links the given advertisement to the latest advertisement, if
+// any, embeds its sequence if WithAdSequence is set, and signs it. The caller
+// must hold the publish lock.
+func (e *Engine) linkAndSign(ctx context.Context, adv *schema.Advertisement) error {
 	// Get the previous advertisement that was generated.
 	prevAdvID, err := e.getLatestAdCid(ctx)
 	if err != nil {
-		return cid.Undef, fmt.Errorf("could not get latest advertisement: %s", err)
+		return fmt.Errorf("could not get latest advertisement: %s", err)
 	}
 
 	// Check for cid.Undef for the previous link. If this is the case, then
@@ -660,16 +1550,21 @@ func (e *Engine) publishAdvForIndex(ctx context.Context, p peer.ID, addrs []mult
 	} else {
 		adv.PreviousID = ipld.Link(cidlink.Link{Cid: prevAdvID})
 	}
+	if e.adSequence {
+		if err = e.addAdSequence(ctx, adv, prevAdvID); err != nil {
+			return fmt.Errorf("could not add advertisement sequence: %w", err)
+		}
+	}
 
 	// Sign the advertisement.
-	if err = adv.Sign(e.key); err != nil {
-		return cid.Undef, err
-	}
-	return e.Publish(ctx, adv)
+	_, signSpan := metrics.Tracer.Start(ctx, "engine.Sign")
+	err = adv.Sign(e.key)
+	metrics.EndSpan(signSpan, err)
+	return err
 }
 
 func (e *Engine) keyToCidKey(provider peer.ID, contextID []byte) datastore.Key {
-	if provider == e.provider.ID {
+	if provider == e.mappingsID {
 		return datastore.NewKey(keyToCidMapPrefix + string(contextID))
 	}
 	return datastore.NewKey(keyToCidMapPrefix + provider.String() + "/" + string(contextID))
@@ -683,17 +1578,22 @@ func (e *Engine) cidToProviderAndKeyKey(c cid.Cid) datastore.Key {
 	return datastore.NewKey(cidToProviderAndKeyMapPrefix + c.String())
 }
 
+func (e *Engine) cidToReusingKeyKey(c cid.Cid, provider peer.ID, contextID []byte) datastore.Key {
+	h := sha256.Sum256(append([]byte(provider), contextID...))
+	return datastore.NewKey(cidToReusingKeyMapPrefix + c.String() + "/" + base64.RawURLEncoding.EncodeToString(h[:]))
+}
+
 func (e *Engine) keyToMetadataKey(provider peer.ID, contextID []byte) datastore.Key {
-	if provider == e.provider.ID {
+	if provider == e.mappingsID {
 		return datastore.NewKey(keyToMetadataMapPrefix + string(contextID))
 	}
 	return datastore.NewKey(keyToMetadataMapPrefix + provider.String() + "/" + string(contextID))
 }
 
-func (e *Engine) putKeyCidMap(ctx context.Context, provider peer.ID, contextID []byte, c cid.Cid) error {
+func (e *Engine) putKeyCidMap(ctx context.Context, w datastore.Write, provider peer.ID, contextID []byte, c cid.Cid) error {
 	// Store the map Key-Cid to know what CidLink to put in advertisement when
 	// notifying about a removal.
-	err := e.ds.Put(ctx, e.keyToCidKey(provider, contextID), c.Bytes())
+	err := w.Put(ctx, e.keyToCidKey(provider, contextID), c.Bytes())
 	if err != nil {
 		return err
 	}
@@ -710,7 +1610,7 @@ func (e *Engine) putKeyCidMap(ctx context.Context, provider peer.ID, contextID [
 	if err != nil {
 		return err
 	}
-	return e.ds.Put(ctx, e.cidToProviderAndKeyKey(c), m)
+	return w.Put(ctx, e.cidToProviderAndKeyKey(c), m)
 }
 
 func (e *Engine) getKeyCidMap(ctx context.Context, provider peer.ID, contextID []byte) (cid.Cid, error) {
@@ -722,16 +1622,120 @@ func (e *Engine) getKeyCidMap(ctx context.Context, provider peer.ID, contextID [
 	return d, err
 }
 
-func (e *Engine) deleteKeyCidMap(ctx context.Context, provider peer.ID, contextID []byte) error {
-	return e.ds.Delete(ctx, e.keyToCidKey(provider, contextID))
+func (e *Engine) deleteKeyCidMap(ctx context.Context, w datastore.Write, provider peer.ID, contextID []byte) error {
+	return w.Delete(ctx, e.keyToCidKey(provider, contextID))
 }
 
-func (e *Engine) deleteCidKeyMap(ctx context.Context, c cid.Cid) error {
-	err := e.ds.Delete(ctx, e.cidToProviderAndKeyKey(c))
+func (e *Engine) deleteCidKeyMap(ctx context.Context, w datastore.Write, c cid.Cid) error {
+	err := w.Delete(ctx, e.cidToProviderAndKeyKey(c))
 	if err != nil {
 		return err
 	}
-	return e.ds.Delete(ctx, e.cidToKeyKey(c))
+	return w.Delete(ctx, e.cidToKeyKey(c))
+}
+
+// reuseEntries stores the relationship between the given provider, context ID
+// and the entries advertised under another context ID, and returns the
+// multihash count of the entries. Unlike putKeyCidMap, the entries remain
+// mapped to the context ID they were generated for, from which they are
+// regenerated if needed.
+func (e *Engine) reuseEntries(ctx context.Context, w datastore.Write, provider peer.ID, contextID []byte, entries cid.Cid) (int, error) {
+	owner, err := e.getCidKeyMap(ctx, entries)
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return 0, ErrUnknownEntries
+		}
+		return 0, fmt.Errorf("could not get provider + context id of entries: %s", err)
+	}
+	ownerInfo, err := e.getKeyInfoMap(ctx, peer.ID(owner.Provider), owner.ContextID)
+	if err != nil {
+		return 0, fmt.Errorf("could not get info for provider + context id of entries: %s", err)
+	}
+	// Entries of split content are regenerated from part of the multihashes
+	// listed for the context ID that is split, which would not hold once the
+	// entries are released to a context ID reusing them.
+	if part, err := e.getPartMap(ctx, peer.ID(owner.Provider), owner.ContextID); err != nil {
+		return 0, fmt.Errorf("could not get part for provider + context id of entries: %s", err)
+	} else if part != nil {
+		return 0, errors.New("entries of split content cannot be reused")
+	}
+	if err = w.Put(ctx, e.keyToCidKey(provider, contextID), entries.Bytes()); err != nil {
+		return 0, fmt.Errorf("failed to write provider + context id to entries cid mapping: %s", err)
+	}
+	pB, err := provider.Marshal()
+	if err != nil {
+		return 0, err
+	}
+	m, err := json.Marshal(&providerAndContext{Provider: pB, ContextID: contextID})
+	if err != nil {
+		return 0, err
+	}
+	if err = w.Put(ctx, e.cidToReusingKeyKey(entries, provider, contextID), m); err != nil {
+		return 0, fmt.Errorf("failed to write entries cid to reusing provider + context id mapping: %s", err)
+	}
+	return ownerInfo.MultihashCount, nil
+}
+
+// releaseEntries deletes the relationship between the given entries and the
+// given provider and context ID that is being removed. If the entries were
+// generated for the context ID and are reused by other context IDs, they are
+// mapped to one of those instead, so that they can still be regenerated.
+//
+// Changes are written to w, and must not yet be visible in the datastore, i.e.
+// the mappings of the context ID being removed are still read from it.
+func (e *Engine) releaseEntries(ctx context.Context, w datastore.Write, provider peer.ID, contextID []byte, entries cid.Cid) error {
+	releasedKey := e.cidToReusingKeyKey(entries, provider, contextID)
+	if err := w.Delete(ctx, releasedKey); err != nil {
+		return err
+	}
+	owner, err := e.getCidKeyMap(ctx, entries)
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	if peer.ID(owner.Provider) != provider || !bytes.Equal(owner.ContextID, contextID) {
+		// The entries are reused from a context ID that is still advertised.
+		return nil
+	}
+	if err = e.deleteCidKeyMap(ctx, w, entries); err != nil {
+		return err
+	}
+
+	results, err := e.ds.Query(ctx, query.Query{Prefix: cidToReusingKeyMapPrefix + entries.String() + "/"})
+	if err != nil {
+		return err
+	}
+	defer results.Close()
+	for r := range results.Next() {
+		if r.Error != nil {
+			return r.Error
+		}
+		if r.Key == releasedKey.String() {
+			continue
+		}
+		if err = w.Delete(ctx, datastore.RawKey(r.Key)); err != nil {
+			return err
+		}
+		var pAndC providerAndContext
+		if err = json.Unmarshal(r.Value, &pAndC); err != nil {
+			return err
+		}
+		// Skip stale mappings of context IDs that no longer reuse the entries.
+		c, err := e.getKeyCidMap(ctx, peer.ID(pAndC.Provider), pAndC.ContextID)
+		if err != nil {
+			if errors.Is(err, datastore.ErrNotFound) {
+				continue
+			}
+			return err
+		}
+		if c != entries {
+			continue
+		}
+		return w.Put(ctx, e.cidToProviderAndKeyKey(entries), r.Value)
+	}
+	return nil
 }
 
 type providerAndContext struct {
@@ -741,14 +1745,15 @@ type providerAndContext struct {
 
 // getCidKeyMap returns the provider and contextID for a given cid. Provider
 // and Context ID are guaranteed to be not nil. In the case if legacy index
-// exists, the default provider identity is assumed.
+// exists, the identity under which mappings are stored without provider ID is
+// assumed.
 func (e *Engine) getCidKeyMap(ctx context.Context, c cid.Cid) (*providerAndContext, error) {
 	// first see whether the mapping exists in the legacy index
 	val, err := e.ds.Get(ctx, e.cidToKeyKey(c))
 	if err == nil {
 		// if the mapping has been found in the legacy index - return the
-		// default provider identity.
-		return &providerAndContext{Provider: []byte(e.provider.ID), ContextID: val}, nil
+		// identity of the mappings stored without provider ID.
+		return &providerAndContext{Provider: []byte(e.mappingsID), ContextID: val}, nil
 	}
 	if !errors.Is(err, datastore.ErrNotFound) {
 		return nil, err
@@ -766,21 +1771,38 @@ func (e *Engine) getCidKeyMap(ctx context.Context, c cid.Cid) (*providerAndConte
 	}
 	// if provider is empty (which should never happen), assume the default one
 	if len(pAndC.Provider) == 0 {
-		pAndC.Provider = []byte(e.provider.ID)
+		pAndC.Provider = []byte(e.mappingsID)
 	}
 	return &pAndC, nil
 }
 
-func (e *Engine) putKeyMetadataMap(ctx context.Context, provider peer.ID, contextID []byte, metadata *metadata.Metadata) error {
+// checkMetadata checks that the given metadata, if not empty, can be decoded
+// with the metadata context of the engine.
+func (e *Engine) checkMetadata(md metadata.Metadata) error {
+	if md.Len() == 0 {
+		return nil
+	}
+	data, err := md.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("cannot encode metadata: %w", err)
+	}
+	decoded := e.metadataContext.New()
+	if err = decoded.UnmarshalBinary(data); err != nil {
+		return fmt.Errorf("cannot decode metadata with protocols %v; register custom protocols via WithMetadataProtocol: %w", md.Protocols(), err)
+	}
+	return nil
+}
+
+func (e *Engine) putKeyMetadataMap(ctx context.Context, w datastore.Write, provider peer.ID, contextID []byte, metadata *metadata.Metadata) error {
 	data, err := metadata.MarshalBinary()
 	if err != nil {
 		return err
 	}
-	return e.ds.Put(ctx, e.keyToMetadataKey(provider, contextID), data)
+	return w.Put(ctx, e.keyToMetadataKey(provider, contextID), data)
 }
 
 func (e *Engine) getKeyMetadataMap(ctx context.Context, provider peer.ID, contextID []byte) (metadata.Metadata, error) {
-	md := metadata.Default.New()
+	md := e.metadataContext.New()
 	data, err := e.ds.Get(ctx, e.keyToMetadataKey(provider, contextID))
 	if err != nil {
 		return md, err
@@ -791,12 +1813,228 @@ func (e *Engine) getKeyMetadataMap(ctx context.Context, provider peer.ID, contex
 	return md, nil
 }
 
-func (e *Engine) deleteKeyMetadataMap(ctx context.Context, provider peer.ID, contextID []byte) error {
-	return e.ds.Delete(ctx, e.keyToMetadataKey(provider, contextID))
+func (e *Engine) deleteKeyMetadataMap(ctx context.Context, w datastore.Write, provider peer.ID, contextID []byte) error {
+	return w.Delete(ctx, e.keyToMetadataKey(provider, contextID))
+}
+
+func (e *Engine) keyToInfoKey(provider peer.ID, contextID []byte) datastore.Key {
+	if provider == e.mappingsID {
+		return datastore.NewKey(keyToInfoMapPrefix + string(contextID))
+	}
+	return datastore.NewKey(keyToInfoMapPrefix + provider.String() + "/" + string(contextID))
+}
+
+// contextInfo is the information stored about the content advertised under a
+// context ID, in addition to its entries CID and metadata.
+type contextInfo struct {
+	// MultihashCount is the number of advertised multihashes, or -1 if unknown.
+	MultihashCount int `json:"n"`
+	// PublishedAt is the time at which the content was last advertised in
+	// nanoseconds since epoch.
+	PublishedAt int64 `json:"t"`
+	// Parts is the number of advertisements the content is split across, or
+	// 0 if it is not split.
+	Parts int `json:"p,omitempty"`
+}
+
+func (e *Engine) putKeyInfoMap(ctx context.Context, w datastore.Write, provider peer.ID, contextID []byte, info *contextInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return w.Put(ctx, e.keyToInfoKey(provider, contextID), data)
+}
+
+// getKeyInfoMap returns the info stored for the given provider and context ID.
+// Content advertised before info was recorded has unknown multihash count and
+// publish time.
+func (e *Engine) getKeyInfoMap(ctx context.Context, provider peer.ID, contextID []byte) (*contextInfo, error) {
+	data, err := e.ds.Get(ctx, e.keyToInfoKey(provider, contextID))
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return &contextInfo{MultihashCount: -1}, nil
+		}
+		return nil, err
+	}
+	var info contextInfo
+	if err = json.Unmarshal(data, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+func (e *Engine) deleteKeyInfoMap(ctx context.Context, w datastore.Write, provider peer.ID, contextID []byte) error {
+	return w.Delete(ctx, e.keyToInfoKey(provider, contextID))
+}
+
+// listMultihashes lists the multihashes of the given context ID using the
+// registered lister, sorted and deduplicated if the engine is configured to do
+// so. The multihashes of a part of split content are those of its part of the
+// multihashes listed for the context ID that is split. Multihashes that the
+// lister reports as skipped are left out, and counted in skipped if not nil.
+//
+// See: WithSortedEntries, WithMaxAdMultihashes, provider.SkipMultihash.
+func (e *Engine) listMultihashes(ctx context.Context, p peer.ID, contextID []byte, skipped *atomic.Int64) (provider.MultihashIterator, error) {
+	mhLister := e.multihashLister()
+	if mhLister == nil {
+		return nil, provider.ErrNoMultihashLister
+	}
+	part, err := e.getPartMap(ctx, p, contextID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get part by provider + context id: %w", err)
+	}
+	if part != nil {
+		contextID = part.ContextID
+	}
+	if err = e.delayLister(ctx); err != nil {
+		return nil, err
+	}
+	mhIter, err := mhLister(ctx, p, contextID)
+	if err != nil {
+		return nil, err
+	}
+	mhIter = &skippingMultihashIterator{MultihashIterator: mhIter, ctx: ctx, contextID: contextID, skipped: skipped}
+	mhIter = &meteredMultihashIterator{
+		MultihashIterator: provider.PrefetchMultihashIterator(mhIter, e.listerPrefetch),
+		ctx:               ctx,
+	}
+	if e.sortEntries || e.sortedChunks {
+		sorted, err := provider.SortedMultihashIterator(mhIter, e.sortMemory, e.sortTempDir)
+		closeMultihashIterator(mhIter)
+		if err != nil {
+			return nil, fmt.Errorf("cannot sort multihashes: %w", err)
+		}
+		mhIter = sorted
+	}
+	if part != nil {
+		mhIter = &limitMultihashIterator{MultihashIterator: mhIter, skip: part.Offset, limit: part.Count}
+	}
+	return mhIter, nil
+}
+
+// newLinkChunker instantiates a chunker that computes the link to entries
+// without storing their chunks: each chunk is encoded and hashed to compute
+// its link, then discarded. The nodes of HAMT entries, which are loaded back
+// while the HAMT is built, are instead only held in memory while chunking.
+//
+// See: WithLazyEntries.
+func (e *Engine) newLinkChunker() (chunker.EntriesChunker, error) {
+	lsys := cidlink.DefaultLinkSystem()
+	lsys.StorageWriteOpener = func(ipld.LinkContext) (io.Writer, ipld.BlockWriteCommitter, error) {
+		return io.Discard, func(ipld.Link) error { return nil }, nil
+	}
+	entriesChunker, err := e.chunker(&lsys)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := entriesChunker.(*chunker.HamtChunker); ok {
+		store := &memstore.Store{}
+		lsys = cidlink.DefaultLinkSystem()
+		lsys.SetReadStorage(store)
+		lsys.SetWriteStorage(store)
+		return e.chunker(&lsys)
+	}
+	return entriesChunker, nil
+}
+
+// closeMultihashIterator releases the resources held by the given iterator, if
+// it holds any.
+func closeMultihashIterator(mhIter provider.MultihashIterator) {
+	if closer, ok := mhIter.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Warnw("Failed to close multihash iterator", "err", err)
+		}
+	}
+}
+
+// countingMultihashIterator counts the multihashes returned by the wrapped
+// iterator.
+type countingMultihashIterator struct {
+	provider.MultihashIterator
+	count int
+}
+
+func (i *countingMultihashIterator) Next() (multihash.Multihash, error) {
+	mh, err := i.MultihashIterator.Next()
+	if err == nil {
+		i.count++
+	}
+	return mh, err
+}
+
+// skippingMultihashIterator leaves out the multihashes that the wrapped
+// iterator reports as skipped, logging and counting them, and returns the
+// others. See: provider.SkipMultihash.
+type skippingMultihashIterator struct {
+	provider.MultihashIterator
+	ctx       context.Context
+	contextID []byte
+	skipped   *atomic.Int64
+}
+
+func (i *skippingMultihashIterator) Next() (multihash.Multihash, error) {
+	for {
+		mh, err := i.MultihashIterator.Next()
+		if err == nil || !errors.Is(err, provider.ErrMultihashSkipped) {
+			return mh, err
+		}
+		log.Warnw("Skipped multihash that could not be listed", "contextID", i.contextID, "err", err)
+		metrics.Engine.MultihashesSkipped.Add(i.ctx, 1)
+		if i.skipped != nil {
+			i.skipped.Add(1)
+		}
+	}
+}
+
+func (i *skippingMultihashIterator) Close() error {
+	if closer, ok := i.MultihashIterator.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// meteredMultihashIterator records the number of multihashes listed by the
+// wrapped iterator, and the time spent waiting for them, once iterated to the
+// end or closed.
+type meteredMultihashIterator struct {
+	provider.MultihashIterator
+	ctx   context.Context
+	count int64
+	wait  time.Duration
+	done  bool
+}
+
+func (i *meteredMultihashIterator) Next() (multihash.Multihash, error) {
+	start := time.Now()
+	mh, err := i.MultihashIterator.Next()
+	i.wait += time.Since(start)
+	if err != nil {
+		i.record()
+		return nil, err
+	}
+	i.count++
+	return mh, nil
+}
+
+func (i *meteredMultihashIterator) Close() error {
+	i.record()
+	if closer, ok := i.MultihashIterator.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (i *meteredMultihashIterator) record() {
+	if i.done {
+		return
+	}
+	i.done = true
+	metrics.Engine.MultihashesListed.Add(i.ctx, i.count)
+	metrics.Engine.ListerWaitDuration.Record(i.ctx, i.wait.Milliseconds())
 }
 
-func (e *Engine) putLatestAdv(ctx context.Context, advID []byte) error {
-	return e.ds.Put(ctx, dsLatestAdvKey, advID)
+func (e *Engine) putLatestAdv(ctx context.Context, w datastore.Write, advID []byte) error {
+	return w.Put(ctx, dsLatestAdvKey, advID)
 }
 
 func (e *Engine) getLatestAdCid(ctx context.Context) (cid.Cid, error) {
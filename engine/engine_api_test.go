@@ -23,12 +23,14 @@ func (e *Engine) Chunker() *chunker.CachedEntriesChunker {
 
 // Key returns the engine's private key, exposed for testing purposes only.
 func (e *Engine) Key() crypto.PrivKey {
-	return e.key
+	_, key := e.identity()
+	return key
 }
 
 // ProviderID returns the engine's default provider ID, exposed for testing purposes only.
 func (e *Engine) ProviderID() peer.ID {
-	return e.provider.ID
+	id, _ := e.identity()
+	return id
 }
 
 // ProviderAddrs returns the engine's default provider addresses, exposed for testing purposes only.
@@ -1,17 +1,25 @@
 package engine_test
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"math/rand"
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	dssync "github.com/ipfs/go-datastore/sync"
 	leveldb "github.com/ipfs/go-ds-leveldb"
 	"github.com/ipld/go-ipld-prime"
 	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
@@ -31,8 +39,15 @@ import (
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/multiformats/go-multiaddr"
+	"github.com/multiformats/go-multicodec"
 	"github.com/multiformats/go-multihash"
+	"github.com/multiformats/go-varint"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestEngine_NotifyRemoveWithUnknownContextIDIsError(t *testing.T) {
@@ -338,6 +353,43 @@ func TestEngine_NotifyPutWithoutListerIsError(t *testing.T) {
 	require.Equal(t, cid.Undef, gotCid)
 }
 
+func TestEngine_NotifyPutRejectsAdsOverLimits(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+	subject, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	var listed int
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		listed++
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+
+	md := metadata.Default.New(metadata.Bitswap{})
+	_, err = subject.NotifyPut(ctx, nil, bytes.Repeat([]byte("f"), provider.MaxContextIDLen+1), md)
+	require.ErrorIs(t, err, provider.ErrContextIDTooLong)
+	_, err = subject.NotifyRemove(ctx, "", bytes.Repeat([]byte("f"), provider.MaxContextIDLen+1))
+	require.ErrorIs(t, err, provider.ErrContextIDTooLong)
+
+	bigMd := metadata.Default.New(&metadata.Unknown{Code: 0x3f0000, Payload: bytes.Repeat([]byte{1}, provider.MaxMetadataLen+1)})
+	_, err = subject.NotifyPut(ctx, nil, []byte("fish"), bigMd)
+	require.ErrorIs(t, err, provider.ErrMetadataTooLong)
+
+	pID, _, _ := test.RandomIdentity()
+	addrs := make([]multiaddr.Multiaddr, provider.MaxAddrs+1)
+	for i := range addrs {
+		addrs[i] = multiaddr.StringCast(fmt.Sprintf("/ip4/192.0.2.1/tcp/%d", 1000+i))
+	}
+	_, err = subject.NotifyPut(ctx, &peer.AddrInfo{ID: pID, Addrs: addrs}, []byte("fish"), md)
+	require.ErrorIs(t, err, provider.ErrTooManyAddrs)
+
+	require.Zero(t, listed)
+	head, _, err := subject.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	require.Equal(t, cid.Undef, head)
+}
+
 func TestEngine_NotifyPutThenNotifyRemove(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
 	t.Cleanup(cancel)
@@ -442,6 +494,276 @@ func TestEngine_NotifyRemoveWithCustomProvider(t *testing.T) {
 	require.Equal(t, providerId.String(), ad.Provider)
 }
 
+func TestEngine_ListContextIDs(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	subject, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+
+	mhsByContextID := map[string][]multihash.Multihash{
+		"fish":    test.RandomMultihashes(3),
+		"lobster": test.RandomMultihashes(3),
+		"crab":    test.RandomMultihashes(3),
+	}
+	subject.RegisterMultihashLister(func(_ context.Context, _ peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(mhsByContextID[string(contextID)]), nil
+	})
+
+	otherProviderID, _, _ := test.RandomIdentity()
+	md := metadata.Default.New(metadata.Bitswap{})
+	_, err = subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.NoError(t, err)
+	_, err = subject.NotifyPut(ctx, nil, []byte("lobster"), md)
+	require.NoError(t, err)
+	_, err = subject.NotifyPut(ctx, &peer.AddrInfo{ID: otherProviderID}, []byte("crab"), md)
+	require.NoError(t, err)
+
+	got, err := subject.ListContextIDs(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("fish"), []byte("lobster")}, got)
+
+	got, err = subject.ListContextIDs(ctx, otherProviderID)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("crab")}, got)
+
+	_, err = subject.NotifyRemove(ctx, "", []byte("fish"))
+	require.NoError(t, err)
+	got, err = subject.ListContextIDs(ctx, subject.ProviderID())
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("lobster")}, got)
+}
+
+func TestEngine_GetContextInfo(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	subject, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	mhs := test.RandomMultihashes(42)
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(mhs), nil
+	})
+
+	contextID := []byte("fish")
+	_, err = subject.GetContextInfo(ctx, "", contextID)
+	require.ErrorIs(t, err, provider.ErrContextIDNotFound)
+
+	before := time.Now()
+	adCid, err := subject.NotifyPut(ctx, nil, contextID, metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	ad, err := subject.GetAdv(ctx, adCid)
+	require.NoError(t, err)
+
+	info, err := subject.GetContextInfo(ctx, "", contextID)
+	require.NoError(t, err)
+	require.Equal(t, contextID, info.ContextID)
+	require.Equal(t, subject.ProviderID(), info.Provider)
+	require.Equal(t, ad.Entries.(cidlink.Link).Cid, info.Entries)
+	require.True(t, info.Metadata.Equal(metadata.Default.New(metadata.Bitswap{})))
+	require.Equal(t, 42, info.MultihashCount)
+	require.False(t, info.PublishedAt.Before(before))
+
+	// Updating the metadata keeps the multihash count.
+	_, err = subject.NotifyPut(ctx, nil, contextID, metadata.Default.New(metadata.IpfsGatewayHttp{}))
+	require.NoError(t, err)
+	updated, err := subject.GetContextInfo(ctx, "", contextID)
+	require.NoError(t, err)
+	require.Equal(t, info.Entries, updated.Entries)
+	require.True(t, updated.Metadata.Equal(metadata.Default.New(metadata.IpfsGatewayHttp{})))
+	require.Equal(t, 42, updated.MultihashCount)
+	require.False(t, updated.PublishedAt.Before(info.PublishedAt))
+
+	_, err = subject.NotifyRemove(ctx, "", contextID)
+	require.NoError(t, err)
+	_, err = subject.GetContextInfo(ctx, "", contextID)
+	require.ErrorIs(t, err, provider.ErrContextIDNotFound)
+}
+
+func TestEngine_NotifyPutWithEntries(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	subject, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	mhs := test.RandomMultihashes(42)
+	var listed []string
+	subject.RegisterMultihashLister(func(_ context.Context, _ peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		listed = append(listed, string(contextID))
+		return provider.SliceMultihashIterator(mhs), nil
+	})
+	md := metadata.Default.New(metadata.Bitswap{})
+
+	_, err = subject.NotifyPutWithEntries(ctx, nil, []byte("lobster"), md, test.RandomCids(1)[0])
+	require.ErrorIs(t, err, engine.ErrUnknownEntries)
+
+	_, err = subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.NoError(t, err)
+	fish, err := subject.GetContextInfo(ctx, "", []byte("fish"))
+	require.NoError(t, err)
+
+	adCid, err := subject.NotifyPutWithEntries(ctx, nil, []byte("lobster"), md, fish.Entries)
+	require.NoError(t, err)
+	ad, err := subject.GetAdv(ctx, adCid)
+	require.NoError(t, err)
+	require.Equal(t, fish.Entries, ad.Entries.(cidlink.Link).Cid)
+	require.Equal(t, []byte("lobster"), ad.ContextID)
+	lobster, err := subject.GetContextInfo(ctx, "", []byte("lobster"))
+	require.NoError(t, err)
+	require.Equal(t, fish.Entries, lobster.Entries)
+	require.Equal(t, 42, lobster.MultihashCount)
+	require.Equal(t, []string{"fish"}, listed)
+
+	_, err = subject.NotifyPutWithEntries(ctx, nil, []byte("lobster"), md, fish.Entries)
+	require.ErrorIs(t, err, provider.ErrAlreadyAdvertised)
+
+	// Once the context ID the entries were generated for is removed, they are
+	// regenerated from the context ID reusing them.
+	_, err = subject.NotifyRemove(ctx, "", []byte("fish"))
+	require.NoError(t, err)
+	got, err := subject.ListContextIDs(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("lobster")}, got)
+
+	_, err = subject.NotifyRemove(ctx, "", []byte("lobster"))
+	require.NoError(t, err)
+	got, err = subject.ListContextIDs(ctx, "")
+	require.NoError(t, err)
+	require.Empty(t, got)
+	_, err = subject.NotifyPutWithEntries(ctx, nil, []byte("crab"), md, fish.Entries)
+	require.ErrorIs(t, err, engine.ErrUnknownEntries)
+}
+
+func TestEngine_NotifyPutIsTraced(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+
+	subject, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(42)), nil
+	})
+
+	ctx, root := tp.Tracer("test").Start(ctx, "root")
+	_, err = subject.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	root.End()
+
+	parents := make(map[string]string)
+	var chunkSpan sdktrace.ReadOnlySpan
+	byID := make(map[trace.SpanID]string)
+	for _, span := range recorder.Ended() {
+		require.Equal(t, root.SpanContext().TraceID(), span.SpanContext().TraceID())
+		byID[span.SpanContext().SpanID()] = span.Name()
+		if span.Name() == "engine.Chunk" {
+			chunkSpan = span
+		}
+	}
+	for _, span := range recorder.Ended() {
+		parents[span.Name()] = byID[span.Parent().SpanID()]
+	}
+	require.Equal(t, map[string]string{
+		"root":                   "",
+		"engine.NotifyPut":       "root",
+		"engine.ListMultihashes": "engine.NotifyPut",
+		"engine.Chunk":           "engine.NotifyPut",
+		"engine.Sign":            "engine.NotifyPut",
+		"engine.Publish":         "engine.NotifyPut",
+		"engine.PublishLocal":    "engine.Publish",
+	}, parents)
+	require.Contains(t, chunkSpan.Attributes(), attribute.Int("multihashCount", 42))
+}
+
+// customProtocol is a retrieval protocol whose encoding is its transport ID
+// followed by a fixed size payload, without a length prefix.
+type customProtocol struct {
+	payload [4]byte
+}
+
+const customProtocolID = multicodec.Code(0x300001)
+
+func (p *customProtocol) ID() multicodec.Code {
+	return customProtocolID
+}
+
+func (p *customProtocol) MarshalBinary() ([]byte, error) {
+	return append(varint.ToUvarint(uint64(customProtocolID)), p.payload[:]...), nil
+}
+
+func (p *customProtocol) UnmarshalBinary(data []byte) error {
+	_, err := p.ReadFrom(bytes.NewReader(data))
+	return err
+}
+
+func (p *customProtocol) ReadFrom(r io.Reader) (int64, error) {
+	br := bufio.NewReader(io.LimitReader(r, int64(varint.UvarintSize(uint64(customProtocolID))+len(p.payload))))
+	id, err := varint.ReadUvarint(br)
+	if err != nil {
+		return 0, err
+	}
+	if multicodec.Code(id) != customProtocolID {
+		return 0, fmt.Errorf("transport ID does not match %s", customProtocolID)
+	}
+	n, err := io.ReadFull(br, p.payload[:])
+	return int64(varint.UvarintSize(id) + n), err
+}
+
+func TestEngine_WithMetadataProtocol(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+	lister := func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	}
+	newMetadata := func(mc metadata.MetadataContext) metadata.Metadata {
+		return mc.New(&customProtocol{payload: [4]byte{'f', 'i', 's', 'h'}}, metadata.Bitswap{})
+	}
+
+	// Without the protocol being registered, the metadata cannot be decoded.
+	subject, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	subject.RegisterMultihashLister(lister)
+	_, err = subject.NotifyPut(ctx, nil, []byte("fish"), newMetadata(metadata.Default))
+	require.ErrorContains(t, err, "register custom protocols via WithMetadataProtocol")
+
+	subject, err = engine.New(
+		engine.WithPublisherKind(engine.NoPublisher),
+		engine.WithMetadataProtocol(customProtocolID, func() metadata.Protocol { return &customProtocol{} }))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	subject.RegisterMultihashLister(lister)
+	md := newMetadata(subject.MetadataContext())
+	adCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.NoError(t, err)
+
+	ad, err := subject.GetAdv(ctx, adCid)
+	require.NoError(t, err)
+	decoded := subject.MetadataContext().New()
+	require.NoError(t, decoded.UnmarshalBinary(ad.Metadata))
+	require.True(t, md.Equal(decoded))
+	require.Equal(t, &customProtocol{payload: [4]byte{'f', 'i', 's', 'h'}}, decoded.Get(customProtocolID))
+
+	info, err := subject.GetContextInfo(ctx, "", []byte("fish"))
+	require.NoError(t, err)
+	require.True(t, md.Equal(info.Metadata))
+	_, err = subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.ErrorIs(t, err, provider.ErrAlreadyAdvertised)
+}
+
 func TestEngine_ProducesSingleChainForMultipleProviders(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
 	t.Cleanup(cancel)
@@ -559,6 +881,68 @@ func TestEngine_VerifyErrAlreadyAdvertised(t *testing.T) {
 	require.NoError(t, err, provider.ErrAlreadyAdvertised)
 }
 
+func TestEngine_UpdateMetadata(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	subject, err := engine.New(engine.WithAuditLog(true), engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	t.Cleanup(func() { subject.Shutdown() })
+	var listed int
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		listed++
+		return provider.SliceMultihashIterator(test.RandomMultihashes(5)), nil
+	})
+	events, unsubscribe := subject.SubscribePublishEvents()
+	t.Cleanup(unsubscribe)
+
+	// Content that is not advertised is never advertised by updating it.
+	bitswap := metadata.Default.New(metadata.Bitswap{})
+	_, err = subject.UpdateMetadata(ctx, nil, []byte("fish"), bitswap)
+	require.ErrorIs(t, err, provider.ErrContextIDNotFound)
+	require.Zero(t, listed)
+
+	putCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), bitswap)
+	require.NoError(t, err)
+	require.False(t, (<-events).IsUpdate)
+	_, err = subject.UpdateMetadata(ctx, nil, []byte("fish"), bitswap)
+	require.ErrorIs(t, err, provider.ErrAlreadyAdvertised)
+
+	gateway := metadata.Default.New(metadata.IpfsGatewayHttp{})
+	updateCid, err := subject.UpdateMetadata(ctx, nil, []byte("fish"), gateway)
+	require.NoError(t, err)
+	require.Equal(t, 1, listed)
+	event := <-events
+	require.Equal(t, updateCid, event.AdCid)
+	require.True(t, event.IsUpdate)
+
+	// The update links to the same entries, under the new metadata.
+	putAd, err := subject.GetAdv(ctx, putCid)
+	require.NoError(t, err)
+	updateAd, err := subject.GetAdv(ctx, updateCid)
+	require.NoError(t, err)
+	require.Equal(t, putAd.Entries, updateAd.Entries)
+	require.Equal(t, putCid, updateAd.PreviousCid())
+	wantMd, err := gateway.MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, wantMd, updateAd.Metadata)
+
+	records, err := subject.ListAuditRecords(ctx, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	require.False(t, records[0].IsUpdate)
+	require.True(t, records[1].IsUpdate)
+	require.Equal(t, 5, records[1].MultihashCount)
+	require.Equal(t, []string{"transport-ipfs-gateway-http"}, records[1].Protocols)
+
+	// Removed content can no longer be updated.
+	_, err = subject.NotifyRemove(ctx, "", []byte("fish"))
+	require.NoError(t, err)
+	_, err = subject.UpdateMetadata(ctx, nil, []byte("fish"), bitswap)
+	require.ErrorIs(t, err, provider.ErrContextIDNotFound)
+}
+
 func TestEngine_ShouldHaveSameChunksInChunkerForSameCIDs(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
 	t.Cleanup(cancel)
@@ -599,6 +983,327 @@ func TestEngine_ShouldHaveSameChunksInChunkerForSameCIDs(t *testing.T) {
 	require.Equal(t, ad1.Entries, ad2.Entries)
 }
 
+func TestEngine_SortedEntriesAreDeterministic(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	mhs := test.RandomMultihashes(42)
+	// List the multihashes in a different order each time, with duplicates.
+	lister := func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		listed := append(append([]multihash.Multihash{}, mhs...), mhs[:7]...)
+		rand.Shuffle(len(listed), func(i, j int) { listed[i], listed[j] = listed[j], listed[i] })
+		return provider.SliceMultihashIterator(listed), nil
+	}
+
+	var entries []ipld.Link
+	for i := 0; i < 2; i++ {
+		subject, err := engine.New(
+			engine.WithSortedEntries(true),
+			// Spill multihashes to disk while sorting them.
+			engine.WithEntriesSortMemory(256, t.TempDir()))
+		require.NoError(t, err)
+		require.NoError(t, subject.Start(ctx))
+		defer subject.Shutdown()
+		subject.RegisterMultihashLister(lister)
+
+		adCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+		require.NoError(t, err)
+		ad, err := subject.GetAdv(ctx, adCid)
+		require.NoError(t, err)
+		entries = append(entries, ad.Entries)
+
+		info, err := subject.GetContextInfo(ctx, "", []byte("fish"))
+		require.NoError(t, err)
+		require.Equal(t, len(mhs), info.MultihashCount)
+
+		// Evict the entries from cache, and check that they are regenerated
+		// identically even though the lister returns them in another order.
+		entriesCid := ad.Entries.(cidlink.Link).Cid
+		require.NoError(t, subject.Datastore().Delete(ctx, datastore.NewKey(entriesCid.String())))
+		require.NoError(t, subject.Datastore().Delete(ctx, datastore.NewKey("/cache/links/"+entriesCid.String())))
+		chunks := requireLoadEntryChunkFromEngine(t, subject, ad.Entries)
+		require.Len(t, chunks, 1)
+		require.Len(t, chunks[0].Entries, len(mhs))
+		require.True(t, slices.IsSortedFunc(chunks[0].Entries, func(a, b multihash.Multihash) int {
+			return bytes.Compare(a, b)
+		}))
+	}
+	require.Equal(t, entries[0], entries[1])
+}
+
+func TestEngine_SortedChainedEntries(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	mhs := test.RandomMultihashes(42)
+	subject, err := engine.New(engine.WithSortedChainedEntries(10))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		listed := append(append([]multihash.Multihash{}, mhs...), mhs[:7]...)
+		rand.Shuffle(len(listed), func(i, j int) { listed[i], listed[j] = listed[j], listed[i] })
+		return provider.SliceMultihashIterator(listed), nil
+	})
+
+	adCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	ad, err := subject.GetAdv(ctx, adCid)
+	require.NoError(t, err)
+	var chunks []*schema.EntryChunk
+	for next := ad.Entries; next != nil; {
+		chunk := requireLoadEntryChunkFromEngine(t, subject, next)[0]
+		chunks = append(chunks, chunk)
+		next = nil
+		if chunk.Next != nil {
+			next = chunk.Next
+		}
+	}
+	require.Len(t, chunks, 5)
+	require.Len(t, chunks[0].Entries, 2)
+	var got []multihash.Multihash
+	for i := len(chunks) - 1; i >= 0; i-- {
+		if i != 0 {
+			require.Len(t, chunks[i].Entries, 10)
+		}
+		got = append(got, chunks[i].Entries...)
+	}
+	require.Len(t, got, len(mhs))
+	require.True(t, slices.IsSortedFunc(got, func(a, b multihash.Multihash) int {
+		return bytes.Compare(a, b)
+	}))
+}
+
+func TestEngine_LazyEntries(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	mhs := test.RandomMultihashes(42)
+	for name, entriesOpt := range map[string]engine.Option{
+		"chain": engine.WithChainedEntries(10),
+		"hamt":  engine.WithHamtEntries(multicodec.Murmur3X64_64, 3, 2),
+	} {
+		t.Run(name, func(t *testing.T) {
+			var entries []ipld.Link
+			for _, lazy := range []bool{false, true} {
+				subject, err := engine.New(entriesOpt, engine.WithLazyEntries(lazy))
+				require.NoError(t, err)
+				require.NoError(t, subject.Start(ctx))
+				defer subject.Shutdown()
+				var listed int
+				subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+					listed++
+					return provider.SliceMultihashIterator(mhs), nil
+				})
+
+				adCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+				require.NoError(t, err)
+				ad, err := subject.GetAdv(ctx, adCid)
+				require.NoError(t, err)
+				entries = append(entries, ad.Entries)
+				require.Equal(t, 1, listed)
+				if !lazy {
+					require.Equal(t, 1, subject.Chunker().Len())
+					continue
+				}
+
+				// Chunks are generated once first requested, then cached.
+				require.Zero(t, subject.Chunker().Len())
+				for i := 0; i < 2; i++ {
+					_, err = subject.LinkSystem().Load(ipld.LinkContext{Ctx: ctx}, ad.Entries, basicnode.Prototype.Any)
+					require.NoError(t, err)
+					require.Equal(t, 2, listed)
+					require.Equal(t, 1, subject.Chunker().Len())
+				}
+			}
+			// The link to entries is the same whether generated lazily or not.
+			require.Equal(t, entries[0], entries[1])
+		})
+	}
+}
+
+func TestEngine_ListerPrefetch(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	_, err := engine.New(engine.WithListerPrefetch(-1))
+	require.ErrorContains(t, err, "must not be negative")
+
+	mhs := test.RandomMultihashes(1000)
+	var entries []ipld.Link
+	for _, prefetch := range []int{0, 10, 5000} {
+		subject, err := engine.New(engine.WithChainedEntries(100), engine.WithListerPrefetch(prefetch))
+		require.NoError(t, err)
+		require.NoError(t, subject.Start(ctx))
+		defer subject.Shutdown()
+		subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+			return provider.SliceMultihashIterator(mhs), nil
+		})
+
+		adCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+		require.NoError(t, err)
+		ad, err := subject.GetAdv(ctx, adCid)
+		require.NoError(t, err)
+		entries = append(entries, ad.Entries)
+		info, err := subject.GetContextInfo(ctx, "", []byte("fish"))
+		require.NoError(t, err)
+		require.Equal(t, len(mhs), info.MultihashCount)
+	}
+	// Prefetching does not change the order in which multihashes are chunked.
+	require.Equal(t, entries[0], entries[1])
+	require.Equal(t, entries[0], entries[2])
+}
+
+// batchingDatastore counts the mappings written directly rather than in a
+// batch, and fails to commit batches if failCommit is set.
+type batchingDatastore struct {
+	datastore.Batching
+	unbatchedPuts int
+	failCommit    bool
+}
+
+type failingBatch struct {
+	datastore.Batch
+	ds *batchingDatastore
+}
+
+func (ds *batchingDatastore) Put(ctx context.Context, key datastore.Key, value []byte) error {
+	if strings.HasPrefix(key.String(), "/map/") {
+		ds.unbatchedPuts++
+	}
+	return ds.Batching.Put(ctx, key, value)
+}
+
+func (ds *batchingDatastore) Batch(ctx context.Context) (datastore.Batch, error) {
+	b, err := ds.Batching.Batch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &failingBatch{Batch: b, ds: ds}, nil
+}
+
+func (b *failingBatch) Commit(ctx context.Context) error {
+	if b.ds.failCommit {
+		return errors.New("fish")
+	}
+	return b.Batch.Commit(ctx)
+}
+
+func TestEngine_WithDatastoreNamespace(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	// Engines of different identities share the datastore of an application.
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	require.NoError(t, ds.Put(ctx, datastore.NewKey("/app/fish"), []byte("fish")))
+	newEngine := func(ns string) *engine.Engine {
+		subject, err := engine.New(engine.WithDatastore(ds), engine.WithDatastoreNamespace(ns), engine.WithPublisherKind(engine.NoPublisher))
+		require.NoError(t, err)
+		require.NoError(t, subject.Start(ctx))
+		subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+			return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+		})
+		return subject
+	}
+	first := newEngine("/provider/first")
+	t.Cleanup(func() { first.Shutdown() })
+	second := newEngine("provider/second")
+	t.Cleanup(func() { second.Shutdown() })
+
+	firstAd, err := first.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	_, err = second.NotifyPut(ctx, nil, []byte("lobster"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	contextIDs, err := first.ListContextIDs(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("fish")}, contextIDs)
+	contextIDs, err = second.ListContextIDs(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("lobster")}, contextIDs)
+
+	// All keys of the engines are under their namespace.
+	results, err := ds.Query(ctx, query.Query{KeysOnly: true})
+	require.NoError(t, err)
+	entries, err := results.Rest()
+	require.NoError(t, err)
+	for _, entry := range entries {
+		if entry.Key != "/app/fish" {
+			require.True(t, strings.HasPrefix(entry.Key, "/provider/first/") || strings.HasPrefix(entry.Key, "/provider/second/"), entry.Key)
+		}
+	}
+
+	// An engine given the same namespace again finds its advertisements.
+	require.NoError(t, first.Shutdown())
+	again := newEngine("provider/first")
+	t.Cleanup(func() { again.Shutdown() })
+	latest, _, err := again.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	require.Equal(t, firstAd, latest)
+
+	_, err = engine.New(engine.WithDatastoreNamespace("/"))
+	require.ErrorContains(t, err, "must not be empty")
+}
+
+func TestEngine_PublishWritesMappingsInBatch(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	ds := &batchingDatastore{Batching: dssync.MutexWrap(datastore.NewMapDatastore())}
+	subject, err := engine.New(engine.WithDatastore(ds))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	mhs := test.RandomMultihashes(3)
+	var listed []string
+	subject.RegisterMultihashLister(func(_ context.Context, _ peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		listed = append(listed, string(contextID))
+		return provider.SliceMultihashIterator(mhs), nil
+	})
+
+	head, err := subject.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	reused, err := subject.GetContextInfo(ctx, "", []byte("fish"))
+	require.NoError(t, err)
+	_, err = subject.NotifyPutWithEntries(ctx, nil, []byte("lobster"), metadata.Default.New(metadata.Bitswap{}), reused.Entries)
+	require.NoError(t, err)
+	head, err = subject.NotifyRemove(ctx, "", []byte("fish"))
+	require.NoError(t, err)
+	require.Zero(t, ds.unbatchedPuts)
+
+	// Nothing is written unless the reference to the advertisement is.
+	ds.failCommit = true
+	_, err = subject.NotifyPut(ctx, nil, []byte("crab"), metadata.Default.New(metadata.Bitswap{}))
+	require.ErrorContains(t, err, "fish")
+	_, err = subject.NotifyRemove(ctx, "", []byte("lobster"))
+	require.ErrorContains(t, err, "fish")
+
+	contextIDs, err := subject.ListContextIDs(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("lobster")}, contextIDs)
+	_, err = subject.GetContextInfo(ctx, "", []byte("crab"))
+	require.ErrorIs(t, err, provider.ErrContextIDNotFound)
+	latest, _, err := subject.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	require.Equal(t, head, latest)
+	stats, err := subject.Stats(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, stats.Multihashes)
+
+	// The entries released by fish are regenerated from lobster.
+	entries := reused.Entries.String()
+	require.NoError(t, subject.Datastore().Delete(ctx, datastore.NewKey(entries)))
+	require.NoError(t, subject.Datastore().Delete(ctx, datastore.NewKey("/cache/links/"+entries)))
+	requireLoadEntryChunkFromEngine(t, subject, cidlink.Link{Cid: reused.Entries})
+	require.Equal(t, "lobster", listed[len(listed)-1])
+
+	ds.failCommit = false
+	_, err = subject.NotifyRemove(ctx, "", []byte("lobster"))
+	require.NoError(t, err)
+	stats, err = subject.Stats(ctx)
+	require.NoError(t, err)
+	require.Zero(t, stats.Multihashes)
+}
+
 func createAd(t *testing.T, contextID []byte, provider string, addrs []string, entries string, isRm bool, prevId string) *schema.Advertisement {
 	var prevLink ipld.Link
 	if prevId != "" {
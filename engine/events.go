@@ -0,0 +1,123 @@
+package engine
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// PublishEventKind is the kind of a PublishEvent.
+type PublishEventKind int
+
+const (
+	// AdPublished signals that an advertisement was stored and marked locally
+	// as the latest advertisement.
+	AdPublished PublishEventKind = iota
+	// AdAnnounced signals that the announcement of an advertisement was sent,
+	// or failed to be sent.
+	AdAnnounced
+)
+
+func (k PublishEventKind) String() string {
+	switch k {
+	case AdPublished:
+		return "published"
+	case AdAnnounced:
+		return "announced"
+	default:
+		return "unknown"
+	}
+}
+
+// PublishEvent describes an advertisement being published or announced by the
+// engine.
+type PublishEvent struct {
+	Kind PublishEventKind
+	// AdCid is the CID of the published or announced advertisement.
+	AdCid cid.Cid
+	// IsRm is whether the advertisement is a removal advertisement. It is only
+	// set for AdPublished events.
+	IsRm bool
+	// IsUpdate is whether the advertisement only updates the metadata of
+	// content already advertised, linking to the same entries. It is only set
+	// for AdPublished events. See: Engine.UpdateMetadata.
+	IsUpdate bool
+	// Skipped is the number of multihashes that the multihash lister failed to
+	// list when generating the entries of the advertisement, and that are
+	// therefore not advertised. See: provider.SkipMultihash. It is only set
+	// for AdPublished events, and for split content, only for the
+	// advertisement of its last part.
+	Skipped int
+	// Time is the time at which the event occurred.
+	Time time.Time
+	// Err is the error with which the announcement failed, if any.
+	Err error
+}
+
+// publishEventsBufferSize is the number of events buffered for each
+// subscriber, beyond which events are dropped for that subscriber.
+const publishEventsBufferSize = 64
+
+// publishEvents delivers publish events to subscribers.
+type publishEvents struct {
+	mutex  sync.Mutex
+	subs   map[chan PublishEvent]struct{}
+	closed bool
+}
+
+// SubscribePublishEvents subscribes to the events of advertisements being
+// published and announced by the engine. It returns the channel on which
+// events are delivered, and a function that cancels the subscription and
+// closes the channel.
+//
+// Events are dropped for subscribers that do not keep up with them. The
+// channel is closed when the engine is shut down.
+func (e *Engine) SubscribePublishEvents() (<-chan PublishEvent, func()) {
+	return e.events.subscribe()
+}
+
+func (p *publishEvents) subscribe() (<-chan PublishEvent, func()) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	ch := make(chan PublishEvent, publishEventsBufferSize)
+	if p.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	if p.subs == nil {
+		p.subs = make(map[chan PublishEvent]struct{})
+	}
+	p.subs[ch] = struct{}{}
+	cancel := func() {
+		p.mutex.Lock()
+		defer p.mutex.Unlock()
+		if _, ok := p.subs[ch]; ok {
+			delete(p.subs, ch)
+			close(ch)
+		}
+	}
+	return ch, cancel
+}
+
+func (p *publishEvents) emit(event PublishEvent) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for ch := range p.subs {
+		select {
+		case ch <- event:
+		default:
+			log.Warnw("Dropped publish event for slow subscriber", "kind", event.Kind, "adCid", event.AdCid)
+		}
+	}
+}
+
+func (p *publishEvents) close() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for ch := range p.subs {
+		close(ch)
+	}
+	p.subs = nil
+	p.closed = true
+}
@@ -0,0 +1,64 @@
+package engine_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_SubscribePublishEvents(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	indexer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer indexer.Close()
+
+	subject, err := engine.New(
+		engine.WithPublisherKind(engine.HttpPublisher),
+		engine.WithHttpPublisherWithoutServer(),
+		engine.WithHttpPublisherAnnounceAddr("/ip4/127.0.0.1/tcp/3104/http"),
+		engine.WithPubsubAnnounce(false),
+		engine.WithDirectAnnounce(indexer.URL))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+
+	events, unsubscribe := subject.SubscribePublishEvents()
+	adCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+
+	event := <-events
+	require.Equal(t, engine.AdPublished, event.Kind)
+	require.Equal(t, adCid, event.AdCid)
+	require.False(t, event.IsRm)
+	require.False(t, event.Time.IsZero())
+
+	event = <-events
+	require.Equal(t, engine.AdAnnounced, event.Kind)
+	require.Equal(t, adCid, event.AdCid)
+	require.NoError(t, event.Err)
+
+	// Unsubscribing closes the channel, and is idempotent.
+	unsubscribe()
+	unsubscribe()
+	_, ok := <-events
+	require.False(t, ok)
+
+	// Shutting down the engine closes the channels of subscribers.
+	events, _ = subject.SubscribePublishEvents()
+	require.NoError(t, subject.Shutdown())
+	_, ok = <-events
+	require.False(t, ok)
+}
@@ -0,0 +1,134 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// externalAddrsTracker follows the changes of the addresses and reachability
+// of the libp2p host, to advertise its external addresses as the retrieval
+// addresses of the default provider. See: WithExternalRetrievalAddrs.
+type externalAddrsTracker struct {
+	sub    event.Subscription
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// startExternalAddrs starts tracking the external addresses of the host, if
+// enabled and no retrieval addresses are configured.
+func (e *Engine) startExternalAddrs() error {
+	if e.externalAddrsQuiet <= 0 || !e.retrievalAddrsFromHost {
+		return nil
+	}
+	sub, err := e.h.EventBus().Subscribe([]any{new(event.EvtLocalAddressesUpdated), new(event.EvtLocalReachabilityChanged)})
+	if err != nil {
+		return fmt.Errorf("cannot subscribe to address changes of libp2p host: %w", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	e.externalAddrs = &externalAddrsTracker{
+		sub:    sub,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go e.trackExternalAddrs(ctx, e.externalAddrs)
+	return nil
+}
+
+// stopExternalAddrs stops tracking the external addresses of the host, and
+// waits for the republishing of content under changed addresses, if any, to
+// stop.
+func (e *Engine) stopExternalAddrs() error {
+	t := e.externalAddrs
+	if t == nil {
+		return nil
+	}
+	e.externalAddrs = nil
+	t.cancel()
+	err := t.sub.Close()
+	<-t.done
+	return err
+}
+
+// trackExternalAddrs changes the retrieval addresses of the default provider
+// to the external addresses of the host once its addresses and reachability
+// have not changed for the quiet period, so that changes that come in bursts,
+// e.g. as peers report the address they observe, are republished once.
+func (e *Engine) trackExternalAddrs(ctx context.Context, t *externalAddrsTracker) {
+	defer close(t.done)
+	reachability := network.ReachabilityUnknown
+	// Check the addresses known at start once settled too.
+	timer := time.NewTimer(e.externalAddrsQuiet)
+	defer timer.Stop()
+	for {
+		select {
+		case evt, ok := <-t.sub.Out():
+			if !ok {
+				return
+			}
+			if changed, ok := evt.(event.EvtLocalReachabilityChanged); ok {
+				reachability = changed.Reachability
+			}
+			timer.Reset(e.externalAddrsQuiet)
+		case <-timer.C:
+			addrs := externalRetrievalAddrs(e.h.Addrs(), reachability)
+			e.publishLock.Lock()
+			current := e.provider.Addrs
+			e.publishLock.Unlock()
+			if sameAddrs(addrs, current) {
+				continue
+			}
+			log.Infow("External addresses of libp2p host changed; republishing content", "retrievalAddrs", addrs, "reachability", reachability)
+			if _, err := e.NotifyAddrsChanged(ctx, addrs); err != nil {
+				log.Errorw("Failed to republish content under external addresses of libp2p host", "err", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// externalRetrievalAddrs returns the addresses of the host to advertise for
+// retrieval: its public addresses, i.e. those observed by its peers or mapped
+// in the NAT device, unless AutoNAT found that the host is not reachable at
+// them, in which case, or if it has none, all its addresses.
+func externalRetrievalAddrs(addrs []multiaddr.Multiaddr, reachability network.Reachability) []multiaddr.Multiaddr {
+	if reachability == network.ReachabilityPrivate {
+		return addrs
+	}
+	var public []multiaddr.Multiaddr
+	for _, addr := range addrs {
+		if manet.IsPublicAddr(addr) {
+			public = append(public, addr)
+		}
+	}
+	if len(public) == 0 {
+		return addrs
+	}
+	return public
+}
+
+// sameAddrs returns whether the given addresses are the same, regardless of
+// their order.
+func sameAddrs(a, b []multiaddr.Multiaddr) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	as := make([]string, len(a))
+	for i, addr := range a {
+		as[i] = addr.String()
+	}
+	bs := make([]string, len(b))
+	for i, addr := range b {
+		bs[i] = addr.String()
+	}
+	slices.Sort(as)
+	slices.Sort(bs)
+	return slices.Equal(as, bs)
+}
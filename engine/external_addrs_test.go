@@ -0,0 +1,87 @@
+package engine_test
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_WithExternalRetrievalAddrs(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	// The host is observed at the external address once set.
+	var lock sync.Mutex
+	var external []multiaddr.Multiaddr
+	h, err := libp2p.New(
+		libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"),
+		libp2p.AddrsFactory(func(addrs []multiaddr.Multiaddr) []multiaddr.Multiaddr {
+			lock.Lock()
+			defer lock.Unlock()
+			return append(addrs, external...)
+		}))
+	require.NoError(t, err)
+	t.Cleanup(func() { h.Close() })
+	listenAddrs := h.Addrs()
+	addrsEmitter, err := h.EventBus().Emitter(new(event.EvtLocalAddressesUpdated))
+	require.NoError(t, err)
+	t.Cleanup(func() { addrsEmitter.Close() })
+	reachabilityEmitter, err := h.EventBus().Emitter(new(event.EvtLocalReachabilityChanged))
+	require.NoError(t, err)
+	t.Cleanup(func() { reachabilityEmitter.Close() })
+
+	subject, err := engine.New(engine.WithHost(h), engine.WithExternalRetrievalAddrs(10*time.Millisecond))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	t.Cleanup(func() { subject.Shutdown() })
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+	adCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	ad, err := subject.GetAdv(ctx, adCid)
+	require.NoError(t, err)
+	require.Equal(t, addrStrings(listenAddrs), ad.Addresses)
+
+	requireRepublishedWith := func(want []multiaddr.Multiaddr) {
+		require.Eventually(t, func() bool {
+			_, latest, err := subject.GetLatestAdv(ctx)
+			require.NoError(t, err)
+			return string(latest.ContextID) == "fish" && slices.Equal(addrStrings(want), latest.Addresses)
+		}, testTimeout, 10*time.Millisecond)
+	}
+
+	// Content is republished under the public address once observed.
+	publicAddr := multiaddr.StringCast("/ip4/1.2.3.4/tcp/3103")
+	lock.Lock()
+	external = []multiaddr.Multiaddr{publicAddr}
+	lock.Unlock()
+	require.NoError(t, addrsEmitter.Emit(event.EvtLocalAddressesUpdated{}))
+	requireRepublishedWith([]multiaddr.Multiaddr{publicAddr})
+	require.Equal(t, []string{publicAddr.String()}, subject.ProviderAddrs())
+
+	// Unless the host is not reachable at it.
+	require.NoError(t, reachabilityEmitter.Emit(event.EvtLocalReachabilityChanged{Reachability: network.ReachabilityPrivate}))
+	requireRepublishedWith(append(listenAddrs, publicAddr))
+}
+
+func addrStrings(addrs []multiaddr.Multiaddr) []string {
+	strs := make([]string, len(addrs))
+	for i, addr := range addrs {
+		strs[i] = addr.String()
+	}
+	return strs
+}
@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/failstore"
+)
+
+// ErrInjectedFault is the error of the failures injected via WithFaults.
+var ErrInjectedFault = errors.New("injected fault")
+
+// Faults configures failures that the engine injects at random, so that
+// operators can check that their alerting and retries handle them, e.g. in
+// staging deployments. Each rate is the probability, between 0 and 1, that
+// the fault is injected into each operation. Faults must not be injected in
+// production.
+type Faults struct {
+	// AnnounceFailureRate is the rate at which sending an announcement to
+	// each target, i.e. indexer URL, gossip pubsub topic or sender added via
+	// WithAnnounceSender, fails without it being sent.
+	AnnounceFailureRate float64
+	// SlowListerRate is the rate at which calls to the registered
+	// provider.MultihashLister are delayed by SlowListerDelay.
+	SlowListerRate  float64
+	SlowListerDelay time.Duration
+	// DatastoreWriteFailureRate is the rate at which writes to the datastore
+	// of the engine fail, including the commit of batches.
+	DatastoreWriteFailureRate float64
+}
+
+// validate checks that the rates of the faults are probabilities.
+func (f Faults) validate() error {
+	for name, rate := range map[string]float64{
+		"announce failure":        f.AnnounceFailureRate,
+		"slow lister":             f.SlowListerRate,
+		"datastore write failure": f.DatastoreWriteFailureRate,
+	} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("%s rate must be between 0 and 1, got %v", name, rate)
+		}
+	}
+	if f.SlowListerDelay < 0 {
+		return fmt.Errorf("slow lister delay must not be negative, got %s", f.SlowListerDelay)
+	}
+	return nil
+}
+
+// enabled returns whether any fault is injected.
+func (f Faults) enabled() bool {
+	return f.AnnounceFailureRate > 0 || (f.SlowListerRate > 0 && f.SlowListerDelay > 0) || f.DatastoreWriteFailureRate > 0
+}
+
+// injectFault returns whether to inject a fault of the given rate.
+func injectFault(rate float64) bool {
+	return rate > 0 && rand.Float64() < rate
+}
+
+// faultyDatastore wraps ds so that its writes fail at the given rate.
+func faultyDatastore(ds datastore.Batching, rate float64) datastore.Batching {
+	return failstore.NewFailstore(ds, func(op string) error {
+		switch op {
+		case "put", "delete", "batch-put", "batch-delete", "batch-commit":
+			if injectFault(rate) {
+				return fmt.Errorf("cannot %s: %w", op, ErrInjectedFault)
+			}
+		}
+		return nil
+	})
+}
+
+// delayLister delays the call to the multihash lister at the rate configured
+// via WithFaults, unless the context is done first.
+func (e *Engine) delayLister(ctx context.Context) error {
+	if !injectFault(e.faults.SlowListerRate) {
+		return nil
+	}
+	timer := time.NewTimer(e.faults.SlowListerDelay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
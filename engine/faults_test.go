@@ -0,0 +1,67 @@
+package engine_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_WithFaults(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	_, err := engine.New(engine.WithFaults(engine.Faults{AnnounceFailureRate: 1.5}))
+	require.ErrorContains(t, err, "announce failure rate must be between 0 and 1")
+
+	md := metadata.Default.New(metadata.Bitswap{})
+	newEngine := func(faults engine.Faults, o ...engine.Option) *engine.Engine {
+		subject, err := engine.New(append(o, engine.WithFaults(faults))...)
+		require.NoError(t, err)
+		require.NoError(t, subject.Start(ctx))
+		t.Cleanup(func() { subject.Shutdown() })
+		subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+			return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+		})
+		return subject
+	}
+
+	// Advertisements are published, but announcements fail without being sent.
+	sender := &recordingSender{}
+	subject := newEngine(engine.Faults{AnnounceFailureRate: 1},
+		engine.WithPublisherKind(engine.HttpPublisher),
+		engine.WithHttpPublisherWithoutServer(),
+		engine.WithHttpPublisherAnnounceAddr("/ip4/127.0.0.1/tcp/3104/http"),
+		engine.WithPubsubAnnounce(false),
+		engine.WithAnnounceSender(sender))
+	_, err = subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.NoError(t, err)
+	require.Empty(t, sender.sent)
+	stats, err := subject.Stats(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), stats.AnnounceFailures)
+
+	// The lister is delayed until the context is done.
+	subject = newEngine(engine.Faults{SlowListerRate: 1, SlowListerDelay: time.Hour})
+	listCtx, listCancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer listCancel()
+	_, err = subject.NotifyPut(listCtx, nil, []byte("fish"), md)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Writes to the datastore fail, while reads succeed.
+	subject = newEngine(engine.Faults{DatastoreWriteFailureRate: 1},
+		engine.WithDatastore(dssync.MutexWrap(datastore.NewMapDatastore())))
+	_, err = subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.ErrorIs(t, err, engine.ErrInjectedFault)
+	contextIDs, err := subject.ListContextIDs(ctx, "")
+	require.NoError(t, err)
+	require.Empty(t, contextIDs)
+}
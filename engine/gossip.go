@@ -0,0 +1,286 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/go-libipni/announce/p2psender"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	pubsubpb "github.com/libp2p/go-libp2p-pubsub/pb"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"golang.org/x/crypto/blake2b"
+)
+
+// gossipDirectConnectTicks makes pubsub check connections to direct peers
+// every N heartbeats, as does the pubsub of go-libipni announce senders.
+const gossipDirectConnectTicks uint64 = 30
+
+// GossipDiagnostics describes the state of announcing advertisements over
+// gossip pubsub, to tell whether anyone receives the announcements.
+type GossipDiagnostics struct {
+	// Topic is the name of the topic on which announcements are published.
+	Topic string
+	// Enabled is whether announcements are published over gossip pubsub.
+	Enabled bool
+	// TopicPeers are the peers known to be subscribed to the topic, which
+	// announcements are published to.
+	TopicPeers []peer.ID
+	// MeshKnown is whether the gossipsub mesh of the topic is known, which it
+	// is only if the engine joins the mesh. See: WithGossipMesh.
+	MeshKnown bool
+	// MeshPeers are the peers in the gossipsub mesh of the topic, which relay
+	// announcements to peers the provider is not connected to.
+	MeshPeers []peer.ID
+	// Indexers describes whether each of the indexer peers given via
+	// WithIndexerPeers is reached by announcements.
+	Indexers []IndexerPeerStatus
+	// LastPropagated is the time at which an announcement was last published
+	// while at least one peer was subscribed to the topic, or zero if none
+	// was since the engine started.
+	LastPropagated time.Time
+	// LastPropagatedAd is the CID of the advertisement last announced at
+	// LastPropagated.
+	LastPropagatedAd cid.Cid
+	// LastPropagatedPeers is the number of peers subscribed to the topic at
+	// LastPropagated.
+	LastPropagatedPeers int
+}
+
+// IndexerPeerStatus describes whether an indexer peer is reached by the
+// announcements published over gossip pubsub.
+type IndexerPeerStatus struct {
+	// ID is the peer ID of the indexer.
+	ID peer.ID
+	// Connected is whether the provider is connected to the indexer.
+	Connected bool
+	// InTopic is whether the indexer is known to be subscribed to the topic.
+	InTopic bool
+	// InMesh is whether the indexer is in the gossipsub mesh of the topic.
+	InMesh bool
+}
+
+// gossip holds the gossipsub mesh of the announce topic, when the engine joins
+// the mesh, and the last announcement that was propagated over the topic.
+type gossip struct {
+	// cancel shuts down the pubsub of the topic joined by the engine, if any.
+	cancel context.CancelFunc
+	// sub is the subscription to the topic by which the engine joins its
+	// mesh, if any.
+	sub *pubsub.Subscription
+
+	lock sync.Mutex
+	// mesh holds the peers in the gossipsub mesh of the topic, and is nil if
+	// the mesh is not traced.
+	mesh                map[peer.ID]struct{}
+	lastPropagated      time.Time
+	lastPropagatedAd    cid.Cid
+	lastPropagatedPeers int
+}
+
+// joinGossipTopic creates a gossipsub router configured as is that of
+// go-libipni announce senders and joins the announce topic. If the engine
+// joins the mesh of the topic, then it subscribes to the topic, discarding the
+// messages it receives, and traces the mesh. The topic is left, and the router
+// shut down, by leaveGossipTopic.
+func (e *Engine) joinGossipTopic() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	opts := []pubsub.Option{
+		pubsub.WithPeerExchange(len(e.trustedIndexers) == 0),
+		pubsub.WithMessageIdFn(func(pmsg *pubsubpb.Message) string {
+			h, _ := blake2b.New256(nil)
+			h.Write(pmsg.Data)
+			return string(h.Sum(nil))
+		}),
+		pubsub.WithFloodPublish(len(e.trustedIndexers) == 0),
+		pubsub.WithDirectConnectTicks(gossipDirectConnectTicks),
+		pubsub.WithRawTracer(&meshTracer{g: &e.gossip, topic: e.pubTopicName}),
+	}
+	// Only exchange announcements with the trusted indexers of a private
+	// network, to which they are sent directly. Flood publishing is disabled
+	// above, since it sends announcements to all peers in the topic.
+	if len(e.trustedIndexers) != 0 {
+		trusted := make(map[peer.ID]struct{}, len(e.trustedIndexers))
+		for _, indexer := range e.trustedIndexers {
+			trusted[indexer.ID] = struct{}{}
+		}
+		opts = append(opts,
+			pubsub.WithDirectPeers(e.trustedIndexers),
+			pubsub.WithPeerFilter(func(p peer.ID, _ string) bool {
+				_, ok := trusted[p]
+				return ok
+			}))
+	}
+	gossipSub, err := pubsub.NewGossipSub(ctx, e.h, opts...)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("cannot create gossip pubsub: %w", err)
+	}
+	e.pubTopic, err = gossipSub.Join(e.pubTopicName)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("cannot join topic %s: %w", e.pubTopicName, err)
+	}
+	e.gossip.cancel = cancel
+	if !e.gossipMesh {
+		return nil
+	}
+
+	e.gossip.lock.Lock()
+	e.gossip.mesh = make(map[peer.ID]struct{})
+	e.gossip.lock.Unlock()
+	if e.gossip.sub, err = e.pubTopic.Subscribe(); err != nil {
+		_ = e.leaveGossipTopic()
+		return fmt.Errorf("cannot subscribe to topic %s: %w", e.pubTopicName, err)
+	}
+	go func(sub *pubsub.Subscription) {
+		for {
+			if _, err := sub.Next(ctx); err != nil {
+				return
+			}
+		}
+	}(e.gossip.sub)
+	return nil
+}
+
+// leaveGossipTopic leaves the topic joined by joinGossipTopic, if any.
+func (e *Engine) leaveGossipTopic() error {
+	if e.gossip.cancel == nil {
+		return nil
+	}
+	if e.gossip.sub != nil {
+		e.gossip.sub.Cancel()
+		e.gossip.sub = nil
+	}
+	err := e.pubTopic.Close()
+	e.gossip.cancel()
+	e.gossip.cancel = nil
+	e.pubTopic = nil
+	return err
+}
+
+// propagated records that the announcement of the given advertisement was
+// published over gossip pubsub, if any peer was subscribed to the topic.
+func (g *gossip) propagated(adCid cid.Cid, topic *pubsub.Topic) {
+	peers := len(topic.ListPeers())
+	if peers == 0 {
+		return
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.lastPropagated = time.Now()
+	g.lastPropagatedAd = adCid
+	g.lastPropagatedPeers = peers
+}
+
+// GossipDiagnostics reports the peers that announcements published over
+// gossip pubsub reach, whether the indexer peers given via WithIndexerPeers
+// are among them, and when an announcement last reached any peer.
+func (e *Engine) GossipDiagnostics() GossipDiagnostics {
+	diag := GossipDiagnostics{Topic: e.pubTopicName}
+	e.sendersLock.RLock()
+	for _, sender := range e.senders {
+		if _, ok := sender.(*p2psender.Sender); ok {
+			diag.Enabled = true
+		}
+	}
+	e.sendersLock.RUnlock()
+
+	e.gossip.lock.Lock()
+	if e.gossip.mesh != nil {
+		diag.MeshKnown = true
+		diag.MeshPeers = make([]peer.ID, 0, len(e.gossip.mesh))
+		for p := range e.gossip.mesh {
+			diag.MeshPeers = append(diag.MeshPeers, p)
+		}
+	}
+	diag.LastPropagated = e.gossip.lastPropagated
+	diag.LastPropagatedAd = e.gossip.lastPropagatedAd
+	diag.LastPropagatedPeers = e.gossip.lastPropagatedPeers
+	e.gossip.lock.Unlock()
+	sort.Slice(diag.MeshPeers, func(i, j int) bool { return diag.MeshPeers[i] < diag.MeshPeers[j] })
+
+	inTopic := make(map[peer.ID]bool)
+	if diag.Enabled && e.pubTopic != nil {
+		diag.TopicPeers = e.pubTopic.ListPeers()
+		sort.Slice(diag.TopicPeers, func(i, j int) bool { return diag.TopicPeers[i] < diag.TopicPeers[j] })
+		for _, p := range diag.TopicPeers {
+			inTopic[p] = true
+		}
+	}
+	inMesh := make(map[peer.ID]bool, len(diag.MeshPeers))
+	for _, p := range diag.MeshPeers {
+		inMesh[p] = true
+	}
+	for _, p := range e.indexerPeers {
+		status := IndexerPeerStatus{ID: p, InTopic: inTopic[p], InMesh: inMesh[p]}
+		if e.h != nil {
+			status.Connected = e.h.Network().Connectedness(p) == network.Connected
+		}
+		diag.Indexers = append(diag.Indexers, status)
+	}
+	return diag
+}
+
+// meshTracer is a pubsub.RawTracer that keeps track of the peers in the
+// gossipsub mesh of a topic.
+type meshTracer struct {
+	g     *gossip
+	topic string
+}
+
+var _ pubsub.RawTracer = (*meshTracer)(nil)
+
+func (t *meshTracer) Graft(p peer.ID, topic string) {
+	if topic != t.topic {
+		return
+	}
+	t.g.lock.Lock()
+	if t.g.mesh != nil {
+		t.g.mesh[p] = struct{}{}
+	}
+	t.g.lock.Unlock()
+	log.Debugw("Peer grafted to announce topic mesh", "peer", p)
+}
+
+func (t *meshTracer) Prune(p peer.ID, topic string) {
+	if topic != t.topic {
+		return
+	}
+	t.g.lock.Lock()
+	delete(t.g.mesh, p)
+	t.g.lock.Unlock()
+	log.Debugw("Peer pruned from announce topic mesh", "peer", p)
+}
+
+func (t *meshTracer) RemovePeer(p peer.ID) {
+	t.g.lock.Lock()
+	delete(t.g.mesh, p)
+	t.g.lock.Unlock()
+}
+
+func (t *meshTracer) Leave(topic string) {
+	if topic != t.topic {
+		return
+	}
+	t.g.lock.Lock()
+	clear(t.g.mesh)
+	t.g.lock.Unlock()
+}
+
+func (*meshTracer) AddPeer(peer.ID, protocol.ID)          {}
+func (*meshTracer) Join(string)                           {}
+func (*meshTracer) ValidateMessage(*pubsub.Message)       {}
+func (*meshTracer) DeliverMessage(*pubsub.Message)        {}
+func (*meshTracer) RejectMessage(*pubsub.Message, string) {}
+func (*meshTracer) DuplicateMessage(*pubsub.Message)      {}
+func (*meshTracer) ThrottlePeer(peer.ID)                  {}
+func (*meshTracer) RecvRPC(*pubsub.RPC)                   {}
+func (*meshTracer) SendRPC(*pubsub.RPC, peer.ID)          {}
+func (*meshTracer) DropRPC(*pubsub.RPC, peer.ID)          {}
+func (*meshTracer) UndeliverableMessage(*pubsub.Message)  {}
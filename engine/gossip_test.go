@@ -0,0 +1,160 @@
+package engine_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/ipni/index-provider/testutil"
+	"github.com/libp2p/go-libp2p"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_GossipDiagnostics(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+	const topic = "test-topic"
+
+	indexerHost, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	t.Cleanup(func() { indexerHost.Close() })
+	absentIndexer, _, _ := test.RandomIdentity()
+
+	subject, err := engine.New(
+		engine.WithPublisherKind(engine.Libp2pPublisher),
+		engine.WithTopicName(topic),
+		engine.WithHostListenAddrs("/ip4/127.0.0.1/tcp/0"),
+		engine.WithGossipMesh(true),
+		engine.WithIndexerPeers(indexerHost.ID(), absentIndexer))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	t.Cleanup(func() { subject.Shutdown() })
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+
+	diag := subject.GossipDiagnostics()
+	require.Equal(t, topic, diag.Topic)
+	require.True(t, diag.Enabled)
+	require.True(t, diag.MeshKnown)
+	require.Empty(t, diag.TopicPeers)
+	require.Empty(t, diag.MeshPeers)
+	require.Equal(t, []engine.IndexerPeerStatus{{ID: indexerHost.ID()}, {ID: absentIndexer}}, diag.Indexers)
+
+	// Announcements published while nobody listens are not propagated.
+	_, err = subject.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	require.True(t, subject.GossipDiagnostics().LastPropagated.IsZero())
+
+	indexerG, err := pubsub.NewGossipSub(ctx, indexerHost)
+	require.NoError(t, err)
+	indexerT, err := indexerG.Join(topic)
+	require.NoError(t, err)
+	sub, err := indexerT.Subscribe()
+	require.NoError(t, err)
+	defer sub.Cancel()
+	require.NoError(t, indexerHost.Connect(ctx, testutil.WaitForAddrs(subject.Host())))
+
+	require.Eventually(t, func() bool {
+		diag = subject.GossipDiagnostics()
+		return len(diag.TopicPeers) == 1 && len(diag.MeshPeers) == 1
+	}, 10*time.Second, 100*time.Millisecond, "timed out waiting for indexer to join the mesh")
+	require.Equal(t, []peer.ID{indexerHost.ID()}, diag.TopicPeers)
+	require.Equal(t, []peer.ID{indexerHost.ID()}, diag.MeshPeers)
+	require.Equal(t, []engine.IndexerPeerStatus{
+		{ID: indexerHost.ID(), Connected: true, InTopic: true, InMesh: true},
+		{ID: absentIndexer},
+	}, diag.Indexers)
+
+	adCid, err := subject.NotifyPut(ctx, nil, []byte("lobster"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	diag = subject.GossipDiagnostics()
+	require.False(t, diag.LastPropagated.IsZero())
+	require.Equal(t, adCid, diag.LastPropagatedAd)
+	require.Equal(t, 1, diag.LastPropagatedPeers)
+	msg, err := sub.Next(ctx)
+	require.NoError(t, err)
+	require.Equal(t, subject.Host().ID(), msg.GetFrom())
+
+	// The mesh of a topic given via WithTopic is unknown.
+	given, err := engine.New(
+		engine.WithPublisherKind(engine.Libp2pPublisher),
+		engine.WithHost(indexerHost),
+		engine.WithTopic(indexerT))
+	require.NoError(t, err)
+	require.NoError(t, given.Start(ctx))
+	defer given.Shutdown()
+	diag = given.GossipDiagnostics()
+	require.True(t, diag.Enabled)
+	require.False(t, diag.MeshKnown)
+	require.Equal(t, []peer.ID{subject.Host().ID()}, diag.TopicPeers)
+	require.Equal(t, cid.Undef, diag.LastPropagatedAd)
+}
+
+func TestEngine_WithTrustedIndexers(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+	const topic = engine.TopicNamespace + "acme"
+
+	joinTopic := func() (*pubsub.Subscription, host.Host) {
+		h, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+		require.NoError(t, err)
+		t.Cleanup(func() { h.Close() })
+		g, err := pubsub.NewGossipSub(ctx, h)
+		require.NoError(t, err)
+		topic, err := g.Join(topic)
+		require.NoError(t, err)
+		sub, err := topic.Subscribe()
+		require.NoError(t, err)
+		t.Cleanup(sub.Cancel)
+		return sub, h
+	}
+	trustedSub, trustedHost := joinTopic()
+	untrustedSub, untrustedHost := joinTopic()
+	trustedID := trustedHost.ID()
+
+	subject, err := engine.New(
+		engine.WithPublisherKind(engine.Libp2pPublisher),
+		engine.WithNetwork("acme"),
+		engine.WithHostListenAddrs("/ip4/127.0.0.1/tcp/0"),
+		engine.WithTrustedIndexers(peer.AddrInfo{ID: trustedID}))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	t.Cleanup(func() { subject.Shutdown() })
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+	require.Equal(t, topic, subject.GossipDiagnostics().Topic)
+
+	// Both indexers subscribe to the topic, but only the trusted one is sent
+	// announcements.
+	for _, h := range []host.Host{trustedHost, untrustedHost} {
+		require.NoError(t, h.Connect(ctx, testutil.WaitForAddrs(subject.Host())))
+	}
+	require.Eventually(t, func() bool {
+		return len(subject.GossipDiagnostics().TopicPeers) == 2
+	}, 10*time.Second, 100*time.Millisecond, "timed out waiting for indexers to join the topic")
+	require.Equal(t, []engine.IndexerPeerStatus{{ID: trustedID, Connected: true, InTopic: true}}, subject.GossipDiagnostics().Indexers)
+
+	adCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	msg, err := trustedSub.Next(ctx)
+	require.NoError(t, err)
+	require.Equal(t, subject.Host().ID(), msg.GetFrom())
+	require.Equal(t, adCid, subject.GossipDiagnostics().LastPropagatedAd)
+	untrustedCtx, untrustedCancel := context.WithTimeout(ctx, time.Second)
+	defer untrustedCancel()
+	_, err = untrustedSub.Next(untrustedCtx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	_, err = engine.New(engine.WithNetwork("acme/private"))
+	require.ErrorContains(t, err, "invalid network name")
+}
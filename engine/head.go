@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	headschema "github.com/ipni/go-libipni/dagsync/ipnisync/head"
+)
+
+// HeadInfo describes the head of the advertisement chain, for monitoring
+// systems to track its movement without fetching advertisements.
+type HeadInfo struct {
+	// Head is the CID of the latest advertisement.
+	Head cid.Cid
+	// Topic is the topic on which the head is announced.
+	Topic string
+	// PubKey is the marshaled public key of the engine, and Sig the signature
+	// of the head and topic by its private key, as in the signed head served
+	// by IPNI HTTP publishers at /ipni/v1/ad/head.
+	PubKey []byte
+	Sig    []byte
+	// Height is the sequence number of the head if it has one, see:
+	// WithAdSequence, and otherwise the number of advertisements in the chain
+	// ending at the head that are kept, which is lower than its height once
+	// the chain is pruned.
+	Height uint64
+	// Published is when the head was published, or the zero time if unknown.
+	Published time.Time
+}
+
+// GetHeadInfo returns the head of the advertisement chain signed with the key
+// of the engine, or nil if no advertisements have been published.
+func (e *Engine) GetHeadInfo(ctx context.Context) (*HeadInfo, error) {
+	head, err := e.getLatestAdCid(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not get latest advertisement: %w", err)
+	}
+	if head == cid.Undef {
+		return nil, nil
+	}
+	_, key := e.identity()
+	signed, err := headschema.NewSignedHead(head, e.pubTopicName, key)
+	if err != nil {
+		return nil, fmt.Errorf("cannot sign head: %w", err)
+	}
+	info := &HeadInfo{
+		Head:   head,
+		Topic:  e.pubTopicName,
+		PubKey: signed.Pubkey,
+		Sig:    signed.Sig,
+	}
+	ad, err := e.loadAd(ctx, head)
+	if err != nil {
+		return nil, err
+	}
+	if seq, err := GetAdSequence(ad.Metadata); err == nil && seq != nil {
+		info.Height = seq.Seq
+	} else {
+		chainLen, err := e.chainLength(ctx, head)
+		if err != nil {
+			return nil, err
+		}
+		info.Height = uint64(chainLen)
+	}
+	if info.Published, err = e.getAdTime(ctx, head); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
@@ -0,0 +1,54 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+)
+
+var dsHealthProbeKey = datastore.NewKey("health/probe")
+
+// Ready checks whether the engine is ready to publish advertisements: the
+// engine must be started, its publisher, if any, must be listening, and its
+// datastore must be writable. A nil error is returned if the engine is ready.
+func (e *Engine) Ready(ctx context.Context) error {
+	if e.entriesChunker == nil {
+		return errNotStarted
+	}
+	if e.pubKind != NoPublisher {
+		e.sendersLock.RLock()
+		publisher := e.publisher
+		e.sendersLock.RUnlock()
+		if publisher == nil {
+			return errors.New("publisher is not started")
+		}
+		if len(publisher.Addrs()) == 0 {
+			return errors.New("publisher is not listening")
+		}
+	}
+	if err := e.ds.Put(ctx, dsHealthProbeKey, []byte(time.Now().UTC().Format(time.RFC3339))); err != nil {
+		return fmt.Errorf("datastore is not writable: %w", err)
+	}
+	if err := e.ds.Delete(ctx, dsHealthProbeKey); err != nil {
+		return fmt.Errorf("datastore is not writable: %w", err)
+	}
+	return nil
+}
+
+// Healthy checks whether the engine is functioning. An error is returned if an
+// announcement has been being sent for longer than the stuck announce timeout.
+//
+// See: WithStuckAnnounceTimeout.
+func (e *Engine) Healthy() error {
+	oldest := e.stats.oldestAnnounce()
+	if oldest.IsZero() {
+		return nil
+	}
+	if elapsed := time.Since(oldest); elapsed > e.stuckAnnounceTimeout {
+		return fmt.Errorf("announcement stuck for %s", elapsed.Truncate(time.Second))
+	}
+	return nil
+}
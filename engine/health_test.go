@@ -0,0 +1,79 @@
+package engine_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_Ready(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	subject, err := engine.New(
+		engine.WithPublisherKind(engine.HttpPublisher),
+		engine.WithHttpPublisherListenAddr("127.0.0.1:0"))
+	require.NoError(t, err)
+	require.ErrorContains(t, subject.Ready(ctx), "not started")
+
+	require.NoError(t, subject.Start(ctx))
+	require.NoError(t, subject.Ready(ctx))
+
+	require.NoError(t, subject.Shutdown())
+	require.ErrorContains(t, subject.Ready(ctx), "not started")
+}
+
+func TestEngine_HealthyDetectsStuckAnnounce(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	release := make(chan struct{})
+	indexer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer indexer.Close()
+
+	subject, err := engine.New(
+		engine.WithPublisherKind(engine.HttpPublisher),
+		engine.WithHttpPublisherWithoutServer(),
+		engine.WithHttpPublisherAnnounceAddr("/ip4/127.0.0.1/tcp/3104/http"),
+		engine.WithPubsubAnnounce(false),
+		engine.WithDirectAnnounce(indexer.URL),
+		engine.WithStuckAnnounceTimeout(100*time.Millisecond))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+	require.NoError(t, subject.Healthy())
+
+	published := make(chan error, 1)
+	go func() {
+		_, err := subject.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+		published <- err
+	}()
+	require.Eventually(t, func() bool {
+		return subject.Healthy() != nil
+	}, testTimeout, 50*time.Millisecond)
+	require.ErrorContains(t, subject.Healthy(), "announcement stuck")
+	require.Equal(t, 1, subject.PendingAnnounces())
+
+	close(release)
+	require.NoError(t, <-published)
+	require.NoError(t, subject.Healthy())
+	require.Zero(t, subject.PendingAnnounces())
+}
@@ -0,0 +1,155 @@
+package engine
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/multiformats/go-multiaddr"
+)
+
+const (
+	// hostIdentityKey is the key under which the private key of the libp2p
+	// host created by the engine is persisted, unless it is persisted in a
+	// file. See: WithHostIdentityPath.
+	hostIdentityKey = "host/identity"
+	// hostListenAddrsKey is the key under which the addresses that the libp2p
+	// host created by the engine last listened on are persisted.
+	hostListenAddrsKey = "host/listenAddrs"
+)
+
+// newHost creates the libp2p host of the engine, for when none is given via
+// WithHost. The host has the identity of the private key given via
+// WithPrivateKey if any, or else an identity that is persisted, such that the
+// peer ID of the provider is stable across restarts. Unless listen addresses
+// are given via WithHostListenAddrs, the host listens on the addresses it
+// listened on last, so that the addresses of the provider are stable too.
+func (o *options) newHost(ctx context.Context) (host.Host, error) {
+	key := o.key
+	if key == nil {
+		var err error
+		if key, err = o.loadHostIdentity(ctx); err != nil {
+			return nil, err
+		}
+	}
+	opts := []libp2p.Option{libp2p.Identity(key)}
+	if o.hostNATPortMap {
+		opts = append(opts, libp2p.NATPortMap())
+	}
+	if o.hostAutoNATService {
+		opts = append(opts, libp2p.EnableNATService())
+	}
+
+	var h host.Host
+	var err error
+	if len(o.hostListenAddrs) != 0 {
+		h, err = libp2p.New(append(opts, libp2p.ListenAddrStrings(o.hostListenAddrs...))...)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		lastAddrs, err := o.loadHostListenAddrs(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(lastAddrs) != 0 {
+			h, err = libp2p.New(append(opts, libp2p.ListenAddrStrings(lastAddrs...))...)
+			if err != nil {
+				log.Warnw("Cannot listen on the addresses of the previous libp2p host; using default addresses instead.", "addrs", lastAddrs, "err", err)
+			}
+		}
+		if h == nil {
+			if h, err = libp2p.New(opts...); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var listenAddrs []string
+	for _, addr := range h.Network().ListenAddresses() {
+		// Relayed addresses are not listened on, but added by the host.
+		if _, err := addr.ValueForProtocol(multiaddr.P_CIRCUIT); err == nil {
+			continue
+		}
+		listenAddrs = append(listenAddrs, addr.String())
+	}
+	data, err := json.Marshal(listenAddrs)
+	if err == nil {
+		err = o.ds.Put(ctx, datastore.NewKey(hostListenAddrsKey), data)
+	}
+	if err != nil {
+		_ = h.Close()
+		return nil, fmt.Errorf("cannot persist libp2p host listen addresses: %w", err)
+	}
+	return h, nil
+}
+
+// loadHostIdentity loads the persisted private key of the libp2p host created
+// by the engine, generating and persisting one if there is none yet.
+func (o *options) loadHostIdentity(ctx context.Context) (crypto.PrivKey, error) {
+	var data []byte
+	var err error
+	if o.hostIdentityPath != "" {
+		data, err = os.ReadFile(o.hostIdentityPath)
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+	} else {
+		data, err = o.ds.Get(ctx, datastore.NewKey(hostIdentityKey))
+		if errors.Is(err, datastore.ErrNotFound) {
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot load libp2p host identity: %w", err)
+	}
+	if data != nil {
+		key, err := crypto.UnmarshalPrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode libp2p host identity: %w", err)
+		}
+		return key, nil
+	}
+
+	key, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = crypto.MarshalPrivateKey(key); err != nil {
+		return nil, err
+	}
+	if o.hostIdentityPath != "" {
+		err = os.WriteFile(o.hostIdentityPath, data, 0600)
+	} else {
+		err = o.ds.Put(ctx, datastore.NewKey(hostIdentityKey), data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot persist libp2p host identity: %w", err)
+	}
+	log.Infow("Generated libp2p host identity", "path", o.hostIdentityPath)
+	return key, nil
+}
+
+// loadHostListenAddrs loads the addresses that the libp2p host created by the
+// engine last listened on, if any.
+func (o *options) loadHostListenAddrs(ctx context.Context) ([]string, error) {
+	data, err := o.ds.Get(ctx, datastore.NewKey(hostListenAddrsKey))
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot load libp2p host listen addresses: %w", err)
+	}
+	var addrs []string
+	if err = json.Unmarshal(data, &addrs); err != nil {
+		return nil, fmt.Errorf("cannot decode libp2p host listen addresses: %w", err)
+	}
+	return addrs, nil
+}
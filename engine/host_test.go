@@ -0,0 +1,69 @@
+package engine_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_CreatesHostWithPersistedIdentity(t *testing.T) {
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	subject, err := engine.New(engine.WithDatastore(ds), engine.WithHostListenAddrs("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	h := subject.Host()
+	id, addrs := h.ID(), h.Network().ListenAddresses()
+	require.Equal(t, id, subject.ProviderID())
+	require.NoError(t, subject.Shutdown())
+	// The host created by the engine is closed with it.
+	require.Empty(t, h.Network().ListenAddresses())
+
+	// The identity and listen addresses are reused on restart.
+	subject, err = engine.New(engine.WithDatastore(ds))
+	require.NoError(t, err)
+	t.Cleanup(func() { subject.Shutdown() })
+	require.Equal(t, id, subject.ProviderID())
+	require.ElementsMatch(t, addrs, subject.Host().Network().ListenAddresses())
+
+	// Other datastores have other identities.
+	other, err := engine.New(engine.WithHostListenAddrs("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	t.Cleanup(func() { other.Shutdown() })
+	require.NotEqual(t, id, other.ProviderID())
+}
+
+func TestEngine_CreatesHostWithIdentityFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "identity")
+	var ids []peer.ID
+	for i := 0; i < 2; i++ {
+		subject, err := engine.New(engine.WithHostIdentityPath(path), engine.WithHostListenAddrs("/ip4/127.0.0.1/tcp/0"))
+		require.NoError(t, err)
+		ids = append(ids, subject.ProviderID())
+		require.NoError(t, subject.Shutdown())
+	}
+	require.Equal(t, ids[0], ids[1])
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	key, err := crypto.UnmarshalPrivateKey(data)
+	require.NoError(t, err)
+	id, err := peer.IDFromPrivateKey(key)
+	require.NoError(t, err)
+	require.Equal(t, id, ids[0])
+
+	// A given private key is the identity of the host instead.
+	key, _, err = crypto.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+	subject, err := engine.New(engine.WithPrivateKey(key), engine.WithHostIdentityPath(path), engine.WithHostListenAddrs("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	t.Cleanup(func() { subject.Shutdown() })
+	id, err = peer.IDFromPrivateKey(key)
+	require.NoError(t, err)
+	require.Equal(t, id, subject.Host().ID())
+}
@@ -0,0 +1,106 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+)
+
+const (
+	// adLabelsMapPrefix prefixes the labels of each advertisement. See:
+	// Engine.SetAdLabels.
+	adLabelsMapPrefix = "map/adLabels/"
+
+	// MaxAdLabels is the maximum number of labels of an advertisement.
+	MaxAdLabels = 64
+	// MaxAdLabelLen is the maximum length of the key and of the value of a
+	// label.
+	MaxAdLabelLen = 256
+)
+
+// ErrInvalidLabels signals that the labels of an advertisement are invalid.
+// See: ValidateLabels.
+var ErrInvalidLabels = errors.New("invalid labels")
+
+// SetAdLabels replaces the labels of the advertisement with the given CID by
+// the given labels, or removes them if none are given. Labels are key/value
+// pairs defined by the operator to manage advertisements, e.g. to find the
+// advertisements of a dataset. They are stored alongside the advertisement in
+// the datastore of the engine, and so are neither signed nor published.
+//
+// ErrAdNotFound is returned if the engine has no such advertisement, and an
+// error wrapping ErrInvalidLabels if the labels are invalid.
+func (e *Engine) SetAdLabels(ctx context.Context, adCid cid.Cid, labels map[string]string) error {
+	if err := ValidateLabels(labels); err != nil {
+		return err
+	}
+	if _, err := e.loadAd(ctx, adCid); err != nil {
+		return err
+	}
+	if len(labels) == 0 {
+		return e.ds.Delete(ctx, adLabelsKey(adCid))
+	}
+	data, err := json.Marshal(labels)
+	if err != nil {
+		return err
+	}
+	return e.ds.Put(ctx, adLabelsKey(adCid), data)
+}
+
+// GetAdLabels gets the labels of the advertisement with the given CID, or nil
+// if it has none. See: Engine.SetAdLabels.
+func (e *Engine) GetAdLabels(ctx context.Context, adCid cid.Cid) (map[string]string, error) {
+	data, err := e.ds.Get(ctx, adLabelsKey(adCid))
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var labels map[string]string
+	if err = json.Unmarshal(data, &labels); err != nil {
+		return nil, fmt.Errorf("cannot decode labels of advertisement %s: %w", adCid, err)
+	}
+	return labels, nil
+}
+
+// ValidateLabels checks that there are at most MaxAdLabels labels, and that
+// their keys are not empty, do not contain "=", and are at most MaxAdLabelLen
+// bytes long, as are their values.
+func ValidateLabels(labels map[string]string) error {
+	if len(labels) > MaxAdLabels {
+		return fmt.Errorf("%w: %d labels, maximum is %d", ErrInvalidLabels, len(labels), MaxAdLabels)
+	}
+	for k, v := range labels {
+		switch {
+		case k == "":
+			return fmt.Errorf("%w: empty key", ErrInvalidLabels)
+		case strings.Contains(k, "="):
+			return fmt.Errorf("%w: key %q contains '='", ErrInvalidLabels, k)
+		case len(k) > MaxAdLabelLen:
+			return fmt.Errorf("%w: key %q is longer than %d bytes", ErrInvalidLabels, k, MaxAdLabelLen)
+		case len(v) > MaxAdLabelLen:
+			return fmt.Errorf("%w: value of key %q is longer than %d bytes", ErrInvalidLabels, k, MaxAdLabelLen)
+		}
+	}
+	return nil
+}
+
+// MatchLabels returns whether the given labels have all the wanted labels.
+func MatchLabels(labels, want map[string]string) bool {
+	for k, v := range want {
+		if got, ok := labels[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+func adLabelsKey(c cid.Cid) datastore.Key {
+	return datastore.NewKey(adLabelsMapPrefix + c.String())
+}
@@ -13,7 +13,10 @@ import (
 	"github.com/ipld/go-ipld-prime/node/basicnode"
 	"github.com/ipni/go-libipni/ingest/schema"
 	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/metrics"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 )
 
 var (
@@ -37,6 +40,14 @@ func (e *Engine) mkLinkSystem() ipld.LinkSystem {
 		c := lnk.(cidlink.Link).Cid
 		log.Debugf("Triggered ReadOpener from engine's linksystem with cid (%s)", c)
 
+		// Serve popular entries chunks from memory, if cached.
+		if val, ok := e.memCache.get(c); ok {
+			log.Debugw("Retrieved entries chunk from memory", "cid", c, "size", len(val))
+			metrics.Engine.EntriesMemoryCacheLookup.Add(ctx, 1, metric.WithAttributeSet(attribute.NewSet(metrics.Attributes.CacheHit)))
+			metrics.Engine.BlocksServed.Add(ctx, 1, metric.WithAttributeSet(attribute.NewSet(metrics.Attributes.BlockKindEntries)))
+			return bytes.NewBuffer(val), nil
+		}
+
 		// Get the node from main datastore. If it is in the
 		// main datastore it means it is an advertisement.
 		val, err := e.ds.Get(ctx, datastore.NewKey(c.String()))
@@ -56,15 +67,19 @@ func (e *Engine) mkLinkSystem() ipld.LinkSystem {
 			// If this was an advertisement, then return it.
 			if isAdvertisement(n) {
 				log.Debugw("Retrieved advertisement from datastore", "cid", c, "size", len(val))
+				metrics.Engine.BlocksServed.Add(ctx, 1, metric.WithAttributeSet(attribute.NewSet(metrics.Attributes.BlockKindAd)))
 				return bytes.NewBuffer(val), nil
 			}
 			log.Debugw("Retrieved non-advertisement object from datastore", "cid", c, "size", len(val))
 		}
 
 		// Not an advertisement, so this means we are receiving ingestion data.
+		if e.memCache != nil {
+			metrics.Engine.EntriesMemoryCacheLookup.Add(ctx, 1, metric.WithAttributeSet(attribute.NewSet(metrics.Attributes.CacheMiss)))
+		}
 
 		// If no lister registered return error
-		if e.mhLister == nil {
+		if e.multihashLister() == nil {
 			log.Error("No multihash lister has been registered in engine")
 			return nil, provider.ErrNoMultihashLister
 		}
@@ -77,6 +92,11 @@ func (e *Engine) mkLinkSystem() ipld.LinkSystem {
 			log.Errorf("Error fetching cached list for Cid (%s): %s", c, err)
 			return nil, err
 		}
+		cacheResult := metrics.Attributes.CacheHit
+		if b == nil {
+			cacheResult = metrics.Attributes.CacheMiss
+		}
+		metrics.Engine.EntriesCacheLookup.Add(ctx, 1, metric.WithAttributeSet(attribute.NewSet(cacheResult)))
 
 		// If we don't have the link, generate the linked list of entries in
 		// cache so it is ready to serve for this and future ingestion.
@@ -108,12 +128,15 @@ if b == nil {
     if err != nil {
         return nil, err
     }
-    mhIter, err := e.mhLister(timeoutCtx, provider, key.ContextID)
+    mhIter, err := e.listMultihashes(timeoutCtx, provider, key.ContextID, nil)
     if err != nil {
         return nil, err
     }
+    defer closeMultihashIterator(mhIter)
 
+    chunkStart := time.Now()
     regeneratedLink, err := e.entriesChunker.Chunk(timeoutCtx, mhIter)
+    metrics.Engine.ChunkingDuration.Record(ctx, time.Since(chunkStart).Milliseconds())
     if err != nil {
         if timeoutCtx.Err() == context.DeadlineExceeded {
             log.Error("Timeout occurred during chunk generation")
@@ -144,7 +167,9 @@ if b == nil {
 			log.Errorf("No object found in linksystem for CID (%s)", c)
 			return nil, datastore.ErrNotFound
 		}
+		e.memCache.put(c, val)
 
+		metrics.Engine.BlocksServed.Add(ctx, 1, metric.WithAttributeSet(attribute.NewSet(metrics.Attributes.BlockKindEntries)))
 		return bytes.NewBuffer(val), nil
 	}
 
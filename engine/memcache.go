@@ -0,0 +1,106 @@
+package engine
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/index-provider/metrics"
+)
+
+// memCache is an in-memory LRU cache of entry chunk blocks, bounded by the
+// total size of the cached blocks. It keeps the chunks that are served to
+// indexers most recently in front of the entries cache, so that indexers
+// syncing the same advertisements do not read the same chunks from the
+// datastore, or cause them to be regenerated, over and over.
+//
+// A nil memCache caches nothing.
+type memCache struct {
+	lock     sync.Mutex
+	maxBytes int64
+	size     int64
+	// lru holds the cached blocks, least recently used last.
+	lru   *list.List
+	items map[cid.Cid]*list.Element
+}
+
+type memCacheItem struct {
+	c    cid.Cid
+	data []byte
+}
+
+// newMemCache instantiates a new memCache that holds up to maxBytes of
+// blocks, or returns nil if maxBytes is not positive.
+func newMemCache(maxBytes int64) *memCache {
+	if maxBytes <= 0 {
+		return nil
+	}
+	return &memCache{
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		items:    make(map[cid.Cid]*list.Element),
+	}
+}
+
+// get returns the cached block with the given CID, if any.
+func (m *memCache) get(c cid.Cid) ([]byte, bool) {
+	if m == nil {
+		return nil, false
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	elem, ok := m.items[c]
+	if !ok {
+		return nil, false
+	}
+	m.lru.MoveToFront(elem)
+	return elem.Value.(*memCacheItem).data, true
+}
+
+// put caches the given block, evicting the least recently used blocks as
+// needed to stay within the size limit. Blocks larger than the limit are not
+// cached.
+func (m *memCache) put(c cid.Cid, data []byte) {
+	if m == nil || int64(len(data)) > m.maxBytes {
+		return
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if elem, ok := m.items[c]; ok {
+		m.lru.MoveToFront(elem)
+		return
+	}
+	m.items[c] = m.lru.PushFront(&memCacheItem{c: c, data: data})
+	added := int64(len(data))
+	for m.size+added > m.maxBytes {
+		item := m.lru.Remove(m.lru.Back()).(*memCacheItem)
+		delete(m.items, item.c)
+		added -= int64(len(item.data))
+	}
+	m.size += added
+	metrics.Engine.EntriesMemoryCacheSize.Add(context.Background(), added)
+}
+
+// clear removes all cached blocks.
+func (m *memCache) clear() {
+	if m == nil {
+		return
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	metrics.Engine.EntriesMemoryCacheSize.Add(context.Background(), -m.size)
+	m.lru.Init()
+	clear(m.items)
+	m.size = 0
+}
+
+// len returns the number of cached blocks and their total size in bytes.
+func (m *memCache) len() (int, int64) {
+	if m == nil {
+		return 0, 0
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.lru.Len(), m.size
+}
@@ -0,0 +1,52 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/ipni/go-libipni/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemCache(t *testing.T) {
+	cids := test.RandomCids(4)
+	subject := newMemCache(10)
+
+	subject.put(cids[0], []byte("fish"))
+	subject.put(cids[1], []byte("crab"))
+	n, size := subject.len()
+	require.Equal(t, 2, n)
+	require.EqualValues(t, 8, size)
+
+	// Using fish makes crab the least recently used.
+	got, ok := subject.get(cids[0])
+	require.True(t, ok)
+	require.Equal(t, "fish", string(got))
+	subject.put(cids[2], []byte("clam"))
+	_, ok = subject.get(cids[1])
+	require.False(t, ok)
+	_, ok = subject.get(cids[0])
+	require.True(t, ok)
+	n, size = subject.len()
+	require.Equal(t, 2, n)
+	require.EqualValues(t, 8, size)
+
+	// Blocks larger than the cache are not cached.
+	subject.put(cids[3], []byte("lobster lobster"))
+	_, ok = subject.get(cids[3])
+	require.False(t, ok)
+
+	subject.clear()
+	n, size = subject.len()
+	require.Zero(t, n)
+	require.Zero(t, size)
+	_, ok = subject.get(cids[0])
+	require.False(t, ok)
+
+	// A disabled cache caches nothing.
+	subject = newMemCache(0)
+	require.Nil(t, subject)
+	subject.put(cids[0], []byte("fish"))
+	_, ok = subject.get(cids[0])
+	require.False(t, ok)
+	subject.clear()
+}
@@ -1,16 +1,24 @@
 package engine
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/url"
+	"strings"
+	"time"
 
 	"github.com/ipfs/go-datastore"
+	dsn "github.com/ipfs/go-datastore/namespace"
 	dssync "github.com/ipfs/go-datastore/sync"
 	"github.com/ipld/go-ipld-prime"
+	"github.com/ipni/go-libipni/announce"
 	_ "github.com/ipni/go-libipni/maurl"
+	"github.com/ipni/go-libipni/metadata"
+	provider "github.com/ipni/index-provider"
 	"github.com/ipni/index-provider/engine/chunker"
 	"github.com/ipni/index-provider/engine/policy"
-	"github.com/libp2p/go-libp2p"
+	"github.com/ipni/index-provider/msgbus"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/host"
@@ -40,6 +48,11 @@ const (
 	DataTransferPublisher PublisherKind = "dtsync"
 )
 
+// TopicNamespace is the namespace of the gossip pubsub topics on which the
+// indexers of an IPNI network receive announcements, each named after its
+// network. See: WithNetwork.
+const TopicNamespace = "/indexer/ingest/"
+
 type (
 	// PublisherKind represents the kind of publisher to use in order to announce a new
 	// advertisement to the network.
@@ -52,6 +65,18 @@ type (
 	options struct {
 		ds datastore.Batching
 		h  host.Host
+		// ownHost is whether h was created by the engine, and so is closed by
+		// Engine.Shutdown.
+		ownHost bool
+		// dsNamespace is the namespace under which all keys are put in ds.
+		dsNamespace string
+		// hostIdentityPath, hostListenAddrs, hostNATPortMap and
+		// hostAutoNATService configure the host created by the engine when
+		// none is given.
+		hostIdentityPath   string
+		hostListenAddrs    []string
+		hostNATPortMap     bool
+		hostAutoNATService bool
 
 		// key is always initialized from the host peerstore.
 		// Setting an explicit identity must not be exposed unless it is tightly coupled with the
@@ -67,6 +92,17 @@ type (
 		// overidden from the NotifyPut and Notify Remove method, otherwise the
 		// default configured provider will be assumed.
 		provider peer.AddrInfo
+		// maxRetrievalAddrs and privateRetrievalAddrs limit the retrieval
+		// addresses put into advertisements. See: WithMaxRetrievalAddrs and
+		// WithPrivateRetrievalAddrs.
+		maxRetrievalAddrs     int
+		privateRetrievalAddrs bool
+		// retrievalAddrsFromHost is whether the retrieval addresses default
+		// to the addresses of the host, since none are configured, and
+		// externalAddrsQuiet the quiet period after which changes of its
+		// external addresses are advertised. See: WithExternalRetrievalAddrs.
+		retrievalAddrsFromHost bool
+		externalAddrsQuiet     time.Duration
 
 		// ---- publisher config ----
 
@@ -88,33 +124,108 @@ type (
 		announceURLs []*url.URL
 		// pubsubAnnounce enables broadcasting announcements via gossip pubsub.
 		pubsubAnnounce bool
+		// gossipMesh makes the engine join the mesh of the announce topic.
+		gossipMesh bool
+		// indexerPeers are the peers of the indexers whose reachability by
+		// gossip pubsub announcements is reported. See: WithIndexerPeers.
+		indexerPeers []peer.ID
+		// trustedIndexers are the only peers with which gossip pubsub
+		// announcements are exchanged, if any. See: WithTrustedIndexers.
+		trustedIndexers []peer.AddrInfo
+		// announceAuthTokens are the bearer tokens sent with direct HTTP
+		// announcements, by the origin of the announce URL. See:
+		// WithAnnounceAuthTokens.
+		announceAuthTokens map[string]string
 		// pubsubExtraGossipData supplies extra data to include in pubsub
 		// announcements.
 		pubsubExtraGossipData []byte
+		// extraSenders are the announce senders added by the user, which
+		// announce alongside the senders created by the engine.
+		extraSenders []announce.Sender
+		// stuckAnnounceTimeout is the duration after which an announcement
+		// that is still being sent is considered stuck.
+		stuckAnnounceTimeout time.Duration
+		// announceQuiet and announceMaxDelay configure the debouncing of
+		// announcements. See: WithAnnounceDebounce.
+		announceQuiet    time.Duration
+		announceMaxDelay time.Duration
 
 		entCacheCap int
 		purgeCache  bool
 		chunker     chunker.NewChunkerFunc
+		// entMemCacheSize is the maximum total size in bytes of the served
+		// entries chunks cached in memory.
+		entMemCacheSize int64
+		// lazyEntries enables generating entries chunks only once they are
+		// first requested.
+		lazyEntries bool
+
+		// sortEntries enables sorting and deduplicating the multihashes
+		// listed for a context ID before chunking them.
+		sortEntries bool
+		// sortedChunks is set when entries are chunked by a
+		// chunker.SortedChainChunker, which requires sorted multihashes
+		// regardless of sortEntries. See: WithSortedChainedEntries.
+		sortedChunks bool
+		// sortMemory and sortTempDir configure the external sort of
+		// multihashes. See: provider.SortedMultihashIterator.
+		sortMemory  int
+		sortTempDir string
+		// listerPrefetch is the number of multihashes read ahead from the
+		// multihash lister while chunking.
+		listerPrefetch int
+		// maxAdMultihashes is the maximum number of multihashes advertised by
+		// a single advertisement, beyond which content is split across
+		// several advertisements.
+		maxAdMultihashes int
 
 		syncPolicy *policy.Policy
 
+		// metadataContext decodes metadata, including that of the retrieval
+		// protocols registered via WithMetadataProtocol.
+		metadataContext metadata.MetadataContext
+
 		storageReadOpenerErrorHook func(lctx ipld.LinkContext, lnk ipld.Link, err error) error
+
+		// auditLog enables recording every published advertisement.
+		auditLog bool
+
+		// adSequence enables embedding the sequence number and timestamp of
+		// each advertisement in its metadata. See: WithAdSequence.
+		adSequence bool
+
+		// staticCarDir is the directory to which each published advertisement
+		// is written as a CAR file.
+		staticCarDir string
+
+		// retainAds and retainAge are the retention policy applied by
+		// Engine.Prune.
+		retainAds int
+		retainAge time.Duration
+
+		// faults are the failures injected for testing. See: WithFaults.
+		faults Faults
 	}
 )
 
 func newOptions(o ...Option) (*options, error) {
 	opts := &options{
-		pubKind:           NoPublisher,
-		pubHttpListenAddr: "0.0.0.0:3104",
-		pubTopicName:      "/indexer/ingest/mainnet",
-		pubsubAnnounce:    true,
+		pubKind:              NoPublisher,
+		pubHttpListenAddr:    "0.0.0.0:3104",
+		pubTopicName:         TopicNamespace + "mainnet",
+		pubsubAnnounce:       true,
+		stuckAnnounceTimeout: 5 * time.Minute,
 		// Keep 1024 ad entry DAG in cache; note, the size on disk depends on DAG format and
 		// multihash code.
 		entCacheCap: 1024,
 		// By default use chained Entry Chunk as the format of advertisement entries, with maximum
 		// 16384 multihashes per chunk.
-		chunker:    chunker.NewChainChunkerFunc(16384),
-		purgeCache: false,
+		chunker:         chunker.NewChainChunkerFunc(16384),
+		purgeCache:      false,
+		metadataContext: metadata.Default,
+		// Private addresses are advertised unless disabled, for backwards
+		// compatibility.
+		privateRetrievalAddrs: true,
 	}
 
 	for _, apply := range o {
@@ -134,15 +245,19 @@ func newOptions(o ...Option) (*options, error) {
 	if opts.ds == nil {
 		opts.ds = dssync.MutexWrap(datastore.NewMapDatastore())
 	}
+	if opts.dsNamespace != "" {
+		opts.ds = dsn.Wrap(opts.ds, datastore.NewKey(opts.dsNamespace))
+	}
 
 	if (opts.key == nil || len(opts.provider.Addrs) == 0 || opts.provider.ID == "") && opts.h == nil {
 		// need a host
-		h, err := libp2p.New()
+		h, err := opts.newHost(context.Background())
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("cannot create libp2p host: %w", err)
 		}
-		log.Infow("Libp2p host is not configured, but required; created a new host.", "id", h.ID())
+		log.Infow("Libp2p host is not configured, but required; created a new host.", "id", h.ID(), "addrs", h.Addrs())
 		opts.h = h
+		opts.ownHost = true
 	}
 
 	if opts.key == nil {
@@ -156,6 +271,7 @@ func newOptions(o ...Option) (*options, error) {
 
 	if len(opts.provider.Addrs) == 0 {
 		opts.provider.Addrs = opts.h.Addrs()
+		opts.retrievalAddrsFromHost = true
 		log.Infow("Retrieval address not configured; using host listen addresses instead.", "retrievalAddrs", opts.provider.Addrs)
 	}
 	if opts.provider.ID == "" {
@@ -163,6 +279,12 @@ func newOptions(o ...Option) (*options, error) {
 		log.Infow("Retrieval ID not configured; using host ID instead.", "retrievalID", opts.provider.ID)
 	}
 
+	// Inject faults once the host is created, so that its identity is
+	// persisted.
+	if opts.faults.DatastoreWriteFailureRate > 0 {
+		opts.ds = faultyDatastore(opts.ds, opts.faults.DatastoreWriteFailureRate)
+	}
+
 	return opts, nil
 }
 
@@ -196,6 +318,28 @@ func WithPurgeCacheOnStart(p bool) Option {
 func WithChainedEntries(chunkSize int) Option {
 	return func(o *options) error {
 		o.chunker = chunker.NewChainChunkerFunc(chunkSize)
+		o.sortedChunks = false
+		return nil
+	}
+}
+
+// WithSortedChainedEntries sets format of advertisement entries to chained
+// Entry Chunk, where each chunk holds a run of exactly chunkSize multihashes,
+// except for the root chunk, in ascending byte order. The multihashes listed
+// for a context ID are then sorted and deduplicated before they are chunked,
+// regardless of WithSortedEntries, such that the chunks have a predictable
+// layout: indexers can binary search the multihashes of a chunk, and tell from
+// its first and last multihashes whether a multihash may be in it.
+//
+// The chunks remain schema compatible with those of WithChainedEntries, but
+// the same notes on switching to sorted entries as for WithSortedEntries
+// apply.
+//
+// See: chunker.SortedChainChunker.
+func WithSortedChainedEntries(chunkSize int) Option {
+	return func(o *options) error {
+		o.chunker = chunker.NewSortedChainChunkerFunc(chunkSize)
+		o.sortedChunks = true
 		return nil
 	}
 }
@@ -217,6 +361,7 @@ func WithChainedEntries(chunkSize int) Option {
 func WithHamtEntries(hashAlg multicodec.Code, bitWidth, bucketSize int) Option {
 	return func(o *options) error {
 		o.chunker = chunker.NewHamtChunkerFunc(hashAlg, bitWidth, bucketSize)
+		o.sortedChunks = false
 		return nil
 	}
 }
@@ -240,6 +385,149 @@ func WithEntriesCacheCapacity(s int) Option {
 	}
 }
 
+// WithSortedEntries sets whether the multihashes listed for a context ID are
+// sorted in ascending byte order, and deduplicated, before they are chunked
+// into advertisement entries. This makes the entries CID deterministic for the
+// same multihashes, regardless of the order in which the registered
+// provider.MultihashLister returns them or whether it returns any more than
+// once.
+//
+// Note that entries that were generated before this option is enabled are
+// regenerated unsorted by the lister when they are not cached, and so cannot
+// be served if the lister does not return multihashes in a consistent order.
+// Enabling this option is therefore only safe for a provider that either has
+// not yet published any advertisements or whose lister returns multihashes in
+// ascending order already. The same holds for disabling it.
+//
+// If unset, multihashes are chunked in the order they are listed.
+//
+// See: WithEntriesSortMemory.
+func WithSortedEntries(enable bool) Option {
+	return func(o *options) error {
+		o.sortEntries = enable
+		return nil
+	}
+}
+
+// WithEntriesSortMemory sets the maximum number of bytes of multihashes held
+// in memory while sorting entries, beyond which they are spilled to temporary
+// files in tempDir. Only takes effect if WithSortedEntries is enabled.
+//
+// If unset, provider.DefaultSortMemory is used, and temporary files are
+// created in the default directory for temporary files.
+func WithEntriesSortMemory(maxMemory int, tempDir string) Option {
+	return func(o *options) error {
+		if maxMemory < 0 {
+			return fmt.Errorf("entries sort memory must not be negative, got %d", maxMemory)
+		}
+		o.sortMemory = maxMemory
+		o.sortTempDir = tempDir
+		return nil
+	}
+}
+
+// WithListerPrefetch sets the number of multihashes that are read ahead from
+// the registered provider.MultihashLister while generating entries chunks.
+// Multihashes are then listed in a separate goroutine, such that a slow
+// lister, e.g. one backed by a database scan, keeps listing multihashes while
+// those already listed are chunked and the chunks written to the datastore.
+// Once the given number of multihashes are read ahead, listing waits for them
+// to be chunked, so that memory use stays bounded.
+//
+// The lister must then be safe to iterate over in a goroutine other than the
+// one that called it, which is the case unless it relies on goroutine-local
+// state.
+//
+// If unset, or set to zero, multihashes are listed as they are chunked.
+//
+// See: provider.PrefetchMultihashIterator.
+func WithListerPrefetch(n int) Option {
+	return func(o *options) error {
+		if n < 0 {
+			return fmt.Errorf("lister prefetch must not be negative, got %d", n)
+		}
+		o.listerPrefetch = n
+		return nil
+	}
+}
+
+// WithMaxAdMultihashes sets the maximum number of multihashes advertised by a
+// single advertisement published via Engine.NotifyPut. The content of a
+// context ID whose multihash lister yields more multihashes is split across
+// several advertisements, published one after another, that each advertise at
+// most max of the multihashes in the order they are listed. The first part is
+// advertised under the context ID itself, and the rest under context IDs
+// derived from it via SplitContextID, which must then fit within
+// provider.MaxContextIDLen. This keeps the entries DAG that indexers ingest per
+// advertisement, and hence their ingestion latency, bounded.
+//
+// Updating the metadata of, or removing, content that is split publishes an
+// advertisement for each of its parts. The parts are regenerated for indexers
+// from the multihashes listed for the context ID, so the lister must return
+// them in the same order every time, e.g. by enabling WithSortedEntries.
+//
+// If unset, or set to zero, content is never split. Changing it only affects
+// content advertised afterwards.
+func WithMaxAdMultihashes(max int) Option {
+	return func(o *options) error {
+		if max < 0 {
+			return fmt.Errorf("maximum advertisement multihashes must not be negative, got %d", max)
+		}
+		o.maxAdMultihashes = max
+		return nil
+	}
+}
+
+// WithLazyEntries sets whether the entries chunks of new advertisements are
+// only generated once an indexer first requests them, rather than when the
+// advertisement is published. This reduces the time taken to publish
+// advertisements whose entries are seldom synced, and keeps the entries cache
+// for entries that are actually synced.
+//
+// The link to the entries in an advertisement must be the CID of the entries
+// chunks, for indexers to verify them. Therefore, the multihashes are still
+// listed when publishing, to compute the link. The chunks themselves are only
+// encoded to compute the link, and are neither stored nor cached.
+// Once requested, they are regenerated from the registered
+// provider.MultihashLister, and cached as usual. Since the link
+// is the same as that of eagerly generated entries, this option can be
+// enabled or disabled at any time.
+//
+// Note that the lister must return the same multihashes in the same order
+// when the chunks are requested as when the advertisement was published,
+// otherwise the chunks cannot be served. See: WithSortedEntries. Entries are
+// generated within the time allowed for serving a block to an indexer, which
+// may not be enough for very large entries.
+//
+// If unset, entries chunks are generated and cached when the advertisement is
+// published.
+func WithLazyEntries(enable bool) Option {
+	return func(o *options) error {
+		o.lazyEntries = enable
+		return nil
+	}
+}
+
+// WithEntriesMemoryCacheSize sets the maximum total size in bytes of the
+// advertisement entries chunks that are cached in memory as they are served
+// to indexers. Chunks that many indexers sync, e.g. those of the latest
+// advertisements, are then served from memory rather than read from the
+// entries cache in the datastore, or regenerated, each time. The least
+// recently served chunks are evicted once the size is exceeded.
+//
+// If unset, or set to zero, no chunks are cached in memory.
+//
+// See: WithEntriesCacheCapacity.
+func WithEntriesMemoryCacheSize(maxBytes int64) Option {
+	return func(o *options) error {
+		if maxBytes < 0 {
+			return fmt.Errorf("entries memory cache size must not be negative, got %d", maxBytes)
+		}
+		o.entMemCacheSize = maxBytes
+		return nil
+	}
+}
+
 // WithPublisherKind sets the kind of publisher used to serve advertisements.
 // If unset, advertisements are only stored locally and no announcements are
 // made. This does not affect the methods used to send announcements of new
@@ -293,13 +581,19 @@ func WithHttpPublisherHandlerPath(handlerPath string) Option {
 }
 
 // WithHttpPublisherAnnounceAddr sets the address to be supplied in announce
-// messages to tell indexers where to retrieve advertisements.
+// messages to tell indexers where to retrieve advertisements. The address is
+// either a multiaddr or an http or https URL, and is parsed using
+// ParseAnnounceAddr. This option may be given multiple times to announce
+// multiple addresses, such as both a domain name and an IP address.
+//
+// When PublisherKind is HttpPublisher, the address must be an HTTP address,
+// such as "/dns4/example.com/tcp/443/https" or "https://example.com".
 //
 // This option is not used if PublisherKind is set to DataTransferPublisher.
 func WithHttpPublisherAnnounceAddr(addr string) Option {
 	return func(o *options) error {
 		if addr != "" {
-			maddr, err := multiaddr.NewMultiaddr(addr)
+			maddr, err := ParseAnnounceAddr(addr)
 			if err != nil {
 				return err
 			}
@@ -321,6 +615,24 @@ func WithTopicName(t string) Option {
 	}
 }
 
+// WithNetwork sets the name of the IPNI network to which announcements are
+// published over gossip pubsub, such that they are published on the topic
+// named after it in TopicNamespace, e.g. /indexer/ingest/mainnet, the
+// default. The indexers of a private network subscribe to the topic of their
+// network, and so do not receive the announcements of providers of public
+// networks, nor do public indexers receive those of the private network. This
+// option overrides WithTopicName, and is overridden by it, whichever is given
+// last.
+func WithNetwork(name string) Option {
+	return func(o *options) error {
+		if name == "" || strings.Contains(name, "/") {
+			return fmt.Errorf("invalid network name %q: must be non-empty and not contain '/'", name)
+		}
+		o.pubTopicName = TopicNamespace + name
+		return nil
+	}
+}
+
 // WithTopic sets the pubsub topic on which new advertisements are announced.
 // To use the default pubsub configuration with a specific topic name, use WithTopicName. If both
 // options are specified, WithTopic takes presence.
@@ -335,8 +647,11 @@ func WithTopic(t *pubsub.Topic) Option {
 }
 
 // WithHost specifies the host to which the provider engine belongs.
-// If unspecified, a host is created automatically.
-// See: libp2p.New.
+// If unspecified, a host is created automatically if needed, and closed by
+// Engine.Shutdown. Unless a private key is given via WithPrivateKey, the
+// identity of the created host is persisted in the datastore of the engine,
+// or in the file set by WithHostIdentityPath, so that the provider ID remains
+// the same across restarts. See: libp2p.New.
 func WithHost(h host.Host) Option {
 	return func(o *options) error {
 		o.h = h
@@ -344,6 +659,52 @@ func WithHost(h host.Host) Option {
 	}
 }
 
+// WithHostIdentityPath sets the path of the file in which the private key of
+// the libp2p host created by the engine is persisted. The key is generated
+// and written to the file if the file does not exist.
+// If unset, the key is persisted in the datastore of the engine.
+// This option has no effect if a host is given via WithHost.
+func WithHostIdentityPath(path string) Option {
+	return func(o *options) error {
+		o.hostIdentityPath = path
+		return nil
+	}
+}
+
+// WithHostListenAddrs sets the multiaddrs on which the libp2p host created by
+// the engine listens, e.g. "/ip4/0.0.0.0/tcp/3103".
+// If unset, the host listens on the addresses it listened on when it was last
+// created with the same datastore, or on the default addresses of libp2p.
+// This option has no effect if a host is given via WithHost.
+func WithHostListenAddrs(addrs ...string) Option {
+	return func(o *options) error {
+		o.hostListenAddrs = append(o.hostListenAddrs, addrs...)
+		return nil
+	}
+}
+
+// WithHostNATPortMap sets whether the libp2p host created by the engine
+// attempts to open a port in the NAT device of the network, via UPnP or
+// NAT-PMP, so that it is reachable from outside the network.
+// This option has no effect if a host is given via WithHost.
+func WithHostNATPortMap(enabled bool) Option {
+	return func(o *options) error {
+		o.hostNATPortMap = enabled
+		return nil
+	}
+}
+
+// WithHostAutoNATService sets whether the libp2p host created by the engine
+// runs the AutoNAT service, which helps other peers determine whether they
+// are reachable. The host determines whether it is itself reachable
+// regardless. This option has no effect if a host is given via WithHost.
+func WithHostAutoNATService(enabled bool) Option {
+	return func(o *options) error {
+		o.hostAutoNATService = enabled
+		return nil
+	}
+}
+
 // WithDatastore sets the datastore that is used by the engine to store advertisements.
 // If unspecified, an ephemeral in-memory datastore is used.
 // See: datastore.NewMapDatastore.
@@ -354,6 +715,27 @@ func WithDatastore(ds datastore.Batching) Option {
 	}
 }
 
+// WithDatastoreNamespace sets the namespace under which all the keys written
+// by the engine are put in its datastore, so that several engines, e.g. of
+// different provider identities, can share the datastore of a larger
+// application without their keys colliding, with each other or with those of
+// the application. The namespace applies to the datastore set via
+// WithDatastore regardless of the order of the options. An engine must be
+// given the same namespace every time to find the advertisements it
+// published before.
+//
+// The namespace is a datastore key, e.g. "/index-provider/fish", of which the
+// leading slash may be omitted. By default, keys are not namespaced.
+func WithDatastoreNamespace(ns string) Option {
+	return func(o *options) error {
+		if datastore.NewKey(ns).String() == "/" {
+			return errors.New("datastore namespace must not be empty")
+		}
+		o.dsNamespace = ns
+		return nil
+	}
+}
+
 // WithRetrievalAddrs sets the addresses that specify where to get the content corresponding to an
 // indexing advertisement.
 // If unspecified, the libp2p host listen addresses are used.
@@ -375,6 +757,83 @@ func WithRetrievalAddrs(addrs ...string) Option {
 	}
 }
 
+// WithMaxRetrievalAddrs sets the maximum number of retrieval addresses put
+// into an advertisement. Addresses in excess of it, after removing duplicate
+// and, unless allowed, private addresses, are left out of advertisements in
+// the order given. Zero, the default, puts all addresses into advertisements,
+// which are rejected with provider.ErrTooManyAddrs if there are more than
+// provider.MaxAddrs of them.
+func WithMaxRetrievalAddrs(n int) Option {
+	return func(o *options) error {
+		if n < 0 || n > provider.MaxAddrs {
+			return fmt.Errorf("maximum number of retrieval addresses must be between 0 and %d", provider.MaxAddrs)
+		}
+		o.maxRetrievalAddrs = n
+		return nil
+	}
+}
+
+// WithPrivateRetrievalAddrs sets whether private retrieval addresses, i.e.
+// loopback, link-local, unspecified and private network addresses, are put
+// into advertisements. Advertisements built from the listen addresses of a
+// host typically include such addresses, e.g. 127.0.0.1 and docker-internal
+// addresses, which indexers hand out to retrieval clients that cannot reach
+// them. Defaults to true. See: IsPrivateAddr.
+//
+// When false, publishing content whose retrieval addresses are all private
+// fails.
+func WithPrivateRetrievalAddrs(allow bool) Option {
+	return func(o *options) error {
+		o.privateRetrievalAddrs = allow
+		return nil
+	}
+}
+
+// WithExternalRetrievalAddrs sets the engine to advertise the external
+// addresses of the libp2p host as retrieval addresses, instead of the
+// addresses it listens on, when none are set via WithRetrievalAddrs or
+// WithProvider. These are the public addresses of the host that its peers
+// observe, as reported via identify, or that are mapped in the NAT device,
+// unless AutoNAT finds that the host is not reachable at them.
+//
+// Whenever the addresses or reachability of the host change, and then do not
+// change for the given quiet period, the content advertised by the default
+// provider is republished under the changed addresses. See:
+// Engine.NotifyAddrsChanged. A quiet period of zero, the default, disables
+// it, such that the addresses of the host at the time the engine is created
+// are advertised.
+func WithExternalRetrievalAddrs(quiet time.Duration) Option {
+	return func(o *options) error {
+		if quiet < 0 {
+			return errors.New("quiet period of external retrieval addresses must not be negative")
+		}
+		o.externalAddrsQuiet = quiet
+		return nil
+	}
+}
+
+// WithMetadataProtocol registers a custom retrieval protocol with the given
+// transport ID, such that the engine can decode metadata that includes the
+// protocol, e.g. to compare the metadata of content being re-advertised with
+// that previously advertised. The factory returns an empty instance of the
+// protocol, into which its binary encoding is decoded. Metadata passed to
+// NotifyPut is rejected if it includes a protocol that the engine cannot
+// decode. See Engine.MetadataContext to decode metadata with the registered
+// protocols.
+//
+// Unregistered protocols are decoded as metadata.Unknown, which requires the
+// transport ID of the protocol to be followed by the varint length of the
+// protocol payload.
+func WithMetadataProtocol(id multicodec.Code, factory func() metadata.Protocol) Option {
+	return func(o *options) error {
+		if factory == nil {
+			return fmt.Errorf("no factory for metadata protocol %s", id)
+		}
+		o.metadataContext = o.metadataContext.WithProtocol(id, factory)
+		return nil
+	}
+}
+
 func WithSyncPolicy(syncPolicy *policy.Policy) Option {
 	return func(o *options) error {
 		o.syncPolicy = syncPolicy
@@ -398,6 +857,43 @@ func WithPrivateKey(key crypto.PrivKey) Option {
 	}
 }
 
+// WithAnnounceSender adds a sender via which advertisements are announced,
+// alongside the gossip pubsub and direct HTTP senders created by the engine,
+// e.g. to announce advertisements over a message queue. The sender is used by
+// every path that announces advertisements, except for PublishLatestHTTP which
+// only announces to the given URLs. It is closed when the engine is shut down.
+//
+// This option may be given multiple times to add multiple senders.
+func WithAnnounceSender(sender announce.Sender) Option {
+	return func(o *options) error {
+		if sender == nil {
+			return errors.New("announce sender must not be nil")
+		}
+		o.extraSenders = append(o.extraSenders, sender)
+		return nil
+	}
+}
+
+// WithMessageBusAnnounce adds senders that announce advertisements by
+// publishing announcement messages to the message buses with the given URLs,
+// for indexers that ingest announcements from a queue. Each URL is an SQS queue
+// URL with the sqs scheme, an SNS topic ARN or a NATS URL. See: msgbus.Open.
+//
+// To announce via other message buses, such as Kafka, use WithAnnounceSender
+// with a msgbus.Sender.
+func WithMessageBusAnnounce(busURLs ...string) Option {
+	return func(o *options) error {
+		for _, busURL := range busURLs {
+			pub, err := msgbus.Open(busURL)
+			if err != nil {
+				return err
+			}
+			o.extraSenders = append(o.extraSenders, msgbus.NewSender(pub))
+		}
+		return nil
+	}
+}
+
 // WithDirectAnnounce sets indexer URLs to send direct HTTP announcements to.
 func WithDirectAnnounce(announceURLs ...string) Option {
 	return func(o *options) error {
@@ -421,6 +917,78 @@ func WithPubsubAnnounce(enable bool) Option {
 	}
 }
 
+// WithGossipMesh configures whether the engine joins the gossipsub mesh of the
+// announce topic, by subscribing to the topic and discarding the messages it
+// receives. Announcements are published to every peer subscribed to the topic
+// regardless, but peers in the mesh also relay them to the peers they are
+// connected to, and the mesh is reported by Engine.GossipDiagnostics. Default
+// is false. This option has no effect if the topic is given via WithTopic.
+func WithGossipMesh(enable bool) Option {
+	return func(o *options) error {
+		o.gossipMesh = enable
+		return nil
+	}
+}
+
+// WithIndexerPeers sets the peer IDs of the indexers expected to receive the
+// announcements published over gossip pubsub, whose presence in the topic and
+// its mesh is reported by Engine.GossipDiagnostics.
+func WithIndexerPeers(ids ...peer.ID) Option {
+	return func(o *options) error {
+		o.indexerPeers = append(o.indexerPeers, ids...)
+		return nil
+	}
+}
+
+// WithTrustedIndexers sets the indexers of a private IPNI network, which are
+// then the only peers with which the gossip pubsub of the engine exchanges
+// announcements. Announcements are sent to them directly, without relying on
+// the gossipsub mesh, and the engine keeps connecting to them at the given
+// addresses. Their reachability is reported as that of the peers given via
+// WithIndexerPeers. This option has no effect if the topic is given via
+// WithTopic. See: WithNetwork.
+func WithTrustedIndexers(indexers ...peer.AddrInfo) Option {
+	return func(o *options) error {
+		for _, indexer := range indexers {
+			if err := indexer.ID.Validate(); err != nil {
+				return fmt.Errorf("invalid trusted indexer peer ID: %w", err)
+			}
+			o.trustedIndexers = append(o.trustedIndexers, indexer)
+			o.indexerPeers = append(o.indexerPeers, indexer.ID)
+		}
+		return nil
+	}
+}
+
+// WithAnnounceAuthTokens sets the tokens with which direct HTTP announcements
+// are authenticated to indexers that require it, such as those of a private
+// IPNI network. Each token is keyed by the URL of the indexers it is sent to,
+// of which only the scheme and host are considered, e.g.
+// https://indexer.example.com, and is sent as a bearer token in the
+// Authorization header of the announcements sent to any announce URL with
+// that scheme and host. See: WithDirectAnnounce.
+func WithAnnounceAuthTokens(tokens map[string]string) Option {
+	return func(o *options) error {
+		for rawURL, token := range tokens {
+			u, err := url.Parse(rawURL)
+			if err != nil {
+				return fmt.Errorf("invalid URL of announce token: %w", err)
+			}
+			if u.Scheme == "" || u.Host == "" {
+				return fmt.Errorf("URL of announce token must have a scheme and host: %q", rawURL)
+			}
+			if token == "" {
+				return fmt.Errorf("empty announce token for %s", rawURL)
+			}
+			if o.announceAuthTokens == nil {
+				o.announceAuthTokens = make(map[string]string, len(tokens))
+			}
+			o.announceAuthTokens[urlOrigin(u)] = token
+		}
+		return nil
+	}
+}
+
 // WithExtraGossipData supplies extra data to include in the pubsub
 // announcement. Note that this option only takes effect if pubsub
 // announcements are enabled.
@@ -435,6 +1003,104 @@ func WithExtraGossipData(extraData []byte) Option {
 	}
 }
 
+// WithStuckAnnounceTimeout sets the duration after which an announcement that
+// is still being sent is considered stuck, causing Engine.Healthy to report
+// the engine as unhealthy. Default is 5 minutes if this option is not
+// specified.
+func WithStuckAnnounceTimeout(d time.Duration) Option {
+	return func(o *options) error {
+		if d <= 0 {
+			return fmt.Errorf("stuck announce timeout must be positive, got %s", d)
+		}
+		o.stuckAnnounceTimeout = d
+		return nil
+	}
+}
+
+// WithAnnounceDebounce sets the quiet period for which announcing a newly
+// published advertisement is deferred. Advertisements published in quick
+// succession, e.g. by many calls to Engine.NotifyPut, are each still added to
+// the chain and set as its head as they are published, but only the head is
+// announced, once no advertisement has been published for the quiet period.
+// This reduces the gossip pubsub and HTTP announce traffic, since indexers
+// sync every advertisement up to the announced head anyway.
+//
+// If maxDelay is positive, then an announcement is deferred for no longer than
+// maxDelay, so that the head is still announced while advertisements are
+// published continuously. A pending announcement is sent when the engine is
+// shut down, and dropped when Engine.PublishLatest announces the head.
+//
+// If unset, or set to zero, each advertisement is announced as it is
+// published.
+func WithAnnounceDebounce(quiet, maxDelay time.Duration) Option {
+	return func(o *options) error {
+		if quiet < 0 || maxDelay < 0 {
+			return fmt.Errorf("announce debounce durations must not be negative, got %s and %s", quiet, maxDelay)
+		}
+		o.announceQuiet = quiet
+		o.announceMaxDelay = maxDelay
+		return nil
+	}
+}
+
+// WithStaticCarDir sets the directory to which each advertisement published
+// by the engine is additionally written, together with its entries, as a CAR
+// file named after the CID of the advertisement with the advertisement as
+// root, along with a head file that holds the CID of the latest advertisement
+// signed as served at /ipni/v1/ad/head by IPNI HTTP publishers. The directory
+// is suitable for serving from a static HTTP server or CDN, so that
+// advertisements can be hosted without running a publisher. See:
+// StaticCarPath, StaticHeadFile.
+//
+// The directory is created if it does not exist. Files are written before the
+// advertisement is announced, and replaced atomically. Advertisements that are
+// missing from the directory, e.g. because they were published before it was
+// set, are written on the next publish, in which case entries that are not
+// stored are regenerated from the registered provider.MultihashLister. Failures
+// to write are logged and do not fail publishing.
+// If unset, advertisements are only stored in the datastore.
+func WithStaticCarDir(dir string) Option {
+	return func(o *options) error {
+		o.staticCarDir = dir
+		return nil
+	}
+}
+
+// WithAuditLog sets whether every advertisement published by the engine is
+// recorded in an append-only audit log kept in the datastore, along with its
+// context ID, provider, number of multihashes, retrieval protocols, and the
+// outcome of its announcement. Records are never deleted. Disabled by default.
+//
+// See: Engine.ListAuditRecords.
+func WithAuditLog(enable bool) Option {
+	return func(o *options) error {
+		o.auditLog = enable
+		return nil
+	}
+}
+
+// WithAdSequence sets whether the engine embeds a sequence number and a
+// timestamp into the metadata of each advertisement it generates, as the
+// AdSequence protocol, so that they are signed along with the advertisement.
+// Sequence numbers increase by one from each advertisement to the next, and
+// timestamps strictly increase, so that downstream systems can order
+// advertisements without walking the chain. See: GetAdSequence.
+//
+// Advertisements published before the option is set are numbered as well,
+// when the first advertisement with a sequence is published. Advertisements
+// given to Engine.Publish and Engine.PublishLocal are published as they are,
+// without a sequence, but are counted in the sequence numbers of the
+// advertisements that follow them. Disabled by default.
+func WithAdSequence(enable bool) Option {
+	return func(o *options) error {
+		o.adSequence = enable
+		if enable {
+			o.metadataContext = o.metadataContext.WithProtocol(AdSequenceProtocolID, func() metadata.Protocol { return &AdSequence{} })
+		}
+		return nil
+	}
+}
+
 // WithStorageReadOpenerErrorHook allows the calling applicaiton to invoke a custom piece logic whenever a storage read opener error occurs.
 // For example the calling application can delete corrupted / create a new advertisement if the datastore was corrupted for some reason.
 // The calling application can return ipld.ErrNotFound{} to indicate IPNI that this advertisement should be skipped without halting processing of the rest of the chain.
@@ -444,3 +1110,39 @@ func WithStorageReadOpenerErrorHook(hook func(ipld.LinkContext, ipld.Link, error
 		return nil
 	}
 }
+
+// WithRetention sets the retention policy that Engine.Prune applies to the
+// advertisement chain: advertisements that are neither among the latest
+// maxAds advertisements, nor published within maxAge, are pruned. A bound is
+// disabled if it is zero, and no advertisements are pruned if both are. The
+// latest advertisement is always kept.
+//
+// Advertisements published by versions of the engine that did not record
+// their publish time are only kept by maxAds.
+func WithRetention(maxAds int, maxAge time.Duration) Option {
+	return func(o *options) error {
+		if maxAds < 0 {
+			return fmt.Errorf("number of advertisements to retain must not be negative, got %d", maxAds)
+		}
+		if maxAge < 0 {
+			return fmt.Errorf("age of advertisements to retain must not be negative, got %s", maxAge)
+		}
+		o.retainAds = maxAds
+		o.retainAge = maxAge
+		return nil
+	}
+}
+
+// WithFaults sets the failures that the engine injects at random, so that
+// deployments can be tested for how they handle them. The failures are
+// returned as, or logged with, errors that wrap ErrInjectedFault. Faults must
+// not be injected in production.
+func WithFaults(f Faults) Option {
+	return func(o *options) error {
+		if err := f.validate(); err != nil {
+			return err
+		}
+		o.faults = f
+		return nil
+	}
+}
@@ -0,0 +1,228 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipni/go-libipni/ingest/schema"
+)
+
+const (
+	// adTimeMapPrefix prefixes the time at which each advertisement was
+	// published, which is used to prune advertisements by age.
+	adTimeMapPrefix = "map/adTime/"
+	// prunedHorizonKey is the key of the CID of the latest advertisement
+	// that was pruned. See: Engine.Prune.
+	prunedHorizonKey = "prune/horizon"
+)
+
+var dsPrunedHorizonKey = datastore.NewKey(prunedHorizonKey)
+
+// PruneResult describes the outcome of pruning the advertisement chain. See:
+// Engine.Prune.
+type PruneResult struct {
+	// Horizon is the CID of the latest advertisement that is pruned, i.e.
+	// the PreviousID of the earliest advertisement that is kept, or cid.Undef
+	// if no advertisements have ever been pruned.
+	Horizon cid.Cid
+	// Ads is the number of advertisements pruned.
+	Ads int
+	// EntriesChains is the number of cached entries DAGs that were deleted
+	// along with the pruned advertisements.
+	EntriesChains int
+}
+
+// Prune deletes the advertisements that are outside the retention policy set
+// via WithRetention from the datastore, along with their cached entries
+// chunks that are not referenced by any advertisement that is kept. Nothing is
+// pruned if no retention policy is set. The latest advertisement is always
+// kept.
+//
+// The mappings of the context IDs that are currently advertised are kept, so
+// that their content can be removed or updated regardless of whether the
+// advertisement that published it was pruned.
+//
+// Only the kept part of the chain is served once pruned: the latest
+// advertisement that is pruned, called the horizon, is recorded and reported
+// by Engine.PrunedHorizon, and requests for pruned advertisements fail as
+// not found. An indexer that has already synced past the horizon is
+// unaffected. An indexer that syncs the chain from scratch, or that last
+// synced an advertisement that has since been pruned, ingests the kept
+// advertisements and then fails to fetch the horizon, so it should be
+// configured to stop at the depth of the kept chain.
+func (e *Engine) Prune(ctx context.Context) (*PruneResult, error) {
+	e.publishLock.Lock()
+	defer e.publishLock.Unlock()
+
+	horizon, err := e.getPrunedHorizon(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := &PruneResult{Horizon: horizon}
+	if e.retainAds <= 0 && e.retainAge <= 0 {
+		return result, nil
+	}
+	head, err := e.getLatestAdCid(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not get latest advertisement: %w", err)
+	}
+
+	// Find the earliest advertisement to keep, along with the entries
+	// referenced by the kept advertisements.
+	now := time.Now()
+	keptEntries := make(map[cid.Cid]struct{})
+	next := cid.Undef
+	for i, c := 0, head; c != cid.Undef && c != horizon; i++ {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		if i != 0 && !e.retained(ctx, c, i, now) {
+			next = c
+			break
+		}
+		ad, err := e.loadAd(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		if ad.Entries != nil && ad.Entries != schema.NoEntries {
+			keptEntries[ad.Entries.(cidlink.Link).Cid] = struct{}{}
+		}
+		c = ad.PreviousCid()
+	}
+	if next == cid.Undef {
+		return result, nil
+	}
+
+	// Delete the advertisements from the new horizon down to the previous
+	// one, or to the start of the chain.
+	batch, err := e.ds.Batch(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create datastore batch: %w", err)
+	}
+	if err = batch.Put(ctx, dsPrunedHorizonKey, next.Bytes()); err != nil {
+		return nil, err
+	}
+	for c := next; c != cid.Undef && c != horizon; {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		ad, err := e.loadAd(ctx, c)
+		if err != nil {
+			if errors.Is(err, ErrAdNotFound) {
+				break
+			}
+			return nil, err
+		}
+		if ad.Entries != nil && ad.Entries != schema.NoEntries && e.entriesChunker != nil {
+			if _, ok := keptEntries[ad.Entries.(cidlink.Link).Cid]; !ok {
+				removed, err := e.entriesChunker.Remove(ctx, ad.Entries)
+				if err != nil {
+					return nil, fmt.Errorf("cannot delete entries of advertisement %s: %w", c, err)
+				}
+				if removed {
+					result.EntriesChains++
+				}
+			}
+		}
+		if err = batch.Delete(ctx, datastore.NewKey(c.String())); err != nil {
+			return nil, err
+		}
+		if err = batch.Delete(ctx, adTimeKey(c)); err != nil {
+			return nil, err
+		}
+		if err = batch.Delete(ctx, adLabelsKey(c)); err != nil {
+			return nil, err
+		}
+		result.Ads++
+		c = ad.PreviousCid()
+	}
+	// The chunks of the deleted entries may be cached in memory, from where
+	// they would otherwise still be served.
+	if result.EntriesChains != 0 {
+		e.memCache.clear()
+	}
+	if err = batch.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("cannot delete pruned advertisements: %w", err)
+	}
+	if err = e.ds.Sync(ctx, datastore.NewKey("/")); err != nil {
+		return nil, err
+	}
+	result.Horizon = next
+
+	// The cached chain length no longer holds.
+	e.stats.chainLenMutex.Lock()
+	e.stats.chainLenHead = cid.Undef
+	e.stats.chainLenMutex.Unlock()
+
+	log.Infow("Pruned advertisement chain", "horizon", next, "ads", result.Ads, "entriesChains", result.EntriesChains)
+	return result, nil
+}
+
+// PrunedHorizon returns the CID of the latest advertisement that was pruned
+// by Engine.Prune, or cid.Undef if none was. Advertisements down the chain
+// from the horizon, including the horizon itself, are no longer stored.
+func (e *Engine) PrunedHorizon(ctx context.Context) (cid.Cid, error) {
+	return e.getPrunedHorizon(ctx)
+}
+
+// retained returns whether the advertisement with the given CID, at the given
+// depth from the head of the chain, is kept by the retention policy.
+// Advertisements published before their time was recorded are only kept by
+// the retained number of advertisements.
+func (e *Engine) retained(ctx context.Context, c cid.Cid, depth int, now time.Time) bool {
+	if depth < e.retainAds {
+		return true
+	}
+	if e.retainAge <= 0 {
+		return false
+	}
+	published, err := e.getAdTime(ctx, c)
+	if err != nil {
+		log.Warnw("Cannot get time at which advertisement was published", "adCid", c, "err", err)
+		return true
+	}
+	return !published.IsZero() && now.Sub(published) <= e.retainAge
+}
+
+func adTimeKey(c cid.Cid) datastore.Key {
+	return datastore.NewKey(adTimeMapPrefix + c.String())
+}
+
+func (e *Engine) putAdTime(ctx context.Context, w datastore.Write, c cid.Cid, t time.Time) error {
+	return w.Put(ctx, adTimeKey(c), []byte(strconv.FormatInt(t.UnixNano(), 10)))
+}
+
+// getAdTime returns the time at which the advertisement with the given CID was
+// published, or the zero time if it is unknown.
+func (e *Engine) getAdTime(ctx context.Context, c cid.Cid) (time.Time, error) {
+	data, err := e.ds.Get(ctx, adTimeKey(c))
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+	nanos, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, nanos), nil
+}
+
+func (e *Engine) getPrunedHorizon(ctx context.Context) (cid.Cid, error) {
+	b, err := e.ds.Get(ctx, dsPrunedHorizonKey)
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return cid.Undef, nil
+		}
+		return cid.Undef, err
+	}
+	_, c, err := cid.CidFromBytes(b)
+	return c, err
+}
@@ -0,0 +1,160 @@
+package engine_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_Prune(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	_, err := engine.New(engine.WithRetention(-1, 0))
+	require.Error(t, err)
+
+	subject, err := engine.New(engine.WithPublisherKind(engine.NoPublisher), engine.WithRetention(2, 0))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	t.Cleanup(func() { subject.Shutdown() })
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+
+	result, err := subject.Prune(ctx)
+	require.NoError(t, err)
+	require.Equal(t, &engine.PruneResult{}, result)
+
+	md := metadata.Default.New(metadata.Bitswap{})
+	var adCids []cid.Cid
+	for _, contextID := range []string{"fish", "lobster", "crab", "squid", "clam"} {
+		adCid, err := subject.NotifyPut(ctx, nil, []byte(contextID), md)
+		require.NoError(t, err)
+		adCids = append(adCids, adCid)
+	}
+	stats, err := subject.Stats(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 5, stats.ChainLength)
+
+	result, err = subject.Prune(ctx)
+	require.NoError(t, err)
+	require.Equal(t, &engine.PruneResult{Horizon: adCids[2], Ads: 3, EntriesChains: 3}, result)
+	horizon, err := subject.PrunedHorizon(ctx)
+	require.NoError(t, err)
+	require.Equal(t, adCids[2], horizon)
+	for _, adCid := range adCids[:3] {
+		_, err = subject.GetAdInfo(ctx, adCid)
+		require.ErrorIs(t, err, engine.ErrAdNotFound)
+	}
+	info, err := subject.GetAdInfo(ctx, adCids[3])
+	require.NoError(t, err)
+	require.True(t, info.EntriesPresent)
+	stats, err = subject.Stats(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, stats.ChainLength)
+
+	// The kept chain is exported down to the horizon.
+	var buf bytes.Buffer
+	_, err = subject.ExportChain(ctx, &buf, false)
+	require.NoError(t, err)
+	_, blockCids := readCar(t, buf.Bytes())
+	require.Equal(t, []cid.Cid{adCids[4], adCids[3]}, blockCids)
+
+	// Pruning again only prunes what has since fallen out of retention, and
+	// content of pruned advertisements can still be removed.
+	rmCid, err := subject.NotifyRemove(ctx, "", []byte("fish"))
+	require.NoError(t, err)
+	result, err = subject.Prune(ctx)
+	require.NoError(t, err)
+	require.Equal(t, &engine.PruneResult{Horizon: adCids[3], Ads: 1, EntriesChains: 1}, result)
+	result, err = subject.Prune(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, result.Ads)
+	head, _, err := subject.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	require.Equal(t, rmCid, head)
+}
+
+func TestEngine_PruneEntriesCachedInMemory(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	subject, err := engine.New(
+		engine.WithPublisherKind(engine.NoPublisher),
+		engine.WithRetention(1, 0),
+		engine.WithEntriesMemoryCacheSize(1<<20))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	t.Cleanup(func() { subject.Shutdown() })
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+
+	md := metadata.Default.New(metadata.Bitswap{})
+	adCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.NoError(t, err)
+	ad, err := subject.GetAdv(ctx, adCid)
+	require.NoError(t, err)
+	_, err = subject.NotifyPut(ctx, nil, []byte("lobster"), md)
+	require.NoError(t, err)
+
+	// Serve the entries chunk, which caches it in memory.
+	lsys := subject.LinkSystem()
+	_, err = lsys.LoadRaw(ipld.LinkContext{Ctx: ctx}, ad.Entries)
+	require.NoError(t, err)
+	_, err = subject.GetLocalBlock(ctx, ad.Entries.(cidlink.Link).Cid)
+	require.NoError(t, err)
+
+	result, err := subject.Prune(ctx)
+	require.NoError(t, err)
+	require.Equal(t, &engine.PruneResult{Horizon: adCid, Ads: 1, EntriesChains: 1}, result)
+	_, err = subject.GetLocalBlock(ctx, ad.Entries.(cidlink.Link).Cid)
+	require.ErrorIs(t, err, engine.ErrBlockNotFound)
+}
+
+func TestEngine_PruneByAge(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	subject, err := engine.New(engine.WithPublisherKind(engine.NoPublisher), engine.WithRetention(0, 50*time.Millisecond))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	t.Cleanup(func() { subject.Shutdown() })
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+
+	md := metadata.Default.New(metadata.Bitswap{})
+	old, err := subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	_, err = subject.NotifyPut(ctx, nil, []byte("lobster"), md)
+	require.NoError(t, err)
+	_, err = subject.NotifyPut(ctx, nil, []byte("crab"), md)
+	require.NoError(t, err)
+
+	result, err := subject.Prune(ctx)
+	require.NoError(t, err)
+	require.Equal(t, old, result.Horizon)
+	require.Equal(t, 1, result.Ads)
+
+	// The latest advertisement is kept however old it is.
+	time.Sleep(100 * time.Millisecond)
+	result, err = subject.Prune(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Ads)
+	stats, err := subject.Stats(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, stats.ChainLength)
+}
@@ -0,0 +1,267 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// ErrPublishNotQueued signals that a publish is not waiting in the publish
+// queue, either because it is unknown, already being published or done.
+var ErrPublishNotQueued = errors.New("publish not queued")
+
+// PublishPriority is the priority of a publish waiting in the publish queue.
+// Publishes of higher priority are published first, and publishes of equal
+// priority in the order in which they were queued.
+type PublishPriority int
+
+const (
+	// PriorityMetadata is the priority of advertisements that update the
+	// metadata of a context ID that is already advertised.
+	PriorityMetadata PublishPriority = iota
+	// PriorityNewData is the priority of advertisements of context IDs that
+	// are not yet advertised, and of any other update of the chain.
+	PriorityNewData
+	// PriorityRemoval is the priority of advertisements that remove a context
+	// ID, so that retractions are published ahead of bulk publishes.
+	PriorityRemoval
+)
+
+func (p PublishPriority) String() string {
+	switch p {
+	case PriorityMetadata:
+		return "metadata"
+	case PriorityNewData:
+		return "new-data"
+	case PriorityRemoval:
+		return "removal"
+	default:
+		return fmt.Sprintf("PublishPriority(%d)", int(p))
+	}
+}
+
+// ParsePublishPriority returns the priority with the given name, as returned
+// by PublishPriority.String.
+func ParsePublishPriority(name string) (PublishPriority, error) {
+	for p := PriorityMetadata; p <= PriorityRemoval; p++ {
+		if p.String() == name {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown publish priority %q; must be one of metadata, new-data or removal", name)
+}
+
+type publishPriorityKey struct{}
+
+// ContextWithPublishPriority returns a context with which the advertisements
+// published by Engine.NotifyPut, Engine.NotifyPutWithEntries and
+// Engine.NotifyRemove are queued with the given priority, instead of that of
+// their kind. This allows bulk publishes, such as backfills, to yield to
+// others.
+func ContextWithPublishPriority(ctx context.Context, p PublishPriority) context.Context {
+	return context.WithValue(ctx, publishPriorityKey{}, p)
+}
+
+// QueuedPublish describes a publish in the publish queue.
+type QueuedPublish struct {
+	// ID identifies the publish in the queue.
+	ID uint64
+	// Kind is the kind of publish, e.g. "put" or "remove".
+	Kind string
+	// Priority is the priority of the publish.
+	Priority PublishPriority
+	// Provider and ContextID are those of the advertisement, if the publish
+	// is of the advertisement of a context ID.
+	Provider  peer.ID
+	ContextID []byte
+	// Queued is the time at which the publish was queued.
+	Queued time.Time
+	// Publishing is whether the publish is in progress, rather than waiting.
+	Publishing bool
+}
+
+// queuedPublish is a publish waiting in, or holding, the publish queue.
+type queuedPublish struct {
+	QueuedPublish
+	// order orders the waiting publishes of equal priority.
+	order int64
+	// ready is closed once the publish holds the queue.
+	ready chan struct{}
+}
+
+// publishQueue serializes the updates of the head of the advertisement chain
+// like a mutex, except that the waiting updates acquire it in the order of
+// their priority rather than in arbitrary order. Lock and Unlock make it a
+// sync.Locker for updates that are not queued with a priority of their own.
+type publishQueue struct {
+	mutex   sync.Mutex
+	current *queuedPublish
+	waiting []*queuedPublish
+	lastID  uint64
+}
+
+// acquire queues the given publish, and waits until it holds the queue or the
+// given context is done. The queue must be released by calling Unlock once
+// acquired.
+func (q *publishQueue) acquire(ctx context.Context, pub QueuedPublish) error {
+	q.mutex.Lock()
+	q.lastID++
+	pub.ID = q.lastID
+	pub.Queued = time.Now()
+	qp := &queuedPublish{
+		QueuedPublish: pub,
+		order:         int64(pub.ID),
+		ready:         make(chan struct{}),
+	}
+	if q.current == nil && len(q.waiting) == 0 {
+		qp.Publishing = true
+		q.current = qp
+		q.mutex.Unlock()
+		return nil
+	}
+	q.waiting = append(q.waiting, qp)
+	q.mutex.Unlock()
+
+	select {
+	case <-qp.ready:
+		return nil
+	case <-ctx.Done():
+	}
+	q.mutex.Lock()
+	select {
+	case <-qp.ready:
+		// Acquired while the context was done; pass the queue on.
+		q.mutex.Unlock()
+		q.Unlock()
+	default:
+		q.remove(qp.ID)
+		q.mutex.Unlock()
+	}
+	return ctx.Err()
+}
+
+// Lock acquires the queue for an update of the chain of priority
+// PriorityNewData.
+func (q *publishQueue) Lock() {
+	_ = q.acquire(context.Background(), QueuedPublish{Kind: "publish", Priority: PriorityNewData})
+}
+
+// Unlock releases the queue to the waiting publish of highest priority, if
+// any.
+func (q *publishQueue) Unlock() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.current == nil {
+		panic("unlock of unlocked publish queue")
+	}
+	q.current = nil
+	if len(q.waiting) == 0 {
+		return
+	}
+	next := q.waiting[0]
+	for _, qp := range q.waiting[1:] {
+		if qp.Priority > next.Priority || (qp.Priority == next.Priority && qp.order < next.order) {
+			next = qp
+		}
+	}
+	q.remove(next.ID)
+	next.Publishing = true
+	q.current = next
+	close(next.ready)
+}
+
+// remove removes the waiting publish with the given ID. The mutex must be
+// held.
+func (q *publishQueue) remove(id uint64) *queuedPublish {
+	for i, qp := range q.waiting {
+		if qp.ID == id {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			return qp
+		}
+	}
+	return nil
+}
+
+// find returns the waiting publish with the given ID. The mutex must be held.
+func (q *publishQueue) find(id uint64) *queuedPublish {
+	for _, qp := range q.waiting {
+		if qp.ID == id {
+			return qp
+		}
+	}
+	return nil
+}
+
+// list lists the publish in progress, if any, followed by the waiting
+// publishes in the order in which they will be published.
+func (q *publishQueue) list() []QueuedPublish {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	waiting := append([]*queuedPublish{}, q.waiting...)
+	sort.Slice(waiting, func(i, j int) bool {
+		if waiting[i].Priority != waiting[j].Priority {
+			return waiting[i].Priority > waiting[j].Priority
+		}
+		return waiting[i].order < waiting[j].order
+	})
+	pubs := make([]QueuedPublish, 0, len(waiting)+1)
+	if q.current != nil {
+		pubs = append(pubs, q.current.QueuedPublish)
+	}
+	for _, qp := range waiting {
+		pubs = append(pubs, qp.QueuedPublish)
+	}
+	return pubs
+}
+
+// PublishQueue lists the publish in progress, if any, followed by the
+// publishes waiting for it in the order in which they will be published.
+func (e *Engine) PublishQueue() []QueuedPublish {
+	return e.publishLock.list()
+}
+
+// SetPublishPriority changes the priority of the waiting publish with the
+// given ID, which keeps its place among the publishes of the new priority
+// according to when it was queued. ErrPublishNotQueued is returned if no
+// publish with the ID is waiting.
+func (e *Engine) SetPublishPriority(id uint64, p PublishPriority) (QueuedPublish, error) {
+	q := &e.publishLock
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	qp := q.find(id)
+	if qp == nil {
+		return QueuedPublish{}, ErrPublishNotQueued
+	}
+	qp.Priority = p
+	log.Infow("Set priority of queued publish", "id", id, "priority", p)
+	return qp.QueuedPublish, nil
+}
+
+// MovePublishToFront makes the waiting publish with the given ID the next to
+// be published, by raising its priority to the highest of the waiting
+// publishes and placing it ahead of them. ErrPublishNotQueued is returned if
+// no publish with the ID is waiting.
+func (e *Engine) MovePublishToFront(id uint64) (QueuedPublish, error) {
+	q := &e.publishLock
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	qp := q.find(id)
+	if qp == nil {
+		return QueuedPublish{}, ErrPublishNotQueued
+	}
+	for _, other := range q.waiting {
+		if other.Priority > qp.Priority {
+			qp.Priority = other.Priority
+		}
+		if other.order <= qp.order && other != qp {
+			qp.order = other.order - 1
+		}
+	}
+	log.Infow("Moved queued publish to front", "id", id, "priority", qp.Priority)
+	return qp.QueuedPublish, nil
+}
@@ -0,0 +1,141 @@
+package engine_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_PublishQueue(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	subject, err := engine.New()
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+
+	listing := make(chan struct{})
+	release := make(chan struct{})
+	subject.RegisterMultihashLister(func(_ context.Context, _ peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		if string(contextID) == "blocker" {
+			close(listing)
+			<-release
+		}
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+	bitswap := metadata.Default.New(metadata.Bitswap{})
+	for _, contextID := range []string{"lobster", "crab"} {
+		_, err = subject.NotifyPut(ctx, nil, []byte(contextID), bitswap)
+		require.NoError(t, err)
+	}
+	require.Empty(t, subject.PublishQueue())
+
+	// Hold the queue while publishing the blocker, and queue a publish of
+	// each kind behind it.
+	errs := make(chan error, 6)
+	go func() {
+		_, err := subject.NotifyPut(ctx, nil, []byte("blocker"), bitswap)
+		errs <- err
+	}()
+	<-listing
+	awaitQueued := func(n int) []engine.QueuedPublish {
+		var queue []engine.QueuedPublish
+		require.Eventually(t, func() bool {
+			queue = subject.PublishQueue()
+			return len(queue) == n
+		}, 5*time.Second, 10*time.Millisecond)
+		return queue
+	}
+	graphsync := metadata.Default.New(&metadata.GraphsyncFilecoinV1{PieceCID: test.RandomCids(1)[0]})
+	queue := func(f func() error) {
+		go func() { errs <- f() }()
+	}
+	queue(func() error {
+		_, err := subject.NotifyPut(ctx, nil, []byte("lobster"), graphsync)
+		return err
+	})
+	awaitQueued(2)
+	queue(func() error {
+		_, err := subject.NotifyPut(ctx, nil, []byte("fish"), bitswap)
+		return err
+	})
+	awaitQueued(3)
+	queue(func() error {
+		_, err := subject.NotifyRemove(ctx, "", []byte("crab"))
+		return err
+	})
+	awaitQueued(4)
+	queue(func() error {
+		_, err := subject.NotifyPut(engine.ContextWithPublishPriority(ctx, engine.PriorityMetadata), nil, []byte("backfill"), bitswap)
+		return err
+	})
+	pubs := awaitQueued(5)
+
+	kinds := func(pubs []engine.QueuedPublish) []string {
+		var kinds []string
+		for _, pub := range pubs {
+			kinds = append(kinds, pub.Kind+":"+string(pub.ContextID))
+		}
+		return kinds
+	}
+	require.Equal(t, []string{"put:blocker", "remove:crab", "put:fish", "update:lobster", "put:backfill"}, kinds(pubs))
+	require.True(t, pubs[0].Publishing)
+	require.False(t, pubs[1].Publishing)
+	require.Equal(t, engine.PriorityRemoval, pubs[1].Priority)
+	require.Equal(t, engine.PriorityNewData, pubs[2].Priority)
+	require.Equal(t, engine.PriorityMetadata, pubs[3].Priority)
+	require.Equal(t, subject.ProviderID(), pubs[3].Provider)
+
+	// Reorder the queue.
+	_, err = subject.SetPublishPriority(pubs[0].ID, engine.PriorityRemoval)
+	require.ErrorIs(t, err, engine.ErrPublishNotQueued)
+	pub, err := subject.SetPublishPriority(pubs[2].ID, engine.PriorityMetadata)
+	require.NoError(t, err)
+	require.Equal(t, engine.PriorityMetadata, pub.Priority)
+	pub, err = subject.MovePublishToFront(pubs[4].ID)
+	require.NoError(t, err)
+	require.Equal(t, engine.PriorityRemoval, pub.Priority)
+	require.Equal(t, []string{"put:blocker", "put:backfill", "remove:crab", "update:lobster", "put:fish"}, kinds(subject.PublishQueue()))
+
+	// A publish whose context is done leaves the queue.
+	cancelCtx, cancelPut := context.WithCancel(ctx)
+	queue(func() error {
+		_, err := subject.NotifyPut(cancelCtx, nil, []byte("shrimp"), bitswap)
+		return err
+	})
+	awaitQueued(6)
+	cancelPut()
+	require.ErrorIs(t, <-errs, context.Canceled)
+	awaitQueued(5)
+
+	// The publishes are published in the order of the queue.
+	close(release)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, <-errs)
+	}
+	require.Empty(t, subject.PublishQueue())
+	var published []string
+	_, ad, err := subject.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	for len(published) < 5 {
+		published = append(published, string(ad.ContextID))
+		ad, err = subject.GetAdv(ctx, ad.PreviousID.(cidlink.Link).Cid)
+		require.NoError(t, err)
+	}
+	require.Equal(t, []string{"fish", "lobster", "crab", "backfill", "blocker"}, published)
+
+	_, err = engine.ParsePublishPriority("urgent")
+	require.ErrorContains(t, err, "unknown publish priority")
+	priority, err := engine.ParsePublishPriority("removal")
+	require.NoError(t, err)
+	require.Equal(t, engine.PriorityRemoval, priority)
+}
@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+
+	"github.com/ipfs/go-cid"
+	provider "github.com/ipni/index-provider"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+type holdAnnounceKey struct{}
+
+// ContextWithHeldAnnounce returns a context with which the advertisements
+// published by the engine are put into the chain and set as its head, but
+// not announced, so that the advertisements of a bulk publish can be
+// announced at once when done, by announcing the head via
+// Engine.PublishLatest. Announcing the head is enough for indexers to
+// ingest all the advertisements before it. See: Engine.NotifyRemoveMany.
+func ContextWithHeldAnnounce(ctx context.Context) context.Context {
+	return context.WithValue(ctx, holdAnnounceKey{}, true)
+}
+
+func isAnnounceHeld(ctx context.Context) bool {
+	held, _ := ctx.Value(holdAnnounceKey{}).(bool)
+	return held
+}
+
+// RemoveResult is the outcome of removing a context ID via
+// Engine.NotifyRemoveMany.
+type RemoveResult struct {
+	// ContextID is the removed context ID.
+	ContextID []byte
+	// AdCid is the CID of the removal advertisement, if it was published.
+	AdCid cid.Cid
+	// Err is the error that removing the context ID failed with, if any, e.g.
+	// provider.ErrContextIDNotFound.
+	Err error
+	// Done is the number of context IDs removed so far, including this one,
+	// and Total is the number of context IDs to remove.
+	Done, Total int
+}
+
+// NotifyRemoveMany retracts the content advertised under many context IDs of
+// the given provider at once, e.g. when expiring many deals. If p is empty,
+// the default provider is assumed.
+//
+// Since an advertisement retracts a single context ID, one removal
+// advertisement is published per context ID, like Engine.NotifyRemove, but
+// only the last one is announced, once all are published, rather than one
+// announcement being sent per advertisement. Removals are queued with
+// removal priority, unless the context sets the priority.
+//
+// A context ID that fails to be removed, e.g. because it is not advertised,
+// does not affect the others. If progress is not nil, it is called with the
+// result of each context ID once removed, one call at a time. The CID of the
+// last removal advertisement is returned, or cid.Undef if none was published.
+// A non-nil error is returned only if the context is canceled before all
+// context IDs are removed, in which case the removals already published are
+// still announced.
+func (e *Engine) NotifyRemoveMany(ctx context.Context, p peer.ID, contextIDs [][]byte, progress func(RemoveResult)) (cid.Cid, error) {
+	heldCtx := ContextWithHeldAnnounce(ctx)
+	latest := cid.Undef
+	var err error
+	for i, contextID := range contextIDs {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		res := RemoveResult{ContextID: contextID, Done: i + 1, Total: len(contextIDs)}
+		res.AdCid, res.Err = e.NotifyRemove(heldCtx, p, contextID)
+		if res.Err == nil {
+			latest = res.AdCid
+		} else if !errors.Is(res.Err, provider.ErrContextIDNotFound) {
+			log.Errorw("Failed to remove context ID", "contextID", base64.StdEncoding.EncodeToString(contextID), "err", res.Err)
+		}
+		if progress != nil {
+			progress(res)
+		}
+	}
+	if latest != cid.Undef {
+		log.Infow("Announcing removal advertisements", "count", len(contextIDs), "adCid", latest)
+		// Announce the head, which links to all the removals, even if the
+		// context is canceled.
+		if _, perr := e.PublishLatest(context.WithoutCancel(ctx)); perr != nil {
+			log.Errorw("Failed to announce removal advertisements", "err", perr)
+		}
+	}
+	return latest, err
+}
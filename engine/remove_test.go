@@ -0,0 +1,72 @@
+package engine_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_NotifyRemoveMany(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	sender := &recordingSender{}
+	subject, err := engine.New(
+		engine.WithPublisherKind(engine.HttpPublisher),
+		engine.WithHttpPublisherWithoutServer(),
+		engine.WithHttpPublisherAnnounceAddr("/ip4/127.0.0.1/tcp/3104/http"),
+		engine.WithPubsubAnnounce(false),
+		engine.WithAnnounceSender(sender))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	t.Cleanup(func() { subject.Shutdown() })
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+
+	contextIDs := [][]byte{[]byte("fish"), []byte("lobster"), []byte("crab")}
+	for _, contextID := range contextIDs {
+		_, err = subject.NotifyPut(ctx, nil, contextID, metadata.Default.New(metadata.Bitswap{}))
+		require.NoError(t, err)
+	}
+	sender.sent = nil
+
+	// One removal advertisement is published per context ID, and only the
+	// last is announced. Context IDs that are not advertised are skipped.
+	var results []engine.RemoveResult
+	adCid, err := subject.NotifyRemoveMany(ctx, "", append(contextIDs, []byte("squid")), func(res engine.RemoveResult) {
+		results = append(results, res)
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+	for i, res := range results[:3] {
+		require.Equal(t, contextIDs[i], res.ContextID)
+		require.NoError(t, res.Err)
+		require.Equal(t, i+1, res.Done)
+		require.Equal(t, 4, res.Total)
+		ad, err := subject.GetAdv(ctx, res.AdCid)
+		require.NoError(t, err)
+		require.True(t, ad.IsRm)
+		require.Equal(t, contextIDs[i], ad.ContextID)
+	}
+	require.ErrorIs(t, results[3].Err, provider.ErrContextIDNotFound)
+	require.Equal(t, results[2].AdCid, adCid)
+	require.Equal(t, []cid.Cid{adCid}, sender.sent)
+
+	remaining, err := subject.ListContextIDs(ctx, "")
+	require.NoError(t, err)
+	require.Empty(t, remaining)
+
+	// Nothing is announced if nothing is removed.
+	adCid, err = subject.NotifyRemoveMany(ctx, "", contextIDs, nil)
+	require.NoError(t, err)
+	require.Equal(t, cid.Undef, adCid)
+	require.Len(t, sender.sent, 1)
+}
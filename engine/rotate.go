@@ -0,0 +1,136 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipni/index-provider/engine/xproviders"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// RotateKey publishes a hand-off advertisement that transfers the identity of
+// the provider to the given new key, and continues the advertisement chain
+// under the new key from then on.
+//
+// The hand-off advertisement is an extended providers advertisement that
+// applies to all content of the current provider and lists the identity of the
+// new key as an extended provider. It is signed by both the current and the
+// new key, which proves to indexers that the owner of the current identity has
+// handed over to the new identity. All subsequent advertisements are signed by
+// the new key, with the new identity as their provider, and link back to the
+// hand-off advertisement.
+//
+// Key rotation is only supported when the provider identity is the identity of
+// the key used to sign advertisements, and when the publisher does not depend
+// on the identity of the libp2p host. A libp2p host must be restarted with the
+// new identity instead.
+//
+// Content advertised before the rotation remains advertised by the former
+// identity, which indexers attribute to the new identity via the hand-off
+// advertisement. It is updated and removed by passing the former identity as
+// the provider, e.g. to Engine.NotifyRemove, and is not listed for the default
+// provider. The former identity is recorded in the datastore, so that this
+// holds once the engine is restarted with the new key.
+//
+// The CID of the hand-off advertisement is returned.
+func (e *Engine) RotateKey(ctx context.Context, newKey crypto.PrivKey) (cid.Cid, error) {
+	// Hold the publish lock throughout, so that no advertisement is published
+	// between the hand-off and the change of identity.
+	e.publishLock.Lock()
+	defer e.publishLock.Unlock()
+
+	oldID, err := peer.IDFromPrivateKey(e.key)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("cannot get peer ID from private key: %w", err)
+	}
+	if e.provider.ID != oldID {
+		return cid.Undef, fmt.Errorf("provider identity %s differs from the identity %s of the signing key", e.provider.ID, oldID)
+	}
+	newID, err := peer.IDFromPrivateKey(newKey)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("cannot get peer ID from new private key: %w", err)
+	}
+	if newID == oldID {
+		return cid.Undef, errors.New("new key must differ from the current key")
+	}
+	if e.publisher != nil && (e.pubKind == Libp2pPublisher || e.pubKind == Libp2pHttpPublisher) {
+		return cid.Undef, fmt.Errorf("key rotation is not supported with %s publisher; restart the libp2p host with the new identity instead", e.pubKind)
+	}
+
+	latest, err := e.getLatestAdCid(ctx)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("could not get latest advertisement: %w", err)
+	}
+	handOff, err := xproviders.NewAdBuilder(oldID, e.key, e.provider.Addrs).
+		WithExtendedProviders(xproviders.NewInfo(newID, newKey, nil, e.provider.Addrs)).
+		WithLastAdID(latest).
+		BuildAndSign()
+	if err != nil {
+		return cid.Undef, fmt.Errorf("cannot build hand-off advertisement: %w", err)
+	}
+	// Keep the mappings of the context IDs advertised so far, which are
+	// stored without provider ID, keyed to the former identity.
+	if err = e.ds.Put(ctx, dsMappingsProviderKey, []byte(e.mappingsID)); err != nil {
+		return cid.Undef, fmt.Errorf("cannot record provider of context ID mappings: %w", err)
+	}
+	adCid, err := e.publish(ctx, *handOff, -1, 0, nil, func() {})
+	if err != nil {
+		return cid.Undef, fmt.Errorf("cannot publish hand-off advertisement: %w", err)
+	}
+	log.Infow("Published key rotation hand-off advertisement", "adCid", adCid, "oldID", oldID, "newID", newID)
+
+	e.idLock.Lock()
+	e.key = newKey
+	e.provider.ID = newID
+	e.idLock.Unlock()
+
+	// Recreate the publisher and announce senders, since they sign with the
+	// key they were created with.
+	if e.publisher != nil {
+		e.sendersLock.Lock()
+		defer e.sendersLock.Unlock()
+		for _, sender := range e.senders {
+			if err = sender.Close(); err != nil {
+				log.Errorw("Failed to close announce sender", "err", err)
+			}
+		}
+		e.senders = nil
+		if err = e.publisher.Close(); err != nil {
+			log.Errorw("Failed to close publisher", "err", err)
+		}
+		if e.publisher, err = e.newPublisher(e.pubHttpListenAddr, e.pubHttpHandlerPath); err != nil {
+			return adCid, fmt.Errorf("cannot recreate publisher with new key: %w", err)
+		}
+		e.publisher.SetRoot(adCid)
+		if e.senders, err = e.createSenders(e.announceURLs, e.pubsubAnnounce, e.pubsubExtraGossipData); err != nil {
+			return adCid, fmt.Errorf("cannot recreate announce senders with new key: %w", err)
+		}
+	}
+	return adCid, nil
+}
+
+// loadMappingsID loads the identity under which the mappings of context IDs
+// are stored without provider ID, if recorded by Engine.RotateKey.
+func (e *Engine) loadMappingsID(ctx context.Context) error {
+	data, err := e.ds.Get(ctx, dsMappingsProviderKey)
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	e.mappingsID = peer.ID(data)
+	return nil
+}
+
+// identity returns the identity of the default provider and the key with which
+// advertisements are signed, for use without holding publishLock.
+func (e *Engine) identity() (peer.ID, crypto.PrivKey) {
+	e.idLock.RLock()
+	defer e.idLock.RUnlock()
+	return e.provider.ID, e.key
+}
@@ -0,0 +1,288 @@
+package engine_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	headschema "github.com/ipni/go-libipni/dagsync/ipnisync/head"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_RotateKey(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	oldID, oldKey, _ := test.RandomIdentity()
+	newID, newKey, _ := test.RandomIdentity()
+	addr := multiaddr.StringCast("/ip4/127.0.0.1/tcp/9999")
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+
+	subject, err := engine.New(
+		engine.WithDatastore(ds),
+		engine.WithPublisherKind(engine.NoPublisher),
+		engine.WithPrivateKey(oldKey),
+		engine.WithProvider(peer.AddrInfo{ID: oldID, Addrs: []multiaddr.Multiaddr{addr}}))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+
+	md := metadata.Default.New(metadata.Bitswap{})
+	putAdCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.NoError(t, err)
+
+	_, err = subject.RotateKey(ctx, oldKey)
+	require.ErrorContains(t, err, "new key must differ")
+
+	handOffCid, err := subject.RotateKey(ctx, newKey)
+	require.NoError(t, err)
+	require.Equal(t, newID, subject.ProviderID())
+
+	handOff, err := subject.GetAdv(ctx, handOffCid)
+	require.NoError(t, err)
+	require.Equal(t, oldID.String(), handOff.Provider)
+	require.Equal(t, putAdCid, handOff.PreviousID.(cidlink.Link).Cid)
+	require.Empty(t, handOff.ContextID)
+	require.NotNil(t, handOff.ExtendedProvider)
+	var xpIDs []string
+	for _, xp := range handOff.ExtendedProvider.Providers {
+		xpIDs = append(xpIDs, xp.ID)
+	}
+	require.ElementsMatch(t, []string{oldID.String(), newID.String()}, xpIDs)
+	signerID, err := handOff.VerifySignature()
+	require.NoError(t, err)
+	require.Equal(t, oldID, signerID)
+
+	// Subsequent advertisements continue the chain under the new key.
+	nextAdCid, err := subject.NotifyPut(ctx, nil, []byte("lobster"), md)
+	require.NoError(t, err)
+	nextAd, err := subject.GetAdv(ctx, nextAdCid)
+	require.NoError(t, err)
+	require.Equal(t, newID.String(), nextAd.Provider)
+	require.Equal(t, handOffCid, nextAd.PreviousID.(cidlink.Link).Cid)
+	signerID, err = nextAd.VerifySignature()
+	require.NoError(t, err)
+	require.Equal(t, newID, signerID)
+
+	// Content advertised before the rotation remains advertised by the former
+	// identity, and is removed under it.
+	contextIDs, err := subject.ListContextIDs(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("lobster")}, contextIDs)
+	contextIDs, err = subject.ListContextIDs(ctx, oldID)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("fish")}, contextIDs)
+	_, err = subject.NotifyRemove(ctx, "", []byte("fish"))
+	require.ErrorIs(t, err, provider.ErrContextIDNotFound)
+	rmAdCid, err := subject.NotifyRemove(ctx, oldID, []byte("fish"))
+	require.NoError(t, err)
+	rmAd, err := subject.GetAdv(ctx, rmAdCid)
+	require.NoError(t, err)
+	require.True(t, rmAd.IsRm)
+	require.Equal(t, oldID.String(), rmAd.Provider)
+	require.Equal(t, []byte("fish"), rmAd.ContextID)
+
+	// The content of each identity is told apart once restarted with the new
+	// key.
+	require.NoError(t, subject.Shutdown())
+	subject, err = engine.New(
+		engine.WithDatastore(ds),
+		engine.WithPublisherKind(engine.NoPublisher),
+		engine.WithPrivateKey(newKey),
+		engine.WithProvider(peer.AddrInfo{ID: newID, Addrs: []multiaddr.Multiaddr{addr}}))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+	contextIDs, err = subject.ListContextIDs(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("lobster")}, contextIDs)
+	contextIDs, err = subject.ListContextIDs(ctx, oldID)
+	require.NoError(t, err)
+	require.Empty(t, contextIDs)
+	rmAdCid, err = subject.NotifyRemove(ctx, "", []byte("lobster"))
+	require.NoError(t, err)
+	rmAd, err = subject.GetAdv(ctx, rmAdCid)
+	require.NoError(t, err)
+	require.Equal(t, newID.String(), rmAd.Provider)
+}
+
+func TestEngine_RotateKeyWithHttpPublisher(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	oldID, oldKey, _ := test.RandomIdentity()
+	newID, newKey, _ := test.RandomIdentity()
+	subject, err := engine.New(
+		engine.WithPublisherKind(engine.HttpPublisher),
+		engine.WithHttpPublisherListenAddr("127.0.0.1:0"),
+		engine.WithPubsubAnnounce(false),
+		engine.WithPrivateKey(oldKey),
+		engine.WithProvider(peer.AddrInfo{ID: oldID, Addrs: []multiaddr.Multiaddr{multiaddr.StringCast("/ip4/127.0.0.1/tcp/9999")}}))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+
+	_, err = subject.RotateKey(ctx, newKey)
+	require.NoError(t, err)
+	require.Equal(t, newID, subject.ProviderID())
+}
+
+func TestEngine_RotateKeyConcurrently(t *testing.T) {
+	const rotations = 3
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	indexer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer indexer.Close()
+
+	id, key, _ := test.RandomIdentity()
+	subject, err := engine.New(
+		engine.WithPublisherKind(engine.HttpPublisher),
+		engine.WithHttpPublisherWithoutServer(),
+		engine.WithHttpPublisherAnnounceAddr("/ip4/127.0.0.1/tcp/3104/http"),
+		engine.WithPubsubAnnounce(false),
+		engine.WithDirectAnnounce(indexer.URL),
+		engine.WithPrivateKey(key),
+		engine.WithProvider(peer.AddrInfo{ID: id, Addrs: []multiaddr.Multiaddr{multiaddr.StringCast("/ip4/127.0.0.1/tcp/9999")}}))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+
+	// Publish and look up content of the default provider while its key is
+	// rotated.
+	done := make(chan struct{})
+	errs := make(chan error, 1)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		md := metadata.Default.New(metadata.Bitswap{})
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if _, err := subject.NotifyPut(ctx, nil, []byte(fmt.Sprint(i)), md); err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			_, _ = subject.ListContextIDs(ctx, "")
+			_, _ = subject.GetContextInfo(ctx, "", []byte("0"))
+			_, _ = subject.GetHeadInfo(ctx)
+			_, _ = subject.PublishLatestHTTP(ctx)
+		}
+	}()
+	for i := 0; i < rotations; i++ {
+		_, key, _ := test.RandomIdentity()
+		_, err = subject.RotateKey(ctx, key)
+		require.NoError(t, err)
+	}
+	close(done)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	// Every advertisement is signed by the key of its provider, except for
+	// the hand-off advertisements, which are signed by both.
+	_, ad, err := subject.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	for ad != nil {
+		signerID, err := ad.VerifySignature()
+		require.NoError(t, err)
+		require.Equal(t, ad.Provider, signerID.String())
+		if ad.PreviousID == nil {
+			break
+		}
+		ad, err = subject.GetAdv(ctx, ad.PreviousID.(cidlink.Link).Cid)
+		require.NoError(t, err)
+	}
+}
+
+func TestEngine_RotateKeyServesHandOffViaPublisherHandler(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	oldID, oldKey, _ := test.RandomIdentity()
+	newID, newKey, _ := test.RandomIdentity()
+	subject, err := engine.New(
+		engine.WithPublisherKind(engine.HttpPublisher),
+		engine.WithHttpPublisherWithoutServer(),
+		engine.WithHttpPublisherAnnounceAddr("/ip4/127.0.0.1/tcp/3104/http"),
+		engine.WithPubsubAnnounce(false),
+		engine.WithPrivateKey(oldKey),
+		engine.WithProvider(peer.AddrInfo{ID: oldID, Addrs: []multiaddr.Multiaddr{multiaddr.StringCast("/ip4/127.0.0.1/tcp/9999")}}))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+
+	// The handler is obtained before the rotation, as when it is co-hosted by
+	// the admin server.
+	handler, err := subject.GetPublisherHttpFunc()
+	require.NoError(t, err)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	getHead := func() (cid.Cid, peer.ID) {
+		resp, err := http.Get(server.URL + "/ipni/v1/ad/head")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		signed, err := headschema.Decode(resp.Body)
+		require.NoError(t, err)
+		signerID, err := signed.Validate()
+		require.NoError(t, err)
+		return signed.Head.(cidlink.Link).Cid, signerID
+	}
+
+	putAdCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	head, signerID := getHead()
+	require.Equal(t, putAdCid, head)
+	require.Equal(t, oldID, signerID)
+
+	handOffCid, err := subject.RotateKey(ctx, newKey)
+	require.NoError(t, err)
+	head, signerID = getHead()
+	require.Equal(t, handOffCid, head)
+	require.Equal(t, newID, signerID)
+}
@@ -0,0 +1,193 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/go-libipni/ingest/schema"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/multiformats/go-multicodec"
+	"github.com/multiformats/go-varint"
+)
+
+// AdSequenceProtocolID is the code, from the private use range of the
+// multicodec table, of the metadata protocol in which the sequence number and
+// timestamp of an advertisement are embedded. See: WithAdSequence.
+const AdSequenceProtocolID = multicodec.Code(0x3f5e01)
+
+var _ metadata.Protocol = (*AdSequence)(nil)
+
+// AdSequence is the sequence number and timestamp of an advertisement,
+// embedded as a protocol in its metadata by engines with WithAdSequence, so
+// that they are signed along with the advertisement.
+//
+// The sequence number of an advertisement is one more than that of its
+// previous advertisement, and so is its height in the chain, and its
+// timestamp is later than that of its previous advertisement. Downstream
+// systems can therefore order advertisements, and tell how many are missing
+// between two of them, without walking the chain.
+//
+// Like protocols unknown to indexers, it is encoded as its code followed by
+// the varint length of its payload, such that indexers that do not know it
+// skip it as metadata.Unknown.
+type AdSequence struct {
+	// Seq is the sequence number of the advertisement.
+	Seq uint64
+	// Time is when the advertisement was published.
+	Time time.Time
+}
+
+// ID returns AdSequenceProtocolID.
+func (s *AdSequence) ID() multicodec.Code {
+	return AdSequenceProtocolID
+}
+
+// MarshalBinary encodes the code and the varint length of the payload,
+// followed by the payload: the varint sequence number and the varint
+// timestamp in nanoseconds since the Unix epoch.
+func (s *AdSequence) MarshalBinary() ([]byte, error) {
+	if s.Time.UnixNano() < 0 {
+		return nil, errors.New("advertisement timestamp must not be before the Unix epoch")
+	}
+	payload := varint.ToUvarint(s.Seq)
+	payload = append(payload, varint.ToUvarint(uint64(s.Time.UnixNano()))...)
+	buf := varint.ToUvarint(uint64(AdSequenceProtocolID))
+	buf = append(buf, varint.ToUvarint(uint64(len(payload)))...)
+	return append(buf, payload...), nil
+}
+
+// UnmarshalBinary decodes the encoding of MarshalBinary.
+func (s *AdSequence) UnmarshalBinary(data []byte) error {
+	_, err := s.ReadFrom(bytes.NewReader(data))
+	return err
+}
+
+// ReadFrom reads the encoding of MarshalBinary.
+func (s *AdSequence) ReadFrom(r io.Reader) (int64, error) {
+	var u metadata.Unknown
+	n, err := u.ReadFrom(r)
+	if err != nil {
+		return n, err
+	}
+	if u.Code != AdSequenceProtocolID {
+		return n, fmt.Errorf("transport id does not match %s: %s", AdSequenceProtocolID, u.Code)
+	}
+	// Skip the code and length that precede the payload.
+	payload := u.Payload[varint.UvarintSize(uint64(u.Code)):]
+	_, sizeLen, err := varint.FromUvarint(payload)
+	if err != nil {
+		return n, err
+	}
+	payload = payload[sizeLen:]
+	seq, seqLen, err := varint.FromUvarint(payload)
+	if err != nil {
+		return n, fmt.Errorf("invalid advertisement sequence number: %w", err)
+	}
+	nanos, _, err := varint.FromUvarint(payload[seqLen:])
+	if err != nil {
+		return n, fmt.Errorf("invalid advertisement timestamp: %w", err)
+	}
+	s.Seq = seq
+	s.Time = time.Unix(0, int64(nanos)).UTC()
+	return n, nil
+}
+
+// GetAdSequence returns the sequence number and timestamp embedded in the
+// given binary encoded metadata of an advertisement, or nil if it has none.
+// See: WithAdSequence.
+func GetAdSequence(mdBytes []byte) (*AdSequence, error) {
+	md := metadata.Default.WithProtocol(AdSequenceProtocolID, func() metadata.Protocol { return &AdSequence{} }).New()
+	if len(mdBytes) == 0 {
+		return nil, nil
+	}
+	if err := md.UnmarshalBinary(mdBytes); err != nil {
+		return nil, err
+	}
+	seq, _ := md.Get(AdSequenceProtocolID).(*AdSequence)
+	return seq, nil
+}
+
+// addAdSequence embeds the next sequence number and timestamp into the
+// metadata of the given advertisement, which follows the latest
+// advertisement. The caller must hold the publish lock.
+func (e *Engine) addAdSequence(ctx context.Context, adv *schema.Advertisement, prevAdvID cid.Cid) error {
+	next := AdSequence{Seq: 1, Time: time.Now().UTC()}
+	if prevAdvID != cid.Undef {
+		prev, err := e.adSequenceOf(ctx, prevAdvID)
+		if err != nil {
+			return err
+		}
+		next.Seq = prev.Seq + 1
+		if !next.Time.After(prev.Time) {
+			next.Time = prev.Time.Add(time.Nanosecond)
+		}
+	}
+
+	md := e.metadataContext.New()
+	if len(adv.Metadata) != 0 {
+		if err := md.UnmarshalBinary(adv.Metadata); err != nil {
+			return fmt.Errorf("cannot decode metadata: %w", err)
+		}
+	}
+	protocols := []metadata.Protocol{&next}
+	for _, id := range md.Protocols() {
+		if id != AdSequenceProtocolID {
+			protocols = append(protocols, md.Get(id))
+		}
+	}
+	md = e.metadataContext.New(protocols...)
+	mdBytes, err := md.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if len(mdBytes) > metadata.MaxMetadataSize {
+		return fmt.Errorf("metadata with advertisement sequence is longer than %d bytes", metadata.MaxMetadataSize)
+	}
+	adv.Metadata = mdBytes
+	return nil
+}
+
+// adSequenceOf returns the sequence of the advertisement with the given CID.
+// An advertisement without a sequence, e.g. one published before
+// WithAdSequence was set, is numbered by walking the chain back to the latest
+// advertisement with a sequence, or to the start of the chain, or to the
+// pruned horizon, in which case numbering starts from the oldest
+// advertisement kept.
+func (e *Engine) adSequenceOf(ctx context.Context, adCid cid.Cid) (AdSequence, error) {
+	horizon, err := e.getPrunedHorizon(ctx)
+	if err != nil {
+		return AdSequence{}, err
+	}
+	var walked uint64
+	var latest time.Time
+	for c := adCid; c != cid.Undef && c != horizon; walked++ {
+		ad, err := e.loadAd(ctx, c)
+		if err != nil {
+			return AdSequence{}, fmt.Errorf("cannot get sequence of advertisement %s: %w", c, err)
+		}
+		seq, err := GetAdSequence(ad.Metadata)
+		if err != nil {
+			return AdSequence{}, fmt.Errorf("cannot get sequence of advertisement %s: %w", c, err)
+		}
+		if seq != nil {
+			seq.Seq += walked
+			if seq.Time.Before(latest) {
+				seq.Time = latest
+			}
+			return *seq, nil
+		}
+		if walked == 0 {
+			log.Infow("Numbering advertisements published without sequence", "adCid", c)
+			if t, err := e.getAdTime(ctx, c); err == nil {
+				latest = t
+			}
+		}
+		c = ad.PreviousCid()
+	}
+	return AdSequence{Seq: walked, Time: latest}, nil
+}
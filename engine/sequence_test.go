@@ -0,0 +1,102 @@
+package engine_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multicodec"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdSequence_MarshalBinary(t *testing.T) {
+	seq := &engine.AdSequence{Seq: 42, Time: time.Unix(1700000000, 123).UTC()}
+	data, err := seq.MarshalBinary()
+	require.NoError(t, err)
+
+	var decoded engine.AdSequence
+	require.NoError(t, decoded.UnmarshalBinary(data))
+	require.Equal(t, *seq, decoded)
+
+	// Indexers that do not know the protocol decode it as unknown.
+	bitswapMd := metadata.Default.New(metadata.Bitswap{})
+	bitswap, err := bitswapMd.MarshalBinary()
+	require.NoError(t, err)
+	md := metadata.Default.New()
+	require.NoError(t, md.UnmarshalBinary(append(bitswap, data...)))
+	require.Equal(t, []multicodec.Code{multicodec.TransportBitswap, engine.AdSequenceProtocolID}, md.Protocols())
+}
+
+func TestEngine_WithAdSequence(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	lister := func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	}
+	md := metadata.Default.New(metadata.Bitswap{})
+
+	// Advertisements published without sequence are numbered once the option
+	// is set.
+	before, err := engine.New(engine.WithPublisherKind(engine.NoPublisher), engine.WithDatastore(ds))
+	require.NoError(t, err)
+	require.NoError(t, before.Start(ctx))
+	before.RegisterMultihashLister(lister)
+	for _, contextID := range []string{"fish", "lobster"} {
+		adCid, err := before.NotifyPut(ctx, nil, []byte(contextID), md)
+		require.NoError(t, err)
+		ad, err := before.GetAdv(ctx, adCid)
+		require.NoError(t, err)
+		seq, err := engine.GetAdSequence(ad.Metadata)
+		require.NoError(t, err)
+		require.Nil(t, seq)
+	}
+	require.NoError(t, before.Shutdown())
+
+	subject, err := engine.New(engine.WithPublisherKind(engine.NoPublisher), engine.WithDatastore(ds), engine.WithAdSequence(true))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	t.Cleanup(func() { subject.Shutdown() })
+	subject.RegisterMultihashLister(lister)
+
+	var last engine.AdSequence
+	for i, contextID := range []string{"crab", "squid", "fish"} {
+		var adCid cid.Cid
+		if contextID == "fish" {
+			adCid, err = subject.NotifyRemove(ctx, "", []byte(contextID))
+		} else {
+			adCid, err = subject.NotifyPut(ctx, nil, []byte(contextID), md)
+		}
+		require.NoError(t, err)
+		ad, err := subject.GetAdv(ctx, adCid)
+		require.NoError(t, err)
+		_, err = ad.VerifySignature()
+		require.NoError(t, err)
+
+		seq, err := engine.GetAdSequence(ad.Metadata)
+		require.NoError(t, err)
+		require.NotNil(t, seq)
+		require.Equal(t, uint64(i+3), seq.Seq)
+		require.True(t, seq.Time.After(last.Time))
+		last = *seq
+
+		if !ad.IsRm {
+			// The metadata still holds the retrieval protocols.
+			adMd := subject.MetadataContext().New()
+			require.NoError(t, adMd.UnmarshalBinary(ad.Metadata))
+			require.NotNil(t, adMd.Get(multicodec.TransportBitswap))
+		}
+	}
+
+	// Metadata is compared without the sequence when re-advertising.
+	_, err = subject.NotifyPut(ctx, nil, []byte("crab"), md)
+	require.ErrorIs(t, err, provider.ErrAlreadyAdvertised)
+}
@@ -0,0 +1,397 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipni/go-libipni/ingest/schema"
+	"github.com/ipni/go-libipni/metadata"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine/chunker"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/multiformats/go-multihash"
+)
+
+// SplitContextID returns the context ID under which the given part of content
+// that is split across several advertisements is advertised. The first part,
+// i.e. part 0, is advertised under the context ID itself, and each following
+// part under the context ID suffixed with "/part/" and the part number, e.g.
+// "fish/part/1".
+//
+// See: WithMaxAdMultihashes.
+func SplitContextID(contextID []byte, part int) []byte {
+	if part == 0 {
+		return contextID
+	}
+	suffix := "/part/" + strconv.Itoa(part)
+	partID := make([]byte, 0, len(contextID)+len(suffix))
+	partID = append(partID, contextID...)
+	return append(partID, suffix...)
+}
+
+// splitPart locates the multihashes advertised under the context ID of a part
+// of split content among the multihashes listed for the context ID that is
+// split.
+type splitPart struct {
+	// ContextID is the context ID that is split.
+	ContextID []byte `json:"c"`
+	// Offset is the number of listed multihashes that precede the part.
+	Offset int `json:"o"`
+	// Count is the number of multihashes in the part.
+	Count int `json:"n"`
+}
+
+// chunkedPart is a part of split content along with the link to its entries.
+type chunkedPart struct {
+	splitPart
+	// Entries is the link to the entries of the part, or nil if the part has
+	// no multihashes.
+	Entries ipld.Link
+}
+
+// partAd is an advertisement of a part of split content, along with the
+// mappings of its context ID, which are written in batch.
+type partAd struct {
+	adv     schema.Advertisement
+	mhCount int
+	batch   datastore.Batch
+}
+
+// chunkParts chunks the multihashes of the given iterator into parts of at most
+// maxAdMultihashes multihashes each. A single part is returned if the content
+// does not need to be split.
+func (e *Engine) chunkParts(ctx context.Context, entriesChunker chunker.EntriesChunker, contextID []byte, mhIter provider.MultihashIterator) ([]chunkedPart, error) {
+	peeking := &peekingMultihashIterator{MultihashIterator: mhIter}
+	var parts []chunkedPart
+	for offset := 0; ; {
+		partIter := &countingMultihashIterator{
+			MultihashIterator: &limitMultihashIterator{MultihashIterator: peeking, limit: e.maxAdMultihashes},
+		}
+		lnk, err := entriesChunker.Chunk(ctx, partIter)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, chunkedPart{
+			splitPart: splitPart{ContextID: contextID, Offset: offset, Count: partIter.count},
+			Entries:   lnk,
+		})
+		offset += partIter.count
+		if partIter.count < e.maxAdMultihashes {
+			break
+		}
+		more, err := peeking.more()
+		if err != nil {
+			return nil, err
+		}
+		if !more {
+			break
+		}
+	}
+	if len(parts) > 1 {
+		last := SplitContextID(contextID, len(parts)-1)
+		if err := provider.ContextID(last).Validate(); err != nil {
+			return nil, fmt.Errorf("cannot split content of %d multihashes across %d advertisements: %w", partsCount(parts), len(parts), err)
+		}
+		log.Infow("Split content across advertisements", "contextID", provider.ContextID(contextID), "parts", len(parts), "multihashCount", partsCount(parts))
+	}
+	return parts, nil
+}
+
+// partsCount returns the total number of multihashes in the given parts.
+func partsCount(parts []chunkedPart) int {
+	last := parts[len(parts)-1]
+	return last.Offset + last.Count
+}
+
+// putPartAds writes the mappings of the parts of newly split content, other
+// than the first, and returns their advertisements.
+func (e *Engine) putPartAds(ctx context.Context, p peer.ID, addrs []multiaddr.Multiaddr, md metadata.Metadata, parts []chunkedPart) ([]partAd, error) {
+	ads := make([]partAd, 0, len(parts)-1)
+	now := time.Now().UnixNano()
+	for i := 1; i < len(parts); i++ {
+		part := parts[i]
+		partID := SplitContextID(part.ContextID, i)
+		batch, err := e.ds.Batch(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create datastore batch: %w", err)
+		}
+		entries := part.Entries.(cidlink.Link)
+		if err = e.putKeyCidMap(ctx, batch, p, partID, entries.Cid); err != nil {
+			return nil, fmt.Errorf("failed to write provider + context id to entries cid mapping: %s", err)
+		}
+		if err = e.putKeyMetadataMap(ctx, batch, p, partID, &md); err != nil {
+			return nil, fmt.Errorf("failed to write provider + context id to metadata mapping: %s", err)
+		}
+		info := &contextInfo{MultihashCount: part.Count, PublishedAt: now}
+		if err = e.putKeyInfoMap(ctx, batch, p, partID, info); err != nil {
+			return nil, fmt.Errorf("failed to write provider + context id to info mapping: %s", err)
+		}
+		if err = e.putPartMap(ctx, batch, p, partID, &part.splitPart); err != nil {
+			return nil, fmt.Errorf("failed to write provider + context id to part mapping: %s", err)
+		}
+		adv, err := newPartAd(p, addrs, partID, entries, md, false)
+		if err != nil {
+			return nil, err
+		}
+		ads = append(ads, partAd{adv: adv, mhCount: part.Count, batch: batch})
+	}
+	return ads, nil
+}
+
+// updatePartAds writes the new metadata of the parts of split content, other
+// than the first, and returns their advertisements.
+func (e *Engine) updatePartAds(ctx context.Context, p peer.ID, addrs []multiaddr.Multiaddr, contextID []byte, md metadata.Metadata, parts int) ([]partAd, error) {
+	ads := make([]partAd, 0, parts-1)
+	now := time.Now().UnixNano()
+	for i := 1; i < parts; i++ {
+		partID := SplitContextID(contextID, i)
+		c, err := e.getKeyCidMap(ctx, p, partID)
+		if err != nil {
+			if errors.Is(err, datastore.ErrNotFound) {
+				log.Warnw("Part of split content is not advertised", "contextID", provider.ContextID(partID))
+				continue
+			}
+			return nil, fmt.Errorf("could not get entries cid by provider + context id: %s", err)
+		}
+		info, err := e.getKeyInfoMap(ctx, p, partID)
+		if err != nil {
+			return nil, fmt.Errorf("could not get info for provider + context id: %s", err)
+		}
+		batch, err := e.ds.Batch(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create datastore batch: %w", err)
+		}
+		if err = e.putKeyMetadataMap(ctx, batch, p, partID, &md); err != nil {
+			return nil, fmt.Errorf("failed to write provider + context id to metadata mapping: %s", err)
+		}
+		info.PublishedAt = now
+		if err = e.putKeyInfoMap(ctx, batch, p, partID, info); err != nil {
+			return nil, fmt.Errorf("failed to write provider + context id to info mapping: %s", err)
+		}
+		adv, err := newPartAd(p, addrs, partID, cidlink.Link{Cid: c}, md, false)
+		if err != nil {
+			return nil, err
+		}
+		ads = append(ads, partAd{adv: adv, mhCount: info.MultihashCount, batch: batch})
+	}
+	return ads, nil
+}
+
+// removePartAds deletes the mappings of the parts of split content, other than
+// the first, and returns their removal advertisements along with the change
+// in the number of advertised multihashes.
+func (e *Engine) removePartAds(ctx context.Context, p peer.ID, contextID []byte, parts int) ([]partAd, int64, error) {
+	ads := make([]partAd, 0, parts-1)
+	var mhDelta int64
+	for i := 1; i < parts; i++ {
+		partID := SplitContextID(contextID, i)
+		c, err := e.getKeyCidMap(ctx, p, partID)
+		if err != nil {
+			if errors.Is(err, datastore.ErrNotFound) {
+				log.Warnw("Part of split content is not advertised", "contextID", provider.ContextID(partID))
+				continue
+			}
+			return nil, 0, fmt.Errorf("could not get entries cid by provider + context id: %s", err)
+		}
+		info, err := e.getKeyInfoMap(ctx, p, partID)
+		if err != nil {
+			return nil, 0, fmt.Errorf("could not get info for provider + context id: %s", err)
+		}
+		batch, err := e.ds.Batch(ctx)
+		if err != nil {
+			return nil, 0, fmt.Errorf("cannot create datastore batch: %w", err)
+		}
+		if err = e.deleteKeyCidMap(ctx, batch, p, partID); err != nil {
+			return nil, 0, fmt.Errorf("failed to delete provider + context id to entries cid mapping: %s", err)
+		}
+		if err = e.releaseEntries(ctx, batch, p, partID, c); err != nil {
+			return nil, 0, fmt.Errorf("failed to delete entries cid to provider + context id mapping: %s", err)
+		}
+		if err = e.deleteKeyMetadataMap(ctx, batch, p, partID); err != nil {
+			return nil, 0, fmt.Errorf("failed to delete provider + context id to metadata mapping: %s", err)
+		}
+		if err = e.deleteKeyInfoMap(ctx, batch, p, partID); err != nil {
+			return nil, 0, fmt.Errorf("failed to delete provider + context id to info mapping: %s", err)
+		}
+		if err = e.deletePartMap(ctx, batch, p, partID); err != nil {
+			return nil, 0, fmt.Errorf("failed to delete provider + context id to part mapping: %s", err)
+		}
+		if info.MultihashCount > 0 {
+			mhDelta -= int64(info.MultihashCount)
+		}
+		adv, err := newPartAd(p, nil, partID, schema.NoEntries, e.metadataContext.New(), true)
+		if err != nil {
+			return nil, 0, err
+		}
+		ads = append(ads, partAd{adv: adv, mhCount: -1, batch: batch})
+	}
+	return ads, mhDelta, nil
+}
+
+func newPartAd(p peer.ID, addrs []multiaddr.Multiaddr, contextID []byte, entries ipld.Link, md metadata.Metadata, isRm bool) (schema.Advertisement, error) {
+	mdBytes, err := md.MarshalBinary()
+	if err != nil {
+		return schema.Advertisement{}, err
+	}
+	var stringAddrs []string
+	for _, addr := range addrs {
+		stringAddrs = append(stringAddrs, addr.String())
+	}
+	return schema.Advertisement{
+		Provider:  p.String(),
+		Addresses: stringAddrs,
+		Entries:   entries,
+		ContextID: contextID,
+		Metadata:  mdBytes,
+		IsRm:      isRm,
+	}, nil
+}
+
+// publishWithParts publishes the given advertisement, which is already linked
+// and signed, followed by the advertisements of the other parts of its
+// content, if any, and returns the CID of the last advertisement published.
+// Only the last advertisement is announced, and reports the multihashes
+// skipped when listing the content. The caller must hold publishLock, which is
+// released by calling unlock. See: Engine.publish.
+func (e *Engine) publishWithParts(ctx context.Context, adv schema.Advertisement, mhCount, skipped int, parts []partAd, batch datastore.Batch, unlock func()) (cid.Cid, error) {
+	if len(parts) == 0 {
+		return e.publish(ctx, adv, mhCount, skipped, batch, unlock)
+	}
+	defer unlock()
+	c, err := e.publishLocal(ctx, adv, 0, batch)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("failed to publish advertisement locally: %w", err)
+	}
+	e.audit(ctx, c, &adv, mhCount, 0, nil)
+	for i, part := range parts {
+		if err = e.linkAndSign(ctx, &part.adv); err != nil {
+			return cid.Undef, err
+		}
+		if i == len(parts)-1 {
+			return e.publish(ctx, part.adv, part.mhCount, skipped, part.batch, unlock)
+		}
+		if c, err = e.publishLocal(ctx, part.adv, 0, part.batch); err != nil {
+			return cid.Undef, fmt.Errorf("failed to publish advertisement of part %d locally: %w", i+1, err)
+		}
+		e.audit(ctx, c, &part.adv, part.mhCount, 0, nil)
+	}
+	return c, nil
+}
+
+func (e *Engine) keyToPartKey(provider peer.ID, contextID []byte) datastore.Key {
+	if provider == e.mappingsID {
+		return datastore.NewKey(keyToPartMapPrefix + string(contextID))
+	}
+	return datastore.NewKey(keyToPartMapPrefix + provider.String() + "/" + string(contextID))
+}
+
+func (e *Engine) putPartMap(ctx context.Context, w datastore.Write, provider peer.ID, contextID []byte, part *splitPart) error {
+	data, err := json.Marshal(part)
+	if err != nil {
+		return err
+	}
+	return w.Put(ctx, e.keyToPartKey(provider, contextID), data)
+}
+
+// getPartMap returns the part of split content advertised under the given
+// provider and context ID, or nil if the context ID does not advertise part of
+// split content.
+func (e *Engine) getPartMap(ctx context.Context, provider peer.ID, contextID []byte) (*splitPart, error) {
+	data, err := e.ds.Get(ctx, e.keyToPartKey(provider, contextID))
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var part splitPart
+	if err = json.Unmarshal(data, &part); err != nil {
+		return nil, err
+	}
+	return &part, nil
+}
+
+func (e *Engine) deletePartMap(ctx context.Context, w datastore.Write, provider peer.ID, contextID []byte) error {
+	return w.Delete(ctx, e.keyToPartKey(provider, contextID))
+}
+
+// isDerivedPart returns whether the given context ID advertises a part of the
+// split content of another context ID.
+func (e *Engine) isDerivedPart(ctx context.Context, provider peer.ID, contextID []byte) (bool, error) {
+	part, err := e.getPartMap(ctx, provider, contextID)
+	if err != nil {
+		return false, err
+	}
+	return part != nil && !bytes.Equal(part.ContextID, contextID), nil
+}
+
+// limitMultihashIterator skips the first skip multihashes of the wrapped
+// iterator, and returns at most limit of the rest.
+type limitMultihashIterator struct {
+	provider.MultihashIterator
+	skip  int
+	limit int
+}
+
+func (i *limitMultihashIterator) Next() (multihash.Multihash, error) {
+	for ; i.skip > 0; i.skip-- {
+		if _, err := i.MultihashIterator.Next(); err != nil {
+			return nil, err
+		}
+	}
+	if i.limit <= 0 {
+		return nil, io.EOF
+	}
+	i.limit--
+	return i.MultihashIterator.Next()
+}
+
+func (i *limitMultihashIterator) Close() error {
+	if closer, ok := i.MultihashIterator.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// peekingMultihashIterator allows checking whether the wrapped iterator has
+// more multihashes without losing the next one.
+type peekingMultihashIterator struct {
+	provider.MultihashIterator
+	next multihash.Multihash
+}
+
+func (i *peekingMultihashIterator) Next() (multihash.Multihash, error) {
+	if i.next != nil {
+		mh := i.next
+		i.next = nil
+		return mh, nil
+	}
+	return i.MultihashIterator.Next()
+}
+
+// more returns whether the iterator has more multihashes.
+func (i *peekingMultihashIterator) more() (bool, error) {
+	if i.next != nil {
+		return true, nil
+	}
+	mh, err := i.MultihashIterator.Next()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		return false, err
+	}
+	i.next = mh
+	return true, nil
+}
@@ -0,0 +1,113 @@
+package engine_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipni/go-libipni/ingest/schema"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_SplitsAdsOverMaxMultihashes(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	_, err := engine.New(engine.WithMaxAdMultihashes(-1))
+	require.Error(t, err)
+
+	// Lazy entries are regenerated from the multihashes of each part when
+	// loaded.
+	subject, err := engine.New(engine.WithPublisherKind(engine.NoPublisher), engine.WithMaxAdMultihashes(10), engine.WithLazyEntries(true))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	t.Cleanup(func() { subject.Shutdown() })
+	mhs := map[string][]multihash.Multihash{
+		"fish":    test.RandomMultihashes(25),
+		"lobster": test.RandomMultihashes(10),
+	}
+	subject.RegisterMultihashLister(func(_ context.Context, _ peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(mhs[string(contextID)]), nil
+	})
+
+	md := metadata.Default.New(metadata.Bitswap{})
+	headCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.NoError(t, err)
+
+	// The parts are published in order, each advertising its part of the
+	// listed multihashes.
+	var got []multihash.Multihash
+	var ads []*schema.Advertisement
+	for adCid := headCid; len(ads) < 3; {
+		ad, err := subject.GetAdv(ctx, adCid)
+		require.NoError(t, err)
+		ads = append([]*schema.Advertisement{ad}, ads...)
+		adCid = ad.PreviousCid()
+	}
+	for i, ad := range ads {
+		require.Equal(t, engine.SplitContextID([]byte("fish"), i), ad.ContextID)
+		chunk := requireLoadEntryChunkFromEngine(t, subject, ad.Entries)[0]
+		require.LessOrEqual(t, len(chunk.Entries), 10)
+		got = append(got, chunk.Entries...)
+	}
+	require.Equal(t, []byte("fish/part/2"), ads[2].ContextID)
+	require.Equal(t, mhs["fish"], got)
+
+	info, err := subject.GetContextInfo(ctx, "", []byte("fish"))
+	require.NoError(t, err)
+	require.Equal(t, 3, info.Parts)
+	require.Equal(t, 10, info.MultihashCount)
+	stats, err := subject.Stats(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, 25, stats.Multihashes)
+
+	// Content of exactly the maximum number of multihashes is not split.
+	_, err = subject.NotifyPut(ctx, nil, []byte("lobster"), md)
+	require.NoError(t, err)
+	info, err = subject.GetContextInfo(ctx, "", []byte("lobster"))
+	require.NoError(t, err)
+	require.Equal(t, 1, info.Parts)
+
+	contextIDs, err := subject.ListContextIDs(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("fish"), []byte("lobster")}, contextIDs)
+
+	// Parts cannot be updated or removed on their own.
+	_, err = subject.NotifyRemove(ctx, "", []byte("fish/part/1"))
+	require.ErrorContains(t, err, "part of split content")
+
+	// Updating the metadata republishes every part.
+	updatedCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.IpfsGatewayHttp{}))
+	require.NoError(t, err)
+	ad, err := subject.GetAdv(ctx, updatedCid)
+	require.NoError(t, err)
+	require.Equal(t, []byte("fish/part/2"), ad.ContextID)
+	require.Equal(t, ads[2].Entries, ad.Entries)
+
+	// Removing the content removes every part.
+	rmCid, err := subject.NotifyRemove(ctx, "", []byte("fish"))
+	require.NoError(t, err)
+	var removed [][]byte
+	for adCid := rmCid; len(removed) < 3; {
+		ad, err := subject.GetAdv(ctx, adCid)
+		require.NoError(t, err)
+		require.True(t, ad.IsRm)
+		removed = append([][]byte{ad.ContextID}, removed...)
+		adCid = ad.PreviousCid()
+	}
+	require.Equal(t, [][]byte{[]byte("fish"), []byte("fish/part/1"), []byte("fish/part/2")}, removed)
+	_, err = subject.GetContextInfo(ctx, "", []byte("fish/part/1"))
+	require.ErrorIs(t, err, provider.ErrContextIDNotFound)
+	stats, err = subject.Stats(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, 10, stats.Multihashes)
+
+	// Content that is removed can be advertised again.
+	_, err = subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.NoError(t, err)
+}
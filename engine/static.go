@@ -0,0 +1,174 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	carv2 "github.com/ipld/go-car/v2"
+	carstorage "github.com/ipld/go-car/v2/storage"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	headschema "github.com/ipni/go-libipni/dagsync/ipnisync/head"
+	"github.com/ipni/go-libipni/ingest/schema"
+)
+
+const (
+	// StaticHeadFile is the name of the file in the static CAR directory that
+	// holds the signed head of the advertisement chain. See: WithStaticCarDir.
+	StaticHeadFile = "head"
+	// staticCarExt is the extension of the CAR file of each advertisement in
+	// the static CAR directory.
+	staticCarExt = ".car"
+)
+
+// StaticCarPath returns the path of the CAR file of the advertisement with the
+// given CID in the given static CAR directory. See: WithStaticCarDir.
+func StaticCarPath(dir string, adCid cid.Cid) string {
+	return filepath.Join(dir, adCid.String()+staticCarExt)
+}
+
+// writeStatic writes the CAR files of the advertisements published since the
+// static CAR directory was last written to, if any, and updates the head file.
+// Errors are logged rather than returned, since the advertisement is published
+// regardless, and the directory catches up on the next publish.
+func (e *Engine) writeStatic(ctx context.Context) {
+	if e.staticCarDir == "" {
+		return
+	}
+	if err := e.syncStatic(ctx); err != nil {
+		log.Errorw("Failed to write advertisements to static CAR directory", "dir", e.staticCarDir, "err", err)
+	}
+}
+
+// syncStatic writes the CAR files of the advertisements missing from the
+// static CAR directory, from the latest advertisement back to the first one
+// that is present, and updates the head file to the latest advertisement. This
+// catches up on the advertisements that failed to be written, or that were
+// published while the directory was not configured.
+func (e *Engine) syncStatic(ctx context.Context) error {
+	latest, err := e.getLatestAdCid(ctx)
+	if err != nil || latest == cid.Undef {
+		return err
+	}
+	horizon, err := e.getPrunedHorizon(ctx)
+	if err != nil {
+		return err
+	}
+	lsys := e.vanillaLinkSystem()
+	var written int
+	for c := latest; c != cid.Undef && c != horizon; {
+		if _, err = os.Stat(StaticCarPath(e.staticCarDir, c)); err == nil {
+			break
+		}
+		if err = e.writeStaticCar(ctx, c); err != nil {
+			return err
+		}
+		written++
+		n, err := lsys.Load(ipld.LinkContext{Ctx: ctx}, cidlink.Link{Cid: c}, schema.AdvertisementPrototype)
+		if err != nil {
+			return fmt.Errorf("cannot load advertisement %s: %w", c, err)
+		}
+		ad, err := schema.UnwrapAdvertisement(n)
+		if err != nil {
+			return fmt.Errorf("invalid advertisement %s: %w", c, err)
+		}
+		c = cid.Undef
+		if ad.PreviousID != nil {
+			c = ad.PreviousID.(cidlink.Link).Cid
+		}
+	}
+	if written != 0 {
+		log.Infow("Wrote missing advertisements to static CAR directory", "count", written, "dir", e.staticCarDir)
+	}
+	return e.writeStaticHead(latest)
+}
+
+// writeStaticCar writes the advertisement with the given CID and its entries
+// to a CAR file with the advertisement as root, unless the file exists. The
+// file is written to a temporary file first, and then renamed, so that it is
+// never served partially written.
+func (e *Engine) writeStaticCar(ctx context.Context, adCid cid.Cid) error {
+	path := StaticCarPath(e.staticCarDir, adCid)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	data, err := e.ds.Get(ctx, datastore.NewKey(adCid.String()))
+	if err != nil {
+		return fmt.Errorf("cannot get advertisement %s: %w", adCid, err)
+	}
+	ad, err := schema.BytesToAdvertisement(adCid, data)
+	if err != nil {
+		return fmt.Errorf("invalid advertisement %s: %w", adCid, err)
+	}
+
+	return writeFileAtomic(path, func(f *os.File) error {
+		out, err := carstorage.NewWritable(f, []cid.Cid{adCid}, carv2.WriteAsCarV1(true))
+		if err != nil {
+			return err
+		}
+		put := func(c cid.Cid, data []byte) error {
+			has, err := out.Has(ctx, c.KeyString())
+			if err != nil || has {
+				return err
+			}
+			return out.Put(ctx, c.KeyString(), data)
+		}
+		if err = put(adCid, data); err != nil {
+			return err
+		}
+		if ad.Entries != nil && ad.Entries != schema.NoEntries {
+			if err = walkAll(ctx, e.copyingLinkSystem(put), ad.Entries); err != nil {
+				return fmt.Errorf("cannot write entries: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// writeStaticHead writes the head file, which holds the given advertisement
+// CID signed with the key of the engine in the format served by IPNI HTTP
+// publishers at /ipni/v1/ad/head.
+func (e *Engine) writeStaticHead(adCid cid.Cid) error {
+	_, key := e.identity()
+	signedHead, err := headschema.NewSignedHead(adCid, e.pubTopicName, key)
+	if err != nil {
+		return fmt.Errorf("cannot sign head: %w", err)
+	}
+	data, err := signedHead.Encode()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(e.staticCarDir, StaticHeadFile), func(f *os.File) error {
+		_, err := f.Write(data)
+		return err
+	})
+}
+
+// writeFileAtomic writes the file at the given path by calling write with a
+// temporary file in the same directory, which is then renamed to the path.
+func writeFileAtomic(path string, write func(*os.File) error) (err error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}()
+	if err = write(f); err != nil {
+		return err
+	}
+	if err = f.Chmod(0644); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
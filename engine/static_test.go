@@ -0,0 +1,95 @@
+package engine_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	carv2 "github.com/ipld/go-car/v2"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	headschema "github.com/ipni/go-libipni/dagsync/ipnisync/head"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_StaticCarDir(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	key, _, err := crypto.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+	id, err := peer.IDFromPrivateKey(key)
+	require.NoError(t, err)
+	start := func(dir string) *engine.Engine {
+		subject, err := engine.New(
+			engine.WithDatastore(ds),
+			engine.WithPrivateKey(key),
+			engine.WithProvider(peer.AddrInfo{ID: id, Addrs: test.RandomMultiaddrs(1)}),
+			engine.WithPublisherKind(engine.NoPublisher),
+			engine.WithStaticCarDir(dir))
+		require.NoError(t, err)
+		require.NoError(t, subject.Start(ctx))
+		subject.RegisterMultihashLister(func(_ context.Context, _ peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+			return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+		})
+		return subject
+	}
+	requireCar := func(dir string, adCid cid.Cid) {
+		f, err := os.Open(engine.StaticCarPath(dir, adCid))
+		require.NoError(t, err)
+		defer f.Close()
+		br, err := carv2.NewBlockReader(f)
+		require.NoError(t, err)
+		require.Equal(t, []cid.Cid{adCid}, br.Roots)
+		var blocks int
+		for _, err = br.Next(); err == nil; _, err = br.Next() {
+			blocks++
+		}
+		// The advertisement and its single entries chunk.
+		require.Equal(t, 2, blocks)
+	}
+	requireHead := func(dir string, adCid cid.Cid) {
+		f, err := os.Open(filepath.Join(dir, engine.StaticHeadFile))
+		require.NoError(t, err)
+		defer f.Close()
+		head, err := headschema.Decode(f)
+		require.NoError(t, err)
+		signer, err := head.Validate()
+		require.NoError(t, err)
+		require.Equal(t, id, signer)
+		require.Equal(t, adCid, head.Head.(cidlink.Link).Cid)
+	}
+
+	dir := filepath.Join(t.TempDir(), "static")
+	subject := start(dir)
+	md := metadata.Default.New(metadata.Bitswap{})
+	var adCids []cid.Cid
+	for _, contextID := range []string{"fish", "lobster"} {
+		adCid, err := subject.NotifyPut(ctx, nil, []byte(contextID), md)
+		require.NoError(t, err)
+		adCids = append(adCids, adCid)
+		requireCar(dir, adCid)
+		requireHead(dir, adCid)
+	}
+	require.NoError(t, subject.Shutdown())
+
+	// Advertisements missing from the directory are written on next publish.
+	dir = filepath.Join(t.TempDir(), "static")
+	subject = start(dir)
+	t.Cleanup(func() { subject.Shutdown() })
+	adCid, err := subject.NotifyPut(ctx, nil, []byte("crab"), md)
+	require.NoError(t, err)
+	for _, c := range append(adCids, adCid) {
+		requireCar(dir, c)
+	}
+	requireHead(dir, adCid)
+}
@@ -0,0 +1,322 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipni/go-libipni/ingest/schema"
+	"github.com/ipni/index-provider/metrics"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Stats holds statistics about the engine.
+type Stats struct {
+	// Head is the CID of the latest advertisement, or cid.Undef if no
+	// advertisements have been published.
+	Head cid.Cid
+	// ChainLength is the number of advertisements in the chain ending at Head.
+	ChainLength int
+	// Contexts is the number of context IDs currently advertised.
+	Contexts int
+	// Multihashes is the number of multihashes currently advertised across
+	// all context IDs, excluding those whose multihash count is unknown. See:
+	// ContextInfo.MultihashCount.
+	Multihashes int64
+	// CachedChunks is the number of entries chunk chains in the entries cache,
+	// and CacheCapacity is the maximum number of chains it holds.
+	CachedChunks  int
+	CacheCapacity int
+	// AnnounceSuccesses and AnnounceFailures count the announcements sent, and
+	// that failed to be sent, since the engine started.
+	AnnounceSuccesses uint64
+	AnnounceFailures  uint64
+	// LastPublished is the time at which an advertisement was last published
+	// since the engine started, or the zero time if none were published.
+	LastPublished time.Time
+	// AnnounceTargets holds the statistics of announcing to each target since
+	// the engine started, keyed by target as in AnnounceResult.Targets: the
+	// URL of each indexer that direct HTTP announce messages are sent to, or
+	// the gossip pubsub topic prefixed with "pubsub:".
+	AnnounceTargets map[string]AnnounceTargetStats
+}
+
+// AnnounceTargetStats holds statistics about the announcements sent to a
+// target, so that a single broken target can be told apart among several.
+type AnnounceTargetStats struct {
+	// Attempts counts the announcements sent to the target, including those
+	// still being sent, and Successes and Failures those that were sent and
+	// that failed to be sent.
+	Attempts  uint64
+	Successes uint64
+	Failures  uint64
+	// MeanLatency is the mean time taken to send an announcement to the
+	// target, and LastLatency the time taken by the last one.
+	MeanLatency time.Duration
+	LastLatency time.Duration
+	// LastAttempt is when an announcement was last sent to the target.
+	LastAttempt time.Time
+	// LastError is the error with which sending the last failed announcement
+	// failed, if any.
+	LastError string
+}
+
+// engineStats tracks the statistics of the engine that are not derived from
+// the datastore.
+type engineStats struct {
+	announceSuccesses atomic.Uint64
+	announceFailures  atomic.Uint64
+	lastPublished     atomic.Int64
+	// multihashes is the number of multihashes currently advertised, kept up
+	// to date as context IDs are advertised and removed.
+	multihashes atomic.Int64
+
+	// inFlightAnnounces holds the start time of announcements that are being
+	// sent, keyed by a sequence number, used to detect stuck announcements.
+	inFlightMutex     sync.Mutex
+	inFlightSeq       uint64
+	inFlightAnnounces map[uint64]time.Time
+
+	// targets holds the statistics of each announce target, keyed by target.
+	targetsMutex sync.Mutex
+	targets      map[string]*targetStats
+
+	// chainLenHead is the head of the chain whose length was last counted,
+	// so that only newer advertisements are counted subsequently.
+	chainLenMutex sync.Mutex
+	chainLenHead  cid.Cid
+	chainLen      int
+}
+
+// announceStarted records the start of an announcement, and returns the ID
+// that must be passed to announced once it completes.
+func (s *engineStats) announceStarted() uint64 {
+	s.inFlightMutex.Lock()
+	defer s.inFlightMutex.Unlock()
+	if s.inFlightAnnounces == nil {
+		s.inFlightAnnounces = make(map[uint64]time.Time)
+	}
+	s.inFlightSeq++
+	s.inFlightAnnounces[s.inFlightSeq] = time.Now()
+	return s.inFlightSeq
+}
+
+// oldestAnnounce returns the start time of the oldest announcement that is
+// still being sent, or the zero time if none are.
+func (s *engineStats) oldestAnnounce() time.Time {
+	s.inFlightMutex.Lock()
+	defer s.inFlightMutex.Unlock()
+	var oldest time.Time
+	for _, started := range s.inFlightAnnounces {
+		if oldest.IsZero() || started.Before(oldest) {
+			oldest = started
+		}
+	}
+	return oldest
+}
+
+// multihashesChanged records a change in the number of multihashes currently
+// advertised, e.g. a negative count when a context ID is removed.
+func (s *engineStats) multihashesChanged(ctx context.Context, count int64) {
+	s.multihashes.Add(count)
+	metrics.Engine.Multihashes.Add(ctx, count)
+}
+
+// pending returns the number of announcements that are being sent.
+func (s *engineStats) pending() int {
+	s.inFlightMutex.Lock()
+	defer s.inFlightMutex.Unlock()
+	return len(s.inFlightAnnounces)
+}
+
+func (s *engineStats) announced(ctx context.Context, id uint64, err error) {
+	s.inFlightMutex.Lock()
+	delete(s.inFlightAnnounces, id)
+	s.inFlightMutex.Unlock()
+
+	attr := metrics.Attributes.StatusSuccess
+	if err != nil {
+		s.announceFailures.Add(1)
+		attr = metrics.Attributes.StatusFailure
+	} else {
+		s.announceSuccesses.Add(1)
+	}
+	metrics.Engine.Announces.Add(ctx, 1, metric.WithAttributeSet(attribute.NewSet(attr)))
+}
+
+// targetStats tracks the statistics of an announce target.
+type targetStats struct {
+	AnnounceTargetStats
+	totalLatency time.Duration
+}
+
+// targetStarted records the start of sending an announcement to the given
+// target.
+func (s *engineStats) targetStarted(target string) {
+	s.targetsMutex.Lock()
+	defer s.targetsMutex.Unlock()
+	if s.targets == nil {
+		s.targets = make(map[string]*targetStats)
+	}
+	ts, ok := s.targets[target]
+	if !ok {
+		ts = &targetStats{}
+		s.targets[target] = ts
+	}
+	ts.Attempts++
+	ts.LastAttempt = time.Now()
+}
+
+// targetAnnounced records the outcome of sending an announcement to the given
+// target, which took the given time.
+func (s *engineStats) targetAnnounced(ctx context.Context, target string, latency time.Duration, err error) {
+	s.targetsMutex.Lock()
+	ts := s.targets[target]
+	if err != nil {
+		ts.Failures++
+		ts.LastError = err.Error()
+	} else {
+		ts.Successes++
+	}
+	ts.LastLatency = latency
+	ts.totalLatency += latency
+	s.targetsMutex.Unlock()
+
+	targetAttr := attribute.String("target", target)
+	status := metrics.Attributes.StatusSuccess
+	if err != nil {
+		status = metrics.Attributes.StatusFailure
+	}
+	metrics.Engine.TargetAnnounces.Add(ctx, 1, metric.WithAttributes(targetAttr, status))
+	metrics.Engine.TargetAnnounceDuration.Record(ctx, latency.Milliseconds(), metric.WithAttributes(targetAttr))
+}
+
+// announceTargets returns a copy of the statistics of each announce target.
+func (s *engineStats) announceTargets() map[string]AnnounceTargetStats {
+	s.targetsMutex.Lock()
+	defer s.targetsMutex.Unlock()
+	if len(s.targets) == 0 {
+		return nil
+	}
+	targets := make(map[string]AnnounceTargetStats, len(s.targets))
+	for target, ts := range s.targets {
+		stats := ts.AnnounceTargetStats
+		if done := ts.Successes + ts.Failures; done != 0 {
+			stats.MeanLatency = ts.totalLatency / time.Duration(done)
+		}
+		targets[target] = stats
+	}
+	return targets
+}
+
+// Stats returns statistics about the engine. The length of the advertisement
+// chain is counted by walking it, which is done in full only once.
+func (e *Engine) Stats(ctx context.Context) (*Stats, error) {
+	head, err := e.getLatestAdCid(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not get latest advertisement: %w", err)
+	}
+	chainLen, err := e.chainLength(ctx, head)
+	if err != nil {
+		return nil, err
+	}
+	contextIDs, err := e.ListContextIDs(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{
+		Head:              head,
+		ChainLength:       chainLen,
+		Contexts:          len(contextIDs),
+		Multihashes:       e.stats.multihashes.Load(),
+		AnnounceSuccesses: e.stats.announceSuccesses.Load(),
+		AnnounceFailures:  e.stats.announceFailures.Load(),
+		AnnounceTargets:   e.stats.announceTargets(),
+	}
+	if e.entriesChunker != nil {
+		stats.CachedChunks = e.entriesChunker.Len()
+		stats.CacheCapacity = e.entriesChunker.Cap()
+	}
+	if t := e.stats.lastPublished.Load(); t != 0 {
+		stats.LastPublished = time.Unix(0, t)
+	}
+	return stats, nil
+}
+
+// chainLength counts the advertisements in the chain ending at head, down to
+// the pruned horizon.
+func (e *Engine) chainLength(ctx context.Context, head cid.Cid) (int, error) {
+	e.stats.chainLenMutex.Lock()
+	defer e.stats.chainLenMutex.Unlock()
+
+	horizon, err := e.getPrunedHorizon(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	lsys := e.vanillaLinkSystem()
+	for c := head; c != cid.Undef && c != horizon; {
+		if c == e.stats.chainLenHead {
+			count += e.stats.chainLen
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		n, err := lsys.Load(ipld.LinkContext{Ctx: ctx}, cidlink.Link{Cid: c}, schema.AdvertisementPrototype)
+		if err != nil {
+			return 0, fmt.Errorf("cannot load advertisement %s: %w", c, err)
+		}
+		ad, err := schema.UnwrapAdvertisement(n)
+		if err != nil {
+			return 0, fmt.Errorf("invalid advertisement %s: %w", c, err)
+		}
+		count++
+		c = ad.PreviousCid()
+	}
+	e.stats.chainLenHead = head
+	e.stats.chainLen = count
+	return count, nil
+}
+
+// PendingAnnounces returns the number of announcements that are being sent.
+// Since advertisements are announced as they are published, announcements
+// pile up when publishing outpaces announcing, e.g. while indexers are slow
+// to respond.
+func (e *Engine) PendingAnnounces() int {
+	return e.stats.pending()
+}
+
+// loadMultihashCount counts the multihashes currently advertised from the info
+// stored about each context ID, such that they need not be listed again.
+func (e *Engine) loadMultihashCount(ctx context.Context) error {
+	results, err := e.ds.Query(ctx, query.Query{Prefix: keyToInfoMapPrefix})
+	if err != nil {
+		return err
+	}
+	defer results.Close()
+	var total int64
+	for r := range results.Next() {
+		if r.Error != nil {
+			return r.Error
+		}
+		var info contextInfo
+		if err = json.Unmarshal(r.Value, &info); err != nil {
+			return fmt.Errorf("cannot decode context info %s: %w", r.Key, err)
+		}
+		if info.MultihashCount > 0 {
+			total += int64(info.MultihashCount)
+		}
+	}
+	e.stats.multihashesChanged(ctx, total)
+	return nil
+}
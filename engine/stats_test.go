@@ -0,0 +1,161 @@
+package engine_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipni/go-libipni/announce/httpsender"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine_Stats(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	var failAnnounce atomic.Bool
+	indexer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failAnnounce.Load() {
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer indexer.Close()
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	newEngine := func() *engine.Engine {
+		subject, err := engine.New(
+			engine.WithDatastore(ds),
+			engine.WithPublisherKind(engine.HttpPublisher),
+			engine.WithHttpPublisherWithoutServer(),
+			engine.WithHttpPublisherAnnounceAddr("/ip4/127.0.0.1/tcp/3104/http"),
+			engine.WithPubsubAnnounce(false),
+			engine.WithDirectAnnounce(indexer.URL))
+		require.NoError(t, err)
+		require.NoError(t, subject.Start(ctx))
+		subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+			return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+		})
+		return subject
+	}
+	subject := newEngine()
+
+	stats, err := subject.Stats(ctx)
+	require.NoError(t, err)
+	require.Equal(t, cid.Undef, stats.Head)
+	require.Zero(t, stats.ChainLength)
+	require.Zero(t, stats.Multihashes)
+	require.True(t, stats.LastPublished.IsZero())
+
+	before := time.Now()
+	md := metadata.Default.New(metadata.Bitswap{})
+	_, err = subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.NoError(t, err)
+	_, err = subject.NotifyPut(ctx, nil, []byte("lobster"), md)
+	require.NoError(t, err)
+	stats, err = subject.Stats(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, stats.ChainLength)
+	require.Equal(t, 2, stats.Contexts)
+	require.Equal(t, int64(6), stats.Multihashes)
+	require.Equal(t, uint64(2), stats.AnnounceSuccesses)
+	require.Zero(t, stats.AnnounceFailures)
+	require.False(t, stats.LastPublished.Before(before))
+	require.NotZero(t, stats.CacheCapacity)
+
+	failAnnounce.Store(true)
+	head, err := subject.NotifyRemove(ctx, "", []byte("fish"))
+	require.NoError(t, err)
+	stats, err = subject.Stats(ctx)
+	require.NoError(t, err)
+	require.Equal(t, head, stats.Head)
+	require.Equal(t, 3, stats.ChainLength)
+	require.Equal(t, 1, stats.Contexts)
+	require.Equal(t, int64(3), stats.Multihashes)
+	require.Equal(t, uint64(2), stats.AnnounceSuccesses)
+	require.Equal(t, uint64(1), stats.AnnounceFailures)
+
+	// Updating the metadata of a context ID does not change its multihashes.
+	_, err = subject.NotifyPut(ctx, nil, []byte("lobster"), metadata.Default.New(metadata.IpfsGatewayHttp{}))
+	require.NoError(t, err)
+	stats, err = subject.Stats(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), stats.Multihashes)
+
+	// The multihashes advertised are counted once the engine restarts.
+	require.NoError(t, subject.Shutdown())
+	subject = newEngine()
+	defer subject.Shutdown()
+	stats, err = subject.Stats(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), stats.Multihashes)
+}
+
+func TestEngine_StatsAnnounceTargets(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	working := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer working.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "", http.StatusBadGateway)
+	}))
+	defer broken.Close()
+
+	subject, err := engine.New(
+		engine.WithPublisherKind(engine.HttpPublisher),
+		engine.WithHttpPublisherWithoutServer(),
+		engine.WithHttpPublisherAnnounceAddr("/ip4/127.0.0.1/tcp/3104/http"),
+		engine.WithPubsubAnnounce(false),
+		engine.WithDirectAnnounce(working.URL, broken.URL),
+		engine.WithAnnounceSender(&recordingSender{}))
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	defer subject.Shutdown()
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+
+	stats, err := subject.Stats(ctx)
+	require.NoError(t, err)
+	require.Empty(t, stats.AnnounceTargets)
+
+	md := metadata.Default.New(metadata.Bitswap{})
+	_, err = subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.NoError(t, err)
+	_, err = subject.NotifyPut(ctx, nil, []byte("lobster"), md)
+	require.NoError(t, err)
+	stats, err = subject.Stats(ctx)
+	require.NoError(t, err)
+	require.Len(t, stats.AnnounceTargets, 3)
+
+	workingStats := stats.AnnounceTargets[working.URL+httpsender.DefaultAnnouncePath]
+	require.Equal(t, uint64(2), workingStats.Attempts)
+	require.Equal(t, uint64(2), workingStats.Successes)
+	require.Zero(t, workingStats.Failures)
+	require.Empty(t, workingStats.LastError)
+	require.NotZero(t, workingStats.MeanLatency)
+	require.False(t, workingStats.LastAttempt.IsZero())
+
+	brokenStats := stats.AnnounceTargets[broken.URL+httpsender.DefaultAnnouncePath]
+	require.Equal(t, uint64(2), brokenStats.Attempts)
+	require.Zero(t, brokenStats.Successes)
+	require.Equal(t, uint64(2), brokenStats.Failures)
+	require.Equal(t, "502 Bad Gateway", brokenStats.LastError)
+
+	require.Equal(t, uint64(2), stats.AnnounceTargets["queue:fish"].Successes)
+}
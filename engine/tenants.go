@@ -0,0 +1,354 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/ipfs/go-datastore"
+	dsn "github.com/ipfs/go-datastore/namespace"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/ipni/go-libipni/maurl"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+const (
+	tenantsRegistryPrefix = "/tenants/registry/"
+	tenantsDataPrefix     = "/tenants/data/"
+	// TenantsHandlerPath is the path under which the advertisements of each
+	// tenant are served by Tenants, followed by the peer ID of the tenant.
+	TenantsHandlerPath = "/tenants/"
+)
+
+var (
+	// ErrUnknownTenant signals that there is no tenant with a given ID.
+	ErrUnknownTenant = errors.New("unknown tenant")
+	// ErrTenantExists signals that a tenant with a given ID already exists.
+	ErrTenantExists = errors.New("tenant already exists")
+)
+
+// Tenant is a provider identity hosted by Tenants, which publishes its own
+// chain of advertisements via its own Engine.
+type Tenant struct {
+	// ID is the peer ID of the tenant, which is the provider ID of its
+	// advertisements.
+	ID peer.ID
+	// RetrievalAddrs are the addresses at which the content advertised by the
+	// tenant is retrievable.
+	RetrievalAddrs []multiaddr.Multiaddr
+	// Engine publishes the advertisements of the tenant.
+	Engine *Engine
+	// Datastore is the namespace of the datastore of Tenants in which the
+	// engine of the tenant stores its data. Data kept on behalf of the tenant,
+	// e.g. by a multihash lister, should be stored in it too, so that it is
+	// removed along with the tenant.
+	Datastore datastore.Batching
+}
+
+// tenantRecord is the persisted identity of a tenant.
+type tenantRecord struct {
+	PrivateKey     []byte
+	RetrievalAddrs []string
+}
+
+// TenantSetupFunc is called with each tenant once its engine is started, e.g.
+// to register the provider.MultihashLister of the engine. The tenant is not
+// hosted if it returns an error.
+type TenantSetupFunc func(ctx context.Context, t *Tenant) error
+
+// Tenants hosts multiple provider identities, such that one daemon maintains
+// independent advertisement chains on behalf of many providers. Each tenant
+// has its own private key, advertisement chain, head pointer and datastore
+// namespace, and is published by its own Engine.
+//
+// The engines of all tenants serve advertisements over HTTP, via Tenants
+// itself as the http.Handler at TenantsHandlerPath followed by the peer ID of
+// each tenant. Announcements are sent directly over HTTP only, since gossip
+// pubsub announcements are signed by the identity of a libp2p host.
+//
+// Tenants are persisted in the datastore, including their private keys, and
+// restored by Tenants.Start. Methods of Tenants are safe to call from multiple
+// goroutines.
+type Tenants struct {
+	ds     datastore.Batching
+	pubURL *url.URL
+	setup  TenantSetupFunc
+	opts   []Option
+
+	lock    sync.RWMutex
+	tenants map[peer.ID]*Tenant
+}
+
+// NewTenants instantiates Tenants that store their data in the given
+// datastore. The publisherURL is the external HTTP URL at which Tenants is
+// served, as put into announcements that tell indexers where to fetch the
+// advertisements of each tenant. The setup function, if not nil, is called with
+// each tenant once its engine is started.
+//
+// The given options are applied to the engine of every tenant. Options that
+// configure the identity, datastore, publisher and gossip pubsub announcements
+// of the engine are overridden.
+func NewTenants(ds datastore.Batching, publisherURL string, setup TenantSetupFunc, o ...Option) (*Tenants, error) {
+	u, err := url.Parse(publisherURL)
+	if err != nil {
+		return nil, fmt.Errorf("bad tenants publisher url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("tenants publisher url must be http or https: %s", publisherURL)
+	}
+	return &Tenants{
+		ds:      ds,
+		pubURL:  u,
+		setup:   setup,
+		opts:    o,
+		tenants: make(map[peer.ID]*Tenant),
+	}, nil
+}
+
+// Start starts the engines of all persisted tenants.
+func (t *Tenants) Start(ctx context.Context) error {
+	results, err := t.ds.Query(ctx, query.Query{Prefix: tenantsRegistryPrefix})
+	if err != nil {
+		return fmt.Errorf("cannot query tenants: %w", err)
+	}
+	defer results.Close()
+
+	for result := range results.Next() {
+		if result.Error != nil {
+			return fmt.Errorf("cannot read tenant: %w", result.Error)
+		}
+		var rec tenantRecord
+		if err = json.Unmarshal(result.Value, &rec); err != nil {
+			return fmt.Errorf("cannot decode tenant %s: %w", result.Key, err)
+		}
+		key, err := crypto.UnmarshalPrivateKey(rec.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("cannot decode private key of tenant %s: %w", result.Key, err)
+		}
+		addrs, err := stringsToMultiaddrs(rec.RetrievalAddrs)
+		if err != nil {
+			return fmt.Errorf("bad retrieval address of tenant %s: %w", result.Key, err)
+		}
+		tenant, err := t.start(ctx, key, addrs)
+		if err != nil {
+			return fmt.Errorf("cannot start tenant %s: %w", strings.TrimPrefix(result.Key, tenantsRegistryPrefix), err)
+		}
+		t.lock.Lock()
+		t.tenants[tenant.ID] = tenant
+		t.lock.Unlock()
+	}
+	log.Infow("Started tenants", "count", t.Len())
+	return nil
+}
+
+// Create creates a tenant with the identity of the given private key, which
+// advertises content retrievable at the given addresses, and starts its
+// engine. ErrTenantExists is returned if the tenant is already hosted.
+func (t *Tenants) Create(ctx context.Context, key crypto.PrivKey, retrievalAddrs ...multiaddr.Multiaddr) (*Tenant, error) {
+	if len(retrievalAddrs) == 0 {
+		return nil, errors.New("tenant must have at least one retrieval address")
+	}
+	id, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get peer ID from private key: %w", err)
+	}
+	keyBytes, err := crypto.MarshalPrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode private key: %w", err)
+	}
+	rec := tenantRecord{
+		PrivateKey:     keyBytes,
+		RetrievalAddrs: make([]string, 0, len(retrievalAddrs)),
+	}
+	for _, addr := range retrievalAddrs {
+		rec.RetrievalAddrs = append(rec.RetrievalAddrs, addr.String())
+	}
+	value, err := json.Marshal(&rec)
+	if err != nil {
+		return nil, err
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if _, ok := t.tenants[id]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrTenantExists, id)
+	}
+	tenant, err := t.start(ctx, key, retrievalAddrs)
+	if err != nil {
+		return nil, err
+	}
+	if err = t.ds.Put(ctx, tenantRegistryKey(id), value); err != nil {
+		_ = tenant.Engine.Shutdown()
+		return nil, fmt.Errorf("cannot store tenant: %w", err)
+	}
+	t.tenants[id] = tenant
+	log.Infow("Created tenant", "id", id, "retrievalAddrs", retrievalAddrs)
+	return tenant, nil
+}
+
+// start creates and starts the engine of the tenant with the given identity.
+func (t *Tenants) start(ctx context.Context, key crypto.PrivKey, retrievalAddrs []multiaddr.Multiaddr) (*Tenant, error) {
+	id, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get peer ID from private key: %w", err)
+	}
+	handlerPath := path.Join(TenantsHandlerPath, id.String())
+	announceAddr, err := maurl.FromURL(t.pubURL.JoinPath(handlerPath))
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert tenant publisher url to multiaddr: %w", err)
+	}
+	ds := dsn.Wrap(t.ds, datastore.NewKey(tenantsDataPrefix+id.String()))
+
+	opts := append(t.opts[:len(t.opts):len(t.opts)],
+		WithDatastore(ds),
+		WithPrivateKey(key),
+		WithProvider(peer.AddrInfo{ID: id, Addrs: retrievalAddrs}),
+		WithPublisherKind(HttpPublisher),
+		WithHttpPublisherListenAddr(t.pubURL.Host),
+		WithHttpPublisherWithoutServer(),
+		WithHttpPublisherHandlerPath(handlerPath),
+		WithHttpPublisherAnnounceAddr(announceAddr.String()),
+		WithPubsubAnnounce(false),
+	)
+	e, err := New(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err = e.Start(ctx); err != nil {
+		return nil, err
+	}
+	tenant := &Tenant{
+		ID:             id,
+		RetrievalAddrs: retrievalAddrs,
+		Engine:         e,
+		Datastore:      ds,
+	}
+	if t.setup != nil {
+		if err = t.setup(ctx, tenant); err != nil {
+			_ = e.Shutdown()
+			return nil, err
+		}
+	}
+	return tenant, nil
+}
+
+// Delete stops hosting the tenant with the given ID, and removes all of its
+// data, including its private key and advertisements. Indexers can no longer
+// fetch the advertisements of the tenant once it is deleted.
+func (t *Tenants) Delete(ctx context.Context, id peer.ID) error {
+	t.lock.Lock()
+	tenant, ok := t.tenants[id]
+	if ok {
+		delete(t.tenants, id)
+	}
+	t.lock.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownTenant, id)
+	}
+
+	if err := tenant.Engine.Shutdown(); err != nil {
+		log.Errorw("Failed to shut down engine of tenant", "id", id, "err", err)
+	}
+	if err := t.ds.Delete(ctx, tenantRegistryKey(id)); err != nil {
+		return fmt.Errorf("cannot delete tenant: %w", err)
+	}
+	count, err := deletePrefix(ctx, t.ds, tenantsDataPrefix+id.String())
+	if err != nil {
+		return fmt.Errorf("cannot delete data of tenant: %w", err)
+	}
+	log.Infow("Deleted tenant", "id", id, "records", count)
+	return nil
+}
+
+// Get returns the tenant with the given ID, or nil if there is none.
+func (t *Tenants) Get(id peer.ID) *Tenant {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.tenants[id]
+}
+
+// List returns all tenants in ascending order of ID.
+func (t *Tenants) List() []*Tenant {
+	t.lock.RLock()
+	tenants := make([]*Tenant, 0, len(t.tenants))
+	for _, tenant := range t.tenants {
+		tenants = append(tenants, tenant)
+	}
+	t.lock.RUnlock()
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].ID < tenants[j].ID })
+	return tenants
+}
+
+// Len returns the number of tenants.
+func (t *Tenants) Len() int {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return len(t.tenants)
+}
+
+// ServeHTTP serves the advertisements of the tenant whose peer ID follows
+// TenantsHandlerPath in the request path.
+func (t *Tenants) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rest, ok := strings.CutPrefix(r.URL.Path, TenantsHandlerPath)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	idStr, _, _ := strings.Cut(rest, "/")
+	id, err := peer.Decode(idStr)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	tenant := t.Get(id)
+	if tenant == nil {
+		http.NotFound(w, r)
+		return
+	}
+	handler, err := tenant.Engine.GetPublisherHttpFunc()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	handler(w, r)
+}
+
+// Shutdown shuts down the engines of all tenants.
+func (t *Tenants) Shutdown() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	var errs error
+	for id, tenant := range t.tenants {
+		if err := tenant.Engine.Shutdown(); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("error shutting down tenant %s: %w", id, err))
+		}
+	}
+	t.tenants = make(map[peer.ID]*Tenant)
+	return errs
+}
+
+func tenantRegistryKey(id peer.ID) datastore.Key {
+	return datastore.NewKey(tenantsRegistryPrefix + id.String())
+}
+
+func stringsToMultiaddrs(addrs []string) ([]multiaddr.Multiaddr, error) {
+	maddrs := make([]multiaddr.Multiaddr, len(addrs))
+	for i, a := range addrs {
+		var err error
+		maddrs[i], err = multiaddr.NewMultiaddr(a)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return maddrs, nil
+}
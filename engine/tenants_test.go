@@ -0,0 +1,109 @@
+package engine_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenants(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	var setups int
+	setup := func(_ context.Context, tenant *engine.Tenant) error {
+		setups++
+		tenant.Engine.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+			return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+		})
+		return nil
+	}
+	subject, err := engine.NewTenants(ds, "http://fish.example", setup)
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	server := httptest.NewServer(subject)
+	t.Cleanup(server.Close)
+
+	addr := multiaddr.StringCast("/ip4/127.0.0.1/tcp/9999")
+	fishID, fishKey, _ := test.RandomIdentity()
+	lobsterID, lobsterKey, _ := test.RandomIdentity()
+	fish, err := subject.Create(ctx, fishKey, addr)
+	require.NoError(t, err)
+	require.Equal(t, fishID, fish.ID)
+	_, err = subject.Create(ctx, fishKey, addr)
+	require.ErrorIs(t, err, engine.ErrTenantExists)
+	_, err = subject.Create(ctx, lobsterKey)
+	require.ErrorContains(t, err, "at least one retrieval address")
+	_, err = subject.Create(ctx, lobsterKey, addr)
+	require.NoError(t, err)
+	require.Equal(t, 2, setups)
+
+	// Each tenant publishes its own chain, signed by its own key.
+	md := metadata.Default.New(metadata.Bitswap{})
+	adCid, err := fish.Engine.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.NoError(t, err)
+	ad, err := fish.Engine.GetAdv(ctx, adCid)
+	require.NoError(t, err)
+	require.Equal(t, fishID.String(), ad.Provider)
+	signerID, err := ad.VerifySignature()
+	require.NoError(t, err)
+	require.Equal(t, fishID, signerID)
+	lobsterHead, _, err := subject.Get(lobsterID).Engine.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	require.False(t, lobsterHead.Defined())
+
+	get := func(id peer.ID) (int, string) {
+		resp, err := http.Get(server.URL + engine.TenantsHandlerPath + id.String() + "/ipni/v1/ad/head")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+	status, body := get(fishID)
+	require.Equal(t, http.StatusOK, status)
+	require.Contains(t, body, adCid.String())
+	unknownID, err := peer.Decode("12D3KooWSG3JuvEjRkSxt93ADTjQxqe4ExbBwSkQ9Zyk1WfBaZJF")
+	require.NoError(t, err)
+	status, _ = get(unknownID)
+	require.Equal(t, http.StatusNotFound, status)
+
+	// Tenants are restored once started again.
+	require.NoError(t, subject.Shutdown())
+	subject, err = engine.NewTenants(ds, "http://fish.example", setup)
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	t.Cleanup(func() { _ = subject.Shutdown() })
+	tenants := subject.List()
+	require.Len(t, tenants, 2)
+	head, _, err := subject.Get(fishID).Engine.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	require.Equal(t, adCid, head)
+
+	require.NoError(t, subject.Delete(ctx, fishID))
+	require.ErrorIs(t, subject.Delete(ctx, fishID), engine.ErrUnknownTenant)
+	require.Nil(t, subject.Get(fishID))
+	require.Equal(t, 1, subject.Len())
+	require.NoError(t, subject.Shutdown())
+	subject, err = engine.NewTenants(ds, "http://fish.example", nil)
+	require.NoError(t, err)
+	require.NoError(t, subject.Start(ctx))
+	require.Equal(t, lobsterID, subject.List()[0].ID)
+	require.Equal(t, 1, subject.Len())
+
+	_, err = engine.NewTenants(ds, "fish.example", nil)
+	require.ErrorContains(t, err, "must be http or https")
+}
@@ -1,6 +1,9 @@
 package provider
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	// ErrNoMultihashLister signals that no provider.MultihashLister is registered for lookup.
@@ -9,7 +12,32 @@ var (
 	// ErrContextIDNotFound signals that no item is associated to the given context ID.
 	ErrContextIDNotFound = errors.New("context ID not found")
 
+	// ErrEmptyContextID signals that a context ID is empty.
+	ErrEmptyContextID = errors.New("context ID must not be empty")
+
+	// ErrContextIDTooLong signals that a context ID is longer than MaxContextIDLen.
+	ErrContextIDTooLong = errors.New("context ID too long")
+
+	// ErrMetadataTooLong signals that encoded metadata is longer than MaxMetadataLen.
+	ErrMetadataTooLong = errors.New("metadata too long")
+
+	// ErrTooManyAddrs signals that an advertisement has more than MaxAddrs provider addresses.
+	ErrTooManyAddrs = errors.New("too many provider addresses")
+
 	// ErrAlreadyAdvertised signals that an advertisement for identical content was already
 	// published.
 	ErrAlreadyAdvertised = errors.New("advertisement already published")
+
+	// ErrMultihashSkipped signals that a MultihashIterator could not list a
+	// multihash, which is skipped rather than failing the listing of all
+	// multihashes. See: SkipMultihash.
+	ErrMultihashSkipped = errors.New("multihash skipped")
 )
+
+// SkipMultihash wraps the error with which a MultihashIterator failed to list a
+// multihash, such that returning it from MultihashIterator.Next skips that
+// multihash rather than failing the listing of all multihashes. The returned
+// error wraps both ErrMultihashSkipped and err.
+func SkipMultihash(err error) error {
+	return fmt.Errorf("%w: %w", ErrMultihashSkipped, err)
+}
@@ -0,0 +1,330 @@
+// Package forwarding provides an implementation of provider.Interface that
+// forwards advertising requests to the admin server of a provider daemon, so
+// that several processes can advertise content under the one provider identity
+// of the daemon, which keeps the advertisement chain and publishes it.
+package forwarding
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log/v2"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipni/go-libipni/ingest/schema"
+	"github.com/ipni/go-libipni/metadata"
+	provider "github.com/ipni/index-provider"
+	adminserver "github.com/ipni/index-provider/server/admin/http"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multihash"
+)
+
+var log = logging.Logger("provider/forwarding")
+
+var (
+	// ErrNotSupported signals that a method of provider.Interface cannot be
+	// forwarded to the admin server.
+	ErrNotSupported = errors.New("not supported by forwarding provider")
+	// ErrShutdown signals that the forwarding provider is shut down.
+	ErrShutdown = errors.New("forwarding provider is shut down")
+)
+
+var _ provider.Interface = (*ForwardingProvider)(nil)
+
+// ForwardingProvider is a provider.Interface whose advertisements are published
+// by a remote provider daemon, via its admin server. NotifyPut and
+// NotifyRemove are forwarded to the daemon, which appends the advertisements
+// to its chain and announces them, and advertisements are read back from it.
+//
+// The multihashes of a context ID are listed locally, by the registered
+// provider.MultihashLister, and sent to the daemon along with the request to
+// advertise them, where they are stored to serve the entries of the
+// advertisement to indexers. If no lister is registered, then the daemon
+// lists the multihashes of the context ID itself, e.g. from a CAR file
+// imported into it.
+type ForwardingProvider struct {
+	*options
+	baseURL string
+
+	lock     sync.Mutex
+	mhLister provider.MultihashLister
+	closed   bool
+}
+
+// NewForwardingProvider instantiates a provider that forwards to the admin
+// server at the given base URL, e.g. "http://localhost:3102".
+func NewForwardingProvider(adminURL string, o ...Option) (*ForwardingProvider, error) {
+	opts, err := newOptions(o...)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.ParseRequestURI(adminURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid admin server url %q: %w", adminURL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid admin server url %q: must be an http or https URL", adminURL)
+	}
+	return &ForwardingProvider{
+		options: opts,
+		baseURL: strings.TrimSuffix(adminURL, "/"),
+	}, nil
+}
+
+// PublishLocal is not supported, since the admin server publishes only the
+// advertisements it generates.
+func (p *ForwardingProvider) PublishLocal(context.Context, schema.Advertisement) (cid.Cid, error) {
+	return cid.Undef, ErrNotSupported
+}
+
+// Publish is not supported, since the admin server publishes only the
+// advertisements it generates.
+func (p *ForwardingProvider) Publish(context.Context, schema.Advertisement) (cid.Cid, error) {
+	return cid.Undef, ErrNotSupported
+}
+
+// RegisterMultihashLister registers the lister of the multihashes that are
+// sent to the daemon with the requests of NotifyPut.
+func (p *ForwardingProvider) RegisterMultihashLister(mhl provider.MultihashLister) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.mhLister = mhl
+}
+
+// NotifyPut lists the multihashes of the given context ID, if a lister is
+// registered, and requests the daemon to advertise them. If provider is nil,
+// then the default provider of the daemon is assumed.
+func (p *ForwardingProvider) NotifyPut(ctx context.Context, prov *peer.AddrInfo, contextID []byte, md metadata.Metadata) (cid.Cid, error) {
+	if err := p.checkOpen(); err != nil {
+		return cid.Undef, err
+	}
+	mdBytes, err := md.MarshalBinary()
+	if err != nil {
+		return cid.Undef, fmt.Errorf("cannot marshal metadata: %w", err)
+	}
+	req := &adminserver.AdvertiseReq{
+		ContextID: contextID,
+		Provider:  prov,
+		Metadata:  mdBytes,
+	}
+	if req.Multihashes, err = p.listMultihashes(ctx, prov, contextID); err != nil {
+		return cid.Undef, err
+	}
+
+	var res adminserver.AdvertiseRes
+	if err = p.do(ctx, http.MethodPost, "/admin/advertise", nil, req, &res); err != nil {
+		switch statusCode(err) {
+		case http.StatusConflict:
+			return cid.Undef, fmt.Errorf("%w: %w", provider.ErrAlreadyAdvertised, err)
+		case http.StatusNotFound:
+			return cid.Undef, fmt.Errorf("%w: %w", provider.ErrContextIDNotFound, err)
+		}
+		return cid.Undef, err
+	}
+	log.Debugw("Forwarded advertisement", "advertisement", res.AdvId, "multihashes", len(req.Multihashes))
+	return res.AdvId, nil
+}
+
+// listMultihashes lists the multihashes of the given context ID via the
+// registered lister, or returns nil if no lister is registered.
+func (p *ForwardingProvider) listMultihashes(ctx context.Context, prov *peer.AddrInfo, contextID []byte) ([]multihash.Multihash, error) {
+	p.lock.Lock()
+	mhLister := p.mhLister
+	p.lock.Unlock()
+	if mhLister == nil {
+		return nil, nil
+	}
+
+	var providerID peer.ID
+	if prov != nil {
+		providerID = prov.ID
+	}
+	mhIter, err := mhLister(ctx, providerID, contextID)
+	if err != nil {
+		return nil, err
+	}
+	if closer, ok := mhIter.(io.Closer); ok {
+		defer closer.Close()
+	}
+	var mhs []multihash.Multihash
+	for {
+		mh, err := mhIter.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if errors.Is(err, provider.ErrMultihashSkipped) {
+				log.Warnw("Skipped multihash", "err", err)
+				continue
+			}
+			return nil, fmt.Errorf("cannot list multihashes: %w", err)
+		}
+		mhs = append(mhs, mh)
+	}
+	if len(mhs) == 0 {
+		return nil, fmt.Errorf("%w: no multihashes listed", provider.ErrContextIDNotFound)
+	}
+	return mhs, nil
+}
+
+// NotifyRemove requests the daemon to advertise the removal of the given
+// context ID. If providerID is empty, then the default provider of the daemon
+// is assumed.
+func (p *ForwardingProvider) NotifyRemove(ctx context.Context, providerID peer.ID, contextID []byte) (cid.Cid, error) {
+	if err := p.checkOpen(); err != nil {
+		return cid.Undef, err
+	}
+	req := &adminserver.RemoveReq{
+		ContextID: contextID,
+		Provider:  providerID,
+	}
+	var res adminserver.RemoveRes
+	if err := p.do(ctx, http.MethodPost, "/admin/remove", nil, req, &res); err != nil {
+		if statusCode(err) == http.StatusNotFound {
+			return cid.Undef, fmt.Errorf("%w: %w", provider.ErrContextIDNotFound, err)
+		}
+		return cid.Undef, err
+	}
+	log.Debugw("Forwarded removal", "advertisement", res.AdvId)
+	return res.AdvId, nil
+}
+
+// GetAdv gets the advertisement with the given CID from the daemon. The
+// advertisement lacks its signature and extended providers, which the admin
+// server does not serve.
+func (p *ForwardingProvider) GetAdv(ctx context.Context, adCid cid.Cid) (*schema.Advertisement, error) {
+	if err := p.checkOpen(); err != nil {
+		return nil, err
+	}
+	var res adminserver.AdInfo
+	if err := p.do(ctx, http.MethodGet, "/admin/ads/"+adCid.String(), nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return newAdvertisement(res), nil
+}
+
+// GetLatestAdv gets the head of the advertisement chain of the daemon, as
+// GetAdv does. cid.Undef is returned if the chain is empty.
+func (p *ForwardingProvider) GetLatestAdv(ctx context.Context) (cid.Cid, *schema.Advertisement, error) {
+	if err := p.checkOpen(); err != nil {
+		return cid.Undef, nil, err
+	}
+	var res adminserver.ListAdsRes
+	if err := p.do(ctx, http.MethodGet, "/admin/ads", url.Values{"limit": []string{"1"}}, nil, &res); err != nil {
+		return cid.Undef, nil, err
+	}
+	if len(res.Ads) == 0 {
+		return cid.Undef, nil, nil
+	}
+	return res.Ads[0].ID, newAdvertisement(res.Ads[0]), nil
+}
+
+func newAdvertisement(info adminserver.AdInfo) *schema.Advertisement {
+	ad := &schema.Advertisement{
+		Provider:  info.Provider,
+		Addresses: info.Addresses,
+		Entries:   schema.NoEntries,
+		ContextID: info.ContextID,
+		Metadata:  info.Metadata,
+		IsRm:      info.IsRm,
+	}
+	if info.PreviousID != nil {
+		ad.PreviousID = cidlink.Link{Cid: *info.PreviousID}
+	}
+	if info.Entries != nil {
+		ad.Entries = cidlink.Link{Cid: *info.Entries}
+	}
+	return ad
+}
+
+// Shutdown closes the idle connections to the admin server. The daemon keeps
+// running, and the forwarding provider is no longer usable.
+func (p *ForwardingProvider) Shutdown() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if !p.closed {
+		p.closed = true
+		p.httpClient.CloseIdleConnections()
+	}
+	return nil
+}
+
+func (p *ForwardingProvider) checkOpen() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closed {
+		return ErrShutdown
+	}
+	return nil
+}
+
+// Error is returned when the admin server responds with an unexpected status.
+type Error struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Message is the body of the response.
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("admin server responded with %s: %s", http.StatusText(e.StatusCode), strings.TrimSpace(e.Message))
+}
+
+func statusCode(err error) int {
+	var aerr *Error
+	if errors.As(err, &aerr) {
+		return aerr.StatusCode
+	}
+	return 0
+}
+
+// do sends a request with the given JSON body, if any, and decodes the JSON
+// body of a 200 OK response into res. Otherwise, an *Error is returned.
+func (p *ForwardingProvider) do(ctx context.Context, method, path string, query url.Values, req io.WriterTo, res io.ReaderFrom) error {
+	u := p.baseURL + path
+	if len(query) != 0 {
+		u += "?" + query.Encode()
+	}
+	var body io.Reader
+	if req != nil {
+		var buf bytes.Buffer
+		if _, err := req.WriteTo(&buf); err != nil {
+			return err
+		}
+		body = &buf
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	if req != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	if p.bearerToken != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.bearerToken)
+	}
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+		return &Error{StatusCode: resp.StatusCode, Message: string(msg)}
+	}
+	if _, err = res.ReadFrom(resp.Body); err != nil {
+		return fmt.Errorf("received ok response from admin server but cannot decode response body: %w", err)
+	}
+	return nil
+}
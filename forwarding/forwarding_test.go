@@ -0,0 +1,135 @@
+package forwarding_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipni/go-libipni/ingest/schema"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/ipni/index-provider/forwarding"
+	adminserver "github.com/ipni/index-provider/server/admin/http"
+	"github.com/ipni/index-provider/supplier"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+const testTimeout = 30 * time.Second
+
+// startDaemon starts an engine served by an admin server that requires the
+// given bearer token, as the provider daemon does, and returns the engine and
+// the URL of the admin server.
+func startDaemon(t *testing.T, token string) (*engine.Engine, *supplier.MultihashSupplier, string) {
+	ctx := context.Background()
+	eng, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	cs := supplier.NewCarSupplier(eng, ds)
+	ms := supplier.NewMultihashSupplier(eng, ds)
+	eng.RegisterMultihashLister(supplier.ChainListers(cs.ListMultihashes, ms.ListMultihashes))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+	server, err := adminserver.New(nil, eng, cs,
+		adminserver.WithListenAddr(addr),
+		adminserver.WithBearerToken(token),
+		adminserver.WithMultihashSupplier(ms))
+	require.NoError(t, err)
+	go func() { _ = server.Start() }()
+	t.Cleanup(func() { _ = server.Shutdown(ctx) })
+	return eng, ms, "http://" + addr
+}
+
+func TestForwardingProvider(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+	daemon, ms, adminURL := startDaemon(t, "secret")
+
+	_, err := forwarding.NewForwardingProvider("localhost:3102")
+	require.ErrorContains(t, err, "invalid admin server url")
+	unauthorized, err := forwarding.NewForwardingProvider(adminURL)
+	require.NoError(t, err)
+	_, _, err = unauthorized.GetLatestAdv(ctx)
+	var aerr *forwarding.Error
+	require.True(t, errors.As(err, &aerr))
+	require.Equal(t, 401, aerr.StatusCode)
+
+	subject, err := forwarding.NewForwardingProvider(adminURL+"/", forwarding.WithBearerToken("secret"))
+	require.NoError(t, err)
+	adCid, ad, err := subject.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	require.Equal(t, cid.Undef, adCid)
+	require.Nil(t, ad)
+
+	mhs := test.RandomMultihashes(5)
+	subject.RegisterMultihashLister(func(_ context.Context, _ peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		if string(contextID) != "fish" {
+			return provider.SliceMultihashIterator(nil), nil
+		}
+		return provider.SliceMultihashIterator(mhs), nil
+	})
+	md := metadata.Default.New(metadata.Bitswap{})
+	putCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.NoError(t, err)
+	_, err = subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.ErrorIs(t, err, provider.ErrAlreadyAdvertised)
+	_, err = subject.NotifyPut(ctx, nil, []byte("lobster"), md)
+	require.ErrorIs(t, err, provider.ErrContextIDNotFound)
+
+	// The advertisement is published by the daemon, which stores the
+	// multihashes listed by the forwarding provider to serve its entries.
+	head, daemonAd, err := daemon.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	require.Equal(t, putCid, head)
+	require.Equal(t, []byte("fish"), daemonAd.ContextID)
+	mhIter, err := ms.ListMultihashes(ctx, "", []byte("fish"))
+	require.NoError(t, err)
+	var stored []multihash.Multihash
+	for {
+		mh, err := mhIter.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		stored = append(stored, mh)
+	}
+	require.Equal(t, mhs, stored)
+
+	adCid, ad, err = subject.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	require.Equal(t, putCid, adCid)
+	require.Equal(t, daemonAd.Provider, ad.Provider)
+	require.Equal(t, daemonAd.Entries, ad.Entries)
+	require.Equal(t, daemonAd.Metadata, ad.Metadata)
+	require.False(t, ad.IsRm)
+
+	rmCid, err := subject.NotifyRemove(ctx, "", []byte("fish"))
+	require.NoError(t, err)
+	_, err = subject.NotifyRemove(ctx, "", []byte("fish"))
+	require.ErrorIs(t, err, provider.ErrContextIDNotFound)
+	ad, err = subject.GetAdv(ctx, rmCid)
+	require.NoError(t, err)
+	require.True(t, ad.IsRm)
+	require.Equal(t, schema.NoEntries, ad.Entries)
+	require.Equal(t, putCid, ad.PreviousCid())
+
+	_, err = subject.Publish(ctx, schema.Advertisement{})
+	require.ErrorIs(t, err, forwarding.ErrNotSupported)
+	require.NoError(t, subject.Shutdown())
+	_, err = subject.NotifyRemove(ctx, "", []byte("fish"))
+	require.ErrorIs(t, err, forwarding.ErrShutdown)
+}
@@ -0,0 +1,80 @@
+package forwarding
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"time"
+)
+
+type (
+	// Option sets a configuration parameter for the forwarding provider.
+	Option func(*options) error
+
+	options struct {
+		bearerToken string
+		tlsConfig   *tls.Config
+		httpClient  *http.Client
+		timeout     time.Duration
+	}
+)
+
+func newOptions(o ...Option) (*options, error) {
+	opts := &options{}
+	for _, apply := range o {
+		if err := apply(opts); err != nil {
+			return nil, err
+		}
+	}
+	if opts.httpClient == nil {
+		opts.httpClient = &http.Client{Timeout: opts.timeout}
+		if opts.tlsConfig != nil {
+			opts.httpClient.Transport = &http.Transport{TLSClientConfig: opts.tlsConfig}
+		}
+	}
+	return opts, nil
+}
+
+// WithBearerToken sets the bearer token with which requests to the admin
+// server are authenticated. Unset by default.
+func WithBearerToken(token string) Option {
+	return func(o *options) error {
+		o.bearerToken = token
+		return nil
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used to connect to an admin server
+// served over HTTPS, e.g. to verify the server certificate or to present a
+// client certificate. Ignored if WithHTTPClient is set.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(o *options) error {
+		o.tlsConfig = config
+		return nil
+	}
+}
+
+// WithHTTPClient sets the HTTP client with which requests are sent to the
+// admin server. Defaults to a client with the timeout set by WithTimeout.
+func WithHTTPClient(c *http.Client) Option {
+	return func(o *options) error {
+		if c == nil {
+			return errors.New("http client must not be nil")
+		}
+		o.httpClient = c
+		return nil
+	}
+}
+
+// WithTimeout sets the timeout of each request to the admin server. Defaults
+// to zero, meaning no timeout, since publishing the advertisement of many
+// multihashes may take long. Ignored if WithHTTPClient is set.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) error {
+		if timeout < 0 {
+			return errors.New("timeout must not be negative")
+		}
+		o.timeout = timeout
+		return nil
+	}
+}
@@ -0,0 +1,6 @@
+// Package httpgateway helps advertise content as retrievable from IPFS
+// trustless HTTP gateways, i.e. over the transport-ipfs-gateway-http
+// transport. Such advertisements carry the metadata returned by Metadata, and
+// list the multiaddrs of the gateway URLs as retrieval addresses, e.g. via
+// AddrInfo or engine.WithRetrievalAddrs.
+package httpgateway
@@ -0,0 +1,48 @@
+package httpgateway
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/ipni/go-libipni/maurl"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// Metadata returns the metadata of content retrievable from IPFS trustless
+// HTTP gateways.
+func Metadata() metadata.Metadata {
+	return metadata.Default.New(metadata.IpfsGatewayHttp{})
+}
+
+// Multiaddr returns the multiaddr of the gateway with the given http or https
+// URL, e.g. /dns/gateway.example/https for https://gateway.example.
+func Multiaddr(gatewayURL string) (multiaddr.Multiaddr, error) {
+	u, err := url.Parse(gatewayURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported gateway URL scheme %q; must be http or https", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("gateway URL %q has no host", gatewayURL)
+	}
+	return maurl.FromURL(u)
+}
+
+// AddrInfo returns the address info of the provider with the given ID that
+// serves content from the gateways with the given URLs, for use with
+// engine.Engine.NotifyPut.
+func AddrInfo(id peer.ID, gatewayURLs ...string) (*peer.AddrInfo, error) {
+	addrInfo := &peer.AddrInfo{ID: id}
+	for _, u := range gatewayURLs {
+		addr, err := Multiaddr(u)
+		if err != nil {
+			return nil, err
+		}
+		addrInfo.Addrs = append(addrInfo.Addrs, addr)
+	}
+	return addrInfo, nil
+}
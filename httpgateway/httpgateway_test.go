@@ -0,0 +1,50 @@
+package httpgateway_test
+
+import (
+	"testing"
+
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/index-provider/httpgateway"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multicodec"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadata(t *testing.T) {
+	md := httpgateway.Metadata()
+	require.Equal(t, []multicodec.Code{multicodec.TransportIpfsGatewayHttp}, md.Protocols())
+
+	data, err := md.MarshalBinary()
+	require.NoError(t, err)
+	decoded := metadata.Default.New()
+	require.NoError(t, decoded.UnmarshalBinary(data))
+	require.True(t, md.Equal(decoded))
+}
+
+func TestMultiaddr(t *testing.T) {
+	addr, err := httpgateway.Multiaddr("https://gateway.example")
+	require.NoError(t, err)
+	require.Equal(t, "/dns/gateway.example/https", addr.String())
+
+	addr, err = httpgateway.Multiaddr("http://127.0.0.1:8080")
+	require.NoError(t, err)
+	require.Equal(t, "/ip4/127.0.0.1/tcp/8080/http", addr.String())
+
+	_, err = httpgateway.Multiaddr("ftp://gateway.example")
+	require.ErrorContains(t, err, "unsupported gateway URL scheme")
+	_, err = httpgateway.Multiaddr("gateway.example")
+	require.Error(t, err)
+}
+
+func TestAddrInfo(t *testing.T) {
+	id, err := peer.Decode("12D3KooWPMGfQs5CaJKG4yCxVWizWBRtB85gEUwiX2ekStvYvqgp")
+	require.NoError(t, err)
+	addrInfo, err := httpgateway.AddrInfo(id, "https://a.example", "https://b.example")
+	require.NoError(t, err)
+	require.Equal(t, id, addrInfo.ID)
+	require.Len(t, addrInfo.Addrs, 2)
+	require.Equal(t, "/dns/b.example/https", addrInfo.Addrs[1].String())
+
+	_, err = httpgateway.AddrInfo(id, "fish")
+	require.Error(t, err)
+}
@@ -7,6 +7,8 @@
 // A reference implementation of provider.Interface can be found in engine.Engine.
 package provider
 
+//go:generate go run github.com/golang/mock/mockgen -source=interface.go -destination=mock/interface.go
+
 import (
 	"context"
 
@@ -95,6 +97,11 @@ type MultihashIterator interface {
 	// iterator fails fast: errors that occur during iteration are returned
 	// immediately.  This function returns a zero multihash and io.EOF when
 	// there are no more elements to return.
+	//
+	// An error that wraps ErrMultihashSkipped, as returned by SkipMultihash,
+	// signals that only the multihash at hand could not be listed. The engine
+	// logs and counts such errors, and calls Next again to carry on listing
+	// the remaining multihashes, instead of failing the advertisement.
 	Next() (multihash.Multihash, error)
 }
 
@@ -102,6 +109,9 @@ type MultihashIterator interface {
 // The lister must be deterministic: it must produce the same list of multihashes in the same
 // order for the same (provider, contextID) tuple.
 //
+// The lister may be called from multiple goroutines at once, e.g. to regenerate entries requested
+// by indexers while a new advertisement is published, and so must be safe for concurrent use.
+//
 // empty provider means falling back to the default.
 // See: Interface.NotifyPut, Interface.NotifyRemove, MultihashIterator.
 type MultihashLister func(ctx context.Context, provider peer.ID, contextID []byte) (MultihashIterator, error)
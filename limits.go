@@ -0,0 +1,90 @@
+package provider
+
+import (
+	"fmt"
+
+	"github.com/ipni/go-libipni/ingest/schema"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+const (
+	// MaxMetadataLen is the maximum number of bytes of the encoded metadata
+	// of an advertisement accepted by indexers.
+	MaxMetadataLen = schema.MaxMetadataLen
+	// MaxAddrs is the maximum number of provider addresses in an
+	// advertisement. Indexers store the addresses of a provider and hand them
+	// all out to retrieval clients, so advertisements with more addresses are
+	// rejected.
+	MaxAddrs = 32
+)
+
+// ValidatePut checks that an advertisement of the given context ID, metadata
+// and provider addresses, such as published by Interface.NotifyPut, is within
+// the limits accepted by indexers. The returned error wraps
+// ErrContextIDTooLong, ErrMetadataTooLong or ErrTooManyAddrs, depending on
+// the limit that is exceeded.
+func ValidatePut(contextID []byte, md metadata.Metadata, addrs []multiaddr.Multiaddr) error {
+	if err := validateContextIDLen(contextID); err != nil {
+		return err
+	}
+	if md.Len() != 0 {
+		data, err := md.MarshalBinary()
+		if err != nil {
+			return fmt.Errorf("cannot encode metadata: %w", err)
+		}
+		if err = validateMetadataLen(data); err != nil {
+			return err
+		}
+	}
+	return validateAddrsLen(len(addrs))
+}
+
+// ValidateAdvertisement checks that the advertisement is within the limits
+// accepted by indexers, as ValidatePut does, and that its provider ID and
+// addresses are valid. Its signature is not checked.
+func ValidateAdvertisement(ad schema.Advertisement) error {
+	if err := validateContextIDLen(ad.ContextID); err != nil {
+		return err
+	}
+	if err := validateMetadataLen(ad.Metadata); err != nil {
+		return err
+	}
+	if ad.IsRm {
+		return nil
+	}
+	if _, err := peer.Decode(ad.Provider); err != nil {
+		return fmt.Errorf("invalid provider ID %q: %w", ad.Provider, err)
+	}
+	if err := validateAddrsLen(len(ad.Addresses)); err != nil {
+		return err
+	}
+	for _, addr := range ad.Addresses {
+		if _, err := multiaddr.NewMultiaddr(addr); err != nil {
+			return fmt.Errorf("invalid provider address %q: %w", addr, err)
+		}
+	}
+	return nil
+}
+
+func validateContextIDLen(contextID []byte) error {
+	if len(contextID) > MaxContextIDLen {
+		return fmt.Errorf("%w: %d bytes, maximum is %d", ErrContextIDTooLong, len(contextID), MaxContextIDLen)
+	}
+	return nil
+}
+
+func validateMetadataLen(data []byte) error {
+	if len(data) > MaxMetadataLen {
+		return fmt.Errorf("%w: %d bytes encoded, maximum is %d", ErrMetadataTooLong, len(data), MaxMetadataLen)
+	}
+	return nil
+}
+
+func validateAddrsLen(n int) error {
+	if n > MaxAddrs {
+		return fmt.Errorf("%w: %d provider addresses, maximum is %d", ErrTooManyAddrs, n, MaxAddrs)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package provider
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ipni/go-libipni/ingest/schema"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatePut(t *testing.T) {
+	md := metadata.Default.New(metadata.Bitswap{})
+	addrs := []multiaddr.Multiaddr{multiaddr.StringCast("/ip4/192.0.2.1/tcp/3103")}
+	require.NoError(t, ValidatePut([]byte("fish"), md, addrs))
+	require.NoError(t, ValidatePut([]byte("fish"), metadata.Metadata{}, nil))
+	require.ErrorIs(t, ValidatePut(bytes.Repeat([]byte("f"), MaxContextIDLen+1), md, addrs), ErrContextIDTooLong)
+	require.ErrorIs(t, ValidatePut([]byte("fish"), md, make([]multiaddr.Multiaddr, MaxAddrs+1)), ErrTooManyAddrs)
+}
+
+func TestValidateAdvertisement(t *testing.T) {
+	providerID, _, _ := test.RandomIdentity()
+	valid := func() schema.Advertisement {
+		return schema.Advertisement{
+			Provider:  providerID.String(),
+			Addresses: []string{"/ip4/192.0.2.1/tcp/3103"},
+			ContextID: []byte("fish"),
+			Metadata:  []byte{0x80, 0x80, 0x04},
+		}
+	}
+	require.NoError(t, ValidateAdvertisement(valid()))
+
+	ad := valid()
+	ad.ContextID = bytes.Repeat([]byte("f"), MaxContextIDLen+1)
+	require.ErrorIs(t, ValidateAdvertisement(ad), ErrContextIDTooLong)
+
+	ad = valid()
+	ad.Metadata = bytes.Repeat([]byte{1}, MaxMetadataLen+1)
+	err := ValidateAdvertisement(ad)
+	require.ErrorIs(t, err, ErrMetadataTooLong)
+	require.ErrorContains(t, err, "1025 bytes encoded, maximum is 1024")
+
+	ad = valid()
+	ad.Addresses = make([]string, MaxAddrs+1)
+	require.ErrorIs(t, ValidateAdvertisement(ad), ErrTooManyAddrs)
+
+	ad = valid()
+	ad.Addresses = []string{"fish"}
+	require.ErrorContains(t, ValidateAdvertisement(ad), `invalid provider address "fish"`)
+
+	ad = valid()
+	ad.Provider = "fish"
+	require.ErrorContains(t, ValidateAdvertisement(ad), `invalid provider ID "fish"`)
+
+	// Removals need no provider addresses.
+	ad.IsRm = true
+	ad.Addresses = nil
+	require.NoError(t, ValidateAdvertisement(ad))
+}
@@ -5,9 +5,29 @@ import "go.opentelemetry.io/otel/attribute"
 var Attributes struct {
 	StatusFailure attribute.KeyValue
 	StatusSuccess attribute.KeyValue
+
+	AdKindPut    attribute.KeyValue
+	AdKindUpdate attribute.KeyValue
+	AdKindRemove attribute.KeyValue
+
+	CacheHit  attribute.KeyValue
+	CacheMiss attribute.KeyValue
+
+	BlockKindAd      attribute.KeyValue
+	BlockKindEntries attribute.KeyValue
 }
 
 func init() {
 	Attributes.StatusFailure = attribute.String("status", "failure")
 	Attributes.StatusSuccess = attribute.String("status", "success")
+
+	Attributes.AdKindPut = attribute.String("kind", "put")
+	Attributes.AdKindUpdate = attribute.String("kind", "update")
+	Attributes.AdKindRemove = attribute.String("kind", "remove")
+
+	Attributes.CacheHit = attribute.String("result", "hit")
+	Attributes.CacheMiss = attribute.String("result", "miss")
+
+	Attributes.BlockKindAd = attribute.String("kind", "advertisement")
+	Attributes.BlockKindEntries = attribute.String("kind", "entries")
 }
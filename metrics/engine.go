@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"go.opentelemetry.io/otel/metric"
+)
+
+var Engine struct {
+	AdsPublished       metric.Int64Counter
+	Announces          metric.Int64Counter
+	ChunkingDuration   metric.Int64Histogram
+	EntriesCacheLookup metric.Int64Counter
+	BlocksServed       metric.Int64Counter
+	Multihashes        metric.Int64UpDownCounter
+
+	EntriesMemoryCacheLookup metric.Int64Counter
+	EntriesMemoryCacheSize   metric.Int64UpDownCounter
+
+	TargetAnnounces        metric.Int64Counter
+	TargetAnnounceDuration metric.Int64Histogram
+
+	MultihashesListed  metric.Int64Counter
+	MultihashesSkipped metric.Int64Counter
+	ListerWaitDuration metric.Int64Histogram
+}
+
+func init() {
+	var err error
+	if Engine.AdsPublished, err = meter.Int64Counter(
+		"index-provider/engine/ads_published",
+		metric.WithUnit("{advertisement}"),
+		metric.WithDescription("The number of advertisements published"),
+	); err != nil {
+		panic(err)
+	}
+	if Engine.Announces, err = meter.Int64Counter(
+		"index-provider/engine/announces",
+		metric.WithUnit("{announcement}"),
+		metric.WithDescription("The number of announcements sent, by status"),
+	); err != nil {
+		panic(err)
+	}
+	if Engine.TargetAnnounces, err = meter.Int64Counter(
+		"index-provider/engine/target_announces",
+		metric.WithUnit("{announcement}"),
+		metric.WithDescription("The number of announcements sent to each target, i.e. indexer URL or gossip pubsub topic, by target and status"),
+	); err != nil {
+		panic(err)
+	}
+	if Engine.TargetAnnounceDuration, err = meter.Int64Histogram(
+		"index-provider/engine/target_announce_duration",
+		metric.WithUnit("ms"),
+		metric.WithDescription("The time taken to send an announcement to each target in milliseconds, by target"),
+	); err != nil {
+		panic(err)
+	}
+	if Engine.ChunkingDuration, err = meter.Int64Histogram(
+		"index-provider/engine/chunking_duration",
+		metric.WithUnit("ms"),
+		metric.WithDescription("The time taken to chunk the entries of an advertisement in milliseconds"),
+	); err != nil {
+		panic(err)
+	}
+	if Engine.MultihashesListed, err = meter.Int64Counter(
+		"index-provider/engine/multihashes_listed",
+		metric.WithUnit("{multihash}"),
+		metric.WithDescription("The number of multihashes listed by the multihash lister to generate entries"),
+	); err != nil {
+		panic(err)
+	}
+	if Engine.MultihashesSkipped, err = meter.Int64Counter(
+		"index-provider/engine/multihashes_skipped",
+		metric.WithUnit("{multihash}"),
+		metric.WithDescription("The number of multihashes that the multihash lister failed to list and that are not advertised"),
+	); err != nil {
+		panic(err)
+	}
+	if Engine.ListerWaitDuration, err = meter.Int64Histogram(
+		"index-provider/engine/lister_wait_duration",
+		metric.WithUnit("ms"),
+		metric.WithDescription("The time spent waiting for the multihash lister while generating entries in milliseconds"),
+	); err != nil {
+		panic(err)
+	}
+	if Engine.EntriesCacheLookup, err = meter.Int64Counter(
+		"index-provider/engine/entries_cache_lookups",
+		metric.WithUnit("{lookup}"),
+		metric.WithDescription("The number of entries chunk lookups in the entries cache, by result"),
+	); err != nil {
+		panic(err)
+	}
+	if Engine.EntriesMemoryCacheLookup, err = meter.Int64Counter(
+		"index-provider/engine/entries_memory_cache_lookups",
+		metric.WithUnit("{lookup}"),
+		metric.WithDescription("The number of entries chunk lookups in the in-memory cache of served chunks, by result"),
+	); err != nil {
+		panic(err)
+	}
+	if Engine.EntriesMemoryCacheSize, err = meter.Int64UpDownCounter(
+		"index-provider/engine/entries_memory_cache_size",
+		metric.WithUnit("By"),
+		metric.WithDescription("The total size of the entries chunks held in the in-memory cache of served chunks"),
+	); err != nil {
+		panic(err)
+	}
+	if Engine.BlocksServed, err = meter.Int64Counter(
+		"index-provider/engine/blocks_served",
+		metric.WithUnit("{block}"),
+		metric.WithDescription("The number of blocks served to syncing indexers, by kind"),
+	); err != nil {
+		panic(err)
+	}
+	if Engine.Multihashes, err = meter.Int64UpDownCounter(
+		"index-provider/engine/multihashes",
+		metric.WithUnit("{multihash}"),
+		metric.WithDescription("The number of multihashes currently advertised"),
+	); err != nil {
+		panic(err)
+	}
+}
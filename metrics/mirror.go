@@ -7,6 +7,7 @@ import (
 var Mirror struct {
 	SyncDuration    metric.Int64Histogram
 	ProcessDuration metric.Int64Histogram
+	Lag             metric.Int64UpDownCounter
 }
 
 func init() {
@@ -25,4 +26,11 @@ func init() {
 	); err != nil {
 		panic(err)
 	}
+	if Mirror.Lag, err = meter.Int64UpDownCounter(
+		"index-provider/mirror/lag",
+		metric.WithUnit("{advertisement}"),
+		metric.WithDescription("The number of advertisements found at the source that are yet to be mirrored"),
+	); err != nil {
+		panic(err)
+	}
 }
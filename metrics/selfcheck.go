@@ -0,0 +1,20 @@
+package metrics
+
+import (
+	"go.opentelemetry.io/otel/metric"
+)
+
+var SelfCheck struct {
+	Retrievals metric.Int64Counter
+}
+
+func init() {
+	var err error
+	if SelfCheck.Retrievals, err = meter.Int64Counter(
+		"index-provider/selfcheck/retrievals",
+		metric.WithUnit("{retrieval}"),
+		metric.WithDescription("The number of retrievals of advertised content attempted by the self-check, by protocol and status"),
+	); err != nil {
+		panic(err)
+	}
+}
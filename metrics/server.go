@@ -15,31 +15,68 @@ import (
 
 var log = logging.Logger("provider/metrics")
 
+// Exporter exposes the collected metrics as Prometheus metrics via its
+// http.Handler.
+type Exporter struct {
+	exporter *otelprom.Exporter
+	handler  http.Handler
+}
+
 type Server struct {
-	exporter   *otelprom.Exporter
+	exporter   *Exporter
 	httpserver http.Server
 	listen     net.Listener
 }
 
+// NewExporter instantiates a new exporter and sets it as the global meter
+// provider, so that metrics are collected from then on. Only one exporter
+// should be instantiated.
+func NewExporter() (*Exporter, error) {
+	// Create Prometheus Exporter and register its Collector.
+	exporter, err := otelprom.New()
+	if err != nil {
+		return nil, err
+	}
+	provider := metric.NewMeterProvider(metric.WithReader(exporter))
+	otel.SetMeterProvider(provider)
+
+	return &Exporter{
+		exporter: exporter,
+		handler: promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{
+			EnableOpenMetrics: true,
+		}),
+	}, nil
+}
+
+// ServeHTTP serves the collected metrics in Prometheus format.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.handler.ServeHTTP(w, r)
+}
+
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	return e.exporter.Shutdown(ctx)
+}
+
 // NewServer instantiates a new server that upon start exposes the collected metrics as Prometheus
 // metrics.
 func NewServer(listenAddr string) (*Server, error) {
-	listen, err := net.Listen("tcp", listenAddr)
+	exporter, err := NewExporter()
 	if err != nil {
 		return nil, err
 	}
-	// Create Prometheus Exporter and register its Collector.
-	exporter, err := otelprom.New()
+	return NewServerWithExporter(listenAddr, exporter)
+}
+
+// NewServerWithExporter instantiates a new server that upon start exposes the
+// metrics of the given exporter at /metrics.
+func NewServerWithExporter(listenAddr string, exporter *Exporter) (*Server, error) {
+	listen, err := net.Listen("tcp", listenAddr)
 	if err != nil {
 		return nil, err
 	}
-	provider := metric.NewMeterProvider(metric.WithReader(exporter))
-	otel.SetMeterProvider(provider)
 
 	mux := http.NewServeMux()
-	mux.Handle("/metrics", promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{
-		EnableOpenMetrics: true,
-	}))
+	mux.Handle("/metrics", exporter)
 	return &Server{
 		exporter: exporter,
 		httpserver: http.Server{
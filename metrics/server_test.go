@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+func TestExporter(t *testing.T) {
+	ctx := context.Background()
+	subject, err := NewExporter()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = subject.Shutdown(ctx) })
+
+	// Instruments created before the exporter report to it.
+	Engine.AdsPublished.Add(ctx, 2, metric.WithAttributeSet(attribute.NewSet(Attributes.AdKindPut)))
+	Engine.Announces.Add(ctx, 1, metric.WithAttributeSet(attribute.NewSet(Attributes.StatusFailure)))
+
+	server := httptest.NewServer(subject)
+	t.Cleanup(server.Close)
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Regexp(t, `index_provider_engine_ads_published_total{.*kind="put".*} 2`, string(body))
+	require.Regexp(t, `index_provider_engine_announces_total{.*status="failure".*} 1`, string(body))
+}
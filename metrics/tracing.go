@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracer records the spans of the publishing of advertisements, from the
+// request to publish down to the announcement of the advertisement. Spans are
+// dropped unless a TraceExporter is instantiated.
+var Tracer trace.Tracer = otel.Tracer("index-provider")
+
+// TraceExporter exports the recorded spans to an OpenTelemetry collector via
+// OTLP over HTTP.
+type TraceExporter struct {
+	provider *sdktrace.TracerProvider
+}
+
+// NewTraceExporter instantiates a new trace exporter and sets it as the global
+// tracer provider, so that spans are recorded from then on. It also sets the
+// W3C trace context as the global propagator, so that traces are continued
+// across HTTP requests.
+//
+// Spans are exported to the given OTLP/HTTP traces endpoint URL, such as
+// http://localhost:4318/v1/traces. If endpoint is empty, the standard
+// OTEL_EXPORTER_OTLP_ENDPOINT and OTEL_EXPORTER_OTLP_TRACES_ENDPOINT
+// environment variables are used instead. The sampleRatio is the fraction of
+// traces that are sampled, between 0 and 1. Only one exporter should be
+// instantiated.
+func NewTraceExporter(ctx context.Context, endpoint string, sampleRatio float64) (*TraceExporter, error) {
+	var opts []otlptracehttp.Option
+	if endpoint != "" {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("bad traces endpoint: %w", err)
+		}
+		switch u.Scheme {
+		case "http":
+			opts = append(opts, otlptracehttp.WithInsecure())
+		case "https":
+		default:
+			return nil, fmt.Errorf("bad traces endpoint: unsupported scheme %q", u.Scheme)
+		}
+		opts = append(opts, otlptracehttp.WithEndpoint(u.Host))
+		if u.Path != "" {
+			opts = append(opts, otlptracehttp.WithURLPath(u.Path))
+		}
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	res := resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName("index-provider"))
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return &TraceExporter{provider: provider}, nil
+}
+
+// Shutdown exports the spans recorded so far and stops exporting.
+func (e *TraceExporter) Shutdown(ctx context.Context) error {
+	return e.provider.Shutdown(ctx)
+}
+
+// EndSpan ends the given span, setting its status to error if err is not nil.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
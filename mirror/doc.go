@@ -18,6 +18,11 @@
 // original PreviousID link, even though the content corresponding to that link will not be hosted
 // by the mirror.
 //
+// The progress of a Mirror is persisted in its datastore after each mirrored advertisement. When
+// restarted with the same datastore, a Mirror immediately resumes serving the previously mirrored
+// chain and continues mirroring from the latest original advertisement it processed. The number of
+// advertisements that are yet to be mirrored is reported via Mirror.Status and as a metric.
+//
 // Note that mirroring advertisements is one-to-one: for each original advertisement there will be
 // a mirrored one. This is not affected by optional remapping of entries. Future work will provide
 // the ability to also remap advertisements in addition to entries.
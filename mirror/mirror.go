@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sync"
 	"time"
 
 	"github.com/ipfs/go-cid"
@@ -44,6 +45,23 @@ type Mirror struct {
 	chunker *chunker.CachedEntriesChunker
 	cancel  context.CancelFunc
 	senders []announce.Sender
+
+	statusLock sync.Mutex
+	status     Status
+}
+
+// Status describes the progress of a Mirror relative to its source.
+type Status struct {
+	// LatestOriginalAdCid is the CID of the latest source advertisement processed by the mirror.
+	LatestOriginalAdCid cid.Cid
+	// LatestMirroredAdCid is the CID of the latest advertisement published by the mirror.
+	LatestMirroredAdCid cid.Cid
+	// Lag is the number of advertisements found at the source during the last check that are
+	// yet to be mirrored.
+	Lag int
+	// LastCheck is the time at which the source was last successfully checked for new
+	// advertisements.
+	LastCheck time.Time
 }
 
 // New instantiates a new Mirror that mirrors ad chain from the given source provider.
@@ -102,70 +120,127 @@ func New(ctx context.Context, source peer.AddrInfo, o ...Option) (*Mirror, error
 	return m, nil
 }
 
+// Start starts mirroring the source advertisement chain. Mirroring resumes from the state
+// persisted in the datastore, if any, and the source is checked for new advertisements right away
+// and then at every sync interval.
 func (m *Mirror) Start() error {
 	ctx, cancel := context.WithCancel(context.Background())
 	m.cancel = cancel
 
+	latestMirrored, err := m.getLatestMirroredAdCid(ctx)
+	if err != nil {
+		cancel()
+		return err
+	}
+	latestOriginal, err := m.getLatestOriginalAdCid(ctx)
+	if err != nil {
+		cancel()
+		return err
+	}
+	// Serve the previously mirrored chain right away so that the mirror is useful even before the
+	// source is reachable.
+	if !cid.Undef.Equals(latestMirrored) {
+		m.pub.SetRoot(latestMirrored)
+		log.Infow("Resuming mirror", "latestOriginalAdCid", latestOriginal, "latestMirroredAdCid", latestMirrored)
+	}
+	m.statusLock.Lock()
+	m.status.LatestOriginalAdCid = latestOriginal
+	m.status.LatestMirroredAdCid = latestMirrored
+	m.statusLock.Unlock()
+
 	go func() {
 		ticker := time.NewTicker(m.syncInterval)
 		defer ticker.Stop()
 		for {
-			var t time.Time
+			m.check(ctx)
 			select {
-			case t = <-ticker.C:
+			case <-ticker.C:
 			case <-ctx.Done():
 				return
 			}
-			log := log.With("time", t)
-			log.Info("checking for new advertisements")
-			mc, err := m.getLatestOriginalAdCid(ctx)
+		}
+	}()
 
-			if err != nil {
-				log.Errorw("failed to get the latest mirrored cid", "err", err)
-				continue
-			}
-			log = log.With("latestMirroredCid", mc)
-
-			var depthLimit int64
-			var stopAtCid cid.Cid
-			if cid.Undef.Equals(mc) {
-				depthLimit = m.initAdRecurLimit
-			} else {
-				stopAtCid = mc
-			}
+	return nil
+}
 
-			syncedAdCids, err := m.syncAds(ctx, stopAtCid, depthLimit)
-			if err != nil {
-				log.Errorw("Failed to sync source", "err", err)
-				continue
-			}
+// Status returns the current progress of the mirror relative to its source.
+func (m *Mirror) Status() Status {
+	m.statusLock.Lock()
+	defer m.statusLock.Unlock()
+	return m.status
+}
 
-			for _, adCid := range syncedAdCids {
-				start := time.Now()
-				err := m.mirror(ctx, adCid)
-				elapsed := time.Since(start)
-				attr := metrics.Attributes.StatusSuccess
-				if err != nil {
-					attr = metrics.Attributes.StatusFailure
-					log.Errorw("Failed to mirror ad", "cid", adCid, "err", err)
-					// TODO add an option on what to do if the mirroring of an ad failed?
-					// TODO codify the errors and use the error code as an additional attribute in metrics.
-				}
-				metrics.Mirror.ProcessDuration.Record(ctx, elapsed.Milliseconds(), metric.WithAttributeSet(attribute.NewSet(attr)))
-			}
+// check syncs any new advertisements from the source and mirrors them.
+func (m *Mirror) check(ctx context.Context) {
+	log := log.With("time", time.Now())
+	log.Info("checking for new advertisements")
+	mc, err := m.getLatestOriginalAdCid(ctx)
+	if err != nil {
+		log.Errorw("failed to get the latest mirrored cid", "err", err)
+		return
+	}
+	log = log.With("latestMirroredCid", mc)
 
-			syncedCount := len(syncedAdCids)
-			if syncedCount > 0 {
-				latestOriginal := syncedAdCids[syncedCount-1]
-				err = m.setLatestOriginalAdCid(ctx, latestOriginal)
-				if err != nil {
-					log.Errorw("Failed to store latest original ad cid", "cid", latestOriginal, "err", err)
-				}
-			}
+	var depthLimit int64
+	var stopAtCid cid.Cid
+	if cid.Undef.Equals(mc) {
+		depthLimit = m.initAdRecurLimit
+	} else {
+		stopAtCid = mc
+	}
+
+	syncedAdCids, err := m.syncAds(ctx, stopAtCid, depthLimit)
+	if err != nil {
+		log.Errorw("Failed to sync source", "err", err)
+		return
+	}
+
+	lag := len(syncedAdCids)
+	m.setLag(ctx, lag)
+	m.statusLock.Lock()
+	m.status.LastCheck = time.Now()
+	m.statusLock.Unlock()
+	log.Infow("Mirror lag", "lag", lag)
+
+	for _, adCid := range syncedAdCids {
+		start := time.Now()
+		err := m.mirror(ctx, adCid)
+		elapsed := time.Since(start)
+		attr := metrics.Attributes.StatusSuccess
+		if err != nil {
+			attr = metrics.Attributes.StatusFailure
+			log.Errorw("Failed to mirror ad", "cid", adCid, "err", err)
+			// TODO add an option on what to do if the mirroring of an ad failed?
+			// TODO codify the errors and use the error code as an additional attribute in metrics.
+		}
+		metrics.Mirror.ProcessDuration.Record(ctx, elapsed.Milliseconds(), metric.WithAttributeSet(attribute.NewSet(attr)))
+		if ctx.Err() != nil {
+			// Shutting down; leave the ad to be mirrored again once resumed.
+			return
 		}
-	}()
 
-	return nil
+		// Persist progress after each ad, so that mirroring resumes from where it left off
+		// if interrupted.
+		if err = m.setLatestOriginalAdCid(ctx, adCid); err != nil {
+			log.Errorw("Failed to store latest original ad cid", "cid", adCid, "err", err)
+			return
+		}
+		lag--
+		m.setLag(ctx, lag)
+		m.statusLock.Lock()
+		m.status.LatestOriginalAdCid = adCid
+		m.statusLock.Unlock()
+	}
+}
+
+// setLag records the number of advertisements synced from the source that are yet to be
+// mirrored.
+func (m *Mirror) setLag(ctx context.Context, lag int) {
+	m.statusLock.Lock()
+	defer m.statusLock.Unlock()
+	metrics.Mirror.Lag.Add(ctx, int64(lag-m.status.Lag))
+	m.status.Lag = lag
 }
 
 func (m *Mirror) Shutdown() error {
@@ -270,6 +345,9 @@ func (m *Mirror) mirror(ctx context.Context, adCid cid.Cid) error {
 	if err = m.setLatestMirroredAdCid(ctx, mirroredAdCid); err != nil {
 		return err
 	}
+	m.statusLock.Lock()
+	m.status.LatestMirroredAdCid = mirroredAdCid
+	m.statusLock.Unlock()
 
 	m.pub.SetRoot(mirroredAdCid)
 	if err = announce.Send(ctx, mirroredAdCid, m.pub.Addrs(), m.senders...); err != nil {
@@ -390,7 +468,7 @@ func (m *Mirror) syncAds(ctx context.Context, stopAtCid cid.Cid, depthLimit int6
 	}
 	startSync := time.Now()
 	var syncedAdCids []cid.Cid
-	_, err := m.sub.SyncAdChain(ctx, m.source, dagsync.WithHeadAdCid(stopAtCid), dagsync.ScopedDepthLimit(depthLimit),
+	_, err := m.sub.SyncAdChain(ctx, m.source, dagsync.WithStopAdCid(stopAtCid), dagsync.ScopedDepthLimit(depthLimit),
 		dagsync.ScopedBlockHook(func(id peer.ID, c cid.Cid, actions dagsync.SegmentSyncActions) {
 			// TODO: set actions next segment link to ad previous id if it is present. For
 			//      now segmentation is disabled.
@@ -52,17 +52,18 @@ func (te *testEnv) startMirror(t *testing.T, ctx context.Context, opts ...mirror
 	opts = append(opts, mirror.WithHost(te.mirrorHost, privKey))
 	te.mirror, err = mirror.New(ctx, te.sourceAddrInfo(t), opts...)
 	require.NoError(t, err)
-	require.NoError(t, te.mirror.Start())
-	t.Cleanup(func() { require.NoError(t, te.mirror.Shutdown()) })
+	m := te.mirror
+	require.NoError(t, m.Start())
+	t.Cleanup(func() { require.NoError(t, m.Shutdown()) })
 
 	te.mirrorSyncLsStore = &memstore.Store{}
 	te.mirrorSyncLs = cidlink.DefaultLinkSystem()
 	te.mirrorSyncLs.SetReadStorage(te.mirrorSyncLsStore)
 	te.mirrorSyncLs.SetWriteStorage(te.mirrorSyncLsStore)
 
-	te.mirrorSync = ipnisync.NewSync(te.mirrorSyncLs, nil)
-	require.NoError(t, err)
-	t.Cleanup(func() { te.mirrorSync.Close() })
+	mirrorSync := ipnisync.NewSync(te.mirrorSyncLs, nil)
+	te.mirrorSync = mirrorSync
+	t.Cleanup(func() { mirrorSync.Close() })
 	pubInfo := peer.AddrInfo{
 		ID:    te.mirrorHost.ID(),
 		Addrs: te.mirror.PublisherAddrs(),
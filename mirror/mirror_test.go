@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
 	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
 	selectorparse "github.com/ipld/go-ipld-prime/traversal/selector/parse"
 	"github.com/ipni/go-libipni/ingest/schema"
@@ -311,3 +313,48 @@ func TestMirror_MirrorsAdsIdenticallyWhenConfiguredTo(t *testing.T) {
 	// verified against the content.
 	te.requireAdChainMirroredRecursively(t, ctx, originalHeadCid, gotMirroredHeadAdCid)
 }
+
+func TestMirror_MirrorsNewAdsAndResumes(t *testing.T) {
+	ctx := newTestContext(t)
+	md := metadata.Default.New(metadata.Bitswap{})
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+
+	te := &testEnv{}
+	te.startSource(t, ctx, engine.WithPublisherKind(engine.Libp2pPublisher))
+	ad1 := te.putAdOnSource(t, ctx, []byte("ad1"), test.RandomMultihashes(1), md)
+
+	te.startMirror(t, ctx, mirror.WithSyncInterval(time.Second), mirror.WithDatastore(ds))
+	mirroredHead := te.requireEventuallyMirrored(t, ctx, ad1)
+	status := te.mirror.Status()
+	require.Equal(t, ad1, status.LatestOriginalAdCid)
+	require.Equal(t, mirroredHead, status.LatestMirroredAdCid)
+	require.Zero(t, status.Lag)
+	require.False(t, status.LastCheck.IsZero())
+
+	// Assert that ads published after the initial sync are mirrored too.
+	ad2 := te.putAdOnSource(t, ctx, []byte("ad2"), test.RandomMultihashes(2), md)
+	te.requireEventuallyMirrored(t, ctx, ad2)
+	require.NoError(t, te.mirror.Shutdown())
+
+	// Restart the mirror with the same datastore and assert that the previously mirrored chain
+	// is served and that mirroring resumes without duplicating any ads.
+	ad3 := te.putAdOnSource(t, ctx, []byte("ad3"), test.RandomMultihashes(3), md)
+	te.startMirror(t, ctx, mirror.WithSyncInterval(time.Second), mirror.WithDatastore(ds))
+	te.requireEventuallyMirrored(t, ctx, ad3)
+}
+
+// requireEventuallyMirrored waits until the mirror head corresponds to the given original ad, and
+// asserts that the chain is mirrored as expected.
+func (te *testEnv) requireEventuallyMirrored(t *testing.T, ctx context.Context, originalAdCid cid.Cid) cid.Cid {
+	var gotMirroredHeadAdCid cid.Cid
+	var err error
+	require.Eventually(t, func() bool {
+		gotMirroredHeadAdCid, err = te.mirrorSyncer.GetHead(ctx)
+		if err != nil || cid.Undef.Equals(gotMirroredHeadAdCid) {
+			return false
+		}
+		return te.mirror.Status().LatestOriginalAdCid.Equals(originalAdCid)
+	}, testEventualTimeout, testCheckInterval, "err: %v", err)
+	te.requireAdChainMirroredRecursively(t, ctx, originalAdCid, gotMirroredHeadAdCid)
+	return gotMirroredHeadAdCid
+}
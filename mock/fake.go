@@ -0,0 +1,304 @@
+package mock_provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/storage/memstore"
+	"github.com/ipni/go-libipni/ingest/schema"
+	"github.com/ipni/go-libipni/metadata"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine/chunker"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/multiformats/go-multihash"
+)
+
+// ErrShutdown is returned by the methods of a Fake that is shut down.
+var ErrShutdown = errors.New("provider is shut down")
+
+// Fake is an in-memory implementation of provider.Interface for unit testing
+// code that publishes advertisements, without a datastore or libp2p host.
+// Unlike MockInterface, it needs no expectations to be set: it keeps the
+// advertised content and the chain of advertisements in memory, and lists the
+// multihashes of put content with the registered provider.MultihashLister, so
+// that tests can check what was advertised.
+//
+// Advertisements are linked into a chain but not signed, nor announced.
+type Fake struct {
+	// ProviderID and Addrs are the default provider assumed when none is
+	// given to NotifyPut or NotifyRemove.
+	ProviderID peer.ID
+	Addrs      []multiaddr.Multiaddr
+
+	mu       sync.Mutex
+	lsys     ipld.LinkSystem
+	lister   provider.MultihashLister
+	head     cid.Cid
+	ads      map[cid.Cid]*schema.Advertisement
+	contexts map[string]*FakeContent
+	shutdown bool
+}
+
+// FakeContent is the content currently advertised under a context ID by a
+// Fake.
+type FakeContent struct {
+	Provider    peer.ID
+	ContextID   []byte
+	Metadata    metadata.Metadata
+	Multihashes []multihash.Multihash
+}
+
+var _ provider.Interface = (*Fake)(nil)
+
+// NewFake instantiates a Fake that advertises content on behalf of the given
+// default provider.
+func NewFake(providerID peer.ID, addrs ...multiaddr.Multiaddr) *Fake {
+	lsys := cidlink.DefaultLinkSystem()
+	store := &memstore.Store{}
+	lsys.SetReadStorage(store)
+	lsys.SetWriteStorage(store)
+	return &Fake{
+		ProviderID: providerID,
+		Addrs:      addrs,
+		lsys:       lsys,
+		ads:        make(map[cid.Cid]*schema.Advertisement),
+		contexts:   make(map[string]*FakeContent),
+	}
+}
+
+// PublishLocal appends the advertisement to the chain as is.
+func (f *Fake) PublishLocal(ctx context.Context, adv schema.Advertisement) (cid.Cid, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.shutdown {
+		return cid.Undef, ErrShutdown
+	}
+	return f.store(ctx, adv)
+}
+
+// Publish appends the advertisement to the chain as is, like PublishLocal.
+func (f *Fake) Publish(ctx context.Context, adv schema.Advertisement) (cid.Cid, error) {
+	return f.PublishLocal(ctx, adv)
+}
+
+// RegisterMultihashLister registers the lister used by NotifyPut.
+func (f *Fake) RegisterMultihashLister(mhl provider.MultihashLister) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lister = mhl
+}
+
+// NotifyPut lists the multihashes of the context ID, leaving out those that the
+// lister reports as skipped, and appends an advertisement of them to the
+// chain, as provider.Interface specifies.
+func (f *Fake) NotifyPut(ctx context.Context, p *peer.AddrInfo, contextID []byte, md metadata.Metadata) (cid.Cid, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.shutdown {
+		return cid.Undef, ErrShutdown
+	}
+	providerID, addrs := f.ProviderID, f.Addrs
+	if p != nil {
+		providerID, addrs = p.ID, p.Addrs
+	}
+	key := contentKey(providerID, contextID)
+	var mhs []multihash.Multihash
+	if content, ok := f.contexts[key]; ok {
+		if md.Equal(content.Metadata) {
+			return cid.Undef, provider.ErrAlreadyAdvertised
+		}
+		mhs = content.Multihashes
+	} else {
+		if f.lister == nil {
+			return cid.Undef, provider.ErrNoMultihashLister
+		}
+		mhIter, err := f.lister(ctx, providerID, contextID)
+		if err != nil {
+			return cid.Undef, err
+		}
+		for {
+			mh, err := mhIter.Next()
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if errors.Is(err, provider.ErrMultihashSkipped) {
+					continue
+				}
+				return cid.Undef, err
+			}
+			mhs = append(mhs, mh)
+		}
+	}
+
+	entries, err := f.chunk(mhs)
+	if err != nil {
+		return cid.Undef, err
+	}
+	mdBytes, err := md.MarshalBinary()
+	if err != nil {
+		return cid.Undef, err
+	}
+	adv := schema.Advertisement{
+		Provider:  providerID.String(),
+		Entries:   entries,
+		ContextID: contextID,
+		Metadata:  mdBytes,
+	}
+	for _, addr := range addrs {
+		adv.Addresses = append(adv.Addresses, addr.String())
+	}
+	c, err := f.store(ctx, adv)
+	if err != nil {
+		return cid.Undef, err
+	}
+	f.contexts[key] = &FakeContent{Provider: providerID, ContextID: contextID, Metadata: md, Multihashes: mhs}
+	return c, nil
+}
+
+// NotifyRemove appends an advertisement that removes the content of the
+// context ID to the chain, as provider.Interface specifies.
+func (f *Fake) NotifyRemove(ctx context.Context, providerID peer.ID, contextID []byte) (cid.Cid, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.shutdown {
+		return cid.Undef, ErrShutdown
+	}
+	if providerID == "" {
+		providerID = f.ProviderID
+	}
+	key := contentKey(providerID, contextID)
+	if _, ok := f.contexts[key]; !ok {
+		return cid.Undef, provider.ErrContextIDNotFound
+	}
+	md := metadata.Default.New()
+	mdBytes, err := md.MarshalBinary()
+	if err != nil {
+		return cid.Undef, err
+	}
+	c, err := f.store(ctx, schema.Advertisement{
+		Provider:  providerID.String(),
+		Entries:   schema.NoEntries,
+		ContextID: contextID,
+		Metadata:  mdBytes,
+		IsRm:      true,
+	})
+	if err != nil {
+		return cid.Undef, err
+	}
+	delete(f.contexts, key)
+	return c, nil
+}
+
+// GetAdv gets the advertisement with the given CID.
+func (f *Fake) GetAdv(_ context.Context, c cid.Cid) (*schema.Advertisement, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	adv, ok := f.ads[c]
+	if !ok {
+		return nil, fmt.Errorf("advertisement %s not found", c)
+	}
+	return adv, nil
+}
+
+// GetLatestAdv gets the latest advertisement in the chain, or cid.Undef and
+// nil if none has been published.
+func (f *Fake) GetLatestAdv(context.Context) (cid.Cid, *schema.Advertisement, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.head == cid.Undef {
+		return cid.Undef, nil, nil
+	}
+	return f.head, f.ads[f.head], nil
+}
+
+// Shutdown shuts down the fake, after which its methods fail with
+// ErrShutdown.
+func (f *Fake) Shutdown() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.shutdown = true
+	return nil
+}
+
+// Content returns the content currently advertised by the given provider
+// under the given context ID, or nil if none is. The default provider is
+// assumed if providerID is empty.
+func (f *Fake) Content(providerID peer.ID, contextID []byte) *FakeContent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if providerID == "" {
+		providerID = f.ProviderID
+	}
+	return f.contexts[contentKey(providerID, contextID)]
+}
+
+// Chain returns the advertisements published so far, from the earliest to
+// the latest.
+func (f *Fake) Chain() []*schema.Advertisement {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var chain []*schema.Advertisement
+	for c := f.head; c != cid.Undef; {
+		adv, ok := f.ads[c]
+		if !ok {
+			break
+		}
+		chain = append(chain, adv)
+		c = adv.PreviousCid()
+	}
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain
+}
+
+// LinkSystem returns the link system that stores the advertisements and their
+// entries.
+func (f *Fake) LinkSystem() *ipld.LinkSystem {
+	return &f.lsys
+}
+
+// store links the advertisement to the head of the chain, if it is not linked
+// yet, and makes it the new head. The caller must hold mu.
+func (f *Fake) store(ctx context.Context, adv schema.Advertisement) (cid.Cid, error) {
+	if adv.PreviousID == nil && f.head != cid.Undef {
+		adv.PreviousID = cidlink.Link{Cid: f.head}
+	}
+	node, err := adv.ToNode()
+	if err != nil {
+		return cid.Undef, err
+	}
+	lnk, err := f.lsys.Store(ipld.LinkContext{Ctx: ctx}, schema.Linkproto, node)
+	if err != nil {
+		return cid.Undef, err
+	}
+	c := lnk.(cidlink.Link).Cid
+	f.ads[c] = &adv
+	f.head = c
+	return c, nil
+}
+
+// chunk stores the multihashes as a chain of entry chunks, and returns the
+// link to it. The caller must hold mu.
+func (f *Fake) chunk(mhs []multihash.Multihash) (ipld.Link, error) {
+	if len(mhs) == 0 {
+		return schema.NoEntries, nil
+	}
+	entriesChunker, err := chunker.NewChainChunker(&f.lsys, 16384)
+	if err != nil {
+		return nil, err
+	}
+	return entriesChunker.Chunk(context.Background(), provider.SliceMultihashIterator(mhs))
+}
+
+func contentKey(providerID peer.ID, contextID []byte) string {
+	return string(providerID) + "/" + string(contextID)
+}
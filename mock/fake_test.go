@@ -0,0 +1,69 @@
+package mock_provider_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipld/go-ipld-prime"
+	"github.com/ipni/go-libipni/ingest/schema"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	mock_provider "github.com/ipni/index-provider/mock"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFake(t *testing.T) {
+	ctx := context.Background()
+	providerID, _, _ := test.RandomIdentity()
+	addr := multiaddr.StringCast("/ip4/192.0.2.1/tcp/3103")
+	subject := mock_provider.NewFake(providerID, addr)
+	md := metadata.Default.New(metadata.Bitswap{})
+
+	_, err := subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.ErrorIs(t, err, provider.ErrNoMultihashLister)
+
+	mhs := test.RandomMultihashes(3)
+	subject.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(mhs), nil
+	})
+	putCid, err := subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.NoError(t, err)
+	_, err = subject.NotifyPut(ctx, nil, []byte("fish"), md)
+	require.ErrorIs(t, err, provider.ErrAlreadyAdvertised)
+	content := subject.Content("", []byte("fish"))
+	require.NotNil(t, content)
+	require.Equal(t, mhs, content.Multihashes)
+	require.True(t, md.Equal(content.Metadata))
+
+	ad, err := subject.GetAdv(ctx, putCid)
+	require.NoError(t, err)
+	require.Equal(t, providerID.String(), ad.Provider)
+	require.Equal(t, []string{addr.String()}, ad.Addresses)
+	n, err := subject.LinkSystem().Load(ipld.LinkContext{Ctx: ctx}, ad.Entries, schema.EntryChunkPrototype)
+	require.NoError(t, err)
+	chunk, err := schema.UnwrapEntryChunk(n)
+	require.NoError(t, err)
+	require.Equal(t, mhs, chunk.Entries)
+
+	_, err = subject.NotifyRemove(ctx, "", []byte("lobster"))
+	require.ErrorIs(t, err, provider.ErrContextIDNotFound)
+	rmCid, err := subject.NotifyRemove(ctx, "", []byte("fish"))
+	require.NoError(t, err)
+	require.Nil(t, subject.Content("", []byte("fish")))
+	head, latest, err := subject.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	require.Equal(t, rmCid, head)
+	require.True(t, latest.IsRm)
+	require.Equal(t, putCid, latest.PreviousCid())
+
+	chain := subject.Chain()
+	require.Len(t, chain, 2)
+	require.Equal(t, ad, chain[0])
+
+	require.NoError(t, subject.Shutdown())
+	_, err = subject.NotifyPut(ctx, nil, []byte("lobster"), md)
+	require.ErrorIs(t, err, mock_provider.ErrShutdown)
+}
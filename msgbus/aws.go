@@ -0,0 +1,284 @@
+package msgbus
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	sqsAPIVersion = "2012-11-05"
+	snsAPIVersion = "2010-03-31"
+
+	// fifoMessageGroupID is the group of the messages published to FIFO SQS
+	// queues and SNS topics, which keeps announcements in order.
+	fifoMessageGroupID = "ipni-announce"
+)
+
+// AWSConfig configures the access to AWS services.
+type AWSConfig struct {
+	// Region is the AWS region of the service, e.g. "us-east-1". If empty,
+	// the region is taken from the queue URL or topic ARN.
+	Region string
+	// AccessKeyID, SecretAccessKey and SessionToken are the credentials with
+	// which requests are signed. SessionToken is only needed for temporary
+	// credentials.
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Endpoint, if set, overrides the endpoint of SNS, e.g. to use LocalStack.
+	// SQS requests are sent to the queue URL.
+	Endpoint string
+	// HTTPClient is the client with which requests are sent. If nil,
+	// http.DefaultClient is used.
+	HTTPClient *http.Client
+}
+
+// AWSConfigFromEnv returns the AWS configuration given by the standard
+// environment variables AWS_REGION, or else AWS_DEFAULT_REGION,
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN and
+// AWS_ENDPOINT_URL.
+func AWSConfigFromEnv() AWSConfig {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	return AWSConfig{
+		Region:          region,
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		Endpoint:        os.Getenv("AWS_ENDPOINT_URL"),
+	}
+}
+
+// SQSPublisher publishes messages to an AWS SQS queue.
+type SQSPublisher struct {
+	cfg      AWSConfig
+	queueURL string
+	fifo     bool
+}
+
+// NewSQSPublisher creates a publisher that sends messages to the SQS queue
+// with the given URL, e.g.
+// "https://sqs.us-east-1.amazonaws.com/123456789012/announce". Messages sent
+// to FIFO queues are all in the same message group, so that they are received
+// in order, and are deduplicated by content.
+func NewSQSPublisher(queueURL string, cfg AWSConfig) (*SQSPublisher, error) {
+	u, err := url.Parse(queueURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SQS queue URL: %w", err)
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return nil, fmt.Errorf("invalid SQS queue URL %q: must be an http or https URL", queueURL)
+	}
+	if cfg.Region == "" {
+		// The host is "sqs.<region>.amazonaws.com".
+		parts := strings.Split(u.Hostname(), ".")
+		if len(parts) < 3 || parts[0] != "sqs" {
+			return nil, fmt.Errorf("cannot determine the region of SQS queue %q; set it explicitly", queueURL)
+		}
+		cfg.Region = parts[1]
+	}
+	if err = cfg.checkCredentials(); err != nil {
+		return nil, err
+	}
+	return &SQSPublisher{
+		cfg:      cfg,
+		queueURL: queueURL,
+		fifo:     strings.HasSuffix(u.Path, ".fifo"),
+	}, nil
+}
+
+// Publish sends the given message to the queue.
+func (p *SQSPublisher) Publish(ctx context.Context, data []byte) error {
+	form := url.Values{
+		"Action":      {"SendMessage"},
+		"Version":     {sqsAPIVersion},
+		"MessageBody": {string(data)},
+	}
+	if p.fifo {
+		form.Set("MessageGroupId", fifoMessageGroupID)
+		form.Set("MessageDeduplicationId", sha256Hex(data))
+	}
+	return p.cfg.post(ctx, "sqs", p.queueURL, form)
+}
+
+// Close does nothing, since requests are not sent over a connection of the
+// publisher.
+func (p *SQSPublisher) Close() error { return nil }
+
+func (p *SQSPublisher) String() string {
+	return "sqs:" + p.queueURL
+}
+
+// SNSPublisher publishes messages to an AWS SNS topic.
+type SNSPublisher struct {
+	cfg      AWSConfig
+	topicARN string
+	endpoint string
+	fifo     bool
+}
+
+// NewSNSPublisher creates a publisher that publishes messages to the SNS
+// topic with the given ARN, e.g. "arn:aws:sns:us-east-1:123456789012:announce".
+// Messages published to FIFO topics are all in the same message group, so that
+// they are received in order, and are deduplicated by content.
+func NewSNSPublisher(topicARN string, cfg AWSConfig) (*SNSPublisher, error) {
+	// The ARN is "arn:aws:sns:<region>:<account>:<topic>".
+	parts := strings.Split(topicARN, ":")
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != "sns" {
+		return nil, fmt.Errorf("invalid SNS topic ARN %q", topicARN)
+	}
+	if cfg.Region == "" {
+		cfg.Region = parts[3]
+	}
+	if err := cfg.checkCredentials(); err != nil {
+		return nil, err
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://sns." + cfg.Region + ".amazonaws.com/"
+	}
+	return &SNSPublisher{
+		cfg:      cfg,
+		topicARN: topicARN,
+		endpoint: endpoint,
+		fifo:     strings.HasSuffix(topicARN, ".fifo"),
+	}, nil
+}
+
+// Publish publishes the given message to the topic.
+func (p *SNSPublisher) Publish(ctx context.Context, data []byte) error {
+	form := url.Values{
+		"Action":   {"Publish"},
+		"Version":  {snsAPIVersion},
+		"TopicArn": {p.topicARN},
+		"Message":  {string(data)},
+	}
+	if p.fifo {
+		form.Set("MessageGroupId", fifoMessageGroupID)
+		form.Set("MessageDeduplicationId", sha256Hex(data))
+	}
+	return p.cfg.post(ctx, "sns", p.endpoint, form)
+}
+
+// Close does nothing, since requests are not sent over a connection of the
+// publisher.
+func (p *SNSPublisher) Close() error { return nil }
+
+func (p *SNSPublisher) String() string {
+	return "sns:" + p.topicARN
+}
+
+func (c *AWSConfig) checkCredentials() error {
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return errors.New("AWS access key ID and secret access key must be set")
+	}
+	return nil
+}
+
+// post sends the given form to an AWS query API endpoint, signed with
+// signature version 4.
+func (c *AWSConfig) post(ctx context.Context, service, endpoint string, form url.Values) error {
+	body := form.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	if c.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.SessionToken)
+	}
+	c.sign(req, service, []byte(body), time.Now())
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s responded with %s: %s", service, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// sign signs the given request with AWS signature version 4, by setting its
+// X-Amz-Date and Authorization headers. All the headers set on the request
+// are signed, as is its host.
+func (c *AWSConfig) sign(req *http.Request, service string, body []byte, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		strings.ReplaceAll(req.URL.Query().Encode(), "+", "%20"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		sha256Hex(body),
+	}, "\n")
+	scope := date + "/" + c.Region + "/" + service + "/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+c.SecretAccessKey), date)
+	key = hmacSHA256(key, c.Region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,87 @@
+package msgbus
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAWSConfig_Sign(t *testing.T) {
+	// The get-vanilla case of the AWS signature version 4 test suite.
+	cfg := AWSConfig{
+		Region:          "us-east-1",
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	require.NoError(t, err)
+	cfg.sign(req, "service", nil, time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+	require.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+	require.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		req.Header.Get("Authorization"))
+}
+
+func TestSQSPublisher(t *testing.T) {
+	var got url.Values
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		got, err = url.ParseQuery(string(body))
+		require.NoError(t, err)
+		auth = r.Header.Get("Authorization")
+		if got.Get("MessageBody") == "fail" {
+			http.Error(w, "AccessDenied", http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	_, err := NewSQSPublisher(server.URL+"/123456789012/announce", AWSConfig{AccessKeyID: "fish", SecretAccessKey: "lobster"})
+	require.ErrorContains(t, err, "cannot determine the region")
+	_, err = NewSQSPublisher(server.URL+"/123456789012/announce", AWSConfig{Region: "us-east-1"})
+	require.ErrorContains(t, err, "must be set")
+
+	cfg := AWSConfig{Region: "us-east-1", AccessKeyID: "fish", SecretAccessKey: "lobster"}
+	subject, err := NewSQSPublisher(server.URL+"/123456789012/announce.fifo", cfg)
+	require.NoError(t, err)
+	require.NoError(t, subject.Publish(context.Background(), []byte(`{"Cid":"x"}`)))
+	require.Equal(t, "SendMessage", got.Get("Action"))
+	require.Equal(t, `{"Cid":"x"}`, got.Get("MessageBody"))
+	require.Equal(t, fifoMessageGroupID, got.Get("MessageGroupId"))
+	require.NotEmpty(t, got.Get("MessageDeduplicationId"))
+	require.True(t, strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=fish/"), auth)
+	require.Contains(t, auth, "/us-east-1/sqs/aws4_request")
+
+	require.ErrorContains(t, subject.Publish(context.Background(), []byte("fail")), "AccessDenied")
+}
+
+func TestSNSPublisher(t *testing.T) {
+	var got url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		got, err = url.ParseQuery(string(body))
+		require.NoError(t, err)
+	}))
+	defer server.Close()
+
+	cfg := AWSConfig{AccessKeyID: "fish", SecretAccessKey: "lobster", Endpoint: server.URL}
+	_, err := NewSNSPublisher("arn:aws:sqs:us-east-1:123456789012:announce", cfg)
+	require.ErrorContains(t, err, "invalid SNS topic ARN")
+	subject, err := NewSNSPublisher("arn:aws:sns:eu-west-1:123456789012:announce", cfg)
+	require.NoError(t, err)
+	require.Equal(t, "eu-west-1", subject.cfg.Region)
+	require.NoError(t, subject.Publish(context.Background(), []byte(`{"Cid":"x"}`)))
+	require.Equal(t, "Publish", got.Get("Action"))
+	require.Equal(t, "arn:aws:sns:eu-west-1:123456789012:announce", got.Get("TopicArn"))
+	require.Equal(t, `{"Cid":"x"}`, got.Get("Message"))
+	require.Empty(t, got.Get("MessageGroupId"))
+}
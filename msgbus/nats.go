@@ -0,0 +1,189 @@
+package msgbus
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// natsDialTimeout is the maximum time to connect to a NATS server when the
+// context of a publish has no deadline.
+const natsDialTimeout = 10 * time.Second
+
+// NATSPublisher publishes messages to a NATS subject. It implements enough of
+// the NATS client protocol to publish over plain TCP, and waits for the server
+// to acknowledge each message with a PONG to its following PING, so that a
+// message that is not accepted is reported as failed. The connection is made
+// on first publish, and remade after any error.
+type NATSPublisher struct {
+	addr    string
+	subject string
+	connect []byte
+
+	lock sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// NewNATSPublisher creates a publisher that publishes messages to the subject
+// given as the path of the given NATS URL, e.g.
+// "nats://localhost:4222/ipni.announce". The URL may carry a user and password,
+// or a token as user without password. TLS is not supported.
+func NewNATSPublisher(natsURL string) (*NATSPublisher, error) {
+	u, err := url.Parse(natsURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid NATS URL: %w", err)
+	}
+	if u.Scheme != "nats" {
+		return nil, fmt.Errorf("invalid NATS URL %q: scheme must be nats", natsURL)
+	}
+	subject := strings.TrimPrefix(u.Path, "/")
+	if subject == "" || strings.ContainsAny(subject, " \t\r\n/") {
+		return nil, fmt.Errorf("invalid NATS URL %q: path must be a subject", natsURL)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "4222")
+	}
+
+	connect := map[string]any{
+		"verbose":  false,
+		"pedantic": false,
+		"lang":     "go",
+		"name":     "index-provider",
+	}
+	if u.User != nil {
+		if pass, ok := u.User.Password(); ok {
+			connect["user"] = u.User.Username()
+			connect["pass"] = pass
+		} else {
+			connect["auth_token"] = u.User.Username()
+		}
+	}
+	connectJSON, err := json.Marshal(connect)
+	if err != nil {
+		return nil, err
+	}
+	return &NATSPublisher{
+		addr:    addr,
+		subject: subject,
+		connect: connectJSON,
+	}, nil
+}
+
+// Publish publishes the given message to the subject.
+func (p *NATSPublisher) Publish(ctx context.Context, data []byte) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	err := p.publish(ctx, data)
+	if err != nil && p.conn != nil {
+		_ = p.conn.Close()
+		p.conn = nil
+	}
+	return err
+}
+
+func (p *NATSPublisher) publish(ctx context.Context, data []byte) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(natsDialTimeout)
+	}
+	if p.conn == nil {
+		if err := p.dial(ctx, deadline); err != nil {
+			return err
+		}
+	}
+	if err := p.conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	msg := fmt.Sprintf("PUB %s %d\r\n%s\r\nPING\r\n", p.subject, len(data), data)
+	if _, err := p.conn.Write([]byte(msg)); err != nil {
+		return err
+	}
+	return p.awaitPong()
+}
+
+// dial connects to the server and sends the CONNECT message in response to
+// its INFO message.
+func (p *NATSPublisher) dial(ctx context.Context, deadline time.Time) error {
+	d := net.Dialer{Deadline: deadline}
+	conn, err := d.DialContext(ctx, "tcp", p.addr)
+	if err != nil {
+		return err
+	}
+	if err = conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return err
+	}
+	r := bufio.NewReader(conn)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("cannot read INFO from NATS server: %w", err)
+	}
+	info, ok := strings.CutPrefix(line, "INFO ")
+	if !ok {
+		conn.Close()
+		return fmt.Errorf("unexpected message from NATS server: %q", strings.TrimSpace(line))
+	}
+	var serverInfo struct {
+		TLSRequired bool `json:"tls_required"`
+	}
+	if err = json.Unmarshal([]byte(info), &serverInfo); err == nil && serverInfo.TLSRequired {
+		conn.Close()
+		return errors.New("NATS server requires TLS, which is not supported")
+	}
+	if _, err = conn.Write([]byte("CONNECT " + string(p.connect) + "\r\n")); err != nil {
+		conn.Close()
+		return err
+	}
+	p.conn = conn
+	p.r = r
+	return nil
+}
+
+// awaitPong reads messages from the server until the PONG to the last PING,
+// answering the PINGs of the server, and failing on any error message.
+func (p *NATSPublisher) awaitPong() error {
+	for {
+		line, err := p.r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err = p.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("NATS server error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
+
+// Close closes the connection to the server, if any.
+func (p *NATSPublisher) Close() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.Close()
+	p.conn = nil
+	return err
+}
+
+func (p *NATSPublisher) String() string {
+	return "nats://" + p.addr + "/" + p.subject
+}
@@ -0,0 +1,98 @@
+package msgbus
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNATSServer accepts NATS connections, records the CONNECT messages and
+// the published messages, and rejects messages published to "forbidden".
+type fakeNATSServer struct {
+	l         net.Listener
+	connects  chan string
+	published chan string
+}
+
+func newFakeNATSServer(t *testing.T) *fakeNATSServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	s := &fakeNATSServer{l: l, connects: make(chan string, 10), published: make(chan string, 10)}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeNATSServer) serve(conn net.Conn) {
+	defer conn.Close()
+	fmt.Fprint(conn, "INFO {\"server_id\":\"fake\"}\r\n")
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		switch fields[0] {
+		case "CONNECT":
+			s.connects <- strings.TrimSpace(strings.TrimPrefix(line, "CONNECT"))
+		case "PUB":
+			size, _ := strconv.Atoi(fields[2])
+			data := make([]byte, size+2)
+			if _, err = r.Read(data); err != nil {
+				return
+			}
+			if fields[1] == "forbidden" {
+				fmt.Fprint(conn, "-ERR 'Permissions Violation'\r\n")
+				return
+			}
+			fmt.Fprint(conn, "PING\r\n")
+			s.published <- fields[1] + " " + string(data[:size])
+		case "PING":
+			fmt.Fprint(conn, "PONG\r\n")
+		}
+	}
+}
+
+func TestNATSPublisher(t *testing.T) {
+	server := newFakeNATSServer(t)
+	ctx := context.Background()
+
+	_, err := NewNATSPublisher("nats://" + server.l.Addr().String())
+	require.ErrorContains(t, err, "path must be a subject")
+	subject, err := NewNATSPublisher("nats://fish:lobster@" + server.l.Addr().String() + "/ipni.announce")
+	require.NoError(t, err)
+	defer subject.Close()
+	require.Equal(t, "nats://"+server.l.Addr().String()+"/ipni.announce", subject.String())
+
+	require.NoError(t, subject.Publish(ctx, []byte("fish")))
+	require.Contains(t, <-server.connects, `"pass":"lobster"`)
+	require.Equal(t, "ipni.announce fish", <-server.published)
+	// The connection is reused.
+	require.NoError(t, subject.Publish(ctx, []byte("lobster")))
+	require.Equal(t, "ipni.announce lobster", <-server.published)
+	require.Empty(t, server.connects)
+
+	// Errors are reported, and the connection remade after them.
+	forbidden, err := NewNATSPublisher("nats://token@" + server.l.Addr().String() + "/forbidden")
+	require.NoError(t, err)
+	defer forbidden.Close()
+	require.ErrorContains(t, forbidden.Publish(ctx, []byte("fish")), "Permissions Violation")
+	require.Contains(t, <-server.connects, `"auth_token":"token"`)
+	require.Error(t, forbidden.Publish(ctx, []byte("fish")))
+	require.Len(t, server.connects, 1)
+}
@@ -0,0 +1,68 @@
+package msgbus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/ipni/go-libipni/announce/message"
+	"github.com/ipni/go-libipni/test"
+	"github.com/stretchr/testify/require"
+)
+
+type fakePublisher struct {
+	published [][]byte
+	err       error
+	closed    bool
+}
+
+func (p *fakePublisher) Publish(_ context.Context, data []byte) error {
+	p.published = append(p.published, data)
+	return p.err
+}
+
+func (p *fakePublisher) Close() error {
+	p.closed = true
+	return nil
+}
+
+func TestSender(t *testing.T) {
+	pub := &fakePublisher{}
+	subject := NewSender(pub)
+	require.Equal(t, "*msgbus.fakePublisher", subject.String())
+
+	msg := message.Message{Cid: test.RandomCids(1)[0]}
+	msg.SetAddrs(test.RandomMultiaddrs(2))
+	require.NoError(t, subject.Send(context.Background(), msg))
+	require.Len(t, pub.published, 1)
+	var got message.Message
+	require.NoError(t, json.Unmarshal(pub.published[0], &got))
+	require.Equal(t, msg, got)
+
+	pub.err = errors.New("fish")
+	require.ErrorIs(t, subject.Send(context.Background(), msg), pub.err)
+	require.NoError(t, subject.Close())
+	require.True(t, pub.closed)
+}
+
+func TestOpen(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", "fish")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "lobster")
+	t.Setenv("AWS_ENDPOINT_URL", "")
+
+	pub, err := Open("sqs://sqs.us-east-1.amazonaws.com/123456789012/announce")
+	require.NoError(t, err)
+	require.Equal(t, "sqs:https://sqs.us-east-1.amazonaws.com/123456789012/announce", pub.(*SQSPublisher).String())
+	require.Equal(t, "us-east-1", pub.(*SQSPublisher).cfg.Region)
+	pub, err = Open("arn:aws:sns:us-east-1:123456789012:announce")
+	require.NoError(t, err)
+	require.IsType(t, &SNSPublisher{}, pub)
+	pub, err = Open("nats://localhost/ipni.announce")
+	require.NoError(t, err)
+	require.Equal(t, "nats://localhost:4222/ipni.announce", pub.(*NATSPublisher).String())
+	_, err = Open("kafka://localhost/announce")
+	require.ErrorContains(t, err, "unsupported message bus URL")
+}
@@ -0,0 +1,210 @@
+package provider
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	carindex "github.com/ipld/go-car/v2/index"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multihash"
+)
+
+var (
+	_ MultihashIterator = (*fileMhIterator)(nil)
+	_ MultihashIterator = (*sqlMhIterator)(nil)
+)
+
+// PathFunc returns the path of the file that holds the multihashes of the
+// given provider and context ID, for the listers that read them from files,
+// or an error, such as ErrContextIDNotFound, if there is none.
+type PathFunc func(provider peer.ID, contextID []byte) (string, error)
+
+// CarIndexMultihashLister constructs a MultihashLister that lists the
+// multihashes of each context ID from the CARv2 index file at the path
+// returned by pathOf, such as one written by `car index`, in the order of
+// their offset in the CAR. The index must be iterable, e.g. of the
+// car-multihash-index-sorted codec.
+func CarIndexMultihashLister(pathOf PathFunc) MultihashLister {
+	return func(_ context.Context, p peer.ID, contextID []byte) (MultihashIterator, error) {
+		path, err := pathOf(p, contextID)
+		if err != nil {
+			return nil, err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		idx, err := carindex.ReadFrom(f)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read CAR index %s: %w", path, err)
+		}
+		iterable, ok := idx.(carindex.IterableIndex)
+		if !ok {
+			return nil, fmt.Errorf("CAR index %s of codec %s is not iterable", path, idx.Codec())
+		}
+		return CarMultihashIterator(iterable)
+	}
+}
+
+// FileMultihashLister constructs a MultihashLister that lists the multihashes
+// of each context ID from the file at the path returned by pathOf, as they
+// are iterated over. If lines is true, the file lists a multihash or CID per
+// line, as read by LinesMultihashIterator, and otherwise holds binary
+// multihashes written one after the other, as read by
+// ReaderMultihashIterator.
+//
+// The file is closed once iterated to the end or once an error occurs. The
+// returned iterators implement io.Closer, so that the file can be closed
+// before then.
+func FileMultihashLister(pathOf PathFunc, lines bool) MultihashLister {
+	return func(_ context.Context, p peer.ID, contextID []byte) (MultihashIterator, error) {
+		path, err := pathOf(p, contextID)
+		if err != nil {
+			return nil, err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		it := &fileMhIterator{f: f}
+		if lines {
+			it.it = LinesMultihashIterator(f)
+		} else {
+			it.it = ReaderMultihashIterator(f)
+		}
+		return it, nil
+	}
+}
+
+// fileMhIterator iterates over the multihashes read from a file, and closes
+// the file once done.
+type fileMhIterator struct {
+	f  *os.File
+	it MultihashIterator
+}
+
+// Next implements the MultihashIterator interface.
+func (it *fileMhIterator) Next() (multihash.Multihash, error) {
+	if it.f == nil {
+		return nil, io.EOF
+	}
+	mh, err := it.it.Next()
+	if err != nil {
+		if cerr := it.Close(); cerr != nil && err == io.EOF {
+			err = cerr
+		}
+		return nil, err
+	}
+	return mh, nil
+}
+
+// Close closes the file.
+func (it *fileMhIterator) Close() error {
+	if it.f == nil {
+		return nil
+	}
+	f := it.f
+	it.f = nil
+	return f.Close()
+}
+
+// DatastoreMultihashLister constructs a MultihashLister that lists the
+// multihashes of each context ID from the values of the datastore entries
+// under the key prefix returned by prefixOf, in ascending key order, such as
+// from a table of multihashes in a LevelDB, Pebble or Badger datastore. The
+// entries are fetched as they are iterated over, via QueryMultihashIterator.
+// If decode is nil, the value of each entry is decoded as a binary multihash.
+func DatastoreMultihashLister(ds datastore.Read, prefixOf func(provider peer.ID, contextID []byte) datastore.Key, decode func(query.Entry) (multihash.Multihash, error)) MultihashLister {
+	return func(ctx context.Context, p peer.ID, contextID []byte) (MultihashIterator, error) {
+		q := query.Query{
+			Prefix: prefixOf(p, contextID).String(),
+			Orders: []query.Order{query.OrderByKey{}},
+		}
+		return QueryMultihashIterator(ctx, ds, q, decode)
+	}
+}
+
+// SQLMultihashLister constructs a MultihashLister that lists the multihashes
+// of each context ID from the rows returned by the given SQL query, as they
+// are iterated over. The query is run with the arguments returned by args, or
+// with the context ID as only argument if args is nil, and must return a
+// single column that holds either binary multihashes, or base58 encoded
+// multihashes or CIDs as text. Since listers must be deterministic, the query
+// must order its rows, e.g.:
+//
+//	SELECT multihash FROM blocks WHERE deal_id = $1 ORDER BY multihash
+//
+// The rows are closed once iterated to the end or once an error occurs. The
+// returned iterators implement io.Closer, so that the rows can be closed
+// before then.
+func SQLMultihashLister(db *sql.DB, sqlQuery string, args func(provider peer.ID, contextID []byte) []any) MultihashLister {
+	return func(ctx context.Context, p peer.ID, contextID []byte) (MultihashIterator, error) {
+		queryArgs := []any{contextID}
+		if args != nil {
+			queryArgs = args(p, contextID)
+		}
+		rows, err := db.QueryContext(ctx, sqlQuery, queryArgs...)
+		if err != nil {
+			return nil, err
+		}
+		return &sqlMhIterator{rows: rows}, nil
+	}
+}
+
+// sqlMhIterator iterates over the multihashes scanned from SQL rows.
+type sqlMhIterator struct {
+	rows *sql.Rows
+	row  int
+	done bool
+}
+
+// Next implements the MultihashIterator interface.
+func (it *sqlMhIterator) Next() (multihash.Multihash, error) {
+	if it.done {
+		return nil, io.EOF
+	}
+	if !it.rows.Next() {
+		err := it.rows.Err()
+		if err == nil {
+			err = io.EOF
+		}
+		return nil, it.finish(err)
+	}
+	it.row++
+	var value []byte
+	if err := it.rows.Scan(&value); err != nil {
+		return nil, it.finish(fmt.Errorf("cannot scan row %d: %w", it.row, err))
+	}
+	if n, mh, err := multihash.MHFromBytes(value); err == nil && n == len(value) {
+		return mh, nil
+	}
+	if mh, err := multihash.FromB58String(string(value)); err == nil {
+		return mh, nil
+	}
+	c, err := cid.Decode(string(value))
+	if err != nil {
+		return nil, it.finish(fmt.Errorf("row %d is neither a multihash nor a CID: %q", it.row, value))
+	}
+	return c.Hash(), nil
+}
+
+// Close closes the rows.
+func (it *sqlMhIterator) Close() error {
+	if it.done {
+		return nil
+	}
+	it.done = true
+	return it.rows.Close()
+}
+
+func (it *sqlMhIterator) finish(err error) error {
+	_ = it.Close()
+	return err
+}
@@ -0,0 +1,175 @@
+package provider
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipld/go-car/v2"
+	carindex "github.com/ipld/go-car/v2/index"
+	"github.com/ipni/go-libipni/test"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCarIndexMultihashLister(t *testing.T) {
+	idx, err := car.GenerateIndexFromFile("testdata/sample-v1.car")
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "fish.idx")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	_, err = carindex.WriteTo(idx, f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	wantIt, err := CarMultihashIterator(idx.(carindex.IterableIndex))
+	require.NoError(t, err)
+
+	lister := CarIndexMultihashLister(func(_ peer.ID, contextID []byte) (string, error) {
+		if string(contextID) != "fish" {
+			return "", ErrContextIDNotFound
+		}
+		return path, nil
+	})
+	it, err := lister(context.Background(), "", []byte("fish"))
+	require.NoError(t, err)
+	require.Equal(t, collectMultihashes(t, wantIt), collectMultihashes(t, it))
+
+	_, err = lister(context.Background(), "", []byte("lobster"))
+	require.ErrorIs(t, err, ErrContextIDNotFound)
+}
+
+func TestFileMultihashLister(t *testing.T) {
+	mhs := test.RandomMultihashes(5)
+	dir := t.TempDir()
+	var bin []byte
+	var lines strings.Builder
+	for _, mh := range mhs {
+		bin = append(bin, mh...)
+		lines.WriteString(mh.B58String() + "\n")
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "fish.bin"), bin, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "fish.txt"), []byte(lines.String()), 0o644))
+
+	for ext, isLines := range map[string]bool{".bin": false, ".txt": true} {
+		lister := FileMultihashLister(func(_ peer.ID, contextID []byte) (string, error) {
+			return filepath.Join(dir, string(contextID)+ext), nil
+		}, isLines)
+		it, err := lister(context.Background(), "", []byte("fish"))
+		require.NoError(t, err)
+		require.Equal(t, mhs, collectMultihashes(t, it), ext)
+		// The file is closed once iterated to the end.
+		_, err = it.Next()
+		require.Equal(t, io.EOF, err)
+
+		it, err = lister(context.Background(), "", []byte("fish"))
+		require.NoError(t, err)
+		require.NoError(t, it.(io.Closer).Close())
+
+		_, err = lister(context.Background(), "", []byte("lobster"))
+		require.ErrorIs(t, err, os.ErrNotExist)
+	}
+}
+
+func TestDatastoreMultihashLister(t *testing.T) {
+	ctx := context.Background()
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	mhs := test.RandomMultihashes(5)
+	for i, mh := range mhs {
+		require.NoError(t, ds.Put(ctx, datastore.NewKey("fish/"+string(rune('a'+i))), mh))
+	}
+	require.NoError(t, ds.Put(ctx, datastore.NewKey("lobster/a"), test.RandomMultihashes(1)[0]))
+
+	lister := DatastoreMultihashLister(ds, func(_ peer.ID, contextID []byte) datastore.Key {
+		return datastore.NewKey(string(contextID))
+	}, nil)
+	it, err := lister(ctx, "", []byte("fish"))
+	require.NoError(t, err)
+	require.Equal(t, mhs, collectMultihashes(t, it))
+}
+
+func TestSQLMultihashLister(t *testing.T) {
+	mhs := test.RandomMultihashes(3)
+	cids := test.RandomCids(1)
+	rows := map[string][]driver.Value{
+		"fish":    {[]byte(mhs[0]), mhs[1].B58String(), []byte(mhs[2])},
+		"lobster": {cids[0].String()},
+		"crab":    {"not a multihash"},
+	}
+	sql.Register("mhlister", &testSQLDriver{rows: rows})
+	db, err := sql.Open("mhlister", "")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	ctx := context.Background()
+	lister := SQLMultihashLister(db, "SELECT multihash FROM blocks WHERE context_id = ? ORDER BY multihash", nil)
+	it, err := lister(ctx, "", []byte("fish"))
+	require.NoError(t, err)
+	require.Equal(t, mhs, collectMultihashes(t, it))
+
+	// CIDs are listed as their multihash.
+	lister = SQLMultihashLister(db, "SELECT multihash FROM blocks WHERE context_id = ?", func(_ peer.ID, contextID []byte) []any {
+		return []any{"lobster"}
+	})
+	it, err = lister(ctx, "", []byte("squid"))
+	require.NoError(t, err)
+	require.Equal(t, []multihash.Multihash{cids[0].Hash()}, collectMultihashes(t, it))
+
+	lister = SQLMultihashLister(db, "SELECT multihash FROM blocks WHERE context_id = ?", nil)
+	it, err = lister(ctx, "", []byte("crab"))
+	require.NoError(t, err)
+	_, err = it.Next()
+	require.ErrorContains(t, err, "row 1 is neither a multihash nor a CID")
+}
+
+// testSQLDriver is a database/sql driver whose queries return the rows of the
+// context ID given as first argument, in a single column.
+type testSQLDriver struct {
+	rows map[string][]driver.Value
+}
+
+func (d *testSQLDriver) Open(string) (driver.Conn, error) { return &testSQLConn{d: d}, nil }
+
+type testSQLConn struct{ d *testSQLDriver }
+
+func (c *testSQLConn) Prepare(string) (driver.Stmt, error) { return &testSQLStmt{c: c}, nil }
+func (c *testSQLConn) Close() error                        { return nil }
+func (c *testSQLConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+type testSQLStmt struct{ c *testSQLConn }
+
+func (s *testSQLStmt) Close() error  { return nil }
+func (s *testSQLStmt) NumInput() int { return 1 }
+func (s *testSQLStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+func (s *testSQLStmt) Query(args []driver.Value) (driver.Rows, error) {
+	var key string
+	switch arg := args[0].(type) {
+	case []byte:
+		key = string(arg)
+	case string:
+		key = arg
+	}
+	return &testSQLRows{values: s.c.d.rows[key]}, nil
+}
+
+type testSQLRows struct{ values []driver.Value }
+
+func (r *testSQLRows) Columns() []string { return []string{"multihash"} }
+func (r *testSQLRows) Close() error      { return nil }
+func (r *testSQLRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	dest[0], r.values = r.values[0], r.values[1:]
+	return nil
+}
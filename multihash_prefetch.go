@@ -0,0 +1,119 @@
+package provider
+
+import (
+	"io"
+	"sync"
+
+	"github.com/multiformats/go-multihash"
+)
+
+// maxPrefetchBatch is the maximum number of multihashes that are handed over
+// from the prefetching goroutine at a time.
+const maxPrefetchBatch = 256
+
+var _ MultihashIterator = (*prefetchMhIterator)(nil)
+
+// prefetchMhIterator iterates over the multihashes read ahead from another
+// iterator by a separate goroutine.
+type prefetchMhIterator struct {
+	batches <-chan prefetchBatch
+	batch   prefetchBatch
+	pos     int
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// prefetchBatch is a batch of multihashes read ahead, followed by the error
+// that ended the iteration, if any.
+type prefetchBatch struct {
+	mhs []multihash.Multihash
+	err error
+}
+
+// PrefetchMultihashIterator constructs a MultihashIterator that reads ahead up
+// to size multihashes from the given iterator in a separate goroutine, such
+// that a slow iterator, e.g. one backed by a database scan, keeps listing
+// multihashes while those already listed are consumed. The given iterator is
+// returned as is if size is not positive.
+//
+// The multihashes are returned in the same order as by the given iterator,
+// followed by the error that ended its iteration. The given iterator is only
+// used by the prefetching goroutine, and is closed once iterated to the end if
+// it implements io.Closer.
+//
+// The returned iterator implements io.Closer, which stops the prefetching.
+// The prefetching goroutine exits, and closes the given iterator, once the
+// call to its Next that is in progress returns.
+func PrefetchMultihashIterator(it MultihashIterator, size int) MultihashIterator {
+	if size <= 0 {
+		return it
+	}
+	batchSize := min(size, maxPrefetchBatch)
+	batches := make(chan prefetchBatch, (size+batchSize-1)/batchSize)
+	p := &prefetchMhIterator{
+		batches: batches,
+		stop:    make(chan struct{}),
+	}
+	go p.prefetch(it, batches, batchSize)
+	return p
+}
+
+func (p *prefetchMhIterator) prefetch(it MultihashIterator, batches chan<- prefetchBatch, batchSize int) {
+	defer close(batches)
+	if closer, ok := it.(io.Closer); ok {
+		defer closer.Close()
+	}
+	for {
+		batch := prefetchBatch{mhs: make([]multihash.Multihash, 0, batchSize)}
+		for len(batch.mhs) < batchSize {
+			mh, err := it.Next()
+			if err != nil {
+				batch.err = err
+				break
+			}
+			batch.mhs = append(batch.mhs, mh)
+		}
+		select {
+		case batches <- batch:
+		case <-p.stop:
+			return
+		}
+		if batch.err != nil {
+			return
+		}
+	}
+}
+
+// Next implements the MultihashIterator interface.
+func (p *prefetchMhIterator) Next() (multihash.Multihash, error) {
+	select {
+	case <-p.stop:
+		return nil, io.EOF
+	default:
+	}
+	for p.pos >= len(p.batch.mhs) {
+		if p.batch.err != nil {
+			return nil, p.batch.err
+		}
+		select {
+		case batch, ok := <-p.batches:
+			if !ok {
+				// Stopped before the iteration ended.
+				return nil, io.EOF
+			}
+			p.batch, p.pos = batch, 0
+		case <-p.stop:
+			return nil, io.EOF
+		}
+	}
+	mh := p.batch.mhs[p.pos]
+	p.pos++
+	return mh, nil
+}
+
+// Close stops prefetching multihashes, after which Next returns io.EOF.
+func (p *prefetchMhIterator) Close() error {
+	p.stopOnce.Do(func() { close(p.stop) })
+	return nil
+}
@@ -0,0 +1,94 @@
+package provider
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ipni/go-libipni/test"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+// slowMhIterator returns the multihashes of a slice, taking the given delay
+// to return each, followed by err.
+type slowMhIterator struct {
+	mhs    []multihash.Multihash
+	delay  time.Duration
+	err    error
+	closed chan struct{}
+}
+
+func (it *slowMhIterator) Next() (multihash.Multihash, error) {
+	time.Sleep(it.delay)
+	if len(it.mhs) == 0 {
+		return nil, it.err
+	}
+	mh := it.mhs[0]
+	it.mhs = it.mhs[1:]
+	return mh, nil
+}
+
+func (it *slowMhIterator) Close() error {
+	close(it.closed)
+	return nil
+}
+
+func TestPrefetchMultihashIterator(t *testing.T) {
+	want := test.RandomMultihashes(1000)
+	for _, size := range []int{1, 100, 1000, 5000} {
+		it := &slowMhIterator{mhs: want, err: io.EOF, closed: make(chan struct{})}
+		subject := PrefetchMultihashIterator(it, size)
+		require.Equal(t, want, collectMultihashes(t, subject))
+		_, err := subject.Next()
+		require.Equal(t, io.EOF, err)
+		<-it.closed
+	}
+
+	it := SliceMultihashIterator(want)
+	require.Equal(t, it, PrefetchMultihashIterator(it, 0))
+}
+
+func TestPrefetchMultihashIterator_Error(t *testing.T) {
+	want := test.RandomMultihashes(10)
+	it := &slowMhIterator{mhs: want, err: errors.New("fish"), closed: make(chan struct{})}
+	subject := PrefetchMultihashIterator(it, 3)
+	for _, mh := range want {
+		got, err := subject.Next()
+		require.NoError(t, err)
+		require.Equal(t, mh, got)
+	}
+	_, err := subject.Next()
+	require.ErrorContains(t, err, "fish")
+	_, err = subject.Next()
+	require.ErrorContains(t, err, "fish")
+}
+
+func TestPrefetchMultihashIterator_ReadsAhead(t *testing.T) {
+	it := &slowMhIterator{mhs: test.RandomMultihashes(10), delay: time.Millisecond, err: io.EOF, closed: make(chan struct{})}
+	subject := PrefetchMultihashIterator(it, 20)
+	// The multihashes are listed to the end without being consumed.
+	require.Eventually(t, func() bool {
+		select {
+		case <-it.closed:
+			return true
+		default:
+			return false
+		}
+	}, time.Second, time.Millisecond)
+	require.Len(t, collectMultihashes(t, subject), 10)
+}
+
+func TestPrefetchMultihashIterator_Close(t *testing.T) {
+	it := &slowMhIterator{mhs: test.RandomMultihashes(100), err: io.EOF, closed: make(chan struct{})}
+	subject := PrefetchMultihashIterator(it, 1)
+	_, err := subject.Next()
+	require.NoError(t, err)
+	require.NoError(t, subject.(io.Closer).Close())
+	require.NoError(t, subject.(io.Closer).Close())
+	_, err = subject.Next()
+	require.Equal(t, io.EOF, err)
+	// Prefetching stops and the iterator is closed.
+	<-it.closed
+}
@@ -0,0 +1,211 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"errors"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/multiformats/go-multihash"
+)
+
+// DefaultSortMemory is the default number of bytes of multihashes that
+// SortedMultihashIterator holds in memory before spilling them to disk.
+const DefaultSortMemory = 64 << 20
+
+var _ MultihashIterator = (*sortedMhIterator)(nil)
+
+// sortedMhIterator iterates over multihashes in ascending byte order without
+// duplicates, merging the sorted runs of multihashes spilled to disk, or
+// iterating over those held in memory if none were spilled.
+type sortedMhIterator struct {
+	mhs  []multihash.Multihash
+	pos  int
+	runs runHeap
+	last multihash.Multihash
+	// files are the spilled runs, which are closed once iterated over.
+	files []*spillFile
+}
+
+// spillFile is a temporary file holding a sorted run of multihashes. It is
+// removed as soon as it is written, where the platform allows removing open
+// files, or otherwise once closed.
+type spillFile struct {
+	*os.File
+	removed bool
+}
+
+// run is a sorted run of multihashes read from a spill file, positioned at
+// head.
+type run struct {
+	head multihash.Multihash
+	next MultihashIterator
+}
+
+// runHeap orders runs by their head multihash.
+type runHeap []*run
+
+func (h runHeap) Len() int           { return len(h) }
+func (h runHeap) Less(i, j int) bool { return bytes.Compare(h[i].head, h[j].head) < 0 }
+func (h runHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *runHeap) Push(x any)        { *h = append(*h, x.(*run)) }
+func (h *runHeap) Pop() any {
+	old := *h
+	r := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return r
+}
+
+// SortedMultihashIterator constructs a MultihashIterator that returns the
+// multihashes of the given iterator in ascending byte order, with duplicates
+// removed. This makes the multihashes listed deterministic regardless of the
+// order in which they are found, as required of a MultihashLister.
+//
+// The given iterator is iterated over to the end before returning. At most
+// maxMemory bytes of multihashes are held in memory at a time, beyond which
+// sorted runs of multihashes are spilled to temporary files in tempDir, and
+// merged as the returned iterator is iterated over. DefaultSortMemory is used
+// if maxMemory is not positive, and the default directory for temporary files
+// if tempDir is empty.
+//
+// Temporary files are released once iterated to the end. The returned
+// iterator implements io.Closer, so that they can be released before then.
+func SortedMultihashIterator(it MultihashIterator, maxMemory int, tempDir string) (MultihashIterator, error) {
+	if maxMemory <= 0 {
+		maxMemory = DefaultSortMemory
+	}
+	s := &sortedMhIterator{}
+	var mhs []multihash.Multihash
+	var size int
+	for {
+		mh, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		mhs = append(mhs, mh)
+		size += len(mh)
+		if size >= maxMemory {
+			if err = s.spill(mhs, tempDir); err != nil {
+				s.Close()
+				return nil, err
+			}
+			mhs, size = mhs[:0], 0
+		}
+	}
+
+	if len(s.files) == 0 {
+		s.mhs = sortAndDedup(mhs)
+		return s, nil
+	}
+	if len(mhs) != 0 {
+		if err := s.spill(mhs, tempDir); err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+	for _, f := range s.files {
+		r := &run{next: ReaderMultihashIterator(f)}
+		head, err := r.next.Next()
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		r.head = head
+		s.runs = append(s.runs, r)
+	}
+	heap.Init(&s.runs)
+	return s, nil
+}
+
+// spill writes the given multihashes as a sorted run to a temporary file.
+func (s *sortedMhIterator) spill(mhs []multihash.Multihash, tempDir string) error {
+	f, err := os.CreateTemp(tempDir, "multihashes-*")
+	if err != nil {
+		return err
+	}
+	sf := &spillFile{File: f}
+	s.files = append(s.files, sf)
+	// Remove the file right away where open files can be removed, so that it
+	// does not outlive the process.
+	sf.removed = os.Remove(f.Name()) == nil
+
+	w := bufio.NewWriter(f)
+	for _, mh := range sortAndDedup(mhs) {
+		if _, err = w.Write(mh); err != nil {
+			return err
+		}
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	_, err = f.Seek(0, io.SeekStart)
+	return err
+}
+
+// Next implements the MultihashIterator interface.
+func (s *sortedMhIterator) Next() (multihash.Multihash, error) {
+	if s.files == nil {
+		if s.pos >= len(s.mhs) {
+			return nil, io.EOF
+		}
+		mh := s.mhs[s.pos]
+		s.pos++
+		return mh, nil
+	}
+
+	for len(s.runs) != 0 {
+		r := s.runs[0]
+		mh := r.head
+		next, err := r.next.Next()
+		switch {
+		case err == io.EOF:
+			heap.Pop(&s.runs)
+		case err != nil:
+			s.Close()
+			return nil, err
+		default:
+			r.head = next
+			heap.Fix(&s.runs, 0)
+		}
+		// Runs are deduplicated, but may hold the same multihashes as others.
+		if s.last != nil && bytes.Equal(mh, s.last) {
+			continue
+		}
+		s.last = mh
+		return mh, nil
+	}
+	s.Close()
+	return nil, io.EOF
+}
+
+// Close releases the temporary files that multihashes were spilled to.
+func (s *sortedMhIterator) Close() error {
+	var errs []error
+	for _, f := range s.files {
+		errs = append(errs, f.Close())
+		if !f.removed {
+			errs = append(errs, os.Remove(f.Name()))
+		}
+	}
+	s.files = s.files[:0]
+	s.runs = nil
+	return errors.Join(errs...)
+}
+
+// sortAndDedup sorts the given multihashes in ascending byte order and removes
+// duplicates in place.
+func sortAndDedup(mhs []multihash.Multihash) []multihash.Multihash {
+	slices.SortFunc(mhs, func(a, b multihash.Multihash) int {
+		return bytes.Compare(a, b)
+	})
+	return slices.CompactFunc(mhs, func(a, b multihash.Multihash) bool {
+		return bytes.Equal(a, b)
+	})
+}
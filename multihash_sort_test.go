@@ -0,0 +1,59 @@
+package provider
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/ipni/go-libipni/test"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortedMultihashIterator(t *testing.T) {
+	mhs := test.RandomMultihashes(1000)
+	// Duplicate some multihashes, such that they are spilled in separate runs.
+	input := append(slices.Clone(mhs), mhs[:100]...)
+	input = append(input, mhs[500:510]...)
+	want := slices.Clone(mhs)
+	slices.SortFunc(want, func(a, b multihash.Multihash) int { return bytes.Compare(a, b) })
+
+	for _, tc := range []struct {
+		name      string
+		maxMemory int
+	}{
+		{name: "in memory", maxMemory: 0},
+		{name: "spilled", maxMemory: 1000},
+		{name: "spilled one by one", maxMemory: 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			subject, err := SortedMultihashIterator(SliceMultihashIterator(slices.Clone(input)), tc.maxMemory, dir)
+			require.NoError(t, err)
+			require.Equal(t, want, collectMultihashes(t, subject))
+			_, err = subject.Next()
+			require.Equal(t, io.EOF, err)
+
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err)
+			require.Empty(t, entries)
+		})
+	}
+}
+
+func TestSortedMultihashIterator_Close(t *testing.T) {
+	dir := t.TempDir()
+	subject, err := SortedMultihashIterator(SliceMultihashIterator(test.RandomMultihashes(100)), 100, dir)
+	require.NoError(t, err)
+	_, err = subject.Next()
+	require.NoError(t, err)
+	require.NoError(t, subject.(io.Closer).Close())
+	_, err = subject.Next()
+	require.Equal(t, io.EOF, err)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
@@ -0,0 +1,152 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/multiformats/go-multihash"
+)
+
+var (
+	_ MultihashIterator = (*readerMhIterator)(nil)
+	_ MultihashIterator = (*linesMhIterator)(nil)
+	_ MultihashIterator = (*queryMhIterator)(nil)
+)
+
+// readerMhIterator iterates over the binary multihashes read from a reader.
+type readerMhIterator struct {
+	br *bufio.Reader
+	r  multihash.Reader
+}
+
+// ReaderMultihashIterator constructs a MultihashIterator that reads binary
+// multihashes, written one after the other, from the given reader as they are
+// iterated over. Since multihashes are self-delimiting, no separator is
+// needed.
+func ReaderMultihashIterator(r io.Reader) MultihashIterator {
+	br := bufio.NewReader(r)
+	return &readerMhIterator{br: br, r: multihash.NewReader(br)}
+}
+
+// Next implements the MultihashIterator interface.
+func (it *readerMhIterator) Next() (multihash.Multihash, error) {
+	if _, err := it.br.Peek(1); err != nil {
+		return nil, err
+	}
+	mh, err := it.r.ReadMultihash()
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		// The reader ended part way through a multihash.
+		return nil, fmt.Errorf("truncated multihash: %w", io.ErrUnexpectedEOF)
+	}
+	return mh, err
+}
+
+// linesMhIterator iterates over the multihashes listed one per line by a
+// reader.
+type linesMhIterator struct {
+	s    *bufio.Scanner
+	line int
+}
+
+// LinesMultihashIterator constructs a MultihashIterator that reads multihashes
+// listed one per line from the given reader as they are iterated over, such as
+// from a file of millions of multihashes. Each line holds either a base58
+// encoded multihash or a CID, whose multihash is returned. Blank lines and
+// lines starting with # are skipped.
+func LinesMultihashIterator(r io.Reader) MultihashIterator {
+	return &linesMhIterator{s: bufio.NewScanner(r)}
+}
+
+// Next implements the MultihashIterator interface.
+func (it *linesMhIterator) Next() (multihash.Multihash, error) {
+	for it.s.Scan() {
+		it.line++
+		line := bytes.TrimSpace(it.s.Bytes())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		if mh, err := multihash.FromB58String(string(line)); err == nil {
+			return mh, nil
+		}
+		c, err := cid.Decode(string(line))
+		if err != nil {
+			return nil, fmt.Errorf("line %d is neither a multihash nor a CID: %q", it.line, line)
+		}
+		return c.Hash(), nil
+	}
+	if err := it.s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// queryMhIterator iterates over the multihashes decoded from the results of
+// a datastore query.
+type queryMhIterator struct {
+	results query.Results
+	decode  func(query.Entry) (multihash.Multihash, error)
+	done    bool
+}
+
+// QueryMultihashIterator constructs a MultihashIterator over the multihashes
+// decoded from the results of the given datastore query, which are fetched as
+// they are iterated over. If decode is nil, the value of each result is
+// decoded as a binary multihash, and the query should not be KeysOnly.
+//
+// The query results are released once iterated to the end or once an error
+// occurs. The returned iterator implements io.Closer, so that the results can
+// be released before then.
+func QueryMultihashIterator(ctx context.Context, ds datastore.Read, q query.Query, decode func(query.Entry) (multihash.Multihash, error)) (MultihashIterator, error) {
+	if decode == nil {
+		decode = decodeValueMultihash
+	}
+	results, err := ds.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return &queryMhIterator{results: results, decode: decode}, nil
+}
+
+// Next implements the MultihashIterator interface.
+func (it *queryMhIterator) Next() (multihash.Multihash, error) {
+	if it.done {
+		return nil, io.EOF
+	}
+	r, ok := it.results.NextSync()
+	if !ok {
+		return nil, it.finish(io.EOF)
+	}
+	if r.Error != nil {
+		return nil, it.finish(r.Error)
+	}
+	mh, err := it.decode(r.Entry)
+	if err != nil {
+		return nil, it.finish(fmt.Errorf("cannot decode multihash of %s: %w", r.Key, err))
+	}
+	return mh, nil
+}
+
+// Close releases the query results.
+func (it *queryMhIterator) Close() error {
+	if it.done {
+		return nil
+	}
+	it.done = true
+	return it.results.Close()
+}
+
+func (it *queryMhIterator) finish(err error) error {
+	_ = it.Close()
+	return err
+}
+
+func decodeValueMultihash(e query.Entry) (multihash.Multihash, error) {
+	_, mh, err := multihash.MHFromBytes(e.Value)
+	return mh, err
+}
@@ -0,0 +1,91 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/ipni/go-libipni/test"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func collectMultihashes(t *testing.T, it MultihashIterator) []multihash.Multihash {
+	var mhs []multihash.Multihash
+	for {
+		mh, err := it.Next()
+		if err == io.EOF {
+			return mhs
+		}
+		require.NoError(t, err)
+		mhs = append(mhs, mh)
+	}
+}
+
+func TestReaderMultihashIterator(t *testing.T) {
+	want := test.RandomMultihashes(100)
+	var buf bytes.Buffer
+	for _, mh := range want {
+		buf.Write(mh)
+	}
+	data := buf.Bytes()
+
+	require.Equal(t, want, collectMultihashes(t, ReaderMultihashIterator(bytes.NewReader(data))))
+
+	subject := ReaderMultihashIterator(bytes.NewReader(data[:len(data)-1]))
+	for range want[1:] {
+		_, err := subject.Next()
+		require.NoError(t, err)
+	}
+	_, err := subject.Next()
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestLinesMultihashIterator(t *testing.T) {
+	mhs := test.RandomMultihashes(2)
+	c := cid.NewCidV1(cid.Raw, mhs[1])
+	input := "# multihashes of fish\n" + mhs[0].B58String() + "\n\n  " + c.String() + "  \n"
+
+	got := collectMultihashes(t, LinesMultihashIterator(strings.NewReader(input)))
+	require.Equal(t, mhs, got)
+
+	subject := LinesMultihashIterator(strings.NewReader(mhs[0].B58String() + "\nlobster\n"))
+	_, err := subject.Next()
+	require.NoError(t, err)
+	_, err = subject.Next()
+	require.ErrorContains(t, err, `line 2 is neither a multihash nor a CID: "lobster"`)
+}
+
+func TestQueryMultihashIterator(t *testing.T) {
+	ctx := context.Background()
+	ds := datastore.NewMapDatastore()
+	want := test.RandomMultihashes(10)
+	for i, mh := range want {
+		require.NoError(t, ds.Put(ctx, datastore.NewKey("fish/"+string(rune('a'+i))), mh))
+	}
+	require.NoError(t, ds.Put(ctx, datastore.NewKey("bad/lobster"), []byte("not a multihash")))
+
+	q := query.Query{Prefix: "fish/", Orders: []query.Order{query.OrderByKey{}}}
+	subject, err := QueryMultihashIterator(ctx, ds, q, nil)
+	require.NoError(t, err)
+	require.Equal(t, want, collectMultihashes(t, subject))
+
+	// Multihashes are decoded from keys by a custom decoder.
+	q = query.Query{Prefix: "fish/", KeysOnly: true}
+	subject, err = QueryMultihashIterator(ctx, ds, q, func(e query.Entry) (multihash.Multihash, error) {
+		return multihash.Sum([]byte(e.Key), multihash.SHA2_256, -1)
+	})
+	require.NoError(t, err)
+	require.Len(t, collectMultihashes(t, subject), 10)
+
+	subject, err = QueryMultihashIterator(ctx, ds, query.Query{Prefix: "bad"}, nil)
+	require.NoError(t, err)
+	_, err = subject.Next()
+	require.ErrorContains(t, err, "cannot decode multihash of /bad/lobster")
+	require.NoError(t, subject.(io.Closer).Close())
+}
@@ -0,0 +1,97 @@
+package selfcheck
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+)
+
+const (
+	defaultSamples    = 3
+	defaultTimeout    = 30 * time.Second
+	defaultMaxResults = 100
+)
+
+type (
+	Option  func(*options) error
+	options struct {
+		samples    int
+		timeout    time.Duration
+		maxResults int
+		host       host.Host
+		httpClient *http.Client
+	}
+)
+
+func newOptions(o ...Option) (*options, error) {
+	opts := options{
+		samples:    defaultSamples,
+		timeout:    defaultTimeout,
+		maxResults: defaultMaxResults,
+		httpClient: http.DefaultClient,
+	}
+	for _, apply := range o {
+		if err := apply(&opts); err != nil {
+			return nil, err
+		}
+	}
+	return &opts, nil
+}
+
+// WithSamples sets the number of multihashes of each advertisement that are
+// sampled and retrieved. Defaults to 3.
+func WithSamples(n int) Option {
+	return func(o *options) error {
+		if n <= 0 {
+			return errors.New("samples must be greater than zero")
+		}
+		o.samples = n
+		return nil
+	}
+}
+
+// WithTimeout sets the maximum time that retrieving a sampled multihash from
+// one address may take. Defaults to 30 seconds.
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) error {
+		if d <= 0 {
+			return errors.New("timeout must be greater than zero")
+		}
+		o.timeout = d
+		return nil
+	}
+}
+
+// WithMaxResults sets the number of the latest results kept by the checker.
+// See: Checker.Results. Defaults to 100.
+func WithMaxResults(n int) Option {
+	return func(o *options) error {
+		if n <= 0 {
+			return errors.New("max results must be greater than zero")
+		}
+		o.maxResults = n
+		return nil
+	}
+}
+
+// WithHost sets the libp2p host from which content is retrieved over bitswap
+// and graphsync. It must not be the host of the provider itself. The host is
+// not closed by Checker.Close. Defaults to a new host, without listen
+// addresses, that is closed by Checker.Close.
+func WithHost(h host.Host) Option {
+	return func(o *options) error {
+		o.host = h
+		return nil
+	}
+}
+
+// WithHttpClient sets the HTTP client used to retrieve content from HTTP
+// gateways. Defaults to http.DefaultClient.
+func WithHttpClient(c *http.Client) Option {
+	return func(o *options) error {
+		o.httpClient = c
+		return nil
+	}
+}
@@ -0,0 +1,250 @@
+package selfcheck
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sync"
+
+	"github.com/ipfs/boxo/bitswap/client"
+	bsnet "github.com/ipfs/boxo/bitswap/network"
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	gsnet "github.com/ipfs/go-graphsync/network"
+	"github.com/ipni/go-libipni/maurl"
+	routinghelpers "github.com/libp2p/go-libp2p-routing-helpers"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/multiformats/go-multicodec"
+	"github.com/multiformats/go-multihash"
+)
+
+// maxBlockSize is the maximum size of a block retrieved over HTTP.
+const maxBlockSize = 4 << 20
+
+// ErrUnsupportedProtocol is returned by Prober.Probe for retrieval protocols
+// that cannot be probed.
+var ErrUnsupportedProtocol = errors.New("retrieval protocol cannot be probed")
+
+var errNoHost = errors.New("no libp2p host to probe from")
+
+// Protocols are the retrieval protocols that a Prober can probe.
+var Protocols = []multicodec.Code{
+	multicodec.TransportBitswap,
+	multicodec.TransportGraphsyncFilecoinv1,
+	multicodec.TransportIpfsGatewayHttp,
+}
+
+// Prober attempts to retrieve blocks from providers over the retrieval
+// protocols that advertisements declare:
+//   - multicodec.TransportIpfsGatewayHttp: the block is fetched from the IPFS
+//     trustless HTTP gateway at the address, and checked against its
+//     multihash.
+//   - multicodec.TransportBitswap: the block is fetched over bitswap from the
+//     provider at the address, and checked against its multihash.
+//   - multicodec.TransportGraphsyncFilecoinv1: the provider is only checked to
+//     be reachable at the address, and to support graphsync, since retrieving
+//     content over graphsync filecoinv1 requires a data transfer deal.
+//
+// Bitswap and graphsync are probed from the libp2p host of the prober, which
+// must not be the host of the provider itself.
+type Prober struct {
+	host       host.Host
+	httpClient *http.Client
+
+	// mutex serializes libp2p probes, each of which uses a fresh connection
+	// to the address being probed.
+	mutex     sync.Mutex
+	bsNet     bsnet.BitSwapNetwork
+	bsClient  *client.Client
+	bsCtx     context.Context
+	bsCancel  context.CancelFunc
+	bsStarted bool
+}
+
+// NewProber instantiates a Prober that probes HTTP addresses with the given
+// client, or http.DefaultClient if nil, and libp2p addresses from the given
+// host. Only HTTP addresses can be probed if the host is nil.
+func NewProber(h host.Host, httpClient *http.Client) *Prober {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Prober{
+		host:       h,
+		httpClient: httpClient,
+		bsCtx:      ctx,
+		bsCancel:   cancel,
+	}
+}
+
+// Supports returns whether the given address is one that content retrievable
+// over the given protocol is retrieved from, i.e. an HTTP address for
+// multicodec.TransportIpfsGatewayHttp and a libp2p address otherwise.
+func Supports(protocol multicodec.Code, addr multiaddr.Multiaddr) bool {
+	switch protocol {
+	case multicodec.TransportIpfsGatewayHttp:
+		return isHttpAddr(addr)
+	case multicodec.TransportBitswap, multicodec.TransportGraphsyncFilecoinv1:
+		return !isHttpAddr(addr)
+	default:
+		return false
+	}
+}
+
+// Probe attempts to retrieve the block with the given multihash from the
+// provider with the given ID, at the given address, over the given protocol.
+// It returns ErrUnsupportedProtocol if the protocol cannot be probed.
+func (p *Prober) Probe(ctx context.Context, protocol multicodec.Code, providerID peer.ID, addr multiaddr.Multiaddr, mh multihash.Multihash) error {
+	switch protocol {
+	case multicodec.TransportIpfsGatewayHttp:
+		return p.probeHttp(ctx, addr, mh)
+	case multicodec.TransportBitswap:
+		return p.probeBitswap(ctx, providerID, addr, mh)
+	case multicodec.TransportGraphsyncFilecoinv1:
+		return p.probeGraphsync(ctx, providerID, addr)
+	default:
+		return ErrUnsupportedProtocol
+	}
+}
+
+// Close stops the bitswap client of the prober, if any. The host of the prober
+// is not closed.
+func (p *Prober) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.bsCancel()
+	if !p.bsStarted {
+		return nil
+	}
+	p.bsStarted = false
+	p.bsNet.Stop()
+	return p.bsClient.Close()
+}
+
+func (p *Prober) probeHttp(ctx context.Context, addr multiaddr.Multiaddr, mh multihash.Multihash) error {
+	u, err := maurl.ToURL(addr)
+	if err != nil {
+		return fmt.Errorf("cannot convert address to URL: %w", err)
+	}
+	u.Path = path.Join(u.Path, "ipfs", cid.NewCidV1(cid.Raw, mh).String())
+	u.RawQuery = "format=raw"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.ipld.raw")
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("gateway responded with status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBlockSize+1))
+	if err != nil {
+		return fmt.Errorf("cannot read block: %w", err)
+	}
+	if len(data) > maxBlockSize {
+		return fmt.Errorf("block exceeds maximum size of %d bytes", maxBlockSize)
+	}
+	return verifyBlock(mh, data)
+}
+
+func (p *Prober) probeBitswap(ctx context.Context, providerID peer.ID, addr multiaddr.Multiaddr, mh multihash.Multihash) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.host == nil {
+		return errNoHost
+	}
+	// The client must be started before connecting, so that it is notified
+	// of the connection to the provider.
+	if !p.bsStarted {
+		if err := p.bsCtx.Err(); err != nil {
+			return err
+		}
+		p.bsNet = bsnet.NewFromIpfsHost(p.host, routinghelpers.Null{})
+		bstore := blockstore.NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore()))
+		p.bsClient = client.New(p.bsCtx, p.bsNet, bstore, client.WithoutDuplicatedBlockStats())
+		p.bsNet.Start(p.bsClient)
+		p.bsStarted = true
+	}
+	if err := p.connect(ctx, providerID, addr); err != nil {
+		return err
+	}
+	// The client verifies that the block matches the CID it is fetched by.
+	if _, err := p.bsClient.GetBlock(ctx, cid.NewCidV1(cid.Raw, mh)); err != nil {
+		return fmt.Errorf("cannot get block over bitswap: %w", err)
+	}
+	return nil
+}
+
+func (p *Prober) probeGraphsync(ctx context.Context, providerID peer.ID, addr multiaddr.Multiaddr) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if err := p.connect(ctx, providerID, addr); err != nil {
+		return err
+	}
+	supported, err := p.host.Peerstore().SupportsProtocols(providerID, gsnet.ProtocolGraphsync_2_0_0)
+	if err != nil {
+		return err
+	}
+	if len(supported) == 0 {
+		return errors.New("provider does not support graphsync")
+	}
+	return nil
+}
+
+// connect connects to the provider at the given address only, so that the
+// probe tells whether the provider is reachable at that address. The caller
+// must hold mutex.
+func (p *Prober) connect(ctx context.Context, providerID peer.ID, addr multiaddr.Multiaddr) error {
+	if p.host == nil {
+		return errNoHost
+	}
+	if providerID == p.host.ID() {
+		return errors.New("cannot probe the host of the prober")
+	}
+	if err := p.host.Network().ClosePeer(providerID); err != nil {
+		return err
+	}
+	p.host.Peerstore().ClearAddrs(providerID)
+	if err := p.host.Connect(ctx, peer.AddrInfo{ID: providerID, Addrs: []multiaddr.Multiaddr{addr}}); err != nil {
+		return fmt.Errorf("cannot connect to provider: %w", err)
+	}
+	return nil
+}
+
+// verifyBlock checks that the data hashes to the given multihash.
+func verifyBlock(mh multihash.Multihash, data []byte) error {
+	decoded, err := multihash.Decode(mh)
+	if err != nil {
+		return err
+	}
+	got, err := multihash.Sum(data, decoded.Code, decoded.Length)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(got, mh) {
+		return errors.New("block does not match multihash")
+	}
+	return nil
+}
+
+func isHttpAddr(addr multiaddr.Multiaddr) bool {
+	for _, p := range addr.Protocols() {
+		if p.Code == multiaddr.P_HTTP || p.Code == multiaddr.P_HTTPS {
+			return true
+		}
+	}
+	return false
+}
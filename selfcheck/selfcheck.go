@@ -0,0 +1,258 @@
+// Package selfcheck checks that the content advertised by a provider can
+// actually be retrieved, so that advertisements of unretrievable content are
+// noticed by operators before they are noticed by clients.
+//
+// A Checker watches an engine.Engine and, for each advertisement of content
+// that it publishes, samples a few of the advertised multihashes and attempts
+// to retrieve them from each advertised address, over each retrieval protocol
+// declared by the metadata of the advertisement. Failures are logged, counted
+// in the index-provider/selfcheck/retrievals metric, and kept along with the
+// other latest results. See Prober for how each protocol is checked.
+package selfcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/ipld/go-ipld-prime"
+	"github.com/ipni/go-libipni/ingest/schema"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/index-provider/engine"
+	"github.com/ipni/index-provider/metrics"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/multiformats/go-multicodec"
+	"github.com/multiformats/go-multihash"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var log = logging.Logger("provider/selfcheck")
+
+// Result is the outcome of attempting to retrieve a sampled multihash of an
+// advertisement from one of its addresses over one of its protocols.
+type Result struct {
+	// AdCid is the CID of the advertisement.
+	AdCid cid.Cid
+	// ContextID is the context ID of the advertisement.
+	ContextID []byte
+	// Protocol is the retrieval protocol over which retrieval was attempted.
+	Protocol multicodec.Code
+	// Addr is the address from which retrieval was attempted.
+	Addr multiaddr.Multiaddr
+	// Multihash is the sampled multihash.
+	Multihash multihash.Multihash
+	// Err is the error with which retrieval failed, if any.
+	Err error
+	// Time is the time at which retrieval was attempted.
+	Time time.Time
+}
+
+// Checker checks that the content advertised by an engine can be retrieved.
+type Checker struct {
+	*options
+	e        *engine.Engine
+	prober   *Prober
+	ownsHost host.Host
+
+	resultsMutex sync.Mutex
+	// results holds the latest results, from the earliest to the latest.
+	results []Result
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New instantiates a new Checker of the content advertised by the given
+// engine. Checking starts once Checker.Start is called.
+func New(e *engine.Engine, o ...Option) (*Checker, error) {
+	opts, err := newOptions(o...)
+	if err != nil {
+		return nil, err
+	}
+	c := &Checker{
+		options: opts,
+		e:       e,
+		done:    make(chan struct{}),
+	}
+	if opts.host == nil {
+		if c.ownsHost, err = libp2p.New(libp2p.NoListenAddrs); err != nil {
+			return nil, fmt.Errorf("cannot create libp2p host: %w", err)
+		}
+		opts.host = c.ownsHost
+	}
+	c.prober = NewProber(opts.host, opts.httpClient)
+	return c, nil
+}
+
+// Start starts checking the advertisements of content published by the engine
+// in the background, until Checker.Close is called or the engine is shut down.
+// Advertisements are checked one at a time, and those published while the
+// checker lags behind by more than the events buffered for it are skipped.
+func (c *Checker) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	events, unsubscribe := c.e.SubscribePublishEvents()
+	go func() {
+		defer close(c.done)
+		defer unsubscribe()
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				if event.Kind != engine.AdPublished || event.IsRm {
+					continue
+				}
+				if _, err := c.Check(ctx, event.AdCid); err != nil && ctx.Err() == nil {
+					log.Warnw("Cannot check retrieval of advertised content", "adCid", event.AdCid, "err", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Close stops checking, and closes the libp2p host of the checker unless it
+// was set via WithHost.
+func (c *Checker) Close() error {
+	if c.cancel != nil {
+		c.cancel()
+		<-c.done
+	}
+	err := c.prober.Close()
+	if c.ownsHost != nil {
+		err = errors.Join(err, c.ownsHost.Close())
+	}
+	return err
+}
+
+// Check samples multihashes of the advertisement with the given CID, and
+// attempts to retrieve each from each address of the advertisement over each
+// of its retrieval protocols that the address applies to. It returns the
+// result of each attempt, failed or not, and fails only if the advertisement
+// cannot be sampled. Nothing is checked for removal advertisements, or for
+// advertisements of no content.
+//
+// Only the first chunk of entries is sampled. Entries that are HAMTs cannot be
+// sampled.
+func (c *Checker) Check(ctx context.Context, adCid cid.Cid) ([]Result, error) {
+	ad, err := c.e.GetAdv(ctx, adCid)
+	if err != nil {
+		return nil, err
+	}
+	if ad.IsRm || ad.Entries == nil || ad.Entries == schema.NoEntries {
+		return nil, nil
+	}
+	providerID, err := peer.Decode(ad.Provider)
+	if err != nil {
+		return nil, fmt.Errorf("bad provider ID: %w", err)
+	}
+	addrs := make([]multiaddr.Multiaddr, 0, len(ad.Addresses))
+	for _, s := range ad.Addresses {
+		addr, err := multiaddr.NewMultiaddr(s)
+		if err != nil {
+			return nil, fmt.Errorf("bad address %q: %w", s, err)
+		}
+		addrs = append(addrs, addr)
+	}
+	md := metadata.Default.New()
+	if err = md.UnmarshalBinary(ad.Metadata); err != nil {
+		return nil, fmt.Errorf("cannot decode metadata: %w", err)
+	}
+	mhs, err := c.sample(ctx, ad.Entries)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []Result
+	for _, protocol := range md.Protocols() {
+		for _, addr := range addrs {
+			if !Supports(protocol, addr) {
+				continue
+			}
+			for _, mh := range mhs {
+				result := c.retrieve(ctx, protocol, providerID, addr, mh)
+				if errors.Is(result.Err, context.Canceled) && ctx.Err() != nil {
+					return results, ctx.Err()
+				}
+				result.AdCid = adCid
+				result.ContextID = ad.ContextID
+				results = append(results, result)
+			}
+		}
+	}
+	c.record(results)
+	return results, nil
+}
+
+// Results returns the latest results of checks, from the latest to the
+// earliest.
+func (c *Checker) Results() []Result {
+	c.resultsMutex.Lock()
+	defer c.resultsMutex.Unlock()
+	results := make([]Result, len(c.results))
+	for i := range c.results {
+		results[i] = c.results[len(c.results)-1-i]
+	}
+	return results
+}
+
+// sample picks up to the configured number of multihashes at random from the
+// first chunk of the given entries.
+func (c *Checker) sample(ctx context.Context, entries ipld.Link) ([]multihash.Multihash, error) {
+	n, err := c.e.LinkSystem().Load(ipld.LinkContext{Ctx: ctx}, entries, schema.EntryChunkPrototype)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load entries: %w", err)
+	}
+	chunk, err := schema.UnwrapEntryChunk(n)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode entries: %w", err)
+	}
+	if len(chunk.Entries) <= c.samples {
+		return chunk.Entries, nil
+	}
+	mhs := make([]multihash.Multihash, 0, c.samples)
+	for _, i := range rand.Perm(len(chunk.Entries))[:c.samples] {
+		mhs = append(mhs, chunk.Entries[i])
+	}
+	return mhs, nil
+}
+
+func (c *Checker) retrieve(ctx context.Context, protocol multicodec.Code, providerID peer.ID, addr multiaddr.Multiaddr, mh multihash.Multihash) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	result := Result{
+		Protocol:  protocol,
+		Addr:      addr,
+		Multihash: mh,
+		Time:      time.Now(),
+	}
+	result.Err = c.prober.Probe(ctx, protocol, providerID, addr, mh)
+	status := metrics.Attributes.StatusSuccess
+	if result.Err != nil {
+		status = metrics.Attributes.StatusFailure
+		log.Warnw("Cannot retrieve advertised content", "protocol", protocol, "addr", addr, "multihash", mh, "err", result.Err)
+	}
+	metrics.SelfCheck.Retrievals.Add(ctx, 1, metric.WithAttributes(attribute.String("protocol", protocol.String()), status))
+	return result
+}
+
+func (c *Checker) record(results []Result) {
+	c.resultsMutex.Lock()
+	defer c.resultsMutex.Unlock()
+	c.results = append(c.results, results...)
+	if excess := len(c.results) - c.maxResults; excess > 0 {
+		c.results = append(c.results[:0:0], c.results[excess:]...)
+	}
+}
@@ -0,0 +1,172 @@
+package selfcheck_test
+
+import (
+	"context"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/bitswap"
+	bsnet "github.com/ipfs/boxo/bitswap/network"
+	"github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/ipni/index-provider/httpgateway"
+	"github.com/ipni/index-provider/selfcheck"
+	"github.com/libp2p/go-libp2p"
+	routinghelpers "github.com/libp2p/go-libp2p-routing-helpers"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multicodec"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecker_RecordsFailedRetrievals(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	// The gateway serves all blocks but the last.
+	served := make(map[string][]byte)
+	var mhs []multihash.Multihash
+	for i := 0; i < 4; i++ {
+		data := make([]byte, 64)
+		_, _ = rand.Read(data)
+		mh, err := multihash.Sum(data, multihash.SHA2_256, -1)
+		require.NoError(t, err)
+		mhs = append(mhs, mh)
+		if i != 3 {
+			served[string(mh)] = data
+		}
+	}
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "application/vnd.ipld.raw", r.Header.Get("Accept"))
+		c, err := cid.Decode(strings.TrimPrefix(r.URL.Path, "/ipfs/"))
+		require.NoError(t, err)
+		data, ok := served[string(c.Hash())]
+		if !ok {
+			http.Error(w, "block not found", http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(data)
+	}))
+	t.Cleanup(gateway.Close)
+
+	providerID, privKey, _ := test.RandomIdentity()
+	e, err := engine.New(engine.WithPrivateKey(privKey), engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, e.Start(ctx))
+	t.Cleanup(func() { e.Shutdown() })
+	e.RegisterMultihashLister(func(_ context.Context, _ peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(mhs), nil
+	})
+
+	_, err = selfcheck.New(e, selfcheck.WithSamples(0))
+	require.Error(t, err)
+	subject, err := selfcheck.New(e, selfcheck.WithSamples(len(mhs)), selfcheck.WithMaxResults(3))
+	require.NoError(t, err)
+	subject.Start()
+	t.Cleanup(func() { require.NoError(t, subject.Close()) })
+
+	addrInfo, err := httpgateway.AddrInfo(providerID, gateway.URL)
+	require.NoError(t, err)
+	adCid, err := e.NotifyPut(ctx, addrInfo, []byte("fish"), httpgateway.Metadata())
+	require.NoError(t, err)
+
+	results, err := subject.Check(ctx, adCid)
+	require.NoError(t, err)
+	require.Len(t, results, len(mhs))
+	for _, result := range results {
+		require.Equal(t, adCid, result.AdCid)
+		require.Equal(t, []byte("fish"), result.ContextID)
+		require.Equal(t, multicodec.TransportIpfsGatewayHttp, result.Protocol)
+		require.Equal(t, addrInfo.Addrs[0], result.Addr)
+		if result.Multihash.String() == mhs[3].String() {
+			require.ErrorContains(t, result.Err, "status 404")
+		} else {
+			require.NoError(t, result.Err)
+		}
+	}
+
+	// The checker also checks the advertisement in the background, and keeps
+	// the latest results only.
+	require.Eventually(t, func() bool {
+		return len(subject.Results()) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Advertisements of content that is not retrievable over a protocol that
+	// can be probed are not checked.
+	e.RegisterMultihashLister(func(_ context.Context, _ peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(mhs[:1]), nil
+	})
+	adCid, err = e.NotifyPut(ctx, addrInfo, []byte("lobster"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	results, err = subject.Check(ctx, adCid)
+	require.NoError(t, err)
+	require.Empty(t, results)
+}
+
+func TestProber_Graphsync(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	h, err := libp2p.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { h.Close() })
+	providerHost, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	t.Cleanup(func() { providerHost.Close() })
+
+	subject := selfcheck.NewProber(h, nil)
+	t.Cleanup(func() { require.NoError(t, subject.Close()) })
+	addr := providerHost.Addrs()[0]
+	mh, err := multihash.Sum([]byte("fish"), multihash.SHA2_256, -1)
+	require.NoError(t, err)
+
+	err = subject.Probe(ctx, multicodec.TransportGraphsyncFilecoinv1, providerHost.ID(), addr, mh)
+	require.ErrorContains(t, err, "does not support graphsync")
+
+	providerHost.SetStreamHandler("/ipfs/graphsync/2.0.0", func(s network.Stream) { s.Reset() })
+	require.NoError(t, subject.Probe(ctx, multicodec.TransportGraphsyncFilecoinv1, providerHost.ID(), addr, mh))
+
+	err = subject.Probe(ctx, multicodec.Code(0x3f0000), providerHost.ID(), addr, mh)
+	require.ErrorIs(t, err, selfcheck.ErrUnsupportedProtocol)
+}
+
+func TestProber_Bitswap(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	h, err := libp2p.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { h.Close() })
+	providerHost, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	t.Cleanup(func() { providerHost.Close() })
+	bstore := blockstore.NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore()))
+	block := blocks.NewBlock([]byte("fish"))
+	require.NoError(t, bstore.Put(ctx, block))
+	bs := bitswap.New(ctx, bsnet.NewFromIpfsHost(providerHost, routinghelpers.Null{}), bstore)
+	t.Cleanup(func() { bs.Close() })
+
+	subject := selfcheck.NewProber(h, nil)
+	t.Cleanup(func() { require.NoError(t, subject.Close()) })
+	addr := providerHost.Addrs()[0]
+	require.NoError(t, subject.Probe(ctx, multicodec.TransportBitswap, providerHost.ID(), addr, block.Cid().Hash()))
+
+	missing, err := multihash.Sum([]byte("lobster"), multihash.SHA2_256, -1)
+	require.NoError(t, err)
+	shortCtx, shortCancel := context.WithTimeout(ctx, time.Second)
+	defer shortCancel()
+	err = subject.Probe(shortCtx, multicodec.TransportBitswap, providerHost.ID(), addr, missing)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
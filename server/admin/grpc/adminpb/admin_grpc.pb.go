@@ -0,0 +1,1015 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: admin.proto
+
+package adminpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	AdminService_Announce_FullMethodName               = "/ipni.provider.admin.v1.AdminService/Announce"
+	AdminService_AnnounceHttp_FullMethodName           = "/ipni.provider.admin.v1.AdminService/AnnounceHttp"
+	AdminService_Connect_FullMethodName                = "/ipni.provider.admin.v1.AdminService/Connect"
+	AdminService_Disconnect_FullMethodName             = "/ipni.provider.admin.v1.AdminService/Disconnect"
+	AdminService_Protect_FullMethodName                = "/ipni.provider.admin.v1.AdminService/Protect"
+	AdminService_Unprotect_FullMethodName              = "/ipni.provider.admin.v1.AdminService/Unprotect"
+	AdminService_ListPeers_FullMethodName              = "/ipni.provider.admin.v1.AdminService/ListPeers"
+	AdminService_GetStats_FullMethodName               = "/ipni.provider.admin.v1.AdminService/GetStats"
+	AdminService_ListLogLevels_FullMethodName          = "/ipni.provider.admin.v1.AdminService/ListLogLevels"
+	AdminService_GetLogLevel_FullMethodName            = "/ipni.provider.admin.v1.AdminService/GetLogLevel"
+	AdminService_SetLogLevel_FullMethodName            = "/ipni.provider.admin.v1.AdminService/SetLogLevel"
+	AdminService_PurgeCache_FullMethodName             = "/ipni.provider.admin.v1.AdminService/PurgeCache"
+	AdminService_CollectGarbage_FullMethodName         = "/ipni.provider.admin.v1.AdminService/CollectGarbage"
+	AdminService_ListAds_FullMethodName                = "/ipni.provider.admin.v1.AdminService/ListAds"
+	AdminService_GetAd_FullMethodName                  = "/ipni.provider.admin.v1.AdminService/GetAd"
+	AdminService_ImportCar_FullMethodName              = "/ipni.provider.admin.v1.AdminService/ImportCar"
+	AdminService_RemoveCar_FullMethodName              = "/ipni.provider.admin.v1.AdminService/RemoveCar"
+	AdminService_ListCars_FullMethodName               = "/ipni.provider.admin.v1.AdminService/ListCars"
+	AdminService_Advertise_FullMethodName              = "/ipni.provider.admin.v1.AdminService/Advertise"
+	AdminService_Remove_FullMethodName                 = "/ipni.provider.admin.v1.AdminService/Remove"
+	AdminService_RemoveContexts_FullMethodName         = "/ipni.provider.admin.v1.AdminService/RemoveContexts"
+	AdminService_ListContexts_FullMethodName           = "/ipni.provider.admin.v1.AdminService/ListContexts"
+	AdminService_SubscribePublishEvents_FullMethodName = "/ipni.provider.admin.v1.AdminService/SubscribePublishEvents"
+)
+
+// AdminServiceClient is the client API for AdminService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AdminServiceClient interface {
+	// Announce re-publishes the latest advertisement and announces it using the
+	// configured senders.
+	Announce(ctx context.Context, in *AnnounceRequest, opts ...grpc.CallOption) (*AnnounceResponse, error)
+	// AnnounceHttp announces the latest advertisement directly to the given
+	// indexers via HTTP.
+	AnnounceHttp(ctx context.Context, in *AnnounceHttpRequest, opts ...grpc.CallOption) (*AnnounceResponse, error)
+	// Connect connects to the libp2p peer at the given multiaddr.
+	Connect(ctx context.Context, in *ConnectRequest, opts ...grpc.CallOption) (*ConnectResponse, error)
+	// Disconnect closes all connections to the given peer.
+	Disconnect(ctx context.Context, in *PeerRequest, opts ...grpc.CallOption) (*PeerResponse, error)
+	// Protect protects the connections to the given peer from being trimmed by
+	// the connection manager.
+	Protect(ctx context.Context, in *PeerRequest, opts ...grpc.CallOption) (*PeerResponse, error)
+	// Unprotect removes the protection of the connections to the given peer.
+	Unprotect(ctx context.Context, in *PeerRequest, opts ...grpc.CallOption) (*PeerResponse, error)
+	// ListPeers lists the peers connected to the provider.
+	ListPeers(ctx context.Context, in *ListPeersRequest, opts ...grpc.CallOption) (*ListPeersResponse, error)
+	// GetStats gets the statistics of the engine.
+	GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*GetStatsResponse, error)
+	// ListLogLevels lists the log level of every logging subsystem.
+	ListLogLevels(ctx context.Context, in *ListLogLevelsRequest, opts ...grpc.CallOption) (*ListLogLevelsResponse, error)
+	// GetLogLevel gets the log level of a logging subsystem.
+	GetLogLevel(ctx context.Context, in *GetLogLevelRequest, opts ...grpc.CallOption) (*LogLevel, error)
+	// SetLogLevel sets the log level of a logging subsystem, or of all
+	// subsystems if the subsystem is "*".
+	SetLogLevel(ctx context.Context, in *SetLogLevelRequest, opts ...grpc.CallOption) (*LogLevel, error)
+	// PurgeCache purges the entries cache, returning once done.
+	PurgeCache(ctx context.Context, in *PurgeCacheRequest, opts ...grpc.CallOption) (*ReclaimResponse, error)
+	// CollectGarbage deletes unreferenced items from the entries cache and
+	// compacts the datastore if supported, returning once done.
+	CollectGarbage(ctx context.Context, in *CollectGarbageRequest, opts ...grpc.CallOption) (*ReclaimResponse, error)
+	// ListAds lists the advertisements published by the provider, newest first.
+	ListAds(ctx context.Context, in *ListAdsRequest, opts ...grpc.CallOption) (*ListAdsResponse, error)
+	// GetAd gets an advertisement published by the provider.
+	GetAd(ctx context.Context, in *GetAdRequest, opts ...grpc.CallOption) (*AdInfo, error)
+	// ImportCar imports and advertises the multihashes of a CAR file.
+	ImportCar(ctx context.Context, in *ImportCarRequest, opts ...grpc.CallOption) (*ImportCarResponse, error)
+	// RemoveCar removes a previously imported CAR file.
+	RemoveCar(ctx context.Context, in *RemoveCarRequest, opts ...grpc.CallOption) (*RemoveCarResponse, error)
+	// ListCars lists the paths of the imported CAR files.
+	ListCars(ctx context.Context, in *ListCarsRequest, opts ...grpc.CallOption) (*ListCarsResponse, error)
+	// Advertise publishes an advertisement for a context ID.
+	Advertise(ctx context.Context, in *AdvertiseRequest, opts ...grpc.CallOption) (*AdvertiseResponse, error)
+	// Remove publishes a removal advertisement for a context ID.
+	Remove(ctx context.Context, in *RemoveRequest, opts ...grpc.CallOption) (*RemoveResponse, error)
+	// RemoveContexts publishes removal advertisements for context IDs of the
+	// default provider.
+	RemoveContexts(ctx context.Context, in *RemoveContextsRequest, opts ...grpc.CallOption) (*RemoveContextsResponse, error)
+	// ListContexts lists the context IDs currently advertised, in ascending
+	// byte order.
+	ListContexts(ctx context.Context, in *ListContextsRequest, opts ...grpc.CallOption) (*ListContextsResponse, error)
+	// SubscribePublishEvents streams the events of advertisements being
+	// published and announced, until the client cancels the call or the
+	// provider shuts down.
+	SubscribePublishEvents(ctx context.Context, in *SubscribePublishEventsRequest, opts ...grpc.CallOption) (AdminService_SubscribePublishEventsClient, error)
+}
+
+type adminServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAdminServiceClient(cc grpc.ClientConnInterface) AdminServiceClient {
+	return &adminServiceClient{cc}
+}
+
+func (c *adminServiceClient) Announce(ctx context.Context, in *AnnounceRequest, opts ...grpc.CallOption) (*AnnounceResponse, error) {
+	out := new(AnnounceResponse)
+	err := c.cc.Invoke(ctx, AdminService_Announce_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) AnnounceHttp(ctx context.Context, in *AnnounceHttpRequest, opts ...grpc.CallOption) (*AnnounceResponse, error) {
+	out := new(AnnounceResponse)
+	err := c.cc.Invoke(ctx, AdminService_AnnounceHttp_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) Connect(ctx context.Context, in *ConnectRequest, opts ...grpc.CallOption) (*ConnectResponse, error) {
+	out := new(ConnectResponse)
+	err := c.cc.Invoke(ctx, AdminService_Connect_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) Disconnect(ctx context.Context, in *PeerRequest, opts ...grpc.CallOption) (*PeerResponse, error) {
+	out := new(PeerResponse)
+	err := c.cc.Invoke(ctx, AdminService_Disconnect_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) Protect(ctx context.Context, in *PeerRequest, opts ...grpc.CallOption) (*PeerResponse, error) {
+	out := new(PeerResponse)
+	err := c.cc.Invoke(ctx, AdminService_Protect_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) Unprotect(ctx context.Context, in *PeerRequest, opts ...grpc.CallOption) (*PeerResponse, error) {
+	out := new(PeerResponse)
+	err := c.cc.Invoke(ctx, AdminService_Unprotect_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) ListPeers(ctx context.Context, in *ListPeersRequest, opts ...grpc.CallOption) (*ListPeersResponse, error) {
+	out := new(ListPeersResponse)
+	err := c.cc.Invoke(ctx, AdminService_ListPeers_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*GetStatsResponse, error) {
+	out := new(GetStatsResponse)
+	err := c.cc.Invoke(ctx, AdminService_GetStats_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) ListLogLevels(ctx context.Context, in *ListLogLevelsRequest, opts ...grpc.CallOption) (*ListLogLevelsResponse, error) {
+	out := new(ListLogLevelsResponse)
+	err := c.cc.Invoke(ctx, AdminService_ListLogLevels_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) GetLogLevel(ctx context.Context, in *GetLogLevelRequest, opts ...grpc.CallOption) (*LogLevel, error) {
+	out := new(LogLevel)
+	err := c.cc.Invoke(ctx, AdminService_GetLogLevel_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) SetLogLevel(ctx context.Context, in *SetLogLevelRequest, opts ...grpc.CallOption) (*LogLevel, error) {
+	out := new(LogLevel)
+	err := c.cc.Invoke(ctx, AdminService_SetLogLevel_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) PurgeCache(ctx context.Context, in *PurgeCacheRequest, opts ...grpc.CallOption) (*ReclaimResponse, error) {
+	out := new(ReclaimResponse)
+	err := c.cc.Invoke(ctx, AdminService_PurgeCache_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) CollectGarbage(ctx context.Context, in *CollectGarbageRequest, opts ...grpc.CallOption) (*ReclaimResponse, error) {
+	out := new(ReclaimResponse)
+	err := c.cc.Invoke(ctx, AdminService_CollectGarbage_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) ListAds(ctx context.Context, in *ListAdsRequest, opts ...grpc.CallOption) (*ListAdsResponse, error) {
+	out := new(ListAdsResponse)
+	err := c.cc.Invoke(ctx, AdminService_ListAds_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) GetAd(ctx context.Context, in *GetAdRequest, opts ...grpc.CallOption) (*AdInfo, error) {
+	out := new(AdInfo)
+	err := c.cc.Invoke(ctx, AdminService_GetAd_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) ImportCar(ctx context.Context, in *ImportCarRequest, opts ...grpc.CallOption) (*ImportCarResponse, error) {
+	out := new(ImportCarResponse)
+	err := c.cc.Invoke(ctx, AdminService_ImportCar_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) RemoveCar(ctx context.Context, in *RemoveCarRequest, opts ...grpc.CallOption) (*RemoveCarResponse, error) {
+	out := new(RemoveCarResponse)
+	err := c.cc.Invoke(ctx, AdminService_RemoveCar_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) ListCars(ctx context.Context, in *ListCarsRequest, opts ...grpc.CallOption) (*ListCarsResponse, error) {
+	out := new(ListCarsResponse)
+	err := c.cc.Invoke(ctx, AdminService_ListCars_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) Advertise(ctx context.Context, in *AdvertiseRequest, opts ...grpc.CallOption) (*AdvertiseResponse, error) {
+	out := new(AdvertiseResponse)
+	err := c.cc.Invoke(ctx, AdminService_Advertise_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) Remove(ctx context.Context, in *RemoveRequest, opts ...grpc.CallOption) (*RemoveResponse, error) {
+	out := new(RemoveResponse)
+	err := c.cc.Invoke(ctx, AdminService_Remove_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) RemoveContexts(ctx context.Context, in *RemoveContextsRequest, opts ...grpc.CallOption) (*RemoveContextsResponse, error) {
+	out := new(RemoveContextsResponse)
+	err := c.cc.Invoke(ctx, AdminService_RemoveContexts_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) ListContexts(ctx context.Context, in *ListContextsRequest, opts ...grpc.CallOption) (*ListContextsResponse, error) {
+	out := new(ListContextsResponse)
+	err := c.cc.Invoke(ctx, AdminService_ListContexts_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) SubscribePublishEvents(ctx context.Context, in *SubscribePublishEventsRequest, opts ...grpc.CallOption) (AdminService_SubscribePublishEventsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &AdminService_ServiceDesc.Streams[0], AdminService_SubscribePublishEvents_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &adminServiceSubscribePublishEventsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type AdminService_SubscribePublishEventsClient interface {
+	Recv() (*PublishEvent, error)
+	grpc.ClientStream
+}
+
+type adminServiceSubscribePublishEventsClient struct {
+	grpc.ClientStream
+}
+
+func (x *adminServiceSubscribePublishEventsClient) Recv() (*PublishEvent, error) {
+	m := new(PublishEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// AdminServiceServer is the server API for AdminService service.
+// All implementations must embed UnimplementedAdminServiceServer
+// for forward compatibility
+type AdminServiceServer interface {
+	// Announce re-publishes the latest advertisement and announces it using the
+	// configured senders.
+	Announce(context.Context, *AnnounceRequest) (*AnnounceResponse, error)
+	// AnnounceHttp announces the latest advertisement directly to the given
+	// indexers via HTTP.
+	AnnounceHttp(context.Context, *AnnounceHttpRequest) (*AnnounceResponse, error)
+	// Connect connects to the libp2p peer at the given multiaddr.
+	Connect(context.Context, *ConnectRequest) (*ConnectResponse, error)
+	// Disconnect closes all connections to the given peer.
+	Disconnect(context.Context, *PeerRequest) (*PeerResponse, error)
+	// Protect protects the connections to the given peer from being trimmed by
+	// the connection manager.
+	Protect(context.Context, *PeerRequest) (*PeerResponse, error)
+	// Unprotect removes the protection of the connections to the given peer.
+	Unprotect(context.Context, *PeerRequest) (*PeerResponse, error)
+	// ListPeers lists the peers connected to the provider.
+	ListPeers(context.Context, *ListPeersRequest) (*ListPeersResponse, error)
+	// GetStats gets the statistics of the engine.
+	GetStats(context.Context, *GetStatsRequest) (*GetStatsResponse, error)
+	// ListLogLevels lists the log level of every logging subsystem.
+	ListLogLevels(context.Context, *ListLogLevelsRequest) (*ListLogLevelsResponse, error)
+	// GetLogLevel gets the log level of a logging subsystem.
+	GetLogLevel(context.Context, *GetLogLevelRequest) (*LogLevel, error)
+	// SetLogLevel sets the log level of a logging subsystem, or of all
+	// subsystems if the subsystem is "*".
+	SetLogLevel(context.Context, *SetLogLevelRequest) (*LogLevel, error)
+	// PurgeCache purges the entries cache, returning once done.
+	PurgeCache(context.Context, *PurgeCacheRequest) (*ReclaimResponse, error)
+	// CollectGarbage deletes unreferenced items from the entries cache and
+	// compacts the datastore if supported, returning once done.
+	CollectGarbage(context.Context, *CollectGarbageRequest) (*ReclaimResponse, error)
+	// ListAds lists the advertisements published by the provider, newest first.
+	ListAds(context.Context, *ListAdsRequest) (*ListAdsResponse, error)
+	// GetAd gets an advertisement published by the provider.
+	GetAd(context.Context, *GetAdRequest) (*AdInfo, error)
+	// ImportCar imports and advertises the multihashes of a CAR file.
+	ImportCar(context.Context, *ImportCarRequest) (*ImportCarResponse, error)
+	// RemoveCar removes a previously imported CAR file.
+	RemoveCar(context.Context, *RemoveCarRequest) (*RemoveCarResponse, error)
+	// ListCars lists the paths of the imported CAR files.
+	ListCars(context.Context, *ListCarsRequest) (*ListCarsResponse, error)
+	// Advertise publishes an advertisement for a context ID.
+	Advertise(context.Context, *AdvertiseRequest) (*AdvertiseResponse, error)
+	// Remove publishes a removal advertisement for a context ID.
+	Remove(context.Context, *RemoveRequest) (*RemoveResponse, error)
+	// RemoveContexts publishes removal advertisements for context IDs of the
+	// default provider.
+	RemoveContexts(context.Context, *RemoveContextsRequest) (*RemoveContextsResponse, error)
+	// ListContexts lists the context IDs currently advertised, in ascending
+	// byte order.
+	ListContexts(context.Context, *ListContextsRequest) (*ListContextsResponse, error)
+	// SubscribePublishEvents streams the events of advertisements being
+	// published and announced, until the client cancels the call or the
+	// provider shuts down.
+	SubscribePublishEvents(*SubscribePublishEventsRequest, AdminService_SubscribePublishEventsServer) error
+	mustEmbedUnimplementedAdminServiceServer()
+}
+
+// UnimplementedAdminServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedAdminServiceServer struct {
+}
+
+func (UnimplementedAdminServiceServer) Announce(context.Context, *AnnounceRequest) (*AnnounceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Announce not implemented")
+}
+func (UnimplementedAdminServiceServer) AnnounceHttp(context.Context, *AnnounceHttpRequest) (*AnnounceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AnnounceHttp not implemented")
+}
+func (UnimplementedAdminServiceServer) Connect(context.Context, *ConnectRequest) (*ConnectResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Connect not implemented")
+}
+func (UnimplementedAdminServiceServer) Disconnect(context.Context, *PeerRequest) (*PeerResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Disconnect not implemented")
+}
+func (UnimplementedAdminServiceServer) Protect(context.Context, *PeerRequest) (*PeerResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Protect not implemented")
+}
+func (UnimplementedAdminServiceServer) Unprotect(context.Context, *PeerRequest) (*PeerResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Unprotect not implemented")
+}
+func (UnimplementedAdminServiceServer) ListPeers(context.Context, *ListPeersRequest) (*ListPeersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListPeers not implemented")
+}
+func (UnimplementedAdminServiceServer) GetStats(context.Context, *GetStatsRequest) (*GetStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStats not implemented")
+}
+func (UnimplementedAdminServiceServer) ListLogLevels(context.Context, *ListLogLevelsRequest) (*ListLogLevelsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListLogLevels not implemented")
+}
+func (UnimplementedAdminServiceServer) GetLogLevel(context.Context, *GetLogLevelRequest) (*LogLevel, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLogLevel not implemented")
+}
+func (UnimplementedAdminServiceServer) SetLogLevel(context.Context, *SetLogLevelRequest) (*LogLevel, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetLogLevel not implemented")
+}
+func (UnimplementedAdminServiceServer) PurgeCache(context.Context, *PurgeCacheRequest) (*ReclaimResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PurgeCache not implemented")
+}
+func (UnimplementedAdminServiceServer) CollectGarbage(context.Context, *CollectGarbageRequest) (*ReclaimResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CollectGarbage not implemented")
+}
+func (UnimplementedAdminServiceServer) ListAds(context.Context, *ListAdsRequest) (*ListAdsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAds not implemented")
+}
+func (UnimplementedAdminServiceServer) GetAd(context.Context, *GetAdRequest) (*AdInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAd not implemented")
+}
+func (UnimplementedAdminServiceServer) ImportCar(context.Context, *ImportCarRequest) (*ImportCarResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ImportCar not implemented")
+}
+func (UnimplementedAdminServiceServer) RemoveCar(context.Context, *RemoveCarRequest) (*RemoveCarResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveCar not implemented")
+}
+func (UnimplementedAdminServiceServer) ListCars(context.Context, *ListCarsRequest) (*ListCarsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListCars not implemented")
+}
+func (UnimplementedAdminServiceServer) Advertise(context.Context, *AdvertiseRequest) (*AdvertiseResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Advertise not implemented")
+}
+func (UnimplementedAdminServiceServer) Remove(context.Context, *RemoveRequest) (*RemoveResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Remove not implemented")
+}
+func (UnimplementedAdminServiceServer) RemoveContexts(context.Context, *RemoveContextsRequest) (*RemoveContextsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveContexts not implemented")
+}
+func (UnimplementedAdminServiceServer) ListContexts(context.Context, *ListContextsRequest) (*ListContextsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListContexts not implemented")
+}
+func (UnimplementedAdminServiceServer) SubscribePublishEvents(*SubscribePublishEventsRequest, AdminService_SubscribePublishEventsServer) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribePublishEvents not implemented")
+}
+func (UnimplementedAdminServiceServer) mustEmbedUnimplementedAdminServiceServer() {}
+
+// UnsafeAdminServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AdminServiceServer will
+// result in compilation errors.
+type UnsafeAdminServiceServer interface {
+	mustEmbedUnimplementedAdminServiceServer()
+}
+
+func RegisterAdminServiceServer(s grpc.ServiceRegistrar, srv AdminServiceServer) {
+	s.RegisterService(&AdminService_ServiceDesc, srv)
+}
+
+func _AdminService_Announce_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AnnounceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).Announce(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_Announce_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).Announce(ctx, req.(*AnnounceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_AnnounceHttp_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AnnounceHttpRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).AnnounceHttp(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_AnnounceHttp_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).AnnounceHttp(ctx, req.(*AnnounceHttpRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_Connect_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConnectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).Connect(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_Connect_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).Connect(ctx, req.(*ConnectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_Disconnect_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PeerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).Disconnect(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_Disconnect_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).Disconnect(ctx, req.(*PeerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_Protect_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PeerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).Protect(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_Protect_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).Protect(ctx, req.(*PeerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_Unprotect_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PeerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).Unprotect(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_Unprotect_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).Unprotect(ctx, req.(*PeerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_ListPeers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListPeersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).ListPeers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_ListPeers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).ListPeers(ctx, req.(*ListPeersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_GetStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).GetStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_GetStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).GetStats(ctx, req.(*GetStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_ListLogLevels_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListLogLevelsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).ListLogLevels(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_ListLogLevels_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).ListLogLevels(ctx, req.(*ListLogLevelsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_GetLogLevel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLogLevelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).GetLogLevel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_GetLogLevel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).GetLogLevel(ctx, req.(*GetLogLevelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_SetLogLevel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetLogLevelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).SetLogLevel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_SetLogLevel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).SetLogLevel(ctx, req.(*SetLogLevelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_PurgeCache_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PurgeCacheRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).PurgeCache(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_PurgeCache_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).PurgeCache(ctx, req.(*PurgeCacheRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_CollectGarbage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CollectGarbageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).CollectGarbage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_CollectGarbage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).CollectGarbage(ctx, req.(*CollectGarbageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_ListAds_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAdsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).ListAds(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_ListAds_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).ListAds(ctx, req.(*ListAdsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_GetAd_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetAdRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).GetAd(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_GetAd_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).GetAd(ctx, req.(*GetAdRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_ImportCar_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImportCarRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).ImportCar(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_ImportCar_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).ImportCar(ctx, req.(*ImportCarRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_RemoveCar_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveCarRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).RemoveCar(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_RemoveCar_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).RemoveCar(ctx, req.(*RemoveCarRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_ListCars_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCarsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).ListCars(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_ListCars_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).ListCars(ctx, req.(*ListCarsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_Advertise_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdvertiseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).Advertise(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_Advertise_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).Advertise(ctx, req.(*AdvertiseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_Remove_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).Remove(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_Remove_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).Remove(ctx, req.(*RemoveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_RemoveContexts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveContextsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).RemoveContexts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_RemoveContexts_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).RemoveContexts(ctx, req.(*RemoveContextsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_ListContexts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListContextsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).ListContexts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_ListContexts_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).ListContexts(ctx, req.(*ListContextsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_SubscribePublishEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribePublishEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AdminServiceServer).SubscribePublishEvents(m, &adminServiceSubscribePublishEventsServer{stream})
+}
+
+type AdminService_SubscribePublishEventsServer interface {
+	Send(*PublishEvent) error
+	grpc.ServerStream
+}
+
+type adminServiceSubscribePublishEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *adminServiceSubscribePublishEventsServer) Send(m *PublishEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// AdminService_ServiceDesc is the grpc.ServiceDesc for AdminService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AdminService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "ipni.provider.admin.v1.AdminService",
+	HandlerType: (*AdminServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Announce",
+			Handler:    _AdminService_Announce_Handler,
+		},
+		{
+			MethodName: "AnnounceHttp",
+			Handler:    _AdminService_AnnounceHttp_Handler,
+		},
+		{
+			MethodName: "Connect",
+			Handler:    _AdminService_Connect_Handler,
+		},
+		{
+			MethodName: "Disconnect",
+			Handler:    _AdminService_Disconnect_Handler,
+		},
+		{
+			MethodName: "Protect",
+			Handler:    _AdminService_Protect_Handler,
+		},
+		{
+			MethodName: "Unprotect",
+			Handler:    _AdminService_Unprotect_Handler,
+		},
+		{
+			MethodName: "ListPeers",
+			Handler:    _AdminService_ListPeers_Handler,
+		},
+		{
+			MethodName: "GetStats",
+			Handler:    _AdminService_GetStats_Handler,
+		},
+		{
+			MethodName: "ListLogLevels",
+			Handler:    _AdminService_ListLogLevels_Handler,
+		},
+		{
+			MethodName: "GetLogLevel",
+			Handler:    _AdminService_GetLogLevel_Handler,
+		},
+		{
+			MethodName: "SetLogLevel",
+			Handler:    _AdminService_SetLogLevel_Handler,
+		},
+		{
+			MethodName: "PurgeCache",
+			Handler:    _AdminService_PurgeCache_Handler,
+		},
+		{
+			MethodName: "CollectGarbage",
+			Handler:    _AdminService_CollectGarbage_Handler,
+		},
+		{
+			MethodName: "ListAds",
+			Handler:    _AdminService_ListAds_Handler,
+		},
+		{
+			MethodName: "GetAd",
+			Handler:    _AdminService_GetAd_Handler,
+		},
+		{
+			MethodName: "ImportCar",
+			Handler:    _AdminService_ImportCar_Handler,
+		},
+		{
+			MethodName: "RemoveCar",
+			Handler:    _AdminService_RemoveCar_Handler,
+		},
+		{
+			MethodName: "ListCars",
+			Handler:    _AdminService_ListCars_Handler,
+		},
+		{
+			MethodName: "Advertise",
+			Handler:    _AdminService_Advertise_Handler,
+		},
+		{
+			MethodName: "Remove",
+			Handler:    _AdminService_Remove_Handler,
+		},
+		{
+			MethodName: "RemoveContexts",
+			Handler:    _AdminService_RemoveContexts_Handler,
+		},
+		{
+			MethodName: "ListContexts",
+			Handler:    _AdminService_ListContexts_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SubscribePublishEvents",
+			Handler:       _AdminService_SubscribePublishEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "admin.proto",
+}
@@ -0,0 +1,5 @@
+// Package adminpb contains the protocol buffer definitions of the admin gRPC
+// service of the provider, along with the Go code generated from them.
+package adminpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative admin.proto
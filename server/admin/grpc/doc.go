@@ -0,0 +1,14 @@
+// Package admingrpc provides a gRPC server that allows to perform administrative
+// operations, as does the admin HTTP server, such that orchestration systems
+// with gRPC tooling can integrate with the provider.
+//
+// In addition to the operations of the admin HTTP server, the admin service
+// streams the events of advertisements being published and announced via
+// SubscribePublishEvents, so that clients need not poll. Purging the entries
+// cache and collecting garbage run synchronously; the deadline of the call
+// bounds their duration.
+//
+// The standard gRPC health service is served too. Checking the empty service
+// checks liveness, and checking "ipni.provider.admin.v1.AdminService" checks
+// readiness.
+package admingrpc
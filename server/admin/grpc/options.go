@@ -0,0 +1,93 @@
+package admingrpc
+
+import (
+	"crypto/tls"
+
+	"github.com/ipni/go-libipni/metadata"
+
+	"github.com/ipni/index-provider/supplier"
+)
+
+type (
+	// Option captures a configurable parameter in admin gRPC server.
+	Option func(*options) error
+
+	options struct {
+		listenAddr  string
+		bearerToken string
+		tlsConfig   *tls.Config
+
+		multihashSupplier *supplier.MultihashSupplier
+		defaultMetadata   func(contextID []byte) (metadata.Metadata, error)
+	}
+)
+
+func newOptions(o ...Option) (*options, error) {
+	opts := &options{
+		listenAddr: "0.0.0.0:3105",
+	}
+
+	for _, apply := range o {
+		if err := apply(opts); err != nil {
+			return nil, err
+		}
+	}
+	return opts, nil
+}
+
+// WithListenAddr sets the net address on which the admin gRPC server is
+// exposed. If unset, the default address of '0.0.0.0:3105' is used.
+func WithListenAddr(addr string) Option {
+	return func(o *options) error {
+		o.listenAddr = addr
+		return nil
+	}
+}
+
+// WithBearerToken sets the token that calls to the admin service must present
+// in an "authorization: Bearer <token>" metadata entry. Calls without the token
+// fail with codes.Unauthenticated. Health checks are not authenticated.
+// If unset, calls are not authenticated.
+func WithBearerToken(token string) Option {
+	return func(o *options) error {
+		o.bearerToken = token
+		return nil
+	}
+}
+
+// WithTLSConfig sets the TLS configuration with which the admin gRPC server
+// serves. As with the admin HTTP server, setting ClientAuth to
+// tls.RequireAndVerifyClientCert requires clients to present a certificate
+// trusted by ClientCAs.
+// If unset, the server serves without transport security.
+func WithTLSConfig(c *tls.Config) Option {
+	return func(o *options) error {
+		o.tlsConfig = c
+		return nil
+	}
+}
+
+// WithMultihashSupplier sets the supplier via which multihashes given in
+// Advertise calls are advertised. The supplier must be registered as a
+// multihash lister of the engine, e.g. via supplier.ChainListers.
+// If unset, only the multihashes already listed by the engine for a context ID
+// can be advertised.
+func WithMultihashSupplier(ms *supplier.MultihashSupplier) Option {
+	return func(o *options) error {
+		o.multihashSupplier = ms
+		return nil
+	}
+}
+
+// WithDefaultMetadata sets the function that returns the retrieval metadata of
+// the advertisements requested via Advertise and ImportCar calls without
+// metadata.
+// If unset, Advertise calls must specify metadata, and CARs imported without
+// metadata are advertised as retrievable over graphsync from the CAR data
+// transfer, as described by cardatatransfer.TransportFromContextID.
+func WithDefaultMetadata(f func(contextID []byte) (metadata.Metadata, error)) Option {
+	return func(o *options) error {
+		o.defaultMetadata = f
+		return nil
+	}
+}
@@ -0,0 +1,151 @@
+package admingrpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"net"
+	"strings"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/ipni/index-provider/engine"
+	"github.com/ipni/index-provider/server/admin/grpc/adminpb"
+	"github.com/ipni/index-provider/supplier"
+	"github.com/libp2p/go-libp2p/core/host"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var log = logging.Logger("admingrpc")
+
+// Server serves the admin service, along with the standard gRPC health service,
+// over gRPC.
+type Server struct {
+	server *grpc.Server
+	l      net.Listener
+	// done is closed on shutdown to end the streams of subscribers.
+	done chan struct{}
+}
+
+// New instantiates a new admin gRPC server that operates on the given host,
+// engine and CAR supplier.
+func New(h host.Host, e *engine.Engine, cs *supplier.CarSupplier, o ...Option) (*Server, error) {
+	opts, err := newOptions(o...)
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := net.Listen("tcp", opts.listenAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	var serverOpts []grpc.ServerOption
+	if opts.tlsConfig != nil {
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(opts.tlsConfig)))
+	}
+	if opts.bearerToken != "" {
+		want := []byte("Bearer " + opts.bearerToken)
+		serverOpts = append(serverOpts,
+			grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+				if err := authorize(ctx, want, info.FullMethod); err != nil {
+					return nil, err
+				}
+				return handler(ctx, req)
+			}),
+			grpc.StreamInterceptor(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+				if err := authorize(ss.Context(), want, info.FullMethod); err != nil {
+					return err
+				}
+				return handler(srv, ss)
+			}))
+	}
+
+	s := &Server{
+		server: grpc.NewServer(serverOpts...),
+		l:      l,
+		done:   make(chan struct{}),
+	}
+	adminpb.RegisterAdminServiceServer(s.server, &adminService{
+		h:    h,
+		e:    e,
+		cs:   cs,
+		ms:   opts.multihashSupplier,
+		done: s.done,
+
+		defaultMetadata: opts.defaultMetadata,
+	})
+	grpc_health_v1.RegisterHealthServer(s.server, &healthService{e: e})
+	return s, nil
+}
+
+func (s *Server) Start() error {
+	log.Infow("admin grpc server listening", "addr", s.l.Addr())
+	return s.server.Serve(s.l)
+}
+
+// Shutdown gracefully stops the server, ending the streams of subscribers to
+// publish events. Calls still in progress when the context ends are aborted.
+func (s *Server) Shutdown(ctx context.Context) error {
+	log.Info("admin grpc server shutdown")
+	close(s.done)
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		return ctx.Err()
+	}
+}
+
+// authorize checks that the call to the given method presents the wanted
+// authorization. Health checks are not authorized so that they are usable by
+// orchestrators such as Kubernetes.
+func authorize(ctx context.Context, want []byte, method string) error {
+	if strings.HasPrefix(method, "/"+grpc_health_v1.Health_ServiceDesc.ServiceName+"/") {
+		return nil
+	}
+	var got []byte
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get("authorization"); len(v) != 0 {
+			got = []byte(v[0])
+		}
+	}
+	if subtle.ConstantTimeCompare(got, want) != 1 {
+		return status.Error(codes.Unauthenticated, "missing or invalid bearer token")
+	}
+	return nil
+}
+
+// healthService implements the standard gRPC health service. Checking the
+// empty service checks the liveness of the engine, and checking the admin
+// service checks whether the engine is ready to publish advertisements.
+type healthService struct {
+	grpc_health_v1.UnimplementedHealthServer
+	e *engine.Engine
+}
+
+func (h *healthService) Check(ctx context.Context, req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	var err error
+	switch req.GetService() {
+	case "":
+		err = h.e.Healthy()
+	case adminpb.AdminService_ServiceDesc.ServiceName:
+		err = h.e.Ready(ctx)
+	default:
+		return nil, status.Errorf(codes.NotFound, "unknown service %q", req.GetService())
+	}
+	if err != nil {
+		log.Warnw("Health check failed", "service", req.GetService(), "err", err)
+		return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_NOT_SERVING}, nil
+	}
+	return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
+}
@@ -0,0 +1,172 @@
+package admingrpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	"github.com/ipni/index-provider/engine"
+	"github.com/ipni/index-provider/server/admin/grpc/adminpb"
+	"github.com/ipni/index-provider/supplier"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	grpcmd "google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func newTestHost(t *testing.T) host.Host {
+	h, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+func TestServer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	eng, err := engine.New(
+		engine.WithPublisherKind(engine.HttpPublisher),
+		engine.WithHttpPublisherListenAddr("127.0.0.1:0"),
+		engine.WithPubsubAnnounce(false))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	cs := supplier.NewCarSupplier(eng, ds)
+	ms := supplier.NewMultihashSupplier(eng, ds)
+	eng.RegisterMultihashLister(supplier.ChainListers(cs.ListMultihashes, ms.ListMultihashes))
+
+	h := newTestHost(t)
+	subject, err := New(h, eng, cs,
+		WithListenAddr("127.0.0.1:0"),
+		WithBearerToken("fish"),
+		WithMultihashSupplier(ms))
+	require.NoError(t, err)
+	served := make(chan error, 1)
+	go func() { served <- subject.Start() }()
+
+	conn, err := grpc.Dial(subject.l.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	client := adminpb.NewAdminServiceClient(conn)
+
+	// Health checks are not authenticated.
+	health := grpc_health_v1.NewHealthClient(conn)
+	check, err := health.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	require.NoError(t, err)
+	require.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, check.GetStatus())
+	check, err = health.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: adminpb.AdminService_ServiceDesc.ServiceName})
+	require.NoError(t, err)
+	require.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, check.GetStatus())
+
+	_, err = client.GetStats(ctx, &adminpb.GetStatsRequest{})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	authCtx := grpcmd.AppendToOutgoingContext(ctx, "authorization", "Bearer fish")
+	stats, err := client.GetStats(authCtx, &adminpb.GetStatsRequest{})
+	require.NoError(t, err)
+	require.Empty(t, stats.GetHead())
+
+	// Subscribe to publish events, and wait for the subscription to be set up.
+	streamCtx, cancelStream := context.WithCancel(authCtx)
+	defer cancelStream()
+	events, err := client.SubscribePublishEvents(streamCtx, &adminpb.SubscribePublishEventsRequest{})
+	require.NoError(t, err)
+	_, err = events.Header()
+	require.NoError(t, err)
+
+	bitswap := metadata.Default.New(metadata.Bitswap{})
+	md, err := bitswap.MarshalBinary()
+	require.NoError(t, err)
+	var mhs [][]byte
+	for _, mh := range test.RandomMultihashes(3) {
+		mhs = append(mhs, mh)
+	}
+	advertised, err := client.Advertise(authCtx, &adminpb.AdvertiseRequest{
+		ContextId:   []byte("fish"),
+		Metadata:    md,
+		Multihashes: mhs,
+	})
+	require.NoError(t, err)
+
+	event, err := events.Recv()
+	require.NoError(t, err)
+	require.Equal(t, adminpb.PublishEvent_KIND_PUBLISHED, event.GetKind())
+	require.Equal(t, advertised.GetAdCid(), event.GetAdCid())
+	require.False(t, event.GetIsRm())
+	event, err = events.Recv()
+	require.NoError(t, err)
+	require.Equal(t, adminpb.PublishEvent_KIND_ANNOUNCED, event.GetKind())
+	require.Equal(t, advertised.GetAdCid(), event.GetAdCid())
+
+	_, err = client.Advertise(authCtx, &adminpb.AdvertiseRequest{
+		ContextId:   []byte("fish"),
+		Metadata:    md,
+		Multihashes: mhs,
+	})
+	require.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	// Metadata is required without default metadata.
+	_, err = client.Advertise(authCtx, &adminpb.AdvertiseRequest{
+		ContextId:   []byte("lobster"),
+		Multihashes: mhs,
+	})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	contexts, err := client.ListContexts(authCtx, &adminpb.ListContextsRequest{})
+	require.NoError(t, err)
+	require.Len(t, contexts.GetContexts(), 1)
+	require.Equal(t, []byte("fish"), contexts.GetContexts()[0].GetContextId())
+	require.Equal(t, int64(3), contexts.GetContexts()[0].GetMultihashCount())
+	require.Equal(t, []string{"transport-bitswap"}, contexts.GetContexts()[0].GetProtocols())
+	stats, err = client.GetStats(authCtx, &adminpb.GetStatsRequest{})
+	require.NoError(t, err)
+	require.Equal(t, int64(3), stats.GetMultihashes())
+
+	ads, err := client.ListAds(authCtx, &adminpb.ListAdsRequest{})
+	require.NoError(t, err)
+	require.Len(t, ads.GetAds(), 1)
+	require.Equal(t, advertised.GetAdCid(), ads.GetAds()[0].GetId())
+
+	removed, err := client.Remove(authCtx, &adminpb.RemoveRequest{ContextId: []byte("fish")})
+	require.NoError(t, err)
+	event, err = events.Recv()
+	require.NoError(t, err)
+	require.Equal(t, adminpb.PublishEvent_KIND_PUBLISHED, event.GetKind())
+	require.Equal(t, removed.GetAdCid(), event.GetAdCid())
+	require.True(t, event.GetIsRm())
+	event, err = events.Recv()
+	require.NoError(t, err)
+	require.Equal(t, adminpb.PublishEvent_KIND_ANNOUNCED, event.GetKind())
+
+	_, err = client.Remove(authCtx, &adminpb.RemoveRequest{ContextId: []byte("fish")})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.GetAd(authCtx, &adminpb.GetAdRequest{Cid: "fish"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	level, err := client.SetLogLevel(authCtx, &adminpb.SetLogLevelRequest{Subsystem: "admingrpc", Level: "debug"})
+	require.NoError(t, err)
+	require.Equal(t, "debug", level.GetLevel())
+	_, err = client.GetLogLevel(authCtx, &adminpb.GetLogLevelRequest{Subsystem: "no-such-subsystem"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	peers, err := client.ListPeers(authCtx, &adminpb.ListPeersRequest{})
+	require.NoError(t, err)
+	require.Empty(t, peers.GetPeers())
+
+	// Shutting down ends the streams of subscribers.
+	require.NoError(t, subject.Shutdown(ctx))
+	_, err = events.Recv()
+	require.Equal(t, codes.Unavailable, status.Code(err))
+	require.NoError(t, <-served)
+}
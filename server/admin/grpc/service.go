@@ -0,0 +1,738 @@
+package admingrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log/v2"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipni/go-libipni/ingest/schema"
+	"github.com/ipni/go-libipni/metadata"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/cardatatransfer"
+	"github.com/ipni/index-provider/engine"
+	"github.com/ipni/index-provider/server/admin/grpc/adminpb"
+	"github.com/ipni/index-provider/supplier"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/multiformats/go-multihash"
+	"google.golang.org/grpc/codes"
+	grpcmd "google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// defaultProtectTag is the tag with which connections are protected when
+	// no tag is specified.
+	defaultProtectTag = "admin"
+
+	defaultListAdsLimit      = 100
+	maxListAdsLimit          = 1000
+	defaultListContextsLimit = 1000
+	maxListContextsLimit     = 10000
+)
+
+type adminService struct {
+	adminpb.UnimplementedAdminServiceServer
+
+	h  host.Host
+	e  *engine.Engine
+	cs *supplier.CarSupplier
+	ms *supplier.MultihashSupplier
+
+	done <-chan struct{}
+
+	defaultMetadata func(contextID []byte) (metadata.Metadata, error)
+}
+
+func (s *adminService) Announce(ctx context.Context, _ *adminpb.AnnounceRequest) (*adminpb.AnnounceResponse, error) {
+	adCid, err := s.e.PublishLatest(ctx)
+	if err != nil {
+		log.Errorw("Could not republish latest advertisement", "err", err)
+		return nil, announceError(adCid, err)
+	}
+	return &adminpb.AnnounceResponse{AdCid: cidString(adCid)}, nil
+}
+
+func (s *adminService) AnnounceHttp(ctx context.Context, req *adminpb.AnnounceHttpRequest) (*adminpb.AnnounceResponse, error) {
+	if len(req.GetIndexers()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "missing indexer url in request")
+	}
+	indexerURLs := make([]*url.URL, 0, len(req.GetIndexers()))
+	for _, indexer := range req.GetIndexers() {
+		indexerURL, err := url.Parse(indexer)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		indexerURLs = append(indexerURLs, indexerURL)
+	}
+	adCid, err := s.e.PublishLatestHTTP(ctx, indexerURLs...)
+	if err != nil {
+		log.Errorw("Could not publish latest advertisement via http", "err", err)
+		return nil, announceError(adCid, err)
+	}
+	return &adminpb.AnnounceResponse{AdCid: cidString(adCid)}, nil
+}
+
+// announceError returns the status of a failure to announce the given
+// advertisement. If the advertisement is defined, then it was published and
+// only its announcement failed.
+func announceError(adCid cid.Cid, err error) error {
+	if adCid.Defined() {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+func (s *adminService) Connect(ctx context.Context, req *adminpb.ConnectRequest) (*adminpb.ConnectResponse, error) {
+	maddr, err := multiaddr.NewMultiaddr(req.GetMaddr())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to parse multiaddr: %v", err)
+	}
+	addrInfo, err := peer.AddrInfoFromP2pAddr(maddr)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to create addrInfo from multiaddr: %v", err)
+	}
+	if err = s.h.Connect(ctx, *addrInfo); err != nil {
+		msg := fmt.Sprintf("failed to connect to peer: %v", err)
+		log.Errorw(msg, "err", err)
+		return nil, status.Error(codes.Internal, msg)
+	}
+	log.Infow("Connected to peer successfully", "addrInfo", addrInfo)
+	return &adminpb.ConnectResponse{}, nil
+}
+
+func (s *adminService) Disconnect(_ context.Context, req *adminpb.PeerRequest) (*adminpb.PeerResponse, error) {
+	p, _, err := decodePeerRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.h.Network().ClosePeer(p); err != nil {
+		msg := fmt.Sprintf("failed to disconnect from peer: %v", err)
+		log.Errorw(msg, "err", err)
+		return nil, status.Error(codes.Internal, msg)
+	}
+	log.Infow("Disconnected from peer", "peer", p)
+	return &adminpb.PeerResponse{}, nil
+}
+
+func (s *adminService) Protect(_ context.Context, req *adminpb.PeerRequest) (*adminpb.PeerResponse, error) {
+	p, tag, err := decodePeerRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	s.h.ConnManager().Protect(p, tag)
+	log.Infow("Protected peer", "peer", p, "tag", tag)
+	return &adminpb.PeerResponse{}, nil
+}
+
+func (s *adminService) Unprotect(_ context.Context, req *adminpb.PeerRequest) (*adminpb.PeerResponse, error) {
+	p, tag, err := decodePeerRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	s.h.ConnManager().Unprotect(p, tag)
+	log.Infow("Unprotected peer", "peer", p, "tag", tag)
+	return &adminpb.PeerResponse{}, nil
+}
+
+// decodePeerRequest decodes the peer ID of the given request, and its tag
+// defaulted to defaultProtectTag.
+func decodePeerRequest(req *adminpb.PeerRequest) (peer.ID, string, error) {
+	p, err := peer.Decode(req.GetPeerId())
+	if err != nil {
+		return "", "", status.Error(codes.InvalidArgument, "valid peer ID must be specified")
+	}
+	tag := req.GetTag()
+	if tag == "" {
+		tag = defaultProtectTag
+	}
+	return p, tag, nil
+}
+
+func (s *adminService) ListPeers(context.Context, *adminpb.ListPeersRequest) (*adminpb.ListPeersResponse, error) {
+	cm := s.h.ConnManager()
+	peers := s.h.Network().Peers()
+	resp := &adminpb.ListPeersResponse{Peers: make([]*adminpb.PeerInfo, 0, len(peers))}
+	for _, p := range peers {
+		conns := s.h.Network().ConnsToPeer(p)
+		if len(conns) == 0 {
+			continue
+		}
+		info := &adminpb.PeerInfo{
+			Id:        p.String(),
+			Addrs:     make([]string, 0, len(conns)),
+			Direction: directionString(conns[0].Stat().Direction),
+			Protected: cm.IsProtected(p, ""),
+		}
+		for _, c := range conns {
+			info.Addrs = append(info.Addrs, c.RemoteMultiaddr().String())
+		}
+		if protos, err := s.h.Peerstore().GetProtocols(p); err == nil {
+			for _, proto := range protos {
+				info.Protocols = append(info.Protocols, string(proto))
+			}
+			sort.Strings(info.Protocols)
+		}
+		resp.Peers = append(resp.Peers, info)
+	}
+	sort.Slice(resp.Peers, func(i, j int) bool { return resp.Peers[i].Id < resp.Peers[j].Id })
+	return resp, nil
+}
+
+func directionString(d network.Direction) string {
+	switch d {
+	case network.DirInbound:
+		return "inbound"
+	case network.DirOutbound:
+		return "outbound"
+	default:
+		return "unknown"
+	}
+}
+
+func (s *adminService) GetStats(ctx context.Context, _ *adminpb.GetStatsRequest) (*adminpb.GetStatsResponse, error) {
+	stats, err := s.e.Stats(ctx)
+	if err != nil {
+		err = fmt.Errorf("failed to get engine stats: %w", err)
+		log.Error(err)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &adminpb.GetStatsResponse{
+		Head:              cidString(stats.Head),
+		ChainLength:       int64(stats.ChainLength),
+		Contexts:          int64(stats.Contexts),
+		Multihashes:       stats.Multihashes,
+		CachedChunks:      int64(stats.CachedChunks),
+		CacheCapacity:     int64(stats.CacheCapacity),
+		AnnounceSuccesses: stats.AnnounceSuccesses,
+		AnnounceFailures:  stats.AnnounceFailures,
+		LastPublished:     timestamp(stats.LastPublished),
+	}, nil
+}
+
+func (s *adminService) ListLogLevels(context.Context, *adminpb.ListLogLevelsRequest) (*adminpb.ListLogLevelsResponse, error) {
+	resp := &adminpb.ListLogLevelsResponse{Levels: make(map[string]string)}
+	for _, subsystem := range logging.GetSubsystems() {
+		resp.Levels[subsystem] = logLevel(subsystem)
+	}
+	return resp, nil
+}
+
+func (s *adminService) GetLogLevel(_ context.Context, req *adminpb.GetLogLevelRequest) (*adminpb.LogLevel, error) {
+	subsystem := req.GetSubsystem()
+	if subsystem == "" {
+		return nil, status.Error(codes.InvalidArgument, "subsystem must be specified")
+	}
+	if !hasSubsystem(subsystem) {
+		return nil, status.Errorf(codes.NotFound, "logging subsystem %q not found", subsystem)
+	}
+	return &adminpb.LogLevel{Subsystem: subsystem, Level: logLevel(subsystem)}, nil
+}
+
+func (s *adminService) SetLogLevel(_ context.Context, req *adminpb.SetLogLevelRequest) (*adminpb.LogLevel, error) {
+	subsystem := req.GetSubsystem()
+	if subsystem == "" {
+		return nil, status.Error(codes.InvalidArgument, "subsystem must be specified")
+	}
+	if _, err := logging.LevelFromString(req.GetLevel()); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid log level %q", req.GetLevel())
+	}
+	if err := logging.SetLogLevel(subsystem, req.GetLevel()); err != nil {
+		if errors.Is(err, logging.ErrNoSuchLogger) {
+			return nil, status.Errorf(codes.NotFound, "logging subsystem %q not found", subsystem)
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	log.Infow("Set log level", "subsystem", subsystem, "level", req.GetLevel())
+	level := strings.ToLower(req.GetLevel())
+	if subsystem != "*" {
+		level = logLevel(subsystem)
+	}
+	return &adminpb.LogLevel{Subsystem: subsystem, Level: level}, nil
+}
+
+func hasSubsystem(subsystem string) bool {
+	for _, s := range logging.GetSubsystems() {
+		if s == subsystem {
+			return true
+		}
+	}
+	return false
+}
+
+// logLevel returns the name of the current log level of the given existing
+// logging subsystem.
+func logLevel(subsystem string) string {
+	return logging.Logger(subsystem).Level().String()
+}
+
+func (s *adminService) PurgeCache(ctx context.Context, _ *adminpb.PurgeCacheRequest) (*adminpb.ReclaimResponse, error) {
+	reclaimed, err := s.e.PurgeCache(ctx)
+	if err != nil {
+		err = fmt.Errorf("failed to purge entries cache: %w", err)
+		log.Error(err)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	log.Infow("Purged entries cache", "bytesReclaimed", reclaimed)
+	return &adminpb.ReclaimResponse{BytesReclaimed: reclaimed}, nil
+}
+
+func (s *adminService) CollectGarbage(ctx context.Context, _ *adminpb.CollectGarbageRequest) (*adminpb.ReclaimResponse, error) {
+	reclaimed, err := s.e.CollectGarbage(ctx)
+	if err != nil {
+		err = fmt.Errorf("failed to collect garbage: %w", err)
+		log.Error(err)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	log.Infow("Collected garbage", "bytesReclaimed", reclaimed)
+	return &adminpb.ReclaimResponse{BytesReclaimed: reclaimed}, nil
+}
+
+func (s *adminService) ListAds(ctx context.Context, req *adminpb.ListAdsRequest) (*adminpb.ListAdsResponse, error) {
+	limit := int(req.GetLimit())
+	if limit < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit must not be negative")
+	}
+	if limit == 0 {
+		limit = defaultListAdsLimit
+	} else if limit > maxListAdsLimit {
+		limit = maxListAdsLimit
+	}
+
+	var next cid.Cid
+	if req.GetAfter() != "" {
+		after, err := cid.Decode(req.GetAfter())
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "after is not a valid CID")
+		}
+		info, err := s.e.GetAdInfo(ctx, after)
+		if err != nil {
+			return nil, adError(after, err)
+		}
+		next = info.Advertisement.PreviousCid()
+	} else {
+		var err error
+		next, _, err = s.e.GetLatestAdv(ctx)
+		if err != nil {
+			err = fmt.Errorf("failed to get latest advertisement: %w", err)
+			log.Error(err)
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	resp := &adminpb.ListAdsResponse{}
+	for next != cid.Undef {
+		if len(resp.Ads) == limit {
+			resp.Next = resp.Ads[len(resp.Ads)-1].Id
+			break
+		}
+		info, err := s.e.GetAdInfo(ctx, next)
+		if err != nil {
+			return nil, adError(next, err)
+		}
+		resp.Ads = append(resp.Ads, newAdInfo(info, s.e.MetadataContext()))
+		next = info.Advertisement.PreviousCid()
+	}
+	return resp, nil
+}
+
+func (s *adminService) GetAd(ctx context.Context, req *adminpb.GetAdRequest) (*adminpb.AdInfo, error) {
+	adCid, err := cid.Decode(req.GetCid())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid advertisement CID")
+	}
+	info, err := s.e.GetAdInfo(ctx, adCid)
+	if err != nil {
+		return nil, adError(adCid, err)
+	}
+	return newAdInfo(info, s.e.MetadataContext()), nil
+}
+
+func adError(adCid cid.Cid, err error) error {
+	if errors.Is(err, engine.ErrAdNotFound) {
+		return status.Errorf(codes.NotFound, "advertisement %s not found", adCid)
+	}
+	err = fmt.Errorf("failed to get advertisement %s: %w", adCid, err)
+	log.Error(err)
+	return status.Error(codes.Internal, err.Error())
+}
+
+func newAdInfo(info *engine.AdInfo, mc metadata.MetadataContext) *adminpb.AdInfo {
+	ad := info.Advertisement
+	ai := &adminpb.AdInfo{
+		Id:                info.ID.String(),
+		PreviousId:        cidString(ad.PreviousCid()),
+		Provider:          ad.Provider,
+		Addresses:         ad.Addresses,
+		ContextId:         ad.ContextID,
+		Metadata:          ad.Metadata,
+		IsRm:              ad.IsRm,
+		ExtendedProviders: ad.ExtendedProvider != nil,
+		ChunkCount:        int64(info.ChunkCount),
+		EntriesPresent:    info.EntriesPresent,
+	}
+	if ad.Entries != nil && ad.Entries != schema.NoEntries {
+		ai.Entries = ad.Entries.(cidlink.Link).Cid.String()
+	}
+	md := mc.New()
+	if err := md.UnmarshalBinary(ad.Metadata); err == nil {
+		for _, p := range md.Protocols() {
+			ai.Protocols = append(ai.Protocols, p.String())
+		}
+	}
+	return ai
+}
+
+func (s *adminService) ImportCar(ctx context.Context, req *adminpb.ImportCarRequest) (*adminpb.ImportCarResponse, error) {
+	key := req.GetKey()
+	defaultMetadata := s.defaultMetadata
+	if defaultMetadata == nil {
+		defaultMetadata = carMetadata
+	}
+	md, err := decodeMetadata(s.e.MetadataContext(), req.GetMetadata(), key, defaultMetadata)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to unmarshal metadata: %v", err)
+	}
+	log.Infow("Importing CAR", "path", req.GetPath())
+	adCid, err := s.cs.Put(ctx, key, req.GetPath(), md)
+	if err != nil {
+		if errors.Is(err, provider.ErrAlreadyAdvertised) {
+			log.Infow("CAR already advertised", "path", req.GetPath())
+			return nil, status.Error(codes.AlreadyExists, "CAR already advertised")
+		}
+		msg := fmt.Sprintf("failed to import CAR: %v", err)
+		log.Errorw(msg, "err", err, "path", req.GetPath())
+		return nil, status.Error(codes.Internal, msg)
+	}
+	log.Infow("Imported CAR successfully", "path", req.GetPath(), "contextID", key)
+	return &adminpb.ImportCarResponse{Key: key, AdCid: adCid.String()}, nil
+}
+
+func (s *adminService) RemoveCar(ctx context.Context, req *adminpb.RemoveCarRequest) (*adminpb.RemoveCarResponse, error) {
+	if len(req.GetKey()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "key must be specified")
+	}
+	b64Key := base64.StdEncoding.EncodeToString(req.GetKey())
+	log.Infow("Removing CAR by key", "key", b64Key)
+	adCid, err := s.cs.Remove(ctx, req.GetKey())
+	if err != nil {
+		if errors.Is(err, supplier.ErrNotFound) {
+			return nil, status.Errorf(codes.NotFound, "provider has no car file for key %s", b64Key)
+		}
+		log.Errorw("Failed to remove CAR", "err", err, "key", b64Key)
+		return nil, status.Errorf(codes.Internal, "error removing car: %s", err)
+	}
+	log.Infow("Removed CAR successfully", "contextID", b64Key)
+	return &adminpb.RemoveCarResponse{AdCid: adCid.String()}, nil
+}
+
+func (s *adminService) ListCars(ctx context.Context, _ *adminpb.ListCarsRequest) (*adminpb.ListCarsResponse, error) {
+	paths, err := s.cs.List(ctx)
+	if err != nil {
+		err = fmt.Errorf("failed to list CARs %w", err)
+		log.Error(err)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &adminpb.ListCarsResponse{Paths: paths}, nil
+}
+
+func (s *adminService) Advertise(ctx context.Context, req *adminpb.AdvertiseRequest) (*adminpb.AdvertiseResponse, error) {
+	contextID := req.GetContextId()
+	if len(contextID) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "context ID must be specified")
+	}
+	if len(req.GetMultihashes()) != 0 && s.ms == nil {
+		return nil, status.Error(codes.Unimplemented, "advertising multihashes is not supported by this provider")
+	}
+	var addrInfo *peer.AddrInfo
+	if req.GetProviderId() != "" {
+		p, err := peer.Decode(req.GetProviderId())
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid provider ID: %v", err)
+		}
+		addrInfo = &peer.AddrInfo{ID: p}
+		for _, addr := range req.GetProviderAddrs() {
+			maddr, err := multiaddr.NewMultiaddr(addr)
+			if err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid provider address: %v", err)
+			}
+			addrInfo.Addrs = append(addrInfo.Addrs, maddr)
+		}
+	} else if len(req.GetProviderAddrs()) != 0 {
+		return nil, status.Error(codes.InvalidArgument, "provider addresses require provider ID")
+	}
+	mhs := make([]multihash.Multihash, 0, len(req.GetMultihashes()))
+	for _, b := range req.GetMultihashes() {
+		mh, err := multihash.Cast(b)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid multihash: %v", err)
+		}
+		mhs = append(mhs, mh)
+	}
+	md, err := decodeMetadata(s.e.MetadataContext(), req.GetMetadata(), contextID, s.defaultMetadata)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to unmarshal metadata: %v", err)
+	}
+
+	b64ContextID := base64.StdEncoding.EncodeToString(contextID)
+	log.Infow("Advertising context", "contextID", b64ContextID, "multihashes", len(mhs))
+	var adCid cid.Cid
+	if len(mhs) != 0 {
+		adCid, err = s.ms.Put(ctx, addrInfo, contextID, mhs, md)
+	} else {
+		adCid, err = s.e.NotifyPut(ctx, addrInfo, contextID, md)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, provider.ErrAlreadyAdvertised):
+			return nil, status.Errorf(codes.AlreadyExists, "context ID %s is already advertised", b64ContextID)
+		case errors.Is(err, supplier.ErrNotFound), errors.Is(err, provider.ErrContextIDNotFound):
+			return nil, status.Errorf(codes.NotFound, "provider has no multihashes for context ID %s", b64ContextID)
+		case errors.Is(err, provider.ErrContextIDTooLong), errors.Is(err, provider.ErrMetadataTooLong), errors.Is(err, provider.ErrTooManyAddrs):
+			return nil, status.Errorf(codes.InvalidArgument, "cannot advertise context ID %s: %v", b64ContextID, err)
+		}
+		err = fmt.Errorf("error advertising context ID %s: %w", b64ContextID, err)
+		log.Error(err)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	log.Infow("Advertised context successfully", "contextID", b64ContextID, "advertisement", adCid)
+	return &adminpb.AdvertiseResponse{AdCid: adCid.String()}, nil
+}
+
+func (s *adminService) Remove(ctx context.Context, req *adminpb.RemoveRequest) (*adminpb.RemoveResponse, error) {
+	contextID := req.GetContextId()
+	if len(contextID) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "context ID must be specified")
+	}
+	var p peer.ID
+	if req.GetProviderId() != "" {
+		var err error
+		if p, err = peer.Decode(req.GetProviderId()); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid provider ID: %v", err)
+		}
+	}
+	adCid, err := s.remove(ctx, p, contextID)
+	if err != nil {
+		return nil, removeError(contextID, err)
+	}
+	return &adminpb.RemoveResponse{AdCid: adCid.String()}, nil
+}
+
+func (s *adminService) RemoveContexts(ctx context.Context, req *adminpb.RemoveContextsRequest) (*adminpb.RemoveContextsResponse, error) {
+	if req.GetAll() == (len(req.GetContextIds()) != 0) {
+		return nil, status.Error(codes.InvalidArgument, "either context IDs or all must be specified")
+	}
+	contextIDs := req.GetContextIds()
+	if req.GetAll() {
+		var err error
+		contextIDs, err = s.e.ListContextIDs(ctx, "")
+		if err != nil {
+			err = fmt.Errorf("failed to list context IDs: %w", err)
+			log.Error(err)
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+	for _, contextID := range contextIDs {
+		if len(contextID) == 0 {
+			return nil, status.Error(codes.InvalidArgument, "context ID must not be empty")
+		}
+	}
+
+	resp := &adminpb.RemoveContextsResponse{}
+	for _, contextID := range contextIDs {
+		adCid, err := s.remove(ctx, "", contextID)
+		if err != nil {
+			st := status.Convert(removeError(contextID, err))
+			if len(resp.Removed) != 0 {
+				return nil, status.Errorf(st.Code(), "%s; %d context IDs were removed before the error", st.Message(), len(resp.Removed))
+			}
+			return nil, st.Err()
+		}
+		resp.Removed = append(resp.Removed, &adminpb.RemovedContext{ContextId: contextID, AdCid: adCid.String()})
+	}
+	log.Infow("Removed contexts successfully", "count", len(resp.Removed))
+	return resp, nil
+}
+
+// remove publishes a removal advertisement for the given context ID of the
+// given provider. Multihashes advertised via the multihash supplier, and CARs
+// imported with the context ID as key, are removed via their suppliers, so
+// that the suppliers stop serving them too.
+func (s *adminService) remove(ctx context.Context, p peer.ID, contextID []byte) (cid.Cid, error) {
+	log.Infow("Removing context", "contextID", base64.StdEncoding.EncodeToString(contextID))
+	if s.ms != nil {
+		adCid, err := s.ms.Remove(ctx, p, contextID)
+		if !errors.Is(err, supplier.ErrNotFound) {
+			return adCid, err
+		}
+	}
+	// CARs are always imported for the default provider.
+	if s.cs != nil && p == "" {
+		adCid, err := s.cs.Remove(ctx, contextID)
+		if !errors.Is(err, supplier.ErrNotFound) {
+			return adCid, err
+		}
+	}
+	return s.e.NotifyRemove(ctx, p, contextID)
+}
+
+// decodeMetadata decodes the metadata given in a call to advertise the given
+// context ID with the metadata protocols of mc. If none is given and
+// defaultMetadata is not nil, the metadata it returns is used instead.
+func decodeMetadata(mc metadata.MetadataContext, data, contextID []byte, defaultMetadata func([]byte) (metadata.Metadata, error)) (metadata.Metadata, error) {
+	if len(data) == 0 && defaultMetadata != nil {
+		return defaultMetadata(contextID)
+	}
+	md := mc.New()
+	if err := md.UnmarshalBinary(data); err != nil {
+		return metadata.Metadata{}, err
+	}
+	return md, nil
+}
+
+// carMetadata returns the metadata of the CAR imported with the given context
+// ID when no metadata is specified and no default is set, i.e. retrieval over
+// graphsync from the CAR data transfer.
+func carMetadata(contextID []byte) (metadata.Metadata, error) {
+	tp, err := cardatatransfer.TransportFromContextID(contextID)
+	if err != nil {
+		return metadata.Metadata{}, err
+	}
+	return metadata.Default.New(tp), nil
+}
+
+func removeError(contextID []byte, err error) error {
+	b64ContextID := base64.StdEncoding.EncodeToString(contextID)
+	if errors.Is(err, provider.ErrContextIDNotFound) {
+		return status.Errorf(codes.NotFound, "provider has no content for context ID %s", b64ContextID)
+	}
+	err = fmt.Errorf("error removing context ID %s: %w", b64ContextID, err)
+	log.Error(err)
+	return status.Error(codes.Internal, err.Error())
+}
+
+func (s *adminService) ListContexts(ctx context.Context, req *adminpb.ListContextsRequest) (*adminpb.ListContextsResponse, error) {
+	limit := int(req.GetLimit())
+	if limit < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit must not be negative")
+	}
+	if limit == 0 {
+		limit = defaultListContextsLimit
+	} else if limit > maxListContextsLimit {
+		limit = maxListContextsLimit
+	}
+
+	contextIDs, err := s.e.ListContextIDs(ctx, "")
+	if err != nil {
+		err = fmt.Errorf("failed to list context IDs: %w", err)
+		log.Error(err)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if after := req.GetAfter(); len(after) != 0 {
+		// Context IDs are sorted; skip to the first one after the cursor.
+		start := sort.Search(len(contextIDs), func(i int) bool {
+			return bytes.Compare(contextIDs[i], after) > 0
+		})
+		contextIDs = contextIDs[start:]
+	}
+
+	resp := &adminpb.ListContextsResponse{}
+	if len(contextIDs) > limit {
+		contextIDs = contextIDs[:limit]
+		resp.Next = contextIDs[limit-1]
+	}
+	for _, contextID := range contextIDs {
+		info, err := s.e.GetContextInfo(ctx, "", contextID)
+		if err != nil {
+			if errors.Is(err, provider.ErrContextIDNotFound) {
+				// Removed since listed.
+				continue
+			}
+			err = fmt.Errorf("failed to get context info: %w", err)
+			log.Error(err)
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		ci := &adminpb.ContextInfo{
+			ContextId:      info.ContextID,
+			Entries:        cidString(info.Entries),
+			MultihashCount: int64(info.MultihashCount),
+			PublishedAt:    timestamp(info.PublishedAt),
+		}
+		for _, p := range info.Metadata.Protocols() {
+			ci.Protocols = append(ci.Protocols, p.String())
+		}
+		resp.Contexts = append(resp.Contexts, ci)
+	}
+	return resp, nil
+}
+
+func (s *adminService) SubscribePublishEvents(_ *adminpb.SubscribePublishEventsRequest, stream adminpb.AdminService_SubscribePublishEventsServer) error {
+	events, cancel := s.e.SubscribePublishEvents()
+	defer cancel()
+	// Send headers so that clients know once they are subscribed.
+	if err := stream.SendHeader(grpcmd.MD{}); err != nil {
+		return err
+	}
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(newPublishEvent(event)); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		case <-s.done:
+			return status.Error(codes.Unavailable, "server is shutting down")
+		}
+	}
+}
+
+func newPublishEvent(event engine.PublishEvent) *adminpb.PublishEvent {
+	pe := &adminpb.PublishEvent{
+		AdCid: cidString(event.AdCid),
+		IsRm:  event.IsRm,
+		Time:  timestamp(event.Time),
+	}
+	switch event.Kind {
+	case engine.AdPublished:
+		pe.Kind = adminpb.PublishEvent_KIND_PUBLISHED
+	case engine.AdAnnounced:
+		pe.Kind = adminpb.PublishEvent_KIND_ANNOUNCED
+	}
+	if event.Err != nil {
+		pe.Error = event.Err.Error()
+	}
+	return pe
+}
+
+// cidString returns the string representation of the given CID, or the empty
+// string if the CID is undefined.
+func cidString(c cid.Cid) string {
+	if c == cid.Undef {
+		return ""
+	}
+	return c.String()
+}
+
+// timestamp returns the given time as a timestamp, or nil if the time is zero.
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
@@ -0,0 +1,210 @@
+package adminserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipni/go-libipni/ingest/schema"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/index-provider/engine"
+)
+
+const (
+	adsPath             = "/admin/ads"
+	defaultListAdsLimit = 100
+	maxListAdsLimit     = 1000
+)
+
+func (s *Server) listAdsHandler(w http.ResponseWriter, r *http.Request) {
+	if !methodOK(w, r, http.MethodGet) {
+		return
+	}
+
+	query := r.URL.Query()
+	limit := defaultListAdsLimit
+	if v := query.Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		if limit > maxListAdsLimit {
+			limit = maxListAdsLimit
+		}
+	}
+
+	var labels map[string]string
+	for _, v := range query["label"] {
+		key, value, ok := strings.Cut(v, "=")
+		if !ok || key == "" {
+			http.Error(w, "label must be of the form key=value", http.StatusBadRequest)
+			return
+		}
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[key] = value
+	}
+
+	ctx := r.Context()
+	var next cid.Cid
+	if v := query.Get("after"); v != "" {
+		after, err := cid.Decode(v)
+		if err != nil {
+			http.Error(w, "after is not a valid CID", http.StatusBadRequest)
+			return
+		}
+		info, err := s.e.GetAdInfo(ctx, after)
+		if err != nil {
+			s.adError(w, after, err)
+			return
+		}
+		next = info.Advertisement.PreviousCid()
+	} else {
+		var err error
+		next, _, err = s.e.GetLatestAdv(ctx)
+		if err != nil {
+			err = fmt.Errorf("failed to get latest advertisement: %w", err)
+			log.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	resp := &ListAdsRes{Ads: []AdInfo{}}
+	for next != cid.Undef {
+		if len(resp.Ads) == limit {
+			last := resp.Ads[len(resp.Ads)-1].ID
+			resp.Next = &last
+			break
+		}
+		info, err := s.e.GetAdInfo(ctx, next)
+		if err != nil {
+			s.adError(w, next, err)
+			return
+		}
+		if engine.MatchLabels(info.Labels, labels) {
+			resp.Ads = append(resp.Ads, newAdInfo(info, s.e.MetadataContext()))
+		}
+		next = info.Advertisement.PreviousCid()
+	}
+	respond(w, http.StatusOK, resp)
+}
+
+func (s *Server) getAdHandler(w http.ResponseWriter, r *http.Request) {
+	cidStr, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, adsPath+"/"), "/")
+	adCid, err := cid.Decode(cidStr)
+	if err != nil {
+		http.Error(w, "invalid advertisement CID", http.StatusBadRequest)
+		return
+	}
+	switch action {
+	case "":
+	case "labels":
+		s.setAdLabelsHandler(w, r, adCid)
+		return
+	default:
+		http.Error(w, "", http.StatusNotFound)
+		return
+	}
+	if !methodOK(w, r, http.MethodGet) {
+		return
+	}
+
+	info, err := s.e.GetAdInfo(r.Context(), adCid)
+	if err != nil {
+		s.adError(w, adCid, err)
+		return
+	}
+	resp := newAdInfo(info, s.e.MetadataContext())
+	respond(w, http.StatusOK, &resp)
+}
+
+func (s *Server) setAdLabelsHandler(w http.ResponseWriter, r *http.Request, adCid cid.Cid) {
+	if !methodOK(w, r, http.MethodPut) {
+		return
+	}
+	if !matchContentTypeJson(w, r) {
+		return
+	}
+	var req SetAdLabelsReq
+	if _, err := req.ReadFrom(r.Body); err != nil {
+		msg := fmt.Sprintf("failed to unmarshal request. %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	if err := s.e.SetAdLabels(ctx, adCid, req.Labels); err != nil {
+		if errors.Is(err, engine.ErrInvalidLabels) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.adError(w, adCid, err)
+		return
+	}
+	log.Infow("Set labels of advertisement", "advertisement", adCid, "labels", req.Labels)
+	info, err := s.e.GetAdInfo(ctx, adCid)
+	if err != nil {
+		s.adError(w, adCid, err)
+		return
+	}
+	resp := newAdInfo(info, s.e.MetadataContext())
+	respond(w, http.StatusOK, &resp)
+}
+
+func (s *Server) adError(w http.ResponseWriter, adCid cid.Cid, err error) {
+	if errors.Is(err, engine.ErrAdNotFound) {
+		http.Error(w, fmt.Sprintf("advertisement %s not found", adCid), http.StatusNotFound)
+		return
+	}
+	err = fmt.Errorf("failed to get advertisement %s: %w", adCid, err)
+	log.Error(err)
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+func newAdInfo(info *engine.AdInfo, mc metadata.MetadataContext) AdInfo {
+	ad := info.Advertisement
+	ai := AdInfo{
+		ID:                info.ID,
+		Provider:          ad.Provider,
+		Addresses:         ad.Addresses,
+		ContextID:         ad.ContextID,
+		Metadata:          ad.Metadata,
+		Protocols:         []string{},
+		IsRm:              ad.IsRm,
+		ExtendedProviders: ad.ExtendedProvider != nil,
+		ChunkCount:        info.ChunkCount,
+		EntriesPresent:    info.EntriesPresent,
+		Labels:            info.Labels,
+	}
+	if ai.Addresses == nil {
+		ai.Addresses = []string{}
+	}
+	if prev := ad.PreviousCid(); prev != cid.Undef {
+		ai.PreviousID = &prev
+	}
+	if ad.Entries != nil && ad.Entries != schema.NoEntries {
+		entries := ad.Entries.(cidlink.Link).Cid
+		ai.Entries = &entries
+	}
+	md := mc.New()
+	if err := md.UnmarshalBinary(ad.Metadata); err == nil {
+		for _, p := range md.Protocols() {
+			if p != engine.AdSequenceProtocolID {
+				ai.Protocols = append(ai.Protocols, p.String())
+			}
+		}
+	}
+	if seq, err := engine.GetAdSequence(ad.Metadata); err == nil && seq != nil {
+		ai.Sequence = &seq.Seq
+		ai.Timestamp = &seq.Time
+	}
+	return ai
+}
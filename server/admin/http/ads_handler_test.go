@@ -0,0 +1,165 @@
+package adminserver
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_adsHandlers(t *testing.T) {
+	ctx := context.Background()
+	eng, err := engine.New(engine.WithPublisherKind(engine.NoPublisher), engine.WithAdSequence(true))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+	eng.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+	subject := &Server{e: eng}
+	get := func(handler http.HandlerFunc, target string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, target, nil)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	list := func(target string) *ListAdsRes {
+		rr := get(subject.listAdsHandler, target)
+		require.Equal(t, http.StatusOK, rr.Code)
+		var resp ListAdsRes
+		_, err := resp.ReadFrom(rr.Body)
+		require.NoError(t, err)
+		return &resp
+	}
+
+	require.Empty(t, list("/admin/ads").Ads)
+
+	var adCids []cid.Cid
+	for _, contextID := range []string{"fish", "lobster", "crab"} {
+		adCid, err := eng.NotifyPut(ctx, nil, []byte(contextID), metadata.Default.New(metadata.Bitswap{}))
+		require.NoError(t, err)
+		adCids = append(adCids, adCid)
+	}
+
+	resp := list("/admin/ads?limit=2")
+	require.Len(t, resp.Ads, 2)
+	require.Equal(t, adCids[2], resp.Ads[0].ID)
+	require.Equal(t, adCids[1], resp.Ads[1].ID)
+	require.Equal(t, []byte("crab"), resp.Ads[0].ContextID)
+	require.Equal(t, []string{"transport-bitswap"}, resp.Ads[0].Protocols)
+	require.Equal(t, adCids[1], *resp.Ads[0].PreviousID)
+	require.Equal(t, uint64(3), *resp.Ads[0].Sequence)
+	require.True(t, resp.Ads[0].Timestamp.After(*resp.Ads[1].Timestamp))
+	require.NotNil(t, resp.Next)
+
+	resp = list("/admin/ads?limit=2&after=" + resp.Next.String())
+	require.Len(t, resp.Ads, 1)
+	require.Equal(t, adCids[0], resp.Ads[0].ID)
+	require.Nil(t, resp.Ads[0].PreviousID)
+	require.Nil(t, resp.Next)
+
+	rr := get(subject.listAdsHandler, "/admin/ads?limit=0")
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = get(subject.listAdsHandler, "/admin/ads?after=fish")
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = get(subject.getAdHandler, "/admin/ads/"+adCids[0].String())
+	require.Equal(t, http.StatusOK, rr.Code)
+	var info AdInfo
+	_, err = info.ReadFrom(rr.Body)
+	require.NoError(t, err)
+	require.Equal(t, adCids[0], info.ID)
+	require.Equal(t, []byte("fish"), info.ContextID)
+	require.NotNil(t, info.Entries)
+	require.Equal(t, 1, info.ChunkCount)
+	require.True(t, info.EntriesPresent)
+	require.False(t, info.IsRm)
+
+	rr = get(subject.getAdHandler, "/admin/ads/"+test.RandomCids(1)[0].String())
+	require.Equal(t, http.StatusNotFound, rr.Code)
+	rr = get(subject.getAdHandler, "/admin/ads/fish")
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func Test_adLabels(t *testing.T) {
+	ctx := context.Background()
+	eng, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+	eng.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+	subject := &Server{e: eng}
+	do := func(handler http.HandlerFunc, method, target string, body []byte) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, target, bytes.NewReader(body))
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	setLabels := func(adCid cid.Cid, labels map[string]string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		_, err := (&SetAdLabelsReq{Labels: labels}).WriteTo(&body)
+		require.NoError(t, err)
+		return do(subject.getAdHandler, http.MethodPut, "/admin/ads/"+adCid.String()+"/labels", body.Bytes())
+	}
+	list := func(target string) []cid.Cid {
+		rr := do(subject.listAdsHandler, http.MethodGet, target, nil)
+		require.Equal(t, http.StatusOK, rr.Code)
+		var resp ListAdsRes
+		_, err := resp.ReadFrom(rr.Body)
+		require.NoError(t, err)
+		var ids []cid.Cid
+		for _, ad := range resp.Ads {
+			ids = append(ids, ad.ID)
+		}
+		return ids
+	}
+
+	var adCids []cid.Cid
+	for _, contextID := range []string{"fish", "lobster", "crab"} {
+		adCid, err := eng.NotifyPut(ctx, nil, []byte(contextID), metadata.Default.New(metadata.Bitswap{}))
+		require.NoError(t, err)
+		adCids = append(adCids, adCid)
+	}
+
+	rr := setLabels(adCids[0], map[string]string{"dataset": "wikipedia", "tier": "hot"})
+	require.Equal(t, http.StatusOK, rr.Code)
+	var info AdInfo
+	_, err = info.ReadFrom(rr.Body)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"dataset": "wikipedia", "tier": "hot"}, info.Labels)
+	rr = setLabels(adCids[2], map[string]string{"dataset": "wikipedia"})
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	require.Equal(t, []cid.Cid{adCids[2], adCids[0]}, list("/admin/ads?label=dataset=wikipedia"))
+	require.Equal(t, []cid.Cid{adCids[0]}, list("/admin/ads?label=dataset=wikipedia&label=tier=hot"))
+	require.Equal(t, []cid.Cid{adCids[2]}, list("/admin/ads?label=dataset=wikipedia&limit=1"))
+	require.Empty(t, list("/admin/ads?label=dataset=gutenberg"))
+	require.Len(t, list("/admin/ads"), 3)
+
+	rr = do(subject.listAdsHandler, http.MethodGet, "/admin/ads?label=dataset", nil)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = setLabels(adCids[1], map[string]string{"a=b": "c"})
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = setLabels(test.RandomCids(1)[0], map[string]string{"dataset": "wikipedia"})
+	require.Equal(t, http.StatusNotFound, rr.Code)
+	rr = do(subject.getAdHandler, http.MethodGet, "/admin/ads/"+adCids[1].String()+"/labels", nil)
+	require.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+
+	// Removing the labels of an advertisement unlists it.
+	rr = setLabels(adCids[2], nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, []cid.Cid{adCids[0]}, list("/admin/ads?label=dataset=wikipedia"))
+}
@@ -1,8 +1,6 @@
 package adminserver
 
 import (
-	"encoding/json"
-	"io"
 	"net/http"
 	"net/url"
 )
@@ -33,31 +31,31 @@ func (s *Server) announceHttpHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		log.Errorw("failed reading import cidlist request", "err", err)
-		http.Error(w, "", http.StatusBadRequest)
-		return
-	}
-	var params map[string][]byte
-	err = json.Unmarshal(body, &params)
-	if err != nil {
+	var req AnnounceHttpReq
+	if _, err := req.ReadFrom(r.Body); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	indexer, ok := params["indexer"]
-	if !ok {
+	indexers := req.Indexers
+	if len(req.Indexer) != 0 {
+		indexers = append(indexers, string(req.Indexer))
+	}
+	if len(indexers) == 0 {
 		http.Error(w, "missing indexer url in request", http.StatusBadRequest)
 		return
 	}
 
-	indexerURL, err := url.Parse(string(indexer))
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+	indexerURLs := make([]*url.URL, 0, len(indexers))
+	for _, indexer := range indexers {
+		indexerURL, err := url.Parse(indexer)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		indexerURLs = append(indexerURLs, indexerURL)
 	}
 
-	adCid, err := s.e.PublishLatestHTTP(r.Context(), indexerURL)
+	adCid, err := s.e.PublishLatestHTTP(r.Context(), indexerURLs...)
 	if err != nil {
 		log.Errorw("Could not publish latest advertisement via http", "err", err)
 		if adCid.Defined() {
@@ -0,0 +1,86 @@
+package adminserver
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/ipni/index-provider/engine"
+)
+
+const (
+	auditPath             = "/admin/audit"
+	defaultListAuditLimit = 100
+	maxListAuditLimit     = 1000
+)
+
+func (s *Server) listAuditHandler(w http.ResponseWriter, r *http.Request) {
+	if !methodOK(w, r, http.MethodGet) {
+		return
+	}
+
+	query := r.URL.Query()
+	limit := defaultListAuditLimit
+	if v := query.Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		if limit > maxListAuditLimit {
+			limit = maxListAuditLimit
+		}
+	}
+	var after uint64
+	if v := query.Get("after"); v != "" {
+		var err error
+		after, err = strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			http.Error(w, "after must be a sequence number", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// List one more record than requested to tell whether there are more.
+	records, err := s.e.ListAuditRecords(r.Context(), after, limit+1)
+	if err != nil {
+		err = fmt.Errorf("failed to list audit records: %w", err)
+		log.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := &ListAuditRes{Records: []AuditRecord{}}
+	for _, record := range records {
+		if len(resp.Records) == limit {
+			last := resp.Records[len(resp.Records)-1].Seq
+			resp.Next = &last
+			break
+		}
+		resp.Records = append(resp.Records, newAuditRecord(record))
+	}
+	respond(w, http.StatusOK, resp)
+}
+
+func newAuditRecord(record engine.AuditRecord) AuditRecord {
+	ar := AuditRecord{
+		Seq:            record.Seq,
+		Time:           record.Time,
+		AdID:           record.AdCid,
+		Provider:       record.Provider,
+		ContextID:      record.ContextID,
+		IsRm:           record.IsRm,
+		IsUpdate:       record.IsUpdate,
+		MultihashCount: record.MultihashCount,
+		SkippedCount:   record.SkippedCount,
+		Protocols:      record.Protocols,
+		Announces:      make([]AuditAnnounce, 0, len(record.Announces)),
+	}
+	if ar.Protocols == nil {
+		ar.Protocols = []string{}
+	}
+	for _, a := range record.Announces {
+		ar.Announces = append(ar.Announces, AuditAnnounce{Targets: a.Targets, Error: a.Err})
+	}
+	return ar
+}
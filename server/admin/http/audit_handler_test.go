@@ -0,0 +1,73 @@
+package adminserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_listAuditHandler(t *testing.T) {
+	ctx := context.Background()
+	eng, err := engine.New(engine.WithPublisherKind(engine.NoPublisher), engine.WithAuditLog(true))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+	eng.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+	subject := &Server{e: eng}
+	get := func(target string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, target, nil)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		subject.listAuditHandler(rr, req)
+		return rr
+	}
+	list := func(target string) *ListAuditRes {
+		rr := get(target)
+		require.Equal(t, http.StatusOK, rr.Code)
+		var resp ListAuditRes
+		_, err := resp.ReadFrom(rr.Body)
+		require.NoError(t, err)
+		return &resp
+	}
+
+	require.Empty(t, list("/admin/audit").Records)
+
+	var adCids []cid.Cid
+	for _, contextID := range []string{"fish", "lobster", "crab"} {
+		adCid, err := eng.NotifyPut(ctx, nil, []byte(contextID), metadata.Default.New(metadata.Bitswap{}))
+		require.NoError(t, err)
+		adCids = append(adCids, adCid)
+	}
+
+	resp := list("/admin/audit?limit=2")
+	require.Len(t, resp.Records, 2)
+	require.Equal(t, uint64(1), resp.Records[0].Seq)
+	require.Equal(t, adCids[0], resp.Records[0].AdID)
+	require.Equal(t, []byte("fish"), resp.Records[0].ContextID)
+	require.Equal(t, 3, resp.Records[0].MultihashCount)
+	require.Equal(t, []string{"transport-bitswap"}, resp.Records[0].Protocols)
+	require.Equal(t, adCids[1], resp.Records[1].AdID)
+	require.NotNil(t, resp.Next)
+	require.Equal(t, uint64(2), *resp.Next)
+
+	resp = list("/admin/audit?after=2")
+	require.Len(t, resp.Records, 1)
+	require.Equal(t, adCids[2], resp.Records[0].AdID)
+	require.Nil(t, resp.Next)
+
+	rr := get("/admin/audit?limit=0")
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = get("/admin/audit?after=fish")
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+}
@@ -10,11 +10,15 @@ import (
 	"github.com/ipfs/go-cid"
 	"github.com/ipni/go-libipni/metadata"
 	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/cardatatransfer"
+	"github.com/ipni/index-provider/engine"
 	"github.com/ipni/index-provider/supplier"
 )
 
 type carHandler struct {
-	cs *supplier.CarSupplier
+	e               *engine.Engine
+	cs              *supplier.CarSupplier
+	defaultMetadata MetadataFunc
 }
 
 func (h *carHandler) handleImport(w http.ResponseWriter, r *http.Request) {
@@ -37,11 +41,14 @@ func (h *carHandler) handleImport(w http.ResponseWriter, r *http.Request) {
 
 	// Supply CAR.
 	var advID cid.Cid
-	var err error
 	ctx := context.Background()
 
-	md := metadata.Default.New()
-	if err := md.UnmarshalBinary(req.Metadata); err != nil {
+	defaultMetadata := h.defaultMetadata
+	if defaultMetadata == nil {
+		defaultMetadata = carMetadata
+	}
+	md, err := decodeMetadata(h.e, req.Metadata, req.Key, defaultMetadata)
+	if err != nil {
 		msg := fmt.Sprintf("failed to unmarshal metadata: %v", err)
 		log.Errorw(msg, "err", err)
 		http.Error(w, msg, http.StatusBadRequest)
@@ -138,3 +145,14 @@ func (h *carHandler) handleList(w http.ResponseWriter, r *http.Request) {
 	}
 	respond(w, http.StatusOK, resp)
 }
+
+// carMetadata returns the metadata of the CAR imported with the given context
+// ID when no metadata is specified and no default is set, i.e. retrieval over
+// graphsync from the CAR data transfer.
+func carMetadata(contextID []byte) (metadata.Metadata, error) {
+	tp, err := cardatatransfer.TransportFromContextID(contextID)
+	if err != nil {
+		return metadata.Metadata{}, err
+	}
+	return metadata.Default.New(tp), nil
+}
@@ -54,7 +54,7 @@ func Test_importCarHandler(t *testing.T) {
 
 	cs := supplier.NewCarSupplier(mockEng, ds)
 
-	subject := carHandler{cs}
+	subject := carHandler{cs: cs}
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(subject.handleImport)
@@ -106,7 +106,7 @@ func Test_importCarHandlerFail(t *testing.T) {
 	ds := dssync.MutexWrap(datastore.NewMapDatastore())
 	cs := supplier.NewCarSupplier(mockEng, ds)
 
-	subject := carHandler{cs}
+	subject := carHandler{cs: cs}
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(subject.handleImport)
@@ -125,6 +125,49 @@ func Test_importCarHandlerFail(t *testing.T) {
 	require.Equal(t, "failed to import CAR: fish\n", string(respBytes))
 }
 
+func Test_importCarHandlerDefaultMetadata(t *testing.T) {
+	wantKey := []byte("lobster")
+	jsonReq, err := json.Marshal(&ImportCarReq{Path: "fish", Key: wantKey})
+	require.NoError(t, err)
+
+	mc := gomock.NewController(t)
+	mockEng := mock_provider.NewMockInterface(mc)
+	mockEng.EXPECT().RegisterMultihashLister(gomock.Any())
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	cs := supplier.NewCarSupplier(mockEng, ds)
+	wantCid := test.RandomCids(1)[0]
+
+	// Without default metadata, CARs are advertised as retrievable from the
+	// CAR data transfer.
+	wantTp, err := cardatatransfer.TransportFromContextID(wantKey)
+	require.NoError(t, err)
+	mockEng.
+		EXPECT().
+		NotifyPut(gomock.Any(), gomock.Nil(), gomock.Eq(wantKey), gomock.Eq(metadata.Default.New(wantTp))).
+		Return(wantCid, nil)
+	subject := carHandler{cs: cs}
+	rr := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodPost, "/admin/import/car", bytes.NewReader(jsonReq))
+	require.NoError(t, err)
+	subject.handleImport(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	bitswap := metadata.Default.New(metadata.Bitswap{})
+	mockEng.
+		EXPECT().
+		NotifyPut(gomock.Any(), gomock.Nil(), gomock.Eq(wantKey), gomock.Eq(bitswap)).
+		Return(wantCid, nil)
+	subject.defaultMetadata = func(contextID []byte) (metadata.Metadata, error) {
+		require.Equal(t, wantKey, contextID)
+		return bitswap, nil
+	}
+	rr = httptest.NewRecorder()
+	req, err = http.NewRequest(http.MethodPost, "/admin/import/car", bytes.NewReader(jsonReq))
+	require.NoError(t, err)
+	subject.handleImport(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+}
+
 func Test_importCarAlreadyAdvertised(t *testing.T) {
 	wantKey := []byte("lobster")
 	wantTp, err := cardatatransfer.TransportFromContextID(wantKey)
@@ -150,7 +193,7 @@ func Test_importCarAlreadyAdvertised(t *testing.T) {
 	ds := dssync.MutexWrap(datastore.NewMapDatastore())
 	cs := supplier.NewCarSupplier(mockEng, ds)
 
-	subject := carHandler{cs}
+	subject := carHandler{cs: cs}
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(subject.handleImport)
@@ -179,7 +222,7 @@ func Test_removeCarHandler(t *testing.T) {
 	ds := dssync.MutexWrap(datastore.NewMapDatastore())
 	cs := supplier.NewCarSupplier(mockEng, ds)
 
-	subject := carHandler{cs}
+	subject := carHandler{cs: cs}
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(subject.handleRemove)
@@ -214,7 +257,7 @@ func Test_removeCarHandlerFail(t *testing.T) {
 	ds := dssync.MutexWrap(datastore.NewMapDatastore())
 	cs := supplier.NewCarSupplier(mockEng, ds)
 
-	subject := carHandler{cs}
+	subject := carHandler{cs: cs}
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(subject.handleRemove)
@@ -244,7 +287,7 @@ func Test_removeCarHandler_NonExistingCarIsNotFound(t *testing.T) {
 	ds := dssync.MutexWrap(datastore.NewMapDatastore())
 	cs := supplier.NewCarSupplier(mockEng, ds)
 
-	subject := carHandler{cs}
+	subject := carHandler{cs: cs}
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(subject.handleRemove)
@@ -262,7 +305,7 @@ func Test_removeCarHandler_UnspecifiedKeyIsBadRequest(t *testing.T) {
 	ds := dssync.MutexWrap(datastore.NewMapDatastore())
 	cs := supplier.NewCarSupplier(mockEng, ds)
 
-	subject := carHandler{cs}
+	subject := carHandler{cs: cs}
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(subject.handleRemove)
@@ -280,7 +323,7 @@ func Test_removeCarHandler_InvalidJsonIsBadRequest(t *testing.T) {
 	ds := dssync.MutexWrap(datastore.NewMapDatastore())
 	cs := supplier.NewCarSupplier(mockEng, ds)
 
-	subject := carHandler{cs}
+	subject := carHandler{cs: cs}
 
 	rr := httptest.NewRecorder()
 	handler := http.HandlerFunc(subject.handleRemove)
@@ -332,7 +375,7 @@ func Test_ListCarHandler(t *testing.T) {
 	ds := dssync.MutexWrap(datastore.NewMapDatastore())
 	cs := supplier.NewCarSupplier(mockEng, ds)
 
-	subject := carHandler{cs}
+	subject := carHandler{cs: cs}
 
 	req, err := http.NewRequest(http.MethodGet, "/admin/list/car", nil)
 	require.NoError(t, err)
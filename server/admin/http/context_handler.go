@@ -0,0 +1,370 @@
+package adminserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/ipfs/go-cid"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/ipni/index-provider/supplier"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	defaultListContextsLimit = 1000
+	maxListContextsLimit     = 10000
+)
+
+type contextHandler struct {
+	e  *engine.Engine
+	cs *supplier.CarSupplier
+	ms *supplier.MultihashSupplier
+
+	defaultMetadata MetadataFunc
+}
+
+func (h *contextHandler) handleAdvertise(w http.ResponseWriter, r *http.Request) {
+	if !methodOK(w, r, http.MethodPost) {
+		return
+	}
+	if !matchContentTypeJson(w, r) {
+		return
+	}
+	log.Info("Received advertise request")
+
+	// Decode request.
+	var req AdvertiseReq
+	if _, err := req.ReadFrom(r.Body); err != nil {
+		msg := fmt.Sprintf("failed to unmarshal request. %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if len(req.ContextID) == 0 {
+		http.Error(w, "context ID must be specified", http.StatusBadRequest)
+		return
+	}
+	if len(req.Multihashes) != 0 && h.ms == nil {
+		http.Error(w, "advertising multihashes is not supported by this provider", http.StatusNotImplemented)
+		return
+	}
+	md, err := decodeMetadata(h.e, req.Metadata, req.ContextID, h.defaultMetadata)
+	if err != nil {
+		msg := fmt.Sprintf("failed to unmarshal metadata: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if err = engine.ValidateLabels(req.Labels); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+
+	b64ContextID := base64.StdEncoding.EncodeToString(req.ContextID)
+	log.Infow("Advertising context", "contextID", b64ContextID, "multihashes", len(req.Multihashes), "dryRun", dryRun)
+	ctx := context.Background()
+	var advID cid.Cid
+	var dryRunRes *engine.DryRunResult
+	switch {
+	case dryRun:
+		var mhIter provider.MultihashIterator
+		if len(req.Multihashes) != 0 {
+			mhIter = provider.SliceMultihashIterator(req.Multihashes)
+		}
+		dryRunRes, err = h.e.DryRunPut(ctx, req.Provider, req.ContextID, md, mhIter)
+	case len(req.Multihashes) != 0:
+		advID, err = h.ms.Put(ctx, req.Provider, req.ContextID, req.Multihashes, md)
+	default:
+		advID, err = h.e.NotifyPut(ctx, req.Provider, req.ContextID, md)
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, provider.ErrAlreadyAdvertised):
+			status = http.StatusConflict
+			err = fmt.Errorf("context ID %s is already advertised", b64ContextID)
+		case errors.Is(err, supplier.ErrNotFound), errors.Is(err, provider.ErrContextIDNotFound):
+			status = http.StatusNotFound
+			err = fmt.Errorf("provider has no multihashes for context ID %s", b64ContextID)
+		case errors.Is(err, provider.ErrContextIDTooLong), errors.Is(err, provider.ErrMetadataTooLong), errors.Is(err, provider.ErrTooManyAddrs):
+			status = http.StatusBadRequest
+			err = fmt.Errorf("cannot advertise context ID %s: %w", b64ContextID, err)
+		default:
+			err = fmt.Errorf("error advertising context ID %s: %w", b64ContextID, err)
+		}
+		log.Error(err)
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	if dryRun {
+		resp := &DryRunRes{
+			AdvId:        dryRunRes.AdCid,
+			Entries:      dryRunRes.Entries,
+			SkippedCount: dryRunRes.Skipped,
+			Parts:        dryRunRes.Parts,
+			Size:         dryRunRes.Size,
+		}
+		if dryRunRes.MultihashCount >= 0 {
+			count := dryRunRes.MultihashCount
+			resp.MultihashCount = &count
+		}
+		respond(w, http.StatusOK, resp)
+		return
+	}
+	log.Infow("Advertised context successfully", "contextID", b64ContextID, "advertisement", advID)
+	if len(req.Labels) != 0 {
+		if err = h.e.SetAdLabels(ctx, advID, req.Labels); err != nil {
+			err = fmt.Errorf("advertised context ID %s as %s but failed to set its labels: %w", b64ContextID, advID, err)
+			log.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	respond(w, http.StatusOK, &AdvertiseRes{AdvId: advID})
+}
+
+func (h *contextHandler) handleRemoveOne(w http.ResponseWriter, r *http.Request) {
+	if !methodOK(w, r, http.MethodPost) {
+		return
+	}
+	if !matchContentTypeJson(w, r) {
+		return
+	}
+	log.Info("Received remove request")
+
+	// Decode request.
+	var req RemoveReq
+	if _, err := req.ReadFrom(r.Body); err != nil {
+		msg := fmt.Sprintf("failed to unmarshal request. %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if len(req.ContextID) == 0 {
+		http.Error(w, "context ID must be specified", http.StatusBadRequest)
+		return
+	}
+
+	b64ContextID := base64.StdEncoding.EncodeToString(req.ContextID)
+	log.Infow("Removing context", "contextID", b64ContextID)
+	advID, err := h.removeFor(context.Background(), req.Provider, req.ContextID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, provider.ErrContextIDNotFound) {
+			status = http.StatusNotFound
+			err = fmt.Errorf("provider has no content for context ID %s", b64ContextID)
+		} else {
+			err = fmt.Errorf("error removing context ID %s: %w", b64ContextID, err)
+		}
+		log.Error(err)
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	log.Infow("Removed context successfully", "contextID", b64ContextID, "advertisement", advID)
+	respond(w, http.StatusOK, &RemoveRes{AdvId: advID})
+}
+
+func (h *contextHandler) handleRemove(w http.ResponseWriter, r *http.Request) {
+	if !methodOK(w, r, http.MethodPost) {
+		return
+	}
+	if !matchContentTypeJson(w, r) {
+		return
+	}
+	log.Info("Received remove context request")
+
+	// Decode request.
+	var req RemoveContextReq
+	if _, err := req.ReadFrom(r.Body); err != nil {
+		msg := fmt.Sprintf("failed to unmarshal request. %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if req.All == (len(req.ContextIDs) != 0) {
+		http.Error(w, "either context IDs or all must be specified", http.StatusBadRequest)
+		return
+	}
+
+	// The removal advertisements are announced at once, after they are all
+	// published.
+	ctx := engine.ContextWithHeldAnnounce(context.Background())
+	contextIDs := req.ContextIDs
+	if req.All {
+		var err error
+		contextIDs, err = h.e.ListContextIDs(ctx, "")
+		if err != nil {
+			err = fmt.Errorf("failed to list context IDs: %w", err)
+			log.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	for _, contextID := range contextIDs {
+		if len(contextID) == 0 {
+			http.Error(w, "context ID must not be empty", http.StatusBadRequest)
+			return
+		}
+	}
+
+	resp := &RemoveContextRes{Removed: []RemovedContext{}}
+	defer func() {
+		if len(resp.Removed) == 0 {
+			return
+		}
+		if _, err := h.e.PublishLatest(context.Background()); err != nil {
+			log.Errorw("Failed to announce removal advertisements", "err", err)
+		}
+	}()
+	for _, contextID := range contextIDs {
+		b64ContextID := base64.StdEncoding.EncodeToString(contextID)
+		log.Infow("Removing context", "contextID", b64ContextID)
+		advID, err := h.remove(ctx, contextID)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, provider.ErrContextIDNotFound) {
+				status = http.StatusNotFound
+				err = fmt.Errorf("provider has no content for context ID %s", b64ContextID)
+			} else {
+				err = fmt.Errorf("error removing context ID %s: %w", b64ContextID, err)
+			}
+			if len(resp.Removed) != 0 {
+				err = fmt.Errorf("%w; %d context IDs were removed before the error", err, len(resp.Removed))
+			}
+			log.Error(err)
+			http.Error(w, err.Error(), status)
+			return
+		}
+		resp.Removed = append(resp.Removed, RemovedContext{ContextID: contextID, AdvId: advID})
+		reportProgress(r.Context(), int64(len(resp.Removed)), int64(len(contextIDs)))
+	}
+
+	log.Infow("Removed contexts successfully", "count", len(resp.Removed))
+
+	// Respond with successful remove result.
+	respond(w, http.StatusOK, resp)
+}
+
+// remove publishes a removal advertisement for the given context ID of the
+// default provider.
+func (h *contextHandler) remove(ctx context.Context, contextID []byte) (cid.Cid, error) {
+	return h.removeFor(ctx, "", contextID)
+}
+
+// removeFor publishes a removal advertisement for the given context ID of the
+// given provider. Multihashes advertised via the multihash supplier, and CARs
+// imported with the context ID as key, are removed via their suppliers, so
+// that the suppliers stop serving them too.
+func (h *contextHandler) removeFor(ctx context.Context, p peer.ID, contextID []byte) (cid.Cid, error) {
+	if h.ms != nil {
+		advID, err := h.ms.Remove(ctx, p, contextID)
+		if !errors.Is(err, supplier.ErrNotFound) {
+			return advID, err
+		}
+	}
+	// CARs are always imported for the default provider.
+	if h.cs != nil && p == "" {
+		advID, err := h.cs.Remove(ctx, contextID)
+		if !errors.Is(err, supplier.ErrNotFound) {
+			return advID, err
+		}
+	}
+	return h.e.NotifyRemove(ctx, p, contextID)
+}
+
+func (h *contextHandler) handleList(w http.ResponseWriter, r *http.Request) {
+	if !methodOK(w, r, http.MethodGet) {
+		return
+	}
+
+	query := r.URL.Query()
+	limit := defaultListContextsLimit
+	if v := query.Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		if limit > maxListContextsLimit {
+			limit = maxListContextsLimit
+		}
+	}
+	var after []byte
+	if v := query.Get("after"); v != "" {
+		var err error
+		after, err = base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			http.Error(w, "after is not a valid base64 encoded string", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx := r.Context()
+	contextIDs, err := h.e.ListContextIDs(ctx, "")
+	if err != nil {
+		err = fmt.Errorf("failed to list context IDs: %w", err)
+		log.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if after != nil {
+		// Context IDs are sorted; skip to the first one after the cursor.
+		start := sort.Search(len(contextIDs), func(i int) bool {
+			return bytes.Compare(contextIDs[i], after) > 0
+		})
+		contextIDs = contextIDs[start:]
+	}
+
+	resp := &ListContextsRes{Contexts: []ContextInfo{}}
+	if len(contextIDs) > limit {
+		contextIDs = contextIDs[:limit]
+		resp.Next = contextIDs[limit-1]
+	}
+	for _, contextID := range contextIDs {
+		info, err := h.e.GetContextInfo(ctx, "", contextID)
+		if err != nil {
+			if errors.Is(err, provider.ErrContextIDNotFound) {
+				// Removed since listed.
+				continue
+			}
+			err = fmt.Errorf("failed to get context info: %w", err)
+			log.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp.Contexts = append(resp.Contexts, newContextInfo(info))
+	}
+	respond(w, http.StatusOK, resp)
+}
+
+func newContextInfo(info *engine.ContextInfo) ContextInfo {
+	ci := ContextInfo{
+		ContextID: info.ContextID,
+		Entries:   info.Entries,
+		Protocols: []string{},
+	}
+	if info.MultihashCount >= 0 {
+		count := info.MultihashCount
+		ci.MultihashCount = &count
+	}
+	for _, p := range info.Metadata.Protocols() {
+		ci.Protocols = append(ci.Protocols, p.String())
+	}
+	if !info.PublishedAt.IsZero() {
+		publishedAt := info.PublishedAt.UTC()
+		ci.PublishedAt = &publishedAt
+	}
+	return ci
+}
@@ -0,0 +1,275 @@
+package adminserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/ipni/index-provider/supplier"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_removeContextHandler(t *testing.T) {
+	ctx := context.Background()
+	eng, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+	eng.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+	for _, contextID := range []string{"fish", "lobster", "crab"} {
+		_, err = eng.NotifyPut(ctx, nil, []byte(contextID), metadata.Default.New(metadata.Bitswap{}))
+		require.NoError(t, err)
+	}
+
+	subject := contextHandler{e: eng}
+	doRemove := func(req *RemoveContextReq) *httptest.ResponseRecorder {
+		jsonReq, err := json.Marshal(req)
+		require.NoError(t, err)
+		httpReq, err := http.NewRequest(http.MethodPost, "/admin/remove/context", bytes.NewReader(jsonReq))
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(subject.handleRemove).ServeHTTP(rr, httpReq)
+		return rr
+	}
+
+	rr := doRemove(&RemoveContextReq{})
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = doRemove(&RemoveContextReq{ContextIDs: [][]byte{[]byte("fish")}, All: true})
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = doRemove(&RemoveContextReq{ContextIDs: [][]byte{[]byte("fish")}})
+	require.Equal(t, http.StatusOK, rr.Code)
+	var resp RemoveContextRes
+	_, err = resp.ReadFrom(rr.Body)
+	require.NoError(t, err)
+	require.Len(t, resp.Removed, 1)
+	require.Equal(t, []byte("fish"), resp.Removed[0].ContextID)
+	_, latestAd, err := eng.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	require.True(t, latestAd.IsRm)
+	require.Equal(t, []byte("fish"), latestAd.ContextID)
+
+	rr = doRemove(&RemoveContextReq{ContextIDs: [][]byte{[]byte("fish")}})
+	require.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = doRemove(&RemoveContextReq{All: true})
+	require.Equal(t, http.StatusOK, rr.Code)
+	resp = RemoveContextRes{}
+	_, err = resp.ReadFrom(rr.Body)
+	require.NoError(t, err)
+	require.Len(t, resp.Removed, 2)
+	require.Equal(t, []byte("crab"), resp.Removed[0].ContextID)
+	require.Equal(t, []byte("lobster"), resp.Removed[1].ContextID)
+
+	rr = doRemove(&RemoveContextReq{All: true})
+	require.Equal(t, http.StatusOK, rr.Code)
+	resp = RemoveContextRes{}
+	_, err = resp.ReadFrom(rr.Body)
+	require.NoError(t, err)
+	require.Empty(t, resp.Removed)
+}
+
+func Test_listContextsHandler(t *testing.T) {
+	ctx := context.Background()
+	eng, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+	eng.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+	for _, contextID := range []string{"fish", "lobster", "crab"} {
+		_, err = eng.NotifyPut(ctx, nil, []byte(contextID), metadata.Default.New(metadata.Bitswap{}))
+		require.NoError(t, err)
+	}
+
+	subject := contextHandler{e: eng}
+	doList := func(query string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, "/admin/list/contexts"+query, nil)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(subject.handleList).ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := doList("?limit=0")
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = doList("?limit=2")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var resp ListContextsRes
+	_, err = resp.ReadFrom(rr.Body)
+	require.NoError(t, err)
+	require.Len(t, resp.Contexts, 2)
+	require.Equal(t, []byte("crab"), resp.Contexts[0].ContextID)
+	require.Equal(t, []byte("fish"), resp.Contexts[1].ContextID)
+	require.Equal(t, []byte("fish"), resp.Next)
+	require.Equal(t, 3, *resp.Contexts[0].MultihashCount)
+	require.Equal(t, []string{"transport-bitswap"}, resp.Contexts[0].Protocols)
+	require.NotNil(t, resp.Contexts[0].PublishedAt)
+
+	rr = doList("?limit=2&after=" + url.QueryEscape(base64.StdEncoding.EncodeToString(resp.Next)))
+	require.Equal(t, http.StatusOK, rr.Code)
+	resp = ListContextsRes{}
+	_, err = resp.ReadFrom(rr.Body)
+	require.NoError(t, err)
+	require.Len(t, resp.Contexts, 1)
+	require.Equal(t, []byte("lobster"), resp.Contexts[0].ContextID)
+	require.Nil(t, resp.Next)
+}
+
+func Test_advertiseAndRemoveHandlers(t *testing.T) {
+	ctx := context.Background()
+	eng, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+	ms := supplier.NewMultihashSupplier(eng, dssync.MutexWrap(datastore.NewMapDatastore()))
+	eng.RegisterMultihashLister(ms.ListMultihashes)
+
+	subject := contextHandler{e: eng, ms: ms}
+	do := func(handler http.HandlerFunc, req any) *httptest.ResponseRecorder {
+		jsonReq, err := json.Marshal(req)
+		require.NoError(t, err)
+		httpReq, err := http.NewRequest(http.MethodPost, "/admin/advertise", bytes.NewReader(jsonReq))
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httpReq)
+		return rr
+	}
+	bitswap := metadata.Default.New(metadata.Bitswap{})
+	md, err := bitswap.MarshalBinary()
+	require.NoError(t, err)
+	mhs := test.RandomMultihashes(3)
+
+	rr := do(subject.handleAdvertise, &AdvertiseReq{Metadata: md, Multihashes: mhs})
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// No multihashes are known for the context ID.
+	rr = do(subject.handleAdvertise, &AdvertiseReq{ContextID: []byte("fish"), Metadata: md})
+	require.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = do(subject.handleAdvertise, &AdvertiseReq{ContextID: []byte("fish"), Metadata: md, Multihashes: mhs, Labels: map[string]string{"": "x"}})
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	labels := map[string]string{"dataset": "wikipedia"}
+	rr = do(subject.handleAdvertise, &AdvertiseReq{ContextID: []byte("fish"), Metadata: md, Multihashes: mhs, Labels: labels})
+	require.Equal(t, http.StatusOK, rr.Code)
+	var adRes AdvertiseRes
+	_, err = adRes.ReadFrom(rr.Body)
+	require.NoError(t, err)
+	ad, err := eng.GetAdv(ctx, adRes.AdvId)
+	require.NoError(t, err)
+	require.Equal(t, []byte("fish"), ad.ContextID)
+	require.False(t, ad.IsRm)
+	gotLabels, err := eng.GetAdLabels(ctx, adRes.AdvId)
+	require.NoError(t, err)
+	require.Equal(t, labels, gotLabels)
+
+	rr = do(subject.handleAdvertise, &AdvertiseReq{ContextID: []byte("fish"), Metadata: md})
+	require.Equal(t, http.StatusConflict, rr.Code)
+
+	// Context IDs that indexers would reject are not advertised.
+	rr = do(subject.handleAdvertise, &AdvertiseReq{ContextID: bytes.Repeat([]byte("f"), 65), Metadata: md, Multihashes: mhs})
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Contains(t, rr.Body.String(), "context ID too long")
+
+	rr = do(subject.handleRemoveOne, &RemoveReq{})
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = do(subject.handleRemoveOne, &RemoveReq{ContextID: []byte("fish")})
+	require.Equal(t, http.StatusOK, rr.Code)
+	var rmRes RemoveRes
+	_, err = rmRes.ReadFrom(rr.Body)
+	require.NoError(t, err)
+	ad, err = eng.GetAdv(ctx, rmRes.AdvId)
+	require.NoError(t, err)
+	require.True(t, ad.IsRm)
+
+	rr = do(subject.handleRemoveOne, &RemoveReq{ContextID: []byte("fish")})
+	require.Equal(t, http.StatusNotFound, rr.Code)
+
+	// Metadata must be given unless there is a default.
+	rr = do(subject.handleAdvertise, &AdvertiseReq{ContextID: []byte("lobster"), Multihashes: mhs})
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	subject.defaultMetadata = func([]byte) (metadata.Metadata, error) { return bitswap, nil }
+	rr = do(subject.handleAdvertise, &AdvertiseReq{ContextID: []byte("lobster"), Multihashes: mhs})
+	require.Equal(t, http.StatusOK, rr.Code)
+	adRes = AdvertiseRes{}
+	_, err = adRes.ReadFrom(rr.Body)
+	require.NoError(t, err)
+	ad, err = eng.GetAdv(ctx, adRes.AdvId)
+	require.NoError(t, err)
+	require.Equal(t, md, ad.Metadata)
+
+	// Without a multihash supplier, multihashes cannot be given.
+	subject.ms = nil
+	subject.defaultMetadata = nil
+	rr = do(subject.handleAdvertise, &AdvertiseReq{ContextID: []byte("lobster"), Metadata: md, Multihashes: mhs})
+	require.Equal(t, http.StatusNotImplemented, rr.Code)
+}
+
+func Test_advertiseHandlerDryRun(t *testing.T) {
+	ctx := context.Background()
+	eng, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+	ms := supplier.NewMultihashSupplier(eng, dssync.MutexWrap(datastore.NewMapDatastore()))
+	eng.RegisterMultihashLister(ms.ListMultihashes)
+
+	subject := contextHandler{e: eng, ms: ms}
+	bitswap := metadata.Default.New(metadata.Bitswap{})
+	md, err := bitswap.MarshalBinary()
+	require.NoError(t, err)
+	jsonReq, err := json.Marshal(&AdvertiseReq{ContextID: []byte("fish"), Metadata: md, Multihashes: test.RandomMultihashes(3)})
+	require.NoError(t, err)
+	do := func(target string) *httptest.ResponseRecorder {
+		httpReq, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(jsonReq))
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(subject.handleAdvertise).ServeHTTP(rr, httpReq)
+		return rr
+	}
+
+	rr := do("/admin/advertise?dry_run=true")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var dryRunRes DryRunRes
+	_, err = dryRunRes.ReadFrom(rr.Body)
+	require.NoError(t, err)
+	require.Equal(t, 3, *dryRunRes.MultihashCount)
+	require.Equal(t, 1, dryRunRes.Parts)
+	require.NotZero(t, dryRunRes.Size)
+
+	// Nothing is published, nor supplied, by the dry run.
+	latest, _, err := eng.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	require.False(t, latest.Defined())
+	rr = do("/admin/advertise?dry_run=true")
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	rr = do("/admin/advertise")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var adRes AdvertiseRes
+	_, err = adRes.ReadFrom(rr.Body)
+	require.NoError(t, err)
+	require.Equal(t, dryRunRes.AdvId, adRes.AdvId)
+
+	rr = do("/admin/advertise?dry_run=true")
+	require.Equal(t, http.StatusConflict, rr.Code)
+}
@@ -0,0 +1,62 @@
+package adminserver
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	_ "github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/ipld/go-ipld-prime/codec/dagjson"
+	"github.com/ipld/go-ipld-prime/multicodec"
+	basicnode "github.com/ipld/go-ipld-prime/node/basic"
+	"github.com/ipni/index-provider/engine"
+)
+
+// debugBlockPath is the path under which locally stored blocks are served.
+// See: WithDebugBlocks.
+const debugBlockPath = "/debug/block/"
+
+// debugBlockHandler responds with the advertisement or entries chunk block of
+// the CID in the path as dag-json, regardless of the codec it is stored with.
+func (s *Server) debugBlockHandler(w http.ResponseWriter, r *http.Request) {
+	if !methodOK(w, r, http.MethodGet) {
+		return
+	}
+	c, err := cid.Decode(strings.TrimPrefix(r.URL.Path, debugBlockPath))
+	if err != nil {
+		http.Error(w, "invalid block CID", http.StatusBadRequest)
+		return
+	}
+
+	data, err := s.e.GetLocalBlock(r.Context(), c)
+	if err != nil {
+		if errors.Is(err, engine.ErrBlockNotFound) {
+			http.Error(w, "", http.StatusNotFound)
+			return
+		}
+		log.Errorw("Failed to get block", "cid", c, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	decoder, err := multicodec.LookupDecoder(c.Prefix().Codec)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	nb := basicnode.Prototype.Any.NewBuilder()
+	if err = decoder(nb, bytes.NewReader(data)); err != nil {
+		log.Errorw("Failed to decode block", "cid", c, "err", err)
+		http.Error(w, "cannot decode block: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var buf bytes.Buffer
+	if err = dagjson.Encode(nb.Build(), &buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = buf.WriteTo(w)
+}
@@ -0,0 +1,66 @@
+package adminserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_debugBlockHandler(t *testing.T) {
+	ctx := context.Background()
+	eng, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+	mhs := test.RandomMultihashes(3)
+	eng.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(mhs), nil
+	})
+	subject := &Server{e: eng}
+	get := func(target string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, target, nil)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		subject.debugBlockHandler(rr, req)
+		return rr
+	}
+
+	adCid, err := eng.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	ad, err := eng.GetAdv(ctx, adCid)
+	require.NoError(t, err)
+
+	rr := get(debugBlockPath + adCid.String())
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	require.Contains(t, rr.Body.String(), `"Signature"`)
+	require.Contains(t, rr.Body.String(), ad.Entries.String())
+
+	rr = get(debugBlockPath + ad.Entries.(cidlink.Link).Cid.String())
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), `"Entries"`)
+
+	require.Equal(t, http.StatusNotFound, get(debugBlockPath+test.RandomCids(1)[0].String()).Code)
+	require.Equal(t, http.StatusBadRequest, get(debugBlockPath+"fish").Code)
+}
+
+func TestServer_DebugBlocks(t *testing.T) {
+	get := func(subject *Server) int {
+		resp, err := http.Get("http://" + subject.l.Addr().String() + debugBlockPath + "fish")
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	require.Equal(t, http.StatusNotFound, get(startServer(t)))
+	require.Equal(t, http.StatusBadRequest, get(startServer(t, WithDebugBlocks(true))))
+}
@@ -0,0 +1,38 @@
+package adminserver
+
+import (
+	"io"
+	"net/http"
+)
+
+// healthzHandler reports whether the engine is functioning, e.g. for use as a
+// liveness probe. It responds with 503 if announcements are stuck.
+func (s *Server) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	if !methodOK(w, r, http.MethodGet) {
+		return
+	}
+	probeResult(w, s.e.Healthy())
+}
+
+// readyzHandler reports whether the engine is ready to publish advertisements,
+// e.g. for use as a readiness probe. It responds with 503 if the engine is not
+// started, its publisher is not listening or its datastore is not writable.
+func (s *Server) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	if !methodOK(w, r, http.MethodGet) {
+		return
+	}
+	probeResult(w, s.e.Ready(r.Context()))
+}
+
+func probeResult(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err != nil {
+		log.Warnw("Probe failed", "err", err)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = io.WriteString(w, err.Error()+"\n")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.WriteString(w, "ok\n")
+}
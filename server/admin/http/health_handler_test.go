@@ -0,0 +1,65 @@
+package adminserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ipni/index-provider/engine"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_healthHandlers(t *testing.T) {
+	ctx := context.Background()
+	eng, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	subject := &Server{e: eng}
+	probe := func(handler http.HandlerFunc, method string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, "/", nil)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := probe(subject.readyzHandler, http.MethodGet)
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	require.Contains(t, rr.Body.String(), "not started")
+
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+	rr = probe(subject.readyzHandler, http.MethodGet)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "ok\n", rr.Body.String())
+
+	rr = probe(subject.healthzHandler, http.MethodGet)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	rr = probe(subject.healthzHandler, http.MethodPost)
+	require.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+}
+
+func TestServer_HealthProbesWithoutBearerToken(t *testing.T) {
+	ctx := context.Background()
+	eng, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+
+	subject, err := New(nil, eng, nil, WithListenAddr("127.0.0.1:0"), WithBearerToken("fish"))
+	require.NoError(t, err)
+	go func() { _ = subject.Start() }()
+	t.Cleanup(func() { _ = subject.server.Close() })
+
+	for path, want := range map[string]int{
+		"/healthz":     http.StatusOK,
+		"/readyz":      http.StatusOK,
+		"/admin/stats": http.StatusUnauthorized,
+	} {
+		resp, err := http.Get("http://" + subject.l.Addr().String() + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, want, resp.StatusCode, path)
+	}
+}
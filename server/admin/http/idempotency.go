@@ -0,0 +1,268 @@
+package adminserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	dssync "github.com/ipfs/go-datastore/sync"
+)
+
+const (
+	// idempotencyKeyHeader is the header via which clients identify a request
+	// that may be retried, such that it is served at most once.
+	idempotencyKeyHeader = "Idempotency-Key"
+	// idempotentReplayedHeader is set on responses that are replayed from
+	// the response to an earlier request with the same idempotency key.
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	// idempotencyKeyPrefix is the prefix of the datastore keys under which
+	// the responses to requests with an idempotency key are persisted.
+	idempotencyKeyPrefix = "/admin/idempotency/"
+	// maxIdempotencyKeyLength is the maximum length of idempotency keys.
+	maxIdempotencyKeyLength = 255
+
+	defaultIdempotencyKeyTTL = 24 * time.Hour
+)
+
+// idempotentResponse is the response to a request with an idempotency key,
+// as persisted in the datastore.
+type idempotentResponse struct {
+	// Fingerprint identifies the request, such that reusing its key for a
+	// different request is detected.
+	Fingerprint []byte
+	Status      int
+	ContentType string `json:",omitempty"`
+	Body        []byte `json:",omitempty"`
+	Expires     time.Time
+}
+
+// idempotency persists the responses to requests with an idempotency key, so
+// that retries of a request are responded to with the response to the
+// original request instead of being served again.
+type idempotency struct {
+	ds  datastore.Datastore
+	ttl time.Duration
+
+	mutex     sync.Mutex
+	inFlight  map[string]struct{}
+	lastPrune time.Time
+}
+
+// newIdempotency instantiates an idempotency that persists responses in the
+// given datastore, or in memory if nil, for the given duration.
+func newIdempotency(ds datastore.Datastore, ttl time.Duration) *idempotency {
+	if ds == nil {
+		ds = dssync.MutexWrap(datastore.NewMapDatastore())
+	}
+	return &idempotency{
+		ds:       ds,
+		ttl:      ttl,
+		inFlight: make(map[string]struct{}),
+	}
+}
+
+// idempotentHandler wraps the given handler such that POST requests with an
+// Idempotency-Key header are served at most once per key. Retries of such a
+// request are responded to with the response to the original request, with
+// the Idempotent-Replayed header set, unless the original request failed with
+// a server error, in which case it is served again. Requests that reuse a key
+// for a different request are rejected with 422 Unprocessable Entity, and
+// those whose key is in use by a request being served with 409 Conflict.
+func (s *Server) idempotentHandler(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" || r.Method != http.MethodPost {
+			handler(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			http.Error(w, "idempotency key is too long", http.StatusBadRequest)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "failed to read request body", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := requestFingerprint(r, body)
+
+		if !s.idempotency.acquire(key) {
+			http.Error(w, "a request with the same idempotency key is in progress", http.StatusConflict)
+			return
+		}
+		defer s.idempotency.release(key)
+
+		res, err := s.idempotency.get(r.Context(), key)
+		if err != nil {
+			log.Errorw("Failed to get response of idempotent request", "key", key, "err", err)
+			http.Error(w, "failed to get response of idempotent request", http.StatusInternalServerError)
+			return
+		}
+		if res != nil {
+			if !bytes.Equal(res.Fingerprint, fingerprint) {
+				http.Error(w, "idempotency key was used for a different request", http.StatusUnprocessableEntity)
+				return
+			}
+			log.Infow("Replaying response of idempotent request", "key", key, "path", r.URL.Path)
+			if res.ContentType != "" {
+				w.Header().Set("Content-Type", res.ContentType)
+			}
+			w.Header().Set(idempotentReplayedHeader, "true")
+			w.WriteHeader(res.Status)
+			_, _ = w.Write(res.Body)
+			return
+		}
+
+		rw := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+		handler(rw, r)
+		if rw.status >= http.StatusInternalServerError {
+			return
+		}
+		s.idempotency.put(key, &idempotentResponse{
+			Fingerprint: fingerprint,
+			Status:      rw.status,
+			ContentType: rw.Header().Get("Content-Type"),
+			Body:        rw.body.Bytes(),
+			Expires:     time.Now().Add(s.idempotency.ttl).UTC(),
+		})
+	}
+}
+
+// requestFingerprint returns the hash of the method, target and body of the
+// given request.
+func requestFingerprint(r *http.Request, body []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+// acquire marks the given key as in use by a request being served, and
+// returns false if it already is.
+func (i *idempotency) acquire(key string) bool {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	if _, ok := i.inFlight[key]; ok {
+		return false
+	}
+	i.inFlight[key] = struct{}{}
+	return true
+}
+
+func (i *idempotency) release(key string) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	delete(i.inFlight, key)
+}
+
+// get returns the unexpired response persisted for the given key, or nil if
+// there is none.
+func (i *idempotency) get(ctx context.Context, key string) (*idempotentResponse, error) {
+	value, err := i.ds.Get(ctx, idempotencyDSKey(key))
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var res idempotentResponse
+	if err = json.Unmarshal(value, &res); err != nil {
+		log.Errorw("Ignoring invalid persisted response of idempotent request", "key", key, "err", err)
+		return nil, nil
+	}
+	if time.Now().After(res.Expires) {
+		return nil, nil
+	}
+	return &res, nil
+}
+
+// put persists the response to the request with the given key, and prunes the
+// expired responses at most once per TTL.
+func (i *idempotency) put(key string, res *idempotentResponse) {
+	// Persist even if the request is canceled, since it was served.
+	ctx := context.Background()
+	value, err := json.Marshal(res)
+	if err == nil {
+		err = i.ds.Put(ctx, idempotencyDSKey(key), value)
+	}
+	if err != nil {
+		log.Errorw("Failed to persist response of idempotent request", "key", key, "err", err)
+	}
+
+	i.mutex.Lock()
+	prune := time.Since(i.lastPrune) >= i.ttl
+	if prune {
+		i.lastPrune = time.Now()
+	}
+	i.mutex.Unlock()
+	if prune {
+		if err := i.prune(ctx); err != nil {
+			log.Errorw("Failed to prune expired responses of idempotent requests", "err", err)
+		}
+	}
+}
+
+// prune deletes the expired responses from the datastore.
+func (i *idempotency) prune(ctx context.Context) error {
+	results, err := i.ds.Query(ctx, query.Query{Prefix: idempotencyKeyPrefix})
+	if err != nil {
+		return err
+	}
+	defer results.Close()
+
+	now := time.Now()
+	var expired []string
+	for r := range results.Next() {
+		if r.Error != nil {
+			return r.Error
+		}
+		var res idempotentResponse
+		if err := json.Unmarshal(r.Value, &res); err != nil || now.After(res.Expires) {
+			expired = append(expired, r.Key)
+		}
+	}
+	for _, key := range expired {
+		if err := i.ds.Delete(ctx, datastore.NewKey(key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// idempotencyDSKey returns the datastore key of the response to the request
+// with the given idempotency key, which is encoded such that it is a single
+// datastore key segment.
+func idempotencyDSKey(key string) datastore.Key {
+	return datastore.NewKey(idempotencyKeyPrefix + base64.RawURLEncoding.EncodeToString([]byte(key)))
+}
+
+// recordingWriter writes a response while recording its status and body.
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
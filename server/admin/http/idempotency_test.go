@@ -0,0 +1,77 @@
+package adminserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_idempotentHandler(t *testing.T) {
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	var served int
+	status := http.StatusInternalServerError
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		served++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"served":` + strings.Repeat("1", served) + `}`))
+	}
+	do := func(s *Server, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/admin/advertise", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(idempotencyKeyHeader, key)
+		}
+		rr := httptest.NewRecorder()
+		s.idempotentHandler(handler)(rr, req)
+		return rr
+	}
+	subject := &Server{idempotency: newIdempotency(ds, time.Hour)}
+
+	// Server errors are not kept, so that the request can be retried.
+	rr := do(subject, "fish", "{}")
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+	status = http.StatusOK
+	rr = do(subject, "fish", "{}")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, `{"served":11}`, rr.Body.String())
+	require.Empty(t, rr.Header().Get(idempotentReplayedHeader))
+	require.Equal(t, 2, served)
+
+	// Retries are responded to with the kept response, across restarts.
+	subject = &Server{idempotency: newIdempotency(ds, time.Hour)}
+	rr = do(subject, "fish", "{}")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, `{"served":11}`, rr.Body.String())
+	require.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	require.Equal(t, "true", rr.Header().Get(idempotentReplayedHeader))
+	require.Equal(t, 2, served)
+
+	rr = do(subject, "fish", `{"context_id":"bG9ic3Rlcg=="}`)
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	require.True(t, subject.idempotency.acquire("lobster"))
+	rr = do(subject, "lobster", "{}")
+	require.Equal(t, http.StatusConflict, rr.Code)
+	subject.idempotency.release("lobster")
+	rr = do(subject, strings.Repeat("a", maxIdempotencyKeyLength+1), "{}")
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Equal(t, 2, served)
+
+	// Requests without key are always served.
+	do(subject, "", "{}")
+	do(subject, "", "{}")
+	require.Equal(t, 4, served)
+
+	// Expired responses are forgotten.
+	subject = &Server{idempotency: newIdempotency(ds, time.Nanosecond)}
+	do(subject, "eel", "{}")
+	rr = do(subject, "eel", "{}")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Empty(t, rr.Header().Get(idempotentReplayedHeader))
+	require.Equal(t, 6, served)
+}
@@ -11,6 +11,16 @@ var (
 	_ io.ReaderFrom = (*ImportCarRes)(nil)
 	_ io.ReaderFrom = (*RemoveCarReq)(nil)
 	_ io.ReaderFrom = (*RemoveCarRes)(nil)
+	_ io.ReaderFrom = (*AdvertiseReq)(nil)
+	_ io.ReaderFrom = (*AdvertiseRes)(nil)
+	_ io.ReaderFrom = (*DryRunRes)(nil)
+	_ io.ReaderFrom = (*RemoveReq)(nil)
+	_ io.ReaderFrom = (*RemoveRes)(nil)
+	_ io.ReaderFrom = (*ListAdsRes)(nil)
+	_ io.ReaderFrom = (*AdInfo)(nil)
+	_ io.ReaderFrom = (*SetAdLabelsReq)(nil)
+	_ io.ReaderFrom = (*RemoveContextReq)(nil)
+	_ io.ReaderFrom = (*RemoveContextRes)(nil)
 	_ io.ReaderFrom = (*ConnectReq)(nil)
 	_ io.ReaderFrom = (*ConnectRes)(nil)
 
@@ -18,8 +28,26 @@ var (
 	_ io.WriterTo = (*ImportCarRes)(nil)
 	_ io.WriterTo = (*RemoveCarReq)(nil)
 	_ io.WriterTo = (*RemoveCarRes)(nil)
+	_ io.WriterTo = (*AdvertiseReq)(nil)
+	_ io.WriterTo = (*AdvertiseRes)(nil)
+	_ io.WriterTo = (*DryRunRes)(nil)
+	_ io.WriterTo = (*RemoveReq)(nil)
+	_ io.WriterTo = (*RemoveRes)(nil)
+	_ io.WriterTo = (*ListAdsRes)(nil)
+	_ io.WriterTo = (*AdInfo)(nil)
+	_ io.WriterTo = (*SetAdLabelsReq)(nil)
+	_ io.WriterTo = (*JobRes)(nil)
+	_ io.WriterTo = (*ListLogLevelsRes)(nil)
+	_ io.WriterTo = (*SetLogLevelReq)(nil)
+	_ io.WriterTo = (*LogLevelRes)(nil)
+	_ io.WriterTo = (*ReloadRes)(nil)
+	_ io.WriterTo = (*RemoveContextReq)(nil)
+	_ io.WriterTo = (*RemoveContextRes)(nil)
 	_ io.WriterTo = (*ConnectReq)(nil)
 	_ io.WriterTo = (*ConnectRes)(nil)
+	_ io.WriterTo = (*ListPeersRes)(nil)
+	_ io.WriterTo = (*PeerReq)(nil)
+	_ io.WriterTo = (*PeerRes)(nil)
 )
 
 func (er *ImportCarReq) WriteTo(w io.Writer) (int64, error) {
@@ -54,6 +82,142 @@ func (er *RemoveCarRes) ReadFrom(r io.Reader) (int64, error) {
 	return unmarshalAsJson(r, er)
 }
 
+func (er *AdvertiseReq) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *AdvertiseReq) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *AdvertiseRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *AdvertiseRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *DryRunRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *DryRunRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *RemoveReq) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *RemoveReq) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *RemoveRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *RemoveRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *RemoveContextReq) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *RemoveContextReq) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *RemoveContextRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *RemoveContextRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *ListContextsRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *ListContextsRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *ListAdsRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *ListAdsRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *AdInfo) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *AdInfo) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *SetAdLabelsReq) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *SetAdLabelsReq) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *ListAuditRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *ListAuditRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *ListLogLevelsRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *ListLogLevelsRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *SetLogLevelReq) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *SetLogLevelReq) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *LogLevelRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *LogLevelRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *ReloadRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *ReloadRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *JobRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *JobRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
 func (er *ListCarRes) WriteTo(w io.Writer) (int64, error) {
 	return marshalToJson(w, er)
 }
@@ -78,6 +242,38 @@ func (er *ConnectRes) ReadFrom(r io.Reader) (int64, error) {
 	return unmarshalAsJson(r, er)
 }
 
+func (er *ListPeersRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *ListPeersRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *PeerReq) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *PeerReq) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *PeerRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *PeerRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *AnnounceHttpReq) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *AnnounceHttpReq) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
 func (er *AnnounceRes) WriteTo(w io.Writer) (int64, error) {
 	return marshalToJson(w, er)
 }
@@ -86,6 +282,86 @@ func (er *AnnounceRes) ReadFrom(r io.Reader) (int64, error) {
 	return unmarshalAsJson(r, er)
 }
 
+func (sr *StatsRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, sr)
+}
+
+func (sr *StatsRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, sr)
+}
+
+func (hr *HeadRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, hr)
+}
+
+func (hr *HeadRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, hr)
+}
+
+func (gr *GossipRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, gr)
+}
+
+func (gr *GossipRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, gr)
+}
+
+func (er *ListPublishQueueRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *ListPublishQueueRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *QueuedPublishInfo) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *QueuedPublishInfo) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *ReorderPublishReq) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *ReorderPublishReq) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *TenantInfo) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *TenantInfo) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *ListTenantsRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *ListTenantsRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *CreateTenantReq) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *CreateTenantReq) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
+func (er *DeleteTenantRes) WriteTo(w io.Writer) (int64, error) {
+	return marshalToJson(w, er)
+}
+
+func (er *DeleteTenantRes) ReadFrom(r io.Reader) (int64, error) {
+	return unmarshalAsJson(r, er)
+}
+
 func respond(w http.ResponseWriter, statusCode int, body io.WriterTo) {
 	w.WriteHeader(statusCode)
 	// Attempt to serialize body as JSON
@@ -0,0 +1,236 @@
+package adminserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+// maxFinishedJobs is the maximum number of finished jobs whose status is
+// retained.
+const maxFinishedJobs = 100
+
+// jobsKeyPrefix is the prefix of the datastore keys under which the status of
+// jobs is persisted.
+const jobsKeyPrefix = "/admin/jobs/"
+
+// errJobInterrupted is the error of jobs that were running when the server
+// stopped.
+var errJobInterrupted = errors.New("job interrupted by server shutdown")
+
+// jobs runs and tracks the status of asynchronous jobs. Jobs are canceled when
+// the server shuts down. If a datastore is given, the status of jobs is
+// persisted in it, such that it is retained across restarts; jobs that were
+// running when the server stopped are reported as failed.
+type jobs struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	ds     datastore.Datastore
+
+	mutex    sync.Mutex
+	byID     map[string]*JobRes
+	finished []string
+}
+
+// runningJob is the handle via which a running job updates its status.
+type runningJob struct {
+	j   *jobs
+	res *JobRes
+}
+
+// jobFunc is the function run by a job, which updates the status of the job
+// via the given handle.
+type jobFunc func(ctx context.Context, job *runningJob) error
+
+func newJobs(ds datastore.Datastore) *jobs {
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &jobs{
+		ctx:    ctx,
+		cancel: cancel,
+		ds:     ds,
+		byID:   make(map[string]*JobRes),
+	}
+	if ds != nil {
+		if err := j.load(); err != nil {
+			log.Errorw("Failed to load status of jobs", "err", err)
+		}
+	}
+	return j
+}
+
+// load loads the status of the jobs persisted in the datastore, marking the
+// jobs that were running as failed.
+func (j *jobs) load() error {
+	results, err := j.ds.Query(j.ctx, query.Query{Prefix: jobsKeyPrefix})
+	if err != nil {
+		return err
+	}
+	defer results.Close()
+
+	var loaded []*JobRes
+	for r := range results.Next() {
+		if r.Error != nil {
+			return r.Error
+		}
+		var job JobRes
+		if err := json.Unmarshal(r.Value, &job); err != nil {
+			log.Errorw("Ignoring invalid persisted job", "key", r.Key, "err", err)
+			continue
+		}
+		if job.Status == JobRunning {
+			finished := time.Now().UTC()
+			job.Status = JobFailed
+			job.Finished = &finished
+			job.Error = errJobInterrupted.Error()
+			j.persist(&job)
+		}
+		loaded = append(loaded, &job)
+	}
+	sort.Slice(loaded, func(a, b int) bool { return loaded[a].Finished.Before(*loaded[b].Finished) })
+	for _, job := range loaded {
+		j.byID[job.ID] = job
+		j.finish(job.ID)
+	}
+	return nil
+}
+
+// start runs the given function in the background as a job of the given kind,
+// and returns the initial status of the job.
+func (j *jobs) start(kind string, run jobFunc) JobRes {
+	job := &JobRes{
+		ID:      uuid.NewString(),
+		Kind:    kind,
+		Status:  JobRunning,
+		Started: time.Now().UTC(),
+	}
+	j.mutex.Lock()
+	j.byID[job.ID] = job
+	status := *job
+	j.persist(job)
+	j.mutex.Unlock()
+
+	log := log.With("job", job.ID, "kind", kind)
+	log.Info("Started job")
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		err := run(j.ctx, &runningJob{j, job})
+		finished := time.Now().UTC()
+
+		j.mutex.Lock()
+		defer j.mutex.Unlock()
+		job.Finished = &finished
+		if err != nil {
+			log.Errorw("Job failed", "err", err)
+			job.Status = JobFailed
+			job.Error = err.Error()
+		} else {
+			log.Infow("Job succeeded", "bytesReclaimed", job.BytesReclaimed)
+			job.Status = JobSucceeded
+		}
+		j.persist(job)
+		j.finish(job.ID)
+	}()
+	return status
+}
+
+// finish records the job with the given ID as finished, forgetting the oldest
+// finished jobs beyond maxFinishedJobs. The mutex must be held.
+func (j *jobs) finish(id string) {
+	j.finished = append(j.finished, id)
+	for len(j.finished) > maxFinishedJobs {
+		oldest := j.finished[0]
+		delete(j.byID, oldest)
+		j.finished = j.finished[1:]
+		if j.ds != nil {
+			if err := j.ds.Delete(j.ctx, jobKey(oldest)); err != nil {
+				log.Errorw("Failed to delete status of job", "job", oldest, "err", err)
+			}
+		}
+	}
+}
+
+// persist stores the status of the given job in the datastore, if any.
+func (j *jobs) persist(job *JobRes) {
+	if j.ds == nil {
+		return
+	}
+	value, err := json.Marshal(job)
+	if err == nil {
+		// Persist even if the jobs are closed, so that the status of canceled
+		// jobs is recorded.
+		err = j.ds.Put(context.Background(), jobKey(job.ID), value)
+	}
+	if err != nil {
+		log.Errorw("Failed to persist status of job", "job", job.ID, "err", err)
+	}
+}
+
+func jobKey(id string) datastore.Key {
+	return datastore.NewKey(jobsKeyPrefix + id)
+}
+
+// get returns the status of the job with the given ID, if it is known.
+func (j *jobs) get(id string) (JobRes, bool) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	job, ok := j.byID[id]
+	if !ok {
+		return JobRes{}, false
+	}
+	status := *job
+	if job.Progress != nil {
+		progress := *job.Progress
+		status.Progress = &progress
+	}
+	return status, true
+}
+
+// close cancels the running jobs and waits for them to return.
+func (j *jobs) close() {
+	j.cancel()
+	j.wg.Wait()
+}
+
+// update updates the status of the job via the given function.
+func (rj *runningJob) update(f func(*JobRes)) {
+	rj.j.mutex.Lock()
+	defer rj.j.mutex.Unlock()
+	f(rj.res)
+}
+
+// reportProgress reports that done out of total units of work of the job are
+// done. A total of zero signals that the total is unknown.
+func (rj *runningJob) reportProgress(done, total int64) {
+	rj.update(func(res *JobRes) {
+		res.Progress = &JobProgress{Done: done, Total: total}
+	})
+}
+
+// reclaimJob returns the function of a job that reclaims space via the given
+// function.
+func reclaimJob(reclaim func(context.Context) (int64, error)) jobFunc {
+	return func(ctx context.Context, job *runningJob) error {
+		reclaimed, err := reclaim(ctx)
+		job.update(func(res *JobRes) { res.BytesReclaimed = reclaimed })
+		return err
+	}
+}
+
+type runningJobKey struct{}
+
+// reportProgress reports the progress of the job whose request is served with
+// the given context, if the request is served as a job.
+func reportProgress(ctx context.Context, done, total int64) {
+	if job, ok := ctx.Value(runningJobKey{}).(*runningJob); ok {
+		job.reportProgress(done, total)
+	}
+}
@@ -0,0 +1,146 @@
+package adminserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ipni/index-provider/metrics"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	jobsPath = "/admin/jobs/"
+
+	jobKindCachePurge     = "cache-purge"
+	jobKindGC             = "gc"
+	jobKindImportCar      = "import-car"
+	jobKindRemoveContexts = "remove-contexts"
+)
+
+// cachePurgeHandler starts a job that deletes all cached advertisement
+// entries, and responds with the status of the job.
+func (s *Server) cachePurgeHandler(w http.ResponseWriter, r *http.Request) {
+	if !methodOK(w, r, http.MethodPost) {
+		return
+	}
+	job := s.jobs.start(jobKindCachePurge, reclaimJob(s.e.PurgeCache))
+	respond(w, http.StatusAccepted, &job)
+}
+
+// gcHandler starts a job that garbage collects the entries cache and the
+// datastore, and responds with the status of the job.
+func (s *Server) gcHandler(w http.ResponseWriter, r *http.Request) {
+	if !methodOK(w, r, http.MethodPost) {
+		return
+	}
+	job := s.jobs.start(jobKindGC, reclaimJob(s.e.CollectGarbage))
+	respond(w, http.StatusAccepted, &job)
+}
+
+func (s *Server) getJobHandler(w http.ResponseWriter, r *http.Request) {
+	if !methodOK(w, r, http.MethodGet) {
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, jobsPath)
+	job, ok := s.jobs.get(id)
+	if !ok {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+	respond(w, http.StatusOK, &job)
+}
+
+// asyncHandler wraps the given POST handler such that requests with the query
+// parameter "async=true" are served in the background as a job of the given
+// kind. Such requests are responded to immediately with 202 Accepted and the
+// status of the job, whose code and result are those of the response the
+// handler would have responded with.
+func (s *Server) asyncHandler(kind string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		async, err := strconv.ParseBool(r.URL.Query().Get("async"))
+		if err != nil || !async {
+			handler(w, r)
+			return
+		}
+		if !methodOK(w, r, http.MethodPost) {
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "failed to read request body", http.StatusBadRequest)
+			return
+		}
+		header := r.Header.Clone()
+		target := r.URL.String()
+		// The job outlives the request, but is traced as part of its trace.
+		spanCtx := trace.SpanContextFromContext(r.Context())
+
+		job := s.jobs.start(kind, func(ctx context.Context, job *runningJob) (err error) {
+			ctx, span := metrics.Tracer.Start(trace.ContextWithSpanContext(ctx, spanCtx), "adminserver.job",
+				trace.WithAttributes(attribute.String("job.kind", kind), attribute.String("job.id", job.res.ID)))
+			defer func() { metrics.EndSpan(span, err) }()
+
+			ctx = context.WithValue(ctx, runningJobKey{}, job)
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+			if err != nil {
+				return err
+			}
+			req.Header = header
+			rw := &jobResponseWriter{header: make(http.Header)}
+			handler(rw, req)
+			return rw.finish(job)
+		})
+		respond(w, http.StatusAccepted, &job)
+	}
+}
+
+// jobResponseWriter records the response to a request served as a job.
+type jobResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *jobResponseWriter) Header() http.Header {
+	return rw.header
+}
+
+func (rw *jobResponseWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	return rw.body.Write(b)
+}
+
+func (rw *jobResponseWriter) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+}
+
+// finish records the recorded response as the outcome of the given job, and
+// returns an error if the response is not successful.
+func (rw *jobResponseWriter) finish(job *runningJob) error {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	job.update(func(res *JobRes) { res.Code = rw.status })
+	if rw.status < 200 || rw.status > 299 {
+		msg := strings.TrimSpace(rw.body.String())
+		if msg == "" {
+			msg = http.StatusText(rw.status)
+		}
+		return errors.New(msg)
+	}
+	if json.Valid(rw.body.Bytes()) {
+		job.update(func(res *JobRes) { res.Result = json.RawMessage(rw.body.Bytes()) })
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package adminserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_jobsHandlers(t *testing.T) {
+	ctx := context.Background()
+	eng, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+	eng.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+	_, err = eng.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+
+	subject := &Server{e: eng, jobs: newJobs(nil)}
+	t.Cleanup(subject.jobs.close)
+	do := func(handler http.HandlerFunc, method, path string) (*httptest.ResponseRecorder, *JobRes) {
+		req, err := http.NewRequest(method, path, nil)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		var resp JobRes
+		if rr.Code == http.StatusOK || rr.Code == http.StatusAccepted {
+			_, err = resp.ReadFrom(rr.Body)
+			require.NoError(t, err)
+		}
+		return rr, &resp
+	}
+	awaitJob := func(id string) *JobRes {
+		var job *JobRes
+		require.Eventually(t, func() bool {
+			var rr *httptest.ResponseRecorder
+			rr, job = do(subject.getJobHandler, http.MethodGet, jobsPath+id)
+			require.Equal(t, http.StatusOK, rr.Code)
+			return job.Status != JobRunning
+		}, 10*time.Second, 10*time.Millisecond)
+		return job
+	}
+
+	rr, job := do(subject.gcHandler, http.MethodPost, "/admin/gc")
+	require.Equal(t, http.StatusAccepted, rr.Code)
+	require.Equal(t, jobKindGC, job.Kind)
+	job = awaitJob(job.ID)
+	require.Equal(t, JobSucceeded, job.Status)
+	require.Zero(t, job.BytesReclaimed)
+	require.NotNil(t, job.Finished)
+
+	rr, job = do(subject.cachePurgeHandler, http.MethodPost, "/admin/cache/purge")
+	require.Equal(t, http.StatusAccepted, rr.Code)
+	require.Equal(t, jobKindCachePurge, job.Kind)
+	job = awaitJob(job.ID)
+	require.Equal(t, JobSucceeded, job.Status)
+	require.Positive(t, job.BytesReclaimed)
+	require.Empty(t, job.Error)
+
+	rr, _ = do(subject.getJobHandler, http.MethodGet, jobsPath+"unknown")
+	require.Equal(t, http.StatusNotFound, rr.Code)
+	rr, _ = do(subject.cachePurgeHandler, http.MethodGet, "/admin/cache/purge")
+	require.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+}
+
+func Test_asyncHandler(t *testing.T) {
+	ctx := context.Background()
+	eng, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+	eng.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+	for _, contextID := range []string{"fish", "lobster", "crab"} {
+		_, err = eng.NotifyPut(ctx, nil, []byte(contextID), metadata.Default.New(metadata.Bitswap{}))
+		require.NoError(t, err)
+	}
+
+	s := &Server{e: eng, jobs: newJobs(nil)}
+	t.Cleanup(s.jobs.close)
+	subject := s.asyncHandler(jobKindRemoveContexts, (&contextHandler{e: eng}).handleRemove)
+	do := func(body string) *JobRes {
+		req, err := http.NewRequest(http.MethodPost, "/admin/remove/context?async=true", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		subject.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusAccepted, rr.Code)
+		var job JobRes
+		_, err = job.ReadFrom(rr.Body)
+		require.NoError(t, err)
+		require.Equal(t, jobKindRemoveContexts, job.Kind)
+		require.Eventually(t, func() bool {
+			job, _ = s.jobs.get(job.ID)
+			return job.Status != JobRunning
+		}, 10*time.Second, 10*time.Millisecond)
+		return &job
+	}
+
+	job := do(`{"all":true}`)
+	require.Equal(t, JobSucceeded, job.Status)
+	require.Equal(t, http.StatusOK, job.Code)
+	require.Equal(t, &JobProgress{Done: 3, Total: 3}, job.Progress)
+	var res RemoveContextRes
+	_, err = res.ReadFrom(bytes.NewReader(job.Result))
+	require.NoError(t, err)
+	require.Len(t, res.Removed, 3)
+
+	job = do(`{"context_ids":["ZmlzaA=="]}`)
+	require.Equal(t, JobFailed, job.Status)
+	require.Equal(t, http.StatusNotFound, job.Code)
+	require.Contains(t, job.Error, "provider has no content for context ID")
+	require.Empty(t, job.Result)
+
+	// Requests without async=true are served synchronously.
+	req, err := http.NewRequest(http.MethodPost, "/admin/remove/context", bytes.NewBufferString(`{}`))
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	subject.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func Test_jobsPersistedAcrossRestarts(t *testing.T) {
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	subject := newJobs(ds)
+	done := subject.start(jobKindGC, func(context.Context, *runningJob) error { return nil })
+	running := subject.start(jobKindGC, func(ctx context.Context, job *runningJob) error {
+		job.reportProgress(1, 2)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	require.Eventually(t, func() bool {
+		job, _ := subject.get(done.ID)
+		return job.Status == JobSucceeded
+	}, 10*time.Second, 10*time.Millisecond)
+
+	// Simulate the server stopping while a job runs, without it recording the
+	// failure of the job.
+	runningStatus, ok := subject.get(running.ID)
+	require.True(t, ok)
+	require.Equal(t, JobRunning, runningStatus.Status)
+	subject.close()
+	value, err := json.Marshal(&runningStatus)
+	require.NoError(t, err)
+	require.NoError(t, ds.Put(context.Background(), jobKey(running.ID), value))
+
+	subject = newJobs(ds)
+	t.Cleanup(subject.close)
+	job, ok := subject.get(done.ID)
+	require.True(t, ok)
+	require.Equal(t, JobSucceeded, job.Status)
+	job, ok = subject.get(running.ID)
+	require.True(t, ok)
+	require.Equal(t, JobFailed, job.Status)
+	require.Equal(t, errJobInterrupted.Error(), job.Error)
+	require.NotNil(t, job.Finished)
+	require.Equal(t, &JobProgress{Done: 1, Total: 2}, job.Progress)
+}
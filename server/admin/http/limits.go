@@ -0,0 +1,113 @@
+package adminserver
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimit limits the rate of requests to a route to Rate requests per
+// second, allowing bursts of up to Burst requests. See WithRateLimit.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+func newRateLimiter(limit RateLimit) (*rate.Limiter, error) {
+	if limit.Rate <= 0 {
+		return nil, errors.New("rate limit must be positive")
+	}
+	if limit.Burst < 1 {
+		return nil, errors.New("rate limit burst must be at least 1")
+	}
+	return rate.NewLimiter(rate.Limit(limit.Rate), limit.Burst), nil
+}
+
+// SetRateLimits replaces the rate limits of all routes with the given limits,
+// keyed by route, while the server is running. Routes are matched as in
+// WithRateLimit. Requests are no longer rate limited if no limits are given.
+func (s *Server) SetRateLimits(limits map[string]RateLimit) error {
+	limiters := make(map[string]*rate.Limiter, len(limits))
+	for route, limit := range limits {
+		limiter, err := newRateLimiter(limit)
+		if err != nil {
+			return fmt.Errorf("invalid rate limit of route %s: %w", route, err)
+		}
+		limiters[route] = limiter
+	}
+	s.rateLimits.Store(&limiters)
+	log.Infow("Rate limits changed", "limits", limits)
+	return nil
+}
+
+// limitRequests wraps the given handler such that requests are rejected with
+// 429 Too Many Requests when they exceed the current rate limit of their
+// route, and with 413 Request Entity Too Large when their body exceeds the
+// maximum size for their route.
+func limitRequests(rateLimits *atomic.Pointer[map[string]*rate.Limiter], maxBodySizes map[string]int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if limiters := rateLimits.Load(); limiters != nil {
+			if limiter, ok := matchRoute(*limiters, r.URL.Path); ok {
+				res := limiter.Reserve()
+				if delay := res.Delay(); delay > 0 {
+					res.Cancel()
+					w.Header().Set("Retry-After", retryAfter(delay))
+					http.Error(w, "", http.StatusTooManyRequests)
+					return
+				}
+			}
+		}
+		if maxSize, ok := matchRoute(maxBodySizes, r.URL.Path); ok && maxSize > 0 && r.Body != nil {
+			if r.ContentLength > maxSize {
+				http.Error(w, "", http.StatusRequestEntityTooLarge)
+				return
+			}
+			// The body is read in full, since it is read in full by handlers
+			// anyway, such that bodies of unknown length exceeding the
+			// maximum size are rejected too.
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxSize+1))
+			if err != nil {
+				http.Error(w, "failed to read request body", http.StatusBadRequest)
+				return
+			}
+			if int64(len(body)) > maxSize {
+				http.Error(w, "", http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// matchRoute returns the value of the route that matches the given path. A
+// route matches the path if it is equal to the path, or if it ends with "/"
+// and prefixes the path, in which case the longest such route matches.
+func matchRoute[T any](routes map[string]T, path string) (T, bool) {
+	if v, ok := routes[path]; ok {
+		return v, true
+	}
+	var match T
+	var matchLen int
+	for route, v := range routes {
+		if strings.HasSuffix(route, "/") && strings.HasPrefix(path, route) && len(route) > matchLen {
+			match, matchLen = v, len(route)
+		}
+	}
+	return match, matchLen != 0
+}
+
+// retryAfter returns the value of the Retry-After header for the given delay,
+// in whole seconds.
+func retryAfter(delay time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(delay.Seconds())))
+}
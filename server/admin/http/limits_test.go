@@ -0,0 +1,93 @@
+package adminserver
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func Test_limitRequests(t *testing.T) {
+	opts, err := newOptions(
+		WithRateLimit("/admin/", 0.001, 2),
+		WithRateLimit("/admin/stats", 1000, 100),
+		WithMaxRequestBodySize("/", 8),
+		WithMaxRequestBodySize("/admin/advertise", 16),
+		WithMaxRequestBodySize("/admin/import/car", 0))
+	require.NoError(t, err)
+
+	var rateLimits atomic.Pointer[map[string]*rate.Limiter]
+	rateLimits.Store(&opts.rateLimits)
+	var gotBody string
+	subject := limitRequests(&rateLimits, opts.maxBodySizes, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		gotBody = string(body)
+	}))
+	do := func(path, body string, unknownLength bool) *httptest.ResponseRecorder {
+		var r io.Reader = strings.NewReader(body)
+		if unknownLength {
+			r = io.MultiReader(r)
+		}
+		req := httptest.NewRequest(http.MethodPost, path, r)
+		if unknownLength {
+			req.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		subject.ServeHTTP(w, req)
+		return w
+	}
+
+	// The most specific route applies.
+	require.Equal(t, http.StatusOK, do("/admin/advertise", "0123456789abcdef", false).Code)
+	require.Equal(t, "0123456789abcdef", gotBody)
+	require.Equal(t, http.StatusRequestEntityTooLarge, do("/admin/stats", "0123456789", false).Code)
+	require.Equal(t, http.StatusRequestEntityTooLarge, do("/admin/stats", "0123456789", true).Code)
+	require.Equal(t, http.StatusOK, do("/admin/stats", "01234567", true).Code)
+	require.Equal(t, "01234567", gotBody)
+	require.Equal(t, http.StatusOK, do("/admin/import/car", strings.Repeat("x", 100), false).Code)
+
+	// The burst of 2 is used by the requests to /admin/advertise and
+	// /admin/import/car above.
+	w := do("/admin/announce", "", false)
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.NotEmpty(t, w.Header().Get("Retry-After"))
+	require.Equal(t, http.StatusOK, do("/admin/stats", "", false).Code)
+	// Paths under no rate limited route are not rate limited.
+	require.Equal(t, http.StatusOK, do("/metrics", "", false).Code)
+}
+
+func TestServer_SetRateLimits(t *testing.T) {
+	subject := &Server{}
+	handler := limitRequests(&subject.rateLimits, nil, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	do := func() int {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/stats", nil))
+		return w.Code
+	}
+
+	require.Equal(t, http.StatusOK, do())
+	require.Equal(t, http.StatusOK, do())
+	require.NoError(t, subject.SetRateLimits(map[string]RateLimit{"/admin/": {Rate: 0.001, Burst: 1}}))
+	require.Equal(t, http.StatusOK, do())
+	require.Equal(t, http.StatusTooManyRequests, do())
+	require.NoError(t, subject.SetRateLimits(nil))
+	require.Equal(t, http.StatusOK, do())
+
+	require.Error(t, subject.SetRateLimits(map[string]RateLimit{"/admin/": {Rate: 1}}))
+	require.Equal(t, http.StatusOK, do())
+}
+
+func Test_limitRequestsValidatesOptions(t *testing.T) {
+	_, err := newOptions(WithRateLimit("/", 0, 1))
+	require.Error(t, err)
+	_, err = newOptions(WithRateLimit("/", 1, 0))
+	require.Error(t, err)
+	_, err = newOptions(WithMaxRequestBodySize("/", -1))
+	require.Error(t, err)
+}
@@ -0,0 +1,92 @@
+package adminserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	logging "github.com/ipfs/go-log/v2"
+)
+
+const logPath = "/admin/log"
+
+// listLogLevelsHandler responds with the current log level of every logging
+// subsystem.
+func (s *Server) listLogLevelsHandler(w http.ResponseWriter, r *http.Request) {
+	if !methodOK(w, r, http.MethodGet) {
+		return
+	}
+	resp := &ListLogLevelsRes{Levels: make(map[string]string)}
+	for _, subsystem := range logging.GetSubsystems() {
+		resp.Levels[subsystem] = logLevel(subsystem)
+	}
+	respond(w, http.StatusOK, resp)
+}
+
+// logLevelHandler gets or sets the log level of the logging subsystem named
+// by the request path, e.g. "/admin/log/provider/engine". Setting the level of
+// subsystem "*" sets the level of all subsystems.
+func (s *Server) logLevelHandler(w http.ResponseWriter, r *http.Request) {
+	subsystem := strings.TrimPrefix(r.URL.Path, logPath+"/")
+	if subsystem == "" {
+		http.Error(w, "subsystem must be specified", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if !hasSubsystem(subsystem) {
+			http.Error(w, fmt.Sprintf("logging subsystem %q not found", subsystem), http.StatusNotFound)
+			return
+		}
+		respond(w, http.StatusOK, &LogLevelRes{Subsystem: subsystem, Level: logLevel(subsystem)})
+	case http.MethodPut:
+		if !matchContentTypeJson(w, r) {
+			return
+		}
+		var req SetLogLevelReq
+		if _, err := req.ReadFrom(r.Body); err != nil {
+			msg := fmt.Sprintf("failed to unmarshal request. %v", err)
+			log.Errorw(msg, "err", err)
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		if _, err := logging.LevelFromString(req.Level); err != nil {
+			http.Error(w, fmt.Sprintf("invalid log level %q", req.Level), http.StatusBadRequest)
+			return
+		}
+		if err := logging.SetLogLevel(subsystem, req.Level); err != nil {
+			if errors.Is(err, logging.ErrNoSuchLogger) {
+				http.Error(w, fmt.Sprintf("logging subsystem %q not found", subsystem), http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Infow("Set log level", "subsystem", subsystem, "level", req.Level)
+		level := strings.ToLower(req.Level)
+		if subsystem != "*" {
+			level = logLevel(subsystem)
+		}
+		respond(w, http.StatusOK, &LogLevelRes{Subsystem: subsystem, Level: level})
+	default:
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPut)
+		http.Error(w, "", http.StatusMethodNotAllowed)
+	}
+}
+
+func hasSubsystem(subsystem string) bool {
+	for _, s := range logging.GetSubsystems() {
+		if s == subsystem {
+			return true
+		}
+	}
+	return false
+}
+
+// logLevel returns the name of the current log level of the given existing
+// logging subsystem.
+func logLevel(subsystem string) string {
+	return logging.Logger(subsystem).Level().String()
+}
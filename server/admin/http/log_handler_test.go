@@ -0,0 +1,55 @@
+package adminserver
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_logLevelHandlers(t *testing.T) {
+	const subsystem = "adminserver/test"
+	testLog := logging.Logger(subsystem)
+	require.NoError(t, logging.SetLogLevel(subsystem, "info"))
+	subject := &Server{}
+	do := func(handler http.HandlerFunc, method, path, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(subject.listLogLevelsHandler, http.MethodGet, logPath, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var levels ListLogLevelsRes
+	_, err := levels.ReadFrom(rr.Body)
+	require.NoError(t, err)
+	require.Equal(t, "info", levels.Levels[subsystem])
+
+	rr = do(subject.logLevelHandler, http.MethodPut, logPath+"/"+subsystem, `{"level":"debug"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var level LogLevelRes
+	_, err = level.ReadFrom(rr.Body)
+	require.NoError(t, err)
+	require.Equal(t, LogLevelRes{Subsystem: subsystem, Level: "debug"}, level)
+	require.Equal(t, "debug", testLog.Level().String())
+
+	rr = do(subject.logLevelHandler, http.MethodGet, logPath+"/"+subsystem, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	_, err = level.ReadFrom(rr.Body)
+	require.NoError(t, err)
+	require.Equal(t, "debug", level.Level)
+
+	rr = do(subject.logLevelHandler, http.MethodPut, logPath+"/"+subsystem, `{"level":"loud"}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = do(subject.logLevelHandler, http.MethodPut, logPath+"/unknown/subsystem", `{"level":"debug"}`)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+	rr = do(subject.logLevelHandler, http.MethodGet, logPath+"/unknown/subsystem", "")
+	require.Equal(t, http.StatusNotFound, rr.Code)
+	rr = do(subject.logLevelHandler, http.MethodPost, logPath+"/"+subsystem, "")
+	require.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+}
@@ -1,7 +1,12 @@
 package adminserver
 
 import (
+	"encoding/json"
+	"time"
+
 	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multihash"
 )
 
 type (
@@ -14,14 +19,48 @@ type (
 	}
 )
 
+type (
+	// ListPeersRes represents the response to list the peers connected to the provider.
+	ListPeersRes struct {
+		// The connected peers, in ascending peer ID order.
+		Peers []PeerInfo `json:"peers"`
+	}
+	// PeerInfo represents a peer connected to the provider.
+	PeerInfo struct {
+		// The ID of the peer.
+		ID peer.ID `json:"id"`
+		// The remote multiaddrs of the connections to the peer.
+		Addrs []string `json:"addrs"`
+		// The direction of the first connection to the peer: "inbound" or "outbound".
+		Direction string `json:"direction"`
+		// Whether the connections to the peer are protected from being trimmed by the
+		// connection manager.
+		Protected bool `json:"protected"`
+		// The protocols supported by the peer, if known.
+		Protocols []string `json:"protocols"`
+	}
+	// PeerReq represents a request to disconnect from, protect or unprotect a peer.
+	PeerReq struct {
+		// The ID of the peer.
+		PeerID peer.ID `json:"peer_id"`
+		// The optional tag with which to protect or unprotect the connections to the peer. If not
+		// provided, the "admin" tag is used.
+		Tag string `json:"tag,omitempty"`
+	}
+	// PeerRes represents the response to a PeerReq.
+	PeerRes struct { // Empty placeholder used to return an empty JSON object in body.
+	}
+)
+
 type (
 	// ImportCarReq represents a request for importing a CAR file.
 	ImportCarReq struct {
-		// The path to the CAR file
+		// The path to the CAR file, or the http, https or s3 URL of a remote CAR file.
 		Path string `json:"path"`
 		// The optional key associated to the CAR. If not provided, one will be generated.
 		Key []byte `json:"key"`
-		// The optional metadata.
+		// The optional binary encoded metadata. If not provided, the default
+		// metadata of the provider is used.
 		Metadata []byte `json:"metadata"`
 	}
 	// ImportCarRes represents the response to an ImportCarReq.
@@ -46,6 +85,405 @@ type (
 	}
 )
 
+type (
+	// AdvertiseReq represents a request for publishing an advertisement by context ID.
+	AdvertiseReq struct {
+		// The context ID to advertise.
+		ContextID []byte `json:"context_id"`
+		// The optional provider to advertise the content for. If not provided, the default
+		// provider is assumed.
+		Provider *peer.AddrInfo `json:"provider,omitempty"`
+		// The binary encoded metadata. Required unless the provider has default
+		// metadata.
+		Metadata []byte `json:"metadata"`
+		// The optional multihashes to advertise. If not provided, the multihashes are listed
+		// by the provider for the context ID, e.g. from a previously imported CAR.
+		Multihashes []multihash.Multihash `json:"multihashes,omitempty"`
+		// The optional labels of the published advertisement. Labels are kept by the provider
+		// to manage its advertisements, and are not published.
+		Labels map[string]string `json:"labels,omitempty"`
+	}
+	// AdvertiseRes represents the response to an AdvertiseReq.
+	AdvertiseRes struct {
+		// The CID of the published advertisement.
+		AdvId cid.Cid `json:"adv_id"`
+	}
+	// DryRunRes represents the response to an AdvertiseReq sent as a dry run, which
+	// describes the advertisement that would be published without publishing it.
+	DryRunRes struct {
+		// The CID that the advertisement would have if it were published next.
+		AdvId cid.Cid `json:"adv_id"`
+		// The CID of the entries of the advertisement.
+		Entries cid.Cid `json:"entries"`
+		// The number of advertised multihashes, if known.
+		MultihashCount *int `json:"multihash_count,omitempty"`
+		// The number of multihashes that the provider failed to list.
+		SkippedCount int `json:"skipped_count,omitempty"`
+		// The number of advertisements that the content would be split across.
+		Parts int `json:"parts"`
+		// The estimated number of bytes of the advertisement and of its new entries.
+		Size int64 `json:"size"`
+	}
+	// RemoveReq represents a request for publishing a removal advertisement by context ID.
+	RemoveReq struct {
+		// The context ID to remove.
+		ContextID []byte `json:"context_id"`
+		// The optional ID of the provider to remove the content for. If not provided, the
+		// default provider is assumed.
+		Provider peer.ID `json:"provider,omitempty"`
+	}
+	// RemoveRes represents the response to a RemoveReq.
+	RemoveRes struct {
+		// The CID of the removal advertisement.
+		AdvId cid.Cid `json:"adv_id"`
+	}
+)
+
+type (
+	// RemoveContextReq represents a request for publishing removal advertisements by context ID.
+	RemoveContextReq struct {
+		// The context IDs to remove.
+		ContextIDs [][]byte `json:"context_ids,omitempty"`
+		// Whether to remove all the context IDs currently advertised by the provider.
+		All bool `json:"all,omitempty"`
+	}
+	// RemoveContextRes represents the response to a RemoveContextReq.
+	RemoveContextRes struct {
+		// The removed context IDs along with the CIDs of the removal advertisements.
+		Removed []RemovedContext `json:"removed"`
+	}
+	// RemovedContext represents a context ID removed as a result of a RemoveContextReq.
+	RemovedContext struct {
+		// The removed context ID.
+		ContextID []byte `json:"context_id"`
+		// The CID of the advertisement generated as a result of removal.
+		AdvId cid.Cid `json:"adv_id"`
+	}
+)
+
+type (
+	// ListContextsRes represents the response to list the context IDs currently advertised.
+	ListContextsRes struct {
+		// The advertised contexts, in ascending context ID byte order.
+		Contexts []ContextInfo `json:"contexts"`
+		// The cursor from which to list the next page of contexts, if there are more contexts.
+		Next []byte `json:"next,omitempty"`
+	}
+	// ContextInfo represents the content advertised under a context ID.
+	ContextInfo struct {
+		// The context ID.
+		ContextID []byte `json:"context_id"`
+		// The CID of the advertised entries.
+		Entries cid.Cid `json:"entries"`
+		// The number of advertised multihashes, if known.
+		MultihashCount *int `json:"multihash_count,omitempty"`
+		// The names of the retrieval protocols in the advertised metadata.
+		Protocols []string `json:"protocols"`
+		// The time at which the content was last advertised, if known.
+		PublishedAt *time.Time `json:"published_at,omitempty"`
+	}
+)
+
+type (
+	// StatsRes represents the response to get the statistics of the engine.
+	StatsRes struct {
+		// The CID of the latest advertisement, if any advertisements are published.
+		Head *cid.Cid `json:"head,omitempty"`
+		// The number of advertisements in the chain.
+		ChainLength int `json:"chain_length"`
+		// The number of context IDs currently advertised.
+		Contexts int `json:"contexts"`
+		// The number of multihashes currently advertised, excluding those of contexts whose
+		// multihash count is unknown.
+		Multihashes int64 `json:"multihashes"`
+		// The number of entries chunk chains in the entries cache.
+		CachedChunks int `json:"cached_chunks"`
+		// The maximum number of entries chunk chains in the entries cache.
+		CacheCapacity int `json:"cache_capacity"`
+		// The number of announcements sent since the provider started.
+		AnnounceSuccesses uint64 `json:"announce_successes"`
+		// The number of announcements that failed to be sent since the provider started.
+		AnnounceFailures uint64 `json:"announce_failures"`
+		// The time at which an advertisement was last published since the provider started.
+		LastPublished *time.Time `json:"last_published,omitempty"`
+		// The statistics of announcing to each target since the provider started, keyed by the
+		// URL of the indexer or by the gossip pubsub topic prefixed with "pubsub:".
+		AnnounceTargets map[string]AnnounceTargetStats `json:"announce_targets,omitempty"`
+	}
+	// AnnounceTargetStats represents the statistics of announcing to a target.
+	AnnounceTargetStats struct {
+		// The number of announcements sent to the target, including those still being sent.
+		Attempts uint64 `json:"attempts"`
+		// The number of announcements sent to the target successfully.
+		Successes uint64 `json:"successes"`
+		// The number of announcements that failed to be sent to the target.
+		Failures uint64 `json:"failures"`
+		// The mean time taken to send an announcement to the target in milliseconds.
+		MeanLatencyMs int64 `json:"mean_latency_ms"`
+		// The time taken to send the last announcement to the target in milliseconds.
+		LastLatencyMs int64 `json:"last_latency_ms"`
+		// The time at which an announcement was last sent to the target.
+		LastAttempt *time.Time `json:"last_attempt,omitempty"`
+		// The error of the last announcement that failed to be sent to the target, if any.
+		LastError string `json:"last_error,omitempty"`
+	}
+	// HeadRes represents the response to get the head of the advertisement chain.
+	HeadRes struct {
+		// The CID of the latest advertisement.
+		Head cid.Cid `json:"head"`
+		// The topic on which the head is announced.
+		Topic string `json:"topic"`
+		// The marshaled public key of the provider.
+		PubKey []byte `json:"pubkey"`
+		// The signature of the head and topic, as in the signed head served at /ipni/v1/ad/head.
+		Sig []byte `json:"sig"`
+		// The sequence number of the head if it has one, or otherwise the number of
+		// advertisements kept in the chain, which is lower than its height once pruned.
+		Height uint64 `json:"height"`
+		// The time at which the head was published, if known.
+		Published *time.Time `json:"published,omitempty"`
+	}
+)
+
+type (
+	// GossipRes represents the response to get the diagnostics of announcing over gossip pubsub.
+	GossipRes struct {
+		// The name of the topic on which announcements are published.
+		Topic string `json:"topic"`
+		// Whether announcements are published over gossip pubsub.
+		Enabled bool `json:"enabled"`
+		// The peers known to be subscribed to the topic, in ascending peer ID order.
+		TopicPeers []peer.ID `json:"topic_peers"`
+		// The peers in the gossipsub mesh of the topic, in ascending peer ID order, if the mesh
+		// is known.
+		MeshPeers []peer.ID `json:"mesh_peers,omitempty"`
+		// Whether the gossipsub mesh of the topic is known, which it is only if the provider
+		// joins the mesh.
+		MeshKnown bool `json:"mesh_known"`
+		// Whether each configured indexer peer is reached by announcements.
+		Indexers []GossipIndexer `json:"indexers"`
+		// The time at which an announcement was last published while at least one peer was
+		// subscribed to the topic, since the provider started.
+		LastPropagated *time.Time `json:"last_propagated,omitempty"`
+		// The CID of the advertisement last announced at last_propagated.
+		LastPropagatedAd *cid.Cid `json:"last_propagated_ad,omitempty"`
+		// The number of peers subscribed to the topic at last_propagated.
+		LastPropagatedPeers int `json:"last_propagated_peers"`
+	}
+	// GossipIndexer represents whether an indexer peer is reached by announcements.
+	GossipIndexer struct {
+		// The ID of the indexer peer.
+		ID peer.ID `json:"id"`
+		// Whether the provider is connected to the indexer.
+		Connected bool `json:"connected"`
+		// Whether the indexer is known to be subscribed to the topic.
+		InTopic bool `json:"in_topic"`
+		// Whether the indexer is in the gossipsub mesh of the topic.
+		InMesh bool `json:"in_mesh"`
+	}
+)
+
+type (
+	// ListPublishQueueRes represents the response to list the publish queue of the provider.
+	ListPublishQueueRes struct {
+		// The publish in progress, if any, followed by the waiting publishes in the order in
+		// which they will be published.
+		Queue []QueuedPublishInfo `json:"queue"`
+	}
+	// QueuedPublishInfo represents a publish in the publish queue.
+	QueuedPublishInfo struct {
+		// The ID of the publish in the queue.
+		ID uint64 `json:"id"`
+		// The kind of publish, e.g. "put", "update" or "remove".
+		Kind string `json:"kind"`
+		// The priority of the publish, one of "removal", "new-data" or "metadata".
+		Priority string `json:"priority"`
+		// The ID of the provider of the advertised context ID, if any.
+		Provider string `json:"provider,omitempty"`
+		// The advertised context ID, if any.
+		ContextID []byte `json:"context_id,omitempty"`
+		// The time at which the publish was queued.
+		Queued time.Time `json:"queued"`
+		// Whether the publish is in progress, rather than waiting.
+		Publishing bool `json:"publishing"`
+	}
+	// ReorderPublishReq represents a request to reorder a waiting publish in the publish queue.
+	ReorderPublishReq struct {
+		// The priority to set, one of "removal", "new-data" or "metadata".
+		Priority string `json:"priority,omitempty"`
+		// Whether to make the publish the next to be published, raising its priority to the
+		// highest of the waiting publishes if needed. Applied after setting the priority.
+		Front bool `json:"front,omitempty"`
+	}
+)
+
+type (
+	// ListAdsRes represents the response to list the advertisements published by the provider.
+	ListAdsRes struct {
+		// The advertisements, newest first.
+		Ads []AdInfo `json:"ads"`
+		// The cursor from which to list the next page of advertisements, if there are more
+		// advertisements.
+		Next *cid.Cid `json:"next,omitempty"`
+	}
+	// AdInfo represents an advertisement published by the provider.
+	AdInfo struct {
+		// The CID of the advertisement.
+		ID cid.Cid `json:"id"`
+		// The CID of the previous advertisement in the chain, if any.
+		PreviousID *cid.Cid `json:"previous_id,omitempty"`
+		// The ID of the provider of the advertised content.
+		Provider string `json:"provider"`
+		// The addresses of the provider.
+		Addresses []string `json:"addresses"`
+		// The context ID.
+		ContextID []byte `json:"context_id"`
+		// The binary encoded metadata.
+		Metadata []byte `json:"metadata"`
+		// The retrieval protocols in the metadata.
+		Protocols []string `json:"protocols"`
+		// The CID of the entries, if the advertisement has any.
+		Entries *cid.Cid `json:"entries,omitempty"`
+		// Whether the advertisement is a removal advertisement.
+		IsRm bool `json:"is_rm"`
+		// Whether the advertisement has extended providers.
+		ExtendedProviders bool `json:"extended_providers,omitempty"`
+		// The number of entries chunks present locally, or -1 if the entries are not a chain of
+		// chunks.
+		ChunkCount int `json:"chunk_count"`
+		// Whether all entries are present locally.
+		EntriesPresent bool `json:"entries_present"`
+		// The labels of the advertisement, if any.
+		Labels map[string]string `json:"labels,omitempty"`
+		// The sequence number embedded in the metadata of the advertisement, if any.
+		Sequence *uint64 `json:"sequence,omitempty"`
+		// The timestamp embedded in the metadata of the advertisement, if any.
+		Timestamp *time.Time `json:"timestamp,omitempty"`
+	}
+	// SetAdLabelsReq represents a request to replace the labels of an advertisement.
+	SetAdLabelsReq struct {
+		// The labels. The labels of the advertisement are removed if empty.
+		Labels map[string]string `json:"labels"`
+	}
+)
+
+type (
+	// ListAuditRes represents the response to list the records of the audit log of the
+	// advertisements published by the provider.
+	ListAuditRes struct {
+		// The records, oldest first.
+		Records []AuditRecord `json:"records"`
+		// The cursor from which to list the next page of records, if there are more records.
+		Next *uint64 `json:"next,omitempty"`
+	}
+	// AuditRecord represents a record of an advertisement published by the provider.
+	AuditRecord struct {
+		// The sequence number of the record.
+		Seq uint64 `json:"seq"`
+		// The time at which the advertisement was published.
+		Time time.Time `json:"time"`
+		// The CID of the advertisement.
+		AdID cid.Cid `json:"ad_id"`
+		// The ID of the provider of the advertised content.
+		Provider string `json:"provider"`
+		// The context ID.
+		ContextID []byte `json:"context_id"`
+		// Whether the advertisement is a removal advertisement.
+		IsRm bool `json:"is_rm"`
+		// Whether the advertisement only updates the metadata of content already advertised.
+		IsUpdate bool `json:"is_update,omitempty"`
+		// The number of multihashes advertised, or -1 if unknown.
+		MultihashCount int `json:"multihash_count"`
+		// The number of multihashes that the lister failed to list, and that are not advertised.
+		SkippedCount int `json:"skipped_count,omitempty"`
+		// The retrieval protocols in the metadata.
+		Protocols []string `json:"protocols"`
+		// The outcomes of announcing the advertisement, one per announce sender.
+		Announces []AuditAnnounce `json:"announces"`
+	}
+	// AuditAnnounce represents the outcome of sending the announcement of an advertisement via
+	// one announce sender.
+	AuditAnnounce struct {
+		// The indexer URLs or gossip pubsub topic the announcement was sent to.
+		Targets []string `json:"targets"`
+		// The error with which sending the announcement failed, if any.
+		Error string `json:"error,omitempty"`
+	}
+)
+
+type (
+	// ListLogLevelsRes represents the response to list the log levels of logging subsystems.
+	ListLogLevelsRes struct {
+		// The log level of each subsystem, keyed by subsystem name.
+		Levels map[string]string `json:"levels"`
+	}
+	// SetLogLevelReq represents a request to set the log level of a logging subsystem.
+	SetLogLevelReq struct {
+		// The log level, e.g. "debug", "info", "warn" or "error".
+		Level string `json:"level"`
+	}
+	// LogLevelRes represents the log level of a logging subsystem.
+	LogLevelRes struct {
+		// The name of the subsystem, or "*" if the level was set for all subsystems.
+		Subsystem string `json:"subsystem"`
+		// The log level.
+		Level string `json:"level"`
+	}
+)
+
+type (
+	// ReloadRes represents the response to reload the configuration of the provider.
+	ReloadRes struct {
+		// The paths of the changed configuration fields that were applied, e.g.
+		// "DirectAnnounce.URLs".
+		Applied []string `json:"applied"`
+		// The paths of the changed configuration fields that require a restart to be applied.
+		RestartRequired []string `json:"restart_required"`
+	}
+)
+
+// Job statuses.
+const (
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+type (
+	// JobRes represents the status of an asynchronous job, such as purging the entries cache.
+	JobRes struct {
+		// The ID of the job.
+		ID string `json:"id"`
+		// The kind of job, e.g. "cache-purge" or "gc".
+		Kind string `json:"kind"`
+		// The status of the job: one of "running", "succeeded" or "failed".
+		Status string `json:"status"`
+		// The time at which the job started.
+		Started time.Time `json:"started"`
+		// The time at which the job finished, if it has.
+		Finished *time.Time `json:"finished,omitempty"`
+		// The number of bytes reclaimed by the job.
+		BytesReclaimed int64 `json:"bytes_reclaimed"`
+		// The progress of the job, if it reports any.
+		Progress *JobProgress `json:"progress,omitempty"`
+		// The HTTP status code with which the request run as the job would have been responded
+		// to, for jobs that run requests asynchronously.
+		Code int `json:"code,omitempty"`
+		// The body with which the request run as the job would have been responded to, if the
+		// job succeeded, e.g. an ImportCarRes for "import-car" jobs.
+		Result json.RawMessage `json:"result,omitempty"`
+		// The error with which the job failed, if any.
+		Error string `json:"error,omitempty"`
+	}
+	// JobProgress represents the progress of a job.
+	JobProgress struct {
+		// The units of work done, e.g. the number of context IDs removed.
+		Done int64 `json:"done"`
+		// The total units of work, if known.
+		Total int64 `json:"total,omitempty"`
+	}
+)
+
 type (
 	// ListCarRes represents the response to list cars.
 	ListCarRes struct {
@@ -55,8 +493,48 @@ type (
 )
 
 type (
+	// AnnounceHttpReq represents a request to announce the latest advertisement directly to
+	// indexers via HTTP.
+	AnnounceHttpReq struct {
+		// The URL of a single indexer to announce to, encoded as bytes.
+		//
+		// Deprecated: Use Indexers instead. Kept for compatibility with older clients.
+		Indexer []byte `json:"indexer,omitempty"`
+		// The URLs of the indexers to announce to.
+		Indexers []string `json:"indexers,omitempty"`
+	}
 	AnnounceRes struct {
 		// The CID of the advertisement announced as latest.
 		AdvId cid.Cid `json:"adv_id"`
 	}
 )
+
+type (
+	// TenantInfo represents a provider identity hosted by the provider.
+	TenantInfo struct {
+		// The peer ID of the tenant.
+		ID peer.ID `json:"id"`
+		// The addresses at which the content advertised by the tenant is retrievable.
+		RetrievalAddrs []string `json:"retrieval_addrs"`
+		// The CID of the latest advertisement of the tenant, if any.
+		Head *cid.Cid `json:"head,omitempty"`
+		// The path at which the advertisements of the tenant are published.
+		PublisherPath string `json:"publisher_path"`
+	}
+	// ListTenantsRes represents the response to list the tenants.
+	ListTenantsRes struct {
+		// The tenants, in ascending peer ID order.
+		Tenants []TenantInfo `json:"tenants"`
+	}
+	// CreateTenantReq represents a request to create a tenant.
+	CreateTenantReq struct {
+		// The optional protobuf encoded libp2p private key of the tenant. If not
+		// provided, a new Ed25519 key is generated.
+		PrivateKey []byte `json:"private_key,omitempty"`
+		// The addresses at which the content advertised by the tenant is retrievable.
+		RetrievalAddrs []string `json:"retrieval_addrs"`
+	}
+	// DeleteTenantRes represents the response to delete a tenant.
+	DeleteTenantRes struct { // Empty placeholder used to return an empty JSON object in body.
+	}
+)
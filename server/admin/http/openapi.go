@@ -0,0 +1,19 @@
+package adminserver
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// OpenAPI is the OpenAPI document describing the routes of the admin server.
+//
+//go:embed openapi.json
+var OpenAPI []byte
+
+func (s *Server) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	if !methodOK(w, r, http.MethodGet) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(OpenAPI)
+}
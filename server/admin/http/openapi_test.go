@@ -0,0 +1,68 @@
+package adminserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipni/index-provider/engine"
+	"github.com/ipni/index-provider/supplier"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOpenAPI_DocumentsRoutes checks that every operation in the OpenAPI
+// document is routed by the server with the documented method.
+func TestOpenAPI_DocumentsRoutes(t *testing.T) {
+	var doc struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(OpenAPI, &doc))
+	require.Equal(t, "3.0.3", doc.OpenAPI)
+
+	ctx := context.Background()
+	eng, err := engine.New(
+		engine.WithPublisherKind(engine.HttpPublisher),
+		engine.WithHttpPublisherListenAddr("127.0.0.1:0"),
+		engine.WithPubsubAnnounce(false))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+	cs := supplier.NewCarSupplier(eng, dssync.MutexWrap(datastore.NewMapDatastore()))
+	subject, err := New(newTestHost(t), eng, cs, WithListenAddr("127.0.0.1:0"))
+	require.NoError(t, err)
+	go func() { _ = subject.Start() }()
+	t.Cleanup(func() { _ = subject.Shutdown(ctx) })
+
+	params := strings.NewReplacer("{subsystem}", "adminserver", "{id}", "unknown", "{cid}", "bafkqaaa")
+	for path, ops := range doc.Paths {
+		for method := range ops {
+			if method == "parameters" {
+				continue
+			}
+			method = strings.ToUpper(method)
+			var body io.Reader
+			if method != http.MethodGet {
+				body = bytes.NewBufferString("{}")
+			}
+			req, err := http.NewRequest(method, "http://"+subject.l.Addr().String()+params.Replace(path), body)
+			require.NoError(t, err)
+			if body != nil {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err, "%s %s", method, path)
+			respBody, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			require.NoError(t, err)
+			require.NotEqual(t, http.StatusMethodNotAllowed, resp.StatusCode, "%s %s", method, path)
+			require.NotEqual(t, "404 page not found\n", string(respBody), "%s %s is not routed", method, path)
+		}
+	}
+}
@@ -1,6 +1,16 @@
 package adminserver
 
-import "time"
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipni/index-provider/engine"
+	"github.com/ipni/index-provider/supplier"
+	"golang.org/x/time/rate"
+)
 
 type (
 	// Option captures a configurable parameter in admin HTTP server.
@@ -10,6 +20,27 @@ type (
 		listenAddr   string
 		readTimeout  time.Duration
 		writeTimeout time.Duration
+		bearerToken  string
+		tlsConfig    *tls.Config
+
+		multihashSupplier *supplier.MultihashSupplier
+		metricsHandler    http.Handler
+		publicMetrics     bool
+		publisherHandler  http.Handler
+		defaultMetadata   MetadataFunc
+
+		datastore         datastore.Datastore
+		idempotencyKeyTTL time.Duration
+
+		rateLimits   map[string]*rate.Limiter
+		maxBodySizes map[string]int64
+
+		reload ReloadFunc
+
+		tenants *engine.Tenants
+
+		profiling   bool
+		debugBlocks bool
 	}
 )
 
@@ -18,6 +49,8 @@ func newOptions(o ...Option) (*options, error) {
 		listenAddr:   "0.0.0.0:3102",
 		readTimeout:  30 * time.Second,
 		writeTimeout: 30 * time.Second,
+
+		idempotencyKeyTTL: defaultIdempotencyKeyTTL,
 	}
 
 	for _, apply := range o {
@@ -54,3 +87,200 @@ func WithWriteTimeout(t time.Duration) Option {
 		return nil
 	}
 }
+
+// WithBearerToken sets the token that requests to all admin routes must
+// present in an "Authorization: Bearer <token>" header. Requests without the
+// token are rejected with 401 Unauthorized.
+// If unset, requests are not authenticated.
+func WithBearerToken(token string) Option {
+	return func(o *options) error {
+		o.bearerToken = token
+		return nil
+	}
+}
+
+// WithTLSConfig sets the TLS configuration with which the admin HTTP server
+// serves HTTPS. Setting ClientAuth to tls.RequireAndVerifyClientCert in the
+// config verifies client certificates against ClientCAs, so that only clients
+// presenting a trusted certificate can access the admin routes.
+// If unset, plain HTTP is served.
+func WithTLSConfig(c *tls.Config) Option {
+	return func(o *options) error {
+		o.tlsConfig = c
+		return nil
+	}
+}
+
+// WithMultihashSupplier sets the supplier via which multihashes given in
+// requests to /admin/advertise are advertised. The supplier must be registered
+// as a multihash lister of the engine, e.g. via supplier.ChainListers.
+// If unset, only the multihashes already listed by the engine for a context ID
+// can be advertised.
+func WithMultihashSupplier(ms *supplier.MultihashSupplier) Option {
+	return func(o *options) error {
+		o.multihashSupplier = ms
+		return nil
+	}
+}
+
+// WithDefaultMetadata sets the function that returns the retrieval metadata
+// of the advertisements requested via /admin/advertise and /admin/import/car
+// without metadata.
+// If unset, requests to /admin/advertise must specify metadata, and CARs
+// imported without metadata are advertised as retrievable over graphsync from
+// the CAR data transfer, as described by cardatatransfer.TransportFromContextID.
+func WithDefaultMetadata(f MetadataFunc) Option {
+	return func(o *options) error {
+		o.defaultMetadata = f
+		return nil
+	}
+}
+
+// WithMetricsHandler sets the handler that serves metrics at /metrics.
+// If unset, metrics are not served by the admin HTTP server.
+func WithMetricsHandler(h http.Handler) Option {
+	return func(o *options) error {
+		o.metricsHandler = h
+		return nil
+	}
+}
+
+// WithPublicMetrics sets whether the metrics set by WithMetricsHandler are
+// served without the bearer token and client certificate required by the
+// admin API, e.g. so that they can be scraped by Prometheus.
+// If unset, metrics require the same authentication as the admin API.
+func WithPublicMetrics(public bool) Option {
+	return func(o *options) error {
+		o.publicMetrics = public
+		return nil
+	}
+}
+
+// WithPublisherHandler sets the handler of the HTTP publisher of the provider,
+// such that advertisements are served under /ipni/v1/ad/ on the same listener
+// as the admin API, as returned by engine.Engine.GetPublisherHttpFunc when the
+// engine is configured with engine.WithHttpPublisherWithoutServer.
+// Advertisements are public, and so are served without the bearer token and
+// client certificate required by the admin API.
+// If unset, advertisements are not served by the admin HTTP server.
+func WithPublisherHandler(h http.Handler) Option {
+	return func(o *options) error {
+		o.publisherHandler = h
+		return nil
+	}
+}
+
+// WithDatastore sets the datastore in which the status of asynchronous jobs,
+// and the responses to requests with an Idempotency-Key header, are persisted,
+// such that they are retained across restarts. Jobs that were running when the
+// server stopped are reported as failed once it restarts.
+// If unset, they are only kept in memory.
+func WithDatastore(ds datastore.Datastore) Option {
+	return func(o *options) error {
+		o.datastore = ds
+		return nil
+	}
+}
+
+// WithIdempotencyKeyTTL sets how long the response to a request to publish or
+// remove content with an Idempotency-Key header is kept, during which retries
+// of the request are responded to with that response instead of publishing
+// again. If unset, the default of 24 hours is used.
+func WithIdempotencyKeyTTL(ttl time.Duration) Option {
+	return func(o *options) error {
+		if ttl <= 0 {
+			return errors.New("idempotency key TTL must be greater than zero")
+		}
+		o.idempotencyKeyTTL = ttl
+		return nil
+	}
+}
+
+// WithReloadFunc sets the function called to reload the configuration of the
+// provider upon requests to /admin/reload.
+// If unset, such requests are rejected with 501 Not Implemented.
+func WithReloadFunc(reload ReloadFunc) Option {
+	return func(o *options) error {
+		o.reload = reload
+		return nil
+	}
+}
+
+// WithRateLimit limits the rate of requests to the given route to r requests
+// per second, allowing bursts of up to burst requests. A route ending in "/"
+// limits all paths under it that are not limited by a more specific route,
+// e.g. "/admin/" limits all admin routes. Requests exceeding the limit are
+// rejected with 429 Too Many Requests.
+// If unset, requests are not rate limited. See Server.SetRateLimits to change
+// rate limits while the server is running.
+func WithRateLimit(route string, r float64, burst int) Option {
+	return func(o *options) error {
+		limiter, err := newRateLimiter(RateLimit{Rate: r, Burst: burst})
+		if err != nil {
+			return err
+		}
+		if o.rateLimits == nil {
+			o.rateLimits = make(map[string]*rate.Limiter)
+		}
+		o.rateLimits[route] = limiter
+		return nil
+	}
+}
+
+// WithMaxRequestBodySize sets the maximum size in bytes of the bodies of
+// requests to the given route. Routes are matched as in WithRateLimit, e.g.
+// "/" sets the maximum size for all routes. Requests with larger bodies are
+// rejected with 413 Request Entity Too Large. A size of zero lifts the limit
+// for the route.
+// If unset, the size of request bodies is not limited.
+func WithMaxRequestBodySize(route string, size int64) Option {
+	return func(o *options) error {
+		if size < 0 {
+			return errors.New("maximum request body size must not be negative")
+		}
+		if o.maxBodySizes == nil {
+			o.maxBodySizes = make(map[string]int64)
+		}
+		o.maxBodySizes[route] = size
+		return nil
+	}
+}
+
+// WithTenants sets the tenants managed via /admin/tenants, on whose behalf
+// content is advertised via /admin/tenants/{id}/advertise. Multihashes given in
+// such requests are stored in the datastore of the tenant by a
+// supplier.MultihashSupplier, which must be registered as the multihash lister
+// of the engine of each tenant, e.g. by the setup function of the tenants.
+// If unset, requests to /admin/tenants are rejected with 501 Not Implemented.
+func WithTenants(t *engine.Tenants) Option {
+	return func(o *options) error {
+		o.tenants = t
+		return nil
+	}
+}
+
+// WithProfiling sets whether the runtime profiling data of the provider is
+// served in the format expected by the pprof visualization tool under
+// /debug/pprof/, e.g. "go tool pprof http://localhost:3102/debug/pprof/heap".
+// Profiles are subject to the bearer token, if set, and the duration of CPU
+// profiles and traces must be shorter than the write timeout.
+// If unset, profiling data is not served.
+func WithProfiling(enabled bool) Option {
+	return func(o *options) error {
+		o.profiling = enabled
+		return nil
+	}
+}
+
+// WithDebugBlocks sets whether the advertisements and entries chunks stored
+// by the provider are served as dag-json by CID under /debug/block/, e.g.
+// "curl http://localhost:3102/debug/block/<cid>", to help debug what is
+// published. Entries are only served if they are stored or cached, and are
+// never regenerated. Blocks are subject to the bearer token, if set.
+// If unset, blocks are not served.
+func WithDebugBlocks(enabled bool) Option {
+	return func(o *options) error {
+		o.debugBlocks = enabled
+		return nil
+	}
+}
@@ -0,0 +1,120 @@
+package adminserver
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/libp2p/go-libp2p/core/network"
+)
+
+// defaultProtectTag is the tag with which connections are protected when no
+// tag is specified.
+const defaultProtectTag = "admin"
+
+func (s *Server) listPeersHandler(w http.ResponseWriter, r *http.Request) {
+	if !methodOK(w, r, http.MethodGet) {
+		return
+	}
+
+	cm := s.h.ConnManager()
+	peers := s.h.Network().Peers()
+	resp := &ListPeersRes{Peers: make([]PeerInfo, 0, len(peers))}
+	for _, p := range peers {
+		conns := s.h.Network().ConnsToPeer(p)
+		if len(conns) == 0 {
+			continue
+		}
+		info := PeerInfo{
+			ID:        p,
+			Addrs:     make([]string, 0, len(conns)),
+			Direction: directionString(conns[0].Stat().Direction),
+			Protected: cm.IsProtected(p, ""),
+			Protocols: []string{},
+		}
+		for _, c := range conns {
+			info.Addrs = append(info.Addrs, c.RemoteMultiaddr().String())
+		}
+		if protos, err := s.h.Peerstore().GetProtocols(p); err == nil {
+			for _, proto := range protos {
+				info.Protocols = append(info.Protocols, string(proto))
+			}
+			sort.Strings(info.Protocols)
+		}
+		resp.Peers = append(resp.Peers, info)
+	}
+	sort.Slice(resp.Peers, func(i, j int) bool { return resp.Peers[i].ID < resp.Peers[j].ID })
+	respond(w, http.StatusOK, resp)
+}
+
+func (s *Server) disconnectHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.decodePeerReq(w, r)
+	if !ok {
+		return
+	}
+	if err := s.h.Network().ClosePeer(req.PeerID); err != nil {
+		msg := fmt.Sprintf("failed to disconnect from peer: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	log.Infow("Disconnected from peer", "peer", req.PeerID)
+	respond(w, http.StatusOK, &PeerRes{})
+}
+
+func (s *Server) protectHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.decodePeerReq(w, r)
+	if !ok {
+		return
+	}
+	s.h.ConnManager().Protect(req.PeerID, req.Tag)
+	log.Infow("Protected peer", "peer", req.PeerID, "tag", req.Tag)
+	respond(w, http.StatusOK, &PeerRes{})
+}
+
+func (s *Server) unprotectHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.decodePeerReq(w, r)
+	if !ok {
+		return
+	}
+	s.h.ConnManager().Unprotect(req.PeerID, req.Tag)
+	log.Infow("Unprotected peer", "peer", req.PeerID, "tag", req.Tag)
+	respond(w, http.StatusOK, &PeerRes{})
+}
+
+// decodePeerReq decodes a PeerReq from the given POST request, defaulting its
+// tag, and responds with an error if the request is not valid.
+func (s *Server) decodePeerReq(w http.ResponseWriter, r *http.Request) (*PeerReq, bool) {
+	if !methodOK(w, r, http.MethodPost) {
+		return nil, false
+	}
+	if !matchContentTypeJson(w, r) {
+		return nil, false
+	}
+	var req PeerReq
+	if _, err := req.ReadFrom(r.Body); err != nil {
+		msg := fmt.Sprintf("failed to unmarshal request: %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusBadRequest)
+		return nil, false
+	}
+	if err := req.PeerID.Validate(); err != nil {
+		http.Error(w, "valid peer ID must be specified", http.StatusBadRequest)
+		return nil, false
+	}
+	if req.Tag == "" {
+		req.Tag = defaultProtectTag
+	}
+	return &req, true
+}
+
+func directionString(d network.Direction) string {
+	switch d {
+	case network.DirInbound:
+		return "inbound"
+	case network.DirOutbound:
+		return "outbound"
+	default:
+		return "unknown"
+	}
+}
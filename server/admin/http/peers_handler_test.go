@@ -0,0 +1,65 @@
+package adminserver
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestHost(t *testing.T) host.Host {
+	h, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+func Test_peersHandlers(t *testing.T) {
+	h := newTestHost(t)
+	indexer := newTestHost(t)
+	require.NoError(t, h.Connect(context.Background(), peer.AddrInfo{ID: indexer.ID(), Addrs: indexer.Addrs()}))
+	subject := &Server{h: h}
+
+	do := func(handler http.HandlerFunc, method string, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, "/", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	listPeers := func() []PeerInfo {
+		rr := do(subject.listPeersHandler, http.MethodGet, "")
+		require.Equal(t, http.StatusOK, rr.Code)
+		var resp ListPeersRes
+		_, err := resp.ReadFrom(rr.Body)
+		require.NoError(t, err)
+		return resp.Peers
+	}
+	peerReq := `{"peer_id":"` + indexer.ID().String() + `"}`
+
+	peers := listPeers()
+	require.Len(t, peers, 1)
+	require.Equal(t, indexer.ID(), peers[0].ID)
+	require.Equal(t, "outbound", peers[0].Direction)
+	require.Len(t, peers[0].Addrs, 1)
+	require.False(t, peers[0].Protected)
+
+	require.Equal(t, http.StatusOK, do(subject.protectHandler, http.MethodPost, peerReq).Code)
+	require.True(t, listPeers()[0].Protected)
+	require.True(t, h.ConnManager().IsProtected(indexer.ID(), defaultProtectTag))
+	require.Equal(t, http.StatusOK, do(subject.unprotectHandler, http.MethodPost, peerReq).Code)
+	require.False(t, listPeers()[0].Protected)
+
+	require.Equal(t, http.StatusOK, do(subject.disconnectHandler, http.MethodPost, peerReq).Code)
+	require.Empty(t, listPeers())
+
+	require.Equal(t, http.StatusBadRequest, do(subject.protectHandler, http.MethodPost, `{}`).Code)
+	require.Equal(t, http.StatusBadRequest, do(subject.protectHandler, http.MethodPost, `{"peer_id":"fish"}`).Code)
+	require.Equal(t, http.StatusMethodNotAllowed, do(subject.disconnectHandler, http.MethodGet, "").Code)
+}
@@ -0,0 +1,99 @@
+package adminserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ipni/index-provider/engine"
+)
+
+const queuePath = "/admin/queue"
+
+// listQueueHandler responds with the publish in progress, if any, followed by
+// the waiting publishes in the order in which they will be published.
+func (s *Server) listQueueHandler(w http.ResponseWriter, r *http.Request) {
+	if !methodOK(w, r, http.MethodGet) {
+		return
+	}
+	pubs := s.e.PublishQueue()
+	resp := &ListPublishQueueRes{Queue: make([]QueuedPublishInfo, 0, len(pubs))}
+	for _, pub := range pubs {
+		resp.Queue = append(resp.Queue, queuedPublishInfo(pub))
+	}
+	respond(w, http.StatusOK, resp)
+}
+
+// reorderQueueHandler sets the priority of the waiting publish whose ID is
+// given by the request path, e.g. "/admin/queue/42", and optionally moves it
+// to the front of the queue.
+func (s *Server) reorderQueueHandler(w http.ResponseWriter, r *http.Request) {
+	if !methodOK(w, r, http.MethodPost) {
+		return
+	}
+	id, err := strconv.ParseUint(strings.TrimPrefix(r.URL.Path, queuePath+"/"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid publish ID", http.StatusBadRequest)
+		return
+	}
+	if !matchContentTypeJson(w, r) {
+		return
+	}
+	var req ReorderPublishReq
+	if _, err := req.ReadFrom(r.Body); err != nil {
+		msg := fmt.Sprintf("failed to unmarshal request. %v", err)
+		log.Errorw(msg, "err", err)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if req.Priority == "" && !req.Front {
+		http.Error(w, "priority or front must be specified", http.StatusBadRequest)
+		return
+	}
+
+	var pub engine.QueuedPublish
+	if req.Priority != "" {
+		priority, err := engine.ParsePublishPriority(req.Priority)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if pub, err = s.e.SetPublishPriority(id, priority); err != nil {
+			respondReorderError(w, id, err)
+			return
+		}
+	}
+	if req.Front {
+		if pub, err = s.e.MovePublishToFront(id); err != nil {
+			respondReorderError(w, id, err)
+			return
+		}
+	}
+	info := queuedPublishInfo(pub)
+	respond(w, http.StatusOK, &info)
+}
+
+func respondReorderError(w http.ResponseWriter, id uint64, err error) {
+	if errors.Is(err, engine.ErrPublishNotQueued) {
+		http.Error(w, fmt.Sprintf("publish %d not waiting in queue", id), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+func queuedPublishInfo(pub engine.QueuedPublish) QueuedPublishInfo {
+	info := QueuedPublishInfo{
+		ID:         pub.ID,
+		Kind:       pub.Kind,
+		Priority:   pub.Priority.String(),
+		ContextID:  pub.ContextID,
+		Queued:     pub.Queued.UTC(),
+		Publishing: pub.Publishing,
+	}
+	if pub.Provider != "" {
+		info.Provider = pub.Provider.String()
+	}
+	return info
+}
@@ -0,0 +1,113 @@
+package adminserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_queueHandlers(t *testing.T) {
+	ctx := context.Background()
+	eng, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+	subject := &Server{e: eng}
+
+	listing := make(chan struct{})
+	release := make(chan struct{})
+	eng.RegisterMultihashLister(func(_ context.Context, _ peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		if string(contextID) == "blocker" {
+			close(listing)
+			<-release
+		}
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+	errs := make(chan error, 3)
+	for _, contextID := range []string{"blocker", "fish", "lobster"} {
+		go func(contextID string) {
+			_, err := eng.NotifyPut(ctx, nil, []byte(contextID), metadata.Default.New(metadata.Bitswap{}))
+			errs <- err
+		}(contextID)
+		if contextID == "blocker" {
+			<-listing
+		}
+	}
+	defer func() {
+		close(release)
+		for i := 0; i < 3; i++ {
+			require.NoError(t, <-errs)
+		}
+	}()
+
+	list := func() ListPublishQueueRes {
+		req, err := http.NewRequest(http.MethodGet, queuePath, nil)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(subject.listQueueHandler).ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+		var resp ListPublishQueueRes
+		_, err = resp.ReadFrom(rr.Body)
+		require.NoError(t, err)
+		return resp
+	}
+	var resp ListPublishQueueRes
+	require.Eventually(t, func() bool {
+		resp = list()
+		return len(resp.Queue) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, "blocker", string(resp.Queue[0].ContextID))
+	require.True(t, resp.Queue[0].Publishing)
+	require.Equal(t, "put", resp.Queue[1].Kind)
+	require.Equal(t, "new-data", resp.Queue[1].Priority)
+	require.NotEmpty(t, resp.Queue[1].Provider)
+	first, second := resp.Queue[1], resp.Queue[2]
+
+	reorder := func(path string, req *ReorderPublishReq) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		_, err := req.WriteTo(&body)
+		require.NoError(t, err)
+		httpReq, err := http.NewRequest(http.MethodPost, path, &body)
+		require.NoError(t, err)
+		httpReq.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(subject.reorderQueueHandler).ServeHTTP(rr, httpReq)
+		return rr
+	}
+	rr := reorder(fmt.Sprintf("%s/%d", queuePath, second.ID), &ReorderPublishReq{Priority: "removal"})
+	require.Equal(t, http.StatusOK, rr.Code)
+	var info QueuedPublishInfo
+	_, err = info.ReadFrom(rr.Body)
+	require.NoError(t, err)
+	require.Equal(t, second.ID, info.ID)
+	require.Equal(t, "removal", info.Priority)
+	resp = list()
+	require.Equal(t, second.ID, resp.Queue[1].ID)
+	require.Equal(t, first.ID, resp.Queue[2].ID)
+
+	rr = reorder(fmt.Sprintf("%s/%d", queuePath, first.ID), &ReorderPublishReq{Front: true})
+	require.Equal(t, http.StatusOK, rr.Code)
+	resp = list()
+	require.Equal(t, first.ID, resp.Queue[1].ID)
+	require.Equal(t, "removal", resp.Queue[1].Priority)
+
+	rr = reorder(fmt.Sprintf("%s/%d", queuePath, resp.Queue[0].ID), &ReorderPublishReq{Front: true})
+	require.Equal(t, http.StatusNotFound, rr.Code)
+	rr = reorder(queuePath+"/fish", &ReorderPublishReq{Front: true})
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = reorder(fmt.Sprintf("%s/%d", queuePath, first.ID), &ReorderPublishReq{Priority: "urgent"})
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = reorder(fmt.Sprintf("%s/%d", queuePath, first.ID), &ReorderPublishReq{})
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+}
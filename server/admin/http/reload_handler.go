@@ -0,0 +1,30 @@
+package adminserver
+
+import (
+	"context"
+	"net/http"
+)
+
+// ReloadFunc reloads the configuration of the provider, applying the settings
+// that can be changed at runtime, and reports which changed settings were
+// applied and which require a restart.
+type ReloadFunc func(ctx context.Context) (*ReloadRes, error)
+
+// reloadHandler reloads the configuration of the provider via the configured
+// ReloadFunc.
+func (s *Server) reloadHandler(w http.ResponseWriter, r *http.Request) {
+	if !methodOK(w, r, http.MethodPost) {
+		return
+	}
+	if s.reload == nil {
+		http.Error(w, "reloading configuration is not supported", http.StatusNotImplemented)
+		return
+	}
+	res, err := s.reload(r.Context())
+	if err != nil {
+		log.Errorw("Failed to reload configuration", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respond(w, http.StatusOK, res)
+}
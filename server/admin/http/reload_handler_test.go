@@ -0,0 +1,41 @@
+package adminserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_reloadHandler(t *testing.T) {
+	do := func(subject *Server, method string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, "/admin/reload", nil)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		subject.reloadHandler(rr, req)
+		return rr
+	}
+
+	require.Equal(t, http.StatusNotImplemented, do(&Server{}, http.MethodPost).Code)
+
+	want := &ReloadRes{
+		Applied:         []string{"DirectAnnounce.URLs"},
+		RestartRequired: []string{"Ingest.PublisherKind"},
+	}
+	subject := &Server{reload: func(context.Context) (*ReloadRes, error) { return want, nil }}
+	require.Equal(t, http.StatusMethodNotAllowed, do(subject, http.MethodGet).Code)
+	rr := do(subject, http.MethodPost)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var got ReloadRes
+	_, err := got.ReadFrom(rr.Body)
+	require.NoError(t, err)
+	require.Equal(t, want, &got)
+
+	subject.reload = func(context.Context) (*ReloadRes, error) { return nil, errors.New("bad config") }
+	rr = do(subject, http.MethodPost)
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+	require.Contains(t, rr.Body.String(), "bad config")
+}
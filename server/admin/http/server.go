@@ -2,23 +2,53 @@ package adminserver
 
 import (
 	"context"
+	"crypto/subtle"
+	"crypto/tls"
 	"mime"
 	"net"
 	"net/http"
+	"net/http/pprof"
+	"sync/atomic"
 
 	logging "github.com/ipfs/go-log/v2"
+	"github.com/ipni/go-libipni/dagsync/ipnisync"
+	"github.com/ipni/go-libipni/metadata"
 	"github.com/ipni/index-provider/engine"
+	"github.com/ipni/index-provider/metrics"
 	"github.com/ipni/index-provider/supplier"
 	"github.com/libp2p/go-libp2p/core/host"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
 )
 
 var log = logging.Logger("adminserver")
 
+// pprofPath is the path under which profiling data is served. See:
+// WithProfiling.
+const pprofPath = "/debug/pprof/"
+
+// publisherPath is the path under which advertisements are served. See:
+// WithPublisherHandler.
+const publisherPath = ipnisync.IPNIPath + "/"
+
 type Server struct {
 	server *http.Server
 	l      net.Listener
 	h      host.Host
 	e      *engine.Engine
+	jobs   *jobs
+	reload ReloadFunc
+
+	idempotency *idempotency
+
+	tenants         *engine.Tenants
+	defaultMetadata MetadataFunc
+
+	rateLimits atomic.Pointer[map[string]*rate.Limiter]
 }
 
 func New(h host.Host, e *engine.Engine, cs *supplier.CarSupplier, o ...Option) (*Server, error) {
@@ -31,26 +61,126 @@ func New(h host.Host, e *engine.Engine, cs *supplier.CarSupplier, o ...Option) (
 	if err != nil {
 		return nil, err
 	}
+	// Client certificates are only verified at the TLS handshake if every path
+	// requires them. Otherwise they are required per path.
+	tlsConfig := opts.tlsConfig
+	var requireClientCert bool
+	if tlsConfig != nil && tlsConfig.ClientAuth == tls.RequireAndVerifyClientCert &&
+		(opts.publisherHandler != nil || (opts.publicMetrics && opts.metricsHandler != nil)) {
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		requireClientCert = true
+	}
+	if tlsConfig != nil {
+		l = tls.NewListener(l, tlsConfig)
+	}
+
+	s := &Server{
+		l:      l,
+		h:      h,
+		e:      e,
+		jobs:   newJobs(opts.datastore),
+		reload: opts.reload,
+
+		idempotency: newIdempotency(opts.datastore, opts.idempotencyKeyTTL),
+
+		tenants:         opts.tenants,
+		defaultMetadata: opts.defaultMetadata,
+	}
+	if opts.rateLimits != nil {
+		s.rateLimits.Store(&opts.rateLimits)
+	}
 
 	mux := http.NewServeMux()
-	server := &http.Server{
-		Handler:      mux,
+	handler := limitRequests(&s.rateLimits, opts.maxBodySizes, mux)
+	if opts.bearerToken != "" {
+		handler = requireBearerToken(opts.bearerToken, handler)
+	}
+	if requireClientCert {
+		handler = requireVerifiedClientCert(handler)
+	}
+	handler = traceRequests(mux, handler)
+	// Health probes are served without bearer token so that they are usable by
+	// orchestrators such as Kubernetes.
+	root := http.NewServeMux()
+	root.Handle("/", handler)
+	s.server = &http.Server{
+		Handler:      root,
 		ReadTimeout:  opts.readTimeout,
 		WriteTimeout: opts.writeTimeout,
 	}
-	s := &Server{server, l, h, e}
+
+	root.HandleFunc("/healthz", s.healthzHandler)
+	root.HandleFunc("/readyz", s.readyzHandler)
+	// The head is public, and so is served without authentication, for
+	// monitoring systems to poll.
+	root.HandleFunc("/head", s.headHandler)
+	// Advertisements, and metrics if public, are served without authentication
+	// so that the ports to expose and secure can be reduced to one.
+	if opts.publisherHandler != nil {
+		root.Handle(publisherPath, opts.publisherHandler)
+	}
+	if opts.metricsHandler != nil && opts.publicMetrics {
+		root.Handle("/metrics", opts.metricsHandler)
+	}
 
 	// Set protocol handlers
+	mux.HandleFunc("/admin/openapi.json", s.openAPIHandler)
+
 	mux.HandleFunc("/admin/announce", s.announceHandler)
 	mux.HandleFunc("/admin/announcehttp", s.announceHttpHandler)
 
 	mux.HandleFunc("/admin/connect", s.connectHandler)
+	mux.HandleFunc("/admin/disconnect", s.disconnectHandler)
+	mux.HandleFunc("/admin/peers", s.listPeersHandler)
+	mux.HandleFunc("/admin/protect", s.protectHandler)
+	mux.HandleFunc("/admin/unprotect", s.unprotectHandler)
 
-	cHandler := &carHandler{cs}
-	mux.HandleFunc("/admin/import/car", cHandler.handleImport)
-	mux.HandleFunc("/admin/remove/car", cHandler.handleRemove)
+	mux.HandleFunc("/admin/stats", s.statsHandler)
+	mux.HandleFunc("/admin/gossip", s.gossipHandler)
+	mux.HandleFunc(queuePath, s.listQueueHandler)
+	mux.HandleFunc(queuePath+"/", s.reorderQueueHandler)
+	mux.HandleFunc("/admin/reload", s.reloadHandler)
+
+	if opts.metricsHandler != nil && !opts.publicMetrics {
+		mux.Handle("/metrics", opts.metricsHandler)
+	}
+	if opts.profiling {
+		mux.HandleFunc(pprofPath, pprof.Index)
+		mux.HandleFunc(pprofPath+"cmdline", pprof.Cmdline)
+		mux.HandleFunc(pprofPath+"profile", pprof.Profile)
+		mux.HandleFunc(pprofPath+"symbol", pprof.Symbol)
+		mux.HandleFunc(pprofPath+"trace", pprof.Trace)
+	}
+	if opts.debugBlocks {
+		mux.HandleFunc(debugBlockPath, s.debugBlockHandler)
+	}
+
+	mux.HandleFunc(logPath, s.listLogLevelsHandler)
+	mux.HandleFunc(logPath+"/", s.logLevelHandler)
+
+	mux.HandleFunc("/admin/cache/purge", s.cachePurgeHandler)
+	mux.HandleFunc("/admin/gc", s.gcHandler)
+	mux.HandleFunc(jobsPath, s.getJobHandler)
+
+	mux.HandleFunc(adsPath, s.listAdsHandler)
+	mux.HandleFunc(adsPath+"/", s.getAdHandler)
+	mux.HandleFunc(auditPath, s.listAuditHandler)
+
+	cHandler := &carHandler{e: e, cs: cs, defaultMetadata: opts.defaultMetadata}
+	mux.HandleFunc("/admin/import/car", s.idempotentHandler(s.asyncHandler(jobKindImportCar, cHandler.handleImport)))
+	mux.HandleFunc("/admin/remove/car", s.idempotentHandler(cHandler.handleRemove))
 	mux.HandleFunc("/admin/list/car", cHandler.handleList)
 
+	ctxHandler := &contextHandler{e, cs, opts.multihashSupplier, opts.defaultMetadata}
+	mux.HandleFunc("/admin/advertise", s.idempotentHandler(ctxHandler.handleAdvertise))
+	mux.HandleFunc("/admin/remove", s.idempotentHandler(ctxHandler.handleRemoveOne))
+	mux.HandleFunc("/admin/remove/context", s.idempotentHandler(s.asyncHandler(jobKindRemoveContexts, ctxHandler.handleRemove)))
+	mux.HandleFunc("/admin/list/contexts", ctxHandler.handleList)
+
+	mux.HandleFunc(tenantsPath, s.idempotentHandler(s.tenantsHandler))
+	mux.HandleFunc(tenantsPath+"/", s.idempotentHandler(s.tenantHandler))
+
 	return s, nil
 }
 
@@ -61,7 +191,107 @@ func (s *Server) Start() error {
 
 func (s *Server) Shutdown(ctx context.Context) error {
 	log.Info("admin http server shutdown")
-	return s.server.Shutdown(ctx)
+	err := s.server.Shutdown(ctx)
+	s.jobs.close()
+	return err
+}
+
+// requireBearerToken wraps the given handler such that only requests with the
+// given bearer token are served.
+func requireBearerToken(token string, next http.Handler) http.Handler {
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireVerifiedClientCert wraps the given handler such that only requests
+// over TLS connections with a verified client certificate are served.
+func requireVerifiedClientCert(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			http.Error(w, "client certificate required", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// traceRequests wraps the given handler such that each request is traced as a
+// span named after the pattern of mux that the request matches. The trace of the
+// client is continued if the request carries a trace context, so that the
+// publishing of advertisements can be traced from the client onwards.
+func traceRequests(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		_, pattern := mux.Handler(r)
+		if pattern == "" {
+			pattern = "unmatched"
+		}
+		ctx, span := metrics.Tracer.Start(ctx, r.Method+" "+pattern,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.method", r.Method),
+				attribute.String("http.target", r.URL.RequestURI()),
+			))
+		defer span.End()
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r.WithContext(ctx))
+		span.SetAttributes(attribute.Int("http.status_code", sw.status))
+		if sw.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(sw.status))
+		}
+	})
+}
+
+// statusWriter records the status code of the response it writes.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// MetadataFunc returns the retrieval metadata of the advertisement with the
+// given context ID.
+type MetadataFunc func(contextID []byte) (metadata.Metadata, error)
+
+// decodeMetadata decodes the metadata given in a request to advertise the
+// given context ID, using the metadata protocols registered with the engine
+// e. If none is given and defaultMetadata is not nil, the metadata it returns
+// is used instead.
+func decodeMetadata(e *engine.Engine, data, contextID []byte, defaultMetadata MetadataFunc) (metadata.Metadata, error) {
+	if len(data) == 0 && defaultMetadata != nil {
+		return defaultMetadata(contextID)
+	}
+	md := metadataContext(e).New()
+	if err := md.UnmarshalBinary(data); err != nil {
+		return metadata.Metadata{}, err
+	}
+	return md, nil
+}
+
+// metadataContext returns the metadata context of the engine e, or the
+// default one if there is no engine.
+func metadataContext(e *engine.Engine) metadata.MetadataContext {
+	if e == nil {
+		return metadata.Default
+	}
+	return e.MetadataContext()
 }
 
 func methodOK(w http.ResponseWriter, r *http.Request, method string) bool {
@@ -0,0 +1,234 @@
+package adminserver
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestServer_BearerToken(t *testing.T) {
+	subject := startServer(t, WithBearerToken("fish"))
+	url := "http://" + subject.l.Addr().String() + "/admin/unknown"
+
+	get := func(auth string) int {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	require.Equal(t, http.StatusUnauthorized, get(""))
+	require.Equal(t, http.StatusUnauthorized, get("Bearer lobster"))
+	require.Equal(t, http.StatusUnauthorized, get("fish"))
+	// Authenticated requests reach the router.
+	require.Equal(t, http.StatusNotFound, get("Bearer fish"))
+}
+
+func TestServer_Profiling(t *testing.T) {
+	get := func(subject *Server, path string) int {
+		resp, err := http.Get("http://" + subject.l.Addr().String() + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	require.Equal(t, http.StatusNotFound, get(startServer(t), pprofPath))
+
+	subject := startServer(t, WithProfiling(true))
+	require.Equal(t, http.StatusOK, get(subject, pprofPath))
+	require.Equal(t, http.StatusOK, get(subject, pprofPath+"heap"))
+	require.Equal(t, http.StatusOK, get(subject, pprofPath+"cmdline"))
+}
+
+func TestServer_ClientCertificate(t *testing.T) {
+	ca, caKey := newTestCert(t, nil, nil, true)
+	serverCert := newTestTLSCert(t, ca, caKey)
+	clientCert := newTestTLSCert(t, ca, caKey)
+	otherCA, otherCAKey := newTestCert(t, nil, nil, true)
+	otherClientCert := newTestTLSCert(t, otherCA, otherCAKey)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	subject := startServer(t, WithTLSConfig(&tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}))
+	url := "https://" + subject.l.Addr().String() + "/admin/unknown"
+
+	get := func(certs ...tls.Certificate) (int, error) {
+		cl := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:      pool,
+			Certificates: certs,
+		}}}
+		resp, err := cl.Get(url)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	_, err := get()
+	require.Error(t, err)
+	_, err = get(otherClientCert)
+	require.Error(t, err)
+	code, err := get(clientCert)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, code)
+}
+
+func TestServer_CoHostsPublisher(t *testing.T) {
+	ctx := context.Background()
+	eng, err := engine.New(
+		engine.WithPublisherKind(engine.HttpPublisher),
+		engine.WithHttpPublisherWithoutServer(),
+		engine.WithHttpPublisherListenAddr("127.0.0.1:0"))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+	eng.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+	adCid, err := eng.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	pubHandler, err := eng.GetPublisherHttpFunc()
+	require.NoError(t, err)
+
+	metricsHandler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {})
+	subject := startServer(t, WithBearerToken("fish"), WithPublisherHandler(pubHandler),
+		WithMetricsHandler(metricsHandler), WithPublicMetrics(true))
+	get := func(path string) int {
+		resp, err := http.Get("http://" + subject.l.Addr().String() + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	require.Equal(t, http.StatusOK, get(publisherPath+"head"))
+	require.Equal(t, http.StatusOK, get(publisherPath+adCid.String()))
+	require.Equal(t, http.StatusOK, get("/metrics"))
+	require.Equal(t, http.StatusUnauthorized, get("/admin/unknown"))
+}
+
+func TestServer_CoHostsPublisherWithClientCertificate(t *testing.T) {
+	ca, caKey := newTestCert(t, nil, nil, true)
+	serverCert := newTestTLSCert(t, ca, caKey)
+	clientCert := newTestTLSCert(t, ca, caKey)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	pubHandler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {})
+	subject := startServer(t, WithPublisherHandler(pubHandler), WithTLSConfig(&tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}))
+	get := func(path string, certs ...tls.Certificate) int {
+		cl := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:      pool,
+			Certificates: certs,
+		}}}
+		resp, err := cl.Get("https://" + subject.l.Addr().String() + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	require.Equal(t, http.StatusOK, get(publisherPath+"head"))
+	require.Equal(t, http.StatusUnauthorized, get("/admin/unknown"))
+	require.Equal(t, http.StatusNotFound, get("/admin/unknown", clientCert))
+}
+
+func TestServer_TracesRequests(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+	subject := startServer(t)
+
+	ctx, client := tp.Tracer("test").Start(context.Background(), "client")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+subject.l.Addr().String()+"/admin/openapi.json", nil)
+	require.NoError(t, err)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	client.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	server := spans[0]
+	require.Equal(t, "GET /admin/openapi.json", server.Name())
+	require.Equal(t, client.SpanContext().TraceID(), server.SpanContext().TraceID())
+	require.Equal(t, client.SpanContext().SpanID(), server.Parent().SpanID())
+	require.Contains(t, server.Attributes(), attribute.Int("http.status_code", resp.StatusCode))
+}
+
+func startServer(t *testing.T, o ...Option) *Server {
+	subject, err := New(nil, nil, nil, append([]Option{WithListenAddr("127.0.0.1:0")}, o...)...)
+	require.NoError(t, err)
+	go func() { _ = subject.Start() }()
+	t.Cleanup(func() { _ = subject.server.Close() })
+	return subject
+}
+
+// newTestCert creates a certificate for 127.0.0.1 signed by the given parent,
+// or a self-signed certificate if parent is nil.
+func newTestCert(t *testing.T, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, isCA bool) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func newTestTLSCert(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey) tls.Certificate {
+	cert, key := newTestCert(t, ca, caKey, false)
+	return tls.Certificate{Certificate: [][]byte{cert.Raw}, PrivateKey: key, Leaf: cert}
+}
@@ -0,0 +1,118 @@
+package adminserver
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func (s *Server) statsHandler(w http.ResponseWriter, r *http.Request) {
+	if !methodOK(w, r, http.MethodGet) {
+		return
+	}
+
+	stats, err := s.e.Stats(r.Context())
+	if err != nil {
+		err = fmt.Errorf("failed to get engine stats: %w", err)
+		log.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := &StatsRes{
+		ChainLength:       stats.ChainLength,
+		Contexts:          stats.Contexts,
+		Multihashes:       stats.Multihashes,
+		CachedChunks:      stats.CachedChunks,
+		CacheCapacity:     stats.CacheCapacity,
+		AnnounceSuccesses: stats.AnnounceSuccesses,
+		AnnounceFailures:  stats.AnnounceFailures,
+	}
+	if stats.Head != cid.Undef {
+		resp.Head = &stats.Head
+	}
+	if !stats.LastPublished.IsZero() {
+		lastPublished := stats.LastPublished.UTC()
+		resp.LastPublished = &lastPublished
+	}
+	if len(stats.AnnounceTargets) != 0 {
+		resp.AnnounceTargets = make(map[string]AnnounceTargetStats, len(stats.AnnounceTargets))
+		for target, ts := range stats.AnnounceTargets {
+			lastAttempt := ts.LastAttempt.UTC()
+			resp.AnnounceTargets[target] = AnnounceTargetStats{
+				Attempts:      ts.Attempts,
+				Successes:     ts.Successes,
+				Failures:      ts.Failures,
+				MeanLatencyMs: ts.MeanLatency.Milliseconds(),
+				LastLatencyMs: ts.LastLatency.Milliseconds(),
+				LastAttempt:   &lastAttempt,
+				LastError:     ts.LastError,
+			}
+		}
+	}
+	respond(w, http.StatusOK, resp)
+}
+
+// headHandler responds with the head of the advertisement chain, so that
+// monitoring systems can track its movement cheaply. It is served without
+// authentication, since the signed head is public.
+func (s *Server) headHandler(w http.ResponseWriter, r *http.Request) {
+	if !methodOK(w, r, http.MethodGet) {
+		return
+	}
+
+	head, err := s.e.GetHeadInfo(r.Context())
+	if err != nil {
+		err = fmt.Errorf("failed to get head: %w", err)
+		log.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if head == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	resp := &HeadRes{
+		Head:   head.Head,
+		Topic:  head.Topic,
+		PubKey: head.PubKey,
+		Sig:    head.Sig,
+		Height: head.Height,
+	}
+	if !head.Published.IsZero() {
+		published := head.Published.UTC()
+		resp.Published = &published
+	}
+	respond(w, http.StatusOK, resp)
+}
+
+func (s *Server) gossipHandler(w http.ResponseWriter, r *http.Request) {
+	if !methodOK(w, r, http.MethodGet) {
+		return
+	}
+
+	diag := s.e.GossipDiagnostics()
+	resp := &GossipRes{
+		Topic:               diag.Topic,
+		Enabled:             diag.Enabled,
+		TopicPeers:          diag.TopicPeers,
+		MeshPeers:           diag.MeshPeers,
+		MeshKnown:           diag.MeshKnown,
+		Indexers:            make([]GossipIndexer, 0, len(diag.Indexers)),
+		LastPropagatedPeers: diag.LastPropagatedPeers,
+	}
+	if resp.TopicPeers == nil {
+		resp.TopicPeers = []peer.ID{}
+	}
+	for _, indexer := range diag.Indexers {
+		resp.Indexers = append(resp.Indexers, GossipIndexer(indexer))
+	}
+	if !diag.LastPropagated.IsZero() {
+		lastPropagated := diag.LastPropagated.UTC()
+		resp.LastPropagated = &lastPropagated
+		resp.LastPropagatedAd = &diag.LastPropagatedAd
+	}
+	respond(w, http.StatusOK, resp)
+}
@@ -0,0 +1,144 @@
+package adminserver
+
+import (
+	"context"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	headschema "github.com/ipni/go-libipni/dagsync/ipnisync/head"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_statsHandler(t *testing.T) {
+	ctx := context.Background()
+	eng, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+	eng.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+	subject := &Server{e: eng}
+	doStats := func() (*httptest.ResponseRecorder, *StatsRes) {
+		req, err := http.NewRequest(http.MethodGet, "/admin/stats", nil)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(subject.statsHandler).ServeHTTP(rr, req)
+		var resp StatsRes
+		if rr.Code == http.StatusOK {
+			_, err = resp.ReadFrom(rr.Body)
+			require.NoError(t, err)
+		}
+		return rr, &resp
+	}
+
+	rr, resp := doStats()
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Nil(t, resp.Head)
+	require.Nil(t, resp.LastPublished)
+	require.Zero(t, resp.ChainLength)
+
+	adCid, err := eng.NotifyPut(ctx, nil, []byte("fish"), metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	rr, resp = doStats()
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, adCid, *resp.Head)
+	require.Equal(t, 1, resp.ChainLength)
+	require.Equal(t, 1, resp.Contexts)
+	require.Equal(t, int64(3), resp.Multihashes)
+	require.NotNil(t, resp.LastPublished)
+
+	req, err := http.NewRequest(http.MethodPost, "/admin/stats", nil)
+	require.NoError(t, err)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(subject.statsHandler).ServeHTTP(rr, req)
+	require.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+}
+
+func Test_headHandler(t *testing.T) {
+	ctx := context.Background()
+	key, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	eng, err := engine.New(engine.WithPublisherKind(engine.NoPublisher), engine.WithPrivateKey(key))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+	eng.RegisterMultihashLister(func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(test.RandomMultihashes(3)), nil
+	})
+	subject := &Server{e: eng}
+	doHead := func() *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, "/head", nil)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(subject.headHandler).ServeHTTP(rr, req)
+		return rr
+	}
+
+	require.Equal(t, http.StatusNoContent, doHead().Code)
+
+	for _, contextID := range []string{"fish", "lobster"} {
+		_, err = eng.NotifyPut(ctx, nil, []byte(contextID), metadata.Default.New(metadata.Bitswap{}))
+		require.NoError(t, err)
+	}
+	latest, _, err := eng.GetLatestAdv(ctx)
+	require.NoError(t, err)
+	rr := doHead()
+	require.Equal(t, http.StatusOK, rr.Code)
+	var resp HeadRes
+	_, err = resp.ReadFrom(rr.Body)
+	require.NoError(t, err)
+	require.Equal(t, latest, resp.Head)
+	require.Equal(t, uint64(2), resp.Height)
+	require.NotNil(t, resp.Published)
+
+	// The head is signed as by IPNI HTTP publishers.
+	signed := headschema.SignedHead{Head: cidlink.Link{Cid: resp.Head}, Topic: &resp.Topic, Pubkey: resp.PubKey, Sig: resp.Sig}
+	signer, err := signed.Validate()
+	require.NoError(t, err)
+	wantSigner, err := peer.IDFromPrivateKey(key)
+	require.NoError(t, err)
+	require.Equal(t, wantSigner, signer)
+}
+
+func Test_gossipHandler(t *testing.T) {
+	ctx := context.Background()
+	indexer, _, _ := test.RandomIdentity()
+	eng, err := engine.New(
+		engine.WithPublisherKind(engine.NoPublisher),
+		engine.WithTopicName("test-topic"),
+		engine.WithIndexerPeers(indexer))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+	subject := &Server{e: eng}
+
+	req, err := http.NewRequest(http.MethodGet, "/admin/gossip", nil)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(subject.gossipHandler).ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var resp GossipRes
+	_, err = resp.ReadFrom(rr.Body)
+	require.NoError(t, err)
+	require.Equal(t, "test-topic", resp.Topic)
+	require.False(t, resp.Enabled)
+	require.Empty(t, resp.TopicPeers)
+	require.Equal(t, []GossipIndexer{{ID: indexer}}, resp.Indexers)
+	require.Nil(t, resp.LastPropagated)
+
+	req, err = http.NewRequest(http.MethodPost, "/admin/gossip", nil)
+	require.NoError(t, err)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(subject.gossipHandler).ServeHTTP(rr, req)
+	require.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+}
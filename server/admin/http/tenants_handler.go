@@ -0,0 +1,193 @@
+package adminserver
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/index-provider/engine"
+	"github.com/ipni/index-provider/supplier"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+const tenantsPath = "/admin/tenants"
+
+// tenantsHandler lists the tenants, or creates a tenant.
+func (s *Server) tenantsHandler(w http.ResponseWriter, r *http.Request) {
+	if s.tenants == nil {
+		http.Error(w, "multi-tenant hosting is not enabled", http.StatusNotImplemented)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		tenants := s.tenants.List()
+		resp := &ListTenantsRes{Tenants: make([]TenantInfo, 0, len(tenants))}
+		for _, tenant := range tenants {
+			info, err := newTenantInfo(r.Context(), tenant)
+			if err != nil {
+				err = fmt.Errorf("failed to get latest advertisement of tenant %s: %w", tenant.ID, err)
+				log.Error(err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			resp.Tenants = append(resp.Tenants, info)
+		}
+		respond(w, http.StatusOK, resp)
+	case http.MethodPost:
+		if !matchContentTypeJson(w, r) {
+			return
+		}
+		var req CreateTenantReq
+		if _, err := req.ReadFrom(r.Body); err != nil {
+			msg := fmt.Sprintf("failed to unmarshal request. %v", err)
+			log.Errorw(msg, "err", err)
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		if len(req.RetrievalAddrs) == 0 {
+			http.Error(w, "at least one retrieval address must be specified", http.StatusBadRequest)
+			return
+		}
+		addrs := make([]multiaddr.Multiaddr, 0, len(req.RetrievalAddrs))
+		for _, a := range req.RetrievalAddrs {
+			addr, err := multiaddr.NewMultiaddr(a)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("bad retrieval address %q: %v", a, err), http.StatusBadRequest)
+				return
+			}
+			addrs = append(addrs, addr)
+		}
+		var key crypto.PrivKey
+		var err error
+		if len(req.PrivateKey) != 0 {
+			key, err = crypto.UnmarshalPrivateKey(req.PrivateKey)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("bad private key: %v", err), http.StatusBadRequest)
+				return
+			}
+		} else {
+			key, _, err = crypto.GenerateEd25519Key(rand.Reader)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		tenant, err := s.tenants.Create(r.Context(), key, addrs...)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, engine.ErrTenantExists) {
+				status = http.StatusConflict
+			}
+			err = fmt.Errorf("failed to create tenant: %w", err)
+			log.Error(err)
+			http.Error(w, err.Error(), status)
+			return
+		}
+		info, err := newTenantInfo(r.Context(), tenant)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respond(w, http.StatusOK, &info)
+	default:
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+		http.Error(w, "", http.StatusMethodNotAllowed)
+	}
+}
+
+// tenantHandler gets or deletes the tenant whose ID follows tenantsPath in the
+// request path, e.g. "/admin/tenants/12D3KooW...", or advertises or removes
+// content on its behalf, e.g. "/admin/tenants/12D3KooW.../advertise".
+func (s *Server) tenantHandler(w http.ResponseWriter, r *http.Request) {
+	if s.tenants == nil {
+		http.Error(w, "multi-tenant hosting is not enabled", http.StatusNotImplemented)
+		return
+	}
+	idStr, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, tenantsPath+"/"), "/")
+	id, err := peer.Decode(idStr)
+	if err != nil {
+		http.Error(w, "tenant ID is not a valid peer ID", http.StatusBadRequest)
+		return
+	}
+	tenant := s.tenants.Get(id)
+
+	switch action {
+	case "":
+		switch r.Method {
+		case http.MethodGet:
+			if tenant == nil {
+				http.Error(w, fmt.Sprintf("tenant %s not found", id), http.StatusNotFound)
+				return
+			}
+			info, err := newTenantInfo(r.Context(), tenant)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			respond(w, http.StatusOK, &info)
+		case http.MethodDelete:
+			if err = s.tenants.Delete(r.Context(), id); err != nil {
+				status := http.StatusInternalServerError
+				if errors.Is(err, engine.ErrUnknownTenant) {
+					status = http.StatusNotFound
+				}
+				http.Error(w, err.Error(), status)
+				return
+			}
+			log.Infow("Deleted tenant", "id", id)
+			respond(w, http.StatusOK, &DeleteTenantRes{})
+		default:
+			w.Header().Set("Allow", http.MethodGet+", "+http.MethodDelete)
+			http.Error(w, "", http.StatusMethodNotAllowed)
+		}
+	case "advertise", "remove":
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "", http.StatusMethodNotAllowed)
+			return
+		}
+		if tenant == nil {
+			http.Error(w, fmt.Sprintf("tenant %s not found", id), http.StatusNotFound)
+			return
+		}
+		h := &contextHandler{
+			e:               tenant.Engine,
+			ms:              supplier.NewMultihashSupplier(tenant.Engine, tenant.Datastore),
+			defaultMetadata: s.defaultMetadata,
+		}
+		if action == "advertise" {
+			h.handleAdvertise(w, r)
+		} else {
+			h.handleRemoveOne(w, r)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTenantInfo(ctx context.Context, tenant *engine.Tenant) (TenantInfo, error) {
+	head, _, err := tenant.Engine.GetLatestAdv(ctx)
+	if err != nil {
+		return TenantInfo{}, err
+	}
+	info := TenantInfo{
+		ID:             tenant.ID,
+		RetrievalAddrs: make([]string, 0, len(tenant.RetrievalAddrs)),
+		PublisherPath:  engine.TenantsHandlerPath + tenant.ID.String(),
+	}
+	for _, addr := range tenant.RetrievalAddrs {
+		info.RetrievalAddrs = append(info.RetrievalAddrs, addr.String())
+	}
+	if head != cid.Undef {
+		info.Head = &head
+	}
+	return info, nil
+}
@@ -0,0 +1,94 @@
+package adminserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	"github.com/ipni/index-provider/engine"
+	"github.com/ipni/index-provider/supplier"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_tenantsHandlers(t *testing.T) {
+	ctx := context.Background()
+	setup := func(_ context.Context, tenant *engine.Tenant) error {
+		ms := supplier.NewMultihashSupplier(tenant.Engine, tenant.Datastore)
+		tenant.Engine.RegisterMultihashLister(ms.ListMultihashes)
+		return nil
+	}
+	tenants, err := engine.NewTenants(dssync.MutexWrap(datastore.NewMapDatastore()), "http://fish.example", setup)
+	require.NoError(t, err)
+	require.NoError(t, tenants.Start(ctx))
+	t.Cleanup(func() { _ = tenants.Shutdown() })
+
+	mux := http.NewServeMux()
+	subject := &Server{tenants: tenants}
+	mux.HandleFunc(tenantsPath, subject.tenantsHandler)
+	mux.HandleFunc(tenantsPath+"/", subject.tenantHandler)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(http.MethodPost, tenantsPath, `{"retrieval_addrs":["/ip4/127.0.0.1/tcp/9999"]}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var created TenantInfo
+	_, err = created.ReadFrom(rr.Body)
+	require.NoError(t, err)
+	require.Nil(t, created.Head)
+	require.Equal(t, "/tenants/"+created.ID.String(), created.PublisherPath)
+	tenantPath := tenantsPath + "/" + created.ID.String()
+
+	require.Equal(t, http.StatusBadRequest, do(http.MethodPost, tenantsPath, `{}`).Code)
+	require.Equal(t, http.StatusBadRequest, do(http.MethodPost, tenantsPath, `{"retrieval_addrs":["fish"]}`).Code)
+
+	bitswap := metadata.Default.New(metadata.Bitswap{})
+	md, err := bitswap.MarshalBinary()
+	require.NoError(t, err)
+	advReq, err := json.Marshal(&AdvertiseReq{ContextID: []byte("fish"), Metadata: md, Multihashes: test.RandomMultihashes(3)})
+	require.NoError(t, err)
+	rr = do(http.MethodPost, tenantPath+"/advertise", string(advReq))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var advRes AdvertiseRes
+	_, err = advRes.ReadFrom(rr.Body)
+	require.NoError(t, err)
+	ad, err := tenants.Get(created.ID).Engine.GetAdv(ctx, advRes.AdvId)
+	require.NoError(t, err)
+	require.Equal(t, created.ID.String(), ad.Provider)
+
+	rr = do(http.MethodGet, tenantsPath, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var list ListTenantsRes
+	_, err = list.ReadFrom(rr.Body)
+	require.NoError(t, err)
+	require.Len(t, list.Tenants, 1)
+	require.Equal(t, advRes.AdvId, *list.Tenants[0].Head)
+	require.Equal(t, []string{"/ip4/127.0.0.1/tcp/9999"}, list.Tenants[0].RetrievalAddrs)
+
+	rr = do(http.MethodPost, tenantPath+"/remove", `{"context_id":"ZmlzaA=="}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.Equal(t, http.StatusNotFound, do(http.MethodPost, tenantPath+"/remove", `{"context_id":"ZmlzaA=="}`).Code)
+
+	require.Equal(t, http.StatusOK, do(http.MethodDelete, tenantPath, "").Code)
+	require.Equal(t, http.StatusNotFound, do(http.MethodGet, tenantPath, "").Code)
+	require.Equal(t, http.StatusNotFound, do(http.MethodDelete, tenantPath, "").Code)
+	require.Equal(t, http.StatusNotFound, do(http.MethodPost, tenantPath+"/advertise", string(advReq)).Code)
+	require.Equal(t, http.StatusBadRequest, do(http.MethodGet, tenantsPath+"/fish", "").Code)
+	require.Equal(t, http.StatusMethodNotAllowed, do(http.MethodPut, tenantsPath, "").Code)
+
+	disabled := &Server{}
+	rr = httptest.NewRecorder()
+	disabled.tenantsHandler(rr, httptest.NewRequest(http.MethodGet, tenantsPath, nil))
+	require.Equal(t, http.StatusNotImplemented, rr.Code)
+}
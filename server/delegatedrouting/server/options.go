@@ -11,6 +11,8 @@ type (
 		readTimeout      time.Duration
 		writeTimeout     time.Duration
 		adFlushFrequency time.Duration
+		chunkMaxBytes    int
+		compaction       time.Duration
 	}
 )
 
@@ -62,3 +64,21 @@ func WithAdFlushFrequency(t time.Duration) Option {
 		return nil
 	}
 }
+
+// WithChunkMaxBytes sets the maximum total size in bytes of the multihashes advertised in one advertisement.
+// If unset, advertisements are limited by the number of CIDs only.
+func WithChunkMaxBytes(n int) Option {
+	return func(o *options) error {
+		o.chunkMaxBytes = n
+		return nil
+	}
+}
+
+// WithCompactionInterval sets the frequency at which advertisements of fewer CIDs than the chunk size are merged.
+// If unset, advertisements are not merged.
+func WithCompactionInterval(t time.Duration) Option {
+	return func(o *options) error {
+		o.compaction = t
+		return nil
+	}
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net"
 	"net/http"
@@ -16,6 +17,11 @@ import (
 
 var log = logging.Logger("adminserver")
 
+const (
+	providersPath = "/routing/v1/providers"
+	flushPath     = "/flush"
+)
+
 type Server struct {
 	server      *http.Server
 	netListener net.Listener
@@ -51,15 +57,15 @@ func New(cidTtl time.Duration,
 		ds,
 		nil,
 		drouting.WithPageSize(pageSize),
-		drouting.WithAdFlushFrequency(opts.adFlushFrequency))
+		drouting.WithAdFlushFrequency(opts.adFlushFrequency),
+		drouting.WithChunkMaxBytes(opts.chunkMaxBytes),
+		drouting.WithCompactionInterval(opts.compaction))
 	if err != nil {
 		return nil, fmt.Errorf("delegated routing initialisation failed: %s", err)
 	}
 
-	handler := server.Handler(rListener)
-
 	s := &http.Server{
-		Handler:      handler,
+		Handler:      newHandler(rListener),
 		ReadTimeout:  opts.readTimeout,
 		WriteTimeout: opts.writeTimeout,
 	}
@@ -71,6 +77,45 @@ func New(cidTtl time.Duration,
 	}, nil
 }
 
+// newHandler returns the handler of the /routing/v1 API, which accepts provide
+// requests with or without a trailing slash in their path. POST /flush publishes
+// the CIDs provided so far that have not been advertised yet.
+func newHandler(listener *drouting.Listener) http.Handler {
+	handler := server.Handler(listener)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == flushPath:
+			handleFlush(w, r, listener)
+			return
+		case r.Method == http.MethodPut && r.URL.Path == providersPath:
+			r.URL.Path += "/"
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func handleFlush(w http.ResponseWriter, r *http.Request, listener *drouting.Listener) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "", http.StatusMethodNotAllowed)
+		return
+	}
+	n, err := listener.Flush(r.Context())
+	if err != nil {
+		log.Errorw("Failed to flush current chunk", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(&flushRes{Cids: n})
+}
+
+// flushRes is the response to a flush request.
+type flushRes struct {
+	// Cids is the number of CIDs advertised by the flush.
+	Cids int
+}
+
 func (s *Server) Start() error {
 	log.Infow("Delegated Routing http server listening", "addr", s.netListener.Addr())
 	return s.server.Serve(s.netListener)
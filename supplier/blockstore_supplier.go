@@ -0,0 +1,328 @@
+package supplier
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	_ "github.com/ipld/go-codec-dagpb"
+	"github.com/ipld/go-ipld-prime"
+	_ "github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/ipld/go-ipld-prime/codec/dagjson"
+	_ "github.com/ipld/go-ipld-prime/codec/raw"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/ipld/go-ipld-prime/traversal"
+	"github.com/ipld/go-ipld-prime/traversal/selector"
+	selectorparse "github.com/ipld/go-ipld-prime/traversal/selector/parse"
+	"github.com/ipni/go-libipni/metadata"
+	provider "github.com/ipni/index-provider"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multihash"
+)
+
+const blockstoreSupplierDatastorePrefix = "blockstore_supplier://"
+
+// BlockstoreSupplier supplies the multihashes of the blocks of DAGs in a
+// blockstore, such as that of a Kubo or boxo node, to an implementation of
+// provider.Interface. It allows users to advertise the blocks reachable from a
+// root CID via a selector under a context ID by calling BlockstoreSupplier.Put,
+// to re-advertise them once the DAG changes by calling BlockstoreSupplier.Rescan,
+// and to advertise their removal by calling BlockstoreSupplier.Remove.
+//
+// The multihashes are not stored; the DAG is traversed whenever they are
+// listed. Therefore, the blocks of an advertised DAG must remain in the
+// blockstore until it is rescanned or removed.
+//
+// Like MultihashSupplier, BlockstoreSupplier does not register itself as the
+// provider.MultihashLister of the engine, so that it can be combined with other
+// suppliers via ChainListers.
+type BlockstoreSupplier struct {
+	eng  provider.Interface
+	ds   datastore.Datastore
+	lsys ipld.LinkSystem
+}
+
+// blockstoreDag is the record of a DAG advertised by BlockstoreSupplier.
+type blockstoreDag struct {
+	Provider *peer.AddrInfo `json:",omitempty"`
+	Root     cid.Cid
+	// Selector is the dag-json encoded selector of the blocks of the DAG.
+	Selector []byte
+	Metadata []byte
+	// Digest is the SHA-256 hash of the advertised multihashes, by which
+	// changes to the DAG are detected.
+	Digest []byte
+}
+
+// NewBlockstoreSupplier instantiates a new BlockstoreSupplier that supplies
+// the blocks in bs, and persists the records of advertised DAGs in the given
+// datastore.
+func NewBlockstoreSupplier(eng provider.Interface, ds datastore.Datastore, bs blockstore.Blockstore) *BlockstoreSupplier {
+	lsys := cidlink.DefaultLinkSystem()
+	lsys.StorageReadOpener = func(lctx ipld.LinkContext, lnk ipld.Link) (io.Reader, error) {
+		blk, err := bs.Get(lctx.Ctx, lnk.(cidlink.Link).Cid)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(blk.RawData()), nil
+	}
+	return &BlockstoreSupplier{
+		eng:  eng,
+		ds:   ds,
+		lsys: lsys,
+	}
+}
+
+// Put advertises the multihashes of the blocks that the given selector
+// reaches from the root under the context ID, with the given metadata. If sel
+// is nil, all blocks reachable from the root are advertised. If p is nil, the
+// blocks are advertised by the default provider of the engine. All blocks
+// must be present in the blockstore.
+//
+// A context ID that is already advertised cannot be put again with a
+// different root or selector; use Rescan or Remove instead.
+func (bs *BlockstoreSupplier) Put(ctx context.Context, p *peer.AddrInfo, contextID []byte, root cid.Cid, sel ipld.Node, md metadata.Metadata) (cid.Cid, error) {
+	if sel == nil {
+		sel = selectorparse.CommonSelector_ExploreAllRecursively
+	}
+	var selJson bytes.Buffer
+	if err := dagjson.Encode(sel, &selJson); err != nil {
+		return cid.Undef, fmt.Errorf("cannot encode selector: %w", err)
+	}
+	mdBytes, err := md.MarshalBinary()
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	key := toBlockstoreDagKey(contextID)
+	existing, err := bs.getDag(ctx, contextID)
+	switch {
+	case err == nil:
+		if !existing.Root.Equals(root) || !bytes.Equal(existing.Selector, selJson.Bytes()) {
+			return cid.Undef, errors.New("context ID is already advertised with a different root or selector; rescan or remove it first")
+		}
+	case !errors.Is(err, ErrNotFound):
+		return cid.Undef, err
+	}
+
+	digest, err := bs.digest(ctx, root, selJson.Bytes())
+	if err != nil {
+		return cid.Undef, err
+	}
+	if existing != nil && !bytes.Equal(existing.Digest, digest) {
+		return cid.Undef, errors.New("DAG of context ID has changed since it was advertised; rescan it instead")
+	}
+	dag := &blockstoreDag{
+		Provider: p,
+		Root:     root,
+		Selector: selJson.Bytes(),
+		Metadata: mdBytes,
+		Digest:   digest,
+	}
+	if existing != nil {
+		// The metadata of a context ID that is already advertised may be
+		// updated.
+		advID, err := bs.eng.NotifyPut(ctx, p, contextID, md)
+		if err != nil {
+			return cid.Undef, err
+		}
+		return advID, bs.putDag(ctx, contextID, dag)
+	}
+
+	if err = bs.putDag(ctx, contextID, dag); err != nil {
+		return cid.Undef, err
+	}
+	advID, err := bs.eng.NotifyPut(ctx, p, contextID, md)
+	if err != nil && !errors.Is(err, provider.ErrAlreadyAdvertised) {
+		// Do not keep DAGs that were never advertised.
+		if delErr := bs.ds.Delete(ctx, key); delErr != nil {
+			log.Errorw("Failed to delete DAG of unadvertised context", "err", delErr)
+		}
+	}
+	return advID, err
+}
+
+// Rescan traverses the DAG advertised under the context ID again, and if its
+// blocks have changed, advertises the removal of the context ID followed by
+// its re-advertisement with the current blocks. If root is defined, the DAG is
+// traversed from it instead of the previous root, e.g. when the root of
+// mutable content has changed. cid.Undef is returned if the blocks have not
+// changed. ErrNotFound is returned if the context ID is not advertised.
+func (bs *BlockstoreSupplier) Rescan(ctx context.Context, contextID []byte, root cid.Cid) (cid.Cid, error) {
+	dag, err := bs.getDag(ctx, contextID)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if root == cid.Undef {
+		root = dag.Root
+	}
+	digest, err := bs.digest(ctx, root, dag.Selector)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if bytes.Equal(digest, dag.Digest) {
+		if !root.Equals(dag.Root) {
+			dag.Root = root
+			if err = bs.putDag(ctx, contextID, dag); err != nil {
+				return cid.Undef, err
+			}
+		}
+		return cid.Undef, nil
+	}
+
+	md := metadata.Default.New()
+	if err = md.UnmarshalBinary(dag.Metadata); err != nil {
+		return cid.Undef, fmt.Errorf("cannot decode metadata: %w", err)
+	}
+	var providerID peer.ID
+	if dag.Provider != nil {
+		providerID = dag.Provider.ID
+	}
+	// The entries of an advertised context ID cannot change, so the context
+	// ID is removed before the changed DAG is advertised.
+	if _, err = bs.eng.NotifyRemove(ctx, providerID, contextID); err != nil && !errors.Is(err, provider.ErrContextIDNotFound) {
+		return cid.Undef, err
+	}
+	dag.Root = root
+	dag.Digest = digest
+	if err = bs.putDag(ctx, contextID, dag); err != nil {
+		return cid.Undef, err
+	}
+	log.Infow("DAG of context changed; re-advertising", "contextID", base64.StdEncoding.EncodeToString(contextID), "root", root)
+	return bs.eng.NotifyPut(ctx, dag.Provider, contextID, md)
+}
+
+// Remove deletes the record of the DAG advertised under the context ID, and
+// advertises its removal by the given provider. If p is empty, the removal is
+// advertised by the default provider of the engine. ErrNotFound is returned if
+// no DAG is advertised under the context ID.
+func (bs *BlockstoreSupplier) Remove(ctx context.Context, p peer.ID, contextID []byte) (cid.Cid, error) {
+	key := toBlockstoreDagKey(contextID)
+	has, err := bs.ds.Has(ctx, key)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if !has {
+		return cid.Undef, ErrNotFound
+	}
+	advID, err := bs.eng.NotifyRemove(ctx, p, contextID)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if err = bs.ds.Delete(ctx, key); err != nil {
+		return cid.Undef, err
+	}
+	return advID, nil
+}
+
+// ListMultihashes supplies an iterator over the multihashes of the blocks of
+// the DAG advertised under the given context ID, in the order in which they
+// are traversed. ErrNotFound is returned if no DAG is advertised under the
+// context ID.
+func (bs *BlockstoreSupplier) ListMultihashes(ctx context.Context, _ peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+	dag, err := bs.getDag(ctx, contextID)
+	if err != nil {
+		return nil, err
+	}
+	mhs, err := bs.traverse(ctx, dag.Root, dag.Selector)
+	if err != nil {
+		return nil, err
+	}
+	return provider.SliceMultihashIterator(mhs), nil
+}
+
+// traverse returns the multihashes of the blocks that the dag-json encoded
+// selector reaches from the root, without duplicates.
+func (bs *BlockstoreSupplier) traverse(ctx context.Context, root cid.Cid, selJson []byte) ([]multihash.Multihash, error) {
+	selNode, err := ipld.Decode(selJson, dagjson.Decode)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode selector: %w", err)
+	}
+	sel, err := selector.CompileSelector(selNode)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector: %w", err)
+	}
+
+	var mhs []multihash.Multihash
+	seen := make(map[string]struct{})
+	lsys := bs.lsys
+	lsys.StorageReadOpener = func(lctx ipld.LinkContext, lnk ipld.Link) (io.Reader, error) {
+		r, err := bs.lsys.StorageReadOpener(lctx, lnk)
+		if err != nil {
+			return nil, err
+		}
+		mh := lnk.(cidlink.Link).Cid.Hash()
+		if _, ok := seen[string(mh)]; !ok {
+			seen[string(mh)] = struct{}{}
+			mhs = append(mhs, mh)
+		}
+		return r, nil
+	}
+
+	rootLink := cidlink.Link{Cid: root}
+	n, err := lsys.Load(ipld.LinkContext{Ctx: ctx}, rootLink, basicnode.Prototype.Any)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load root %s: %w", root, err)
+	}
+	progress := traversal.Progress{
+		Cfg: &traversal.Config{
+			Ctx:                            ctx,
+			LinkSystem:                     lsys,
+			LinkTargetNodePrototypeChooser: basicnode.Chooser,
+		},
+	}
+	if err = progress.WalkMatching(n, sel, func(traversal.Progress, ipld.Node) error { return nil }); err != nil {
+		return nil, fmt.Errorf("cannot traverse DAG of %s: %w", root, err)
+	}
+	return mhs, nil
+}
+
+// digest returns the SHA-256 hash of the multihashes of the blocks that the
+// dag-json encoded selector reaches from the root.
+func (bs *BlockstoreSupplier) digest(ctx context.Context, root cid.Cid, selJson []byte) ([]byte, error) {
+	mhs, err := bs.traverse(ctx, root, selJson)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	for _, mh := range mhs {
+		h.Write(mh)
+	}
+	return h.Sum(nil), nil
+}
+
+func (bs *BlockstoreSupplier) getDag(ctx context.Context, contextID []byte) (*blockstoreDag, error) {
+	b, err := bs.ds.Get(ctx, toBlockstoreDagKey(contextID))
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			err = ErrNotFound
+		}
+		return nil, err
+	}
+	var dag blockstoreDag
+	if err = json.Unmarshal(b, &dag); err != nil {
+		return nil, err
+	}
+	return &dag, nil
+}
+
+func (bs *BlockstoreSupplier) putDag(ctx context.Context, contextID []byte, dag *blockstoreDag) error {
+	b, err := json.Marshal(dag)
+	if err != nil {
+		return err
+	}
+	return bs.ds.Put(ctx, toBlockstoreDagKey(contextID), b)
+}
+
+func toBlockstoreDagKey(contextID []byte) datastore.Key {
+	return datastore.NewKey(blockstoreSupplierDatastorePrefix + "dags/" + provider.ContextID(contextID).String())
+}
@@ -0,0 +1,172 @@
+package supplier
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/ipfs/boxo/blockstore"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipld/go-ipld-prime"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/fluent/qp"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/ipld/go-ipld-prime/traversal/selector/builder"
+	"github.com/ipni/go-libipni/metadata"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/multiformats/go-multicodec"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockstoreSupplier(t *testing.T) {
+	ctx := context.Background()
+	eng, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(ds)
+	subject := NewBlockstoreSupplier(eng, ds, bstore)
+	eng.RegisterMultihashLister(subject.ListMultihashes)
+
+	leaves := []cid.Cid{putRawBlock(t, bstore, "fish"), putRawBlock(t, bstore, "lobster")}
+	root := putDagNode(t, bstore, leaves...)
+	contextID := []byte("fish")
+	md := metadata.Default.New(metadata.Bitswap{})
+
+	adCid, err := subject.Put(ctx, nil, contextID, root, nil, md)
+	require.NoError(t, err)
+	ad, err := eng.GetAdv(ctx, adCid)
+	require.NoError(t, err)
+	require.Equal(t, contextID, ad.ContextID)
+
+	it, err := subject.ListMultihashes(ctx, "", contextID)
+	require.NoError(t, err)
+	require.Equal(t, []multihash.Multihash{root.Hash(), leaves[0].Hash(), leaves[1].Hash()}, drain(it))
+
+	// Same DAG and metadata.
+	_, err = subject.Put(ctx, nil, contextID, root, nil, md)
+	require.ErrorIs(t, err, provider.ErrAlreadyAdvertised)
+	// Different root.
+	_, err = subject.Put(ctx, nil, contextID, leaves[0], nil, md)
+	require.ErrorContains(t, err, "different root or selector")
+	// Updated metadata.
+	_, err = subject.Put(ctx, nil, contextID, root, nil, metadata.Default.New(metadata.IpfsGatewayHttp{}))
+	require.NoError(t, err)
+
+	// Unchanged DAG is not re-advertised.
+	adCid, err = subject.Rescan(ctx, contextID, cid.Undef)
+	require.NoError(t, err)
+	require.Equal(t, cid.Undef, adCid)
+
+	// Changed root is re-advertised with the latest metadata.
+	leaves = append(leaves, putRawBlock(t, bstore, "crab"))
+	newRoot := putDagNode(t, bstore, leaves...)
+	adCid, err = subject.Rescan(ctx, contextID, newRoot)
+	require.NoError(t, err)
+	ad, err = eng.GetAdv(ctx, adCid)
+	require.NoError(t, err)
+	require.False(t, ad.IsRm)
+	gotMd := metadata.Default.New()
+	require.NoError(t, gotMd.UnmarshalBinary(ad.Metadata))
+	require.Equal(t, []multicodec.Code{multicodec.TransportIpfsGatewayHttp}, gotMd.Protocols())
+	prevAd, err := eng.GetAdv(ctx, ad.PreviousCid())
+	require.NoError(t, err)
+	require.True(t, prevAd.IsRm)
+	it, err = subject.ListMultihashes(ctx, "", contextID)
+	require.NoError(t, err)
+	require.Len(t, drain(it), 4)
+
+	adCid, err = subject.Remove(ctx, "", contextID)
+	require.NoError(t, err)
+	ad, err = eng.GetAdv(ctx, adCid)
+	require.NoError(t, err)
+	require.True(t, ad.IsRm)
+	_, err = subject.ListMultihashes(ctx, "", contextID)
+	require.ErrorIs(t, err, ErrNotFound)
+	_, err = subject.Rescan(ctx, contextID, cid.Undef)
+	require.ErrorIs(t, err, ErrNotFound)
+	_, err = subject.Remove(ctx, "", contextID)
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestBlockstoreSupplier_Selector(t *testing.T) {
+	ctx := context.Background()
+	eng, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(ds)
+	subject := NewBlockstoreSupplier(eng, ds, bstore)
+	eng.RegisterMultihashLister(subject.ListMultihashes)
+
+	leaves := []cid.Cid{putRawBlock(t, bstore, "fish"), putRawBlock(t, bstore, "lobster")}
+	root := putDagNode(t, bstore, leaves...)
+	md := metadata.Default.New(metadata.Bitswap{})
+
+	// Select only the first link of the root.
+	ssb := builder.NewSelectorSpecBuilder(basicnode.Prototype.Any)
+	sel := ssb.ExploreFields(func(efsb builder.ExploreFieldsSpecBuilder) {
+		efsb.Insert("links", ssb.ExploreIndex(0, ssb.Matcher()))
+	}).Node()
+	_, err = subject.Put(ctx, nil, []byte("fish"), root, sel, md)
+	require.NoError(t, err)
+	it, err := subject.ListMultihashes(ctx, "", []byte("fish"))
+	require.NoError(t, err)
+	require.Equal(t, []multihash.Multihash{root.Hash(), leaves[0].Hash()}, drain(it))
+
+	// All blocks must be present.
+	missing, err := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.SHA2_256, MhLength: -1}.Sum([]byte("crab"))
+	require.NoError(t, err)
+	_, err = subject.Put(ctx, nil, []byte("crab"), putDagNode(t, bstore, missing), nil, md)
+	require.Error(t, err)
+	_, err = subject.ListMultihashes(ctx, "", []byte("crab"))
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func putRawBlock(t *testing.T, bstore blockstore.Blockstore, data string) cid.Cid {
+	c, err := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.SHA2_256, MhLength: -1}.Sum([]byte(data))
+	require.NoError(t, err)
+	blk, err := blocks.NewBlockWithCid([]byte(data), c)
+	require.NoError(t, err)
+	require.NoError(t, bstore.Put(context.Background(), blk))
+	return c
+}
+
+// putDagNode stores a dag-cbor node that links to the given CIDs.
+func putDagNode(t *testing.T, bstore blockstore.Blockstore, links ...cid.Cid) cid.Cid {
+	n, err := qp.BuildMap(basicnode.Prototype.Any, 1, func(ma datamodel.MapAssembler) {
+		qp.MapEntry(ma, "links", qp.List(int64(len(links)), func(la datamodel.ListAssembler) {
+			for _, l := range links {
+				qp.ListEntry(la, qp.Link(cidlink.Link{Cid: l}))
+			}
+		}))
+	})
+	require.NoError(t, err)
+
+	lsys := cidlink.DefaultLinkSystem()
+	lsys.StorageWriteOpener = func(ipld.LinkContext) (io.Writer, ipld.BlockWriteCommitter, error) {
+		var buf bytes.Buffer
+		return &buf, func(lnk ipld.Link) error {
+			blk, err := blocks.NewBlockWithCid(buf.Bytes(), lnk.(cidlink.Link).Cid)
+			if err != nil {
+				return err
+			}
+			return bstore.Put(context.Background(), blk)
+		}, nil
+	}
+	lp := cidlink.LinkPrototype{Prefix: cid.Prefix{Version: 1, Codec: cid.DagCBOR, MhType: multihash.SHA2_256, MhLength: -1}}
+	lnk, err := lsys.Store(ipld.LinkContext{}, lp, n)
+	require.NoError(t, err)
+	return lnk.(cidlink.Link).Cid
+}
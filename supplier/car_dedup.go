@@ -0,0 +1,200 @@
+package supplier
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipni/go-libipni/metadata"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	carDigestDatastoreKeyPrefix   = carSupplierDatastorePrefix + "car_digest/"
+	digestCarIdDatastoreKeyPrefix = carSupplierDatastorePrefix + "digest_car_id/"
+)
+
+// entriesReuser is implemented by engines that can advertise the entries
+// already advertised under another context ID, such as engine.Engine.
+type entriesReuser interface {
+	GetContextInfo(ctx context.Context, providerID peer.ID, contextID []byte) (*engine.ContextInfo, error)
+	NotifyPutWithEntries(ctx context.Context, provider *peer.AddrInfo, contextID []byte, md metadata.Metadata, entries cid.Cid) (cid.Cid, error)
+}
+
+// carDigest is the content digest of the CAR put under a context ID, along with
+// the path, size and modification time of the CAR when it was digested.
+type carDigest struct {
+	Path    string `json:"p"`
+	Size    int64  `json:"s"`
+	ModTime int64  `json:"m"`
+	Digest  []byte `json:"d"`
+}
+
+// carDedup is the outcome of looking up a CAR by its content digest before it
+// is put.
+type carDedup struct {
+	digest *carDigest
+	// advertised is whether the context ID is already advertised.
+	advertised bool
+	// identical is whether the CAR is identical to the CAR advertised under
+	// the context ID.
+	identical bool
+	// entries are the entries of an identical CAR advertised under another
+	// context ID, if any.
+	entries cid.Cid
+}
+
+// SetDeduplicate sets whether CARs are deduplicated by the SHA-256 digest of
+// their content. If enabled, putting a CAR identical to the one already put
+// under the same context ID does not index the CAR, and putting a CAR
+// identical to one put under another context ID reuses the entries already
+// advertised for it instead of listing its multihashes again. Local CARs are
+// read in full once to be digested, unless their path, size and modification
+// time are unchanged since they were last put under the same context ID.
+//
+// Deduplication requires the engine to support reusing entries, such as
+// engine.Engine does. It must be enabled before the supplier is used.
+func (cs *CarSupplier) SetDeduplicate(dedup bool) {
+	cs.dedup = dedup
+}
+
+// dedupCar looks up the local CAR at the given path by its content digest.
+func (cs *CarSupplier) dedupCar(ctx context.Context, r entriesReuser, contextID []byte, path string) (*carDedup, error) {
+	var d carDedup
+	_, err := r.GetContextInfo(ctx, "", contextID)
+	if err == nil {
+		d.advertised = true
+	} else if !errors.Is(err, provider.ErrContextIDNotFound) {
+		return nil, err
+	}
+
+	prev, err := cs.getCarDigest(ctx, contextID)
+	if err != nil {
+		return nil, err
+	}
+	if d.digest, err = digestCar(path, prev); err != nil {
+		return nil, err
+	}
+	if d.advertised {
+		d.identical = prev != nil && bytes.Equal(prev.Digest, d.digest.Digest)
+		return &d, nil
+	}
+
+	srcID, err := cs.ds.Get(ctx, toDigestCarIdKey(d.digest.Digest))
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return &d, nil
+		}
+		return nil, err
+	}
+	info, err := r.GetContextInfo(ctx, "", srcID)
+	if err != nil {
+		if errors.Is(err, provider.ErrContextIDNotFound) {
+			return &d, nil
+		}
+		return nil, err
+	}
+	d.entries = info.Entries
+	return &d, nil
+}
+
+// putCarDigest records the digest of the CAR put under the given context ID,
+// if the entries advertised under the context ID are known to be those of the
+// CAR. The CAR is recorded as the one to reuse the entries of if its entries
+// were generated by listing its multihashes.
+func (cs *CarSupplier) putCarDigest(ctx context.Context, contextID []byte, d *carDedup) error {
+	if d.advertised && !d.identical {
+		return nil
+	}
+	data, err := json.Marshal(d.digest)
+	if err != nil {
+		return err
+	}
+	if err = cs.ds.Put(ctx, toCarDigestKey(contextID), data); err != nil {
+		return err
+	}
+	if d.advertised || d.entries != cid.Undef {
+		return nil
+	}
+	return cs.ds.Put(ctx, toDigestCarIdKey(d.digest.Digest), contextID)
+}
+
+// deleteCarDigest deletes the digest of the CAR put under the given context ID.
+func (cs *CarSupplier) deleteCarDigest(ctx context.Context, contextID []byte) error {
+	d, err := cs.getCarDigest(ctx, contextID)
+	if err != nil || d == nil {
+		return err
+	}
+	digestKey := toDigestCarIdKey(d.Digest)
+	srcID, err := cs.ds.Get(ctx, digestKey)
+	if err == nil && bytes.Equal(srcID, contextID) {
+		err = cs.ds.Delete(ctx, digestKey)
+	}
+	if err != nil && !errors.Is(err, datastore.ErrNotFound) {
+		return err
+	}
+	return cs.ds.Delete(ctx, toCarDigestKey(contextID))
+}
+
+// getCarDigest returns the digest of the CAR put under the given context ID, or
+// nil if there is none.
+func (cs *CarSupplier) getCarDigest(ctx context.Context, contextID []byte) (*carDigest, error) {
+	data, err := cs.ds.Get(ctx, toCarDigestKey(contextID))
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var d carDigest
+	if err = json.Unmarshal(data, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// digestCar returns the digest of the CAR at the given path. The previous
+// digest is returned as is if the CAR is unchanged since, judging by its path,
+// size and modification time.
+func digestCar(path string, prev *carDigest) (*carDigest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	d := &carDigest{
+		Path:    path,
+		Size:    fi.Size(),
+		ModTime: fi.ModTime().UnixNano(),
+	}
+	if prev != nil && prev.Path == d.Path && prev.Size == d.Size && prev.ModTime == d.ModTime {
+		return prev, nil
+	}
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	d.Digest = h.Sum(nil)
+	return d, nil
+}
+
+func toCarDigestKey(contextID []byte) datastore.Key {
+	return datastore.NewKey(carDigestDatastoreKeyPrefix + string(contextID))
+}
+
+func toDigestCarIdKey(digest []byte) datastore.Key {
+	return datastore.NewKey(digestCarIdDatastoreKeyPrefix + base64.RawURLEncoding.EncodeToString(digest))
+}
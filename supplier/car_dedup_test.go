@@ -0,0 +1,81 @@
+package supplier
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipni/go-libipni/metadata"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCarSupplier_Deduplicate(t *testing.T) {
+	ctx := context.Background()
+	eng, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+	subject := NewCarSupplier(eng, dssync.MutexWrap(datastore.NewMapDatastore()))
+	subject.SetDeduplicate(true)
+	var listed []string
+	eng.RegisterMultihashLister(func(ctx context.Context, p peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		listed = append(listed, string(contextID))
+		return subject.ListMultihashes(ctx, p, contextID)
+	})
+
+	data, err := os.ReadFile("../testdata/sample-v1.car")
+	require.NoError(t, err)
+	dir := t.TempDir()
+	var paths []string
+	for _, name := range []string{"fish.car", "lobster.car", "crab.car"} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, data, 0644))
+		paths = append(paths, path)
+	}
+	md := metadata.Default.New(metadata.Bitswap{})
+
+	_, err = subject.Put(ctx, []byte("fish"), paths[0], md)
+	require.NoError(t, err)
+	require.Equal(t, []string{"fish"}, listed)
+	fish, err := eng.GetContextInfo(ctx, "", []byte("fish"))
+	require.NoError(t, err)
+
+	// Putting an identical CAR under the same context ID is a no-op, even if
+	// the CAR was rewritten.
+	_, err = subject.Put(ctx, []byte("fish"), paths[0], md)
+	require.ErrorIs(t, err, provider.ErrAlreadyAdvertised)
+	require.NoError(t, os.Chtimes(paths[0], time.Now(), time.Now().Add(time.Minute)))
+	_, err = subject.Put(ctx, []byte("fish"), paths[1], md)
+	require.ErrorIs(t, err, provider.ErrAlreadyAdvertised)
+	require.Equal(t, []string{"fish"}, listed)
+
+	// Putting an identical CAR under another context ID reuses the entries.
+	adCid, err := subject.Put(ctx, []byte("lobster"), paths[1], md)
+	require.NoError(t, err)
+	require.Equal(t, []string{"fish"}, listed)
+	ad, err := eng.GetAdv(ctx, adCid)
+	require.NoError(t, err)
+	require.Equal(t, []byte("lobster"), ad.ContextID)
+	lobster, err := eng.GetContextInfo(ctx, "", []byte("lobster"))
+	require.NoError(t, err)
+	require.Equal(t, fish.Entries, lobster.Entries)
+	require.Equal(t, fish.MultihashCount, lobster.MultihashCount)
+
+	// Once the CAR the entries were generated for is removed, an identical
+	// CAR is listed again.
+	_, err = subject.Remove(ctx, []byte("fish"))
+	require.NoError(t, err)
+	_, err = subject.Put(ctx, []byte("crab"), paths[2], md)
+	require.NoError(t, err)
+	require.Equal(t, []string{"fish", "crab"}, listed)
+	crab, err := eng.GetContextInfo(ctx, "", []byte("crab"))
+	require.NoError(t, err)
+	require.Equal(t, fish.Entries, crab.Entries)
+}
@@ -0,0 +1,284 @@
+package supplier
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ipld/go-car/v2"
+	"github.com/ipld/go-car/v2/index"
+	provider "github.com/ipni/index-provider"
+	"github.com/multiformats/go-multicodec"
+	"github.com/multiformats/go-multihash"
+	"github.com/multiformats/go-varint"
+	"golang.org/x/exp/mmap"
+)
+
+// DetachedIndexExt is the extension of the detached index file of a CAR,
+// which is looked up at the path of the CAR with the extension appended.
+const DetachedIndexExt = ".idx"
+
+// maxIndexWidth is the maximum width of index entries, matching that accepted
+// by go-car.
+const maxIndexWidth = 32 << 20
+
+var _ provider.MultihashIterator = (*indexIterator)(nil)
+
+// DetachedIndexPath returns the path of the detached index of the CAR at the
+// given path.
+func DetachedIndexPath(carPath string) string {
+	return carPath + DetachedIndexExt
+}
+
+// indexIterator lazily iterates over the multihashes of a
+// multicodec.CarMultihashIndexSorted index, reading them as they are needed
+// instead of decoding the whole index into memory. Indexes of local CARs are
+// read from their memory mapped files, which are unmapped when the iteration
+// ends.
+//
+// Multihashes are supplied in the order of the index, i.e. sorted by
+// multihash code, digest length and digest.
+type indexIterator struct {
+	r      io.ReaderAt
+	closer io.Closer
+	pos    int64
+	end    int64
+
+	// codes is the number of multihash code buckets left to read.
+	codes int32
+	code  uint64
+	// widths is the number of width buckets left to read in the current code
+	// bucket.
+	widths int32
+	width  uint32
+	// remaining is the number of bytes left to read in the current width
+	// bucket.
+	remaining uint64
+
+	entry []byte
+	err   error
+}
+
+// openIndexIterator memory maps the file at the given path, and returns an
+// iterator over the multihashes of the index that starts at the given offset
+// within it. If the index is not a multicodec.CarMultihashIndexSorted, which
+// is the only codec that contains whole multihashes, ok is false.
+func openIndexIterator(path string, offset int64) (*indexIterator, bool, error) {
+	ra, err := mmap.Open(path)
+	if err != nil {
+		return nil, false, err
+	}
+	it, ok, err := newIndexIterator(ra, offset, int64(ra.Len()))
+	if !ok {
+		ra.Close()
+		return nil, false, err
+	}
+	it.closer = ra
+	return it, true, nil
+}
+
+// newIndexIterator returns an iterator over the multihashes of the index that
+// spans the given offsets of r, if it is a
+// multicodec.CarMultihashIndexSorted.
+func newIndexIterator(r io.ReaderAt, start, end int64) (*indexIterator, bool, error) {
+	it := &indexIterator{
+		r:   r,
+		pos: start,
+		end: end,
+	}
+	codec, err := it.readUvarint()
+	if err != nil {
+		return nil, false, fmt.Errorf("cannot read index codec: %w", err)
+	}
+	if multicodec.Code(codec) != multicodec.CarMultihashIndexSorted {
+		return nil, false, nil
+	}
+	if it.codes, err = it.readInt32(); err != nil {
+		return nil, false, err
+	}
+	if it.codes < 0 {
+		return nil, false, errors.New("malformed index; negative number of multihash codes")
+	}
+	return it, true, nil
+}
+
+// openCarIndexIterator returns an iterator over the index embedded in the
+// CARv2 at the given path, if it has one with a usable codec.
+func openCarIndexIterator(path string, opts ...car.ReadOption) (*indexIterator, bool, error) {
+	cr, err := car.OpenReader(path, opts...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer cr.Close()
+	if cr.Version != 2 || !cr.Header.HasIndex() {
+		return nil, false, nil
+	}
+	return openIndexIterator(path, int64(cr.Header.IndexOffset))
+}
+
+// openDetachedIndexIterator returns an iterator over the detached index of the
+// CAR at the given path, if there is one with a usable codec that is not older
+// than the CAR.
+func openDetachedIndexIterator(path string) (*indexIterator, bool, error) {
+	idxPath := DetachedIndexPath(path)
+	idxInfo, err := os.Stat(idxPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	carInfo, err := os.Stat(path)
+	if err != nil {
+		return nil, false, err
+	}
+	if idxInfo.ModTime().Before(carInfo.ModTime()) {
+		log.Debugw("Detached CAR index is older than CAR; ignoring it.", "path", idxPath)
+		return nil, false, nil
+	}
+	return openIndexIterator(idxPath, 0)
+}
+
+// writeDetachedIndex writes the given index as the detached index of the CAR
+// at the given path. The index is written to a temporary file that is renamed
+// once complete, such that a partially written index is never read.
+func writeDetachedIndex(path string, idx index.Index) error {
+	idxPath := DetachedIndexPath(path)
+	f, err := os.CreateTemp(filepath.Dir(idxPath), filepath.Base(idxPath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err = index.WriteTo(idx, f); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), idxPath)
+}
+
+func (it *indexIterator) Next() (multihash.Multihash, error) {
+	if it.err != nil {
+		return nil, it.err
+	}
+	mh, err := it.next()
+	if err != nil {
+		it.err = err
+		if it.closer != nil {
+			it.closer.Close()
+		}
+		return nil, err
+	}
+	return mh, nil
+}
+
+// Close unmaps the index, if memory mapped, before the iteration ends. The
+// iterator supplies no more multihashes once closed.
+func (it *indexIterator) Close() error {
+	if it.err != nil {
+		return nil
+	}
+	it.err = io.EOF
+	if it.closer != nil {
+		return it.closer.Close()
+	}
+	return nil
+}
+
+func (it *indexIterator) next() (multihash.Multihash, error) {
+	var err error
+	for it.remaining == 0 {
+		if it.widths == 0 {
+			if it.codes == 0 {
+				return nil, io.EOF
+			}
+			it.codes--
+			if it.code, err = it.readUint64(); err != nil {
+				return nil, err
+			}
+			if it.widths, err = it.readInt32(); err != nil {
+				return nil, err
+			}
+			if it.widths < 0 {
+				return nil, errors.New("malformed index; negative number of widths")
+			}
+			continue
+		}
+		it.widths--
+		if it.width, err = it.readUint32(); err != nil {
+			return nil, err
+		}
+		if it.width < 8 || it.width > maxIndexWidth {
+			return nil, fmt.Errorf("malformed index; invalid width %d", it.width)
+		}
+		if it.remaining, err = it.readUint64(); err != nil {
+			return nil, err
+		}
+		if it.remaining%uint64(it.width) != 0 || it.remaining > uint64(it.end-it.pos) {
+			return nil, fmt.Errorf("malformed index; invalid length %d of width %d bucket", it.remaining, it.width)
+		}
+	}
+
+	if cap(it.entry) < int(it.width) {
+		it.entry = make([]byte, it.width)
+	}
+	entry := it.entry[:it.width]
+	if err = it.read(entry); err != nil {
+		return nil, err
+	}
+	it.remaining -= uint64(it.width)
+	// Each entry is a digest followed by its 8 byte offset within the CAR.
+	return multihash.Encode(entry[:it.width-8], it.code)
+}
+
+func (it *indexIterator) read(buf []byte) error {
+	if int64(len(buf)) > it.end-it.pos {
+		return io.ErrUnexpectedEOF
+	}
+	n, err := it.r.ReadAt(buf, it.pos)
+	it.pos += int64(n)
+	if err != nil && !(errors.Is(err, io.EOF) && n == len(buf)) {
+		return err
+	}
+	return nil
+}
+
+func (it *indexIterator) readUvarint() (uint64, error) {
+	var buf [binary.MaxVarintLen64]byte
+	n, err := it.r.ReadAt(buf[:], it.pos)
+	if n == 0 {
+		return 0, err
+	}
+	v, l, err := varint.FromUvarint(buf[:n])
+	if err != nil {
+		return 0, err
+	}
+	it.pos += int64(l)
+	return v, nil
+}
+
+func (it *indexIterator) readInt32() (int32, error) {
+	v, err := it.readUint32()
+	return int32(v), err
+}
+
+func (it *indexIterator) readUint32() (uint32, error) {
+	var buf [4]byte
+	if err := it.read(buf[:]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(buf[:]), nil
+}
+
+func (it *indexIterator) readUint64() (uint64, error) {
+	var buf [8]byte
+	if err := it.read(buf[:]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(buf[:]), nil
+}
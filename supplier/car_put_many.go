@@ -0,0 +1,110 @@
+package supplier
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipni/go-libipni/metadata"
+	provider "github.com/ipni/index-provider"
+)
+
+// CarPut is a CAR to put via CarSupplier.PutMany.
+type CarPut struct {
+	// ContextID is the context ID under which the CAR is advertised.
+	ContextID []byte
+	// Path is the path or URL of the CAR. See CarSupplier.Put.
+	Path string
+	// Metadata is the metadata with which the CAR is advertised.
+	Metadata metadata.Metadata
+}
+
+// CarPutResult is the outcome of putting a CAR via CarSupplier.PutMany.
+type CarPutResult struct {
+	CarPut
+	// AdvertisementID is the CID of the advertisement of the CAR, if it was
+	// advertised.
+	AdvertisementID cid.Cid
+	// Err is the error that putting the CAR failed with, if any.
+	Err error
+	// Duration is how long it took to index and advertise the CAR.
+	Duration time.Duration
+	// Done is the number of CARs put so far, including this one, and Total is
+	// the number of CARs to put.
+	Done, Total int
+}
+
+// PutMany puts the given CARs like CarSupplier.Put, indexing up to workers of
+// them concurrently. The advertisement of each CAR is published as soon as it
+// is indexed, regardless of the order of cars. At most workers CAR indexes are
+// held in memory at any time, since a worker indexes its next CAR only once
+// the previous one is advertised. If workers is less than one, CARs are put
+// one at a time.
+//
+// A CAR that fails to be put does not affect the others. If progress is not
+// nil, it is called with the result of each CAR once it is put, one call at a
+// time. A non-nil error is returned only if the context is canceled before all
+// CARs are put, in which case the remaining CARs are skipped.
+func (cs *CarSupplier) PutMany(ctx context.Context, cars []CarPut, workers int, progress func(CarPutResult)) error {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(cars) {
+		workers = len(cars)
+	}
+
+	jobs := make(chan CarPut)
+	results := make(chan CarPutResult)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for car := range jobs {
+				start := time.Now()
+				advID, err := cs.Put(ctx, car.ContextID, car.Path, car.Metadata)
+				results <- CarPutResult{
+					CarPut:          car,
+					AdvertisementID: advID,
+					Err:             err,
+					Duration:        time.Since(start),
+				}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for _, car := range cars {
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case jobs <- car:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var done int
+	for res := range results {
+		done++
+		if res.Err != nil && !errors.Is(res.Err, provider.ErrAlreadyAdvertised) {
+			log.Errorw("Failed to put CAR", "path", res.Path, "err", res.Err)
+		}
+		if progress != nil {
+			res.Done, res.Total = done, len(cars)
+			progress(res)
+		}
+	}
+	if done != len(cars) {
+		return ctx.Err()
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package supplier
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/index-provider/engine"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCarSupplier_PutMany(t *testing.T) {
+	ctx := context.Background()
+	eng, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+	subject := NewCarSupplier(eng, dssync.MutexWrap(datastore.NewMapDatastore()))
+
+	md := metadata.Default.New(metadata.Bitswap{})
+	paths := []string{
+		"../testdata/sample-v1.car",
+		"../testdata/sample-v1-2.car",
+		"../testdata/sample-wrapped-v2.car",
+		"../testdata/missing.car",
+		"../testdata/sample-wrapped-v2-2.car",
+	}
+	var cars []CarPut
+	for _, path := range paths {
+		cars = append(cars, CarPut{ContextID: []byte(path), Path: path, Metadata: md})
+	}
+
+	var results []CarPutResult
+	require.NoError(t, subject.PutMany(ctx, cars, 3, func(res CarPutResult) {
+		results = append(results, res)
+	}))
+	require.Len(t, results, len(cars))
+	for i, res := range results {
+		require.Equal(t, i+1, res.Done)
+		require.Equal(t, len(cars), res.Total)
+		if res.Path == "../testdata/missing.car" {
+			require.Error(t, res.Err)
+			continue
+		}
+		require.NoError(t, res.Err, res.Path)
+		ad, err := eng.GetAdv(ctx, res.AdvertisementID)
+		require.NoError(t, err)
+		require.Equal(t, res.ContextID, ad.ContextID)
+
+		// The multihashes of the advertised entries are those of the CAR.
+		it, err := subject.ListMultihashes(ctx, "", res.ContextID)
+		require.NoError(t, err)
+		require.NotEmpty(t, drainMultihashes(t, it))
+	}
+
+	// CARs that are already advertised are reported as such.
+	var failed int
+	require.NoError(t, subject.PutMany(ctx, cars, 2, func(res CarPutResult) {
+		if res.Err != nil {
+			failed++
+		}
+	}))
+	require.Equal(t, len(cars), failed)
+
+	// Nothing is put once the context is canceled.
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	require.ErrorIs(t, subject.PutMany(cctx, cars, 2, nil), context.Canceled)
+}
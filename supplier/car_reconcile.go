@@ -0,0 +1,101 @@
+package supplier
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-datastore/query"
+	provider "github.com/ipni/index-provider"
+)
+
+// Reconcile checks the local CARs put to this supplier, and removes those that
+// were deleted or became unreadable, such that their content is no longer
+// advertised. Remote CARs are not checked. A CAR is only removed if it is
+// missing, is not a regular file, or cannot be opened for lack of permission;
+// other errors, such as those of an unavailable file system, are logged and
+// the CAR is checked again by the next Reconcile. The number of CARs removed is
+// returned.
+func (cs *CarSupplier) Reconcile(ctx context.Context) (removed int, err error) {
+	results, err := cs.ds.Query(ctx, query.Query{Prefix: carIdDatastoreKeyPrefix})
+	if err != nil {
+		return 0, err
+	}
+	prefix := toCarIdKey(nil).String() + "/"
+	gone := make(map[string]string)
+	for r := range results.Next() {
+		if r.Error != nil {
+			results.Close()
+			return 0, r.Error
+		}
+		path := string(r.Value)
+		if IsRemoteCar(path) {
+			continue
+		}
+		if err := checkCarReadable(path); err != nil {
+			if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) || errors.Is(err, errNotRegularFile) {
+				gone[strings.TrimPrefix(r.Key, prefix)] = path
+			} else {
+				log.Warnw("Failed to check CAR", "path", path, "err", err)
+			}
+		}
+	}
+	results.Close()
+
+	for contextID, path := range gone {
+		if err := ctx.Err(); err != nil {
+			return removed, err
+		}
+		// The CAR may have been put again since it was checked.
+		if current, err := cs.getPath(ctx, []byte(contextID)); err != nil || current != path {
+			continue
+		}
+		_, err := cs.Remove(ctx, []byte(contextID))
+		if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, provider.ErrContextIDNotFound) {
+			log.Errorw("Failed to remove CAR", "path", path, "err", err)
+			continue
+		}
+		log.Infow("Removed CAR that is no longer readable", "path", path)
+		removed++
+	}
+	return removed, nil
+}
+
+// ReconcileEvery calls Reconcile every interval until the context is canceled.
+func (cs *CarSupplier) ReconcileEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		if _, err := cs.Reconcile(ctx); err != nil && ctx.Err() == nil {
+			log.Errorw("Failed to reconcile CARs", "err", err)
+		}
+	}
+}
+
+var errNotRegularFile = errors.New("not a regular file")
+
+// checkCarReadable checks that the CAR at the given path is a regular file that
+// can be opened for reading.
+func checkCarReadable(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return errNotRegularFile
+	}
+	return nil
+}
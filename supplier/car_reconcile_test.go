@@ -0,0 +1,57 @@
+package supplier
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipni/go-libipni/metadata"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCarSupplier_Reconcile(t *testing.T) {
+	ctx := context.Background()
+	eng, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+	subject := NewCarSupplier(eng, dssync.MutexWrap(datastore.NewMapDatastore()))
+
+	data, err := os.ReadFile("../testdata/sample-v1.car")
+	require.NoError(t, err)
+	dir := t.TempDir()
+	fishPath := filepath.Join(dir, "fish.car")
+	lobsterPath := filepath.Join(dir, "lobster.car")
+	require.NoError(t, os.WriteFile(fishPath, data, 0644))
+	require.NoError(t, os.WriteFile(lobsterPath, data, 0644))
+	md := metadata.Default.New(metadata.Bitswap{})
+	_, err = subject.Put(ctx, []byte("fish"), fishPath, md)
+	require.NoError(t, err)
+	_, err = subject.Put(ctx, []byte("lobster"), lobsterPath, md)
+	require.NoError(t, err)
+
+	removed, err := subject.Reconcile(ctx)
+	require.NoError(t, err)
+	require.Zero(t, removed)
+
+	require.NoError(t, os.Remove(fishPath))
+	removed, err = subject.Reconcile(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, removed)
+	paths, err := subject.List(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{lobsterPath}, paths)
+	_, err = eng.GetContextInfo(ctx, "", []byte("fish"))
+	require.ErrorIs(t, err, provider.ErrContextIDNotFound)
+	_, err = eng.GetContextInfo(ctx, "", []byte("lobster"))
+	require.NoError(t, err)
+
+	removed, err = subject.Reconcile(ctx)
+	require.NoError(t, err)
+	require.Zero(t, removed)
+}
@@ -4,7 +4,10 @@ import (
 	"context"
 	"errors"
 	"io"
+	"net/http"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 
 	bstore "github.com/ipfs/boxo/blockstore"
 	"github.com/ipfs/go-cid"
@@ -16,8 +19,12 @@ import (
 	"github.com/ipld/go-car/v2/index"
 	"github.com/ipni/go-libipni/metadata"
 	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/ipni/index-provider/metrics"
 	"github.com/libp2p/go-libp2p/core/peer"
-	"github.com/multiformats/go-multicodec"
+	"github.com/multiformats/go-multihash"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -35,21 +42,37 @@ var log = logging.Logger("provider/carsupplier")
 //
 // CarSupplier accepts both CARv1 and CARv2, and will automatically generate an index if one is not
 // present or the index codec and characteristics are not sufficient for provider.Interface purposes.
+// Indexes that are present, either embedded in a CARv2 or detached, are memory mapped and read
+// lazily, such that multi-GiB CARs are advertised without being scanned.
 //
 // See: engine.New, CarSupplier.Put, CarSupplier.Remove.
 type CarSupplier struct {
 	eng  provider.Interface
 	ds   datastore.Datastore
 	opts []car.ReadOption
+
+	persistIndexes atomic.Bool
+	httpClient     *http.Client
+	s3             S3Config
+	dedup          bool
+
+	// putMutex serializes the publishing of advertisements, while CARs are
+	// indexed concurrently.
+	putMutex sync.Mutex
+	// prepared holds the multihash iterators of CARs indexed by Put, keyed by
+	// context ID, until they are listed by the engine.
+	prepared sync.Map
 }
 
 // NewCarSupplier instantiates a new CarSupplier and registers it as the provider.MultihashLister of the
 // given provider.Interface.
 func NewCarSupplier(eng provider.Interface, ds datastore.Datastore, opts ...car.ReadOption) *CarSupplier {
 	cs := &CarSupplier{
-		eng:  eng,
-		ds:   ds,
-		opts: opts,
+		eng:        eng,
+		ds:         ds,
+		opts:       opts,
+		httpClient: http.DefaultClient,
+		s3:         S3ConfigFromEnv(),
 	}
 	eng.RegisterMultihashLister(cs.ListMultihashes)
 	return cs
@@ -59,19 +82,90 @@ func NewCarSupplier(eng provider.Interface, ds datastore.Datastore, opts ...car.
 // suppliable by this supplier. The return CID can then be used via Supply to
 // get an iterator over CIDs that belong to the CAR.
 //
-// This function accepts both CARv1 and CARv2 formats.
+// This function accepts both CARv1 and CARv2 formats. The path may also be
+// the http, https or s3 URL of a remote CAR, whose multihashes are then
+// fetched when they are listed. See IsRemoteCar.
+//
+// Put is safe for concurrent use. The CAR is indexed before its advertisement
+// is published, such that concurrent calls index CARs in parallel while their
+// advertisements are published one at a time. Remote CARs, and CARs that were
+// already put at the same path, are only indexed if the engine lists their
+// multihashes. See SetDeduplicate for skipping the indexing of CARs identical to
+// those already put.
 func (cs *CarSupplier) Put(ctx context.Context, contextID []byte, path string, metadata metadata.Metadata) (cid.Cid, error) {
 	// Clean path to CAR.
-	path = filepath.Clean(path)
+	if !IsRemoteCar(path) {
+		path = filepath.Clean(path)
+	}
+
+	prepCtx, span := metrics.Tracer.Start(ctx, "supplier.PrepareCar", trace.WithAttributes(attribute.String("path", path)))
+	prevPath, err := cs.getPath(prepCtx, contextID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		metrics.EndSpan(span, err)
+		return cid.Undef, err
+	}
+	var dedup *carDedup
+	reuser, ok := cs.eng.(entriesReuser)
+	if cs.dedup && ok && !IsRemoteCar(path) {
+		dedupCtx, dedupSpan := metrics.Tracer.Start(prepCtx, "supplier.DedupCar")
+		dedup, err = cs.dedupCar(dedupCtx, reuser, contextID, path)
+		metrics.EndSpan(dedupSpan, err)
+		// If the CAR cannot be digested, it is put without deduplication.
+		if err != nil {
+			log.Debugw("Failed to deduplicate CAR", "path", path, "err", err)
+			dedup = nil
+		}
+	}
+	if prevPath != path && !IsRemoteCar(path) && (dedup == nil || (!dedup.identical && dedup.entries == cid.Undef)) {
+		// If the CAR cannot be indexed, the error is left for the engine to
+		// report when it lists the multihashes of the CAR.
+		if it, err := cs.listMultihashes(prepCtx, path); err == nil {
+			key := string(contextID)
+			if _, loaded := cs.prepared.LoadOrStore(key, it); loaded {
+				// The same context ID is being put concurrently.
+				closeMultihashIterator(it)
+			} else {
+				defer func() {
+					if it, ok := cs.prepared.LoadAndDelete(key); ok {
+						closeMultihashIterator(it.(provider.MultihashIterator))
+					}
+				}()
+			}
+		}
+	}
+
+	span.End()
+
+	cs.putMutex.Lock()
+	defer cs.putMutex.Unlock()
 
 	// Store mapping of CAR ID to path, used to instantiate CID iterator.
 	carIdKey := toCarIdKey(contextID)
-	err := cs.ds.Put(ctx, carIdKey, []byte(path))
+	err = cs.ds.Put(ctx, carIdKey, []byte(path))
 	if err != nil {
 		return cid.Undef, err
 	}
 
-	return cs.eng.NotifyPut(ctx, nil, contextID, metadata)
+	if dedup == nil {
+		return cs.eng.NotifyPut(ctx, nil, contextID, metadata)
+	}
+	var adCid cid.Cid
+	if dedup.entries != cid.Undef {
+		adCid, err = reuser.NotifyPutWithEntries(ctx, nil, contextID, metadata, dedup.entries)
+		if errors.Is(err, engine.ErrUnknownEntries) {
+			// The identical CAR was removed in the meantime.
+			dedup.entries = cid.Undef
+		}
+	}
+	if dedup.entries == cid.Undef {
+		adCid, err = cs.eng.NotifyPut(ctx, nil, contextID, metadata)
+	}
+	if err == nil || (dedup.identical && errors.Is(err, provider.ErrAlreadyAdvertised)) {
+		if err := cs.putCarDigest(ctx, contextID, dedup); err != nil {
+			log.Warnw("Failed to store CAR digest", "path", path, "err", err)
+		}
+	}
+	return adCid, err
 }
 
 func toCarIdKey(contextID []byte) datastore.Key {
@@ -82,6 +176,9 @@ func toCarIdKey(contextID []byte) datastore.Key {
 // iterators. If the CAR at given path is not known, this function will return
 // an error.  This function accepts both CARv1 and CARv2 formats.
 func (cs *CarSupplier) Remove(ctx context.Context, contextID []byte) (cid.Cid, error) {
+	cs.putMutex.Lock()
+	defer cs.putMutex.Unlock()
+
 	// Delete mapping of CAR ID to path.
 	carIdKey := toCarIdKey(contextID)
 	has, err := cs.ds.Has(ctx, carIdKey)
@@ -98,6 +195,9 @@ func (cs *CarSupplier) Remove(ctx context.Context, contextID []byte) (cid.Cid, e
 		// See what we can do to opportunistically heal the datastore.
 		return cid.Undef, err
 	}
+	if err := cs.deleteCarDigest(ctx, contextID); err != nil {
+		return cid.Undef, err
+	}
 
 	return cs.eng.NotifyRemove(ctx, "", contextID)
 }
@@ -127,12 +227,80 @@ func (cs *CarSupplier) List(ctx context.Context) ([]string, error) {
 
 // ListMultihashes supplies an iterator over CIDs of the CAR file that corresponds to
 // the given key.  An error is returned if no CAR file is found for the key.
+//
+// The multihashes are read lazily from the index embedded in the CAR, or from
+// its detached index, if either is a multicodec.CarMultihashIndexSorted index.
+// Otherwise, the CAR is scanned to generate the index, which is persisted as
+// the detached index of the CAR if enabled via SetPersistDetachedIndexes.
+// Either way, multihashes are supplied in the order of the index.
 func (cs *CarSupplier) ListMultihashes(ctx context.Context, p peer.ID, contextID []byte) (provider.MultihashIterator, error) {
-	idx, err := cs.lookupIterableIndex(ctx, contextID)
+	path, err := cs.getPath(ctx, contextID)
 	if err != nil {
 		return nil, err
 	}
-	return provider.CarMultihashIterator(idx)
+	if it, ok := cs.prepared.LoadAndDelete(string(contextID)); ok {
+		return it.(provider.MultihashIterator), nil
+	}
+	return cs.listMultihashes(ctx, path)
+}
+
+// listMultihashes supplies an iterator over the multihashes of the CAR at the
+// given path. See ListMultihashes.
+func (cs *CarSupplier) listMultihashes(ctx context.Context, path string) (_ provider.MultihashIterator, err error) {
+	ctx, span := metrics.Tracer.Start(ctx, "supplier.ListMultihashes", trace.WithAttributes(attribute.String("path", path)))
+	defer func() { metrics.EndSpan(span, err) }()
+
+	log := log.With("path", path)
+	if IsRemoteCar(path) {
+		return cs.listRemoteMultihashes(ctx, path)
+	}
+
+	it, ok, err := openCarIndexIterator(path, cs.opts...)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return it, nil
+	}
+	it, ok, err = openDetachedIndexIterator(path)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return it, nil
+	}
+
+	log.Debugw("CAR has no iterable index; generating.")
+	idx, err := cs.generateIterableIndex(path)
+	if err != nil {
+		return nil, err
+	}
+	if cs.persistIndexes.Load() {
+		if err = writeDetachedIndex(path, idx); err != nil {
+			log.Warnw("Failed to persist detached CAR index", "err", err)
+		}
+	}
+	return indexMultihashes(idx)
+}
+
+// SetHTTPClient sets the client with which remote CARs are fetched. It must be
+// called before the supplier is used.
+func (cs *CarSupplier) SetHTTPClient(client *http.Client) {
+	cs.httpClient = client
+}
+
+// SetS3Config sets the config with which CARs at s3 URLs are fetched, which is
+// S3ConfigFromEnv by default. It must be called before the supplier is used.
+func (cs *CarSupplier) SetS3Config(cfg S3Config) {
+	cs.s3 = cfg
+}
+
+// SetPersistDetachedIndexes sets whether the index generated for a CAR that has
+// no iterable index is persisted as its detached index, at DetachedIndexPath,
+// so that subsequent listings of its multihashes read the index lazily instead
+// of scanning the CAR again. A detached index older than its CAR is ignored.
+func (cs *CarSupplier) SetPersistDetachedIndexes(persist bool) {
+	cs.persistIndexes.Store(persist)
 }
 
 // ClosableBlockstore is a blockstore that can be closed
@@ -141,12 +309,16 @@ type ClosableBlockstore interface {
 	io.Closer
 }
 
-// ReadOnlyBlockstore returns a CAR blockstore interface for the given blockstore key
+// ReadOnlyBlockstore returns a CAR blockstore interface for the given blockstore key.
+// ErrRemoteCar is returned if the CAR is remote.
 func (cs *CarSupplier) ReadOnlyBlockstore(contextID []byte) (ClosableBlockstore, error) {
 	path, err := cs.getPath(context.TODO(), contextID)
 	if err != nil {
 		return nil, err
 	}
+	if IsRemoteCar(path) {
+		return nil, ErrRemoteCar
+	}
 	return blockstore.OpenReadOnly(path, cs.opts...)
 }
 
@@ -161,59 +333,42 @@ func (cs *CarSupplier) getPath(ctx context.Context, contextID []byte) (path stri
 	return string(b), nil
 }
 
-func (cs *CarSupplier) lookupIterableIndex(ctx context.Context, contextID []byte) (index.IterableIndex, error) {
-	log := log.With("contextID", contextID)
-
-	path, err := cs.getPath(ctx, contextID)
-	if err != nil {
-		return nil, err
-	}
-
+func (cs *CarSupplier) generateIterableIndex(path string) (*index.MultihashIndexSorted, error) {
 	cr, err := car.OpenReader(path, cs.opts...)
 	if err != nil {
 		return nil, err
 	}
-	idxReader, err := cr.IndexReader()
+	defer cr.Close()
+	idx := index.NewMultihashSorted()
+	dr, err := cr.DataReader()
 	if err != nil {
 		return nil, err
 	}
-	if idxReader == nil {
-		// Missing index; generate it.
-		log.Debugw("CAR has no index; generating.")
-		return cs.generateIterableIndex(cr)
-	}
-	idx, err := index.ReadFrom(idxReader)
-	if err != nil {
+	if err := car.LoadIndex(idx, dr, cs.opts...); err != nil {
 		return nil, err
 	}
-	codec := idx.Codec()
-	log = log.With("codec", codec)
-	if codec != multicodec.CarMultihashIndexSorted {
-		log.Debugw("CAR index not iterable; regenerating index.")
-		return cs.generateIterableIndex(cr)
-	}
-	itIdx, ok := idx.(index.IterableIndex)
-	if !ok {
-		// Though technically possible, this should not happen, since the expectation is that
-		// multicodec.CarMultihashIndexSorted implements the index.IterableIndex interface.
-		// Regardless, defensively check this and re-generate as needed in case go-car library
-		// changes this expectation.
-		log.Warnw("expected CAR index to implement index.IterableIndex interface; regenerating index.")
-		return cs.generateIterableIndex(cr)
-	}
-	return itIdx, nil
+	return idx, nil
 }
 
-func (cs *CarSupplier) generateIterableIndex(cr *car.Reader) (index.IterableIndex, error) {
-	idx := index.NewMultihashSorted()
-	dr, err := cr.DataReader()
-	if err != nil {
+// indexMultihashes returns an iterator over the multihashes of the given
+// index, in the order of the index.
+func indexMultihashes(idx index.IterableIndex) (provider.MultihashIterator, error) {
+	var mhs []multihash.Multihash
+	if err := idx.ForEach(func(mh multihash.Multihash, _ uint64) error {
+		mhs = append(mhs, mh)
+		return nil
+	}); err != nil {
 		return nil, err
 	}
-	if err := car.LoadIndex(idx, dr, cs.opts...); err != nil {
-		return nil, err
+	return provider.SliceMultihashIterator(mhs), nil
+}
+
+// closeMultihashIterator releases the resources held by the given iterator,
+// e.g. the memory mapped index of a CAR, if it is not iterated to the end.
+func closeMultihashIterator(it provider.MultihashIterator) {
+	if c, ok := it.(io.Closer); ok {
+		c.Close()
 	}
-	return idx, nil
 }
 
 // Close permanently closes this supplier.
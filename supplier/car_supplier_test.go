@@ -9,13 +9,17 @@ import (
 	"math/rand"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
 	"github.com/ipld/go-car/v2"
+	"github.com/ipld/go-car/v2/index"
 	"github.com/ipni/go-libipni/metadata"
+	provider "github.com/ipni/index-provider"
 	mock_provider "github.com/ipni/index-provider/mock"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/multiformats/go-multihash"
@@ -191,6 +195,96 @@ func TestRemovedPathIsNoLongerSupplied(t *testing.T) {
 	require.Len(t, pathsAfterRm, 0)
 }
 
+func TestListMultihashesReadsEmbeddedIndexLazily(t *testing.T) {
+	path := "../testdata/sample-wrapped-v2-2.car"
+	subject, contextID := newTestCarSupplier(t, path)
+
+	cr, err := car.OpenReader(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { cr.Close() })
+	idxReader, err := cr.IndexReader()
+	require.NoError(t, err)
+	idx, err := index.ReadFrom(idxReader)
+	require.NoError(t, err)
+	var wantMultihashes []multihash.Multihash
+	require.NoError(t, idx.(index.IterableIndex).ForEach(func(mh multihash.Multihash, _ uint64) error {
+		wantMultihashes = append(wantMultihashes, mh)
+		return nil
+	}))
+	require.NotEmpty(t, wantMultihashes)
+
+	it, err := subject.ListMultihashes(context.Background(), "", contextID)
+	require.NoError(t, err)
+	require.IsType(t, &indexIterator{}, it)
+	require.Equal(t, wantMultihashes, drainMultihashes(t, it))
+}
+
+func TestListMultihashesPersistsDetachedIndex(t *testing.T) {
+	data, err := os.ReadFile("../testdata/sample-v1.car")
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "sample-v1.car")
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	subject, contextID := newTestCarSupplier(t, path)
+	ctx := context.Background()
+
+	// Without persisting, the generated index is not written.
+	it, err := subject.ListMultihashes(ctx, "", contextID)
+	require.NoError(t, err)
+	wantMultihashes := drainMultihashes(t, it)
+	require.NotEmpty(t, wantMultihashes)
+	require.NoFileExists(t, DetachedIndexPath(path))
+
+	subject.SetPersistDetachedIndexes(true)
+	it, err = subject.ListMultihashes(ctx, "", contextID)
+	require.NoError(t, err)
+	require.Equal(t, wantMultihashes, drainMultihashes(t, it))
+	require.FileExists(t, DetachedIndexPath(path))
+
+	// The persisted index is read lazily, and supplies the same multihashes
+	// in the same order.
+	it, err = subject.ListMultihashes(ctx, "", contextID)
+	require.NoError(t, err)
+	require.IsType(t, &indexIterator{}, it)
+	require.Equal(t, wantMultihashes, drainMultihashes(t, it))
+
+	// A detached index older than its CAR is ignored.
+	later := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(path, later, later))
+	subject.SetPersistDetachedIndexes(false)
+	it, err = subject.ListMultihashes(ctx, "", contextID)
+	require.NoError(t, err)
+	require.NotEqual(t, reflect.TypeOf(&indexIterator{}), reflect.TypeOf(it))
+	require.Equal(t, wantMultihashes, drainMultihashes(t, it))
+}
+
+func newTestCarSupplier(t *testing.T, path string) (*CarSupplier, []byte) {
+	mc := gomock.NewController(t)
+	t.Cleanup(mc.Finish)
+	mockEng := mock_provider.NewMockInterface(mc)
+	mockEng.EXPECT().RegisterMultihashLister(gomock.Any())
+	mockEng.EXPECT().NotifyPut(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(cid.Undef, nil)
+	subject := NewCarSupplier(mockEng, datastore.NewMapDatastore())
+	t.Cleanup(func() { require.NoError(t, subject.Close()) })
+
+	contextID := []byte(path)
+	_, err := subject.Put(context.Background(), contextID, path, metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	return subject, contextID
+}
+
+func drainMultihashes(t *testing.T, it provider.MultihashIterator) []multihash.Multihash {
+	var mhs []multihash.Multihash
+	for {
+		mh, err := it.Next()
+		if errors.Is(err, io.EOF) {
+			return mhs
+		}
+		require.NoError(t, err)
+		mhs = append(mhs, mh)
+	}
+}
+
 func generateCidV1(t *testing.T, rng *rand.Rand) cid.Cid {
 	data := []byte(fmt.Sprintf("🌊d-%d", rng.Uint64()))
 	mh, err := multihash.Sum(data, multihash.SHA3_256, -1)
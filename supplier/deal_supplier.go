@@ -0,0 +1,283 @@
+package supplier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/ipld/go-car/v2"
+	"github.com/ipni/go-libipni/metadata"
+	provider "github.com/ipni/index-provider"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	dealSupplierDatastorePrefix = "deal_supplier://"
+	dealPieceDatastorePrefix    = dealSupplierDatastorePrefix + "pieces/"
+)
+
+// Deal is a storage deal of a storage provider.
+type Deal struct {
+	// PieceCID is the CID of the piece that the deal stores.
+	PieceCID cid.Cid
+	// VerifiedDeal is whether the deal is verified.
+	VerifiedDeal bool
+	// SectorStartEpoch is the epoch at which the deal was activated in a
+	// sector, or -1 if it is not yet active.
+	SectorStartEpoch int64
+	// SlashEpoch is the epoch at which the deal was slashed, or -1 if it was
+	// not slashed.
+	SlashEpoch int64
+}
+
+// Active returns whether the deal is active, i.e. stored in a sector and not
+// slashed.
+func (d Deal) Active() bool {
+	return d.SectorStartEpoch >= 0 && d.SlashEpoch < 0
+}
+
+// DealLister lists the storage deals of a storage provider. Deals that have
+// expired are not listed.
+type DealLister func(ctx context.Context) ([]Deal, error)
+
+// MarketDealLister returns a DealLister that lists deals via the
+// Filecoin.MarketListDeals JSON-RPC method of the market API of a storage
+// provider at the given URL, such as that of lotus-miner. If token is not
+// empty, requests are authorized with it.
+func MarketDealLister(client *http.Client, apiURL, token string) DealLister {
+	return func(ctx context.Context) ([]Deal, error) {
+		body, err := json.Marshal(map[string]any{
+			"jsonrpc": "2.0",
+			"id":      1,
+			"method":  "Filecoin.MarketListDeals",
+			"params":  []any{},
+		})
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("cannot list deals: %s", resp.Status)
+		}
+
+		var res struct {
+			Result []struct {
+				Proposal struct {
+					PieceCID     cid.Cid
+					VerifiedDeal bool
+				}
+				State struct {
+					SectorStartEpoch int64
+					SlashEpoch       int64
+				}
+			}
+			Error *struct {
+				Code    int
+				Message string
+			}
+		}
+		if err = json.NewDecoder(resp.Body).Decode(&res); err != nil {
+			return nil, fmt.Errorf("cannot decode deals: %w", err)
+		}
+		if res.Error != nil {
+			return nil, fmt.Errorf("cannot list deals: %s (%d)", res.Error.Message, res.Error.Code)
+		}
+		deals := make([]Deal, 0, len(res.Result))
+		for _, d := range res.Result {
+			deals = append(deals, Deal{
+				PieceCID:         d.Proposal.PieceCID,
+				VerifiedDeal:     d.Proposal.VerifiedDeal,
+				SectorStartEpoch: d.State.SectorStartEpoch,
+				SlashEpoch:       d.State.SlashEpoch,
+			})
+		}
+		return deals, nil
+	}
+}
+
+// DealSupplier supplies the multihashes of the pieces of the active storage
+// deals of a storage provider to an implementation of provider.Interface. Each
+// piece is advertised under its piece CID as context ID, with
+// metadata.GraphsyncFilecoinV1 metadata. The removal of pieces is advertised
+// once they are no longer stored by any active deal, i.e. once their deals
+// have expired or have been slashed. See DealSupplier.Sync.
+//
+// The multihashes of a piece are listed by streaming the piece, which is a
+// CARv1 padded with zeros, from the piece HTTP endpoint of the storage
+// provider, such as that served by booster-http at /piece/{pieceCid}.
+//
+// Like MultihashSupplier, DealSupplier does not register itself as the
+// provider.MultihashLister of the engine, so that it can be combined with other
+// suppliers via ChainListers.
+type DealSupplier struct {
+	eng   provider.Interface
+	ds    datastore.Datastore
+	deals DealLister
+	// pieceURL is the URL of pieces with {pieceCid} standing for the piece
+	// CID.
+	pieceURL string
+	// fastRetrieval is declared in the metadata of advertised pieces.
+	fastRetrieval bool
+	// cs lists the multihashes of pieces as remote CARs.
+	cs *CarSupplier
+}
+
+// NewDealSupplier instantiates a new DealSupplier that advertises the pieces
+// of the deals listed by deals, and persists the advertised pieces in the
+// given datastore. The pieceURL is the URL from which pieces are fetched, in
+// which {pieceCid} stands for the piece CID. If it does not contain
+// {pieceCid}, the piece CID is appended to it as a path segment. The
+// fastRetrieval flag is declared in the metadata of advertised pieces.
+func NewDealSupplier(eng provider.Interface, ds datastore.Datastore, deals DealLister, pieceURL string, fastRetrieval bool) *DealSupplier {
+	if !strings.Contains(pieceURL, "{pieceCid}") {
+		pieceURL = strings.TrimSuffix(pieceURL, "/") + "/{pieceCid}"
+	}
+	return &DealSupplier{
+		eng:           eng,
+		ds:            ds,
+		deals:         deals,
+		pieceURL:      pieceURL,
+		fastRetrieval: fastRetrieval,
+		cs: &CarSupplier{
+			// Pieces are padded with zeros after the CARv1 payload.
+			opts:       []car.ReadOption{car.ZeroLengthSectionAsEOF(true)},
+			httpClient: http.DefaultClient,
+		},
+	}
+}
+
+// SetHTTPClient sets the client with which pieces are fetched. It must be
+// called before the supplier is used.
+func (s *DealSupplier) SetHTTPClient(client *http.Client) {
+	s.cs.SetHTTPClient(client)
+}
+
+// Sync lists the deals of the storage provider, advertises the pieces of
+// active deals that are not yet advertised, and advertises the removal of
+// advertised pieces that are no longer stored by any active deal. The numbers
+// of pieces advertised and removed are returned. Failures to advertise
+// individual pieces are logged, and retried by the next Sync.
+func (s *DealSupplier) Sync(ctx context.Context) (added, removed int, err error) {
+	deals, err := s.deals(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	active := make(map[cid.Cid]bool)
+	for _, d := range deals {
+		if d.Active() {
+			// The piece is verified if any of its deals is.
+			active[d.PieceCID] = active[d.PieceCID] || d.VerifiedDeal
+		}
+	}
+
+	advertised, err := s.List(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, pieceCid := range advertised {
+		if _, ok := active[pieceCid]; ok {
+			delete(active, pieceCid)
+			continue
+		}
+		if _, err = s.eng.NotifyRemove(ctx, "", pieceCid.Bytes()); err != nil && !errors.Is(err, provider.ErrContextIDNotFound) {
+			log.Errorw("Failed to advertise removal of piece", "pieceCid", pieceCid, "err", err)
+			continue
+		}
+		if err = s.ds.Delete(ctx, toDealPieceKey(pieceCid)); err != nil {
+			return added, removed, err
+		}
+		log.Infow("Advertised removal of piece without active deals", "pieceCid", pieceCid)
+		removed++
+	}
+
+	for pieceCid, verified := range active {
+		if err = ctx.Err(); err != nil {
+			return added, removed, err
+		}
+		key := toDealPieceKey(pieceCid)
+		if err = s.ds.Put(ctx, key, nil); err != nil {
+			return added, removed, err
+		}
+		md := metadata.Default.New(&metadata.GraphsyncFilecoinV1{
+			PieceCID:      pieceCid,
+			VerifiedDeal:  verified,
+			FastRetrieval: s.fastRetrieval,
+		})
+		if _, err = s.eng.NotifyPut(ctx, nil, pieceCid.Bytes(), md); err != nil && !errors.Is(err, provider.ErrAlreadyAdvertised) {
+			log.Errorw("Failed to advertise piece", "pieceCid", pieceCid, "err", err)
+			if err = s.ds.Delete(ctx, key); err != nil {
+				return added, removed, err
+			}
+			continue
+		}
+		log.Infow("Advertised piece of active deal", "pieceCid", pieceCid)
+		added++
+	}
+	return added, removed, nil
+}
+
+// List lists the CIDs of the pieces advertised by this supplier.
+func (s *DealSupplier) List(ctx context.Context) ([]cid.Cid, error) {
+	results, err := s.ds.Query(ctx, query.Query{
+		Prefix:   dealPieceDatastorePrefix,
+		KeysOnly: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var pieceCids []cid.Cid
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		pieceCid, err := cid.Decode(datastore.RawKey(r.Key).BaseNamespace())
+		if err != nil {
+			return nil, err
+		}
+		pieceCids = append(pieceCids, pieceCid)
+	}
+	return pieceCids, nil
+}
+
+// ListMultihashes supplies an iterator over the multihashes of the piece whose
+// CID is the given context ID. ErrNotFound is returned if the piece is not
+// advertised by this supplier.
+func (s *DealSupplier) ListMultihashes(ctx context.Context, _ peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+	_, pieceCid, err := cid.CidFromBytes(contextID)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	has, err := s.ds.Has(ctx, toDealPieceKey(pieceCid))
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, ErrNotFound
+	}
+	return s.cs.listRemoteMultihashes(ctx, strings.ReplaceAll(s.pieceURL, "{pieceCid}", pieceCid.String()))
+}
+
+func toDealPieceKey(pieceCid cid.Cid) datastore.Key {
+	return datastore.NewKey(dealPieceDatastorePrefix + pieceCid.String())
+}
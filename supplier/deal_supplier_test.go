@@ -0,0 +1,156 @@
+package supplier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/index-provider/engine"
+	"github.com/multiformats/go-multicodec"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarketDealLister(t *testing.T) {
+	pieceCid, err := cid.Decode("baga6ea4seaqao7s73y24kcutaosvacpdjgfe5pw76ooefnyqw4ynr3d2y6x2mpq")
+	require.NoError(t, err)
+	var failure bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer fish", r.Header.Get("Authorization"))
+		var req struct{ Method string }
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Equal(t, "Filecoin.MarketListDeals", req.Method)
+		if failure {
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":1,"message":"lobster"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":[{"Proposal":{"PieceCID":{"/":"` + pieceCid.String() +
+			`"},"PieceSize":2048,"VerifiedDeal":true,"StartEpoch":10,"EndEpoch":20},"State":{"SectorStartEpoch":12,"LastUpdatedEpoch":-1,"SlashEpoch":-1}}]}`))
+	}))
+	defer srv.Close()
+
+	subject := MarketDealLister(http.DefaultClient, srv.URL, "fish")
+	deals, err := subject(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []Deal{{PieceCID: pieceCid, VerifiedDeal: true, SectorStartEpoch: 12, SlashEpoch: -1}}, deals)
+	require.True(t, deals[0].Active())
+
+	failure = true
+	_, err = subject(context.Background())
+	require.ErrorContains(t, err, "lobster")
+}
+
+func TestDealSupplier(t *testing.T) {
+	ctx := context.Background()
+	eng, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+
+	// Pieces are CARv1 padded with zeros.
+	carPath := "../testdata/sample-v1.car"
+	data, err := os.ReadFile(carPath)
+	require.NoError(t, err)
+	data = append(data, make([]byte, 128)...)
+	pieceSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/piece/baga") {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(data)
+	}))
+	defer pieceSrv.Close()
+
+	pieceCids := randomPieceCids(t, 3)
+	var mutex sync.Mutex
+	deals := []Deal{
+		{PieceCID: pieceCids[0], VerifiedDeal: true, SectorStartEpoch: 10, SlashEpoch: -1},
+		// Not yet active.
+		{PieceCID: pieceCids[1], SectorStartEpoch: -1, SlashEpoch: -1},
+		// Slashed.
+		{PieceCID: pieceCids[2], SectorStartEpoch: 10, SlashEpoch: 20},
+	}
+	lister := func(context.Context) ([]Deal, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]Deal(nil), deals...), nil
+	}
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	subject := NewDealSupplier(eng, ds, lister, pieceSrv.URL+"/piece", true)
+	eng.RegisterMultihashLister(subject.ListMultihashes)
+
+	added, removed, err := subject.Sync(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, added)
+	require.Zero(t, removed)
+	pieceList, err := subject.List(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []cid.Cid{pieceCids[0]}, pieceList)
+
+	info, err := eng.GetContextInfo(ctx, "", pieceCids[0].Bytes())
+	require.NoError(t, err)
+	require.True(t, info.Metadata.Equal(metadata.Default.New(&metadata.GraphsyncFilecoinV1{
+		PieceCID:      pieceCids[0],
+		VerifiedDeal:  true,
+		FastRetrieval: true,
+	})))
+
+	local, localContextID := newTestCarSupplier(t, carPath)
+	it, err := local.ListMultihashes(ctx, "", localContextID)
+	require.NoError(t, err)
+	wantMultihashes := drainMultihashes(t, it)
+	it, err = subject.ListMultihashes(ctx, "", pieceCids[0].Bytes())
+	require.NoError(t, err)
+	require.Equal(t, wantMultihashes, drainMultihashes(t, it))
+	_, err = subject.ListMultihashes(ctx, "", pieceCids[1].Bytes())
+	require.ErrorIs(t, err, ErrNotFound)
+
+	// Nothing changed.
+	added, removed, err = subject.Sync(ctx)
+	require.NoError(t, err)
+	require.Zero(t, added)
+	require.Zero(t, removed)
+
+	// The first deal expired, and the second became active.
+	mutex.Lock()
+	deals = deals[1:]
+	deals[0].SectorStartEpoch = 30
+	mutex.Unlock()
+	added, removed, err = subject.Sync(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, added)
+	require.Equal(t, 1, removed)
+	pieceList, err = subject.List(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []cid.Cid{pieceCids[1]}, pieceList)
+	_, err = eng.GetContextInfo(ctx, "", pieceCids[0].Bytes())
+	require.Error(t, err)
+	_, err = subject.ListMultihashes(ctx, "", pieceCids[0].Bytes())
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+// randomPieceCids returns piece CIDs with sha2-256 multihashes, since the
+// sha2-256-trunc254-padded hash of real piece CIDs is not registered.
+func randomPieceCids(t *testing.T, n int) []cid.Cid {
+	pieceCids := make([]cid.Cid, n)
+	for i := range pieceCids {
+		c, err := cid.Prefix{
+			Version:  1,
+			Codec:    uint64(multicodec.FilCommitmentUnsealed),
+			MhType:   uint64(multicodec.Sha2_256),
+			MhLength: -1,
+		}.Sum([]byte{byte(i)})
+		require.NoError(t, err)
+		pieceCids[i] = c
+	}
+	return pieceCids
+}
@@ -0,0 +1,447 @@
+package supplier
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/blockstore"
+	chunker "github.com/ipfs/boxo/chunker"
+	"github.com/ipfs/boxo/exchange/offline"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/ipld/unixfs/importer/balanced"
+	"github.com/ipfs/boxo/ipld/unixfs/importer/helpers"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	format "github.com/ipfs/go-ipld-format"
+	"github.com/ipni/go-libipni/metadata"
+	provider "github.com/ipni/index-provider"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multihash"
+)
+
+const (
+	directorySupplierDatastorePrefix = "directory_supplier://"
+	directoryFileDatastorePrefix     = directorySupplierDatastorePrefix + "files/"
+)
+
+// directorySettleDelay is how long a watched directory must go unmodified
+// before it is scanned, so that files that are still being written are not
+// imported.
+var directorySettleDelay = 2 * time.Second
+
+// DirectorySupplier supplies the multihashes of the files in a local directory
+// to an implementation of provider.Interface, like `ipfs add` followed by
+// advertising the added content. Each regular file in the directory and its
+// subdirectories is chunked into a UnixFS DAG whose blocks are stored in a
+// blockstore, and advertised under the SHA-256 hash of its absolute path as
+// context ID. Hidden files and directories, i.e. those whose names start with
+// a dot, are ignored.
+//
+// Files are imported by DirectorySupplier.Scan, which re-advertises files that
+// have changed and advertises the removal of files that have been deleted
+// since the previous scan. DirectorySupplier.Watch scans the directory
+// whenever it changes.
+//
+// Blocks are never deleted from the blockstore, since they may be shared with
+// other DAGs. The blocks must be served by a node that uses the same
+// blockstore, e.g. over bitswap, for the advertised content to be retrievable.
+//
+// Like MultihashSupplier, DirectorySupplier does not register itself as the
+// provider.MultihashLister of the engine, so that it can be combined with other
+// suppliers via ChainListers.
+type DirectorySupplier struct {
+	dir string
+	ds  datastore.Datastore
+	md  metadata.Metadata
+	// bss advertises the DAGs of imported files.
+	bss       *BlockstoreSupplier
+	dag       format.DAGService
+	chunker   string
+	rawLeaves bool
+	// mutex serializes scans.
+	mutex sync.Mutex
+}
+
+// directoryFile is the record of a file advertised by DirectorySupplier.
+type directoryFile struct {
+	Path string
+	Root cid.Cid
+	// Size and ModTime are those of the file when it was imported, by which
+	// changes to the file are detected.
+	Size    int64
+	ModTime time.Time
+}
+
+// DirectoryOption configures a DirectorySupplier.
+type DirectoryOption func(*DirectorySupplier)
+
+// WithChunker sets the chunker with which files are split into blocks, in the
+// format of the --chunker option of `ipfs add`, e.g. "size-262144",
+// "rabin-262144" or "buzhash". Defaults to chunker.DefaultBlockSize sized
+// chunks.
+func WithChunker(spec string) DirectoryOption {
+	return func(s *DirectorySupplier) {
+		s.chunker = spec
+	}
+}
+
+// WithRawLeaves sets whether the data of files is stored in raw leaf blocks,
+// rather than UnixFS dag-pb leaves. Raw leaves imply CIDv1, as with
+// `ipfs add --raw-leaves`. Defaults to false.
+func WithRawLeaves(rawLeaves bool) DirectoryOption {
+	return func(s *DirectorySupplier) {
+		s.rawLeaves = rawLeaves
+	}
+}
+
+// NewDirectorySupplier instantiates a new DirectorySupplier that advertises
+// the files in dir with the given metadata, stores their blocks in bs, and
+// persists the records of advertised files in the given datastore.
+func NewDirectorySupplier(eng provider.Interface, ds datastore.Datastore, bs blockstore.Blockstore, dir string, md metadata.Metadata, opts ...DirectoryOption) (*DirectorySupplier, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	s := &DirectorySupplier{
+		dir:     dir,
+		ds:      ds,
+		md:      md,
+		bss:     NewBlockstoreSupplier(eng, ds, bs),
+		dag:     merkledag.NewDAGService(blockservice.New(bs, offline.Exchange(bs))),
+		chunker: fmt.Sprintf("size-%d", chunker.DefaultBlockSize),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if _, err = chunker.FromString(bytes.NewReader(nil), s.chunker); err != nil {
+		return nil, fmt.Errorf("invalid chunker: %w", err)
+	}
+	return s, nil
+}
+
+// Scan imports the files in the directory, advertises files that are not yet
+// advertised, re-advertises files whose content has changed, and advertises
+// the removal of advertised files that no longer exist. The numbers of files
+// added, updated and removed are returned. Failures to import individual files
+// are logged, and retried by the next Scan.
+func (s *DirectorySupplier) Scan(ctx context.Context) (added, updated, removed int, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	seen := make(map[string]struct{})
+	err = filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != s.dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		seen[path] = struct{}{}
+		isNew, changed, err := s.importFile(ctx, path)
+		if err != nil {
+			log.Errorw("Failed to import file", "path", path, "err", err)
+			return nil
+		}
+		switch {
+		case isNew:
+			added++
+		case changed:
+			updated++
+		}
+		return nil
+	})
+	if err != nil {
+		return added, updated, removed, err
+	}
+
+	files, err := s.list(ctx)
+	if err != nil {
+		return added, updated, removed, err
+	}
+	for _, file := range files {
+		if _, ok := seen[file.Path]; ok {
+			continue
+		}
+		contextID := directoryContextID(file.Path)
+		if _, err = s.bss.Remove(ctx, "", contextID); err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, provider.ErrContextIDNotFound) {
+			log.Errorw("Failed to advertise removal of file", "path", file.Path, "err", err)
+			continue
+		}
+		if err = s.ds.Delete(ctx, toDirectoryFileKey(contextID)); err != nil {
+			return added, updated, removed, err
+		}
+		log.Infow("Advertised removal of deleted file", "path", file.Path)
+		removed++
+	}
+	return added, updated, removed, nil
+}
+
+// importFile imports the file at the given path if it is not yet advertised
+// or has changed since it was imported, and advertises it.
+func (s *DirectorySupplier) importFile(ctx context.Context, path string) (isNew, changed bool, err error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return false, false, err
+	}
+	contextID := directoryContextID(path)
+	prev, err := s.getFile(ctx, contextID)
+	switch {
+	case errors.Is(err, ErrNotFound):
+	case err != nil:
+		return false, false, err
+	case prev.Size == fi.Size() && prev.ModTime.Equal(fi.ModTime()):
+		return false, false, nil
+	}
+
+	root, err := s.buildDag(path)
+	if err != nil {
+		return false, false, err
+	}
+	file := &directoryFile{
+		Path:    path,
+		Root:    root,
+		Size:    fi.Size(),
+		ModTime: fi.ModTime(),
+	}
+	if prev != nil {
+		adCid, err := s.bss.Rescan(ctx, contextID, root)
+		switch {
+		case err == nil:
+			return false, adCid != cid.Undef, s.putFile(ctx, contextID, file)
+		case !errors.Is(err, ErrNotFound):
+			return false, false, err
+		}
+		// The DAG of the file was never advertised; advertise it below.
+	}
+
+	// The record of the file must exist before it is advertised, so that its
+	// multihashes can be listed.
+	if err = s.putFile(ctx, contextID, file); err != nil {
+		return false, false, err
+	}
+	if _, err = s.bss.Put(ctx, nil, contextID, root, nil, s.md); err != nil && !errors.Is(err, provider.ErrAlreadyAdvertised) {
+		if delErr := s.ds.Delete(ctx, toDirectoryFileKey(contextID)); delErr != nil {
+			log.Errorw("Failed to delete record of unadvertised file", "err", delErr)
+		}
+		return false, false, err
+	}
+	log.Infow("Advertised file", "path", path, "root", root)
+	return true, false, nil
+}
+
+// buildDag chunks the file at the given path into a UnixFS DAG, stores its
+// blocks, and returns the CID of its root.
+func (s *DirectorySupplier) buildDag(path string) (cid.Cid, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return cid.Undef, err
+	}
+	defer f.Close()
+	spl, err := chunker.FromString(f, s.chunker)
+	if err != nil {
+		return cid.Undef, err
+	}
+	params := helpers.DagBuilderParams{
+		Dagserv:   s.dag,
+		Maxlinks:  helpers.DefaultLinksPerBlock,
+		RawLeaves: s.rawLeaves,
+	}
+	if s.rawLeaves {
+		params.CidBuilder = cid.V1Builder{Codec: cid.DagProtobuf, MhType: multihash.SHA2_256}
+	}
+	db, err := params.New(spl)
+	if err != nil {
+		return cid.Undef, err
+	}
+	nd, err := balanced.Layout(db)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("cannot build DAG: %w", err)
+	}
+	return nd.Cid(), nil
+}
+
+// Watch scans the directory whenever files in it change, until the context is
+// canceled. Changes are scanned once the directory has gone unmodified for a
+// couple of seconds. The directory is not scanned when watching starts; call
+// Scan first to import files that changed while not watched.
+func (s *DirectorySupplier) Watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("cannot watch directory: %w", err)
+	}
+	defer watcher.Close()
+	if err = s.watchTree(watcher, s.dir); err != nil {
+		return fmt.Errorf("cannot watch directory: %w", err)
+	}
+
+	// When the directory was last modified, if a scan is pending.
+	var modified time.Time
+	ticker := time.NewTicker(directorySettleDelay / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if strings.HasPrefix(filepath.Base(event.Name), ".") {
+				continue
+			}
+			if event.Has(fsnotify.Create) {
+				if fi, err := os.Stat(event.Name); err == nil && fi.IsDir() {
+					if err = s.watchTree(watcher, event.Name); err != nil {
+						log.Errorw("Failed to watch directory", "path", event.Name, "err", err)
+					}
+				}
+			}
+			modified = time.Now()
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Errorw("Error watching directory", "err", err)
+		case now := <-ticker.C:
+			if modified.IsZero() || now.Sub(modified) < directorySettleDelay {
+				continue
+			}
+			modified = time.Time{}
+			added, updated, removed, err := s.Scan(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				log.Errorw("Failed to scan directory", "dir", s.dir, "err", err)
+				continue
+			}
+			log.Infow("Scanned directory", "dir", s.dir, "added", added, "updated", updated, "removed", removed)
+		}
+	}
+}
+
+// watchTree adds the given directory and its subdirectories, other than
+// hidden ones, to the watcher.
+func (s *DirectorySupplier) watchTree(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != s.dir && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		return watcher.Add(path)
+	})
+}
+
+// ListMultihashes supplies an iterator over the multihashes of the blocks of
+// the file advertised under the given context ID. ErrNotFound is returned if
+// no file is advertised under the context ID.
+func (s *DirectorySupplier) ListMultihashes(ctx context.Context, p peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+	has, err := s.ds.Has(ctx, toDirectoryFileKey(contextID))
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, ErrNotFound
+	}
+	return s.bss.ListMultihashes(ctx, p, contextID)
+}
+
+// List lists the paths and root CIDs of the files advertised by this supplier.
+func (s *DirectorySupplier) List(ctx context.Context) (map[string]cid.Cid, error) {
+	files, err := s.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	roots := make(map[string]cid.Cid, len(files))
+	for _, file := range files {
+		roots[file.Path] = file.Root
+	}
+	return roots, nil
+}
+
+// list returns the records of the files in the directory of this supplier.
+// Records of files in other directories, advertised by other suppliers with
+// the same datastore, are skipped.
+func (s *DirectorySupplier) list(ctx context.Context) ([]*directoryFile, error) {
+	results, err := s.ds.Query(ctx, query.Query{Prefix: directoryFileDatastorePrefix})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var files []*directoryFile
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		var file directoryFile
+		if err = json.Unmarshal(r.Value, &file); err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(file.Path, s.dir+string(filepath.Separator)) {
+			files = append(files, &file)
+		}
+	}
+	return files, nil
+}
+
+func (s *DirectorySupplier) getFile(ctx context.Context, contextID []byte) (*directoryFile, error) {
+	b, err := s.ds.Get(ctx, toDirectoryFileKey(contextID))
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			err = ErrNotFound
+		}
+		return nil, err
+	}
+	var file directoryFile
+	if err = json.Unmarshal(b, &file); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+func (s *DirectorySupplier) putFile(ctx context.Context, contextID []byte, file *directoryFile) error {
+	b, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+	return s.ds.Put(ctx, toDirectoryFileKey(contextID), b)
+}
+
+// directoryContextID returns the context ID of the file at the given absolute
+// path, i.e. the SHA-256 hash of the path.
+func directoryContextID(path string) []byte {
+	h := sha256.Sum256([]byte(path))
+	return h[:]
+}
+
+func toDirectoryFileKey(contextID []byte) datastore.Key {
+	return datastore.NewKey(directoryFileDatastorePrefix + provider.ContextID(contextID).String())
+}
@@ -0,0 +1,164 @@
+package supplier
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/index-provider/engine"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirectorySupplier(t *testing.T) {
+	ctx := context.Background()
+	eng, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+
+	dir := t.TempDir()
+	fishPath := filepath.Join(dir, "fish")
+	lobsterPath := filepath.Join(dir, "sea", "lobster")
+	require.NoError(t, os.WriteFile(fishPath, []byte("fish"), 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sea"), 0755))
+	require.NoError(t, os.WriteFile(lobsterPath, []byte("lobster"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".crab"), []byte("crab"), 0644))
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(ds)
+	subject, err := NewDirectorySupplier(eng, ds, bstore, dir, metadata.Default.New(metadata.Bitswap{}), WithRawLeaves(true))
+	require.NoError(t, err)
+	eng.RegisterMultihashLister(subject.ListMultihashes)
+
+	added, updated, removed, err := subject.Scan(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, added)
+	require.Zero(t, updated)
+	require.Zero(t, removed)
+
+	// Small files are single raw blocks.
+	fishCid := rawCid(t, "fish")
+	roots, err := subject.List(ctx)
+	require.NoError(t, err)
+	require.Equal(t, map[string]cid.Cid{fishPath: fishCid, lobsterPath: rawCid(t, "lobster")}, roots)
+	it, err := subject.ListMultihashes(ctx, "", directoryContextID(fishPath))
+	require.NoError(t, err)
+	require.Equal(t, []multihash.Multihash{fishCid.Hash()}, drain(it))
+	has, err := bstore.Has(ctx, fishCid)
+	require.NoError(t, err)
+	require.True(t, has)
+	_, err = subject.ListMultihashes(ctx, "", directoryContextID(filepath.Join(dir, ".crab")))
+	require.ErrorIs(t, err, ErrNotFound)
+
+	// Nothing changed.
+	added, updated, removed, err = subject.Scan(ctx)
+	require.NoError(t, err)
+	require.Zero(t, added+updated+removed)
+
+	// Change one file and delete the other.
+	require.NoError(t, os.WriteFile(fishPath, []byte("barreleye"), 0644))
+	require.NoError(t, os.Chtimes(fishPath, time.Now(), time.Now().Add(time.Minute)))
+	require.NoError(t, os.Remove(lobsterPath))
+	added, updated, removed, err = subject.Scan(ctx)
+	require.NoError(t, err)
+	require.Zero(t, added)
+	require.Equal(t, 1, updated)
+	require.Equal(t, 1, removed)
+	roots, err = subject.List(ctx)
+	require.NoError(t, err)
+	require.Equal(t, map[string]cid.Cid{fishPath: rawCid(t, "barreleye")}, roots)
+	info, err := eng.GetContextInfo(ctx, "", directoryContextID(fishPath))
+	require.NoError(t, err)
+	require.True(t, info.Metadata.Equal(metadata.Default.New(metadata.Bitswap{})))
+	_, err = eng.GetContextInfo(ctx, "", directoryContextID(lobsterPath))
+	require.Error(t, err)
+	_, err = subject.ListMultihashes(ctx, "", directoryContextID(lobsterPath))
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestDirectorySupplier_Chunker(t *testing.T) {
+	ctx := context.Background()
+	eng, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "fish"), []byte("fish and lobster"), 0644))
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(ds)
+
+	_, err = NewDirectorySupplier(eng, ds, bstore, dir, metadata.Default.New(metadata.Bitswap{}), WithChunker("fish-4"))
+	require.ErrorContains(t, err, "invalid chunker")
+
+	subject, err := NewDirectorySupplier(eng, ds, bstore, dir, metadata.Default.New(metadata.Bitswap{}), WithChunker("size-4"))
+	require.NoError(t, err)
+	eng.RegisterMultihashLister(subject.ListMultihashes)
+	added, _, _, err := subject.Scan(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, added)
+	it, err := subject.ListMultihashes(ctx, "", directoryContextID(filepath.Join(dir, "fish")))
+	require.NoError(t, err)
+	// A dag-pb root linking to four leaves.
+	mhs := drain(it)
+	require.Len(t, mhs, 5)
+	roots, err := subject.List(ctx)
+	require.NoError(t, err)
+	root := roots[filepath.Join(dir, "fish")]
+	require.Equal(t, uint64(cid.DagProtobuf), root.Prefix().Codec)
+	require.Equal(t, root.Hash(), mhs[0])
+}
+
+func TestDirectorySupplier_Watch(t *testing.T) {
+	defer func(delay time.Duration) { directorySettleDelay = delay }(directorySettleDelay)
+	directorySettleDelay = 100 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	eng, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+
+	dir := t.TempDir()
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	subject, err := NewDirectorySupplier(eng, ds, blockstore.NewBlockstore(ds), dir, metadata.Default.New(metadata.Bitswap{}))
+	require.NoError(t, err)
+	eng.RegisterMultihashLister(subject.ListMultihashes)
+	watchErr := make(chan error, 1)
+	go func() { watchErr <- subject.Watch(ctx) }()
+	// Give the watcher time to start.
+	time.Sleep(100 * time.Millisecond)
+
+	// Files in new subdirectories are imported too.
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sea"), 0755))
+	time.Sleep(100 * time.Millisecond)
+	path := filepath.Join(dir, "sea", "fish")
+	require.NoError(t, os.WriteFile(path, []byte("fish"), 0644))
+	require.Eventually(t, func() bool {
+		_, err := subject.ListMultihashes(ctx, "", directoryContextID(path))
+		return err == nil
+	}, 5*time.Second, 50*time.Millisecond)
+
+	require.NoError(t, os.Remove(path))
+	require.Eventually(t, func() bool {
+		_, err := subject.ListMultihashes(ctx, "", directoryContextID(path))
+		return err == ErrNotFound
+	}, 5*time.Second, 50*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-watchErr)
+}
+
+func rawCid(t *testing.T, data string) cid.Cid {
+	c, err := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.SHA2_256, MhLength: -1}.Sum([]byte(data))
+	require.NoError(t, err)
+	return c
+}
@@ -1,5 +1,10 @@
 // Package supplier provides mechanisms to supply mulithashes to an index-provider engine via
 // provider.MultihashLister
-// The only implemented mechanism is CarSupplier, that in conjunction with an engine allows a user
-// to advertise multihashes by simply providing CAR files.
+// CarSupplier, in conjunction with an engine, allows a user to advertise multihashes by simply
+// providing CAR files. MultihashSupplier allows a user to advertise multihashes given explicitly,
+// and can be combined with other suppliers via ChainListers. BlockstoreSupplier allows a user to
+// advertise the blocks of DAGs in an existing blockstore, such as that of a Kubo or boxo node.
+// DealSupplier advertises the pieces of the active storage deals of a Filecoin storage provider.
+// DirectorySupplier imports the files in a local directory as UnixFS DAGs into a blockstore and
+// advertises them, keeping the advertisements up to date as files change.
 package supplier
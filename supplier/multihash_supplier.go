@@ -0,0 +1,148 @@
+package supplier
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipni/go-libipni/metadata"
+	provider "github.com/ipni/index-provider"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multihash"
+)
+
+const multihashSupplierDatastorePrefix = "multihash_supplier://"
+
+// MultihashSupplier supplies multihashes given explicitly by the user to an
+// implementation of provider.Interface. It allows users to advertise arbitrary
+// multihashes under a context ID by calling MultihashSupplier.Put, and to
+// advertise their removal by calling MultihashSupplier.Remove. The multihashes
+// are persisted in the datastore until removed.
+//
+// Unlike CarSupplier, MultihashSupplier does not register itself as the
+// provider.MultihashLister of the engine, so that it can be combined with other
+// suppliers via ChainListers.
+type MultihashSupplier struct {
+	eng provider.Interface
+	ds  datastore.Datastore
+}
+
+// NewMultihashSupplier instantiates a new MultihashSupplier that persists
+// multihashes in the given datastore.
+func NewMultihashSupplier(eng provider.Interface, ds datastore.Datastore) *MultihashSupplier {
+	return &MultihashSupplier{
+		eng: eng,
+		ds:  ds,
+	}
+}
+
+// Put stores the given multihashes under the context ID, and advertises them
+// with the given metadata. If p is nil, the multihashes are advertised by the
+// default provider of the engine. The metadata of a context ID that is already
+// advertised can be updated by putting it again with the same multihashes.
+func (ms *MultihashSupplier) Put(ctx context.Context, p *peer.AddrInfo, contextID []byte, mhs []multihash.Multihash, md metadata.Metadata) (cid.Cid, error) {
+	if len(mhs) == 0 {
+		return cid.Undef, errors.New("no multihashes to advertise")
+	}
+	var buf bytes.Buffer
+	for _, mh := range mhs {
+		buf.Write(mh)
+	}
+	key := toMultihashesKey(contextID)
+	existing, err := ms.ds.Get(ctx, key)
+	switch {
+	case err == nil:
+		// The entries of an advertised context ID cannot change, but its
+		// metadata may be updated.
+		if !bytes.Equal(existing, buf.Bytes()) {
+			return cid.Undef, errors.New("context ID is already advertised with different multihashes; remove it first")
+		}
+		return ms.eng.NotifyPut(ctx, p, contextID, md)
+	case !errors.Is(err, datastore.ErrNotFound):
+		return cid.Undef, err
+	}
+
+	if err = ms.ds.Put(ctx, key, buf.Bytes()); err != nil {
+		return cid.Undef, err
+	}
+	advID, err := ms.eng.NotifyPut(ctx, p, contextID, md)
+	if err != nil && !errors.Is(err, provider.ErrAlreadyAdvertised) {
+		// Do not keep multihashes that were never advertised.
+		if delErr := ms.ds.Delete(ctx, key); delErr != nil {
+			log.Errorw("Failed to delete multihashes of unadvertised context", "err", delErr)
+		}
+	}
+	return advID, err
+}
+
+// Remove deletes the multihashes stored under the context ID, and advertises
+// their removal by the given provider. If p is empty, the removal is advertised
+// by the default provider of the engine. ErrNotFound is returned if no
+// multihashes are stored under the context ID.
+func (ms *MultihashSupplier) Remove(ctx context.Context, p peer.ID, contextID []byte) (cid.Cid, error) {
+	key := toMultihashesKey(contextID)
+	has, err := ms.ds.Has(ctx, key)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if !has {
+		return cid.Undef, ErrNotFound
+	}
+	advID, err := ms.eng.NotifyRemove(ctx, p, contextID)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if err = ms.ds.Delete(ctx, key); err != nil {
+		return cid.Undef, err
+	}
+	return advID, nil
+}
+
+// ListMultihashes supplies an iterator over the multihashes stored under the
+// given context ID. ErrNotFound is returned if no multihashes are stored under
+// the context ID.
+func (ms *MultihashSupplier) ListMultihashes(ctx context.Context, _ peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+	b, err := ms.ds.Get(ctx, toMultihashesKey(contextID))
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			err = ErrNotFound
+		}
+		return nil, err
+	}
+	var mhs []multihash.Multihash
+	r := multihash.NewReader(bytes.NewReader(b))
+	for {
+		mh, err := r.ReadMultihash()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		mhs = append(mhs, mh)
+	}
+	return provider.SliceMultihashIterator(mhs), nil
+}
+
+func toMultihashesKey(contextID []byte) datastore.Key {
+	return datastore.NewKey(multihashSupplierDatastorePrefix + "mhs/" + provider.ContextID(contextID).String())
+}
+
+// ChainListers returns a provider.MultihashLister that lists multihashes using
+// the first of the given listers that does not return ErrNotFound for the
+// context ID. This allows several suppliers to supply multihashes to one
+// engine.
+func ChainListers(listers ...provider.MultihashLister) provider.MultihashLister {
+	return func(ctx context.Context, p peer.ID, contextID []byte) (provider.MultihashIterator, error) {
+		for _, lister := range listers {
+			it, err := lister(ctx, p, contextID)
+			if !errors.Is(err, ErrNotFound) {
+				return it, err
+			}
+		}
+		return nil, ErrNotFound
+	}
+}
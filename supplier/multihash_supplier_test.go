@@ -0,0 +1,95 @@
+package supplier
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipni/go-libipni/metadata"
+	"github.com/ipni/go-libipni/test"
+	provider "github.com/ipni/index-provider"
+	"github.com/ipni/index-provider/engine"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultihashSupplier(t *testing.T) {
+	ctx := context.Background()
+	eng, err := engine.New(engine.WithPublisherKind(engine.NoPublisher))
+	require.NoError(t, err)
+	require.NoError(t, eng.Start(ctx))
+	t.Cleanup(func() { eng.Shutdown() })
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	cs := NewCarSupplier(eng, ds)
+	subject := NewMultihashSupplier(eng, ds)
+	eng.RegisterMultihashLister(ChainListers(cs.ListMultihashes, subject.ListMultihashes))
+
+	mhs := test.RandomMultihashes(5)
+	contextID := []byte("fish")
+	md := metadata.Default.New(metadata.Bitswap{})
+	adCid, err := subject.Put(ctx, nil, contextID, mhs, md)
+	require.NoError(t, err)
+	ad, err := eng.GetAdv(ctx, adCid)
+	require.NoError(t, err)
+	require.Equal(t, contextID, ad.ContextID)
+
+	it, err := subject.ListMultihashes(ctx, "", contextID)
+	require.NoError(t, err)
+	require.Equal(t, mhs, drain(it))
+
+	// Same multihashes and metadata.
+	_, err = subject.Put(ctx, nil, contextID, mhs, md)
+	require.ErrorIs(t, err, provider.ErrAlreadyAdvertised)
+	// Different multihashes.
+	_, err = subject.Put(ctx, nil, contextID, test.RandomMultihashes(2), md)
+	require.ErrorContains(t, err, "different multihashes")
+	// Updated metadata.
+	_, err = subject.Put(ctx, nil, contextID, mhs, metadata.Default.New(metadata.IpfsGatewayHttp{}))
+	require.NoError(t, err)
+
+	_, err = cs.ListMultihashes(ctx, "", contextID)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	adCid, err = subject.Remove(ctx, "", contextID)
+	require.NoError(t, err)
+	ad, err = eng.GetAdv(ctx, adCid)
+	require.NoError(t, err)
+	require.True(t, ad.IsRm)
+	_, err = subject.ListMultihashes(ctx, "", contextID)
+	require.ErrorIs(t, err, ErrNotFound)
+	_, err = subject.Remove(ctx, "", contextID)
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func drain(it provider.MultihashIterator) []multihash.Multihash {
+	var mhs []multihash.Multihash
+	for {
+		mh, err := it.Next()
+		if err != nil {
+			return mhs
+		}
+		mhs = append(mhs, mh)
+	}
+}
+
+func TestChainListers(t *testing.T) {
+	ctx := context.Background()
+	mhs := test.RandomMultihashes(1)
+	notFound := func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return nil, ErrNotFound
+	}
+	found := func(context.Context, peer.ID, []byte) (provider.MultihashIterator, error) {
+		return provider.SliceMultihashIterator(mhs), nil
+	}
+
+	_, err := ChainListers()(ctx, "", nil)
+	require.ErrorIs(t, err, ErrNotFound)
+	_, err = ChainListers(notFound, notFound)(ctx, "", nil)
+	require.ErrorIs(t, err, ErrNotFound)
+	it, err := ChainListers(notFound, found)(ctx, "", nil)
+	require.NoError(t, err)
+	require.Equal(t, mhs, drain(it))
+}
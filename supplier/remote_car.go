@@ -0,0 +1,322 @@
+package supplier
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ipld/go-car/v2"
+	"github.com/ipld/go-car/v2/index"
+	provider "github.com/ipni/index-provider"
+)
+
+// emptyPayloadHash is the hex encoded SHA-256 hash of an empty request body,
+// as signed by requests to S3 without a body.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// ErrRemoteCar signals that an operation is not supported for remote CARs.
+var ErrRemoteCar = errors.New("operation not supported for remote CAR")
+
+// IsRemoteCar returns whether the given location of a CAR is the URL of a
+// remote CAR, i.e. an http, https or s3 URL, rather than a local path.
+func IsRemoteCar(location string) bool {
+	scheme, _, ok := strings.Cut(location, "://")
+	if !ok {
+		return false
+	}
+	switch strings.ToLower(scheme) {
+	case "http", "https", "s3":
+		return true
+	}
+	return false
+}
+
+// S3Config configures access to CARs at s3://<bucket>/<key> URLs.
+type S3Config struct {
+	// Endpoint is the URL of the S3 service. If empty, the AWS endpoint of
+	// Region is used.
+	Endpoint string
+	// Region is the region of the buckets. If empty, us-east-1 is used.
+	Region string
+	// AccessKeyID, SecretAccessKey and SessionToken are the credentials with
+	// which requests are signed. If AccessKeyID is empty, requests are not
+	// signed, which allows access to public buckets only.
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// PathStyle addresses buckets by the path of URLs instead of their host,
+	// as required by some S3 compatible services.
+	PathStyle bool
+}
+
+// S3ConfigFromEnv returns the S3Config that is set by the standard AWS
+// environment variables AWS_ENDPOINT_URL_S3, AWS_ENDPOINT_URL, AWS_REGION,
+// AWS_DEFAULT_REGION, AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN. Path style addressing is used if a custom endpoint is
+// set.
+func S3ConfigFromEnv() S3Config {
+	cfg := S3Config{
+		Endpoint:        os.Getenv("AWS_ENDPOINT_URL_S3"),
+		Region:          os.Getenv("AWS_REGION"),
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = os.Getenv("AWS_ENDPOINT_URL")
+	}
+	if cfg.Region == "" {
+		cfg.Region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	cfg.PathStyle = cfg.Endpoint != ""
+	return cfg
+}
+
+// remoteCar reads a CAR at an http, https or s3 URL.
+type remoteCar struct {
+	client *http.Client
+	url    *url.URL
+	// s3 is the config with which requests are signed, if the CAR is in S3.
+	s3 *S3Config
+}
+
+func (cs *CarSupplier) openRemoteCar(location string) (*remoteCar, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CAR URL: %w", err)
+	}
+	rc := &remoteCar{
+		client: cs.httpClient,
+		url:    u,
+	}
+	if strings.EqualFold(u.Scheme, "s3") {
+		s3 := cs.s3
+		if rc.url, err = s3.objectURL(u.Host, strings.TrimPrefix(u.Path, "/")); err != nil {
+			return nil, err
+		}
+		rc.s3 = &s3
+	}
+	return rc, nil
+}
+
+// listRemoteMultihashes supplies an iterator over the multihashes of the
+// remote CAR at the given location. The index of a CARv2 with a
+// multicodec.CarMultihashIndexSorted index is fetched via a range request.
+// Otherwise, the CAR is streamed to generate the index.
+func (cs *CarSupplier) listRemoteMultihashes(ctx context.Context, location string) (provider.MultihashIterator, error) {
+	rc, err := cs.openRemoteCar(location)
+	if err != nil {
+		return nil, err
+	}
+
+	// The CARv2 pragma and header are at the beginning of the CAR.
+	prefix, err := rc.readRange(ctx, 0, car.PragmaSize+car.HeaderSize)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(prefix, car.Pragma) {
+		var header car.Header
+		if _, err = header.ReadFrom(bytes.NewReader(prefix[car.PragmaSize:])); err != nil {
+			return nil, fmt.Errorf("cannot read CARv2 header: %w", err)
+		}
+		if header.HasIndex() {
+			idxData, err := rc.readRange(ctx, int64(header.IndexOffset), -1)
+			if err != nil {
+				return nil, err
+			}
+			it, ok, err := newIndexIterator(bytes.NewReader(idxData), 0, int64(len(idxData)))
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				return it, nil
+			}
+		}
+	}
+
+	log.Debugw("Remote CAR has no iterable index; generating.", "url", rc.url.Redacted())
+	body, err := rc.get(ctx, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	idx := index.NewMultihashSorted()
+	if err = car.LoadIndex(idx, body, cs.opts...); err != nil {
+		return nil, err
+	}
+	return indexMultihashes(idx)
+}
+
+// readRange reads the given number of bytes of the CAR that start at offset,
+// or all remaining bytes if length is negative.
+func (rc *remoteCar) readRange(ctx context.Context, offset, length int64) ([]byte, error) {
+	body, err := rc.get(ctx, offset, length)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	if length >= 0 && int64(len(data)) < length {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return data, nil
+}
+
+// get requests the given number of bytes of the CAR that start at offset, or
+// all remaining bytes if length is negative. Servers that do not support range
+// requests are handled by skipping and limiting the response body.
+func (rc *remoteCar) get(ctx context.Context, offset, length int64) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rc.url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	ranged := offset != 0 || length >= 0
+	if ranged {
+		if length >= 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+		} else {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		}
+	}
+	if rc.s3 != nil {
+		rc.s3.sign(req, time.Now())
+	}
+
+	resp, err := rc.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		return resp.Body, nil
+	case http.StatusOK:
+		if !ranged {
+			return resp.Body, nil
+		}
+		if _, err = io.CopyN(io.Discard, resp.Body, offset); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		if length < 0 {
+			return resp.Body, nil
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{io.LimitReader(resp.Body, length), resp.Body}, nil
+	default:
+		resp.Body.Close()
+		return nil, fmt.Errorf("cannot get remote CAR %s: %s", rc.url.Redacted(), resp.Status)
+	}
+}
+
+func (c *S3Config) region() string {
+	if c.Region == "" {
+		return "us-east-1"
+	}
+	return c.Region
+}
+
+// objectURL returns the URL of the object with the given key in the given
+// bucket.
+func (c *S3Config) objectURL(bucket, key string) (*url.URL, error) {
+	if bucket == "" || key == "" {
+		return nil, errors.New("S3 URL must specify a bucket and key")
+	}
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + c.region() + ".amazonaws.com"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 endpoint: %w", err)
+	}
+	path := strings.TrimSuffix(u.Path, "/")
+	if c.PathStyle {
+		path += "/" + bucket
+	} else {
+		u.Host = bucket + "." + u.Host
+	}
+	u.Path = path + "/" + key
+	u.RawPath = s3URIEncode(u.Path)
+	return u, nil
+}
+
+// sign signs the given request with AWS signature version 4, if credentials
+// are configured.
+func (c *S3Config) sign(req *http.Request, now time.Time) {
+	if c.AccessKeyID == "" {
+		return
+	}
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	scope := date + "/" + c.region() + "/s3/aws4_request"
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + emptyPayloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	if c.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.SessionToken)
+		signedHeaders += ";x-amz-security-token"
+		canonicalHeaders += "x-amz-security-token:" + c.SessionToken + "\n"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		emptyPayloadHash,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+c.SecretAccessKey), date)
+	key = hmacSHA256(key, c.region())
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3URIEncode encodes the given path as S3 expects in signed requests, i.e.
+// escaping all bytes other than unreserved characters and slashes.
+func s3URIEncode(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}